@@ -0,0 +1,156 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"nofx/trader"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UpdateApprovalSettingsRequest 更新人工审批设置请求
+type UpdateApprovalSettingsRequest struct {
+	Required          bool    `json:"required"`
+	MinNotional       float64 `json:"min_notional"`
+	MinLeverage       int     `json:"min_leverage"`
+	ExpiryMinutes     int     `json:"expiry_minutes"`
+	PriceTolerancePct float64 `json:"price_tolerance_pct"`
+}
+
+// RejectProposalRequest 拒绝提案请求
+type RejectProposalRequest struct {
+	Note string `json:"note"`
+}
+
+// handleUpdateApprovalSettings 更新交易员人工审批设置
+func (s *Server) handleUpdateApprovalSettings(c *gin.Context) {
+	userID := c.GetString("user_id")
+	traderID := c.Param("id")
+
+	var req UpdateApprovalSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.MinNotional < 0 || req.MinLeverage < 0 || req.ExpiryMinutes < 0 || req.PriceTolerancePct < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "审批参数不能为负数"})
+		return
+	}
+	if req.ExpiryMinutes > 24*60 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "提案有效期不能超过24小时"})
+		return
+	}
+
+	traderRecord, _, _, err := s.database.GetTraderConfig(userID, traderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+		return
+	}
+	before := gin.H{
+		"required":            traderRecord.ApprovalRequired,
+		"min_notional":        traderRecord.ApprovalMinNotional,
+		"min_leverage":        traderRecord.ApprovalMinLeverage,
+		"expiry_minutes":      traderRecord.ApprovalExpiryMinutes,
+		"price_tolerance_pct": traderRecord.ApprovalPriceTolerancePct,
+	}
+
+	settings := trader.ApprovalSettings{
+		Required:          req.Required,
+		MinNotional:       req.MinNotional,
+		MinLeverage:       req.MinLeverage,
+		ExpiryMinutes:     req.ExpiryMinutes,
+		PriceTolerancePct: req.PriceTolerancePct,
+	}
+
+	// 同步到内存中的交易员（会补齐默认值）
+	if at, err := s.traderManager.GetTrader(traderID); err == nil {
+		at.SetApprovalSettings(settings)
+		settings = at.GetApprovalSettings()
+	}
+
+	err = s.database.UpdateTraderApprovalSettings(userID, traderID, settings.Required, settings.MinNotional,
+		settings.MinLeverage, settings.ExpiryMinutes, settings.PriceTolerancePct)
+	s.recordAudit(c, auditEntry{
+		Action:     "update_approval_settings",
+		TargetType: "trader",
+		TargetID:   traderID,
+		TraderID:   traderID,
+		Before:     before,
+		After:      settings,
+		Err:        err,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("保存审批设置失败: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "审批设置已更新", "approval": settings})
+}
+
+// handleListProposals 获取交易员的开仓提案（?status=pending|approved|rejected|expired|failed）
+func (s *Server) handleListProposals(c *gin.Context) {
+	at, ok := s.getOwnedAutoTrader(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, at.GetProposals(c.Query("status")))
+}
+
+// handleApproveProposal 批准开仓提案（价格仍在容差范围内时执行）
+func (s *Server) handleApproveProposal(c *gin.Context) {
+	traderID := c.Param("id")
+	proposalID := c.Param("proposal_id")
+
+	at, ok := s.getOwnedAutoTrader(c)
+	if !ok {
+		return
+	}
+
+	action, err := at.ApproveProposal(proposalID, "api:"+c.GetString("user_id"))
+	s.recordAudit(c, auditEntry{
+		Action:     "approve_proposal",
+		TargetType: "proposal",
+		TargetID:   proposalID,
+		TraderID:   traderID,
+		After:      action,
+		Err:        err,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("提案执行失败: %v", err), "action": action})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "提案已批准并执行", "action": action})
+}
+
+// handleRejectProposal 拒绝开仓提案
+func (s *Server) handleRejectProposal(c *gin.Context) {
+	traderID := c.Param("id")
+	proposalID := c.Param("proposal_id")
+
+	var req RejectProposalRequest
+	// 请求体可选
+	_ = c.ShouldBindJSON(&req)
+
+	at, ok := s.getOwnedAutoTrader(c)
+	if !ok {
+		return
+	}
+
+	err := at.RejectProposal(proposalID, "api:"+c.GetString("user_id"), req.Note)
+	s.recordAudit(c, auditEntry{
+		Action:     "reject_proposal",
+		TargetType: "proposal",
+		TargetID:   proposalID,
+		TraderID:   traderID,
+		After:      req,
+		Err:        err,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "提案已拒绝"})
+}
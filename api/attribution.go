@@ -0,0 +1,61 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"nofx/logger"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleGetAttribution 交易归因报告：开仓决策（信心度、风险、行情快照）与平仓结果关联并聚合
+func (s *Server) handleGetAttribution(c *gin.Context) {
+	at, ok := s.getOwnedAutoTrader(c)
+	if !ok {
+		return
+	}
+
+	report, err := logger.AnalyzeAttribution(at.GetID(), at.GetDecisionLogger())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("生成归因报告失败: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// handleExportAttribution 导出逐笔交易归因（?format=csv|json）
+func (s *Server) handleExportAttribution(c *gin.Context) {
+	format := strings.ToLower(c.DefaultQuery("format", "csv"))
+	if format != "csv" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format 仅支持 csv 或 json"})
+		return
+	}
+
+	at, ok := s.getOwnedAutoTrader(c)
+	if !ok {
+		return
+	}
+
+	report, err := logger.AnalyzeAttribution(at.GetID(), at.GetDecisionLogger())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("生成归因报告失败: %v", err)})
+		return
+	}
+
+	filename := fmt.Sprintf("attribution_%s_%s.%s", at.GetID(), time.Now().Format("20060102_150405"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+
+	if format == "json" {
+		c.JSON(http.StatusOK, report.Trades)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := logger.WriteAttributionCSV(c.Writer, report.Trades); err != nil {
+		log.Printf("⚠️ 导出交易归因 CSV 失败: %v", err)
+	}
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestAttribution_Validation 测试归因、校准报告与市场状态接口的导出格式校验与归属校验
+func TestAttribution_Validation(t *testing.T) {
+	server, db, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	userID, _, _ := setupTestEnv(t, db)
+
+	router := gin.New()
+	withUser := func(handler gin.HandlerFunc) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			c.Set("user_id", userID)
+			handler(c)
+		}
+	}
+	router.GET("/traders/:id/attribution", withUser(server.handleGetAttribution))
+	router.GET("/traders/:id/attribution/export", withUser(server.handleExportAttribution))
+	router.GET("/traders/:id/calibration", withUser(server.handleGetCalibration))
+	router.GET("/traders/:id/regime", withUser(server.handleGetRegime))
+
+	tests := []struct {
+		name     string
+		path     string
+		wantCode int
+	}{
+		{"交易员不存在", "/traders/not-exist/attribution", http.StatusNotFound},
+		{"导出_交易员不存在", "/traders/not-exist/attribution/export", http.StatusNotFound},
+		{"无效导出格式", "/traders/t1/attribution/export?format=xlsx", http.StatusBadRequest},
+		{"校准报告_交易员不存在", "/traders/not-exist/calibration", http.StatusNotFound},
+		{"市场状态_交易员不存在", "/traders/not-exist/regime", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest("GET", tt.path, nil))
+			if w.Code != tt.wantCode {
+				t.Errorf("期望状态码 %d，实际 %d: %s", tt.wantCode, w.Code, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"nofx/config"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// auditSensitiveSuffixes 审计快照中需要脱敏的字段名后缀（忽略大小写和下划线）
+// 按后缀而非子串匹配，避免 prompt_token_budget、otp_verified 等普通配置被误脱敏
+var auditSensitiveSuffixes = []string{
+	"apikey", "secretkey", "privatekey", "wrappedkey",
+	"secret", "password", "passwordhash", "otpcode",
+	"accesstoken", "refreshtoken", "bottoken",
+}
+
+// auditEntry 一次写操作的审计信息
+type auditEntry struct {
+	UserID     string      // 为空时使用上下文中的 user_id（未认证接口需显式传入）
+	Action     string      // 操作类型
+	TargetType string      // 目标类型
+	TargetID   string      // 目标ID
+	TraderID   string      // 关联交易员
+	Before     interface{} // 变更前快照
+	After      interface{} // 变更后快照
+	Err        error       // 操作失败原因
+}
+
+// isSensitiveAuditKey 判断字段名是否属于敏感字段
+func isSensitiveAuditKey(key string) bool {
+	normalized := strings.ToLower(strings.ReplaceAll(key, "_", ""))
+	for _, suffix := range auditSensitiveSuffixes {
+		if strings.HasSuffix(normalized, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactAuditValue 递归脱敏 JSON 结构中的敏感字段
+func redactAuditValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if isSensitiveAuditKey(key) {
+				if str, ok := item.(string); ok {
+					v[key] = MaskSensitiveString(str)
+				} else if item != nil {
+					v[key] = "****"
+				}
+				continue
+			}
+			v[key] = redactAuditValue(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactAuditValue(item)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// snapshotForAudit 将任意结构序列化为脱敏后的 JSON 字符串
+func snapshotForAudit(value interface{}) string {
+	if value == nil {
+		return ""
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	var generic interface{}
+	if err := json.Unmarshal(raw, &generic); err != nil {
+		return ""
+	}
+	if generic == nil {
+		return ""
+	}
+	redacted, err := json.Marshal(redactAuditValue(generic))
+	if err != nil {
+		return ""
+	}
+	return string(redacted)
+}
+
+// joinMapKeys 将批量更新的目标ID按字典序拼接（用于 TargetID）
+func joinMapKeys[V any](m map[string]V) string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+// recordAudit 记录一次写操作（审计失败只打日志，不影响业务请求）
+func (s *Server) recordAudit(c *gin.Context, entry auditEntry) {
+	if s.database == nil {
+		return
+	}
+
+	userID := entry.UserID
+	if userID == "" {
+		userID = c.GetString("user_id")
+	}
+
+	record := &config.AuditLogRecord{
+		UserID:     userID,
+		IPAddress:  c.ClientIP(),
+		Action:     entry.Action,
+		TargetType: entry.TargetType,
+		TargetID:   entry.TargetID,
+		TraderID:   entry.TraderID,
+		Before:     snapshotForAudit(entry.Before),
+		After:      snapshotForAudit(entry.After),
+		Success:    entry.Err == nil,
+	}
+	if entry.Err != nil {
+		record.Error = entry.Err.Error()
+	}
+
+	if err := s.database.CreateAuditLog(record); err != nil {
+		log.Printf("⚠️ 记录审计日志失败 [%s %s/%s]: %v", entry.Action, entry.TargetType, entry.TargetID, err)
+	}
+}
+
+// parseAuditTime 解析时间参数（支持 RFC3339 / 2006-01-02 / Unix 秒）
+func parseAuditTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if ts, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(ts, 0), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("无效的时间格式: %s", value)
+}
+
+// buildAuditFilter 从查询参数构建过滤条件，并限制普通用户只能查看自己的记录
+func (s *Server) buildAuditFilter(c *gin.Context) (config.AuditLogFilter, int, error) {
+	userID := c.GetString("user_id")
+	filter := config.AuditLogFilter{
+		UserID:   c.Query("user_id"),
+		TraderID: c.Query("trader_id"),
+		Action:   c.Query("action"),
+	}
+
+	// 只有 admin 可以查看其他用户的审计记录
+	if userID != "admin" {
+		if filter.UserID != "" && filter.UserID != userID {
+			return filter, http.StatusForbidden, fmt.Errorf("无权查看其他用户的审计日志")
+		}
+		filter.UserID = userID
+	}
+
+	var err error
+	if filter.Since, err = parseAuditTime(c.Query("from")); err != nil {
+		return filter, http.StatusBadRequest, err
+	}
+	if filter.Until, err = parseAuditTime(c.Query("to")); err != nil {
+		return filter, http.StatusBadRequest, err
+	}
+
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			return filter, http.StatusBadRequest, fmt.Errorf("无效的 limit 参数")
+		}
+		if limit > 1000 {
+			limit = 1000
+		}
+		filter.Limit = limit
+	}
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		offset, err := strconv.Atoi(offsetStr)
+		if err != nil || offset < 0 {
+			return filter, http.StatusBadRequest, fmt.Errorf("无效的 offset 参数")
+		}
+		filter.Offset = offset
+	}
+
+	return filter, http.StatusOK, nil
+}
+
+// handleGetAuditLogs 查询审计日志（?user_id=&trader_id=&action=&from=&to=&limit=&offset=）
+func (s *Server) handleGetAuditLogs(c *gin.Context) {
+	filter, status, err := s.buildAuditFilter(c)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	records, err := s.database.QueryAuditLogs(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("查询审计日志失败: %v", err)})
+		return
+	}
+	if records == nil {
+		records = []*config.AuditLogRecord{}
+	}
+
+	c.JSON(http.StatusOK, records)
+}
+
+// handleExportAuditLogs 导出审计日志（?format=csv|json，过滤参数同查询接口）
+func (s *Server) handleExportAuditLogs(c *gin.Context) {
+	filter, status, err := s.buildAuditFilter(c)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	if c.Query("limit") == "" {
+		filter.Limit = 10000
+	}
+
+	format := strings.ToLower(c.DefaultQuery("format", "csv"))
+	if format != "csv" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format 仅支持 csv 或 json"})
+		return
+	}
+
+	records, err := s.database.QueryAuditLogs(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("查询审计日志失败: %v", err)})
+		return
+	}
+	if records == nil {
+		records = []*config.AuditLogRecord{}
+	}
+
+	filename := fmt.Sprintf("audit_logs_%s.%s", time.Now().Format("20060102_150405"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+
+	if format == "json" {
+		c.JSON(http.StatusOK, records)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Status(http.StatusOK)
+	writer := csv.NewWriter(c.Writer)
+	_ = writer.Write([]string{"id", "created_at", "user_id", "ip_address", "action", "target_type", "target_id", "trader_id", "success", "error", "before", "after"})
+	for _, record := range records {
+		_ = writer.Write([]string{
+			strconv.FormatInt(record.ID, 10),
+			record.CreatedAt.UTC().Format(time.RFC3339),
+			record.UserID,
+			record.IPAddress,
+			record.Action,
+			record.TargetType,
+			record.TargetID,
+			record.TraderID,
+			strconv.FormatBool(record.Success),
+			record.Error,
+			record.Before,
+			record.After,
+		})
+	}
+	writer.Flush()
+}
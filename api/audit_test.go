@@ -0,0 +1,130 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"nofx/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestSnapshotForAudit_RedactsSecrets 测试审计快照会脱敏所有敏感字段
+func TestSnapshotForAudit_RedactsSecrets(t *testing.T) {
+	snapshot := snapshotForAudit(map[string]interface{}{
+		"name":              "binance",
+		"api_key":           "abcd1234efgh5678",
+		"secretKey":         "secret-value-123456",
+		"aster_private_key": "0xdeadbeefcafebabe",
+		"nested": []interface{}{
+			map[string]interface{}{"apiKey": "sk-1234567890abcdef", "enabled": true},
+		},
+		"password_hash":       "$2a$10$abcdefghijklmnop",
+		"otp_secret":          "JBSWY3DPEHPK3PXP",
+		"prompt_token_budget": 4096,
+		"otp_verified":        true,
+	})
+
+	for _, secret := range []string{"abcd1234efgh5678", "secret-value-123456", "0xdeadbeefcafebabe", "sk-1234567890abcdef", "$2a$10$abcdefghijklmnop", "JBSWY3DPEHPK3PXP"} {
+		if strings.Contains(snapshot, secret) {
+			t.Errorf("快照中不应包含明文敏感信息 %q: %s", secret, snapshot)
+		}
+	}
+	if !strings.Contains(snapshot, `"name":"binance"`) || !strings.Contains(snapshot, `"enabled":true`) ||
+		!strings.Contains(snapshot, `"prompt_token_budget":4096`) || !strings.Contains(snapshot, `"otp_verified":true`) {
+		t.Errorf("非敏感字段应保留原值: %s", snapshot)
+	}
+
+	if snapshotForAudit(nil) != "" {
+		t.Errorf("nil 快照应为空字符串")
+	}
+}
+
+// TestAuditLogs_RecordedAndScopedByUser 测试写操作会产生审计记录，且普通用户只能查看自己的记录
+func TestAuditLogs_RecordedAndScopedByUser(t *testing.T) {
+	server, db, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	userID, _, _ := setupTestEnv(t, db)
+
+	router := gin.New()
+	router.POST("/user/signal-sources", func(c *gin.Context) {
+		c.Set("user_id", userID)
+		server.handleSaveUserSignalSource(c)
+	})
+	router.GET("/audit-logs", func(c *gin.Context) {
+		c.Set("user_id", userID)
+		server.handleGetAuditLogs(c)
+	})
+	router.GET("/audit-logs/export", func(c *gin.Context) {
+		c.Set("user_id", userID)
+		server.handleExportAuditLogs(c)
+	})
+
+	body, _ := json.Marshal(map[string]string{"coin_pool_url": "https://pool.example.com?token=abcdef123456", "oi_top_url": ""})
+	req := httptest.NewRequest("POST", "/user/signal-sources", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = "10.1.2.3:4567"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("保存信号源失败: %d %s", w.Code, w.Body.String())
+	}
+
+	// 其他用户的记录不应被看到
+	if err := db.CreateAuditLog(&config.AuditLogRecord{UserID: "someone-else", Action: "delete_trader", Success: true}); err != nil {
+		t.Fatalf("写入审计日志失败: %v", err)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/audit-logs", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("查询审计日志失败: %d %s", w.Code, w.Body.String())
+	}
+	var records []config.AuditLogRecord
+	if err := json.Unmarshal(w.Body.Bytes(), &records); err != nil {
+		t.Fatalf("解析响应失败: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("应只返回当前用户的 1 条记录，实际 %d", len(records))
+	}
+	record := records[0]
+	if record.Action != "save_signal_source" || record.UserID != userID || record.IPAddress != "10.1.2.3" || !record.Success {
+		t.Errorf("审计记录字段不正确: %+v", record)
+	}
+	if !strings.Contains(record.After, "pool.example.com") {
+		t.Errorf("After 快照应包含新配置: %s", record.After)
+	}
+
+	// 查询其他用户应被拒绝
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/audit-logs?user_id=someone-else", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("查询其他用户应返回 403，实际 %d", w.Code)
+	}
+
+	// 无效时间参数
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/audit-logs?from=yesterday", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("无效时间参数应返回 400，实际 %d", w.Code)
+	}
+
+	// CSV 导出
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/audit-logs/export?format=csv", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("导出失败: %d %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Header().Get("Content-Disposition"), "attachment") {
+		t.Errorf("导出应以附件形式返回")
+	}
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "id,created_at,user_id") || !strings.Contains(lines[1], "save_signal_source") {
+		t.Errorf("CSV 导出内容不正确: %s", w.Body.String())
+	}
+}
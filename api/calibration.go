@@ -0,0 +1,36 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"nofx/logger"
+	"nofx/trader"
+
+	"github.com/gin-gonic/gin"
+)
+
+// validatePositionSizing 校验仓位计算模式与分数Kelly系数（0 表示使用默认值）
+func validatePositionSizing(mode string, kellyFraction float64) error {
+	if !trader.IsValidPositionSizingMode(mode) {
+		return fmt.Errorf("仓位计算模式必须是 %s 或 %s", trader.PositionSizingAI, trader.PositionSizingKelly)
+	}
+	if kellyFraction < 0 || kellyFraction > trader.MaxKellyFraction {
+		return fmt.Errorf("分数Kelly系数必须在0-%.0f之间（0表示使用默认值%.2f）", trader.MaxKellyFraction, trader.DefaultKellyFraction)
+	}
+	return nil
+}
+
+// handleGetCalibration 信心度校准报告：各信心度十分位的实际胜率、期望与Kelly比例
+func (s *Server) handleGetCalibration(c *gin.Context) {
+	at, ok := s.getOwnedAutoTrader(c)
+	if !ok {
+		return
+	}
+
+	report, err := logger.AnalyzeCalibration(at.GetID(), at.GetDecisionLogger())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("生成校准报告失败: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,251 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"nofx/events"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// 实时推送参数
+const (
+	eventStreamHeartbeat  = 15 * time.Second
+	eventStreamBufferSize = 256
+	eventWSWriteTimeout   = 10 * time.Second
+)
+
+// 来源已由 corsMiddleware 校验，这里不再重复检查
+var eventUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 4096,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+// SetEventBus 设置实时事件总线（为 nil 时推送接口返回 503）
+func (s *Server) SetEventBus(bus *events.Bus) {
+	s.eventBus = bus
+}
+
+// allowQueryToken 允许通过 ?token= 传递JWT（浏览器 EventSource/WebSocket 无法自定义请求头）
+func allowQueryToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			if token := c.Query("token"); token != "" {
+				c.Request.Header.Set("Authorization", "Bearer "+token)
+			}
+		}
+		c.Next()
+	}
+}
+
+// redactedLogFormatter gin 访问日志格式（与默认格式一致，但隐去 ?token= 中的JWT，避免写入访问日志）
+func redactedLogFormatter(param gin.LogFormatterParams) string {
+	if param.Latency > time.Minute {
+		param.Latency = param.Latency.Truncate(time.Second)
+	}
+	return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		param.StatusCode,
+		param.Latency,
+		param.ClientIP,
+		param.Method,
+		redactQueryToken(param.Path),
+		param.ErrorMessage,
+	)
+}
+
+// redactQueryToken 将请求路径中 token 查询参数的值替换为 REDACTED（查询串无法解析时整体去掉）
+func redactQueryToken(path string) string {
+	i := strings.IndexByte(path, '?')
+	if i < 0 {
+		return path
+	}
+	query, err := url.ParseQuery(path[i+1:])
+	if err != nil {
+		return path[:i]
+	}
+	if !query.Has("token") {
+		return path
+	}
+	query.Set("token", "REDACTED")
+	return path[:i] + "?" + query.Encode()
+}
+
+// subscribeEvents 按当前用户订阅事件（?trader_id= 过滤交易员，?types= 逗号分隔过滤事件类型）
+// 携带 Last-Event-ID 头或 ?last_event_id= 时补发断线期间的事件
+func (s *Server) subscribeEvents(c *gin.Context) (*events.Subscription, []events.Event, bool) {
+	if s.eventBus == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "实时推送未启用"})
+		return nil, nil, false
+	}
+
+	userID := c.GetString("user_id")
+	traderID := c.Query("trader_id")
+	if traderID != "" {
+		if _, _, _, err := s.database.GetTraderConfig(userID, traderID); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+			return nil, nil, false
+		}
+	}
+
+	types := map[string]bool{}
+	for _, t := range strings.Split(c.Query("types"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types[t] = true
+		}
+	}
+
+	lastIDStr := c.GetHeader("Last-Event-ID")
+	if lastIDStr == "" {
+		lastIDStr = c.Query("last_event_id")
+	}
+	var lastID uint64
+	if lastIDStr != "" {
+		parsed, err := strconv.ParseUint(lastIDStr, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的last_event_id"})
+			return nil, nil, false
+		}
+		lastID = parsed
+	}
+
+	filter := func(e events.Event) bool {
+		if e.UserID != userID {
+			return false
+		}
+		if traderID != "" && e.TraderID != traderID {
+			return false
+		}
+		return len(types) == 0 || types[e.Type]
+	}
+	sub, missed := s.eventBus.Subscribe(filter, lastID, eventStreamBufferSize)
+	return sub, missed, true
+}
+
+// handleEventStream 通过 Server-Sent Events 推送交易员事件
+func (s *Server) handleEventStream(c *gin.Context) {
+	sub, missed, ok := s.subscribeEvents(c)
+	if !ok {
+		return
+	}
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // 关闭 nginx 缓冲
+	c.Status(http.StatusOK)
+
+	write := func(e events.Event) bool {
+		data, err := json.Marshal(e)
+		if err != nil {
+			log.Printf("⚠️ [事件推送] 序列化事件失败: %v", err)
+			return true
+		}
+		if _, err := fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data); err != nil {
+			return false
+		}
+		c.Writer.Flush()
+		return true
+	}
+
+	// 先发送一条注释，让客户端尽快确认连接
+	fmt.Fprint(c.Writer, ": connected\n\n")
+	c.Writer.Flush()
+	for _, e := range missed {
+		if !write(e) {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(eventStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				// 消费过慢被关闭，客户端会携带 Last-Event-ID 自动重连
+				return
+			}
+			if !write(e) {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// handleEventWebSocket 通过 WebSocket 推送交易员事件（每条消息为一个JSON事件）
+func (s *Server) handleEventWebSocket(c *gin.Context) {
+	sub, missed, ok := s.subscribeEvents(c)
+	if !ok {
+		return
+	}
+	defer sub.Close()
+
+	conn, err := eventUpgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("⚠️ [事件推送] WebSocket升级失败: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	// 读循环：仅用于感知客户端断开（客户端无需发送消息）
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	write := func(e events.Event) bool {
+		conn.SetWriteDeadline(time.Now().Add(eventWSWriteTimeout))
+		return conn.WriteJSON(e) == nil
+	}
+	for _, e := range missed {
+		if !write(e) {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(eventStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case e, ok := <-sub.C:
+			if !ok {
+				conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "slow consumer"), time.Now().Add(time.Second))
+				return
+			}
+			if !write(e) {
+				return
+			}
+		case <-heartbeat.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(eventWSWriteTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
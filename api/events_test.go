@@ -0,0 +1,201 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"nofx/events"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// setupEventRouter 创建带事件总线的测试路由（跳过JWT，直接注入用户）
+func setupEventRouter(t *testing.T) (*httptest.Server, *events.Bus, string) {
+	server, db, cleanup := setupTestServer(t)
+	t.Cleanup(cleanup)
+	userID, _, _ := setupTestEnv(t, db)
+
+	bus := events.NewBus(100)
+	server.SetEventBus(bus)
+
+	router := gin.New()
+	withUser := func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Next()
+	}
+	router.GET("/events/stream", withUser, server.handleEventStream)
+	router.GET("/events/ws", withUser, server.handleEventWebSocket)
+
+	ts := httptest.NewServer(router)
+	t.Cleanup(ts.Close)
+	return ts, bus, userID
+}
+
+// waitSubscribers 等待订阅建立
+func waitSubscribers(t *testing.T, bus *events.Bus, n int) {
+	deadline := time.Now().Add(3 * time.Second)
+	for bus.SubscriberCount() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("订阅未建立")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestEventStream_SSE 测试 SSE 只推送当前用户的事件，并支持断线补发
+func TestEventStream_SSE(t *testing.T) {
+	ts, bus, userID := setupEventRouter(t)
+
+	bus.Publish(events.Event{Type: events.TypeCycleStarted, UserID: userID, TraderID: "t1"}) // id=1
+
+	req, _ := http.NewRequest("GET", ts.URL+"/events/stream?last_event_id=0&types=cycle_started,decision", nil)
+	req.Header.Set("Last-Event-ID", "0")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("请求失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Fatalf("Content-Type 错误: %s", ct)
+	}
+
+	waitSubscribers(t, bus, 1)
+	bus.Publish(events.Event{Type: events.TypeDecision, UserID: "other-user", TraderID: "t2"})
+	bus.Publish(events.Event{Type: events.TypeBalanceUpdate, UserID: userID, TraderID: "t1"})
+	bus.Publish(events.Event{Type: events.TypeDecision, UserID: userID, TraderID: "t1", Data: map[string]interface{}{"cycle": 3}})
+
+	reader := bufio.NewReader(resp.Body)
+	var ids, types []string
+	for len(types) < 1 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("读取事件失败: %v", err)
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			ids = append(ids, strings.TrimPrefix(line, "id: "))
+		case strings.HasPrefix(line, "event: "):
+			types = append(types, strings.TrimPrefix(line, "event: "))
+		case strings.HasPrefix(line, "data: "):
+			var e map[string]interface{}
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e); err != nil {
+				t.Fatalf("事件数据不是JSON: %v", err)
+			}
+			if _, exists := e["UserID"]; exists {
+				t.Errorf("不应输出用户ID")
+			}
+		}
+	}
+	if types[0] != events.TypeDecision || ids[0] != "4" {
+		t.Errorf("应只收到当前用户的 decision 事件，实际 %v %v", types, ids)
+	}
+}
+
+// TestEventStream_Validation 测试未启用推送与越权订阅
+func TestEventStream_Validation(t *testing.T) {
+	server, db, cleanup := setupTestServer(t)
+	defer cleanup()
+	userID, _, _ := setupTestEnv(t, db)
+
+	router := gin.New()
+	router.GET("/events/stream", func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Next()
+	}, server.handleEventStream)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/events/stream", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("未启用推送时应返回 503，实际 %d", w.Code)
+	}
+
+	server.SetEventBus(events.NewBus(10))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/events/stream?trader_id=other-users-trader", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("订阅他人交易员应返回 404，实际 %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/events/stream?last_event_id=abc", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("无效 last_event_id 应返回 400，实际 %d", w.Code)
+	}
+}
+
+// TestEventStream_WebSocket 测试 WebSocket 推送
+func TestEventStream_WebSocket(t *testing.T) {
+	ts, bus, userID := setupEventRouter(t)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(ts.URL, "http")+"/events/ws", nil)
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	defer conn.Close()
+
+	waitSubscribers(t, bus, 1)
+	bus.Publish(events.Event{Type: events.TypeRiskVeto, UserID: "other-user"})
+	bus.Publish(events.Event{Type: events.TypePositionOpened, UserID: userID, TraderID: "t1"})
+
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	var e events.Event
+	if err := conn.ReadJSON(&e); err != nil {
+		t.Fatalf("读取事件失败: %v", err)
+	}
+	if e.Type != events.TypePositionOpened || e.TraderID != "t1" {
+		t.Errorf("事件错误: %+v", e)
+	}
+}
+
+// TestAllowQueryToken 测试查询参数中的 token 转为 Authorization 头
+func TestAllowQueryToken(t *testing.T) {
+	router := gin.New()
+	router.GET("/x", allowQueryToken(), func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetHeader("Authorization"))
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/x?token=abc", nil))
+	if w.Body.String() != "Bearer abc" {
+		t.Errorf("应使用查询参数中的token，实际 %q", w.Body.String())
+	}
+
+	req := httptest.NewRequest("GET", "/x?token=abc", nil)
+	req.Header.Set("Authorization", "Bearer header")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Body.String() != "Bearer header" {
+		t.Errorf("请求头优先，实际 %q", w.Body.String())
+	}
+}
+
+// TestRedactedLogFormatter 测试访问日志不包含查询参数中的JWT
+func TestRedactedLogFormatter(t *testing.T) {
+	var buf bytes.Buffer
+	router := gin.New()
+	router.Use(gin.LoggerWithConfig(gin.LoggerConfig{Formatter: redactedLogFormatter, Output: &buf}))
+	router.GET("/api/events/stream", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/events/stream?trader_id=t1&token=eyJhbGciOi.secret", nil))
+	line := buf.String()
+	if strings.Contains(line, "eyJhbGciOi") {
+		t.Errorf("访问日志不应包含token: %s", line)
+	}
+	if !strings.Contains(line, "token=REDACTED") || !strings.Contains(line, "trader_id=t1") {
+		t.Errorf("应保留其他查询参数并标记token已隐去: %s", line)
+	}
+
+	if got := redactQueryToken("/api/traders?id=1"); got != "/api/traders?id=1" {
+		t.Errorf("无token的路径应保持不变，实际 %q", got)
+	}
+	if got := redactQueryToken("/x?token=abc;%zz"); got != "/x" {
+		t.Errorf("无法解析的查询串应整体去掉，实际 %q", got)
+	}
+}
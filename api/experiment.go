@@ -0,0 +1,229 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"nofx/config"
+	"nofx/decision"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// experimentArmNamePattern 实验组名称（用于影子交易员ID与日志目录）
+var experimentArmNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// maxExperimentVariants 单个实验最多的实验组数量（每个实验组每周期都会调用一次AI）
+const maxExperimentVariants = 3
+
+// experimentRequest 创建实验请求
+type experimentRequest struct {
+	Name            string                 `json:"name"`
+	ControlTraderID string                 `json:"control_trader_id" binding:"required"`
+	Variants        []config.ExperimentArm `json:"variants" binding:"required"`
+}
+
+// validate 校验实验组配置（模板在用户的命名空间中查找）
+func (r *experimentRequest) validate(userID string) error {
+	if len(r.Variants) == 0 {
+		return fmt.Errorf("至少需要一个实验组")
+	}
+	if len(r.Variants) > maxExperimentVariants {
+		return fmt.Errorf("实验组最多%d个", maxExperimentVariants)
+	}
+	seen := make(map[string]bool)
+	for i := range r.Variants {
+		arm := &r.Variants[i]
+		arm.Name = strings.TrimSpace(arm.Name)
+		if arm.Name == "" {
+			arm.Name = fmt.Sprintf("variant%d", i+1)
+		}
+		if !experimentArmNamePattern.MatchString(arm.Name) {
+			return fmt.Errorf("实验组名称 %s 无效（仅支持字母、数字、下划线和短横线，最长32个字符）", arm.Name)
+		}
+		if arm.Name == config.ExperimentControlArm || seen[arm.Name] {
+			return fmt.Errorf("实验组名称 %s 重复或与对照组冲突", arm.Name)
+		}
+		seen[arm.Name] = true
+
+		if arm.AIModelID == "" && arm.SystemPromptTemplate == "" && arm.UserPromptTemplate == "" {
+			return fmt.Errorf("实验组 %s 未指定与对照组不同的模型或提示词模板", arm.Name)
+		}
+		if arm.SystemPromptTemplate != "" && !decision.TemplateExistsFor(userID, arm.SystemPromptTemplate) {
+			return fmt.Errorf("系统提示词模板 %s 不存在", arm.SystemPromptTemplate)
+		}
+		if arm.UserPromptTemplate != "" && !decision.UserPromptTemplateExists(arm.UserPromptTemplate) {
+			return fmt.Errorf("用户提示词模板 %s 不存在", arm.UserPromptTemplate)
+		}
+	}
+	return nil
+}
+
+// experimentErrorStatus 实验相关错误对应的HTTP状态码
+func experimentErrorStatus(err error) int {
+	if strings.Contains(err.Error(), "不存在") {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+// handleGetExperiments 获取当前用户的实验列表
+func (s *Server) handleGetExperiments(c *gin.Context) {
+	experiments, err := s.database.GetExperiments(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取实验失败: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"experiments": experiments})
+}
+
+// handleGetExperiment 获取实验详情
+func (s *Server) handleGetExperiment(c *gin.Context) {
+	exp, err := s.database.GetExperiment(c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		c.JSON(experimentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, exp)
+}
+
+// handleCreateExperiment 创建并启动A/B实验
+// 对照组交易员照常真实下单，每个实验组生成一个共享对照组行情、模拟下单的影子交易员
+func (s *Server) handleCreateExperiment(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var req experimentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+	if err := req.validate(userID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, _, _, err := s.database.GetTraderConfig(userID, req.ControlTraderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在"})
+		return
+	}
+	if running, err := s.database.GetRunningExperimentForTrader(userID, req.ControlTraderID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	} else if running != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("交易员已在实验 %s 中，请先结束该实验", running.ID)})
+		return
+	}
+	if err := s.traderManager.LoadTraderByID(s.database, userID, req.ControlTraderID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("加载交易员失败: %v", err)})
+		return
+	}
+
+	exp := &config.Experiment{
+		UserID:          userID,
+		Name:            req.Name,
+		ControlTraderID: req.ControlTraderID,
+		Variants:        req.Variants,
+	}
+	err := s.database.CreateExperiment(exp)
+	if err == nil {
+		if err = s.traderManager.StartExperiment(exp, s.database); err != nil {
+			_ = s.database.DeleteExperiment(userID, exp.ID)
+		}
+	}
+	s.recordAudit(c, auditEntry{
+		Action:     "create_experiment",
+		TargetType: "experiment",
+		TargetID:   exp.ID,
+		TraderID:   req.ControlTraderID,
+		After:      exp,
+		Err:        err,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("启动实验失败: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusCreated, exp)
+}
+
+// handleStopExperiment 结束实验（影子交易员停止，决策日志保留用于报告）
+func (s *Server) handleStopExperiment(c *gin.Context) {
+	userID := c.GetString("user_id")
+	exp, err := s.database.GetExperiment(userID, c.Param("id"))
+	if err != nil {
+		c.JSON(experimentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	err = s.database.StopExperiment(userID, exp.ID)
+	if err == nil {
+		s.traderManager.StopExperiment(exp.ControlTraderID)
+	}
+	s.recordAudit(c, auditEntry{
+		Action:     "stop_experiment",
+		TargetType: "experiment",
+		TargetID:   exp.ID,
+		TraderID:   exp.ControlTraderID,
+		Before:     gin.H{"status": exp.Status},
+		After:      gin.H{"status": config.ExperimentStatusStopped},
+		Err:        err,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "实验已结束"})
+}
+
+// handleDeleteExperiment 删除实验记录（进行中的实验会先结束）
+func (s *Server) handleDeleteExperiment(c *gin.Context) {
+	userID := c.GetString("user_id")
+	exp, err := s.database.GetExperiment(userID, c.Param("id"))
+	if err != nil {
+		c.JSON(experimentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if exp.Status == config.ExperimentStatusRunning {
+		s.traderManager.StopExperiment(exp.ControlTraderID)
+	}
+	err = s.database.DeleteExperiment(userID, exp.ID)
+	s.recordAudit(c, auditEntry{
+		Action:     "delete_experiment",
+		TargetType: "experiment",
+		TargetID:   exp.ID,
+		TraderID:   exp.ControlTraderID,
+		Before:     exp,
+		Err:        err,
+	})
+	if err != nil {
+		c.JSON(experimentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "实验已删除"})
+}
+
+// handleGetExperimentReport 实验报告：各组胜率、盈亏比、夏普比率，以及与对照组的决策一致率和显著性估计
+func (s *Server) handleGetExperimentReport(c *gin.Context) {
+	userID := c.GetString("user_id")
+	exp, err := s.database.GetExperiment(userID, c.Param("id"))
+	if err != nil {
+		c.JSON(experimentErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.traderManager.LoadTraderByID(s.database, userID, exp.ControlTraderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("对照组交易员不可用: %v", err)})
+		return
+	}
+
+	report, err := s.traderManager.GetExperimentReport(exp)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("生成实验报告失败: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"experiment": exp,
+		"report":     report,
+	})
+}
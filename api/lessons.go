@@ -0,0 +1,166 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"nofx/config"
+	"nofx/market"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxLessonLength 用户编辑复盘经验时的最大长度（字符）
+const maxLessonLength = 500
+
+// handleGetLessons 获取复盘经验列表（可按 trader_id、symbol 筛选，limit 默认100、最多500）
+func (s *Server) handleGetLessons(c *gin.Context) {
+	filter := config.TradeLessonFilter{
+		TraderID: c.Query("trader_id"),
+		Limit:    100,
+	}
+	if symbol := c.Query("symbol"); symbol != "" {
+		filter.Symbol = market.Normalize(symbol)
+	}
+	if limitStr := c.Query("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 || limit > 500 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit 必须在1-500之间"})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	lessons, err := s.database.GetTradeLessons(c.GetString("user_id"), filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取复盘经验失败: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"lessons": lessons})
+}
+
+// lessonIDParam 解析路径中的复盘经验ID
+func lessonIDParam(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的复盘经验ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// handleGetLesson 获取一条复盘经验
+func (s *Server) handleGetLesson(c *gin.Context) {
+	id, ok := lessonIDParam(c)
+	if !ok {
+		return
+	}
+	lesson, err := s.database.GetTradeLesson(c.GetString("user_id"), id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, lesson)
+}
+
+// updateLessonRequest 修改复盘经验请求（未传的字段保持原值）
+type updateLessonRequest struct {
+	Setup    *string   `json:"setup"`
+	Lesson   *string   `json:"lesson"`
+	Regime   *string   `json:"regime"`
+	Keywords *[]string `json:"keywords"`
+}
+
+// handleUpdateLesson 修改复盘经验（修正AI总结不准确的教训或关键词）
+func (s *Server) handleUpdateLesson(c *gin.Context) {
+	id, ok := lessonIDParam(c)
+	if !ok {
+		return
+	}
+	var req updateLessonRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	userID := c.GetString("user_id")
+	existing, err := s.database.GetTradeLesson(userID, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated := *existing
+	if req.Setup != nil {
+		updated.Setup = strings.TrimSpace(*req.Setup)
+	}
+	if req.Lesson != nil {
+		updated.Lesson = strings.TrimSpace(*req.Lesson)
+	}
+	if req.Regime != nil {
+		updated.Regime = strings.TrimSpace(*req.Regime)
+	}
+	if req.Keywords != nil {
+		updated.Keywords = *req.Keywords
+	}
+	if updated.Lesson == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "经验内容不能为空"})
+		return
+	}
+	if len([]rune(updated.Lesson)) > maxLessonLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("经验内容不能超过%d个字符", maxLessonLength)})
+		return
+	}
+
+	err = s.database.UpdateTradeLesson(&updated)
+	s.recordAudit(c, auditEntry{
+		Action:     "update_trade_lesson",
+		TargetType: "trade_lesson",
+		TargetID:   c.Param("id"),
+		TraderID:   existing.TraderID,
+		Before:     gin.H{"setup": existing.Setup, "lesson": existing.Lesson, "regime": existing.Regime, "keywords": existing.Keywords},
+		After:      gin.H{"setup": updated.Setup, "lesson": updated.Lesson, "regime": updated.Regime, "keywords": updated.Keywords},
+		Err:        err,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	lesson, err := s.database.GetTradeLesson(userID, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, lesson)
+}
+
+// handleDeleteLesson 删除复盘经验（之后不再写入提示词）
+func (s *Server) handleDeleteLesson(c *gin.Context) {
+	id, ok := lessonIDParam(c)
+	if !ok {
+		return
+	}
+	userID := c.GetString("user_id")
+	existing, err := s.database.GetTradeLesson(userID, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = s.database.DeleteTradeLesson(userID, id)
+	s.recordAudit(c, auditEntry{
+		Action:     "delete_trade_lesson",
+		TargetType: "trade_lesson",
+		TargetID:   c.Param("id"),
+		TraderID:   existing.TraderID,
+		Before:     gin.H{"symbol": existing.Symbol, "lesson": existing.Lesson},
+		Err:        err,
+	})
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "复盘经验已删除"})
+}
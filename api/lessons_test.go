@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"nofx/config"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestLessonAPI_ViewEditDelete 测试复盘经验的查看、修改、删除与用户隔离
+func TestLessonAPI_ViewEditDelete(t *testing.T) {
+	server, db, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	router := gin.New()
+	group := router.Group("/", func(c *gin.Context) {
+		c.Set("user_id", c.GetHeader("X-Test-User"))
+		c.Next()
+	})
+	group.GET("/lessons", server.handleGetLessons)
+	group.GET("/lessons/:id", server.handleGetLesson)
+	group.PUT("/lessons/:id", server.handleUpdateLesson)
+	group.DELETE("/lessons/:id", server.handleDeleteLesson)
+
+	lesson := &config.TradeLesson{
+		UserID: "user-a", TraderID: "trader-1", TradeKey: "BTCUSDT_long_1", Symbol: "BTCUSDT", Side: "long",
+		Regime: "bullish/high", Setup: "突破追高", Lesson: "等回踩确认", Keywords: []string{"突破"}, ClosedAt: time.Now(),
+	}
+	if err := db.CreateTradeLesson(lesson); err != nil {
+		t.Fatalf("保存复盘经验失败: %v", err)
+	}
+	path := fmt.Sprintf("/lessons/%d", lesson.ID)
+
+	w := doWebhookRequest(router, "GET", "/lessons?symbol=btc", "user-a", nil)
+	var list struct {
+		Lessons []config.TradeLesson `json:"lessons"`
+	}
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &list) != nil || len(list.Lessons) != 1 {
+		t.Fatalf("按币种查询复盘经验失败: %d %s", w.Code, w.Body.String())
+	}
+	if w := doWebhookRequest(router, "GET", "/lessons", "user-b", nil); w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &list) != nil || len(list.Lessons) != 0 {
+		t.Errorf("其他用户不应看到复盘经验: %s", w.Body.String())
+	}
+	if w := doWebhookRequest(router, "GET", "/lessons?limit=0", "user-a", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("无效 limit 应返回 400，实际 %d", w.Code)
+	}
+
+	if w := doWebhookRequest(router, "PUT", path, "user-b", gin.H{"lesson": "篡改"}); w.Code != http.StatusNotFound {
+		t.Errorf("其他用户修改应返回 404，实际 %d", w.Code)
+	}
+	if w := doWebhookRequest(router, "PUT", path, "user-a", gin.H{"lesson": "  "}); w.Code != http.StatusBadRequest {
+		t.Errorf("空经验应返回 400，实际 %d", w.Code)
+	}
+	w = doWebhookRequest(router, "PUT", path, "user-a", gin.H{"lesson": "高波动时等回踩确认再入场", "keywords": []string{"回踩", "高波动"}})
+	var updated config.TradeLesson
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &updated) != nil {
+		t.Fatalf("修改复盘经验失败: %d %s", w.Code, w.Body.String())
+	}
+	if updated.Lesson != "高波动时等回踩确认再入场" || len(updated.Keywords) != 2 || updated.Setup != "突破追高" {
+		t.Errorf("修改结果不正确（未传字段应保持原值）: %+v", updated)
+	}
+
+	if w := doWebhookRequest(router, "DELETE", path, "user-b", nil); w.Code != http.StatusNotFound {
+		t.Errorf("其他用户删除应返回 404，实际 %d", w.Code)
+	}
+	if w := doWebhookRequest(router, "DELETE", path, "user-a", nil); w.Code != http.StatusOK {
+		t.Fatalf("删除复盘经验失败: %d %s", w.Code, w.Body.String())
+	}
+	if w := doWebhookRequest(router, "GET", path, "user-a", nil); w.Code != http.StatusNotFound {
+		t.Errorf("删除后应返回 404，实际 %d", w.Code)
+	}
+}
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"nofx/logger"
+	"nofx/trader"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ManualCloseRequest 手动平仓请求（percentage 为空或 100 表示全部平仓）
+type ManualCloseRequest struct {
+	Symbol     string  `json:"symbol" binding:"required"`
+	Side       string  `json:"side"`
+	Percentage float64 `json:"percentage"`
+	Note       string  `json:"note"`
+}
+
+// ManualPriceRequest 手动调整止损/止盈请求
+type ManualPriceRequest struct {
+	Symbol string  `json:"symbol" binding:"required"`
+	Price  float64 `json:"price" binding:"required"`
+	Note   string  `json:"note"`
+}
+
+// ManualCancelOrdersRequest 手动撤单请求（type: all / stop_loss / take_profit / stop）
+type ManualCancelOrdersRequest struct {
+	Symbol string `json:"symbol" binding:"required"`
+	Type   string `json:"type"`
+	Note   string `json:"note"`
+}
+
+// ManualCloseAllRequest 一键平仓请求
+type ManualCloseAllRequest struct {
+	Note string `json:"note"`
+}
+
+// getOwnedAutoTrader 校验交易员归属并返回内存中的交易员实例
+func (s *Server) getOwnedAutoTrader(c *gin.Context) (*trader.AutoTrader, bool) {
+	userID := c.GetString("user_id")
+	traderID := c.Param("id")
+
+	if _, _, _, err := s.database.GetTraderConfig(userID, traderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+		return nil, false
+	}
+
+	// 确保用户的交易员已加载到内存中
+	if err := s.traderManager.LoadUserTraders(s.database, userID); err != nil {
+		log.Printf("⚠️ 加载用户 %s 的交易员失败: %v", userID, err)
+	}
+
+	at, err := s.traderManager.GetTrader(traderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在"})
+		return nil, false
+	}
+	return at, true
+}
+
+// handleManualClosePosition 手动平仓/部分平仓
+func (s *Server) handleManualClosePosition(c *gin.Context) {
+	traderID := c.Param("id")
+	var req ManualCloseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Percentage < 0 || req.Percentage > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "平仓百分比必须在 0-100 之间"})
+		return
+	}
+	side := strings.ToLower(req.Side)
+	if side != "" && side != "long" && side != "short" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "side 仅支持 long 或 short"})
+		return
+	}
+
+	at, ok := s.getOwnedAutoTrader(c)
+	if !ok {
+		return
+	}
+
+	action, err := at.ManualClosePosition(req.Symbol, side, req.Percentage, req.Note)
+	s.recordAudit(c, auditEntry{
+		Action:     "manual_close_position",
+		TargetType: "position",
+		TargetID:   req.Symbol,
+		TraderID:   traderID,
+		After:      req,
+		Err:        err,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("手动平仓失败: %v", err), "action": action})
+		return
+	}
+
+	log.Printf("✋ [%s] 手动平仓: %s %s", at.GetName(), action.Symbol, action.Action)
+	c.JSON(http.StatusOK, gin.H{"message": "平仓成功", "action": action})
+}
+
+// handleManualCloseAll 一键平掉所有持仓（交易员继续运行）
+func (s *Server) handleManualCloseAll(c *gin.Context) {
+	traderID := c.Param("id")
+	var req ManualCloseAllRequest
+	// 请求体可选
+	_ = c.ShouldBindJSON(&req)
+
+	at, ok := s.getOwnedAutoTrader(c)
+	if !ok {
+		return
+	}
+
+	actions, err := at.ManualCloseAllPositions(req.Note)
+	s.recordAudit(c, auditEntry{
+		Action:     "manual_close_all",
+		TargetType: "trader",
+		TargetID:   traderID,
+		TraderID:   traderID,
+		After:      gin.H{"closed": len(actions)},
+		Err:        err,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("一键平仓失败: %v", err), "actions": actions})
+		return
+	}
+
+	log.Printf("✋ [%s] 手动一键平仓: 共 %d 个持仓", at.GetName(), len(actions))
+	c.JSON(http.StatusOK, gin.H{"message": "已平掉所有持仓", "actions": actions})
+}
+
+// handleManualUpdateStopLoss 手动调整止损
+func (s *Server) handleManualUpdateStopLoss(c *gin.Context) {
+	s.handleManualUpdateProtection(c, "manual_update_stop_loss")
+}
+
+// handleManualUpdateTakeProfit 手动调整止盈
+func (s *Server) handleManualUpdateTakeProfit(c *gin.Context) {
+	s.handleManualUpdateProtection(c, "manual_update_take_profit")
+}
+
+// handleManualUpdateProtection 止损/止盈调整的通用处理
+func (s *Server) handleManualUpdateProtection(c *gin.Context, auditAction string) {
+	traderID := c.Param("id")
+	var req ManualPriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Price <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "价格必须大于0"})
+		return
+	}
+
+	at, ok := s.getOwnedAutoTrader(c)
+	if !ok {
+		return
+	}
+
+	var (
+		action *logger.DecisionAction
+		err    error
+	)
+	if auditAction == "manual_update_stop_loss" {
+		action, err = at.ManualUpdateStopLoss(req.Symbol, req.Price, req.Note)
+	} else {
+		action, err = at.ManualUpdateTakeProfit(req.Symbol, req.Price, req.Note)
+	}
+	s.recordAudit(c, auditEntry{
+		Action:     auditAction,
+		TargetType: "position",
+		TargetID:   req.Symbol,
+		TraderID:   traderID,
+		After:      req,
+		Err:        err,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("调整失败: %v", err), "action": action})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "调整成功", "action": action})
+}
+
+// handleManualCancelOrders 手动撤销挂单
+func (s *Server) handleManualCancelOrders(c *gin.Context) {
+	traderID := c.Param("id")
+	var req ManualCancelOrdersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Type == "" {
+		req.Type = trader.CancelOrderTypeAll
+	}
+	switch req.Type {
+	case trader.CancelOrderTypeAll, trader.CancelOrderTypeStopLoss, trader.CancelOrderTypeTakeProfit, trader.CancelOrderTypeStop:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type 仅支持 all / stop_loss / take_profit / stop"})
+		return
+	}
+
+	at, ok := s.getOwnedAutoTrader(c)
+	if !ok {
+		return
+	}
+
+	action, err := at.ManualCancelOrders(req.Symbol, req.Type, req.Note)
+	s.recordAudit(c, auditEntry{
+		Action:     "manual_cancel_orders",
+		TargetType: "order",
+		TargetID:   req.Symbol,
+		TraderID:   traderID,
+		After:      req,
+		Err:        err,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("撤单失败: %v", err), "action": action})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "撤单成功", "action": action})
+}
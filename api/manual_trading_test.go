@@ -0,0 +1,56 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestManualTrading_Validation 测试手动交易接口的参数校验与归属校验
+func TestManualTrading_Validation(t *testing.T) {
+	server, db, cleanup := setupTestServer(t)
+	defer cleanup()
+
+	userID, _, _ := setupTestEnv(t, db)
+
+	router := gin.New()
+	withUser := func(handler gin.HandlerFunc) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			c.Set("user_id", userID)
+			handler(c)
+		}
+	}
+	router.POST("/traders/:id/positions/close", withUser(server.handleManualClosePosition))
+	router.POST("/traders/:id/positions/stop-loss", withUser(server.handleManualUpdateStopLoss))
+	router.POST("/traders/:id/orders/cancel", withUser(server.handleManualCancelOrders))
+
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		wantCode int
+	}{
+		{"缺少symbol", "/traders/t1/positions/close", `{}`, http.StatusBadRequest},
+		{"百分比越界", "/traders/t1/positions/close", `{"symbol":"BTCUSDT","percentage":120}`, http.StatusBadRequest},
+		{"无效方向", "/traders/t1/positions/close", `{"symbol":"BTCUSDT","side":"up"}`, http.StatusBadRequest},
+		{"交易员不存在", "/traders/not-exist/positions/close", `{"symbol":"BTCUSDT"}`, http.StatusNotFound},
+		{"止损价格无效", "/traders/t1/positions/stop-loss", `{"symbol":"BTCUSDT","price":-1}`, http.StatusBadRequest},
+		{"无效撤单类型", "/traders/t1/orders/cancel", `{"symbol":"BTCUSDT","type":"market"}`, http.StatusBadRequest},
+		{"撤单_交易员不存在", "/traders/not-exist/orders/cancel", `{"symbol":"BTCUSDT"}`, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.path, bytes.NewBufferString(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.wantCode {
+				t.Errorf("期望状态码 %d，实际 %d: %s", tt.wantCode, w.Code, w.Body.String())
+			}
+		})
+	}
+}
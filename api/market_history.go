@@ -0,0 +1,111 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"nofx/market"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxHistoryRange 单次历史查询允许的最大时间跨度
+const maxHistoryRange = 90 * 24 * time.Hour
+
+// maxKlinesPerRange 按时间范围查询K线时单次允许的最大根数
+const maxKlinesPerRange = 5000
+
+// parseMillisRange 解析 start/end 查询参数（毫秒时间戳，end 缺省为当前时间）
+func parseMillisRange(c *gin.Context) (time.Time, time.Time, error) {
+	startStr := c.Query("start")
+	if startStr == "" {
+		return time.Time{}, time.Time{}, errors.New("start参数必填（毫秒时间戳）")
+	}
+	startMs, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || startMs <= 0 {
+		return time.Time{}, time.Time{}, errors.New("start参数无效")
+	}
+
+	end := time.Now()
+	if endStr := c.Query("end"); endStr != "" {
+		endMs, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || endMs <= 0 {
+			return time.Time{}, time.Time{}, errors.New("end参数无效")
+		}
+		end = time.UnixMilli(endMs)
+	}
+
+	start := time.UnixMilli(startMs)
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, errors.New("start必须早于end")
+	}
+	if end.Sub(start) > maxHistoryRange {
+		return time.Time{}, time.Time{}, fmt.Errorf("时间范围不能超过%d天", int(maxHistoryRange.Hours()/24))
+	}
+	return start, end, nil
+}
+
+// handleGetMarketHistory 查询本地存储的OI或资金费率历史（自动回填缺口）
+func (s *Server) handleGetMarketHistory(c *gin.Context) {
+	symbol := c.Query("symbol")
+	if symbol == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "symbol参数必填"})
+		return
+	}
+	start, end, err := parseMillisRange(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var data interface{}
+	dataType := c.DefaultQuery("type", "oi")
+	switch dataType {
+	case "oi":
+		data, err = market.GetOIHistoryRange(symbol, start, end)
+	case "funding":
+		data, err = market.GetFundingRateHistoryRange(symbol, start, end)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "type参数无效，可选 oi/funding"})
+		return
+	}
+	if errors.Is(err, market.ErrHistoryStoreDisabled) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("❌ 查询历史数据失败 %s %s: %v", symbol, dataType, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("查询历史数据失败: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"symbol": market.Normalize(symbol),
+		"type":   dataType,
+		"data":   data,
+	})
+}
+
+// handleGetMarketFunding 查询资金费率概况（历史结算、预测费率与结算倒计时、年化成本、期现基差）
+func (s *Server) handleGetMarketFunding(c *gin.Context) {
+	symbol := c.Query("symbol")
+	if symbol == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "symbol参数必填"})
+		return
+	}
+
+	info, err := market.GetFundingInfo(symbol)
+	if err != nil {
+		log.Printf("❌ 获取资金费率概况失败 %s: %v", symbol, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取资金费率概况失败: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"funding":                 info,
+		"seconds_to_next_funding": int64(info.TimeToFunding().Seconds()),
+	})
+}
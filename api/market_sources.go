@@ -0,0 +1,52 @@
+package api
+
+import (
+	"net/http"
+	"nofx/market"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// marketSourceStatusResponse 行情数据源状态
+type marketSourceStatusResponse struct {
+	Name          string    `json:"name"`
+	Healthy       bool      `json:"healthy"`
+	LatencyMs     int64     `json:"latency_ms"`
+	LastCheckTime time.Time `json:"last_check_time"`
+	FailureCount  int       `json:"failure_count"`
+	SuccessCount  int       `json:"success_count"`
+	TotalRequests int       `json:"total_requests"`
+	LastError     string    `json:"last_error,omitempty"`
+}
+
+// handleGetMarketSources 行情数据源健康状态
+func (s *Server) handleGetMarketSources(c *gin.Context) {
+	statuses := market.DefaultSourceManager().GetStatus()
+
+	sources := make([]marketSourceStatusResponse, 0, len(statuses))
+	healthy := 0
+	for _, status := range statuses {
+		if status.Healthy {
+			healthy++
+		}
+		sources = append(sources, marketSourceStatusResponse{
+			Name:          status.Name,
+			Healthy:       status.Healthy,
+			LatencyMs:     status.Latency.Milliseconds(),
+			LastCheckTime: status.LastCheckTime,
+			FailureCount:  status.FailureCount,
+			SuccessCount:  status.SuccessCount,
+			TotalRequests: status.TotalRequests,
+			LastError:     status.LastError,
+		})
+	}
+	sort.Slice(sources, func(i, j int) bool { return sources[i].Name < sources[j].Name })
+
+	c.JSON(http.StatusOK, gin.H{
+		"sources": sources,
+		"healthy": healthy,
+		"total":   len(sources),
+	})
+}
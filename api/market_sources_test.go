@@ -0,0 +1,38 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestHandleGetMarketSources(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	s := &Server{}
+	router := gin.New()
+	router.GET("/api/market/sources", s.handleGetMarketSources)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/market/sources", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("状态码错误: %d %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Sources []marketSourceStatusResponse `json:"sources"`
+		Total   int                          `json:"total"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("解析响应失败: %v", err)
+	}
+	names := map[string]bool{}
+	for _, source := range resp.Sources {
+		names[source.Name] = true
+	}
+	if resp.Total != len(resp.Sources) || !names["binance-ws"] || !names["binance-api"] || !names["hyperliquid"] {
+		t.Errorf("应返回已注册的数据源: %+v", resp)
+	}
+}
@@ -0,0 +1,225 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"nofx/config"
+	"nofx/decision"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// promptTemplateNamePattern 用户模板名称（交易员配置中按名称引用）
+var promptTemplateNamePattern = regexp.MustCompile(`^[\p{L}\p{N}_.-]{1,64}$`)
+
+// validatePromptTemplateName 校验用户模板名称
+func validatePromptTemplateName(name string) error {
+	if !promptTemplateNamePattern.MatchString(name) {
+		return fmt.Errorf("模板名称 %s 无效（仅支持文字、数字、下划线、点和短横线，最长64个字符）", name)
+	}
+	return nil
+}
+
+// promptTemplateErrorStatus 用户模板相关错误对应的HTTP状态码
+func promptTemplateErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "只读"):
+		return http.StatusForbidden
+	case strings.Contains(msg, "已存在"):
+		return http.StatusConflict
+	case strings.Contains(msg, "不存在"):
+		return http.StatusNotFound
+	case strings.Contains(msg, "无效"), strings.Contains(msg, "不唯一"), strings.Contains(msg, "不能为空"):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// ownedPromptTemplate 获取当前用户自己的模板（系统内置模板返回只读错误）
+func (s *Server) ownedPromptTemplate(userID, name string) (*config.PromptTemplateConfig, error) {
+	tmpl, err := s.database.GetPromptTemplate(userID, name)
+	if err != nil && decision.TemplateExists(name) {
+		return nil, fmt.Errorf("系统内置模板只读: %s，请先复制到自己的模板后修改", name)
+	}
+	return tmpl, err
+}
+
+// handleGetMyPromptTemplates 获取当前用户可用的系统提示词模板（自己的模板 + 系统内置模板）
+func (s *Server) handleGetMyPromptTemplates(c *gin.Context) {
+	templates, err := s.database.GetPromptTemplates(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取模板失败: %v", err)})
+		return
+	}
+
+	builtin := make([]gin.H, 0)
+	for _, tmpl := range decision.GetAllPromptTemplates() {
+		builtin = append(builtin, gin.H{
+			"name":         tmpl.Name,
+			"display_name": tmpl.DisplayName,
+			"description":  tmpl.Description,
+			"version":      tmpl.Version,
+			"read_only":    true,
+		})
+	}
+	sort.Slice(builtin, func(i, j int) bool { return builtin[i]["name"].(string) < builtin[j]["name"].(string) })
+
+	c.JSON(http.StatusOK, gin.H{
+		"templates": templates,
+		"builtin":   builtin,
+	})
+}
+
+// handleGetMyPromptTemplate 在当前用户的命名空间中获取模板内容（与交易员运行时的解析顺序一致）
+func (s *Server) handleGetMyPromptTemplate(c *gin.Context) {
+	tmpl, err := decision.ResolvePromptTemplate(c.GetString("user_id"), c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("模板不存在: %s", c.Param("name"))})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"name":      tmpl.Name,
+		"content":   tmpl.Content,
+		"version":   tmpl.Version,
+		"read_only": tmpl.Owner == "",
+	})
+}
+
+// handlePublishPromptTemplate 公开或取消公开自己的模板（其他用户可查看和复制，不能修改）
+func (s *Server) handlePublishPromptTemplate(c *gin.Context) {
+	userID := c.GetString("user_id")
+	templateName := c.Param("name")
+
+	var req struct {
+		Public bool `json:"public"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	existing, err := s.ownedPromptTemplate(userID, templateName)
+	if err != nil {
+		c.JSON(promptTemplateErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	err = s.database.SetPromptTemplatePublic(userID, templateName, req.Public)
+	s.recordAudit(c, auditEntry{
+		Action:     "publish_prompt_template",
+		TargetType: "prompt_template",
+		TargetID:   templateName,
+		Before:     gin.H{"is_public": existing.IsPublic},
+		After:      gin.H{"is_public": req.Public},
+		Err:        err,
+	})
+	if err != nil {
+		c.JSON(promptTemplateErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	message := "模板已公开"
+	if !req.Public {
+		message = "模板已取消公开"
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": message, "id": existing.ID})
+}
+
+// handleGetSharedPromptTemplates 获取其他用户公开共享的模板
+func (s *Server) handleGetSharedPromptTemplates(c *gin.Context) {
+	shared, err := s.database.GetSharedPromptTemplates()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取共享模板失败: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"templates": shared})
+}
+
+// forkRequest 复制模板请求（name 为空时沿用来源模板名称）
+type forkRequest struct {
+	Name string `json:"name"`
+	Note string `json:"note"`
+}
+
+// handleForkPromptTemplate 复制自己的模板或系统内置模板
+func (s *Server) handleForkPromptTemplate(c *gin.Context) {
+	source, err := decision.ResolvePromptTemplate(c.GetString("user_id"), c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("模板不存在: %s", c.Param("name"))})
+		return
+	}
+	description := ""
+	if source.Owner == "" {
+		description = source.Description["zh"]
+	}
+	s.forkPromptTemplate(c, source.Name, source.Content, description, source.Name)
+}
+
+// handleForkSharedPromptTemplate 复制其他用户共享的模板到自己的命名空间（复制后与来源相互独立）
+func (s *Server) handleForkSharedPromptTemplate(c *gin.Context) {
+	source, err := s.database.GetSharedPromptTemplate(c.Param("id"))
+	if err != nil {
+		c.JSON(promptTemplateErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	s.forkPromptTemplate(c, source.Name, source.Content, source.Description, source.ID)
+}
+
+func (s *Server) forkPromptTemplate(c *gin.Context, sourceName, content, description, forkedFrom string) {
+	userID := c.GetString("user_id")
+
+	var req forkRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+			return
+		}
+	}
+	if req.Name == "" {
+		req.Name = sourceName
+	}
+	if err := validatePromptTemplateName(req.Name); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if decision.TemplateExistsFor(userID, req.Name) {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("模板已存在: %s，请指定新的名称", req.Name)})
+		return
+	}
+	if req.Note == "" {
+		req.Note = fmt.Sprintf("复制自 %s", forkedFrom)
+	}
+
+	tmpl := &config.PromptTemplateConfig{
+		UserID:      userID,
+		Name:        req.Name,
+		Content:     content,
+		Description: description,
+		Version:     decision.PromptVersionHash(content),
+		ForkedFrom:  forkedFrom,
+	}
+	err := s.database.CreatePromptTemplate(tmpl, promptAuthor(c), req.Note)
+	s.recordAudit(c, auditEntry{
+		Action:     "fork_prompt_template",
+		TargetType: "prompt_template",
+		TargetID:   req.Name,
+		After:      gin.H{"name": req.Name, "forked_from": forkedFrom, "version": tmpl.Version},
+		Err:        err,
+	})
+	if err != nil {
+		c.JSON(promptTemplateErrorStatus(err), gin.H{"error": fmt.Sprintf("复制模板失败: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "模板复制成功",
+		"name":    tmpl.Name,
+		"version": tmpl.Version,
+	})
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"nofx/decision"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestPromptTemplateAPI_NamespaceShareAndFork 测试用户模板的隔离、共享、复制与回滚
+func TestPromptTemplateAPI_NamespaceShareAndFork(t *testing.T) {
+	server, db, cleanup := setupTestServer(t)
+	defer cleanup()
+	decision.SetPromptTemplateStore(db)
+	defer decision.SetPromptTemplateStore(nil)
+
+	router := gin.New()
+	group := router.Group("/", func(c *gin.Context) {
+		c.Set("user_id", c.GetHeader("X-Test-User"))
+		c.Next()
+	})
+	group.POST("/prompt-templates", server.handleCreatePromptTemplate)
+	group.PUT("/prompt-templates/:name", server.handleUpdatePromptTemplate)
+	group.DELETE("/prompt-templates/:name", server.handleDeletePromptTemplate)
+	group.PUT("/prompt-templates/:name/publish", server.handlePublishPromptTemplate)
+	group.POST("/prompt-templates/:name/rollback", server.handleRollbackPromptTemplate)
+	group.GET("/prompt-templates/:name/versions", server.handleListPromptTemplateVersions)
+	group.GET("/user/prompt-templates/:name", server.handleGetMyPromptTemplate)
+	group.GET("/shared-prompt-templates", server.handleGetSharedPromptTemplates)
+	group.POST("/shared-prompt-templates/:id/fork", server.handleForkSharedPromptTemplate)
+
+	if w := doWebhookRequest(router, "POST", "/prompt-templates", "user-a", gin.H{"name": "../etc", "content": "x"}); w.Code != http.StatusBadRequest {
+		t.Errorf("无效名称应返回 400，实际 %d", w.Code)
+	}
+	if w := doWebhookRequest(router, "POST", "/prompt-templates", "user-a", gin.H{"name": "scalp", "content": "v1"}); w.Code != http.StatusOK {
+		t.Fatalf("创建模板失败: %d %s", w.Code, w.Body.String())
+	}
+	if w := doWebhookRequest(router, "POST", "/prompt-templates", "user-a", gin.H{"name": "scalp", "content": "v1"}); w.Code != http.StatusConflict {
+		t.Errorf("重名应返回 409，实际 %d", w.Code)
+	}
+
+	// 其他用户看不到、也不能修改或删除
+	if w := doWebhookRequest(router, "GET", "/user/prompt-templates/scalp", "user-b", nil); w.Code != http.StatusNotFound {
+		t.Errorf("其他用户读取应返回 404，实际 %d", w.Code)
+	}
+	if w := doWebhookRequest(router, "PUT", "/prompt-templates/scalp", "user-b", gin.H{"content": "hack"}); w.Code != http.StatusNotFound {
+		t.Errorf("其他用户修改应返回 404，实际 %d", w.Code)
+	}
+	if w := doWebhookRequest(router, "DELETE", "/prompt-templates/scalp", "user-b", nil); w.Code != http.StatusNotFound {
+		t.Errorf("其他用户删除应返回 404，实际 %d", w.Code)
+	}
+
+	// 更新后回滚
+	if w := doWebhookRequest(router, "PUT", "/prompt-templates/scalp", "user-a", gin.H{"content": "v2"}); w.Code != http.StatusOK {
+		t.Fatalf("更新模板失败: %d %s", w.Code, w.Body.String())
+	}
+	v1 := decision.PromptVersionHash("v1")
+	if w := doWebhookRequest(router, "POST", "/prompt-templates/scalp/rollback", "user-a", gin.H{"version": v1[:8]}); w.Code != http.StatusOK {
+		t.Fatalf("回滚失败: %d %s", w.Code, w.Body.String())
+	}
+	w := doWebhookRequest(router, "GET", "/prompt-templates/scalp/versions", "user-a", nil)
+	var history struct {
+		Current  string `json:"current_version"`
+		Versions []struct {
+			Hash string `json:"hash"`
+		} `json:"versions"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &history)
+	if history.Current != v1 || len(history.Versions) != 3 {
+		t.Errorf("回滚应记录为新版本: %s", w.Body.String())
+	}
+
+	// 共享后其他用户可复制，复制后相互独立
+	w = doWebhookRequest(router, "PUT", "/prompt-templates/scalp/publish", "user-a", gin.H{"public": true})
+	var published struct {
+		ID string `json:"id"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &published)
+	if w.Code != http.StatusOK || published.ID == "" {
+		t.Fatalf("共享失败: %d %s", w.Code, w.Body.String())
+	}
+	if w := doWebhookRequest(router, "GET", "/shared-prompt-templates", "user-b", nil); !strings.Contains(w.Body.String(), published.ID) {
+		t.Errorf("共享列表应包含该模板: %s", w.Body.String())
+	}
+	if w := doWebhookRequest(router, "POST", "/shared-prompt-templates/"+published.ID+"/fork", "user-b", gin.H{"name": "my-scalp"}); w.Code != http.StatusOK {
+		t.Fatalf("复制共享模板失败: %d %s", w.Code, w.Body.String())
+	}
+	doWebhookRequest(router, "PUT", "/prompt-templates/scalp", "user-a", gin.H{"content": "v3"})
+	if content, _ := db.GetPromptTemplateContent("user-b", "my-scalp"); content != "v1" {
+		t.Errorf("复制后的模板不应随来源变化: %q", content)
+	}
+
+	doWebhookRequest(router, "PUT", "/prompt-templates/scalp/publish", "user-a", gin.H{"public": false})
+	if w := doWebhookRequest(router, "POST", "/shared-prompt-templates/"+published.ID+"/fork", "user-b", gin.H{"name": "again"}); w.Code != http.StatusNotFound {
+		t.Errorf("取消共享后不能再复制，实际 %d", w.Code)
+	}
+}
@@ -0,0 +1,226 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"nofx/config"
+	"nofx/decision"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// promptAuthor 模板修改人（优先使用邮箱）
+func promptAuthor(c *gin.Context) string {
+	if email := c.GetString("email"); email != "" {
+		return email
+	}
+	return c.GetString("user_id")
+}
+
+// promptVersionErrorStatus 版本相关错误对应的HTTP状态码
+func promptVersionErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "不存在"):
+		return http.StatusNotFound
+	case strings.Contains(msg, "不唯一"), strings.Contains(msg, "不能为空"):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// handleListPromptTemplateVersions 获取模板的版本历史
+func (s *Server) handleListPromptTemplateVersions(c *gin.Context) {
+	templateName := c.Param("name")
+
+	// 用户自己的模板版本记录在数据库中
+	if owned, err := s.database.GetPromptTemplate(c.GetString("user_id"), templateName); err == nil {
+		versions, err := s.database.GetPromptTemplateVersions(owned.UserID, templateName)
+		if err != nil {
+			c.JSON(promptVersionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"name":            templateName,
+			"current_version": owned.Version,
+			"versions":        versions,
+		})
+		return
+	}
+
+	// 系统内置模板（prompts/ 目录下的文件）
+	versions, err := decision.ListPromptTemplateVersions(templateName)
+	if err != nil {
+		c.JSON(promptVersionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	current := ""
+	if tmpl, getErr := decision.GetPromptTemplate(templateName); getErr == nil {
+		current = tmpl.Version
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"name":            templateName,
+		"current_version": current,
+		"versions":        versions,
+		"read_only":       true,
+	})
+}
+
+// handleGetPromptTemplateVersion 获取模板指定版本的内容
+func (s *Server) handleGetPromptTemplateVersion(c *gin.Context) {
+	userID, templateName := c.GetString("user_id"), c.Param("name")
+	if _, err := s.database.GetPromptTemplate(userID, templateName); err == nil {
+		version, err := s.database.GetPromptTemplateVersion(userID, templateName, c.Param("version"))
+		if err != nil {
+			c.JSON(promptVersionErrorStatus(err), gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, version)
+		return
+	}
+
+	version, err := decision.GetPromptTemplateVersion(templateName, c.Param("version"))
+	if err != nil {
+		c.JSON(promptVersionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, version)
+}
+
+// handleDiffPromptTemplateVersions 对比模板的两个版本（to 为空时与当前内容对比）
+func (s *Server) handleDiffPromptTemplateVersions(c *gin.Context) {
+	templateName := c.Param("name")
+	from, to := c.Query("from"), c.Query("to")
+	if from == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from参数必填（版本号）"})
+		return
+	}
+
+	lines, err := s.diffPromptTemplateVersions(c.GetString("user_id"), templateName, from, to)
+	if err != nil {
+		c.JSON(promptVersionErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	added, removed := 0, 0
+	for _, line := range lines {
+		switch line.Op {
+		case "+":
+			added++
+		case "-":
+			removed++
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"name":    templateName,
+		"from":    from,
+		"to":      to,
+		"added":   added,
+		"removed": removed,
+		"lines":   lines,
+	})
+}
+
+// handleRollbackPromptTemplate 回滚模板到指定版本（记录为新版本）
+func (s *Server) handleRollbackPromptTemplate(c *gin.Context) {
+	templateName := c.Param("name")
+
+	var req struct {
+		Version string `json:"version" binding:"required"`
+		Note    string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
+		return
+	}
+
+	// 系统内置模板只读，只能回滚自己的模板
+	userID := c.GetString("user_id")
+	existing, err := s.ownedPromptTemplate(userID, templateName)
+	if err != nil {
+		c.JSON(promptTemplateErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	beforeContent := gin.H{"name": existing.Name, "version": existing.Version}
+
+	version, err := s.rollbackPromptTemplate(c, existing, req.Version, req.Note)
+	var after interface{}
+	if version != nil {
+		after = gin.H{"name": templateName, "version": version.Hash, "rollback_to": req.Version}
+	}
+	s.recordAudit(c, auditEntry{
+		Action:     "rollback_prompt_template",
+		TargetType: "prompt_template",
+		TargetID:   templateName,
+		Before:     beforeContent,
+		After:      after,
+		Err:        err,
+	})
+	if err != nil {
+		c.JSON(promptVersionErrorStatus(err), gin.H{"error": fmt.Sprintf("回滚模板失败: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "模板已回滚",
+		"name":    templateName,
+		"version": version.Hash,
+	})
+}
+
+// diffPromptTemplateVersions 对比模板的两个版本（用户模板从数据库读取，系统内置模板从版本文件读取）
+func (s *Server) diffPromptTemplateVersions(userID, templateName, from, to string) ([]decision.PromptDiffLine, error) {
+	owned, err := s.database.GetPromptTemplate(userID, templateName)
+	if err != nil {
+		return decision.DiffPromptTemplateVersions(templateName, from, to)
+	}
+
+	fromVersion, err := s.database.GetPromptTemplateVersion(userID, templateName, from)
+	if err != nil {
+		return nil, err
+	}
+	toContent := owned.Content
+	if to != "" {
+		toVersion, err := s.database.GetPromptTemplateVersion(userID, templateName, to)
+		if err != nil {
+			return nil, err
+		}
+		toContent = toVersion.Content
+	}
+	return decision.DiffPromptContents(fromVersion.Content, toContent), nil
+}
+
+// rollbackPromptTemplate 回滚用户模板到指定版本（作为新版本记录，历史不会被改写）
+func (s *Server) rollbackPromptTemplate(c *gin.Context, existing *config.PromptTemplateConfig, hash, note string) (*config.PromptTemplateVersionRecord, error) {
+	target, err := s.database.GetPromptTemplateVersion(existing.UserID, existing.Name, hash)
+	if err != nil {
+		return nil, err
+	}
+	if note == "" {
+		note = fmt.Sprintf("回滚到版本 %s", target.Hash)
+	}
+
+	tmpl := &config.PromptTemplateConfig{
+		UserID:      existing.UserID,
+		Name:        existing.Name,
+		Content:     target.Content,
+		Description: existing.Description,
+		Version:     target.Hash,
+	}
+	if err := s.database.UpdatePromptTemplate(tmpl, promptAuthor(c), note); err != nil {
+		return nil, err
+	}
+	return &config.PromptTemplateVersionRecord{
+		Template:  existing.Name,
+		Hash:      target.Hash,
+		Author:    promptAuthor(c),
+		Note:      note,
+		CreatedAt: tmpl.UpdatedAt,
+	}, nil
+}
@@ -0,0 +1,58 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"nofx/decision"
+
+	"github.com/gin-gonic/gin"
+)
+
+// encodeRegimePolicies 校验市场状态策略并序列化为数据库存储格式（空配置返回空字符串）
+func encodeRegimePolicies(userID string, policies map[string]decision.RegimePolicy) (string, error) {
+	if len(policies) == 0 {
+		return "", nil
+	}
+	raw, err := json.Marshal(policies)
+	if err != nil {
+		return "", fmt.Errorf("市场状态策略格式错误: %w", err)
+	}
+	if _, err := decision.ParseRegimePolicies(string(raw)); err != nil {
+		return "", err
+	}
+	for regime, policy := range policies {
+		if policy.SystemPromptTemplate != "" && !decision.TemplateExistsFor(userID, policy.SystemPromptTemplate) {
+			return "", fmt.Errorf("市场状态 %s 的系统提示词模板 %s 不存在", regime, policy.SystemPromptTemplate)
+		}
+	}
+	return string(raw), nil
+}
+
+// regimePoliciesForResponse 解析数据库中的市场状态策略用于接口返回（格式错误时返回空对象）
+func regimePoliciesForResponse(raw string) map[string]decision.RegimePolicy {
+	policies, err := decision.ParseRegimePolicies(raw)
+	if err != nil || policies == nil {
+		return map[string]decision.RegimePolicy{}
+	}
+	return policies
+}
+
+// handleGetRegime 当前市场状态、最近的状态切换记录及交易员配置的状态策略
+func (s *Server) handleGetRegime(c *gin.Context) {
+	at, ok := s.getOwnedAutoTrader(c)
+	if !ok {
+		return
+	}
+
+	policies := at.GetRegimePolicies()
+	if policies == nil {
+		policies = map[string]decision.RegimePolicy{}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"trader_id":   at.GetID(),
+		"state":       at.GetRegimeState(),
+		"transitions": at.GetRegimeTransitions(),
+		"policies":    policies,
+	})
+}
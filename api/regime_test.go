@@ -0,0 +1,34 @@
+package api
+
+import (
+	"nofx/decision"
+	"testing"
+)
+
+// TestEncodeRegimePolicies 测试市场状态策略的校验与序列化
+func TestEncodeRegimePolicies(t *testing.T) {
+	raw, err := encodeRegimePolicies("u1", map[string]decision.RegimePolicy{
+		decision.RegimeRiskOff: {NoNewEntries: true},
+	})
+	if err != nil || raw != `{"risk_off":{"no_new_entries":true}}` {
+		t.Errorf("序列化结果不正确: %s, %v", raw, err)
+	}
+	if raw, err := encodeRegimePolicies("u1", nil); err != nil || raw != "" {
+		t.Errorf("空配置应保存为空字符串: %q, %v", raw, err)
+	}
+
+	invalid := []map[string]decision.RegimePolicy{
+		{"bull_market": {MaxLeverage: 3}},
+		{decision.RegimeTrending: {MaxLeverage: -1}},
+		{decision.RegimeRanging: {SystemPromptTemplate: "not-exist-template"}},
+	}
+	for _, policies := range invalid {
+		if _, err := encodeRegimePolicies("u1", policies); err == nil {
+			t.Errorf("%+v 应校验失败", policies)
+		}
+	}
+
+	if policies := regimePoliciesForResponse("{bad"); policies == nil || len(policies) != 0 {
+		t.Errorf("格式错误的配置应返回空对象: %+v", policies)
+	}
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math"
@@ -13,10 +14,13 @@ import (
 	"nofx/config"
 	"nofx/crypto"
 	"nofx/decision"
+	"nofx/events"
 	"nofx/hook"
 	"nofx/manager"
+	"nofx/market"
 	"nofx/middleware"
 	"nofx/trader"
+	"nofx/webhook"
 	"os"
 	"strconv"
 	"strings"
@@ -29,12 +33,14 @@ import (
 
 // Server HTTP API服务器
 type Server struct {
-	router        *gin.Engine
-	httpServer    *http.Server
-	traderManager *manager.TraderManager
-	database      *config.Database
-	cryptoHandler *CryptoHandler
-	port          int
+	router            *gin.Engine
+	httpServer        *http.Server
+	traderManager     *manager.TraderManager
+	database          *config.Database
+	cryptoHandler     *CryptoHandler
+	eventBus          *events.Bus
+	webhookDispatcher *webhook.Dispatcher
+	port              int
 }
 
 // NewServer 创建API服务器
@@ -42,7 +48,9 @@ func NewServer(traderManager *manager.TraderManager, database *config.Database,
 	// 设置为Release模式（减少日志输出）
 	gin.SetMode(gin.ReleaseMode)
 
-	router := gin.Default()
+	// 访问日志隐去 ?token= 中的JWT（实时推送接口允许通过查询参数认证）
+	router := gin.New()
+	router.Use(gin.LoggerWithFormatter(redactedLogFormatter), gin.Recovery())
 
 	// 配置允许的 CORS 来源
 	allowedOrigins := []string{
@@ -221,6 +229,13 @@ func (s *Server) setupRoutes() {
 		api.GET("/prompt-templates", s.handleGetPromptTemplates)
 		api.GET("/prompt-templates/:name", s.handleGetPromptTemplate)
 
+		// 可选技术指标列表（无需认证）
+		api.GET("/indicators", s.handleGetIndicators)
+
+		// 用户提示词模板（市场/账户上下文布局，无需认证）
+		api.GET("/user-prompt-templates", s.handleGetUserPromptTemplates)
+		api.GET("/user-prompt-templates/:name", s.handleGetUserPromptTemplate)
+
 		// 公开的竞赛数据（无需认证）
 		api.GET("/traders", s.handlePublicTraderList)
 		api.GET("/competition", s.handlePublicCompetition)
@@ -239,6 +254,13 @@ func (s *Server) setupRoutes() {
 			authGroup.POST("/refresh-token", s.handleRefreshToken)
 		}
 
+		// 实时事件推送（SSE / WebSocket），按用户投递
+		stream := api.Group("/events", allowQueryToken(), s.authMiddleware())
+		{
+			stream.GET("/stream", s.handleEventStream)
+			stream.GET("/ws", s.handleEventWebSocket)
+		}
+
 		// 需要认证的路由
 		protected := api.Group("/", s.authMiddleware())
 		{
@@ -252,6 +274,11 @@ func (s *Server) setupRoutes() {
 			protected.GET("/klines", s.handleGetKlines)
 			protected.GET("/klines/pattern-analysis", s.handleGetPatternAnalysis)
 
+			// 行情数据源健康状态
+			protected.GET("/market/sources", s.handleGetMarketSources)
+			protected.GET("/market/history", s.handleGetMarketHistory)
+			protected.GET("/market/funding", s.handleGetMarketFunding)
+
 			// AI交易员管理
 			protected.GET("/my-traders", s.handleTraderList)
 			protected.GET("/traders/:id/config", s.handleGetTraderConfig)
@@ -261,6 +288,29 @@ func (s *Server) setupRoutes() {
 			protected.POST("/traders/:id/start", s.handleStartTrader)
 			protected.POST("/traders/:id/stop", s.handleStopTrader)
 			protected.PUT("/traders/:id/prompt", s.handleUpdateTraderPrompt)
+			protected.POST("/traders/:id/prompt-preview", s.handlePreviewTraderPrompt)
+			protected.PUT("/traders/:id/mode", s.handleUpdateTradingMode)
+			protected.PUT("/traders/:id/approval", s.handleUpdateApprovalSettings)
+
+			// 开仓审批提案
+			protected.GET("/traders/:id/proposals", s.handleListProposals)
+			protected.POST("/traders/:id/proposals/:proposal_id/approve", s.handleApproveProposal)
+			protected.POST("/traders/:id/proposals/:proposal_id/reject", s.handleRejectProposal)
+
+			// 手动交易（交易员保持运行）
+			protected.POST("/traders/:id/positions/close", s.handleManualClosePosition)
+			protected.POST("/traders/:id/positions/close-all", s.handleManualCloseAll)
+			protected.POST("/traders/:id/positions/stop-loss", s.handleManualUpdateStopLoss)
+			protected.POST("/traders/:id/positions/take-profit", s.handleManualUpdateTakeProfit)
+			protected.POST("/traders/:id/orders/cancel", s.handleManualCancelOrders)
+
+			// 交易归因与信心度校准报告
+			protected.GET("/traders/:id/attribution", s.handleGetAttribution)
+			protected.GET("/traders/:id/attribution/export", s.handleExportAttribution)
+			protected.GET("/traders/:id/calibration", s.handleGetCalibration)
+
+			// 市场状态识别
+			protected.GET("/traders/:id/regime", s.handleGetRegime)
 
 			// AI模型配置
 			protected.GET("/models", s.handleGetModelConfigs)
@@ -279,6 +329,50 @@ func (s *Server) setupRoutes() {
 			protected.PUT("/prompt-templates/:name", s.handleUpdatePromptTemplate)
 			protected.DELETE("/prompt-templates/:name", s.handleDeletePromptTemplate)
 			protected.POST("/prompt-templates/reload", s.handleReloadPromptTemplates)
+			protected.GET("/prompt-templates/:name/versions", s.handleListPromptTemplateVersions)
+			protected.GET("/prompt-templates/:name/versions/:version", s.handleGetPromptTemplateVersion)
+			protected.GET("/prompt-templates/:name/diff", s.handleDiffPromptTemplateVersions)
+			protected.POST("/prompt-templates/:name/rollback", s.handleRollbackPromptTemplate)
+			protected.PUT("/prompt-templates/:name/publish", s.handlePublishPromptTemplate)
+			protected.POST("/prompt-templates/:name/fork", s.handleForkPromptTemplate)
+			protected.GET("/user/prompt-templates", s.handleGetMyPromptTemplates)
+			protected.GET("/user/prompt-templates/:name", s.handleGetMyPromptTemplate)
+			protected.GET("/shared-prompt-templates", s.handleGetSharedPromptTemplates)
+			protected.POST("/shared-prompt-templates/:id/fork", s.handleForkSharedPromptTemplate)
+
+			// 操作审计日志（普通用户仅能查看自己的记录）
+			protected.GET("/audit-logs", s.handleGetAuditLogs)
+			protected.GET("/audit-logs/export", s.handleExportAuditLogs)
+
+			// Telegram 聊天绑定
+			protected.POST("/telegram/link-code", s.handleCreateTelegramLinkCode)
+			protected.GET("/telegram/links", s.handleGetTelegramLinks)
+			protected.DELETE("/telegram/links/:chat_id", s.handleDeleteTelegramLink)
+
+			// Webhook 订阅（HMAC 签名推送交易事件）
+			protected.GET("/webhooks", s.handleGetWebhooks)
+			protected.POST("/webhooks", s.handleCreateWebhook)
+			protected.PUT("/webhooks/:id", s.handleUpdateWebhook)
+			protected.DELETE("/webhooks/:id", s.handleDeleteWebhook)
+			protected.POST("/webhooks/:id/test", s.handleTestWebhook)
+			protected.GET("/webhook-dead-letters", s.handleGetWebhookDeadLetters)
+			protected.POST("/webhook-dead-letters/:id/retry", s.handleRetryWebhookDeadLetter)
+			protected.DELETE("/webhook-dead-letters/:id", s.handleDeleteWebhookDeadLetter)
+
+			// A/B 实验（对照组真实下单，实验组影子交易员模拟下单）
+			protected.GET("/experiments", s.handleGetExperiments)
+			protected.POST("/experiments", s.handleCreateExperiment)
+			protected.GET("/experiments/:id", s.handleGetExperiment)
+			protected.POST("/experiments/:id/stop", s.handleStopExperiment)
+			protected.DELETE("/experiments/:id", s.handleDeleteExperiment)
+			protected.GET("/experiments/:id/report", s.handleGetExperimentReport)
+
+			// 交易复盘经验
+			protected.GET("/lessons", s.handleGetLessons)
+			protected.GET("/lessons/:id", s.handleGetLesson)
+			protected.PUT("/lessons/:id", s.handleUpdateLesson)
+			protected.DELETE("/lessons/:id", s.handleDeleteLesson)
+
 			// 指定trader的数据（使用query参数 ?trader_id=xxx）
 			protected.GET("/status", s.handleStatus)
 			protected.GET("/account", s.handleAccount)
@@ -548,6 +642,14 @@ type CreateTraderRequest struct {
 	LimitPriceOffset     float64 `json:"limit_price_offset"`    // Limit price offset percentage, default -0.03 (-0.03%)
 	LimitTimeoutSeconds  int     `json:"limit_timeout_seconds"` // Limit order timeout in seconds, default 60
 	Timeframes           string  `json:"timeframes"`            // 时间线选择 (逗号分隔，例如: "1m,4h,1d")
+	Indicators           []market.IndicatorSpec `json:"indicators"` // 指标配置（按时间周期选择指标与参数）
+	UserPromptTemplate   string                 `json:"user_prompt_template"` // 用户提示词模板名称（空=default）
+	PromptTokenBudget    int                    `json:"prompt_token_budget"`  // 提示词 token 预算（0=按AI服务商默认）
+	AgentMode            bool                   `json:"agent_mode"`           // 智能体模式（AI 可调用工具按需获取数据）
+	AgentMaxSteps        int                    `json:"agent_max_steps"`      // 智能体模式最多工具调用轮数（0=默认）
+	PositionSizingMode   string                 `json:"position_sizing_mode"` // 仓位计算模式: ai（默认）, kelly
+	KellyFraction        float64                `json:"kelly_fraction"`       // 分数Kelly系数（0=默认）
+	RegimePolicies       map[string]decision.RegimePolicy `json:"regime_policies"` // 市场状态策略（状态 → 模板/杠杆上限/禁止开仓）
 }
 
 type ModelConfig struct {
@@ -829,11 +931,49 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
 		takerFeeRate, takerFeeRate*100, makerFeeRate, makerFeeRate*100)
 
 	// 设置时间线默认值
-	timeframes := req.Timeframes
+	timeframes, err := normalizeTimeframes(req.Timeframes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if timeframes == "" {
 		timeframes = "4h" // 默认只勾选4小时线
 	}
 
+	// 校验指标配置（为空时只使用内置的固定指标）
+	indicators, err := normalizeIndicators(req.Indicators)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验用户提示词模板（为空时使用 default）
+	if err := validateUserPromptTemplate(req.UserPromptTemplate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validatePromptTokenBudget(req.PromptTokenBudget); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateAgentMaxSteps(req.AgentMaxSteps); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validatePositionSizing(req.PositionSizingMode, req.KellyFraction); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	positionSizingMode := req.PositionSizingMode
+	if positionSizingMode == "" {
+		positionSizingMode = trader.PositionSizingAI
+	}
+	regimePolicies, err := encodeRegimePolicies(userID, req.RegimePolicies)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// 设置订单策略默认值
 	orderStrategy := req.OrderStrategy
 	if orderStrategy == "" {
@@ -936,6 +1076,14 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
 		LimitPriceOffset:     limitPriceOffset,    // 添加限价偏移
 		LimitTimeoutSeconds:  limitTimeoutSeconds, // 添加限价超时
 		Timeframes:           timeframes,          // 添加时间线选择
+		Indicators:           indicators,          // 添加指标配置
+		UserPromptTemplate:   req.UserPromptTemplate,
+		PromptTokenBudget:    req.PromptTokenBudget,
+		AgentMode:            req.AgentMode,
+		AgentMaxSteps:        req.AgentMaxSteps,
+		PositionSizingMode:   positionSizingMode,
+		KellyFraction:        req.KellyFraction,
+		RegimePolicies:       regimePolicies,
 		IsRunning:            false,
 	}
 	log.Printf("✅ [DEBUG] 交易员配置对象已构建: ID=%s, AIModelID=%d, ExchangeID=%d", traderID, aiModelIntID, exchangeIntID)
@@ -943,6 +1091,14 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
 	// 保存到数据库
 	log.Printf("🔍 [DEBUG] 步骤10: 保存交易员到数据库...")
 	err = s.database.CreateTrader(trader)
+	s.recordAudit(c, auditEntry{
+		Action:     "create_trader",
+		TargetType: "trader",
+		TargetID:   traderID,
+		TraderID:   traderID,
+		After:      trader,
+		Err:        err,
+	})
 	if err != nil {
 		log.Printf("❌ [DEBUG] 数据库 CreateTrader 失败: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("创建交易员失败: %v", err)})
@@ -987,6 +1143,14 @@ type UpdateTraderRequest struct {
 	LimitPriceOffset     float64 `json:"limit_price_offset"`    // Limit price offset
 	LimitTimeoutSeconds  int     `json:"limit_timeout_seconds"` // Limit timeout in seconds
 	Timeframes           string  `json:"timeframes"`            // Timeframes selection
+	Indicators           *[]market.IndicatorSpec `json:"indicators"` // Indicator selection (nil keeps existing, empty clears)
+	UserPromptTemplate   *string                 `json:"user_prompt_template"` // User prompt template (nil keeps existing, empty resets to default)
+	PromptTokenBudget    *int                    `json:"prompt_token_budget"`  // Prompt token budget (nil keeps existing, 0 uses provider default)
+	AgentMode            *bool                   `json:"agent_mode"`           // Agent mode with tool calls (nil keeps existing)
+	AgentMaxSteps        *int                    `json:"agent_max_steps"`      // Max tool-call steps (nil keeps existing, 0 uses default)
+	PositionSizingMode   *string                 `json:"position_sizing_mode"` // Position sizing mode: ai or kelly (nil keeps existing)
+	KellyFraction        *float64                `json:"kelly_fraction"`       // Fractional Kelly multiplier (nil keeps existing, 0 uses default)
+	RegimePolicies       *map[string]decision.RegimePolicy `json:"regime_policies"` // Per-regime policies (nil keeps existing, empty clears)
 }
 
 // handleUpdateTrader 更新交易员配置
@@ -1124,7 +1288,11 @@ func (s *Server) handleUpdateTrader(c *gin.Context) {
 	}
 
 	// 设置时间线选择，允许更新
-	timeframes := req.Timeframes
+	timeframes, err := normalizeTimeframes(req.Timeframes)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	if timeframes == "" {
 		if existingTrader.Timeframes != "" {
 			timeframes = existingTrader.Timeframes // 保持原值
@@ -1133,6 +1301,77 @@ func (s *Server) handleUpdateTrader(c *gin.Context) {
 		}
 	}
 
+	// 指标配置：未传时保持原值，传空数组表示清空
+	indicators := existingTrader.Indicators
+	if req.Indicators != nil {
+		indicators, err = normalizeIndicators(*req.Indicators)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// 用户提示词模板：未传时保持原值，传空字符串表示恢复 default
+	userPromptTemplate := existingTrader.UserPromptTemplate
+	if req.UserPromptTemplate != nil {
+		if err := validateUserPromptTemplate(*req.UserPromptTemplate); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		userPromptTemplate = *req.UserPromptTemplate
+	}
+
+	// 提示词 token 预算：未传时保持原值，传0表示按AI服务商默认
+	promptTokenBudget := existingTrader.PromptTokenBudget
+	if req.PromptTokenBudget != nil {
+		if err := validatePromptTokenBudget(*req.PromptTokenBudget); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		promptTokenBudget = *req.PromptTokenBudget
+	}
+
+	// 智能体模式：未传时保持原值
+	agentMode := existingTrader.AgentMode
+	if req.AgentMode != nil {
+		agentMode = *req.AgentMode
+	}
+	agentMaxSteps := existingTrader.AgentMaxSteps
+	if req.AgentMaxSteps != nil {
+		if err := validateAgentMaxSteps(*req.AgentMaxSteps); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		agentMaxSteps = *req.AgentMaxSteps
+	}
+
+	// 仓位计算模式：未传时保持原值
+	positionSizingMode := existingTrader.PositionSizingMode
+	if req.PositionSizingMode != nil {
+		positionSizingMode = *req.PositionSizingMode
+		if positionSizingMode == "" {
+			positionSizingMode = trader.PositionSizingAI
+		}
+	}
+	kellyFraction := existingTrader.KellyFraction
+	if req.KellyFraction != nil {
+		kellyFraction = *req.KellyFraction
+	}
+	if err := validatePositionSizing(positionSizingMode, kellyFraction); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 市场状态策略：未传时保持原值，传空对象清除
+	regimePolicies := existingTrader.RegimePolicies
+	if req.RegimePolicies != nil {
+		regimePolicies, err = encodeRegimePolicies(userID, *req.RegimePolicies)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	// 查询 AI Model 和 Exchange 的自增 ID
 	aiModels, err := s.database.GetAIModels(userID)
 	if err != nil {
@@ -1196,11 +1435,28 @@ func (s *Server) handleUpdateTrader(c *gin.Context) {
 		LimitPriceOffset:     limitPriceOffset,         // 添加限价偏移
 		LimitTimeoutSeconds:  limitTimeoutSeconds,      // 添加限价超时
 		Timeframes:           timeframes,               // 添加时间线选择
+		Indicators:           indicators,               // 添加指标配置
+		UserPromptTemplate:   userPromptTemplate,       // 用户提示词模板
+		PromptTokenBudget:    promptTokenBudget,        // 提示词 token 预算
+		AgentMode:            agentMode,                // 智能体模式
+		AgentMaxSteps:        agentMaxSteps,            // 智能体模式最多工具调用轮数
+		PositionSizingMode:   positionSizingMode,       // 仓位计算模式
+		KellyFraction:        kellyFraction,            // 分数Kelly系数
+		RegimePolicies:       regimePolicies,           // 市场状态策略
 		IsRunning:            existingTrader.IsRunning, // 保持原值
 	}
 
 	// 更新数据库
 	err = s.database.UpdateTrader(trader)
+	s.recordAudit(c, auditEntry{
+		Action:     "update_trader",
+		TargetType: "trader",
+		TargetID:   traderID,
+		TraderID:   traderID,
+		Before:     existingTrader,
+		After:      trader,
+		Err:        err,
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新交易员失败: %v", err)})
 		return
@@ -1248,6 +1504,9 @@ func (s *Server) handleDeleteTrader(c *gin.Context) {
 		log.Printf("⚠️ 加载用户 %s 的交易员失败: %v", userID, err)
 	}
 
+	// 记录删除前的配置快照（用于审计）
+	existingTrader, _, _, _ := s.database.GetTraderConfig(userID, traderID)
+
 	// ✅ 步骤1：先从内存中停止并移除交易员（RemoveTrader会处理停止逻辑和竞赛缓存清除）
 	if removeErr := s.traderManager.RemoveTrader(traderID); removeErr != nil {
 		// 交易员不在内存中也不是错误，可能已经被移除或从未加载
@@ -1256,6 +1515,14 @@ func (s *Server) handleDeleteTrader(c *gin.Context) {
 
 	// ✅ 步骤2：最后才从数据库删除
 	err = s.database.DeleteTrader(userID, traderID)
+	s.recordAudit(c, auditEntry{
+		Action:     "delete_trader",
+		TargetType: "trader",
+		TargetID:   traderID,
+		TraderID:   traderID,
+		Before:     existingTrader,
+		Err:        err,
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("删除交易员失败: %v", err)})
 		return
@@ -1315,6 +1582,14 @@ func (s *Server) handleStartTrader(c *gin.Context) {
 	if err != nil {
 		log.Printf("⚠️  更新交易员状态失败: %v", err)
 	}
+	s.recordAudit(c, auditEntry{
+		Action:     "start_trader",
+		TargetType: "trader",
+		TargetID:   traderID,
+		TraderID:   traderID,
+		Before:     gin.H{"is_running": false},
+		After:      gin.H{"is_running": true},
+	})
 
 	log.Printf("✓ 交易员 %s 已启动", trader.GetName())
 	c.JSON(http.StatusOK, gin.H{"message": "交易员已启动"})
@@ -1359,6 +1634,14 @@ func (s *Server) handleStopTrader(c *gin.Context) {
 	if err != nil {
 		log.Printf("⚠️  更新交易员状态失败: %v", err)
 	}
+	s.recordAudit(c, auditEntry{
+		Action:     "stop_trader",
+		TargetType: "trader",
+		TargetID:   traderID,
+		TraderID:   traderID,
+		Before:     gin.H{"is_running": true},
+		After:      gin.H{"is_running": false},
+	})
 
 	log.Printf("⏹  交易员 %s 已停止", trader.GetName())
 	c.JSON(http.StatusOK, gin.H{"message": "交易员已停止"})
@@ -1385,8 +1668,26 @@ func (s *Server) handleUpdateTraderPrompt(c *gin.Context) {
 		return
 	}
 
+	// 记录修改前的prompt（用于审计）
+	var beforePrompt interface{}
+	if existingTrader, _, _, getErr := s.database.GetTraderConfig(userID, traderID); getErr == nil {
+		beforePrompt = gin.H{
+			"custom_prompt":        existingTrader.CustomPrompt,
+			"override_base_prompt": existingTrader.OverrideBasePrompt,
+		}
+	}
+
 	// 更新数据库
 	err = s.database.UpdateTraderCustomPrompt(userID, traderID, req.CustomPrompt, req.OverrideBasePrompt)
+	s.recordAudit(c, auditEntry{
+		Action:     "update_trader_prompt",
+		TargetType: "trader",
+		TargetID:   traderID,
+		TraderID:   traderID,
+		Before:     beforePrompt,
+		After:      req,
+		Err:        err,
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新自定义prompt失败: %v", err)})
 		return
@@ -1506,6 +1807,15 @@ func (s *Server) handleSyncBalance(c *gin.Context) {
 
 	// 更新数据库中的 initial_balance
 	err = s.database.UpdateTraderInitialBalance(userID, traderID, actualBalance)
+	s.recordAudit(c, auditEntry{
+		Action:     "sync_balance",
+		TargetType: "trader",
+		TargetID:   traderID,
+		TraderID:   traderID,
+		Before:     gin.H{"initial_balance": oldBalance},
+		After:      gin.H{"initial_balance": actualBalance},
+		Err:        err,
+	})
 	if err != nil {
 		log.Printf("❌ 更新initial_balance失败: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新余额失败"})
@@ -1604,10 +1914,21 @@ func (s *Server) handleUpdateModelConfigs(c *gin.Context) {
 	}
 	log.Printf("🔓 已解密模型配置数据 (UserID: %s)", userID)
 
+	// 记录修改前的配置（用于审计，敏感字段会被脱敏）
+	beforeModels, _ := s.database.GetAIModels(userID)
+
 	// 更新每个模型的配置
 	for modelID, modelData := range req.Models {
 		err := s.database.UpdateAIModel(userID, modelID, modelData.Enabled, modelData.APIKey, modelData.CustomAPIURL, modelData.CustomModelName)
 		if err != nil {
+			s.recordAudit(c, auditEntry{
+				Action:     "update_model_configs",
+				TargetType: "ai_model",
+				TargetID:   modelID,
+				Before:     beforeModels,
+				After:      req.Models,
+				Err:        err,
+			})
 			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新模型 %s 失败: %v", modelID, err)})
 			return
 		}
@@ -1620,6 +1941,14 @@ func (s *Server) handleUpdateModelConfigs(c *gin.Context) {
 		// 这里不返回错误，因为模型配置已经成功更新到数据库
 	}
 
+	s.recordAudit(c, auditEntry{
+		Action:     "update_model_configs",
+		TargetType: "ai_model",
+		TargetID:   joinMapKeys(req.Models),
+		Before:     beforeModels,
+		After:      req.Models,
+	})
+
 	log.Printf("✓ AI模型配置已更新: %+v", SanitizeModelConfigForLog(req.Models))
 	c.JSON(http.StatusOK, gin.H{"message": "模型配置已更新"})
 }
@@ -1701,10 +2030,21 @@ func (s *Server) handleUpdateExchangeConfigs(c *gin.Context) {
 	}
 	log.Printf("🔓 已解密交易所配置数据 (UserID: %s)", userID)
 
+	// 记录修改前的配置（用于审计，敏感字段会被脱敏）
+	beforeExchanges, _ := s.database.GetExchanges(userID)
+
 	// 更新每个交易所的配置
 	for exchangeID, exchangeData := range req.Exchanges {
 		err := s.database.UpdateExchange(userID, exchangeID, exchangeData.Enabled, exchangeData.APIKey, exchangeData.SecretKey, exchangeData.Testnet, exchangeData.HyperliquidWalletAddr, exchangeData.AsterUser, exchangeData.AsterSigner, exchangeData.AsterPrivateKey)
 		if err != nil {
+			s.recordAudit(c, auditEntry{
+				Action:     "update_exchange_configs",
+				TargetType: "exchange",
+				TargetID:   exchangeID,
+				Before:     beforeExchanges,
+				After:      req.Exchanges,
+				Err:        err,
+			})
 			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新交易所 %s 失败: %v", exchangeID, err)})
 			return
 		}
@@ -1717,6 +2057,14 @@ func (s *Server) handleUpdateExchangeConfigs(c *gin.Context) {
 		// 这里不返回错误，因为交易所配置已经成功更新到数据库
 	}
 
+	s.recordAudit(c, auditEntry{
+		Action:     "update_exchange_configs",
+		TargetType: "exchange",
+		TargetID:   joinMapKeys(req.Exchanges),
+		Before:     beforeExchanges,
+		After:      req.Exchanges,
+	})
+
 	log.Printf("✓ 交易所配置已更新: %+v", SanitizeExchangeConfigForLog(req.Exchanges))
 	c.JSON(http.StatusOK, gin.H{"message": "交易所配置已更新"})
 }
@@ -1753,7 +2101,17 @@ func (s *Server) handleSaveUserSignalSource(c *gin.Context) {
 		return
 	}
 
+	beforeSource, _ := s.database.GetUserSignalSource(userID)
+
 	err := s.database.CreateUserSignalSource(userID, req.CoinPoolURL, req.OITopURL)
+	s.recordAudit(c, auditEntry{
+		Action:     "save_signal_source",
+		TargetType: "signal_source",
+		TargetID:   userID,
+		Before:     beforeSource,
+		After:      req,
+		Err:        err,
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("保存用户信号源配置失败: %v", err)})
 		return
@@ -1800,11 +2158,15 @@ func (s *Server) handleTraderList(c *gin.Context) {
 	for _, trader := range traders {
 		// 获取实时运行状态
 		isRunning := trader.IsRunning
+		tradingMode := trader.TradingMode
 		if at, err := s.traderManager.GetTrader(trader.ID); err == nil {
 			status := at.GetStatus()
 			if running, ok := status["is_running"].(bool); ok {
 				isRunning = running
 			}
+			if mode, ok := status["trading_mode"].(string); ok {
+				tradingMode = mode
+			}
 		}
 
 		// 返回 AI 模型的 ModelID（如 "deepseek", "qwen-chat"），而不是整数 ID
@@ -1826,6 +2188,7 @@ func (s *Server) handleTraderList(c *gin.Context) {
 			"ai_model":               aiModelID,
 			"exchange_id":            exchangeID,
 			"is_running":             isRunning,
+			"trading_mode":           tradingMode,
 			"initial_balance":        trader.InitialBalance,
 			"system_prompt_template": trader.SystemPromptTemplate,
 		})
@@ -1858,11 +2221,24 @@ func (s *Server) handleGetTraderConfig(c *gin.Context) {
 
 	// 获取实时运行状态
 	isRunning := traderConfig.IsRunning
+	tradingMode := traderConfig.TradingMode
 	if at, err := s.traderManager.GetTrader(traderID); err == nil {
 		status := at.GetStatus()
 		if running, ok := status["is_running"].(bool); ok {
 			isRunning = running
 		}
+		if mode, ok := status["trading_mode"].(string); ok {
+			tradingMode = mode
+		}
+	}
+
+	// 指标配置以数组形式返回（无效的历史配置视为未配置）
+	indicators, err := market.ParseIndicatorSet(traderConfig.Indicators)
+	if err != nil {
+		log.Printf("⚠️ 交易员 %s 的指标配置无效: %v", traderID, err)
+	}
+	if indicators == nil {
+		indicators = []market.IndicatorSpec{}
 	}
 
 	// 返回 AI 模型的 ModelID（如 "deepseek", "qwen-chat"），而不是整数 ID
@@ -1889,12 +2265,26 @@ func (s *Server) handleGetTraderConfig(c *gin.Context) {
 		"use_coin_pool":          traderConfig.UseCoinPool,
 		"use_oi_top":             traderConfig.UseOITop,
 		"timeframes":             traderConfig.Timeframes,  // 🔧 添加时间周期字段
+		"indicators":             indicators,
+		"user_prompt_template":   traderConfig.UserPromptTemplate,
+		"prompt_token_budget":    traderConfig.PromptTokenBudget,
+		"agent_mode":             traderConfig.AgentMode,
+		"agent_max_steps":        traderConfig.AgentMaxSteps,
+		"position_sizing_mode":   traderConfig.PositionSizingMode,
+		"kelly_fraction":         traderConfig.KellyFraction,
+		"regime_policies":        regimePoliciesForResponse(traderConfig.RegimePolicies),
 		"taker_fee_rate":         traderConfig.TakerFeeRate,
 		"maker_fee_rate":          traderConfig.MakerFeeRate,
 		"order_strategy":          traderConfig.OrderStrategy,
 		"limit_price_offset":      traderConfig.LimitPriceOffset,
 		"limit_timeout_seconds":   traderConfig.LimitTimeoutSeconds,
 		"is_running":             isRunning,
+		"trading_mode":           tradingMode,
+		"approval_required":      traderConfig.ApprovalRequired,
+		"approval_min_notional":  traderConfig.ApprovalMinNotional,
+		"approval_min_leverage":  traderConfig.ApprovalMinLeverage,
+		"approval_expiry_minutes": traderConfig.ApprovalExpiryMinutes,
+		"approval_price_tolerance_pct": traderConfig.ApprovalPriceTolerancePct,
 	}
 
 	c.JSON(http.StatusOK, result)
@@ -2271,6 +2661,12 @@ func (s *Server) handleLogout(c *gin.Context) {
 		exp = time.Now().Add(24 * time.Hour)
 	}
 	auth.BlacklistToken(tokenString, exp)
+	s.recordAudit(c, auditEntry{
+		UserID:     claims.UserID,
+		Action:     "logout",
+		TargetType: "user",
+		TargetID:   claims.UserID,
+	})
 	c.JSON(http.StatusOK, gin.H{"message": "已登出"})
 }
 
@@ -2417,6 +2813,15 @@ func (s *Server) handleCompleteRegistration(c *gin.Context) {
 
 	// 更新用户OTP验证状态
 	err = s.database.UpdateUserOTPVerified(req.UserID, true)
+	s.recordAudit(c, auditEntry{
+		UserID:     user.ID,
+		Action:     "complete_registration",
+		TargetType: "user",
+		TargetID:   user.ID,
+		Before:     gin.H{"otp_verified": user.OTPVerified},
+		After:      gin.H{"otp_verified": true},
+		Err:        err,
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新用户状态失败"})
 		return
@@ -2593,6 +2998,13 @@ func (s *Server) handleResetPassword(c *gin.Context) {
 
 	// 更新密码
 	err = s.database.UpdateUserPassword(user.ID, newPasswordHash)
+	s.recordAudit(c, auditEntry{
+		UserID:     user.ID,
+		Action:     "reset_password",
+		TargetType: "user",
+		TargetID:   user.ID,
+		Err:        err,
+	})
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "密码更新失败"})
 		return
@@ -2667,6 +3079,19 @@ func (s *Server) Start() error {
 	log.Printf("  • DELETE /api/traders/:id    - 删除AI交易员")
 	log.Printf("  • POST /api/traders/:id/start - 启动AI交易员")
 	log.Printf("  • POST /api/traders/:id/stop  - 停止AI交易员")
+	log.Printf("  • PUT  /api/traders/:id/mode  - 切换运行模式（normal/paused/reduce_only/close_out）")
+	log.Printf("  • PUT  /api/traders/:id/approval - 设置开仓人工审批")
+	log.Printf("  • GET  /api/traders/:id/proposals?status=pending - 开仓审批提案列表")
+	log.Printf("  • POST /api/traders/:id/proposals/:proposal_id/approve|reject - 批准/拒绝提案")
+	log.Printf("  • POST /api/traders/:id/positions/close - 手动平仓/部分平仓")
+	log.Printf("  • POST /api/traders/:id/positions/close-all - 一键平掉所有持仓")
+	log.Printf("  • POST /api/traders/:id/positions/stop-loss - 手动调整止损")
+	log.Printf("  • POST /api/traders/:id/positions/take-profit - 手动调整止盈")
+	log.Printf("  • POST /api/traders/:id/orders/cancel - 手动撤销挂单")
+	log.Printf("  • GET  /api/traders/:id/attribution - 交易归因报告（按信心度/形态/周期聚合）")
+	log.Printf("  • GET  /api/traders/:id/attribution/export?format=csv|json - 导出逐笔交易归因")
+	log.Printf("  • GET  /api/traders/:id/calibration - 信心度校准报告（各区间实际胜率/期望/Kelly）")
+	log.Printf("  • GET  /api/traders/:id/regime - 当前市场状态、状态切换记录与状态策略")
 	log.Printf("  • GET  /api/models           - 获取AI模型配置")
 	log.Printf("  • PUT  /api/models           - 更新AI模型配置")
 	log.Printf("  • GET  /api/exchanges        - 获取交易所配置")
@@ -2678,6 +3103,29 @@ func (s *Server) Start() error {
 	log.Printf("  • GET  /api/decisions/latest?trader_id=xxx - 指定trader的最新决策")
 	log.Printf("  • GET  /api/statistics?trader_id=xxx - 指定trader的统计信息")
 	log.Printf("  • GET  /api/performance?trader_id=xxx - 指定trader的AI学习表现分析")
+	log.Printf("  • GET  /api/audit-logs?trader_id=xxx&from=&to= - 操作审计日志")
+	log.Printf("  • GET  /api/audit-logs/export?format=csv|json - 导出操作审计日志")
+	log.Printf("  • POST /api/telegram/link-code - 生成Telegram绑定码")
+	log.Printf("  • GET  /api/telegram/links  - 已绑定的Telegram聊天")
+	log.Printf("  • GET  /api/events/stream?trader_id=&types= - 实时事件推送 (SSE)")
+	log.Printf("  • GET  /api/events/ws?trader_id=&types= - 实时事件推送 (WebSocket)")
+	log.Printf("  • GET  /api/webhooks            - Webhook订阅列表")
+	log.Printf("  • POST /api/webhooks/:id/test   - 发送Webhook测试事件")
+	log.Printf("  • GET  /api/webhook-dead-letters - Webhook投递失败记录")
+	log.Printf("  • GET  /api/market/sources      - 行情数据源健康状态")
+	log.Printf("  • GET  /api/market/history      - OI/资金费率历史（本地存储）")
+	log.Printf("  • GET  /api/market/funding      - 资金费率概况（预测费率/结算倒计时/基差）")
+	log.Printf("  • GET  /api/indicators          - 可选技术指标及参数")
+	log.Printf("  • GET  /api/user-prompt-templates - 用户提示词模板列表")
+	log.Printf("  • GET  /api/prompt-templates/:name/versions - 系统提示词模板版本历史")
+	log.Printf("  • POST /api/prompt-templates/:name/rollback - 回滚系统提示词模板")
+	log.Printf("  • GET  /api/user/prompt-templates - 我的系统提示词模板（含只读的内置模板）")
+	log.Printf("  • GET  /api/shared-prompt-templates - 其他用户共享的系统提示词模板")
+	log.Printf("  • POST /api/shared-prompt-templates/:id/fork - 复制共享模板到我的模板")
+	log.Printf("  • POST /api/traders/:id/prompt-preview - 预览当前上下文渲染的提示词")
+	log.Printf("  • POST /api/experiments          - 创建A/B实验（影子交易员模拟下单）")
+	log.Printf("  • GET  /api/experiments/:id/report - A/B实验对比报告")
+	log.Printf("  • GET  /api/lessons              - 交易复盘经验（可编辑、删除）")
 	log.Println()
 
 	// 创建 http.Server 以支持 graceful shutdown
@@ -2735,6 +3183,14 @@ func (s *Server) handleGetPromptTemplate(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"name":    template.Name,
 		"content": template.Content,
+		"version": template.Version,
+	})
+}
+
+// handleGetIndicators 获取可选技术指标及其参数定义（用于交易员指标配置）
+func (s *Server) handleGetIndicators(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"indicators": market.Indicators(),
 	})
 }
 
@@ -2949,14 +3405,23 @@ func (s *Server) handleGetPublicTraderConfig(c *gin.Context) {
 
 // handleReloadPromptTemplates 重新加载所有提示词模板（API 端点）
 func (s *Server) handleReloadPromptTemplates(c *gin.Context) {
-	if err := decision.ReloadPromptTemplates(); err != nil {
+	err := decision.ReloadPromptTemplates()
+	if err == nil {
+		err = decision.ReloadUserPromptTemplates()
+	}
+	s.recordAudit(c, auditEntry{
+		Action:     "reload_prompt_templates",
+		TargetType: "prompt_template",
+		Err:        err,
+	})
+	if err != nil {
 		log.Printf("⚠️  重新加载提示词模板失败: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("重新加载失败: %v", err)})
 		return
 	}
 
 	templates := decision.GetAllPromptTemplates()
-	log.Printf("✓ 已重新加载系统提示词模板，共 %d 个", len(templates))
+	log.Printf("✓ 已重新加载系统提示词模板，共 %d 个；用户提示词模板 %d 个", len(templates), len(decision.GetAllUserPromptTemplateNames()))
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "提示词模板已重新加载",
@@ -2978,27 +3443,50 @@ func (s *Server) reloadPromptTemplatesWithLog(templateName string) {
 	}
 }
 
-// handleCreatePromptTemplate 创建新的提示词模板
+// handleCreatePromptTemplate 在当前用户的命名空间中创建提示词模板
 func (s *Server) handleCreatePromptTemplate(c *gin.Context) {
+	userID := c.GetString("user_id")
+
 	var req struct {
-		Name    string `json:"name" binding:"required"`
-		Content string `json:"content" binding:"required"`
+		Name        string `json:"name" binding:"required"`
+		Content     string `json:"content" binding:"required"`
+		Description string `json:"description"`
+		Note        string `json:"note"` // 修改说明（记录到版本历史）
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "请求参数错误: " + err.Error()})
 		return
 	}
+	if err := validatePromptTemplateName(req.Name); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	// 检查模板是否已存在
-	if decision.TemplateExists(req.Name) {
+	// 检查模板是否已存在（不允许与系统内置模板重名，避免解析歧义）
+	if decision.TemplateExistsFor(userID, req.Name) {
 		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("模板已存在: %s", req.Name)})
 		return
 	}
 
-	// 保存模板
-	if err := decision.SavePromptTemplate(req.Name, req.Content); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("创建模板失败: %v", err)})
+	// 保存模板（记录为第一个版本）
+	tmpl := &config.PromptTemplateConfig{
+		UserID:      userID,
+		Name:        req.Name,
+		Content:     req.Content,
+		Description: req.Description,
+		Version:     decision.PromptVersionHash(req.Content),
+	}
+	err := s.database.CreatePromptTemplate(tmpl, promptAuthor(c), req.Note)
+	s.recordAudit(c, auditEntry{
+		Action:     "create_prompt_template",
+		TargetType: "prompt_template",
+		TargetID:   req.Name,
+		After:      req,
+		Err:        err,
+	})
+	if err != nil {
+		c.JSON(promptTemplateErrorStatus(err), gin.H{"error": fmt.Sprintf("创建模板失败: %v", err)})
 		return
 	}
 
@@ -3006,15 +3494,19 @@ func (s *Server) handleCreatePromptTemplate(c *gin.Context) {
 		"success": true,
 		"message": "模板创建成功",
 		"name":    req.Name,
+		"version": tmpl.Version,
 	})
 }
 
-// handleUpdatePromptTemplate 更新提示词模板
+// handleUpdatePromptTemplate 更新当前用户的提示词模板（系统内置模板只读）
 func (s *Server) handleUpdatePromptTemplate(c *gin.Context) {
+	userID := c.GetString("user_id")
 	templateName := c.Param("name")
 
 	var req struct {
-		Content string `json:"content" binding:"required"`
+		Content     string `json:"content" binding:"required"`
+		Description string `json:"description"`
+		Note        string `json:"note"` // 修改说明（记录到版本历史）
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -3022,15 +3514,32 @@ func (s *Server) handleUpdatePromptTemplate(c *gin.Context) {
 		return
 	}
 
-	// 检查模板是否存在
-	if !decision.TemplateExists(templateName) {
-		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("模板不存在: %s", templateName)})
+	// 检查模板是否存在且属于当前用户
+	existing, err := s.ownedPromptTemplate(userID, templateName)
+	if err != nil {
+		c.JSON(promptTemplateErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
-	// 更新模板
-	if err := decision.SavePromptTemplate(templateName, req.Content); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新模板失败: %v", err)})
+	// 更新模板（记录为新版本，旧版本可通过回滚恢复）
+	tmpl := &config.PromptTemplateConfig{
+		UserID:      userID,
+		Name:        templateName,
+		Content:     req.Content,
+		Description: req.Description,
+		Version:     decision.PromptVersionHash(req.Content),
+	}
+	err = s.database.UpdatePromptTemplate(tmpl, promptAuthor(c), req.Note)
+	s.recordAudit(c, auditEntry{
+		Action:     "update_prompt_template",
+		TargetType: "prompt_template",
+		TargetID:   templateName,
+		Before:     gin.H{"name": existing.Name, "content": existing.Content, "version": existing.Version},
+		After:      gin.H{"name": templateName, "content": req.Content, "version": tmpl.Version},
+		Err:        err,
+	})
+	if err != nil {
+		c.JSON(promptTemplateErrorStatus(err), gin.H{"error": fmt.Sprintf("更新模板失败: %v", err)})
 		return
 	}
 
@@ -3038,22 +3547,32 @@ func (s *Server) handleUpdatePromptTemplate(c *gin.Context) {
 		"success": true,
 		"message": "模板更新成功",
 		"name":    templateName,
+		"version": tmpl.Version,
 	})
 }
 
-// handleDeletePromptTemplate 删除提示词模板
+// handleDeletePromptTemplate 删除当前用户的提示词模板（系统内置模板只读）
 func (s *Server) handleDeletePromptTemplate(c *gin.Context) {
+	userID := c.GetString("user_id")
 	templateName := c.Param("name")
 
-	// 删除模板
-	if err := decision.DeletePromptTemplate(templateName); err != nil {
-		if strings.Contains(err.Error(), "不能删除系统模板") {
-			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
-		} else if strings.Contains(err.Error(), "模板不存在") {
-			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("删除模板失败: %v", err)})
-		}
+	existing, err := s.ownedPromptTemplate(userID, templateName)
+	if err != nil {
+		c.JSON(promptTemplateErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// 删除模板（使用该模板的交易员会回退到 default）
+	err = s.database.DeletePromptTemplate(userID, templateName)
+	s.recordAudit(c, auditEntry{
+		Action:     "delete_prompt_template",
+		TargetType: "prompt_template",
+		TargetID:   templateName,
+		Before:     gin.H{"name": existing.Name, "content": existing.Content},
+		Err:        err,
+	})
+	if err != nil {
+		c.JSON(promptTemplateErrorStatus(err), gin.H{"error": fmt.Sprintf("删除模板失败: %v", err)})
 		return
 	}
 
@@ -3091,14 +3610,27 @@ func (s *Server) handleGetKlines(c *gin.Context) {
 		return
 	}
 
-	// 使用market包的APIClient获取K线数据
-	apiClient := s.traderManager.GetMarketAPIClient()
-	if apiClient == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "市场数据服务不可用"})
-		return
+	// 指定 start 时按时间范围从本地历史存储查询（用于回测），否则返回最近 limit 根
+	var klines []market.Kline
+	if c.Query("start") != "" {
+		start, end, rangeErr := parseMillisRange(c)
+		if rangeErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": rangeErr.Error()})
+			return
+		}
+		// 回填前先按周期估算数量，避免大范围请求消耗与实盘共享的交易所限频额度
+		if market.EstimateKlineCount(interval, start, end) > maxKlinesPerRange {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("时间范围内K线超过%d根，请缩小范围", maxKlinesPerRange)})
+			return
+		}
+		klines, err = market.GetHistoricalKlinesRange(symbol, interval, start, end)
+		if errors.Is(err, market.ErrHistoryStoreDisabled) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+	} else {
+		klines, err = market.GetHistoricalKlines(symbol, interval, limit)
 	}
-
-	klines, err := apiClient.GetKlines(symbol, interval, limit)
 	if err != nil {
 		log.Printf("❌ 获取K线数据失败 %s %s: %v", symbol, interval, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取K线数据失败: %v", err)})
@@ -3129,14 +3661,8 @@ func (s *Server) handleGetPatternAnalysis(c *gin.Context) {
 		limit = 100
 	}
 
-	// 获取K线数据
-	apiClient := s.traderManager.GetMarketAPIClient()
-	if apiClient == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "市场数据服务不可用"})
-		return
-	}
-
-	klines, err := apiClient.GetKlines(symbol, interval, limit)
+	// 获取K线数据（本地历史存储 + 实时缓存）
+	klines, err := market.GetHistoricalKlines(symbol, interval, limit)
 	if err != nil {
 		log.Printf("❌ 获取K线数据失败 %s %s: %v", symbol, interval, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取K线数据失败: %v", err)})
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleCreateTelegramLinkCode 生成 Telegram 绑定码（在机器人中发送 /link <code> 完成绑定）
+func (s *Server) handleCreateTelegramLinkCode(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	code, expiresAt, err := s.database.CreateTelegramLinkCode(userID)
+	s.recordAudit(c, auditEntry{
+		Action:     "create_telegram_link_code",
+		TargetType: "telegram_link",
+		TargetID:   userID,
+		Err:        err,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("生成绑定码失败: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":       code,
+		"expires_at": expiresAt,
+		"command":    "/link " + code,
+	})
+}
+
+// handleGetTelegramLinks 获取当前用户绑定的 Telegram 聊天
+func (s *Server) handleGetTelegramLinks(c *gin.Context) {
+	links, err := s.database.GetTelegramLinks(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取Telegram绑定失败: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, links)
+}
+
+// handleDeleteTelegramLink 解除 Telegram 聊天绑定
+func (s *Server) handleDeleteTelegramLink(c *gin.Context) {
+	chatID, err := strconv.ParseInt(c.Param("chat_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的chat_id"})
+		return
+	}
+
+	err = s.database.UnlinkTelegramChat(c.GetString("user_id"), chatID)
+	s.recordAudit(c, auditEntry{
+		Action:     "delete_telegram_link",
+		TargetType: "telegram_link",
+		TargetID:   c.Param("chat_id"),
+		Err:        err,
+	})
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已解除绑定"})
+}
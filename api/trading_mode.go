@@ -0,0 +1,75 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"nofx/trader"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UpdateTradingModeRequest 切换运行模式请求
+type UpdateTradingModeRequest struct {
+	Mode string `json:"mode" binding:"required"` // normal / paused / reduce_only / close_out
+}
+
+// handleUpdateTradingMode 切换交易员运行模式（暂停/恢复/只减仓/清仓退出）
+func (s *Server) handleUpdateTradingMode(c *gin.Context) {
+	traderID := c.Param("id")
+	var req UpdateTradingModeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !trader.IsValidTradingMode(req.Mode) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode 仅支持 normal / paused / reduce_only / close_out"})
+		return
+	}
+
+	at, ok := s.getOwnedAutoTrader(c)
+	if !ok {
+		return
+	}
+	previous := at.GetTradingMode()
+
+	// 清仓退出：平掉所有持仓后停止交易员
+	if req.Mode == trader.TradingModeCloseOut {
+		actions, err := at.CloseOut()
+		s.recordAudit(c, auditEntry{
+			Action:     "close_out_trader",
+			TargetType: "trader",
+			TargetID:   traderID,
+			TraderID:   traderID,
+			Before:     gin.H{"trading_mode": previous},
+			After:      gin.H{"trading_mode": at.GetTradingMode(), "closed": len(actions)},
+			Err:        err,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("清仓失败（交易员保持清仓模式，将在下一周期重试）: %v", err), "actions": actions})
+			return
+		}
+
+		at.Stop()
+		log.Printf("🏁 交易员 %s 已清仓并停止", at.GetName())
+		c.JSON(http.StatusOK, gin.H{"message": "已清仓并停止交易员", "trading_mode": at.GetTradingMode(), "actions": actions})
+		return
+	}
+
+	err := at.SetTradingMode(req.Mode)
+	s.recordAudit(c, auditEntry{
+		Action:     "update_trading_mode",
+		TargetType: "trader",
+		TargetID:   traderID,
+		TraderID:   traderID,
+		Before:     gin.H{"trading_mode": previous},
+		After:      gin.H{"trading_mode": req.Mode},
+		Err:        err,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("切换运行模式失败: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "运行模式已更新", "trading_mode": req.Mode})
+}
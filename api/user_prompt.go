@@ -0,0 +1,148 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"nofx/decision"
+
+	"github.com/gin-gonic/gin"
+)
+
+// validateUserPromptTemplate 校验用户提示词模板名称（空字符串表示使用 default）
+func validateUserPromptTemplate(name string) error {
+	if name == "" || decision.UserPromptTemplateExists(name) {
+		return nil
+	}
+	return fmt.Errorf("用户提示词模板不存在: %s", name)
+}
+
+// minPromptTokenBudget 提示词 token 预算下限（过小时连持仓信息都放不下）
+const minPromptTokenBudget = 4000
+
+// validatePromptTokenBudget 校验提示词 token 预算（0 表示按AI服务商默认）
+func validatePromptTokenBudget(budget int) error {
+	if budget == 0 {
+		return nil
+	}
+	if budget < minPromptTokenBudget {
+		return fmt.Errorf("提示词token预算不能小于%d（0表示使用默认值）", minPromptTokenBudget)
+	}
+	return nil
+}
+
+// validateAgentMaxSteps 校验智能体模式最多工具调用轮数（0 表示使用默认值）
+func validateAgentMaxSteps(steps int) error {
+	if steps < 0 || steps > decision.MaxAgentMaxSteps {
+		return fmt.Errorf("智能体最多工具调用轮数必须在0-%d之间（0表示使用默认值%d）", decision.MaxAgentMaxSteps, decision.DefaultAgentMaxSteps)
+	}
+	return nil
+}
+
+// handleGetUserPromptTemplates 获取所有用户提示词模板列表
+func (s *Server) handleGetUserPromptTemplates(c *gin.Context) {
+	names := decision.GetAllUserPromptTemplateNames()
+
+	templates := make([]map[string]interface{}, 0, len(names))
+	for _, name := range names {
+		templates = append(templates, map[string]interface{}{
+			"name": name,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"templates": templates,
+		"default":   decision.DefaultUserPromptTemplate,
+	})
+}
+
+// handleGetUserPromptTemplate 获取指定用户提示词模板内容
+func (s *Server) handleGetUserPromptTemplate(c *gin.Context) {
+	name := c.Param("name")
+
+	tmpl, err := decision.GetUserPromptTemplate(name)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("模板不存在: %s", name)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"name":    tmpl.Name,
+		"content": tmpl.Content,
+	})
+}
+
+// handlePreviewTraderPrompt 基于交易员当前实时上下文预览系统/用户提示词（不调用AI）
+// 可传入 user_prompt_template 切换已有模板，或传入 content 预览尚未保存的模板草稿
+func (s *Server) handlePreviewTraderPrompt(c *gin.Context) {
+	traderID := c.Param("id")
+	userID := c.GetString("user_id")
+
+	var req struct {
+		UserPromptTemplate string `json:"user_prompt_template"`
+		Content            string `json:"content"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 校验交易员归属
+	if _, _, _, err := s.database.GetTraderConfig(userID, traderID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在"})
+		return
+	}
+	if err := validateUserPromptTemplate(req.UserPromptTemplate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 草稿模板先做语法校验，避免拉取行情后才报错
+	var draft *decision.UserPromptTemplate
+	if req.Content != "" {
+		var err error
+		draft, err = decision.ParseUserPromptTemplate("draft", req.Content)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := s.traderManager.LoadUserTraders(s.database, userID); err != nil {
+		log.Printf("⚠️ 加载用户 %s 的交易员失败: %v", userID, err)
+	}
+	at, err := s.traderManager.GetTrader(traderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx, systemPrompt, userPrompt, err := at.PreviewPrompts(req.UserPromptTemplate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("构建提示词失败: %v", err)})
+		return
+	}
+
+	templateName := ctx.UserPromptTemplate
+	if templateName == "" {
+		templateName = decision.DefaultUserPromptTemplate
+	}
+	usage := ctx.PromptUsage
+	if draft != nil {
+		userPrompt, usage, err = draft.RenderWithinBudget(ctx, systemPrompt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("模板渲染失败: %v", err)})
+			return
+		}
+		templateName = draft.Name
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"system_prompt":        systemPrompt,
+		"user_prompt":          userPrompt,
+		"user_prompt_template": templateName,
+		"prompt_usage":         usage,
+	})
+}
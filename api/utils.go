@@ -1,6 +1,11 @@
 package api
 
-import "strings"
+import (
+	"encoding/json"
+	"fmt"
+	"nofx/market"
+	"strings"
+)
 
 // MaskSensitiveString 脱敏敏感字符串，只显示前4位和后4位
 // 用于脱敏 API Key、Secret Key、Private Key 等敏感信息
@@ -95,3 +100,33 @@ func MaskEmail(email string) string {
 	}
 	return username[:2] + "****@" + domain
 }
+
+// normalizeTimeframes 校验并规范化时间线配置（逗号分隔，去除空白与重复项）
+func normalizeTimeframes(raw string) (string, error) {
+	seen := make(map[string]bool)
+	var timeframes []string
+	for _, tf := range strings.Split(raw, ",") {
+		tf = strings.TrimSpace(tf)
+		if tf == "" || seen[tf] {
+			continue
+		}
+		if !market.IsValidInterval(tf) {
+			return "", fmt.Errorf("不支持的时间线: %s", tf)
+		}
+		seen[tf] = true
+		timeframes = append(timeframes, tf)
+	}
+	return strings.Join(timeframes, ","), nil
+}
+
+// normalizeIndicators 校验指标配置并补全默认参数，返回存储用的 JSON（空配置返回空字符串）
+func normalizeIndicators(specs []market.IndicatorSpec) (string, error) {
+	if len(specs) == 0 {
+		return "", nil
+	}
+	raw, err := json.Marshal(specs)
+	if err != nil {
+		return "", err
+	}
+	return market.NormalizeIndicatorSet(string(raw))
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"nofx/market"
 	"testing"
 )
 
@@ -191,3 +192,29 @@ func TestMaskEmail(t *testing.T) {
 		})
 	}
 }
+
+func TestNormalizeTimeframes(t *testing.T) {
+	got, err := normalizeTimeframes(" 5m, 2h,5m,,1w ")
+	if err != nil || got != "5m,2h,1w" {
+		t.Errorf("normalizeTimeframes() = %q, %v", got, err)
+	}
+	if got, err := normalizeTimeframes(""); err != nil || got != "" {
+		t.Errorf("空配置应返回空字符串: %q, %v", got, err)
+	}
+	if _, err := normalizeTimeframes("1h,7m"); err == nil {
+		t.Error("不支持的时间线应返回错误")
+	}
+}
+
+func TestNormalizeIndicators(t *testing.T) {
+	got, err := normalizeIndicators([]market.IndicatorSpec{{Name: "supertrend", Timeframe: "4h"}})
+	if err != nil || got != `[{"name":"supertrend","timeframe":"4h","params":{"multiplier":3,"period":10},"points":10}]` {
+		t.Errorf("normalizeIndicators() = %q, %v", got, err)
+	}
+	if got, err := normalizeIndicators(nil); err != nil || got != "" {
+		t.Errorf("空配置应返回空字符串: %q, %v", got, err)
+	}
+	if _, err := normalizeIndicators([]market.IndicatorSpec{{Name: "adx", Timeframe: "4h", Params: market.IndicatorParams{"period": 500}}}); err == nil {
+		t.Error("超出范围的参数应返回错误")
+	}
+}
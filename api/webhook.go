@@ -0,0 +1,266 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"nofx/config"
+	"nofx/webhook"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SetWebhookDispatcher 设置 Webhook 投递器（为 nil 时测试/重发接口返回 503）
+func (s *Server) SetWebhookDispatcher(dispatcher *webhook.Dispatcher) {
+	s.webhookDispatcher = dispatcher
+}
+
+// webhookRequest 创建/更新 Webhook 请求
+type webhookRequest struct {
+	Name         string   `json:"name"`
+	URL          string   `json:"url" binding:"required"`
+	EventTypes   []string `json:"event_types"`
+	Enabled      *bool    `json:"enabled"`
+	Secret       string   `json:"secret"`        // 可选，自定义签名密钥（创建时为空则自动生成）
+	RotateSecret bool     `json:"rotate_secret"` // 更新时重新生成签名密钥
+}
+
+// validate 校验 URL（禁止指向内网地址）与事件类型
+func (r *webhookRequest) validate() error {
+	normalized, err := webhook.ValidateURL(r.URL)
+	if err != nil {
+		return err
+	}
+	r.URL = normalized
+	for _, t := range r.EventTypes {
+		if !webhook.IsValidEventType(t) {
+			return fmt.Errorf("不支持的事件类型: %s（可选: %s）", t, strings.Join(webhook.EventTypes, ", "))
+		}
+	}
+	if r.Secret != "" && len(r.Secret) < 16 {
+		return fmt.Errorf("签名密钥至少16个字符")
+	}
+	return nil
+}
+
+// handleGetWebhooks 获取当前用户的 Webhook 订阅
+func (s *Server) handleGetWebhooks(c *gin.Context) {
+	webhooks, err := s.database.GetWebhooks(c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取Webhook失败: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"webhooks":    webhooks,
+		"event_types": webhook.EventTypes,
+	})
+}
+
+// handleCreateWebhook 创建 Webhook 订阅（签名密钥仅在此时返回一次）
+func (s *Server) handleCreateWebhook(c *gin.Context) {
+	var req webhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	w := &config.WebhookSubscription{
+		UserID:     c.GetString("user_id"),
+		Name:       req.Name,
+		URL:        req.URL,
+		Secret:     req.Secret,
+		EventTypes: req.EventTypes,
+		Enabled:    req.Enabled == nil || *req.Enabled,
+	}
+	err := s.database.CreateWebhook(w)
+	s.recordAudit(c, auditEntry{
+		Action:     "create_webhook",
+		TargetType: "webhook",
+		TargetID:   w.ID,
+		After:      w,
+		Err:        err,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("创建Webhook失败: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"webhook": w,
+		"secret":  w.Secret,
+	})
+}
+
+// handleUpdateWebhook 更新 Webhook 订阅
+func (s *Server) handleUpdateWebhook(c *gin.Context) {
+	userID := c.GetString("user_id")
+	existing, err := s.database.GetWebhook(userID, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req webhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updated := *existing
+	updated.Name = req.Name
+	updated.URL = req.URL
+	updated.EventTypes = req.EventTypes
+	if req.Enabled != nil {
+		updated.Enabled = *req.Enabled
+	}
+	updated.Secret = req.Secret // 为空时保留原密钥
+	if req.RotateSecret {
+		if updated.Secret, err = config.GenerateWebhookSecret(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("生成签名密钥失败: %v", err)})
+			return
+		}
+	}
+
+	err = s.database.UpdateWebhook(&updated)
+	s.recordAudit(c, auditEntry{
+		Action:     "update_webhook",
+		TargetType: "webhook",
+		TargetID:   existing.ID,
+		Before:     existing,
+		After:      &updated,
+		Err:        err,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新Webhook失败: %v", err)})
+		return
+	}
+
+	resp := gin.H{"webhook": &updated}
+	if updated.Secret != "" {
+		resp["secret"] = updated.Secret
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// handleDeleteWebhook 删除 Webhook 订阅
+func (s *Server) handleDeleteWebhook(c *gin.Context) {
+	err := s.database.DeleteWebhook(c.GetString("user_id"), c.Param("id"))
+	s.recordAudit(c, auditEntry{
+		Action:     "delete_webhook",
+		TargetType: "webhook",
+		TargetID:   c.Param("id"),
+		Err:        err,
+	})
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook已删除"})
+}
+
+// handleTestWebhook 向 Webhook 发送一条测试事件并返回投递结果
+func (s *Server) handleTestWebhook(c *gin.Context) {
+	if s.webhookDispatcher == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Webhook推送未启用"})
+		return
+	}
+	w, err := s.database.GetWebhook(c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, s.webhookDispatcher.SendTest(w))
+}
+
+// handleGetWebhookDeadLetters 获取投递失败的死信（?subscription_id= 过滤，?limit= 默认100）
+func (s *Server) handleGetWebhookDeadLetters(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	letters, err := s.database.GetWebhookDeadLetters(c.GetString("user_id"), c.Query("subscription_id"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取死信失败: %v", err)})
+		return
+	}
+	c.JSON(http.StatusOK, letters)
+}
+
+// handleRetryWebhookDeadLetter 重新投递死信，成功后删除（每次重发都记录审计日志）
+func (s *Server) handleRetryWebhookDeadLetter(c *gin.Context) {
+	if s.webhookDispatcher == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Webhook推送未启用"})
+		return
+	}
+	userID := c.GetString("user_id")
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的死信ID"})
+		return
+	}
+	letter, err := s.database.GetWebhookDeadLetter(userID, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	w, err := s.database.GetWebhook(userID, letter.SubscriptionID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	result := s.webhookDispatcher.Redeliver(w, letter)
+	var deleteErr, auditErr error
+	if result.Success {
+		deleteErr = s.database.DeleteWebhookDeadLetter(userID, id)
+		auditErr = deleteErr
+	} else {
+		auditErr = fmt.Errorf("重新投递失败: %s", result.Error)
+	}
+	s.recordAudit(c, auditEntry{
+		Action:     "retry_webhook_dead_letter",
+		TargetType: "webhook_dead_letter",
+		TargetID:   c.Param("id"),
+		After: gin.H{
+			"subscription_id": letter.SubscriptionID,
+			"event_type":      letter.EventType,
+			"success":         result.Success,
+			"status_code":     result.StatusCode,
+			"deleted":         result.Success && deleteErr == nil,
+		},
+		Err: auditErr,
+	})
+	if deleteErr != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("删除死信失败: %v", deleteErr)})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// handleDeleteWebhookDeadLetter 删除死信
+func (s *Server) handleDeleteWebhookDeadLetter(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的死信ID"})
+		return
+	}
+	err = s.database.DeleteWebhookDeadLetter(c.GetString("user_id"), id)
+	s.recordAudit(c, auditEntry{
+		Action:     "delete_webhook_dead_letter",
+		TargetType: "webhook_dead_letter",
+		TargetID:   c.Param("id"),
+		Err:        err,
+	})
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "死信已删除"})
+}
@@ -0,0 +1,176 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"nofx/config"
+	"nofx/webhook"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setupWebhookRouter 创建 Webhook 接口测试路由（userID 通过 X-Test-User 头模拟）
+func setupWebhookRouter(t *testing.T) (*Server, *config.Database, *gin.Engine) {
+	server, db, cleanup := setupTestServer(t)
+	t.Cleanup(cleanup)
+	// 测试接收端运行在本地回环地址
+	webhook.SetAllowPrivateTargets(true)
+	t.Cleanup(func() { webhook.SetAllowPrivateTargets(false) })
+
+	router := gin.New()
+	group := router.Group("/", func(c *gin.Context) {
+		c.Set("user_id", c.GetHeader("X-Test-User"))
+		c.Next()
+	})
+	group.GET("/webhooks", server.handleGetWebhooks)
+	group.POST("/webhooks", server.handleCreateWebhook)
+	group.PUT("/webhooks/:id", server.handleUpdateWebhook)
+	group.DELETE("/webhooks/:id", server.handleDeleteWebhook)
+	group.POST("/webhooks/:id/test", server.handleTestWebhook)
+	group.GET("/webhook-dead-letters", server.handleGetWebhookDeadLetters)
+	group.POST("/webhook-dead-letters/:id/retry", server.handleRetryWebhookDeadLetter)
+	return server, db, router
+}
+
+func doWebhookRequest(router *gin.Engine, method, path, user string, body interface{}) *httptest.ResponseRecorder {
+	var reader io.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Test-User", user)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// TestWebhookAPI_CreateAndTest 测试创建、校验、列表不泄露密钥与测试投递
+func TestWebhookAPI_CreateAndTest(t *testing.T) {
+	server, _, router := setupWebhookRouter(t)
+
+	var gotSignature bool
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ts, _ := strconv.ParseInt(r.Header.Get(webhook.HeaderTimestamp), 10, 64)
+		gotSignature = webhook.Verify("my-webhook-secret-123", r.Header.Get(webhook.HeaderSignature), ts, body) &&
+			r.Header.Get(webhook.HeaderEvent) == webhook.EventTest
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	if w := doWebhookRequest(router, "POST", "/webhooks", "user-a", gin.H{"url": "ftp://x"}); w.Code != http.StatusBadRequest {
+		t.Errorf("非 http 地址应返回 400，实际 %d", w.Code)
+	}
+	webhook.SetAllowPrivateTargets(false)
+	for _, target := range []string{receiver.URL, "http://169.254.169.254/latest/meta-data"} {
+		if w := doWebhookRequest(router, "POST", "/webhooks", "user-a", gin.H{"url": target}); w.Code != http.StatusBadRequest {
+			t.Errorf("内网地址 %s 应返回 400，实际 %d", target, w.Code)
+		}
+	}
+	webhook.SetAllowPrivateTargets(true)
+	if w := doWebhookRequest(router, "POST", "/webhooks", "user-a", gin.H{"url": receiver.URL, "event_types": []string{"bogus"}}); w.Code != http.StatusBadRequest {
+		t.Errorf("无效事件类型应返回 400，实际 %d", w.Code)
+	}
+
+	w := doWebhookRequest(router, "POST", "/webhooks", "user-a", gin.H{
+		"name":        "incident",
+		"url":         receiver.URL,
+		"event_types": []string{webhook.EventStopHit, webhook.EventTraderError},
+		"secret":      "my-webhook-secret-123",
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("创建Webhook失败: %d %s", w.Code, w.Body.String())
+	}
+	var created struct {
+		Webhook config.WebhookSubscription `json:"webhook"`
+		Secret  string                     `json:"secret"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if created.Secret != "my-webhook-secret-123" || !created.Webhook.Enabled {
+		t.Fatalf("创建结果错误: %s", w.Body.String())
+	}
+	id := created.Webhook.ID
+
+	w = doWebhookRequest(router, "GET", "/webhooks", "user-a", nil)
+	if strings.Contains(w.Body.String(), "my-webhook-secret-123") {
+		t.Errorf("列表不应返回签名密钥")
+	}
+
+	if w := doWebhookRequest(router, "POST", "/webhooks/"+id+"/test", "user-a", nil); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("未启用投递器时应返回 503，实际 %d", w.Code)
+	}
+	server.SetWebhookDispatcher(webhook.NewDispatcher(server.database, webhook.Options{}))
+
+	if w := doWebhookRequest(router, "POST", "/webhooks/"+id+"/test", "user-b", nil); w.Code != http.StatusNotFound {
+		t.Errorf("其他用户测试应返回 404，实际 %d", w.Code)
+	}
+	w = doWebhookRequest(router, "POST", "/webhooks/"+id+"/test", "user-a", nil)
+	var result webhook.Result
+	json.Unmarshal(w.Body.Bytes(), &result)
+	if w.Code != http.StatusOK || !result.Success || !gotSignature {
+		t.Errorf("测试投递失败: %s signature=%v", w.Body.String(), gotSignature)
+	}
+
+	// 轮换密钥后旧密钥失效
+	w = doWebhookRequest(router, "PUT", "/webhooks/"+id, "user-a", gin.H{"url": receiver.URL, "rotate_secret": true})
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "my-webhook-secret-123") || !strings.Contains(w.Body.String(), "whsec_") {
+		t.Errorf("轮换密钥失败: %s", w.Body.String())
+	}
+}
+
+// TestWebhookAPI_RetryDeadLetter 测试死信重新投递成功后删除
+func TestWebhookAPI_RetryDeadLetter(t *testing.T) {
+	server, db, router := setupWebhookRouter(t)
+	server.SetWebhookDispatcher(webhook.NewDispatcher(db, webhook.Options{}))
+
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer receiver.Close()
+
+	sub := &config.WebhookSubscription{UserID: "user-a", URL: receiver.URL, Enabled: true}
+	if err := db.CreateWebhook(sub); err != nil {
+		t.Fatalf("创建Webhook失败: %v", err)
+	}
+	dl := &config.WebhookDeadLetter{SubscriptionID: sub.ID, UserID: "user-a", EventType: webhook.EventTradeClosed,
+		Payload: `{"id":"evt-1","type":"trade_closed"}`, Attempts: 5, LastStatus: 502}
+	if err := db.AddWebhookDeadLetter(dl); err != nil {
+		t.Fatalf("写入死信失败: %v", err)
+	}
+
+	path := "/webhook-dead-letters/" + strconv.FormatInt(dl.ID, 10) + "/retry"
+	if w := doWebhookRequest(router, "POST", path, "user-b", nil); w.Code != http.StatusNotFound {
+		t.Errorf("其他用户重发应返回 404，实际 %d", w.Code)
+	}
+	if w := doWebhookRequest(router, "POST", path, "user-a", nil); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"success":true`) {
+		t.Fatalf("重发失败: %d %s", w.Code, w.Body.String())
+	}
+
+	w := doWebhookRequest(router, "GET", "/webhook-dead-letters", "user-a", nil)
+	if strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("重发成功后死信应删除: %s", w.Body.String())
+	}
+
+	logs, err := db.QueryAuditLogs(config.AuditLogFilter{UserID: "user-a"})
+	if err != nil {
+		t.Fatalf("查询审计日志失败: %v", err)
+	}
+	var retried *config.AuditLogRecord
+	for _, l := range logs {
+		if l.Action == "retry_webhook_dead_letter" {
+			retried = l
+		}
+	}
+	if retried == nil || retried.TargetID != strconv.FormatInt(dl.ID, 10) || !retried.Success ||
+		!strings.Contains(retried.After, sub.ID) || !strings.Contains(retried.After, `"status_code":202`) {
+		t.Errorf("重发死信应记录审计日志: %+v", retried)
+	}
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AuditLogRecord 操作审计记录（覆盖 API 层所有写操作，区别于 crypto 包中仅记录密钥事件的 audit_logs）
+type AuditLogRecord struct {
+	ID         int64     `json:"id"`
+	UserID     string    `json:"user_id"`     // 操作者
+	IPAddress  string    `json:"ip_address"`  // 来源IP
+	Action     string    `json:"action"`      // 操作类型，例如 create_trader / update_exchange_configs
+	TargetType string    `json:"target_type"` // 目标类型：trader / ai_model / exchange / prompt_template / user ...
+	TargetID   string    `json:"target_id"`   // 目标ID
+	TraderID   string    `json:"trader_id"`   // 关联的交易员（便于按交易员过滤，可为空）
+	Before     string    `json:"before"`      // 变更前快照（JSON，已脱敏）
+	After      string    `json:"after"`       // 变更后快照（JSON，已脱敏）
+	Success    bool      `json:"success"`     // 操作是否成功
+	Error      string    `json:"error"`       // 失败原因
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// AuditLogFilter 审计日志查询条件（零值字段表示不过滤）
+type AuditLogFilter struct {
+	UserID   string
+	TraderID string
+	Action   string
+	Since    time.Time
+	Until    time.Time
+	Limit    int
+	Offset   int
+}
+
+// CreateAuditLog 写入一条审计记录
+func (d *Database) CreateAuditLog(record *AuditLogRecord) error {
+	if record.CreatedAt.IsZero() {
+		record.CreatedAt = time.Now().UTC()
+	}
+	result, err := d.db.Exec(`
+		INSERT INTO operation_audit_logs (user_id, ip_address, action, target_type, target_id, trader_id, before_data, after_data, success, error, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, record.UserID, record.IPAddress, record.Action, record.TargetType, record.TargetID, record.TraderID,
+		record.Before, record.After, record.Success, record.Error, record.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("写入审计日志失败: %w", err)
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		record.ID = id
+	}
+	return nil
+}
+
+// QueryAuditLogs 按条件查询审计记录（按时间倒序）
+func (d *Database) QueryAuditLogs(filter AuditLogFilter) ([]*AuditLogRecord, error) {
+	var conditions []string
+	var args []interface{}
+
+	if filter.UserID != "" {
+		conditions = append(conditions, "user_id = ?")
+		args = append(args, filter.UserID)
+	}
+	if filter.TraderID != "" {
+		conditions = append(conditions, "trader_id = ?")
+		args = append(args, filter.TraderID)
+	}
+	if filter.Action != "" {
+		conditions = append(conditions, "action = ?")
+		args = append(args, filter.Action)
+	}
+	if !filter.Since.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.Since.UTC())
+	}
+	if !filter.Until.IsZero() {
+		conditions = append(conditions, "created_at <= ?")
+		args = append(args, filter.Until.UTC())
+	}
+
+	query := `SELECT id, user_id, ip_address, action, target_type, target_id, trader_id, before_data, after_data, success, error, created_at
+		FROM operation_audit_logs`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	query += " LIMIT ? OFFSET ?"
+	args = append(args, limit, filter.Offset)
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询审计日志失败: %w", err)
+	}
+	defer rows.Close()
+
+	var records []*AuditLogRecord
+	for rows.Next() {
+		var record AuditLogRecord
+		if err := rows.Scan(
+			&record.ID, &record.UserID, &record.IPAddress, &record.Action,
+			&record.TargetType, &record.TargetID, &record.TraderID,
+			&record.Before, &record.After, &record.Success, &record.Error, &record.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("读取审计日志失败: %w", err)
+		}
+		records = append(records, &record)
+	}
+
+	return records, rows.Err()
+}
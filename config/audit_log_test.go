@@ -0,0 +1,70 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+// TestAuditLog_CreateAndQuery 测试审计记录的写入与按用户/交易员/时间过滤
+func TestAuditLog_CreateAndQuery(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	now := time.Now().UTC()
+	records := []*AuditLogRecord{
+		{UserID: "test-user-001", Action: "create_trader", TargetType: "trader", TargetID: "t1", TraderID: "t1", After: `{"name":"a"}`, Success: true, CreatedAt: now.Add(-2 * time.Hour)},
+		{UserID: "test-user-001", Action: "update_trader", TargetType: "trader", TargetID: "t1", TraderID: "t1", Before: `{"name":"a"}`, After: `{"name":"b"}`, Success: true, CreatedAt: now.Add(-1 * time.Hour)},
+		{UserID: "test-user-001", Action: "update_trader", TargetType: "trader", TargetID: "t2", TraderID: "t2", Success: false, Error: "boom", CreatedAt: now},
+		{UserID: "test-user-002", Action: "delete_trader", TargetType: "trader", TargetID: "t3", TraderID: "t3", Success: true, CreatedAt: now},
+	}
+	for _, record := range records {
+		if err := db.CreateAuditLog(record); err != nil {
+			t.Fatalf("写入审计日志失败: %v", err)
+		}
+		if record.ID == 0 {
+			t.Errorf("写入后应回填自增ID")
+		}
+	}
+
+	byUser, err := db.QueryAuditLogs(AuditLogFilter{UserID: "test-user-001"})
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if len(byUser) != 3 {
+		t.Fatalf("按用户过滤应返回 3 条，实际 %d", len(byUser))
+	}
+	if byUser[0].TraderID != "t2" || byUser[0].Success || byUser[0].Error != "boom" {
+		t.Errorf("结果应按时间倒序且保留失败信息，实际首条: %+v", byUser[0])
+	}
+
+	byTrader, err := db.QueryAuditLogs(AuditLogFilter{UserID: "test-user-001", TraderID: "t1"})
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if len(byTrader) != 2 {
+		t.Fatalf("按交易员过滤应返回 2 条，实际 %d", len(byTrader))
+	}
+	if byTrader[0].Before != `{"name":"a"}` || byTrader[0].After != `{"name":"b"}` {
+		t.Errorf("前后快照未正确保存: %+v", byTrader[0])
+	}
+
+	byTime, err := db.QueryAuditLogs(AuditLogFilter{
+		UserID: "test-user-001",
+		Since:  now.Add(-90 * time.Minute),
+		Until:  now.Add(-30 * time.Minute),
+	})
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if len(byTime) != 1 || byTime[0].Action != "update_trader" || byTime[0].TraderID != "t1" {
+		t.Errorf("按时间范围过滤结果不正确: %+v", byTime)
+	}
+
+	limited, err := db.QueryAuditLogs(AuditLogFilter{Limit: 2, Offset: 1})
+	if err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	if len(limited) != 2 {
+		t.Errorf("分页应返回 2 条，实际 %d", len(limited))
+	}
+}
@@ -35,6 +35,8 @@ type DatabaseInterface interface {
 	CreateTrader(trader *TraderRecord) error
 	GetTraders(userID string) ([]*TraderRecord, error)
 	UpdateTraderStatus(userID, id string, isRunning bool) error
+	UpdateTraderTradingMode(userID, id, mode string) error
+	UpdateTraderApprovalSettings(userID, id string, required bool, minNotional float64, minLeverage, expiryMinutes int, priceTolerancePct float64) error
 	UpdateTrader(trader *TraderRecord) error
 	UpdateTraderInitialBalance(userID, id string, newBalance float64) error
 	UpdateTraderCustomPrompt(userID, id string, customPrompt string, overrideBase bool) error
@@ -51,6 +53,39 @@ type DatabaseInterface interface {
 	ValidateBetaCode(code string) (bool, error)
 	UseBetaCode(code, userEmail string) error
 	GetBetaCodeStats() (total, used int, err error)
+	CreateAuditLog(record *AuditLogRecord) error
+	QueryAuditLogs(filter AuditLogFilter) ([]*AuditLogRecord, error)
+	CreateTelegramLinkCode(userID string) (string, time.Time, error)
+	LinkTelegramChat(code string, chatID int64, username string) (string, error)
+	GetTelegramChatUser(chatID int64) (string, error)
+	GetTelegramLinks(userID string) ([]*TelegramLink, error)
+	UnlinkTelegramChat(userID string, chatID int64) error
+	CreateWebhook(w *WebhookSubscription) error
+	UpdateWebhook(w *WebhookSubscription) error
+	DeleteWebhook(userID, id string) error
+	GetWebhook(userID, id string) (*WebhookSubscription, error)
+	GetWebhooks(userID string) ([]*WebhookSubscription, error)
+	AddWebhookDeadLetter(dl *WebhookDeadLetter) error
+	GetWebhookDeadLetters(userID, subscriptionID string, limit int) ([]*WebhookDeadLetter, error)
+	GetWebhookDeadLetter(userID string, id int64) (*WebhookDeadLetter, error)
+	DeleteWebhookDeadLetter(userID string, id int64) error
+	CreateExperiment(e *Experiment) error
+	StopExperiment(userID, id string) error
+	DeleteExperiment(userID, id string) error
+	GetExperiment(userID, id string) (*Experiment, error)
+	GetExperiments(userID string) ([]*Experiment, error)
+	GetRunningExperimentForTrader(userID, traderID string) (*Experiment, error)
+	CreatePromptTemplate(t *PromptTemplateConfig, author, note string) error
+	UpdatePromptTemplate(t *PromptTemplateConfig, author, note string) error
+	DeletePromptTemplate(userID, name string) error
+	GetPromptTemplate(userID, name string) (*PromptTemplateConfig, error)
+	GetPromptTemplateContent(userID, name string) (string, error)
+	GetPromptTemplates(userID string) ([]*PromptTemplateConfig, error)
+	GetSharedPromptTemplates() ([]*PromptTemplateConfig, error)
+	GetSharedPromptTemplate(id string) (*PromptTemplateConfig, error)
+	SetPromptTemplatePublic(userID, name string, public bool) error
+	GetPromptTemplateVersions(userID, name string) ([]*PromptTemplateVersionRecord, error)
+	GetPromptTemplateVersion(userID, name, hash string) (*PromptTemplateVersionRecord, error)
 	Close() error
 }
 
@@ -187,6 +222,20 @@ func (d *Database) createTables() error {
 			limit_price_offset REAL DEFAULT -0.03,
 			limit_timeout_seconds INTEGER DEFAULT 60,
 			timeframes TEXT DEFAULT '4h',
+			indicators TEXT DEFAULT '',
+			user_prompt_template TEXT DEFAULT '',
+			prompt_token_budget INTEGER DEFAULT 0,
+			agent_mode BOOLEAN DEFAULT 0,
+			agent_max_steps INTEGER DEFAULT 0,
+			position_sizing_mode TEXT DEFAULT 'ai',
+			kelly_fraction REAL DEFAULT 0,
+			regime_policies TEXT DEFAULT '',
+			trading_mode TEXT DEFAULT 'normal',
+			approval_required BOOLEAN DEFAULT 0,
+			approval_min_notional REAL DEFAULT 0,
+			approval_min_leverage INTEGER DEFAULT 0,
+			approval_expiry_minutes INTEGER DEFAULT 15,
+			approval_price_tolerance_pct REAL DEFAULT 0.5,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
@@ -221,6 +270,130 @@ func (d *Database) createTables() error {
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		)`,
 
+		// 操作审计表（记录 API 所有写操作，前后快照已脱敏）
+		`CREATE TABLE IF NOT EXISTS operation_audit_logs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id TEXT NOT NULL DEFAULT '',
+			ip_address TEXT DEFAULT '',
+			action TEXT NOT NULL,
+			target_type TEXT DEFAULT '',
+			target_id TEXT DEFAULT '',
+			trader_id TEXT DEFAULT '',
+			before_data TEXT DEFAULT '',
+			after_data TEXT DEFAULT '',
+			success BOOLEAN DEFAULT 1,
+			error TEXT DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_operation_audit_logs_user ON operation_audit_logs(user_id, created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_operation_audit_logs_trader ON operation_audit_logs(trader_id, created_at)`,
+
+		// Telegram 聊天绑定表（机器人按绑定用户授权）
+		`CREATE TABLE IF NOT EXISTS telegram_links (
+			chat_id INTEGER PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			username TEXT DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_telegram_links_user ON telegram_links(user_id)`,
+
+		// Telegram 一次性绑定码
+		`CREATE TABLE IF NOT EXISTS telegram_link_codes (
+			code TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			expires_at DATETIME NOT NULL
+		)`,
+
+		// Webhook 订阅（密钥加密存储，event_types 为逗号分隔）
+		`CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			name TEXT DEFAULT '',
+			url TEXT NOT NULL,
+			secret TEXT NOT NULL,
+			event_types TEXT DEFAULT '',
+			enabled BOOLEAN DEFAULT 1,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_subscriptions_user ON webhook_subscriptions(user_id)`,
+
+		// Webhook 死信（重试耗尽仍失败的消息，可手动重新投递）
+		`CREATE TABLE IF NOT EXISTS webhook_dead_letters (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			subscription_id TEXT NOT NULL,
+			user_id TEXT NOT NULL,
+			event_type TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			attempts INTEGER DEFAULT 0,
+			last_status INTEGER DEFAULT 0,
+			last_error TEXT DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_dead_letters_user ON webhook_dead_letters(user_id, id)`,
+
+		// A/B 实验（variants 为实验组配置的 JSON 数组）
+		`CREATE TABLE IF NOT EXISTS experiments (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			name TEXT DEFAULT '',
+			control_trader_id TEXT NOT NULL,
+			variants TEXT NOT NULL DEFAULT '[]',
+			status TEXT NOT NULL DEFAULT 'running',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			stopped_at DATETIME
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_experiments_user ON experiments(user_id, control_trader_id)`,
+
+		// 用户自建系统提示词模板（按用户隔离，可公开共享给其他用户复制）
+		`CREATE TABLE IF NOT EXISTS prompt_templates (
+			id TEXT PRIMARY KEY,
+			user_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			content TEXT NOT NULL,
+			description TEXT DEFAULT '',
+			version TEXT DEFAULT '',
+			is_public BOOLEAN DEFAULT 0,
+			forked_from TEXT DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(user_id, name)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_prompt_templates_public ON prompt_templates(is_public, updated_at)`,
+
+		// 用户模板版本历史（只追加）
+		`CREATE TABLE IF NOT EXISTS prompt_template_versions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			template_id TEXT NOT NULL,
+			hash TEXT NOT NULL,
+			author TEXT DEFAULT '',
+			note TEXT DEFAULT '',
+			content TEXT NOT NULL,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_prompt_template_versions_template ON prompt_template_versions(template_id, id)`,
+
+		// 交易复盘经验（平仓后由AI总结，按币种、市场状态和关键词检索后写入用户提示词）
+		`CREATE TABLE IF NOT EXISTS trade_lessons (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id TEXT NOT NULL,
+			trader_id TEXT NOT NULL,
+			trade_key TEXT NOT NULL,
+			symbol TEXT NOT NULL,
+			side TEXT DEFAULT '',
+			regime TEXT DEFAULT '',
+			setup TEXT DEFAULT '',
+			lesson TEXT NOT NULL,
+			keywords TEXT DEFAULT '[]',
+			pnl REAL DEFAULT 0,
+			pnl_pct REAL DEFAULT 0,
+			closed_at DATETIME,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(trader_id, trade_key)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_trade_lessons_user ON trade_lessons(user_id, trader_id, id)`,
+
 		// 触发器：自动更新 updated_at
 		`CREATE TRIGGER IF NOT EXISTS update_users_updated_at
 			AFTER UPDATE ON users
@@ -288,6 +461,20 @@ func (d *Database) createTables() error {
 		`ALTER TABLE traders ADD COLUMN limit_price_offset REAL DEFAULT -0.03`,             // Limit order price offset percentage (e.g., -0.03 for -0.03%)
 		`ALTER TABLE traders ADD COLUMN limit_timeout_seconds INTEGER DEFAULT 60`,          // Timeout in seconds before converting to market order
 		`ALTER TABLE traders ADD COLUMN timeframes TEXT DEFAULT '4h'`,                      // 时间线选择 (逗号分隔，例如: "1m,4h,1d")
+		`ALTER TABLE traders ADD COLUMN indicators TEXT DEFAULT ''`,                        // 指标配置（JSON数组，按时间周期选择指标与参数）
+		`ALTER TABLE traders ADD COLUMN user_prompt_template TEXT DEFAULT ''`,              // 用户提示词模板名称（prompts/user/*.tmpl，为空使用默认模板）
+		`ALTER TABLE traders ADD COLUMN prompt_token_budget INTEGER DEFAULT 0`,             // 提示词 token 预算（0=按AI服务商默认）
+		`ALTER TABLE traders ADD COLUMN agent_mode BOOLEAN DEFAULT 0`,                      // 智能体模式（AI可在周期内调用工具按需获取数据）
+		`ALTER TABLE traders ADD COLUMN agent_max_steps INTEGER DEFAULT 0`,                 // 智能体模式最多调用轮数（0=默认）
+		`ALTER TABLE traders ADD COLUMN position_sizing_mode TEXT DEFAULT 'ai'`,            // 仓位计算模式: ai（使用AI给出的仓位）, kelly（按信心度校准的分数Kelly）
+		`ALTER TABLE traders ADD COLUMN kelly_fraction REAL DEFAULT 0`,                     // 分数Kelly系数（0=默认）
+		`ALTER TABLE traders ADD COLUMN regime_policies TEXT DEFAULT ''`,                   // 市场状态策略（JSON对象：状态 → 模板/杠杆上限/禁止开仓）
+		`ALTER TABLE traders ADD COLUMN trading_mode TEXT DEFAULT 'normal'`,                // 运行模式: normal, paused, reduce_only, close_out
+		`ALTER TABLE traders ADD COLUMN approval_required BOOLEAN DEFAULT 0`,               // 开仓是否需要人工审批
+		`ALTER TABLE traders ADD COLUMN approval_min_notional REAL DEFAULT 0`,              // 仓位价值达到该值时需审批（0=不限）
+		`ALTER TABLE traders ADD COLUMN approval_min_leverage INTEGER DEFAULT 0`,           // 杠杆达到该值时需审批（0=不限）
+		`ALTER TABLE traders ADD COLUMN approval_expiry_minutes INTEGER DEFAULT 15`,        // 审批提案有效期（分钟）
+		`ALTER TABLE traders ADD COLUMN approval_price_tolerance_pct REAL DEFAULT 0.5`,     // 批准时允许的价格偏离（%）
 		`ALTER TABLE ai_models ADD COLUMN custom_api_url TEXT DEFAULT ''`,                  // 自定义API地址
 		`ALTER TABLE ai_models ADD COLUMN custom_model_name TEXT DEFAULT ''`,               // 自定义模型名称
 	}
@@ -1060,6 +1247,22 @@ type TraderRecord struct {
 	LimitPriceOffset     float64   `json:"limit_price_offset"`     // Limit order price offset percentage (e.g., -0.03 for -0.03%)
 	LimitTimeoutSeconds  int       `json:"limit_timeout_seconds"`  // Timeout in seconds before converting to market order (default: 60)
 	Timeframes           string    `json:"timeframes"`             // 时间线选择 (逗号分隔，例如: "1m,4h,1d")
+	Indicators           string    `json:"indicators"`             // 指标配置（JSON数组，例如: [{"name":"bollinger","timeframe":"1h","params":{"period":20}}]）
+	UserPromptTemplate   string    `json:"user_prompt_template"`   // 用户提示词模板名称（为空使用 default）
+	PromptTokenBudget    int       `json:"prompt_token_budget"`    // 提示词 token 预算（0=按AI服务商默认）
+	AgentMode            bool      `json:"agent_mode"`             // 智能体模式（AI可在周期内调用工具按需获取数据）
+	AgentMaxSteps        int       `json:"agent_max_steps"`        // 智能体模式最多调用轮数（0=默认）
+	PositionSizingMode   string    `json:"position_sizing_mode"`   // 仓位计算模式: ai, kelly
+	KellyFraction        float64   `json:"kelly_fraction"`         // 分数Kelly系数（0=默认）
+	RegimePolicies       string    `json:"regime_policies"`        // 市场状态策略（JSON对象，例如: {"risk_off":{"no_new_entries":true}}）
+	TradingMode          string    `json:"trading_mode"`           // 运行模式: normal, paused, reduce_only, close_out
+
+	ApprovalRequired          bool    `json:"approval_required"`            // 开仓是否需要人工审批
+	ApprovalMinNotional       float64 `json:"approval_min_notional"`        // 仓位价值达到该值时需审批（0=不限）
+	ApprovalMinLeverage       int     `json:"approval_min_leverage"`        // 杠杆达到该值时需审批（0=不限）
+	ApprovalExpiryMinutes     int     `json:"approval_expiry_minutes"`      // 审批提案有效期（分钟）
+	ApprovalPriceTolerancePct float64 `json:"approval_price_tolerance_pct"` // 批准时允许的价格偏离（%）
+
 	CreatedAt            time.Time `json:"created_at"`
 	UpdatedAt            time.Time `json:"updated_at"`
 }
@@ -1624,9 +1827,9 @@ func (d *Database) CreateExchange(userID, id, name, typ string, enabled bool, ap
 // CreateTrader 创建交易员
 func (d *Database) CreateTrader(trader *TraderRecord) error {
 	_, err := d.db.Exec(`
-		INSERT INTO traders (id, user_id, name, ai_model_id, exchange_id, initial_balance, scan_interval_minutes, is_running, btc_eth_leverage, altcoin_leverage, trading_symbols, use_coin_pool, use_oi_top, custom_prompt, override_base_prompt, system_prompt_template, is_cross_margin, taker_fee_rate, maker_fee_rate, order_strategy, limit_price_offset, limit_timeout_seconds, timeframes)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, trader.ID, trader.UserID, trader.Name, trader.AIModelID, trader.ExchangeID, trader.InitialBalance, trader.ScanIntervalMinutes, trader.IsRunning, trader.BTCETHLeverage, trader.AltcoinLeverage, trader.TradingSymbols, trader.UseCoinPool, trader.UseOITop, trader.CustomPrompt, trader.OverrideBasePrompt, trader.SystemPromptTemplate, trader.IsCrossMargin, trader.TakerFeeRate, trader.MakerFeeRate, trader.OrderStrategy, trader.LimitPriceOffset, trader.LimitTimeoutSeconds, trader.Timeframes)
+		INSERT INTO traders (id, user_id, name, ai_model_id, exchange_id, initial_balance, scan_interval_minutes, is_running, btc_eth_leverage, altcoin_leverage, trading_symbols, use_coin_pool, use_oi_top, custom_prompt, override_base_prompt, system_prompt_template, is_cross_margin, taker_fee_rate, maker_fee_rate, order_strategy, limit_price_offset, limit_timeout_seconds, timeframes, indicators, user_prompt_template, prompt_token_budget, agent_mode, agent_max_steps, position_sizing_mode, kelly_fraction, regime_policies)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, trader.ID, trader.UserID, trader.Name, trader.AIModelID, trader.ExchangeID, trader.InitialBalance, trader.ScanIntervalMinutes, trader.IsRunning, trader.BTCETHLeverage, trader.AltcoinLeverage, trader.TradingSymbols, trader.UseCoinPool, trader.UseOITop, trader.CustomPrompt, trader.OverrideBasePrompt, trader.SystemPromptTemplate, trader.IsCrossMargin, trader.TakerFeeRate, trader.MakerFeeRate, trader.OrderStrategy, trader.LimitPriceOffset, trader.LimitTimeoutSeconds, trader.Timeframes, trader.Indicators, trader.UserPromptTemplate, trader.PromptTokenBudget, trader.AgentMode, trader.AgentMaxSteps, trader.PositionSizingMode, trader.KellyFraction, trader.RegimePolicies)
 	return err
 }
 
@@ -1645,6 +1848,18 @@ func (d *Database) GetTraders(userID string) ([]*TraderRecord, error) {
 		       COALESCE(limit_price_offset, -0.03) as limit_price_offset,
 		       COALESCE(limit_timeout_seconds, 60) as limit_timeout_seconds,
 		       COALESCE(timeframes, '4h') as timeframes,
+		       COALESCE(indicators, '') as indicators,
+		       COALESCE(user_prompt_template, '') as user_prompt_template,
+		       COALESCE(prompt_token_budget, 0) as prompt_token_budget,
+		       COALESCE(agent_mode, 0) as agent_mode, COALESCE(agent_max_steps, 0) as agent_max_steps,
+		       COALESCE(position_sizing_mode, 'ai') as position_sizing_mode, COALESCE(kelly_fraction, 0) as kelly_fraction,
+		       COALESCE(regime_policies, '') as regime_policies,
+		       COALESCE(trading_mode, 'normal') as trading_mode,
+		       COALESCE(approval_required, 0) as approval_required,
+		       COALESCE(approval_min_notional, 0) as approval_min_notional,
+		       COALESCE(approval_min_leverage, 0) as approval_min_leverage,
+		       COALESCE(approval_expiry_minutes, 15) as approval_expiry_minutes,
+		       COALESCE(approval_price_tolerance_pct, 0.5) as approval_price_tolerance_pct,
 		       created_at, updated_at
 		FROM traders WHERE user_id = ? ORDER BY created_at DESC
 	`, userID)
@@ -1666,6 +1881,15 @@ func (d *Database) GetTraders(userID string) ([]*TraderRecord, error) {
 			&trader.TakerFeeRate, &trader.MakerFeeRate,
 			&trader.OrderStrategy, &trader.LimitPriceOffset, &trader.LimitTimeoutSeconds,
 			&trader.Timeframes,
+			&trader.Indicators,
+			&trader.UserPromptTemplate,
+			&trader.PromptTokenBudget,
+			&trader.AgentMode, &trader.AgentMaxSteps,
+			&trader.PositionSizingMode, &trader.KellyFraction,
+			&trader.RegimePolicies,
+			&trader.TradingMode,
+			&trader.ApprovalRequired, &trader.ApprovalMinNotional, &trader.ApprovalMinLeverage,
+			&trader.ApprovalExpiryMinutes, &trader.ApprovalPriceTolerancePct,
 			&trader.CreatedAt, &trader.UpdatedAt,
 		)
 		if err != nil {
@@ -1683,6 +1907,24 @@ func (d *Database) UpdateTraderStatus(userID, id string, isRunning bool) error {
 	return err
 }
 
+// UpdateTraderTradingMode 更新交易员运行模式（normal/paused/reduce_only/close_out）
+func (d *Database) UpdateTraderTradingMode(userID, id, mode string) error {
+	_, err := d.db.Exec(`UPDATE traders SET trading_mode = ? WHERE id = ? AND user_id = ?`, mode, id, userID)
+	return err
+}
+
+// UpdateTraderApprovalSettings 更新交易员人工审批设置
+func (d *Database) UpdateTraderApprovalSettings(userID, id string, required bool, minNotional float64, minLeverage, expiryMinutes int, priceTolerancePct float64) error {
+	_, err := d.db.Exec(`
+		UPDATE traders SET
+			approval_required = ?, approval_min_notional = ?, approval_min_leverage = ?,
+			approval_expiry_minutes = ?, approval_price_tolerance_pct = ?,
+			updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND user_id = ?
+	`, required, minNotional, minLeverage, expiryMinutes, priceTolerancePct, id, userID)
+	return err
+}
+
 // UpdateTrader 更新交易员配置
 func (d *Database) UpdateTrader(trader *TraderRecord) error {
 	_, err := d.db.Exec(`
@@ -1692,14 +1934,16 @@ func (d *Database) UpdateTrader(trader *TraderRecord) error {
 			trading_symbols = ?, custom_prompt = ?, override_base_prompt = ?,
 			system_prompt_template = ?, is_cross_margin = ?, taker_fee_rate = ?, maker_fee_rate = ?,
 			order_strategy = ?, limit_price_offset = ?, limit_timeout_seconds = ?, timeframes = ?,
-			updated_at = CURRENT_TIMESTAMP
+			indicators = ?, user_prompt_template = ?, prompt_token_budget = ?, agent_mode = ?, agent_max_steps = ?,
+			position_sizing_mode = ?, kelly_fraction = ?, regime_policies = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ? AND user_id = ?
 	`, trader.Name, trader.AIModelID, trader.ExchangeID,
 		trader.ScanIntervalMinutes, trader.BTCETHLeverage, trader.AltcoinLeverage,
 		trader.TradingSymbols, trader.CustomPrompt, trader.OverrideBasePrompt,
 		trader.SystemPromptTemplate, trader.IsCrossMargin, trader.TakerFeeRate, trader.MakerFeeRate,
 		trader.OrderStrategy, trader.LimitPriceOffset, trader.LimitTimeoutSeconds, trader.Timeframes,
-		trader.ID, trader.UserID)
+		trader.Indicators, trader.UserPromptTemplate, trader.PromptTokenBudget, trader.AgentMode, trader.AgentMaxSteps,
+		trader.PositionSizingMode, trader.KellyFraction, trader.RegimePolicies, trader.ID, trader.UserID)
 	return err
 }
 
@@ -1746,6 +1990,18 @@ func (d *Database) GetTraderConfig(userID, traderID string) (*TraderRecord, *AIM
 			COALESCE(t.limit_price_offset, -0.03) as limit_price_offset,
 			COALESCE(t.limit_timeout_seconds, 60) as limit_timeout_seconds,
 			COALESCE(t.timeframes, '4h') as timeframes,
+			COALESCE(t.indicators, '') as indicators,
+			COALESCE(t.user_prompt_template, '') as user_prompt_template,
+			COALESCE(t.prompt_token_budget, 0) as prompt_token_budget,
+			COALESCE(t.agent_mode, 0) as agent_mode, COALESCE(t.agent_max_steps, 0) as agent_max_steps,
+			COALESCE(t.position_sizing_mode, 'ai') as position_sizing_mode, COALESCE(t.kelly_fraction, 0) as kelly_fraction,
+			COALESCE(t.regime_policies, '') as regime_policies,
+			COALESCE(t.trading_mode, 'normal') as trading_mode,
+			COALESCE(t.approval_required, 0) as approval_required,
+			COALESCE(t.approval_min_notional, 0) as approval_min_notional,
+			COALESCE(t.approval_min_leverage, 0) as approval_min_leverage,
+			COALESCE(t.approval_expiry_minutes, 15) as approval_expiry_minutes,
+			COALESCE(t.approval_price_tolerance_pct, 0.5) as approval_price_tolerance_pct,
 			t.created_at, t.updated_at,
 			a.id, a.model_id, a.user_id, a.name, a.provider, a.enabled, a.api_key,
 			COALESCE(a.custom_api_url, '') as custom_api_url,
@@ -1771,6 +2027,15 @@ func (d *Database) GetTraderConfig(userID, traderID string) (*TraderRecord, *AIM
 		&trader.TakerFeeRate, &trader.MakerFeeRate,
 		&trader.OrderStrategy, &trader.LimitPriceOffset, &trader.LimitTimeoutSeconds,
 		&trader.Timeframes,
+		&trader.Indicators,
+		&trader.UserPromptTemplate,
+		&trader.PromptTokenBudget,
+		&trader.AgentMode, &trader.AgentMaxSteps,
+		&trader.PositionSizingMode, &trader.KellyFraction,
+		&trader.RegimePolicies,
+		&trader.TradingMode,
+		&trader.ApprovalRequired, &trader.ApprovalMinNotional, &trader.ApprovalMinLeverage,
+		&trader.ApprovalExpiryMinutes, &trader.ApprovalPriceTolerancePct,
 		&trader.CreatedAt, &trader.UpdatedAt,
 		&aiModel.ID, &aiModel.ModelID, &aiModel.UserID, &aiModel.Name, &aiModel.Provider, &aiModel.Enabled, &aiModel.APIKey,
 		&aiModel.CustomAPIURL, &aiModel.CustomModelName,
@@ -2129,6 +2394,20 @@ func (d *Database) cleanupLegacyColumns() error {
 			limit_price_offset REAL DEFAULT -0.03,
 			limit_timeout_seconds INTEGER DEFAULT 60,
 			timeframes TEXT DEFAULT '4h',
+			indicators TEXT DEFAULT '',
+			user_prompt_template TEXT DEFAULT '',
+			prompt_token_budget INTEGER DEFAULT 0,
+			agent_mode BOOLEAN DEFAULT 0,
+			agent_max_steps INTEGER DEFAULT 0,
+			position_sizing_mode TEXT DEFAULT 'ai',
+			kelly_fraction REAL DEFAULT 0,
+			regime_policies TEXT DEFAULT '',
+			trading_mode TEXT DEFAULT 'normal',
+			approval_required BOOLEAN DEFAULT 0,
+			approval_min_notional REAL DEFAULT 0,
+			approval_min_leverage INTEGER DEFAULT 0,
+			approval_expiry_minutes INTEGER DEFAULT 15,
+			approval_price_tolerance_pct REAL DEFAULT 0.5,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
@@ -2151,6 +2430,10 @@ func (d *Database) cleanupLegacyColumns() error {
 			is_cross_margin, use_default_coins, custom_coins,
 			taker_fee_rate, maker_fee_rate, order_strategy,
 			limit_price_offset, limit_timeout_seconds, timeframes,
+			indicators, user_prompt_template, prompt_token_budget, agent_mode, agent_max_steps,
+			position_sizing_mode, kelly_fraction, regime_policies, trading_mode,
+			approval_required, approval_min_notional, approval_min_leverage,
+			approval_expiry_minutes, approval_price_tolerance_pct,
 			created_at, updated_at
 		)
 		SELECT
@@ -2162,6 +2445,10 @@ func (d *Database) cleanupLegacyColumns() error {
 			COALESCE(is_cross_margin, 1), COALESCE(use_default_coins, 1), COALESCE(custom_coins, ''),
 			COALESCE(taker_fee_rate, 0.0004), COALESCE(maker_fee_rate, 0.0002), COALESCE(order_strategy, 'conservative_hybrid'),
 			COALESCE(limit_price_offset, -0.03), COALESCE(limit_timeout_seconds, 60), COALESCE(timeframes, '4h'),
+			COALESCE(indicators, ''), COALESCE(user_prompt_template, ''), COALESCE(prompt_token_budget, 0), COALESCE(agent_mode, 0), COALESCE(agent_max_steps, 0),
+			COALESCE(position_sizing_mode, 'ai'), COALESCE(kelly_fraction, 0), COALESCE(regime_policies, ''), COALESCE(trading_mode, 'normal'),
+			COALESCE(approval_required, 0), COALESCE(approval_min_notional, 0), COALESCE(approval_min_leverage, 0),
+			COALESCE(approval_expiry_minutes, 15), COALESCE(approval_price_tolerance_pct, 0.5),
 			created_at, updated_at
 		FROM traders
 	`)
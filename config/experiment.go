@@ -0,0 +1,162 @@
+package config
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 实验状态
+const (
+	ExperimentStatusRunning = "running"
+	ExperimentStatusStopped = "stopped"
+)
+
+// ExperimentControlArm 对照组名称（真实下单的交易员）
+const ExperimentControlArm = "control"
+
+// ExperimentArm 实验组配置（未填写的字段沿用对照组交易员的配置）
+type ExperimentArm struct {
+	Name                 string `json:"name"`
+	AIModelID            string `json:"ai_model_id,omitempty"`            // AI模型ID（用户 ai_models 中的记录）
+	SystemPromptTemplate string `json:"system_prompt_template,omitempty"` // 系统提示词模板
+	UserPromptTemplate   string `json:"user_prompt_template,omitempty"`   // 用户提示词模板
+}
+
+// Experiment A/B 实验：对照组交易员真实下单，实验组以影子交易员模拟下单
+type Experiment struct {
+	ID              string          `json:"id"`
+	UserID          string          `json:"user_id"`
+	Name            string          `json:"name"`
+	ControlTraderID string          `json:"control_trader_id"`
+	Variants        []ExperimentArm `json:"variants"`
+	Status          string          `json:"status"`
+	CreatedAt       time.Time       `json:"created_at"`
+	StoppedAt       *time.Time      `json:"stopped_at,omitempty"`
+}
+
+// CreateExperiment 创建实验（状态为运行中）
+func (d *Database) CreateExperiment(e *Experiment) error {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	variants, err := json.Marshal(e.Variants)
+	if err != nil {
+		return fmt.Errorf("序列化实验组失败: %w", err)
+	}
+	e.Status = ExperimentStatusRunning
+	e.CreatedAt = time.Now().UTC()
+	e.StoppedAt = nil
+
+	_, err = d.db.Exec(`
+		INSERT INTO experiments (id, user_id, name, control_trader_id, variants, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, e.ID, e.UserID, e.Name, e.ControlTraderID, string(variants), e.Status, e.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("创建实验失败: %w", err)
+	}
+	return nil
+}
+
+// StopExperiment 结束实验（已结束的实验保留记录用于查看报告）
+func (d *Database) StopExperiment(userID, id string) error {
+	result, err := d.db.Exec(`
+		UPDATE experiments SET status = ?, stopped_at = ? WHERE id = ? AND user_id = ? AND status = ?
+	`, ExperimentStatusStopped, time.Now().UTC(), id, userID, ExperimentStatusRunning)
+	if err != nil {
+		return fmt.Errorf("结束实验失败: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("实验不存在或已结束")
+	}
+	return nil
+}
+
+// DeleteExperiment 删除实验记录
+func (d *Database) DeleteExperiment(userID, id string) error {
+	result, err := d.db.Exec(`DELETE FROM experiments WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return fmt.Errorf("删除实验失败: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("实验不存在")
+	}
+	return nil
+}
+
+// GetExperiment 获取用户的单个实验
+func (d *Database) GetExperiment(userID, id string) (*Experiment, error) {
+	row := d.db.QueryRow(`
+		SELECT id, user_id, name, control_trader_id, variants, status, created_at, stopped_at
+		FROM experiments WHERE id = ? AND user_id = ?
+	`, id, userID)
+	e, err := scanExperiment(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("实验不存在")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询实验失败: %w", err)
+	}
+	return e, nil
+}
+
+// GetExperiments 获取用户的所有实验（按创建时间倒序）
+func (d *Database) GetExperiments(userID string) ([]*Experiment, error) {
+	rows, err := d.db.Query(`
+		SELECT id, user_id, name, control_trader_id, variants, status, created_at, stopped_at
+		FROM experiments WHERE user_id = ? ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询实验失败: %w", err)
+	}
+	return collectExperiments(rows)
+}
+
+// GetRunningExperimentForTrader 获取交易员作为对照组正在运行的实验（没有时返回 nil）
+func (d *Database) GetRunningExperimentForTrader(userID, traderID string) (*Experiment, error) {
+	row := d.db.QueryRow(`
+		SELECT id, user_id, name, control_trader_id, variants, status, created_at, stopped_at
+		FROM experiments WHERE user_id = ? AND control_trader_id = ? AND status = ?
+		ORDER BY created_at DESC LIMIT 1
+	`, userID, traderID, ExperimentStatusRunning)
+	e, err := scanExperiment(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询实验失败: %w", err)
+	}
+	return e, nil
+}
+
+func collectExperiments(rows *sql.Rows) ([]*Experiment, error) {
+	defer rows.Close()
+	experiments := []*Experiment{}
+	for rows.Next() {
+		e, err := scanExperiment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("读取实验失败: %w", err)
+		}
+		experiments = append(experiments, e)
+	}
+	return experiments, rows.Err()
+}
+
+func scanExperiment(scanner interface{ Scan(...interface{}) error }) (*Experiment, error) {
+	var e Experiment
+	var variants string
+	var stoppedAt sql.NullTime
+	if err := scanner.Scan(&e.ID, &e.UserID, &e.Name, &e.ControlTraderID, &variants, &e.Status, &e.CreatedAt, &stoppedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(variants), &e.Variants); err != nil {
+		return nil, fmt.Errorf("解析实验组失败: %w", err)
+	}
+	if stoppedAt.Valid {
+		e.StoppedAt = &stoppedAt.Time
+	}
+	return &e, nil
+}
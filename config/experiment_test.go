@@ -0,0 +1,66 @@
+package config
+
+import "testing"
+
+// TestExperiment_Lifecycle 测试实验的创建、查询、结束与用户隔离
+func TestExperiment_Lifecycle(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	exp := &Experiment{
+		UserID:          "user-a",
+		Name:            "prompt A/B",
+		ControlTraderID: "trader-1",
+		Variants: []ExperimentArm{
+			{Name: "aggressive", SystemPromptTemplate: "aggressive"},
+			{Name: "qwen", AIModelID: "qwen"},
+		},
+	}
+	if err := db.CreateExperiment(exp); err != nil {
+		t.Fatalf("创建实验失败: %v", err)
+	}
+	if exp.ID == "" || exp.Status != ExperimentStatusRunning {
+		t.Fatalf("应生成ID并处于运行中: %+v", exp)
+	}
+
+	got, err := db.GetExperiment("user-a", exp.ID)
+	if err != nil {
+		t.Fatalf("查询实验失败: %v", err)
+	}
+	if len(got.Variants) != 2 || got.Variants[1].AIModelID != "qwen" || got.StoppedAt != nil {
+		t.Errorf("读取的实验不一致: %+v", got)
+	}
+	if _, err := db.GetExperiment("user-b", exp.ID); err == nil {
+		t.Errorf("其他用户不应读取到该实验")
+	}
+
+	running, err := db.GetRunningExperimentForTrader("user-a", "trader-1")
+	if err != nil || running == nil || running.ID != exp.ID {
+		t.Fatalf("应查到交易员进行中的实验: %v %+v", err, running)
+	}
+
+	if err := db.StopExperiment("user-b", exp.ID); err == nil {
+		t.Errorf("其他用户不应结束该实验")
+	}
+	if err := db.StopExperiment("user-a", exp.ID); err != nil {
+		t.Fatalf("结束实验失败: %v", err)
+	}
+	if err := db.StopExperiment("user-a", exp.ID); err == nil {
+		t.Errorf("重复结束应返回错误")
+	}
+	if running, _ := db.GetRunningExperimentForTrader("user-a", "trader-1"); running != nil {
+		t.Errorf("结束后不应再有进行中的实验")
+	}
+
+	list, err := db.GetExperiments("user-a")
+	if err != nil || len(list) != 1 || list[0].Status != ExperimentStatusStopped || list[0].StoppedAt == nil {
+		t.Fatalf("实验列表不正确: %v %+v", err, list)
+	}
+
+	if err := db.DeleteExperiment("user-a", exp.ID); err != nil {
+		t.Fatalf("删除实验失败: %v", err)
+	}
+	if list, _ := db.GetExperiments("user-a"); len(list) != 0 {
+		t.Errorf("删除后列表应为空")
+	}
+}
@@ -0,0 +1,291 @@
+package config
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PromptTemplateConfig 用户自建的系统提示词模板（按用户隔离，系统内置模板仍由 prompts/ 目录提供且只读）
+type PromptTemplateConfig struct {
+	ID          string    `json:"id"`
+	UserID      string    `json:"user_id"`
+	Name        string    `json:"name"`
+	Content     string    `json:"content"`
+	Description string    `json:"description"`
+	Version     string    `json:"version"`               // 当前内容的版本哈希（由调用方计算）
+	IsPublic    bool      `json:"is_public"`             // 是否共享给其他用户（其他用户只能查看和复制）
+	ForkedFrom  string    `json:"forked_from,omitempty"` // 复制来源（系统模板名称或共享模板ID）
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// PromptTemplateVersionRecord 用户模板的一个历史版本
+type PromptTemplateVersionRecord struct {
+	Template  string    `json:"template"`
+	Hash      string    `json:"hash"`
+	Author    string    `json:"author"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `json:"created_at"`
+	Content   string    `json:"content,omitempty"`
+}
+
+const promptTemplateColumns = `id, user_id, name, content, description, version, is_public, forked_from, created_at, updated_at`
+
+// CreatePromptTemplate 创建用户模板并记录为第一个版本
+func (d *Database) CreatePromptTemplate(t *PromptTemplateConfig, author, note string) error {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	now := time.Now().UTC()
+	t.CreatedAt, t.UpdatedAt = now, now
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO prompt_templates (`+promptTemplateColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, t.ID, t.UserID, t.Name, t.Content, t.Description, t.Version, t.IsPublic, t.ForkedFrom, t.CreatedAt, t.UpdatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return fmt.Errorf("模板已存在: %s", t.Name)
+		}
+		return fmt.Errorf("创建模板失败: %w", err)
+	}
+	if err := insertPromptTemplateVersion(tx, t, author, note, now); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UpdatePromptTemplate 更新用户模板内容与描述（内容变化时追加新版本，否则不重复记录）
+func (d *Database) UpdatePromptTemplate(t *PromptTemplateConfig, author, note string) error {
+	existing, err := d.GetPromptTemplate(t.UserID, t.Name)
+	if err != nil {
+		return err
+	}
+	t.ID, t.IsPublic, t.ForkedFrom, t.CreatedAt = existing.ID, existing.IsPublic, existing.ForkedFrom, existing.CreatedAt
+	t.UpdatedAt = time.Now().UTC()
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		UPDATE prompt_templates SET content = ?, description = ?, version = ?, updated_at = ?
+		WHERE id = ?
+	`, t.Content, t.Description, t.Version, t.UpdatedAt, t.ID)
+	if err != nil {
+		return fmt.Errorf("更新模板失败: %w", err)
+	}
+	if existing.Version != t.Version {
+		if err := insertPromptTemplateVersion(tx, t, author, note, t.UpdatedAt); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func insertPromptTemplateVersion(tx *sql.Tx, t *PromptTemplateConfig, author, note string, at time.Time) error {
+	_, err := tx.Exec(`
+		INSERT INTO prompt_template_versions (template_id, hash, author, note, content, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, t.ID, t.Version, author, note, t.Content, at)
+	if err != nil {
+		return fmt.Errorf("记录模板版本失败: %w", err)
+	}
+	return nil
+}
+
+// DeletePromptTemplate 删除用户模板及其版本历史
+func (d *Database) DeletePromptTemplate(userID, name string) error {
+	existing, err := d.GetPromptTemplate(userID, name)
+	if err != nil {
+		return err
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("开始事务失败: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM prompt_template_versions WHERE template_id = ?`, existing.ID); err != nil {
+		return fmt.Errorf("删除模板版本失败: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM prompt_templates WHERE id = ?`, existing.ID); err != nil {
+		return fmt.Errorf("删除模板失败: %w", err)
+	}
+	return tx.Commit()
+}
+
+// GetPromptTemplate 获取用户自己的模板
+func (d *Database) GetPromptTemplate(userID, name string) (*PromptTemplateConfig, error) {
+	row := d.db.QueryRow(`SELECT `+promptTemplateColumns+` FROM prompt_templates WHERE user_id = ? AND name = ?`, userID, name)
+	t, err := scanPromptTemplate(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("模板不存在: %s", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询模板失败: %w", err)
+	}
+	return t, nil
+}
+
+// GetPromptTemplateContent 获取用户模板内容（供决策引擎按交易员所属用户解析模板）
+func (d *Database) GetPromptTemplateContent(userID, name string) (string, error) {
+	var content string
+	err := d.db.QueryRow(`SELECT content FROM prompt_templates WHERE user_id = ? AND name = ?`, userID, name).Scan(&content)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("模板不存在: %s", name)
+	}
+	if err != nil {
+		return "", fmt.Errorf("查询模板失败: %w", err)
+	}
+	return content, nil
+}
+
+// GetPromptTemplates 获取用户自己的所有模板（按名称排序）
+func (d *Database) GetPromptTemplates(userID string) ([]*PromptTemplateConfig, error) {
+	rows, err := d.db.Query(`SELECT `+promptTemplateColumns+` FROM prompt_templates WHERE user_id = ? ORDER BY name`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询模板失败: %w", err)
+	}
+	return collectPromptTemplates(rows)
+}
+
+// GetSharedPromptTemplates 获取所有公开共享的模板（按更新时间倒序）
+func (d *Database) GetSharedPromptTemplates() ([]*PromptTemplateConfig, error) {
+	rows, err := d.db.Query(`SELECT ` + promptTemplateColumns + ` FROM prompt_templates WHERE is_public = 1 ORDER BY updated_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("查询共享模板失败: %w", err)
+	}
+	return collectPromptTemplates(rows)
+}
+
+// GetSharedPromptTemplate 按ID获取公开共享的模板
+func (d *Database) GetSharedPromptTemplate(id string) (*PromptTemplateConfig, error) {
+	row := d.db.QueryRow(`SELECT `+promptTemplateColumns+` FROM prompt_templates WHERE id = ? AND is_public = 1`, id)
+	t, err := scanPromptTemplate(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("共享模板不存在: %s", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询共享模板失败: %w", err)
+	}
+	return t, nil
+}
+
+// SetPromptTemplatePublic 设置模板是否公开共享
+func (d *Database) SetPromptTemplatePublic(userID, name string, public bool) error {
+	result, err := d.db.Exec(`
+		UPDATE prompt_templates SET is_public = ?, updated_at = ? WHERE user_id = ? AND name = ?
+	`, public, time.Now().UTC(), userID, name)
+	if err != nil {
+		return fmt.Errorf("更新模板共享状态失败: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("模板不存在: %s", name)
+	}
+	return nil
+}
+
+// GetPromptTemplateVersions 获取用户模板的版本历史（按时间从新到旧，不含内容）
+func (d *Database) GetPromptTemplateVersions(userID, name string) ([]*PromptTemplateVersionRecord, error) {
+	t, err := d.GetPromptTemplate(userID, name)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := d.db.Query(`
+		SELECT hash, author, note, created_at FROM prompt_template_versions
+		WHERE template_id = ? ORDER BY id DESC
+	`, t.ID)
+	if err != nil {
+		return nil, fmt.Errorf("查询模板版本失败: %w", err)
+	}
+	defer rows.Close()
+
+	versions := []*PromptTemplateVersionRecord{}
+	for rows.Next() {
+		v := &PromptTemplateVersionRecord{Template: name}
+		if err := rows.Scan(&v.Hash, &v.Author, &v.Note, &v.CreatedAt); err != nil {
+			return nil, fmt.Errorf("读取模板版本失败: %w", err)
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// GetPromptTemplateVersion 获取用户模板的指定版本（hash 支持前缀匹配，多次出现时取最新一条）
+func (d *Database) GetPromptTemplateVersion(userID, name, hash string) (*PromptTemplateVersionRecord, error) {
+	if hash == "" {
+		return nil, fmt.Errorf("版本号不能为空")
+	}
+	t, err := d.GetPromptTemplate(userID, name)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := d.db.Query(`
+		SELECT hash, author, note, content, created_at FROM prompt_template_versions
+		WHERE template_id = ? AND hash LIKE ? ORDER BY id DESC
+	`, t.ID, hash+"%")
+	if err != nil {
+		return nil, fmt.Errorf("查询模板版本失败: %w", err)
+	}
+	defer rows.Close()
+
+	var found *PromptTemplateVersionRecord
+	for rows.Next() {
+		v := &PromptTemplateVersionRecord{Template: name}
+		if err := rows.Scan(&v.Hash, &v.Author, &v.Note, &v.Content, &v.CreatedAt); err != nil {
+			return nil, fmt.Errorf("读取模板版本失败: %w", err)
+		}
+		if !strings.HasPrefix(v.Hash, hash) { // LIKE 通配符不算前缀
+			continue
+		}
+		if found != nil && found.Hash != v.Hash {
+			return nil, fmt.Errorf("版本号 %s 不唯一，请提供更长的前缀", hash)
+		}
+		if found == nil {
+			found = v
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("读取模板版本失败: %w", err)
+	}
+	if found == nil {
+		return nil, fmt.Errorf("模板 %s 不存在版本: %s", name, hash)
+	}
+	return found, nil
+}
+
+func collectPromptTemplates(rows *sql.Rows) ([]*PromptTemplateConfig, error) {
+	defer rows.Close()
+	templates := []*PromptTemplateConfig{}
+	for rows.Next() {
+		t, err := scanPromptTemplate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("读取模板失败: %w", err)
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+func scanPromptTemplate(scanner interface{ Scan(...interface{}) error }) (*PromptTemplateConfig, error) {
+	var t PromptTemplateConfig
+	if err := scanner.Scan(&t.ID, &t.UserID, &t.Name, &t.Content, &t.Description, &t.Version,
+		&t.IsPublic, &t.ForkedFrom, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
@@ -0,0 +1,73 @@
+package config
+
+import "testing"
+
+// TestPromptTemplate_NamespaceAndVersions 测试用户模板的隔离、共享与版本历史
+func TestPromptTemplate_NamespaceAndVersions(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	tmpl := &PromptTemplateConfig{UserID: "user-a", Name: "scalp", Content: "v1", Version: "hash1"}
+	if err := db.CreatePromptTemplate(tmpl, "a@test.com", "初版"); err != nil {
+		t.Fatalf("创建模板失败: %v", err)
+	}
+	if err := db.CreatePromptTemplate(&PromptTemplateConfig{UserID: "user-a", Name: "scalp", Content: "x", Version: "x"}, "", ""); err == nil {
+		t.Errorf("同一用户下重名应返回错误")
+	}
+	// 不同用户可以使用同名模板
+	if err := db.CreatePromptTemplate(&PromptTemplateConfig{UserID: "user-b", Name: "scalp", Content: "b", Version: "hashb"}, "", ""); err != nil {
+		t.Fatalf("其他用户创建同名模板失败: %v", err)
+	}
+	if content, err := db.GetPromptTemplateContent("user-b", "scalp"); err != nil || content != "b" {
+		t.Errorf("用户模板应相互隔离: %q %v", content, err)
+	}
+
+	// 内容不变只更新描述时不记录新版本
+	if err := db.UpdatePromptTemplate(&PromptTemplateConfig{UserID: "user-a", Name: "scalp", Content: "v1", Description: "短线", Version: "hash1"}, "a@test.com", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.UpdatePromptTemplate(&PromptTemplateConfig{UserID: "user-a", Name: "scalp", Content: "v2", Version: "hash2"}, "a@test.com", "收紧止损"); err != nil {
+		t.Fatal(err)
+	}
+	versions, err := db.GetPromptTemplateVersions("user-a", "scalp")
+	if err != nil || len(versions) != 2 || versions[0].Hash != "hash2" || versions[0].Content != "" {
+		t.Fatalf("版本历史不正确: %v %+v", err, versions)
+	}
+	if v, err := db.GetPromptTemplateVersion("user-a", "scalp", "hash1"); err != nil || v.Content != "v1" {
+		t.Errorf("读取历史版本失败: %v %+v", err, v)
+	}
+	if _, err := db.GetPromptTemplateVersion("user-a", "scalp", "hash"); err == nil {
+		t.Errorf("前缀不唯一时应返回错误")
+	}
+	if _, err := db.GetPromptTemplateVersions("user-b", "missing"); err == nil {
+		t.Errorf("不存在的模板应返回错误")
+	}
+
+	// 共享
+	if shared, _ := db.GetSharedPromptTemplates(); len(shared) != 0 {
+		t.Errorf("默认不应共享")
+	}
+	if err := db.SetPromptTemplatePublic("user-b", "missing", true); err == nil {
+		t.Errorf("不存在的模板不能共享")
+	}
+	if err := db.SetPromptTemplatePublic("user-a", "scalp", true); err != nil {
+		t.Fatal(err)
+	}
+	shared, err := db.GetSharedPromptTemplates()
+	if err != nil || len(shared) != 1 || shared[0].UserID != "user-a" || shared[0].Content != "v2" {
+		t.Fatalf("共享列表不正确: %v %+v", err, shared)
+	}
+	if _, err := db.GetSharedPromptTemplate(shared[0].ID); err != nil {
+		t.Errorf("按ID读取共享模板失败: %v", err)
+	}
+
+	if err := db.DeletePromptTemplate("user-a", "scalp"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.GetSharedPromptTemplate(shared[0].ID); err == nil {
+		t.Errorf("删除后共享模板应不可见")
+	}
+	if list, _ := db.GetPromptTemplates("user-b"); len(list) != 1 {
+		t.Errorf("删除不应影响其他用户的模板")
+	}
+}
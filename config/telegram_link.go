@@ -0,0 +1,148 @@
+package config
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// telegramLinkCodeTTL 绑定码有效期
+const telegramLinkCodeTTL = 10 * time.Minute
+
+// telegramLinkCodeAlphabet 绑定码字符集（去掉易混淆的 0/O/1/I）
+const telegramLinkCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// TelegramLink Telegram 聊天与 nofx 用户的绑定关系
+type TelegramLink struct {
+	ChatID    int64     `json:"chat_id"`
+	UserID    string    `json:"user_id"`
+	Username  string    `json:"username"` // Telegram 用户名（仅展示）
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateTelegramLinkCode 为用户生成一次性绑定码（在 Telegram 中发送 /link <code> 完成绑定）
+func (d *Database) CreateTelegramLinkCode(userID string) (string, time.Time, error) {
+	code, err := randomLinkCode(8)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("生成绑定码失败: %w", err)
+	}
+	expiresAt := time.Now().UTC().Add(telegramLinkCodeTTL)
+
+	// 清理过期绑定码
+	if _, err := d.db.Exec(`DELETE FROM telegram_link_codes WHERE expires_at < ?`, time.Now().UTC()); err != nil {
+		return "", time.Time{}, fmt.Errorf("清理绑定码失败: %w", err)
+	}
+	if _, err := d.db.Exec(`INSERT INTO telegram_link_codes (code, user_id, expires_at) VALUES (?, ?, ?)`,
+		code, userID, expiresAt); err != nil {
+		return "", time.Time{}, fmt.Errorf("保存绑定码失败: %w", err)
+	}
+	return code, expiresAt, nil
+}
+
+// LinkTelegramChat 使用绑定码绑定聊天，返回绑定的用户ID（绑定码使用后立即失效）
+func (d *Database) LinkTelegramChat(code string, chatID int64, username string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var userID string
+	var expiresAt time.Time
+	err = tx.QueryRow(`SELECT user_id, expires_at FROM telegram_link_codes WHERE code = ?`, code).Scan(&userID, &expiresAt)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("绑定码无效")
+	}
+	if err != nil {
+		return "", fmt.Errorf("查询绑定码失败: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM telegram_link_codes WHERE code = ?`, code); err != nil {
+		return "", fmt.Errorf("删除绑定码失败: %w", err)
+	}
+	if time.Now().UTC().After(expiresAt) {
+		tx.Commit()
+		return "", fmt.Errorf("绑定码已过期")
+	}
+
+	if _, err := tx.Exec(`
+		INSERT INTO telegram_links (chat_id, user_id, username, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(chat_id) DO UPDATE SET user_id = excluded.user_id, username = excluded.username, created_at = excluded.created_at
+	`, chatID, userID, username, time.Now().UTC()); err != nil {
+		return "", fmt.Errorf("保存绑定失败: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return userID, nil
+}
+
+// GetTelegramChatUser 获取聊天绑定的用户ID（未绑定时返回空字符串）
+func (d *Database) GetTelegramChatUser(chatID int64) (string, error) {
+	var userID string
+	err := d.db.QueryRow(`SELECT user_id FROM telegram_links WHERE chat_id = ?`, chatID).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("查询Telegram绑定失败: %w", err)
+	}
+	return userID, nil
+}
+
+// GetTelegramLinks 获取用户绑定的所有聊天
+func (d *Database) GetTelegramLinks(userID string) ([]*TelegramLink, error) {
+	rows, err := d.db.Query(`SELECT chat_id, user_id, username, created_at FROM telegram_links WHERE user_id = ? ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询Telegram绑定失败: %w", err)
+	}
+	defer rows.Close()
+
+	links := []*TelegramLink{}
+	for rows.Next() {
+		var link TelegramLink
+		if err := rows.Scan(&link.ChatID, &link.UserID, &link.Username, &link.CreatedAt); err != nil {
+			return nil, fmt.Errorf("读取Telegram绑定失败: %w", err)
+		}
+		links = append(links, &link)
+	}
+	return links, rows.Err()
+}
+
+// UnlinkTelegramChat 解除聊天绑定（userID 为空时不校验归属，供机器人 /unlink 使用）
+func (d *Database) UnlinkTelegramChat(userID string, chatID int64) error {
+	query := `DELETE FROM telegram_links WHERE chat_id = ?`
+	args := []interface{}{chatID}
+	if userID != "" {
+		query += ` AND user_id = ?`
+		args = append(args, userID)
+	}
+
+	result, err := d.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("解除Telegram绑定失败: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("绑定不存在")
+	}
+	return nil
+}
+
+// randomLinkCode 生成随机绑定码
+func randomLinkCode(length int) (string, error) {
+	max := big.NewInt(int64(len(telegramLinkCodeAlphabet)))
+	var builder strings.Builder
+	for i := 0; i < length; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		builder.WriteByte(telegramLinkCodeAlphabet[n.Int64()])
+	}
+	return builder.String(), nil
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+// TestTelegramLink_Lifecycle 测试绑定码生成、一次性使用、查询与解绑
+func TestTelegramLink_Lifecycle(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	code, expiresAt, err := db.CreateTelegramLinkCode("test-user-001")
+	if err != nil {
+		t.Fatalf("生成绑定码失败: %v", err)
+	}
+	if len(code) != 8 || !expiresAt.After(time.Now()) {
+		t.Fatalf("绑定码格式或有效期异常: %s %v", code, expiresAt)
+	}
+
+	if _, err := db.LinkTelegramChat("WRONG123", 42, "alice"); err == nil {
+		t.Errorf("无效绑定码应失败")
+	}
+
+	userID, err := db.LinkTelegramChat(code, 42, "alice")
+	if err != nil {
+		t.Fatalf("绑定失败: %v", err)
+	}
+	if userID != "test-user-001" {
+		t.Errorf("绑定用户错误: %s", userID)
+	}
+	if _, err := db.LinkTelegramChat(code, 43, "bob"); err == nil {
+		t.Errorf("绑定码只能使用一次")
+	}
+
+	linked, err := db.GetTelegramChatUser(42)
+	if err != nil || linked != "test-user-001" {
+		t.Fatalf("查询绑定失败: %s %v", linked, err)
+	}
+	unlinked, err := db.GetTelegramChatUser(43)
+	if err != nil || unlinked != "" {
+		t.Errorf("未绑定的聊天应返回空用户: %s %v", unlinked, err)
+	}
+
+	links, err := db.GetTelegramLinks("test-user-001")
+	if err != nil || len(links) != 1 || links[0].Username != "alice" {
+		t.Fatalf("查询用户绑定失败: %+v %v", links, err)
+	}
+
+	if err := db.UnlinkTelegramChat("test-user-002", 42); err == nil {
+		t.Errorf("不能解除其他用户的绑定")
+	}
+	if err := db.UnlinkTelegramChat("test-user-001", 42); err != nil {
+		t.Fatalf("解除绑定失败: %v", err)
+	}
+	if linked, _ := db.GetTelegramChatUser(42); linked != "" {
+		t.Errorf("解绑后不应再查到用户")
+	}
+}
+
+// TestTelegramLink_ExpiredCode 测试过期绑定码
+func TestTelegramLink_ExpiredCode(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	if _, err := db.db.Exec(`INSERT INTO telegram_link_codes (code, user_id, expires_at) VALUES (?, ?, ?)`,
+		"EXPIRED1", "test-user-001", time.Now().UTC().Add(-time.Minute)); err != nil {
+		t.Fatalf("插入绑定码失败: %v", err)
+	}
+	if _, err := db.LinkTelegramChat("expired1", 42, ""); err == nil {
+		t.Errorf("过期绑定码应失败")
+	}
+}
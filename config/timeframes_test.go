@@ -361,6 +361,20 @@ func setupTestDBForTimeframes(t *testing.T) (*Database, func()) {
 			limit_price_offset REAL DEFAULT -0.03,
 			limit_timeout_seconds INTEGER DEFAULT 60,
 			timeframes TEXT DEFAULT '4h',
+			indicators TEXT DEFAULT '',
+			user_prompt_template TEXT DEFAULT '',
+			prompt_token_budget INTEGER DEFAULT 0,
+			agent_mode BOOLEAN DEFAULT 0,
+			agent_max_steps INTEGER DEFAULT 0,
+			position_sizing_mode TEXT DEFAULT 'ai',
+			kelly_fraction REAL DEFAULT 0,
+			regime_policies TEXT DEFAULT '',
+			trading_mode TEXT DEFAULT 'normal',
+			approval_required BOOLEAN DEFAULT 0,
+			approval_min_notional REAL DEFAULT 0,
+			approval_min_leverage INTEGER DEFAULT 0,
+			approval_expiry_minutes INTEGER DEFAULT 15,
+			approval_price_tolerance_pct REAL DEFAULT 0.5,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
@@ -373,6 +387,10 @@ func setupTestDBForTimeframes(t *testing.T) (*Database, func()) {
 		       custom_prompt, override_base_prompt, system_prompt_template,
 		       is_cross_margin, taker_fee_rate, maker_fee_rate, order_strategy,
 		       limit_price_offset, limit_timeout_seconds, timeframes,
+		       COALESCE(indicators, ''), COALESCE(user_prompt_template, ''), COALESCE(prompt_token_budget, 0), COALESCE(agent_mode, 0), COALESCE(agent_max_steps, 0),
+		       COALESCE(position_sizing_mode, 'ai'), COALESCE(kelly_fraction, 0), COALESCE(regime_policies, ''), COALESCE(trading_mode, 'normal'),
+		       COALESCE(approval_required, 0), COALESCE(approval_min_notional, 0), COALESCE(approval_min_leverage, 0),
+		       COALESCE(approval_expiry_minutes, 15), COALESCE(approval_price_tolerance_pct, 0.5),
 		       COALESCE(created_at, CURRENT_TIMESTAMP), COALESCE(updated_at, CURRENT_TIMESTAMP)
 		FROM traders;
 		DROP TABLE traders;
@@ -437,3 +455,50 @@ func setupAIModelAndExchange(t *testing.T, db *Database, userID string) (int, in
 
 	return aiModelID, exchangeID
 }
+
+// TestTraderTradingMode_Persist 测试运行模式的默认值与持久化
+func TestTraderTradingMode_Persist(t *testing.T) {
+	db, cleanup := setupTestDBForTimeframes(t)
+	defer cleanup()
+
+	userID := "test-user-tf-002"
+	aiModelID, exchangeID := setupAIModelAndExchange(t, db, userID)
+
+	trader := &TraderRecord{
+		ID:                  "trader-mode",
+		UserID:              userID,
+		Name:                "Mode Trader",
+		AIModelID:           aiModelID,
+		ExchangeID:          exchangeID,
+		InitialBalance:      1000.0,
+		ScanIntervalMinutes: 3,
+		Timeframes:          "4h",
+	}
+	if err := db.CreateTrader(trader); err != nil {
+		t.Fatalf("创建失败: %v", err)
+	}
+
+	traders, err := db.GetTraders(userID)
+	if err != nil || len(traders) != 1 {
+		t.Fatalf("获取 traders 失败: %v", err)
+	}
+	if traders[0].TradingMode != "normal" {
+		t.Errorf("默认运行模式应为 normal，实际 '%s'", traders[0].TradingMode)
+	}
+
+	if err := db.UpdateTraderTradingMode(userID, trader.ID, "reduce_only"); err != nil {
+		t.Fatalf("更新运行模式失败: %v", err)
+	}
+	// 其他用户无法修改
+	if err := db.UpdateTraderTradingMode("test-user-tf-003", trader.ID, "paused"); err != nil {
+		t.Fatalf("更新运行模式失败: %v", err)
+	}
+
+	record, _, _, err := db.GetTraderConfig(userID, trader.ID)
+	if err != nil {
+		t.Fatalf("获取交易员配置失败: %v", err)
+	}
+	if record.TradingMode != "reduce_only" {
+		t.Errorf("运行模式应为 reduce_only，实际 '%s'", record.TradingMode)
+	}
+}
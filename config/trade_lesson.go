@@ -0,0 +1,182 @@
+package config
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TradeLesson 交易复盘经验（平仓后由AI总结的一条教训，用户可查看、修改和删除）
+type TradeLesson struct {
+	ID        int64     `json:"id"`
+	UserID    string    `json:"user_id"`
+	TraderID  string    `json:"trader_id"`
+	TradeKey  string    `json:"trade_key"` // 对应的已平仓交易（同一笔交易只复盘一次）
+	Symbol    string    `json:"symbol"`
+	Side      string    `json:"side"`
+	Regime    string    `json:"regime"` // 复盘时的市场状态（趋势/波动）
+	Setup     string    `json:"setup"`  // 入场形态/理由
+	Lesson    string    `json:"lesson"`
+	Keywords  []string  `json:"keywords"`
+	PnL       float64   `json:"pnl"`
+	PnLPct    float64   `json:"pnl_pct"`
+	ClosedAt  time.Time `json:"closed_at"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TradeLessonFilter 复盘经验查询条件（空字段不过滤）
+type TradeLessonFilter struct {
+	TraderID string
+	Symbol   string
+	Limit    int
+}
+
+const tradeLessonColumns = `id, user_id, trader_id, trade_key, symbol, side, regime, setup, lesson, keywords, pnl, pnl_pct, closed_at, created_at, updated_at`
+
+// CreateTradeLesson 保存一条复盘经验（同一交易员的同一笔交易只保存一次）
+func (d *Database) CreateTradeLesson(l *TradeLesson) error {
+	keywords, err := json.Marshal(normalizeLessonKeywords(l.Keywords))
+	if err != nil {
+		return fmt.Errorf("序列化关键词失败: %w", err)
+	}
+	now := time.Now().UTC()
+	l.CreatedAt, l.UpdatedAt = now, now
+
+	result, err := d.db.Exec(`
+		INSERT INTO trade_lessons (user_id, trader_id, trade_key, symbol, side, regime, setup, lesson, keywords, pnl, pnl_pct, closed_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, l.UserID, l.TraderID, l.TradeKey, l.Symbol, l.Side, l.Regime, l.Setup, l.Lesson, string(keywords), l.PnL, l.PnLPct, l.ClosedAt.UTC(), now, now)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return fmt.Errorf("该交易已复盘: %s", l.TradeKey)
+		}
+		return fmt.Errorf("保存复盘经验失败: %w", err)
+	}
+	l.ID, _ = result.LastInsertId()
+	return nil
+}
+
+// TradeLessonExists 检查交易是否已复盘
+func (d *Database) TradeLessonExists(traderID, tradeKey string) (bool, error) {
+	var count int
+	err := d.db.QueryRow(`SELECT COUNT(*) FROM trade_lessons WHERE trader_id = ? AND trade_key = ?`, traderID, tradeKey).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("查询复盘经验失败: %w", err)
+	}
+	return count > 0, nil
+}
+
+// GetTradeLessons 获取用户的复盘经验（按时间从新到旧）
+func (d *Database) GetTradeLessons(userID string, filter TradeLessonFilter) ([]*TradeLesson, error) {
+	query := `SELECT ` + tradeLessonColumns + ` FROM trade_lessons WHERE user_id = ?`
+	args := []interface{}{userID}
+	if filter.TraderID != "" {
+		query += ` AND trader_id = ?`
+		args = append(args, filter.TraderID)
+	}
+	if filter.Symbol != "" {
+		query += ` AND symbol = ?`
+		args = append(args, filter.Symbol)
+	}
+	query += ` ORDER BY id DESC`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询复盘经验失败: %w", err)
+	}
+	defer rows.Close()
+
+	lessons := []*TradeLesson{}
+	for rows.Next() {
+		l, err := scanTradeLesson(rows)
+		if err != nil {
+			return nil, fmt.Errorf("读取复盘经验失败: %w", err)
+		}
+		lessons = append(lessons, l)
+	}
+	return lessons, rows.Err()
+}
+
+// GetTradeLesson 获取用户的一条复盘经验
+func (d *Database) GetTradeLesson(userID string, id int64) (*TradeLesson, error) {
+	row := d.db.QueryRow(`SELECT `+tradeLessonColumns+` FROM trade_lessons WHERE id = ? AND user_id = ?`, id, userID)
+	l, err := scanTradeLesson(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("复盘经验不存在: %d", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询复盘经验失败: %w", err)
+	}
+	return l, nil
+}
+
+// UpdateTradeLesson 修改复盘经验的形态、教训、市场状态与关键词
+func (d *Database) UpdateTradeLesson(l *TradeLesson) error {
+	keywords, err := json.Marshal(normalizeLessonKeywords(l.Keywords))
+	if err != nil {
+		return fmt.Errorf("序列化关键词失败: %w", err)
+	}
+	l.UpdatedAt = time.Now().UTC()
+	result, err := d.db.Exec(`
+		UPDATE trade_lessons SET regime = ?, setup = ?, lesson = ?, keywords = ?, updated_at = ?
+		WHERE id = ? AND user_id = ?
+	`, l.Regime, l.Setup, l.Lesson, string(keywords), l.UpdatedAt, l.ID, l.UserID)
+	if err != nil {
+		return fmt.Errorf("更新复盘经验失败: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("复盘经验不存在: %d", l.ID)
+	}
+	return nil
+}
+
+// DeleteTradeLesson 删除用户的一条复盘经验
+func (d *Database) DeleteTradeLesson(userID string, id int64) error {
+	result, err := d.db.Exec(`DELETE FROM trade_lessons WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return fmt.Errorf("删除复盘经验失败: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("复盘经验不存在: %d", id)
+	}
+	return nil
+}
+
+// normalizeLessonKeywords 关键词去空白、去重（保持顺序）
+func normalizeLessonKeywords(keywords []string) []string {
+	seen := make(map[string]bool, len(keywords))
+	result := make([]string, 0, len(keywords))
+	for _, kw := range keywords {
+		kw = strings.TrimSpace(kw)
+		if kw == "" || seen[strings.ToLower(kw)] {
+			continue
+		}
+		seen[strings.ToLower(kw)] = true
+		result = append(result, kw)
+	}
+	return result
+}
+
+func scanTradeLesson(scanner interface{ Scan(...interface{}) error }) (*TradeLesson, error) {
+	var l TradeLesson
+	var keywords string
+	var closedAt sql.NullTime
+	if err := scanner.Scan(&l.ID, &l.UserID, &l.TraderID, &l.TradeKey, &l.Symbol, &l.Side, &l.Regime, &l.Setup,
+		&l.Lesson, &keywords, &l.PnL, &l.PnLPct, &closedAt, &l.CreatedAt, &l.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if closedAt.Valid {
+		l.ClosedAt = closedAt.Time
+	}
+	if err := json.Unmarshal([]byte(keywords), &l.Keywords); err != nil || l.Keywords == nil {
+		l.Keywords = []string{}
+	}
+	return &l, nil
+}
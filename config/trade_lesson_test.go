@@ -0,0 +1,86 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+// TestTradeLesson_Lifecycle 测试复盘经验的保存、去重、查询、修改、删除与用户隔离
+func TestTradeLesson_Lifecycle(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	lesson := &TradeLesson{
+		UserID:   "user-a",
+		TraderID: "trader-1",
+		TradeKey: "BTCUSDT_long_1700000000000",
+		Symbol:   "BTCUSDT",
+		Side:     "long",
+		Regime:   "bullish/high",
+		Setup:    "突破追高",
+		Lesson:   "高波动下突破追高容易被扫止损，应等回踩确认",
+		Keywords: []string{"突破", " 追高 ", "突破", ""},
+		PnL:      -12.5,
+		PnLPct:   -8.2,
+		ClosedAt: time.Now(),
+	}
+	if err := db.CreateTradeLesson(lesson); err != nil {
+		t.Fatalf("保存复盘经验失败: %v", err)
+	}
+	if lesson.ID == 0 {
+		t.Fatalf("应返回自增ID")
+	}
+	duplicate := *lesson
+	if err := db.CreateTradeLesson(&duplicate); err == nil {
+		t.Errorf("同一笔交易不应重复保存")
+	}
+	if exists, err := db.TradeLessonExists("trader-1", lesson.TradeKey); err != nil || !exists {
+		t.Errorf("应能查到已复盘的交易: %v %v", exists, err)
+	}
+
+	other := &TradeLesson{UserID: "user-a", TraderID: "trader-2", TradeKey: "ETHUSDT_short_1", Symbol: "ETHUSDT", Lesson: "顺势做空", ClosedAt: time.Now()}
+	if err := db.CreateTradeLesson(other); err != nil {
+		t.Fatalf("保存复盘经验失败: %v", err)
+	}
+
+	all, err := db.GetTradeLessons("user-a", TradeLessonFilter{})
+	if err != nil || len(all) != 2 || all[0].ID != other.ID {
+		t.Fatalf("复盘经验列表不正确（应按时间倒序）: %v %+v", err, all)
+	}
+	byTrader, _ := db.GetTradeLessons("user-a", TradeLessonFilter{TraderID: "trader-1"})
+	if len(byTrader) != 1 || len(byTrader[0].Keywords) != 2 || byTrader[0].Keywords[1] != "追高" {
+		t.Errorf("按交易员筛选或关键词规范化不正确: %+v", byTrader)
+	}
+	if bySymbol, _ := db.GetTradeLessons("user-a", TradeLessonFilter{Symbol: "ETHUSDT"}); len(bySymbol) != 1 {
+		t.Errorf("按币种筛选不正确: %+v", bySymbol)
+	}
+	if others, _ := db.GetTradeLessons("user-b", TradeLessonFilter{}); len(others) != 0 {
+		t.Errorf("其他用户不应看到复盘经验")
+	}
+
+	lesson.UserID = "user-b"
+	lesson.Lesson = "篡改"
+	if err := db.UpdateTradeLesson(lesson); err == nil {
+		t.Errorf("其他用户不应修改复盘经验")
+	}
+	lesson.UserID = "user-a"
+	lesson.Lesson = "等回踩确认再入场"
+	lesson.Keywords = []string{"回踩"}
+	if err := db.UpdateTradeLesson(lesson); err != nil {
+		t.Fatalf("修改复盘经验失败: %v", err)
+	}
+	got, err := db.GetTradeLesson("user-a", lesson.ID)
+	if err != nil || got.Lesson != "等回踩确认再入场" || len(got.Keywords) != 1 || got.Setup != "突破追高" {
+		t.Errorf("修改后的复盘经验不一致: %v %+v", err, got)
+	}
+
+	if err := db.DeleteTradeLesson("user-b", lesson.ID); err == nil {
+		t.Errorf("其他用户不应删除复盘经验")
+	}
+	if err := db.DeleteTradeLesson("user-a", lesson.ID); err != nil {
+		t.Fatalf("删除复盘经验失败: %v", err)
+	}
+	if _, err := db.GetTradeLesson("user-a", lesson.ID); err == nil {
+		t.Errorf("删除后不应再查到")
+	}
+}
@@ -0,0 +1,259 @@
+package config
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WebhookSubscription 用户的 Webhook 订阅（推送交易事件到外部系统）
+type WebhookSubscription struct {
+	ID         string    `json:"id"`
+	UserID     string    `json:"user_id"`
+	Name       string    `json:"name"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"-"`           // HMAC 签名密钥（加密存储，仅创建时返回一次）
+	EventTypes []string  `json:"event_types"` // 订阅的事件类型，为空表示全部
+	Enabled    bool      `json:"enabled"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Accepts 判断订阅是否接收指定类型的事件
+func (w *WebhookSubscription) Accepts(eventType string) bool {
+	if !w.Enabled {
+		return false
+	}
+	if len(w.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range w.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDeadLetter 重试耗尽后仍投递失败的 Webhook 消息
+type WebhookDeadLetter struct {
+	ID             int64     `json:"id"`
+	SubscriptionID string    `json:"subscription_id"`
+	UserID         string    `json:"user_id"`
+	EventType      string    `json:"event_type"`
+	Payload        string    `json:"payload"`     // 原始 JSON 请求体
+	Attempts       int       `json:"attempts"`    // 已尝试次数
+	LastStatus     int       `json:"last_status"` // 最后一次 HTTP 状态码（0 表示网络错误）
+	LastError      string    `json:"last_error"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// GenerateWebhookSecret 生成随机签名密钥
+func GenerateWebhookSecret() (string, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(buf), nil
+}
+
+// CreateWebhook 创建 Webhook 订阅（未指定 Secret 时自动生成）
+func (d *Database) CreateWebhook(w *WebhookSubscription) error {
+	if w.ID == "" {
+		w.ID = uuid.New().String()
+	}
+	if w.Secret == "" {
+		secret, err := GenerateWebhookSecret()
+		if err != nil {
+			return fmt.Errorf("生成签名密钥失败: %w", err)
+		}
+		w.Secret = secret
+	}
+	now := time.Now().UTC()
+	w.CreatedAt, w.UpdatedAt = now, now
+
+	_, err := d.db.Exec(`
+		INSERT INTO webhook_subscriptions (id, user_id, name, url, secret, event_types, enabled, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, w.ID, w.UserID, w.Name, w.URL, d.encryptSensitiveData(w.Secret), strings.Join(w.EventTypes, ","), w.Enabled, now, now)
+	if err != nil {
+		return fmt.Errorf("创建Webhook失败: %w", err)
+	}
+	return nil
+}
+
+// UpdateWebhook 更新 Webhook 订阅（Secret 为空时保留原密钥）
+func (d *Database) UpdateWebhook(w *WebhookSubscription) error {
+	w.UpdatedAt = time.Now().UTC()
+	query := `UPDATE webhook_subscriptions SET name = ?, url = ?, event_types = ?, enabled = ?, updated_at = ?`
+	args := []interface{}{w.Name, w.URL, strings.Join(w.EventTypes, ","), w.Enabled, w.UpdatedAt}
+	if w.Secret != "" {
+		query += `, secret = ?`
+		args = append(args, d.encryptSensitiveData(w.Secret))
+	}
+	query += ` WHERE id = ? AND user_id = ?`
+	args = append(args, w.ID, w.UserID)
+
+	result, err := d.db.Exec(query, args...)
+	if err != nil {
+		return fmt.Errorf("更新Webhook失败: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("Webhook不存在")
+	}
+	return nil
+}
+
+// DeleteWebhook 删除 Webhook 订阅及其死信
+func (d *Database) DeleteWebhook(userID, id string) error {
+	result, err := d.db.Exec(`DELETE FROM webhook_subscriptions WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return fmt.Errorf("删除Webhook失败: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("Webhook不存在")
+	}
+	if _, err := d.db.Exec(`DELETE FROM webhook_dead_letters WHERE subscription_id = ?`, id); err != nil {
+		return fmt.Errorf("删除Webhook死信失败: %w", err)
+	}
+	return nil
+}
+
+// GetWebhook 获取用户的单个 Webhook 订阅（含解密后的密钥）
+func (d *Database) GetWebhook(userID, id string) (*WebhookSubscription, error) {
+	row := d.db.QueryRow(`
+		SELECT id, user_id, name, url, secret, event_types, enabled, created_at, updated_at
+		FROM webhook_subscriptions WHERE id = ? AND user_id = ?
+	`, id, userID)
+	w, err := d.scanWebhook(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("Webhook不存在")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询Webhook失败: %w", err)
+	}
+	return w, nil
+}
+
+// GetWebhooks 获取用户的所有 Webhook 订阅（含解密后的密钥）
+func (d *Database) GetWebhooks(userID string) ([]*WebhookSubscription, error) {
+	rows, err := d.db.Query(`
+		SELECT id, user_id, name, url, secret, event_types, enabled, created_at, updated_at
+		FROM webhook_subscriptions WHERE user_id = ? ORDER BY created_at
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("查询Webhook失败: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := []*WebhookSubscription{}
+	for rows.Next() {
+		w, err := d.scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("读取Webhook失败: %w", err)
+		}
+		webhooks = append(webhooks, w)
+	}
+	return webhooks, rows.Err()
+}
+
+func (d *Database) scanWebhook(scanner interface{ Scan(...interface{}) error }) (*WebhookSubscription, error) {
+	var w WebhookSubscription
+	var eventTypes string
+	if err := scanner.Scan(&w.ID, &w.UserID, &w.Name, &w.URL, &w.Secret, &eventTypes, &w.Enabled, &w.CreatedAt, &w.UpdatedAt); err != nil {
+		return nil, err
+	}
+	w.Secret = d.decryptSensitiveData(w.Secret)
+	w.EventTypes = []string{}
+	for _, t := range strings.Split(eventTypes, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			w.EventTypes = append(w.EventTypes, t)
+		}
+	}
+	return &w, nil
+}
+
+// AddWebhookDeadLetter 记录投递失败的 Webhook 消息
+func (d *Database) AddWebhookDeadLetter(dl *WebhookDeadLetter) error {
+	if dl.CreatedAt.IsZero() {
+		dl.CreatedAt = time.Now().UTC()
+	}
+	result, err := d.db.Exec(`
+		INSERT INTO webhook_dead_letters (subscription_id, user_id, event_type, payload, attempts, last_status, last_error, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, dl.SubscriptionID, dl.UserID, dl.EventType, dl.Payload, dl.Attempts, dl.LastStatus, dl.LastError, dl.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("写入Webhook死信失败: %w", err)
+	}
+	if id, err := result.LastInsertId(); err == nil {
+		dl.ID = id
+	}
+	return nil
+}
+
+// GetWebhookDeadLetters 获取用户的死信（按时间倒序，subscriptionID 为空表示全部订阅）
+func (d *Database) GetWebhookDeadLetters(userID, subscriptionID string, limit int) ([]*WebhookDeadLetter, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	query := `SELECT id, subscription_id, user_id, event_type, payload, attempts, last_status, last_error, created_at
+		FROM webhook_dead_letters WHERE user_id = ?`
+	args := []interface{}{userID}
+	if subscriptionID != "" {
+		query += ` AND subscription_id = ?`
+		args = append(args, subscriptionID)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询Webhook死信失败: %w", err)
+	}
+	defer rows.Close()
+
+	letters := []*WebhookDeadLetter{}
+	for rows.Next() {
+		var dl WebhookDeadLetter
+		if err := rows.Scan(&dl.ID, &dl.SubscriptionID, &dl.UserID, &dl.EventType, &dl.Payload,
+			&dl.Attempts, &dl.LastStatus, &dl.LastError, &dl.CreatedAt); err != nil {
+			return nil, fmt.Errorf("读取Webhook死信失败: %w", err)
+		}
+		letters = append(letters, &dl)
+	}
+	return letters, rows.Err()
+}
+
+// GetWebhookDeadLetter 获取用户的单条死信
+func (d *Database) GetWebhookDeadLetter(userID string, id int64) (*WebhookDeadLetter, error) {
+	var dl WebhookDeadLetter
+	err := d.db.QueryRow(`
+		SELECT id, subscription_id, user_id, event_type, payload, attempts, last_status, last_error, created_at
+		FROM webhook_dead_letters WHERE id = ? AND user_id = ?
+	`, id, userID).Scan(&dl.ID, &dl.SubscriptionID, &dl.UserID, &dl.EventType, &dl.Payload,
+		&dl.Attempts, &dl.LastStatus, &dl.LastError, &dl.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("死信不存在")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询Webhook死信失败: %w", err)
+	}
+	return &dl, nil
+}
+
+// DeleteWebhookDeadLetter 删除死信（重新投递成功或用户手动清理）
+func (d *Database) DeleteWebhookDeadLetter(userID string, id int64) error {
+	result, err := d.db.Exec(`DELETE FROM webhook_dead_letters WHERE id = ? AND user_id = ?`, id, userID)
+	if err != nil {
+		return fmt.Errorf("删除Webhook死信失败: %w", err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return fmt.Errorf("死信不存在")
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"testing"
+)
+
+// TestWebhook_Lifecycle 测试 Webhook 订阅增删改查与用户隔离
+func TestWebhook_Lifecycle(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	w := &WebhookSubscription{
+		UserID:     "user-a",
+		Name:       "slack",
+		URL:        "https://hooks.example.com/a",
+		EventTypes: []string{"trade_opened", "stop_hit"},
+		Enabled:    true,
+	}
+	if err := db.CreateWebhook(w); err != nil {
+		t.Fatalf("创建Webhook失败: %v", err)
+	}
+	if w.ID == "" || len(w.Secret) < 16 {
+		t.Fatalf("应自动生成ID和密钥: %+v", w)
+	}
+
+	got, err := db.GetWebhook("user-a", w.ID)
+	if err != nil {
+		t.Fatalf("查询Webhook失败: %v", err)
+	}
+	if got.Secret != w.Secret || len(got.EventTypes) != 2 || !got.Accepts("stop_hit") || got.Accepts("trader_error") {
+		t.Errorf("读取的Webhook不一致: %+v", got)
+	}
+	if _, err := db.GetWebhook("user-b", w.ID); err == nil {
+		t.Errorf("其他用户不应读取到该Webhook")
+	}
+
+	got.Enabled = false
+	got.EventTypes = nil
+	got.Secret = ""
+	if err := db.UpdateWebhook(got); err != nil {
+		t.Fatalf("更新Webhook失败: %v", err)
+	}
+	list, err := db.GetWebhooks("user-a")
+	if err != nil || len(list) != 1 {
+		t.Fatalf("查询Webhook列表失败: %v %d", err, len(list))
+	}
+	if list[0].Enabled || len(list[0].EventTypes) != 0 || list[0].Secret != w.Secret {
+		t.Errorf("更新后应禁用、订阅全部事件并保留原密钥: %+v", list[0])
+	}
+
+	if err := db.DeleteWebhook("user-b", w.ID); err == nil {
+		t.Errorf("其他用户不应删除该Webhook")
+	}
+	if err := db.DeleteWebhook("user-a", w.ID); err != nil {
+		t.Fatalf("删除Webhook失败: %v", err)
+	}
+}
+
+// TestWebhook_DeadLetters 测试死信写入、查询与删除
+func TestWebhook_DeadLetters(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	for i, sub := range []string{"w1", "w1", "w2"} {
+		if err := db.AddWebhookDeadLetter(&WebhookDeadLetter{
+			SubscriptionID: sub,
+			UserID:         "user-a",
+			EventType:      "trade_closed",
+			Payload:        `{"id":"x"}`,
+			Attempts:       i + 1,
+			LastStatus:     500,
+			LastError:      "HTTP 500",
+		}); err != nil {
+			t.Fatalf("写入死信失败: %v", err)
+		}
+	}
+
+	all, err := db.GetWebhookDeadLetters("user-a", "", 0)
+	if err != nil || len(all) != 3 {
+		t.Fatalf("查询死信失败: %v %d", err, len(all))
+	}
+	if all[0].Attempts != 3 {
+		t.Errorf("死信应按时间倒序: %+v", all[0])
+	}
+	w1, _ := db.GetWebhookDeadLetters("user-a", "w1", 0)
+	if len(w1) != 2 {
+		t.Errorf("按订阅过滤失败: %d", len(w1))
+	}
+	if others, _ := db.GetWebhookDeadLetters("user-b", "", 0); len(others) != 0 {
+		t.Errorf("其他用户不应看到死信")
+	}
+
+	if err := db.DeleteWebhookDeadLetter("user-b", all[0].ID); err == nil {
+		t.Errorf("其他用户不应删除死信")
+	}
+	if err := db.DeleteWebhookDeadLetter("user-a", all[0].ID); err != nil {
+		t.Fatalf("删除死信失败: %v", err)
+	}
+	if _, err := db.GetWebhookDeadLetter("user-a", all[0].ID); err == nil {
+		t.Errorf("删除后不应再查询到")
+	}
+}
@@ -0,0 +1,369 @@
+package decision
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"nofx/market"
+	"nofx/mcp"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 智能体模式：AI 在一个决策周期内可多轮调用工具按需获取数据，最后再输出决策
+const (
+	// DefaultAgentMaxSteps 默认最多工具调用轮数（每轮可调用多个工具）
+	DefaultAgentMaxSteps = 4
+	// MaxAgentMaxSteps 工具调用轮数上限
+	MaxAgentMaxSteps = 10
+	// maxToolCallsPerStep 单轮最多执行的工具调用数
+	maxToolCallsPerStep = 4
+	// agentResponseReserve 为模型回复预留的 token 数（工具结果不能占满预算）
+	agentResponseReserve = 2000
+	// AgentUserPromptTemplate 智能体模式未指定用户提示词模板时使用的精简模板
+	AgentUserPromptTemplate = "agent"
+)
+
+var reToolCall = regexp.MustCompile(`(?s)<tool_call>(.*?)</tool_call>`)
+
+// AgentToolCall 一次工具调用记录（写入思维链，便于复盘AI获取了哪些数据）
+type AgentToolCall struct {
+	Step      int                    `json:"step"`
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+	Result    string                 `json:"result,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+	Tokens    int                    `json:"tokens"` // 结果的估算 token 数
+	Truncated bool                   `json:"truncated,omitempty"`
+}
+
+// agentTool 智能体可调用的工具
+type agentTool struct {
+	usage  string // 参数与用途说明（写入系统提示词）
+	invoke func(ctx *Context, args map[string]interface{}) (string, error)
+}
+
+// agentTools 可用工具（按名称排序写入提示词）
+var agentTools = map[string]agentTool{
+	"get_klines": {
+		usage:  `get_klines(symbol, interval, limit) - 获取K线（interval 如 "5m"/"1h"/"4h"，limit 默认60、最多200），按时间从旧到新返回 开/高/低/收/量`,
+		invoke: toolGetKlines,
+	},
+	"get_orderbook": {
+		usage:  `get_orderbook(symbol, size_usd) - 获取盘口：买卖价差、±0.5%/±1% 深度与失衡度、按 size_usd（默认1000）市价成交的预估滑点`,
+		invoke: toolGetOrderBook,
+	},
+	"get_position_history": {
+		usage:  `get_position_history(symbol) - 获取近期已平仓交易（symbol 为空时返回全部币种）`,
+		invoke: toolGetPositionHistory,
+	},
+	"get_pattern_analysis": {
+		usage:  `get_pattern_analysis(symbol, interval) - 获取K线形态分析（支撑阻力、趋势线、蜡烛形态，interval 默认 "1h"）`,
+		invoke: toolGetPatternAnalysis,
+	},
+}
+
+// agentMaxSteps 交易员配置的最多轮数（<=0 时使用默认值）
+func agentMaxSteps(ctx *Context) int {
+	if ctx.AgentMaxSteps <= 0 {
+		return DefaultAgentMaxSteps
+	}
+	return min(ctx.AgentMaxSteps, MaxAgentMaxSteps)
+}
+
+// buildAgentInstructions 工具调用说明（追加到系统提示词末尾）
+func buildAgentInstructions(ctx *Context) string {
+	names := make([]string, 0, len(agentTools))
+	for name := range agentTools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString("\n\n# 🔧 工具调用（智能体模式）\n\n")
+	sb.WriteString("用户提示词只包含账户、持仓和候选币种的摘要。给出决策前，你可以调用工具按需获取关心币种的详细数据：\n\n")
+	for _, name := range names {
+		sb.WriteString("- " + agentTools[name].usage + "\n")
+	}
+	sb.WriteString("\n调用格式（每轮最多")
+	sb.WriteString(fmt.Sprintf("%d个，可与简短分析一起输出）：\n", maxToolCallsPerStep))
+	sb.WriteString(`<tool_call>{"name": "get_klines", "arguments": {"symbol": "BTCUSDT", "interval": "15m", "limit": 60}}</tool_call>` + "\n\n")
+	sb.WriteString(fmt.Sprintf("- 最多%d轮工具调用，工具结果以 <tool_result> 返回\n", agentMaxSteps(ctx)))
+	sb.WriteString("- 调用工具的回复中不要输出 <decision>；数据足够后停止调用工具，按上述格式输出 <reasoning> 和 <decision>\n")
+	sb.WriteString("- 只为真正需要的币种获取数据，避免重复调用\n")
+	return sb.String()
+}
+
+// runAgentLoop 执行工具调用循环，返回AI的最终回复与工具调用记录
+// 总 token（对话历史 + 工具结果）不超过交易员的提示词预算，轮数或预算用尽时要求AI直接给出决策
+func runAgentLoop(ctx *Context, mcpClient mcp.AIClient, systemPrompt, userPrompt string) (string, []AgentToolCall, string, error) {
+	messages := []mcp.Message{
+		{Role: mcp.RoleSystem, Content: systemPrompt},
+		{Role: mcp.RoleUser, Content: userPrompt},
+	}
+	budget := promptBudgetFor(ctx)
+	used := mcp.EstimateTokens(ctx.AIProvider, systemPrompt) + mcp.EstimateTokens(ctx.AIProvider, userPrompt)
+	maxSteps := agentMaxSteps(ctx)
+
+	var calls []AgentToolCall
+	var transcript strings.Builder
+	for step := 1; step <= maxSteps; step++ {
+		response, err := mcpClient.CallWithConversation(messages)
+		if err != nil {
+			return "", calls, transcript.String(), err
+		}
+		requests := reToolCall.FindAllStringSubmatch(response, -1)
+		if len(requests) == 0 {
+			return response, calls, transcript.String(), nil
+		}
+		used += mcp.EstimateTokens(ctx.AIProvider, response)
+
+		if note := strings.TrimSpace(reToolCall.ReplaceAllString(response, "")); note != "" {
+			transcript.WriteString(fmt.Sprintf("### 第%d轮\n%s\n\n", step, note))
+		} else {
+			transcript.WriteString(fmt.Sprintf("### 第%d轮\n", step))
+		}
+
+		var results strings.Builder
+		for i, req := range requests {
+			call := AgentToolCall{Step: step}
+			if i >= maxToolCallsPerStep {
+				call.Name = "(skipped)"
+				call.Error = fmt.Sprintf("单轮最多调用%d个工具", maxToolCallsPerStep)
+			} else {
+				call = executeToolCall(ctx, step, req[1], budget-used-agentResponseReserve)
+			}
+			used += call.Tokens
+			calls = append(calls, call)
+
+			argsJSON, _ := json.Marshal(call.Arguments)
+			transcript.WriteString(fmt.Sprintf("→ %s %s\n", call.Name, argsJSON))
+			if call.Error != "" {
+				transcript.WriteString("❌ " + call.Error + "\n\n")
+				results.WriteString(fmt.Sprintf("<tool_result name=\"%s\">错误: %s</tool_result>\n", call.Name, call.Error))
+			} else {
+				transcript.WriteString(call.Result + "\n\n")
+				results.WriteString(fmt.Sprintf("<tool_result name=\"%s\">\n%s\n</tool_result>\n", call.Name, call.Result))
+			}
+		}
+
+		messages = append(messages,
+			mcp.Message{Role: mcp.RoleAssistant, Content: response},
+			mcp.Message{Role: mcp.RoleUser, Content: results.String()},
+		)
+		log.Printf("🔧 [智能体] 第%d轮: %d个工具调用，累计约%d/%d tokens", step, len(requests), used, budget)
+		if used >= budget-agentResponseReserve {
+			log.Printf("⚠️  [智能体] token 预算已用尽，要求AI直接给出决策")
+			break
+		}
+	}
+
+	// 轮数或预算用尽：不再执行工具，要求直接输出决策
+	messages = append(messages, mcp.Message{
+		Role:    mcp.RoleUser,
+		Content: "工具调用次数或数据预算已用完，请根据已获取的数据直接输出 <reasoning> 和 <decision>，不要再调用工具。",
+	})
+	response, err := mcpClient.CallWithConversation(messages)
+	return response, calls, transcript.String(), err
+}
+
+// executeToolCall 解析并执行一个工具调用，结果超出剩余预算时截断
+func executeToolCall(ctx *Context, step int, raw string, remainingTokens int) AgentToolCall {
+	call := AgentToolCall{Step: step}
+	var req struct {
+		Name      string                 `json:"name"`
+		Arguments map[string]interface{} `json:"arguments"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(raw)), &req); err != nil {
+		call.Name = "(invalid)"
+		call.Error = fmt.Sprintf("工具调用格式错误: %v", err)
+		return call
+	}
+	call.Name, call.Arguments = req.Name, req.Arguments
+
+	tool, ok := agentTools[req.Name]
+	if !ok {
+		call.Error = fmt.Sprintf("未知工具: %s", req.Name)
+		return call
+	}
+	if remainingTokens <= 0 {
+		call.Error = "token 预算不足，未执行"
+		return call
+	}
+
+	start := time.Now()
+	result, err := tool.invoke(ctx, req.Arguments)
+	if err != nil {
+		call.Error = err.Error()
+		return call
+	}
+	tokens := mcp.EstimateTokens(ctx.AIProvider, result)
+	if tokens > remainingTokens {
+		// 按比例截断到剩余预算内
+		keep := len(result) * remainingTokens / tokens
+		result = truncateUTF8(result, keep) + "\n...（超出token预算，结果已截断）"
+		tokens = mcp.EstimateTokens(ctx.AIProvider, result)
+		call.Truncated = true
+	}
+	call.Result, call.Tokens = result, tokens
+	log.Printf("🔧 [智能体] %s %v (%d tokens, %v)", req.Name, req.Arguments, tokens, time.Since(start).Round(time.Millisecond))
+	return call
+}
+
+// formatAgentTranscript 工具调用记录（写入思维链开头）
+func formatAgentTranscript(calls []AgentToolCall, transcript string) string {
+	if len(calls) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("## 🔧 工具调用记录（%d次）\n\n%s## 🧠 最终分析\n\n", len(calls), transcript)
+}
+
+func truncateUTF8(s string, n int) string {
+	if n >= len(s) {
+		return s
+	}
+	if n <= 0 {
+		return ""
+	}
+	for n > 0 && !isRuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+func isRuneStart(b byte) bool { return b&0xC0 != 0x80 }
+
+// === 工具实现（复用行情与决策模块已有的数据获取和分析函数）===
+
+func stringArg(args map[string]interface{}, key, fallback string) string {
+	if v, ok := args[key].(string); ok && strings.TrimSpace(v) != "" {
+		return strings.TrimSpace(v)
+	}
+	return fallback
+}
+
+func numberArg(args map[string]interface{}, key string, fallback float64) float64 {
+	if v, ok := args[key].(float64); ok && v > 0 {
+		return v
+	}
+	return fallback
+}
+
+// klineLimitArg 读取K线数量参数，先在浮点数上限制到 [1, 200] 再取整，避免超大值转换溢出
+func klineLimitArg(args map[string]interface{}) int {
+	return int(math.Max(1, math.Min(numberArg(args, "limit", 60), 200)))
+}
+
+func symbolArg(args map[string]interface{}) (string, error) {
+	symbol := stringArg(args, "symbol", "")
+	if symbol == "" {
+		return "", fmt.Errorf("缺少参数 symbol")
+	}
+	return market.Normalize(symbol), nil
+}
+
+// agentKlines 获取K线：非币安交易员使用成交场所自身的行情
+func agentKlines(ctx *Context, symbol, interval string, limit int) ([]market.Kline, error) {
+	if !market.IsValidInterval(interval) {
+		return nil, fmt.Errorf("不支持的K线周期: %s", interval)
+	}
+	if ctx.DataSource != nil {
+		return ctx.DataSource.GetKlines(symbol, interval, limit)
+	}
+	return market.DefaultSourceManager().GetKlinesWithFallback(symbol, interval, limit)
+}
+
+func toolGetKlines(ctx *Context, args map[string]interface{}) (string, error) {
+	symbol, err := symbolArg(args)
+	if err != nil {
+		return "", err
+	}
+	interval := stringArg(args, "interval", "1h")
+	limit := klineLimitArg(args)
+
+	klines, err := agentKlines(ctx, symbol, interval, limit)
+	if err != nil {
+		return "", fmt.Errorf("获取%s %s K线失败: %v", symbol, interval, err)
+	}
+	if len(klines) > limit {
+		klines = klines[len(klines)-limit:]
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s %s 最近%d根K线（时间 开 高 低 收 量）\n", symbol, interval, len(klines)))
+	for _, k := range klines {
+		sb.WriteString(fmt.Sprintf("%s %.6g %.6g %.6g %.6g %.4g\n",
+			time.UnixMilli(k.OpenTime).Format("01-02 15:04"), k.Open, k.High, k.Low, k.Close, k.Volume))
+	}
+	return sb.String(), nil
+}
+
+func toolGetOrderBook(ctx *Context, args map[string]interface{}) (string, error) {
+	symbol, err := symbolArg(args)
+	if err != nil {
+		return "", err
+	}
+	metrics, err := market.GetOrderBookMetrics(symbol, numberArg(args, "size_usd", 1000))
+	if err != nil {
+		return "", fmt.Errorf("获取%s盘口失败: %v", symbol, err)
+	}
+	data, err := json.Marshal(metrics)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s 盘口: %s", symbol, data), nil
+}
+
+func toolGetPositionHistory(ctx *Context, args map[string]interface{}) (string, error) {
+	symbol := stringArg(args, "symbol", "")
+	if symbol != "" {
+		symbol = market.Normalize(symbol)
+	}
+
+	var sb strings.Builder
+	count := 0
+	_, trades, _ := parsePerformance(ctx)
+	for _, trade := range trades {
+		if symbol != "" && trade.Symbol != symbol {
+			continue
+		}
+		count++
+		stop := ""
+		if trade.WasStopLoss {
+			stop = " 止损"
+		}
+		sb.WriteString(fmt.Sprintf("%s %s %dx | %s→%s | 开 %.6g → 平 %.6g | 盈亏 %+.2f USDT (%+.2f%%) | 持仓 %s%s\n",
+			trade.Symbol, strings.ToUpper(trade.Side), trade.Leverage,
+			trade.OpenTime.Format("01-02 15:04"), trade.CloseTime.Format("01-02 15:04"),
+			trade.OpenPrice, trade.ClosePrice, trade.PnL, trade.PnLPct, trade.Duration, stop))
+	}
+	if count == 0 {
+		return "无近期已平仓交易", nil
+	}
+	return sb.String(), nil
+}
+
+func toolGetPatternAnalysis(ctx *Context, args map[string]interface{}) (string, error) {
+	symbol, err := symbolArg(args)
+	if err != nil {
+		return "", err
+	}
+	interval := stringArg(args, "interval", "1h")
+
+	if byInterval, ok := ctx.MultiTimeframeAnalysisMap[symbol]; ok {
+		if analysis, ok := byInterval[interval]; ok && analysis != nil {
+			return analysis.FormatForPrompt(), nil
+		}
+	}
+	klines, err := agentKlines(ctx, symbol, interval, 100)
+	if err != nil {
+		return "", fmt.Errorf("获取%s %s K线失败: %v", symbol, interval, err)
+	}
+	if len(klines) < 20 {
+		return "", fmt.Errorf("%s %s K线数据不足（%d根）", symbol, interval, len(klines))
+	}
+	return AnalyzeKlinePatterns(klines, symbol, interval).FormatForPrompt(), nil
+}
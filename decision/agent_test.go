@@ -0,0 +1,153 @@
+package decision
+
+import (
+	"math"
+	"nofx/logger"
+	"nofx/mcp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scriptedAIClient 按顺序返回预设回复，并记录每次收到的对话
+type scriptedAIClient struct {
+	mcp.AIClient
+	responses []string
+	received  [][]mcp.Message
+}
+
+func (c *scriptedAIClient) CallWithConversation(messages []mcp.Message) (string, error) {
+	c.received = append(c.received, append([]mcp.Message(nil), messages...))
+	if len(c.responses) == 0 {
+		return "<reasoning>done</reasoning><decision>[]</decision>", nil
+	}
+	resp := c.responses[0]
+	c.responses = c.responses[1:]
+	return resp, nil
+}
+
+func agentTestContext() *Context {
+	now := time.Now()
+	return &Context{
+		AgentMode:  true,
+		AIProvider: "deepseek",
+		Performance: map[string]interface{}{
+			"recent_trades": []logger.TradeOutcome{
+				{Symbol: "BTCUSDT", Side: "long", Leverage: 5, OpenPrice: 60000, ClosePrice: 61000, PnL: 12.5, PnLPct: 8.3, Duration: "2h", OpenTime: now, CloseTime: now},
+				{Symbol: "ETHUSDT", Side: "short", Leverage: 3, OpenPrice: 3000, ClosePrice: 3100, PnL: -5, PnLPct: -3.3, Duration: "1h", OpenTime: now, CloseTime: now, WasStopLoss: true},
+			},
+		},
+	}
+}
+
+// TestRunAgentLoop_ToolCalls 测试工具调用结果回传给AI并记录到工具调用记录
+func TestRunAgentLoop_ToolCalls(t *testing.T) {
+	client := &scriptedAIClient{responses: []string{
+		`先看看BTC的历史交易
+<tool_call>{"name": "get_position_history", "arguments": {"symbol": "btc"}}</tool_call>
+<tool_call>{"name": "get_funding"}</tool_call>`,
+		"<reasoning>BTC 做多表现好</reasoning><decision>[]</decision>",
+	}}
+
+	final, calls, transcript, err := runAgentLoop(agentTestContext(), client, "system", "user")
+	if err != nil {
+		t.Fatalf("runAgentLoop 失败: %v", err)
+	}
+	if !strings.Contains(final, "BTC 做多表现好") {
+		t.Errorf("应返回AI的最终回复: %q", final)
+	}
+	if len(client.received) != 2 {
+		t.Fatalf("AI应被调用2次，实际 %d 次", len(client.received))
+	}
+	if len(calls) != 2 {
+		t.Fatalf("应记录2个工具调用，实际 %d 个", len(calls))
+	}
+	if calls[0].Name != "get_position_history" || !strings.Contains(calls[0].Result, "BTCUSDT") || strings.Contains(calls[0].Result, "ETHUSDT") {
+		t.Errorf("get_position_history 应只返回 BTCUSDT 的交易: %+v", calls[0])
+	}
+	if calls[1].Error == "" {
+		t.Errorf("未知工具应返回错误: %+v", calls[1])
+	}
+
+	// 第二次调用应包含AI的工具调用回复与工具结果
+	second := client.received[1]
+	if len(second) != 4 || second[2].Role != mcp.RoleAssistant || second[3].Role != mcp.RoleUser {
+		t.Fatalf("第二次调用的对话结构不正确: %+v", second)
+	}
+	if !strings.Contains(second[3].Content, `<tool_result name="get_position_history">`) || !strings.Contains(second[3].Content, "未知工具") {
+		t.Errorf("工具结果未回传给AI: %s", second[3].Content)
+	}
+
+	trace := formatAgentTranscript(calls, transcript)
+	for _, want := range []string{"工具调用记录（2次）", "先看看BTC的历史交易", "→ get_position_history", "BTCUSDT LONG 5x"} {
+		if !strings.Contains(trace, want) {
+			t.Errorf("思维链中的工具调用记录缺少 %q:\n%s", want, trace)
+		}
+	}
+}
+
+// TestRunAgentLoop_StepLimit 测试轮数用尽后要求AI直接给出决策
+func TestRunAgentLoop_StepLimit(t *testing.T) {
+	call := `<tool_call>{"name": "get_position_history", "arguments": {}}</tool_call>`
+	client := &scriptedAIClient{responses: []string{call, call, call, call}}
+
+	ctx := agentTestContext()
+	ctx.AgentMaxSteps = 2
+	_, calls, _, err := runAgentLoop(ctx, client, "system", "user")
+	if err != nil {
+		t.Fatalf("runAgentLoop 失败: %v", err)
+	}
+	if len(calls) != 2 {
+		t.Errorf("应只执行2轮工具调用，实际 %d 个", len(calls))
+	}
+	if len(client.received) != 3 {
+		t.Fatalf("轮数用尽后应再调用一次AI，实际调用 %d 次", len(client.received))
+	}
+	last := client.received[2]
+	if !strings.Contains(last[len(last)-1].Content, "直接输出") {
+		t.Errorf("最后一次调用应要求AI直接输出决策: %s", last[len(last)-1].Content)
+	}
+}
+
+// TestExecuteToolCall_Budget 测试工具结果超出剩余 token 预算时截断或不执行
+func TestExecuteToolCall_Budget(t *testing.T) {
+	ctx := agentTestContext()
+	raw := `{"name": "get_position_history", "arguments": {}}`
+
+	if call := executeToolCall(ctx, 1, raw, 0); call.Error == "" || call.Result != "" {
+		t.Errorf("预算不足时不应执行工具: %+v", call)
+	}
+	call := executeToolCall(ctx, 1, raw, 10)
+	if !call.Truncated || !strings.Contains(call.Result, "已截断") {
+		t.Errorf("超出预算的结果应被截断: %+v", call)
+	}
+	if call := executeToolCall(ctx, 1, "not json", 1000); call.Error == "" {
+		t.Errorf("格式错误的工具调用应返回错误")
+	}
+}
+
+// TestKlineLimitArg 测试K线数量参数被限制在 [1, 200]，超大值不会溢出
+func TestKlineLimitArg(t *testing.T) {
+	tests := []struct {
+		arg  interface{}
+		want int
+	}{
+		{nil, 60},
+		{float64(50), 50},
+		{float64(500), 200},
+		{0.5, 1},
+		{1e300, 200},
+		{math.Inf(1), 200},
+		{math.NaN(), 60},
+		{"100", 60},
+	}
+	for _, tt := range tests {
+		args := map[string]interface{}{}
+		if tt.arg != nil {
+			args["limit"] = tt.arg
+		}
+		if got := klineLimitArg(args); got != tt.want {
+			t.Errorf("limit=%v: got %d, want %d", tt.arg, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package decision
+
+import (
+	"fmt"
+	"math"
+	"nofx/logger"
+)
+
+// BuildEntrySnapshot 记录开仓时该币种的行情快照（价格指标、自定义指标与各周期形态），用于事后交易归因
+func BuildEntrySnapshot(ctx *Context, symbol string) *logger.EntrySnapshot {
+	if ctx == nil {
+		return nil
+	}
+	snapshot := &logger.EntrySnapshot{Regime: MarketRegime(ctx.MarketSummary)}
+
+	if data, ok := ctx.MarketDataMap[symbol]; ok && data != nil {
+		snapshot.Price = data.CurrentPrice
+		snapshot.Change1h = data.PriceChange1h
+		snapshot.Change4h = data.PriceChange4h
+		snapshot.RSI7 = data.CurrentRSI7
+		snapshot.MACD = data.CurrentMACD
+		snapshot.FundingRate = data.FundingRate
+	}
+
+	for _, result := range ctx.IndicatorResultMap[symbol] {
+		for _, line := range result.Lines {
+			if n := len(line.Values); n > 0 && !math.IsNaN(line.Values[n-1]) {
+				if snapshot.Indicators == nil {
+					snapshot.Indicators = make(map[string]float64)
+				}
+				key := fmt.Sprintf("%s %s %s", result.Spec.Timeframe, result.Spec.Label(), line.Name)
+				snapshot.Indicators[key] = line.Values[n-1]
+			}
+		}
+	}
+
+	analyses := ctx.MultiTimeframeAnalysisMap[symbol]
+	for _, interval := range patternIntervalOrder {
+		analysis, ok := analyses[interval]
+		if !ok || analysis == nil {
+			continue
+		}
+		tf := logger.TimeframeSnapshot{Interval: interval, Bias: patternBias(analysis)}
+		for _, pattern := range analysis.Patterns {
+			tf.Patterns = append(tf.Patterns, pattern.Name)
+		}
+		snapshot.Timeframes = append(snapshot.Timeframes, tf)
+	}
+	return snapshot
+}
+
+// patternBias 按看涨/看跌形态的置信度之和判断周期方向
+func patternBias(analysis *PatternAnalysis) string {
+	var bullish, bearish float64
+	for _, pattern := range analysis.Patterns {
+		switch pattern.Type {
+		case "bullish":
+			bullish += pattern.Confidence
+		case "bearish":
+			bearish += pattern.Confidence
+		}
+	}
+	switch {
+	case bullish > bearish:
+		return "bullish"
+	case bearish > bullish:
+		return "bearish"
+	default:
+		return "neutral"
+	}
+}
@@ -0,0 +1,53 @@
+package decision
+
+import (
+	"math"
+	"nofx/market"
+	"testing"
+)
+
+// TestBuildEntrySnapshot 测试开仓快照记录价格指标、自定义指标最新值与各周期形态方向
+func TestBuildEntrySnapshot(t *testing.T) {
+	ctx := &Context{
+		MarketSummary: &MarketSummary{TrendLabel: "bullish", VolatilityLabel: "high"},
+		MarketDataMap: map[string]*market.Data{
+			"BTCUSDT": {Symbol: "BTCUSDT", CurrentPrice: 65000, PriceChange1h: 0.8, CurrentRSI7: 61, FundingRate: 0.0001},
+		},
+		IndicatorResultMap: map[string][]market.IndicatorResult{
+			"BTCUSDT": {{
+				Spec: market.IndicatorSpec{Name: "custom", Timeframe: "1h"},
+				Lines: []market.IndicatorLine{
+					{Name: "value", Values: []float64{math.NaN(), 1.5, 2.5}},
+					{Name: "warmup", Values: []float64{math.NaN()}},
+				},
+			}},
+		},
+		MultiTimeframeAnalysisMap: map[string]map[string]*PatternAnalysis{
+			"BTCUSDT": {
+				"4h": {Patterns: []PatternSignal{{Name: "黄昏星", Type: "bearish", Confidence: 70}}},
+				"1h": {Patterns: []PatternSignal{
+					{Name: "看涨吞没", Type: "bullish", Confidence: 80},
+					{Name: "十字星", Type: "neutral", Confidence: 90},
+				}},
+			},
+		},
+	}
+
+	snapshot := BuildEntrySnapshot(ctx, "BTCUSDT")
+	if snapshot.Price != 65000 || snapshot.RSI7 != 61 || snapshot.FundingRate != 0.0001 || snapshot.Regime != "bullish/high" {
+		t.Errorf("价格指标或市场状态不正确: %+v", snapshot)
+	}
+	if len(snapshot.Indicators) != 1 || snapshot.Indicators["1h custom value"] != 2.5 {
+		t.Errorf("应只记录有效的指标最新值: %v", snapshot.Indicators)
+	}
+	if len(snapshot.Timeframes) != 2 || snapshot.Timeframes[0].Interval != "1h" || snapshot.Timeframes[1].Interval != "4h" {
+		t.Fatalf("周期应按短到长排序: %+v", snapshot.Timeframes)
+	}
+	if snapshot.Timeframes[0].Bias != "bullish" || len(snapshot.Timeframes[0].Patterns) != 2 || snapshot.Timeframes[1].Bias != "bearish" {
+		t.Errorf("形态方向不正确: %+v", snapshot.Timeframes)
+	}
+
+	if empty := BuildEntrySnapshot(ctx, "ETHUSDT"); empty.Price != 0 || len(empty.Timeframes) != 0 || empty.Regime != "bullish/high" {
+		t.Errorf("无数据的币种只应记录市场状态: %+v", empty)
+	}
+}
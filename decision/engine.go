@@ -109,6 +109,24 @@ type Context struct {
 	AltcoinLeverage    int                                     `json:"-"` // 山寨币杠杆倍数（从配置读取）
 	TakerFeeRate       float64                                 `json:"-"` // Taker fee rate (from config, default 0.0004)
 	MakerFeeRate       float64                                 `json:"-"` // Maker fee rate (from config, default 0.0002)
+	DataSource         market.DataSource                       `json:"-"` // 交易所自身的行情数据源（为空时使用币安行情）
+	Indicators         []market.IndicatorSpec                  `json:"-"` // 交易员选择的指标（为空时只输出内置指标）
+	IndicatorResultMap map[string][]market.IndicatorResult     `json:"-"` // 按交易员指标配置计算的结果 (symbol -> results)
+	UserPromptTemplate string                                  `json:"-"` // 用户提示词模板名称（prompts/user/*.tmpl，为空时使用 default）
+	AIProvider         string                                  `json:"-"` // AI服务商（用于估算 token 数）
+	PromptTokenBudget  int                                     `json:"-"` // 提示词 token 预算（<=0 时使用服务商默认值）
+	PromptUsage        *PromptUsage                            `json:"-"` // 本次提示词的 token 用量与压缩情况（构建提示词后填充）
+	SystemPromptTemplate string                                `json:"-"` // 实际生效的系统提示词模板名称（构建提示词后填充）
+	SystemPromptVersion  string                                `json:"-"` // 实际生效的系统提示词版本（模板哈希，附加个性化策略时为 模板哈希+自定义哈希；构建提示词后填充）
+	TemplateOwner        string                                `json:"-"` // 交易员所属用户ID（系统提示词模板在该用户的命名空间中解析）
+	AgentMode            bool                                  `json:"-"` // 智能体模式：AI 可多轮调用工具按需获取数据
+	AgentMaxSteps        int                                   `json:"-"` // 智能体模式最多工具调用轮数（<=0 时使用默认值）
+	Lessons              []TradeLesson                         `json:"-"` // 交易员的历史复盘经验（构建提示词时按相关度筛选）
+	RegimeDetector       *RegimeDetector                       `json:"-"` // 交易员的市场状态识别器（为空时不识别）
+	RegimePolicies       map[string]RegimePolicy               `json:"-"` // 市场状态 → 策略（模板/杠杆上限/禁止开仓）
+	RegimePreview        bool                                  `json:"-"` // 提示词预览：只读取当前状态，不推进识别器
+	Regime               *RegimeState                          `json:"-"` // 本周期的市场状态（构建提示词时填充）
+	RegimePolicy         *RegimePolicy                         `json:"-"` // 本周期生效的策略（未配置时为空）
 }
 
 // Decision AI的交易决策
@@ -131,6 +149,9 @@ type Decision struct {
 	Confidence int     `json:"confidence,omitempty"` // 信心度 (0-100)
 	RiskUSD    float64 `json:"risk_usd,omitempty"`   // 最大美元风险
 	Reasoning  string  `json:"reasoning"`
+
+	// SizeLocked 仓位已最终确定（Kelly 计算或人工审批通过），执行时只做保证金与最小名义价值约束，不再放大
+	SizeLocked bool `json:"-"`
 }
 
 // FullDecision AI的完整决策（包含思维链）
@@ -142,6 +163,13 @@ type FullDecision struct {
 	Timestamp    time.Time  `json:"timestamp"`
 	// AIRequestDurationMs 记录 AI API 调用耗时（毫秒）方便排查延迟问题
 	AIRequestDurationMs int64 `json:"ai_request_duration_ms,omitempty"`
+	// PromptUsage 提示词 token 用量与被压缩的段落
+	PromptUsage *PromptUsage `json:"prompt_usage,omitempty"`
+	// PromptTemplate/PromptTemplateVersion 生成本次决策的系统提示词模板及其版本哈希
+	PromptTemplate        string `json:"prompt_template,omitempty"`
+	PromptTemplateVersion string `json:"prompt_template_version,omitempty"`
+	// ToolCalls 智能体模式下AI的工具调用记录
+	ToolCalls []AgentToolCall `json:"tool_calls,omitempty"`
 }
 
 // GetFullDecision 获取AI的完整交易决策（批量分析所有币种和持仓）
@@ -151,31 +179,21 @@ func GetFullDecision(ctx *Context, mcpClient mcp.AIClient) (*FullDecision, error
 
 // GetFullDecisionWithCustomPrompt 获取AI的完整交易决策（支持自定义prompt和模板选择）
 func GetFullDecisionWithCustomPrompt(ctx *Context, mcpClient mcp.AIClient, customPrompt string, overrideBase bool, templateName string) (*FullDecision, error) {
-	// 1. 为所有币种获取最新市场数据（确保使用最新数据）
-	log.Printf("📊 [决策] 开始获取最新市场数据...")
-	if err := fetchMarketDataForContext(ctx); err != nil {
-		return nil, fmt.Errorf("获取市场数据失败: %w", err)
-	}
-	
-	// 记录BTC当前价格（用于确认数据是最新的）
-	if btcData, hasBTC := ctx.MarketDataMap["BTCUSDT"]; hasBTC {
-		log.Printf("📊 [决策] BTC当前价格: %.2f (1h: %+.2f%%, 4h: %+.2f%%) | MACD: %.4f | RSI: %.2f",
-			btcData.CurrentPrice, btcData.PriceChange1h, btcData.PriceChange4h,
-			btcData.CurrentMACD, btcData.CurrentRSI7)
+	systemPrompt, userPrompt, err := PreparePrompts(ctx, customPrompt, overrideBase, templateName)
+	if err != nil {
+		return nil, err
 	}
-	
-	ctx.MarketSummary = analyzeMarketSummary(ctx)
-	
-	// 2. 获取K线形态分析（异步，不阻塞主流程）
-	fetchPatternAnalysisForContext(ctx)
-
-	// 2. 构建 System Prompt（固定规则）和 User Prompt（动态数据）
-	systemPrompt := buildSystemPromptWithCustom(ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage, customPrompt, overrideBase, templateName)
-	userPrompt := buildUserPrompt(ctx)
 
 	// 3. 调用AI API（使用 system + user prompt）
 	aiCallStart := time.Now()
-	aiResponse, err := mcpClient.CallWithMessages(systemPrompt, userPrompt)
+	var aiResponse, agentTranscript string
+	var toolCalls []AgentToolCall
+	if ctx.AgentMode {
+		// 智能体模式：AI 可多轮调用工具按需获取数据
+		aiResponse, toolCalls, agentTranscript, err = runAgentLoop(ctx, mcpClient, systemPrompt, userPrompt)
+	} else {
+		aiResponse, err = mcpClient.CallWithMessages(systemPrompt, userPrompt)
+	}
 	aiCallDuration := time.Since(aiCallStart)
 	if err != nil {
 		return nil, fmt.Errorf("调用AI API失败: %w", err)
@@ -190,6 +208,11 @@ func GetFullDecisionWithCustomPrompt(ctx *Context, mcpClient mcp.AIClient, custo
 		decision.SystemPrompt = systemPrompt // 保存系统prompt
 		decision.UserPrompt = userPrompt     // 保存输入prompt
 		decision.AIRequestDurationMs = aiCallDuration.Milliseconds()
+		decision.PromptUsage = ctx.PromptUsage
+		decision.PromptTemplate = ctx.SystemPromptTemplate
+		decision.PromptTemplateVersion = ctx.SystemPromptVersion
+		decision.ToolCalls = toolCalls
+		decision.CoTTrace = formatAgentTranscript(toolCalls, agentTranscript) + decision.CoTTrace
 	}
 
 	if err != nil {
@@ -202,6 +225,45 @@ func GetFullDecisionWithCustomPrompt(ctx *Context, mcpClient mcp.AIClient, custo
 	return decision, nil
 }
 
+// PreparePrompts 获取最新市场数据并构建 System Prompt 与 User Prompt（不调用AI，决策与提示词预览共用）
+func PreparePrompts(ctx *Context, customPrompt string, overrideBase bool, templateName string) (systemPrompt, userPrompt string, err error) {
+	// 1. 为所有币种获取最新市场数据（确保使用最新数据）
+	log.Printf("📊 [决策] 开始获取最新市场数据...")
+	if err := fetchMarketDataForContext(ctx); err != nil {
+		return "", "", fmt.Errorf("获取市场数据失败: %w", err)
+	}
+	
+	// 记录BTC当前价格（用于确认数据是最新的）
+	if btcData, hasBTC := ctx.MarketDataMap["BTCUSDT"]; hasBTC {
+		log.Printf("📊 [决策] BTC当前价格: %.2f (1h: %+.2f%%, 4h: %+.2f%%) | MACD: %.4f | RSI: %.2f",
+			btcData.CurrentPrice, btcData.PriceChange1h, btcData.PriceChange4h,
+			btcData.CurrentMACD, btcData.CurrentRSI7)
+	}
+	
+	ctx.MarketSummary = analyzeMarketSummary(ctx)
+	templateName = applyMarketRegime(ctx, templateName)
+	
+	// 2. 获取K线形态分析（异步，不阻塞主流程）
+	// 智能体模式下由AI通过 get_pattern_analysis 按需获取
+	if !ctx.AgentMode {
+		fetchPatternAnalysisForContext(ctx)
+	}
+
+	// 按交易员的指标配置计算指标
+	fetchIndicatorsForContext(ctx)
+
+	// 2. 构建 System Prompt（固定规则）和 User Prompt（动态数据）
+	systemPrompt, ctx.SystemPromptTemplate, ctx.SystemPromptVersion = buildVersionedSystemPrompt(ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage, customPrompt, overrideBase, ctx.TemplateOwner, templateName)
+	if ctx.AgentMode {
+		systemPrompt += buildAgentInstructions(ctx)
+		if ctx.UserPromptTemplate == "" && UserPromptTemplateExists(AgentUserPromptTemplate) {
+			ctx.UserPromptTemplate = AgentUserPromptTemplate
+		}
+	}
+	userPrompt = renderUserPromptWithBudget(ctx, systemPrompt)
+	return systemPrompt, userPrompt, nil
+}
+
 // fetchPatternAnalysisForContext 为上下文中的币种获取K线形态分析
 // ⚡ 关键修复：使用 MarketDataMap 中已获取的K线数据，确保与价格数据同步
 func fetchPatternAnalysisForContext(ctx *Context) {
@@ -244,7 +306,8 @@ func fetchPatternAnalysisForContext(ctx *Context) {
 	// 并发分析K线形态（多时间周期）
 	var wg sync.WaitGroup
 	var mu sync.Mutex
-	apiClient := market.NewAPIClient()
+	// 通过数据源管理器获取（优先使用WebSocket缓存，支持任意币安周期）
+	sources := market.DefaultSourceManager()
 	
 	for symbol := range symbolsToAnalyze {
 		// 为每个币种初始化多时间周期分析map
@@ -268,15 +331,15 @@ func fetchPatternAnalysisForContext(ctx *Context) {
 						klines = marketData.RawKlines1h
 						log.Printf("✓ [K线形态] %s %s 使用已获取的K线数据（%d根）", sym, tf, len(klines))
 					} else {
-						klines, err = apiClient.GetKlines(sym, tf, 100)
+						klines, err = sources.GetKlinesWithFallback(sym, tf, 100)
 			if err != nil {
 							log.Printf("⚠️ 获取%s %s K线数据失败: %v", sym, tf, err)
 							return
 						}
 					}
 				} else {
-					// 其他时间周期直接从API获取
-					klines, err = apiClient.GetKlines(sym, tf, 100)
+					// 其他时间周期从数据源管理器获取
+					klines, err = sources.GetKlinesWithFallback(sym, tf, 100)
 					if err != nil {
 						log.Printf("⚠️ 获取%s %s K线数据失败: %v", sym, tf, err)
 						return
@@ -347,6 +410,38 @@ func fetchPatternAnalysisForContext(ctx *Context) {
 	}
 }
 
+// fetchIndicatorsForContext 按交易员的指标配置为已获取行情的币种计算指标
+func fetchIndicatorsForContext(ctx *Context) {
+	ctx.IndicatorResultMap = make(map[string][]market.IndicatorResult)
+	if len(ctx.Indicators) == 0 {
+		return
+	}
+
+	sources := market.DefaultSourceManager()
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for symbol := range ctx.MarketDataMap {
+		wg.Add(1)
+		go func(sym string) {
+			defer wg.Done()
+			results, err := market.ComputeIndicatorSet(ctx.Indicators, func(tf string, limit int) ([]market.Kline, error) {
+				if ctx.DataSource != nil {
+					return ctx.DataSource.GetKlines(sym, tf, limit)
+				}
+				return sources.GetKlinesWithFallback(sym, tf, limit)
+			})
+			if err != nil {
+				log.Printf("⚠️ %s 指标计算不完整: %v", sym, err)
+			}
+			mu.Lock()
+			ctx.IndicatorResultMap[sym] = results
+			mu.Unlock()
+		}(symbol)
+	}
+	wg.Wait()
+	log.Printf("📊 已按配置计算 %d 个指标（%d 个币种）", len(ctx.Indicators), len(ctx.IndicatorResultMap))
+}
+
 // fetchMarketDataForContext 为上下文中的所有币种获取市场数据和OI数据
 func fetchMarketDataForContext(ctx *Context) error {
 	ctx.MarketDataMap = make(map[string]*market.Data)
@@ -381,16 +476,36 @@ func fetchMarketDataForContext(ctx *Context) error {
 	}
 
 	for symbol := range symbolSet {
-		// ⚡ 关键修复：AI决策时强制从API获取最新数据，不使用WebSocket缓存
-		// 确保AI决策基于最新的实时价格
-		data, err := market.GetFresh(symbol)
-		if err != nil {
-			// 如果GetFresh失败，回退到Get（使用WebSocket缓存）
-			log.Printf("⚠️  [决策] GetFresh失败，回退到Get: %v", err)
-			data, err = market.Get(symbol)
+		var data *market.Data
+		var err error
+		if ctx.DataSource != nil {
+			// 非币安交易员使用成交场所自身的行情（价格/资金费率/持仓量与实际交易一致）
+			data, err = market.GetFromSource(ctx.DataSource, symbol)
+			if err != nil {
+				// 交易所不支持该币种或请求失败时跳过，避免用其他交易所的价格做决策
+				log.Printf("❌ [决策] 从 %s 获取 %s 市场数据失败: %v", ctx.DataSource.GetName(), symbol, err)
+				continue
+			}
+		} else {
+			// ⚡ 关键修复：AI决策时强制从API获取最新数据，不使用WebSocket缓存
+			// 确保AI决策基于最新的实时价格
+			data, err = market.GetFresh(symbol)
 			if err != nil {
-				// 单个币种失败不影响整体，只记录错误
-				log.Printf("❌ [决策] 获取 %s 市场数据失败: %v", symbol, err)
+				// 如果GetFresh失败，回退到Get（使用WebSocket缓存）
+				log.Printf("⚠️  [决策] GetFresh失败，回退到Get: %v", err)
+				data, err = market.Get(symbol)
+				if err != nil {
+					// 单个币种失败不影响整体，只记录错误
+					log.Printf("❌ [决策] 获取 %s 市场数据失败: %v", symbol, err)
+					continue
+				}
+			}
+		}
+
+		// 多数据源价格偏差过大的非持仓币种本周期跳过（持仓币种保留，确保AI仍可决定平仓）
+		if ctx.DataSource == nil && !positionSymbols[symbol] {
+			if err := market.CheckPriceConsistency(symbol); err != nil {
+				log.Printf("⚠️  %v", err)
 				continue
 			}
 		}
@@ -416,6 +531,25 @@ func fetchMarketDataForContext(ctx *Context) error {
 			}
 		}
 
+		// 盘口深度与流动性（仅币安行情，滑点按计划开仓规模估算）
+		if ctx.DataSource == nil {
+			if ob, obErr := market.GetOrderBookMetrics(symbol, intendedPositionUSD(ctx, symbol)); obErr == nil {
+				data.OrderBook = ob
+			} else {
+				log.Printf("⚠️  获取 %s 盘口数据失败: %v", symbol, obErr)
+			}
+			// 资金费率历史、预测费率与期现基差
+			if funding, fErr := market.GetFundingInfo(symbol); fErr == nil {
+				data.Funding = funding
+			} else {
+				log.Printf("⚠️  获取 %s 资金费率概况失败: %v", symbol, fErr)
+			}
+			// 清算与大单流向（仅交易员订阅了成交流的币种）
+			if flow, ok := market.DefaultFlowMonitor().Metrics(symbol); ok {
+				data.Flow = flow
+			}
+		}
+
 		ctx.MarketDataMap[symbol] = data
 	}
 
@@ -439,6 +573,15 @@ func fetchMarketDataForContext(ctx *Context) error {
 	return nil
 }
 
+// intendedPositionUSD 估算开仓名义价值（与默认提示词的仓位建议一致：BTC/ETH 约8倍净值，山寨币约4倍净值）
+func intendedPositionUSD(ctx *Context, symbol string) float64 {
+	multiple := 4.0
+	if symbol == "BTCUSDT" || symbol == "ETHUSDT" {
+		multiple = 8.0
+	}
+	return ctx.Account.TotalEquity * multiple
+}
+
 // calculateMaxCandidates 根据账户状态计算需要分析的候选币种数量
 func calculateMaxCandidates(ctx *Context) int {
 	// ⚠️ 重要：限制候选币种数量，避免 Prompt 过大
@@ -469,18 +612,35 @@ func calculateMaxCandidates(ctx *Context) int {
 }
 
 // buildSystemPromptWithCustom 构建包含自定义内容的 System Prompt
-func buildSystemPromptWithCustom(accountEquity float64, btcEthLeverage, altcoinLeverage int, customPrompt string, overrideBase bool, templateName string) string {
+func buildSystemPromptWithCustom(accountEquity float64, btcEthLeverage, altcoinLeverage int, customPrompt string, overrideBase bool, ownerID, templateName string) string {
+	prompt, _, _ := buildVersionedSystemPrompt(accountEquity, btcEthLeverage, altcoinLeverage, customPrompt, overrideBase, ownerID, templateName)
+	return prompt
+}
+
+// buildVersionedSystemPrompt 构建 System Prompt，并返回同一次模板查找得到的模板名称与版本
+// 版本覆盖实际发送的提示词：附加个性化策略时为「模板版本+自定义提示词哈希」，覆盖基础prompt时记为 "custom"
+func buildVersionedSystemPrompt(accountEquity float64, btcEthLeverage, altcoinLeverage int, customPrompt string, overrideBase bool, ownerID, templateName string) (prompt, name, version string) {
 	// 如果覆盖基础prompt且有自定义prompt，只使用自定义prompt
 	if overrideBase && customPrompt != "" {
-		return customPrompt
+		return customPrompt, "custom", PromptVersionHash(customPrompt)
 	}
 
 	// 获取基础prompt（使用指定的模板）
-	basePrompt := buildSystemPrompt(accountEquity, btcEthLeverage, altcoinLeverage, templateName)
+	template, err := lookupSystemPromptTemplate(ownerID, templateName)
+	if err != nil {
+		template = nil
+	}
+	basePrompt := renderSystemPrompt(accountEquity, btcEthLeverage, altcoinLeverage, template)
+	if template != nil {
+		name, version = template.Name, template.Version
+	}
 
 	// 如果没有自定义prompt，直接返回基础prompt
 	if customPrompt == "" {
-		return basePrompt
+		return basePrompt, name, version
+	}
+	if version != "" {
+		version += "+" + PromptVersionHash(customPrompt)
 	}
 
 	// 添加自定义prompt部分到基础prompt
@@ -492,31 +652,28 @@ func buildSystemPromptWithCustom(accountEquity float64, btcEthLeverage, altcoinL
 	sb.WriteString("\n\n")
 	sb.WriteString("注意: 以上个性化策略是对基础规则的补充，不能违背基础风险控制原则。\n")
 
-	return sb.String()
+	return sb.String(), name, version
 }
 
 // buildSystemPrompt 构建 System Prompt（使用模板+动态部分）
-func buildSystemPrompt(accountEquity float64, btcEthLeverage, altcoinLeverage int, templateName string) string {
-	var sb strings.Builder
-
-	// 1. 加载提示词模板（核心交易策略部分）
-	if templateName == "" {
-		templateName = "default" // 默认使用 default 模板
+func buildSystemPrompt(accountEquity float64, btcEthLeverage, altcoinLeverage int, ownerID, templateName string) string {
+	// 在交易员所属用户的命名空间中查找（用户模板优先，其次系统内置模板），不存在时使用 default
+	template, err := lookupSystemPromptTemplate(ownerID, templateName)
+	if err != nil {
+		template = nil
 	}
+	return renderSystemPrompt(accountEquity, btcEthLeverage, altcoinLeverage, template)
+}
 
-	template, err := GetPromptTemplate(templateName)
-	if err != nil {
-		// 如果模板不存在，记录错误并使用 default
-		log.Printf("⚠️  提示词模板 '%s' 不存在，使用 default: %v", templateName, err)
-		template, err = GetPromptTemplate("default")
-		if err != nil {
-			// 如果连 default 都不存在，使用内置的简化版本
-			log.Printf("❌ 无法加载任何提示词模板，使用内置简化版本")
-			sb.WriteString("你是专业的加密货币交易AI。请根据市场数据做出交易决策。\n\n")
-		} else {
-			sb.WriteString(template.Content)
-			sb.WriteString("\n\n")
-		}
+// renderSystemPrompt 以已解析的模板构建 System Prompt（template 为 nil 时使用内置简化版本）
+func renderSystemPrompt(accountEquity float64, btcEthLeverage, altcoinLeverage int, template *PromptTemplate) string {
+	var sb strings.Builder
+
+	// 1. 提示词模板（核心交易策略部分）
+	if template == nil {
+		// 如果连 default 都不存在，使用内置的简化版本
+		log.Printf("❌ 无法加载任何提示词模板，使用内置简化版本")
+		sb.WriteString("你是专业的加密货币交易AI。请根据市场数据做出交易决策。\n\n")
 	} else {
 		sb.WriteString(template.Content)
 		sb.WriteString("\n\n")
@@ -605,7 +762,7 @@ func buildSystemPrompt(accountEquity float64, btcEthLeverage, altcoinLeverage in
 	return sb.String()
 }
 
-// buildUserPrompt 构建 User Prompt（动态数据）
+// buildUserPrompt 构建 User Prompt（动态数据）的内置格式，用户提示词模板缺失或渲染失败时使用
 func buildUserPrompt(ctx *Context) string {
 	var sb strings.Builder
 
@@ -637,8 +794,7 @@ func buildUserPrompt(ctx *Context) string {
 					// 添加K线可视化（对关键时间周期：1m, 15m, 1h, 4h, 1d）
 					if interval == "1m" || interval == "15m" || interval == "1h" || interval == "4h" || interval == "1d" {
 						// 获取K线数据用于可视化
-						apiClient := market.NewAPIClient()
-						klines, err := apiClient.GetKlines("BTCUSDT", interval, 50) // 获取最近50根用于可视化
+						klines, err := market.GetHistoricalKlines("BTCUSDT", interval, 50) // 获取最近50根用于可视化
 						if err == nil && len(klines) > 0 {
 							visualization := FormatKlineVisualization(klines, "BTCUSDT", interval, 50)
 							if visualization != "" {
@@ -754,6 +910,7 @@ func buildUserPrompt(ctx *Context) string {
 					marketData.CurrentMACD, marketData.CurrentRSI7))
 				
 				sb.WriteString(market.Format(marketData))
+				sb.WriteString(market.FormatIndicators(ctx.IndicatorResultMap[pos.Symbol]))
 				sb.WriteString("\n")
 			}
 
@@ -771,8 +928,7 @@ func buildUserPrompt(ctx *Context) string {
 						// 为关键时间周期添加K线可视化数据（让AI能够更直观地看到K线状态）
 						if interval == "1m" || interval == "1h" || interval == "4h" || interval == "1d" {
 							// 获取K线数据用于可视化
-							apiClient := market.NewAPIClient()
-							klines, err := apiClient.GetKlines(pos.Symbol, interval, 50) // 获取最近50根用于可视化
+							klines, err := market.GetHistoricalKlines(pos.Symbol, interval, 50) // 获取最近50根用于可视化
 							if err == nil && len(klines) > 0 {
 								visualization := FormatKlineVisualization(klines, pos.Symbol, interval, 50)
 								if visualization != "" {
@@ -834,6 +990,7 @@ func buildUserPrompt(ctx *Context) string {
 			marketData.CurrentMACD, marketData.CurrentRSI7))
 		
 		sb.WriteString(market.Format(marketData))
+		sb.WriteString(market.FormatIndicators(ctx.IndicatorResultMap[coin.Symbol]))
 		
 		// 添加多时间周期K线形态分析（完整详细信息 + K线可视化）
 		if analyses, hasAnalyses := ctx.MultiTimeframeAnalysisMap[coin.Symbol]; hasAnalyses && len(analyses) > 0 {
@@ -847,8 +1004,7 @@ func buildUserPrompt(ctx *Context) string {
 					// 为关键时间周期添加K线可视化数据
 					if interval == "1m" || interval == "1h" || interval == "4h" || interval == "1d" {
 						// 获取K线数据用于可视化
-						apiClient := market.NewAPIClient()
-						klines, err := apiClient.GetKlines(coin.Symbol, interval, 50) // 获取最近50根用于可视化
+						klines, err := market.GetHistoricalKlines(coin.Symbol, interval, 50) // 获取最近50根用于可视化
 						if err == nil && len(klines) > 0 {
 							visualization := FormatKlineVisualization(klines, coin.Symbol, interval, 50)
 							if visualization != "" {
@@ -938,6 +1094,15 @@ func buildUserPrompt(ctx *Context) string {
 		}
 	}
 
+	// 与当前行情相关的历史复盘经验
+	if lessons := SelectRelevantLessons(ctx, MaxPromptLessons); len(lessons) > 0 {
+		sb.WriteString("## 🧠 历史复盘经验（与当前行情相关）\n\n")
+		for _, lesson := range lessons {
+			sb.WriteString(formatLessonLine(lesson) + "\n")
+		}
+		sb.WriteString("\n")
+	}
+
 	sb.WriteString("---\n\n")
 	sb.WriteString("现在请分析并输出决策（思维链 + JSON）\n")
 
@@ -0,0 +1,188 @@
+package decision
+
+import (
+	"encoding/json"
+	"fmt"
+	"nofx/logger"
+	"nofx/mcp"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MaxPromptLessons 每次写入用户提示词的复盘经验条数上限
+const MaxPromptLessons = 5
+
+// maxLessonLength 单条复盘经验的最大长度（字符）
+const maxLessonLength = 300
+
+// TradeLesson 交易复盘经验（平仓后由AI总结，构建提示词时按相关度筛选）
+type TradeLesson struct {
+	ID        int64
+	Symbol    string
+	Side      string
+	Regime    string // 市场状态，格式 "趋势/波动"，例如 "bullish/high"
+	Setup     string
+	Lesson    string
+	Keywords  []string
+	PnLPct    float64
+	CreatedAt time.Time
+}
+
+// MarketRegime 市场状态标签（趋势/波动），无市场状态时返回空字符串
+func MarketRegime(summary *MarketSummary) string {
+	if summary == nil {
+		return ""
+	}
+	return summary.TrendLabel + "/" + summary.VolatilityLabel
+}
+
+// SelectRelevantLessons 按与当前行情的相关度选出复盘经验（不相关的经验不写入提示词）
+// 相关度：币种为当前持仓 +3、候选币种 +2；市场状态完全一致 +2、仅趋势一致 +1；每命中一个关键词 +1。
+// 相关度相同时较新的经验优先。
+func SelectRelevantLessons(ctx *Context, limit int) []TradeLesson {
+	if len(ctx.Lessons) == 0 || limit <= 0 {
+		return nil
+	}
+
+	positionSymbols := make(map[string]bool)
+	terms := []string{}
+	for _, pos := range ctx.Positions {
+		positionSymbols[pos.Symbol] = true
+		terms = append(terms, pos.Symbol, pos.Side)
+	}
+	candidateSymbols := make(map[string]bool)
+	for _, coin := range ctx.CandidateCoins {
+		candidateSymbols[coin.Symbol] = true
+		terms = append(terms, coin.Symbol)
+	}
+	regime := MarketRegime(ctx.MarketSummary)
+	if s := ctx.MarketSummary; s != nil {
+		terms = append(terms, s.TrendLabel, s.VolatilityLabel, s.LiquidityLabel, s.SuggestedAction,
+			s.TrendLabelCN(), s.VolatilityLabelCN(), s.LiquidityLabelCN())
+		terms = append(terms, s.Notes...)
+	}
+	haystack := strings.ToLower(strings.Join(terms, " "))
+
+	type scored struct {
+		lesson TradeLesson
+		score  int
+	}
+	var candidates []scored
+	for _, lesson := range ctx.Lessons {
+		score := 0
+		switch {
+		case positionSymbols[lesson.Symbol]:
+			score += 3
+		case candidateSymbols[lesson.Symbol]:
+			score += 2
+		}
+		if regime != "" && lesson.Regime != "" {
+			if lesson.Regime == regime {
+				score += 2
+			} else if strings.SplitN(lesson.Regime, "/", 2)[0] == ctx.MarketSummary.TrendLabel {
+				score++
+			}
+		}
+		for _, kw := range lesson.Keywords {
+			if kw = strings.ToLower(strings.TrimSpace(kw)); kw != "" && strings.Contains(haystack, kw) {
+				score++
+			}
+		}
+		if score > 0 {
+			candidates = append(candidates, scored{lesson, score})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		return candidates[i].lesson.CreatedAt.After(candidates[j].lesson.CreatedAt)
+	})
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	result := make([]TradeLesson, len(candidates))
+	for i, c := range candidates {
+		result[i] = c.lesson
+	}
+	return result
+}
+
+// formatLessonLine 单条复盘经验在提示词中的格式
+func formatLessonLine(l TradeLesson) string {
+	line := fmt.Sprintf("- [%s %s", l.Symbol, strings.ToUpper(l.Side))
+	if l.Regime != "" {
+		line += " | " + l.Regime
+	}
+	line += fmt.Sprintf(" | %+.2f%%]", l.PnLPct)
+	if l.Setup != "" {
+		line += " 形态: " + l.Setup + " →"
+	}
+	return line + " " + l.Lesson
+}
+
+// === 平仓复盘 ===
+
+// TradeReflection AI对一笔已平仓交易的复盘结果
+type TradeReflection struct {
+	Setup    string   `json:"setup"`
+	Lesson   string   `json:"lesson"`
+	Keywords []string `json:"keywords"`
+}
+
+const reflectionSystemPrompt = `你是一名交易复盘教练。根据一笔已平仓交易的信息，总结一条简短、可执行的经验教训，供以后的交易决策参考。
+
+只输出一个 JSON 对象，不要输出其他内容：
+{"setup": "入场形态或理由（10字以内）", "lesson": "经验教训（一到两句话，100字以内）", "keywords": ["3-5个关键词，例如 突破、追高、逆势、止损过紧"]}
+
+要求：
+- 亏损交易重点分析失败原因以及下次如何避免，盈利交易总结可以复用的做法
+- 结合市场状态说明经验适用的条件，不要泛泛而谈`
+
+var reJSONObject = regexp.MustCompile(`(?s)\{.*\}`)
+
+// ReflectOnTrade 请AI复盘一笔已平仓交易，regime 为当前市场状态（可为空）
+func ReflectOnTrade(mcpClient mcp.AIClient, trade logger.TradeOutcome, regime string) (*TradeReflection, error) {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("币种: %s | 方向: %s | 杠杆: %dx\n", trade.Symbol, strings.ToUpper(trade.Side), trade.Leverage))
+	sb.WriteString(fmt.Sprintf("开仓: %s @ %.6g → 平仓: %s @ %.6g | 持仓: %s\n",
+		trade.OpenTime.Format("01-02 15:04"), trade.OpenPrice, trade.CloseTime.Format("01-02 15:04"), trade.ClosePrice, trade.Duration))
+	sb.WriteString(fmt.Sprintf("盈亏: %+.2f USDT (%+.2f%%)", trade.PnL, trade.PnLPct))
+	if trade.WasStopLoss {
+		sb.WriteString(" | 止损出场")
+	}
+	sb.WriteString("\n")
+	if regime != "" {
+		sb.WriteString("市场状态（趋势/波动）: " + regime + "\n")
+	}
+
+	response, err := mcpClient.CallWithMessages(reflectionSystemPrompt, sb.String())
+	if err != nil {
+		return nil, fmt.Errorf("调用AI复盘失败: %w", err)
+	}
+	return parseTradeReflection(response)
+}
+
+// parseTradeReflection 解析AI的复盘回复（容忍 JSON 前后的多余文字）
+func parseTradeReflection(response string) (*TradeReflection, error) {
+	match := reJSONObject.FindString(response)
+	if match == "" {
+		return nil, fmt.Errorf("复盘回复中没有 JSON: %s", truncateUTF8(response, 200))
+	}
+	var reflection TradeReflection
+	if err := json.Unmarshal([]byte(match), &reflection); err != nil {
+		return nil, fmt.Errorf("解析复盘回复失败: %w", err)
+	}
+	reflection.Setup = strings.TrimSpace(reflection.Setup)
+	reflection.Lesson = strings.TrimSpace(reflection.Lesson)
+	if reflection.Lesson == "" {
+		return nil, fmt.Errorf("复盘回复缺少 lesson")
+	}
+	if len([]rune(reflection.Lesson)) > maxLessonLength {
+		reflection.Lesson = string([]rune(reflection.Lesson)[:maxLessonLength])
+	}
+	return &reflection, nil
+}
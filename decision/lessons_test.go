@@ -0,0 +1,90 @@
+package decision
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestSelectRelevantLessons 测试按币种、市场状态和关键词的相关度筛选复盘经验
+func TestSelectRelevantLessons(t *testing.T) {
+	now := time.Now()
+	ctx := &Context{
+		Positions:      []PositionInfo{{Symbol: "SOLUSDT", Side: "long"}},
+		CandidateCoins: []CandidateCoin{{Symbol: "ETHUSDT"}},
+		MarketSummary:  &MarketSummary{TrendLabel: "bullish", VolatilityLabel: "high", LiquidityLabel: "normal"},
+		Lessons: []TradeLesson{
+			{ID: 1, Symbol: "XRPUSDT", Lesson: "无关", CreatedAt: now},
+			{ID: 2, Symbol: "ETHUSDT", Regime: "bearish/low", Lesson: "候选币种", CreatedAt: now.Add(-time.Hour)},
+			{ID: 3, Symbol: "SOLUSDT", Regime: "bullish/high", Lesson: "持仓币种且市场状态一致", CreatedAt: now.Add(-2 * time.Hour)},
+			{ID: 4, Symbol: "DOGEUSDT", Regime: "bullish/low", Keywords: []string{"High"}, Lesson: "趋势一致且关键词命中", CreatedAt: now.Add(-3 * time.Hour)},
+			{ID: 5, Symbol: "ETHUSDT", Lesson: "较新的候选币种经验", CreatedAt: now.Add(time.Minute)},
+		},
+	}
+
+	got := SelectRelevantLessons(ctx, 10)
+	ids := make([]int64, len(got))
+	for i, l := range got {
+		ids[i] = l.ID
+	}
+	// 3: 3+2=5; 5: 2（较新）; 2: 2; 4: 1+1=2（最旧）; 1: 0 不入选
+	want := []int64{3, 5, 2, 4}
+	if len(ids) != len(want) {
+		t.Fatalf("筛选结果数量不正确: %v", ids)
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Fatalf("相关度排序不正确: got %v want %v", ids, want)
+		}
+	}
+
+	if got := SelectRelevantLessons(ctx, 2); len(got) != 2 {
+		t.Errorf("应限制条数: %d", len(got))
+	}
+	if got := SelectRelevantLessons(&Context{}, 5); got != nil {
+		t.Errorf("没有经验时应返回 nil")
+	}
+}
+
+// TestUserPrompt_LessonsSection 测试复盘经验写入用户提示词，且可在预算不足时被省略
+func TestUserPrompt_LessonsSection(t *testing.T) {
+	ctx := userPromptTestContext()
+	prompt := buildUserPrompt(ctx)
+	if !strings.Contains(prompt, "- [SOLUSDT LONG | bullish/normal | +12.30%] 形态: 回踩支撑 → 趋势中回踩支撑做多胜率高") {
+		t.Errorf("提示词应包含相关的复盘经验:\n%s", prompt)
+	}
+	if strings.Contains(prompt, "与当前行情无关的经验") {
+		t.Errorf("不相关的复盘经验不应写入提示词")
+	}
+
+	data := buildUserPromptData(ctx)
+	for _, step := range buildDegradeSteps(data) {
+		if strings.HasPrefix(step.section, "lesson:") {
+			step.apply(data)
+		}
+	}
+	if len(data.Lessons) != 0 {
+		t.Errorf("降级后复盘经验应被全部省略: %+v", data.Lessons)
+	}
+}
+
+// TestParseTradeReflection 测试解析AI的复盘回复
+func TestParseTradeReflection(t *testing.T) {
+	r, err := parseTradeReflection("好的，复盘如下：\n```json\n{\"setup\": \"突破追高\", \"lesson\": \"  等回踩确认  \", \"keywords\": [\"突破\", \"追高\"]}\n```")
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if r.Setup != "突破追高" || r.Lesson != "等回踩确认" || len(r.Keywords) != 2 {
+		t.Errorf("解析结果不正确: %+v", r)
+	}
+	if _, err := parseTradeReflection(`{"setup": "x"}`); err == nil {
+		t.Errorf("缺少 lesson 时应返回错误")
+	}
+	if _, err := parseTradeReflection("没有JSON"); err == nil {
+		t.Errorf("没有 JSON 时应返回错误")
+	}
+	long := strings.Repeat("长", maxLessonLength+50)
+	if r, err := parseTradeReflection(`{"lesson": "` + long + `"}`); err != nil || len([]rune(r.Lesson)) != maxLessonLength {
+		t.Errorf("过长的经验应被截断: %v", err)
+	}
+}
@@ -16,6 +16,11 @@ type MarketSummary struct {
 	Notes           []string `json:"notes"`
 }
 
+// AnalyzeMarketSummary 根据上下文中的行情生成全局市场状态（供审批等决策周期之外的风控复核使用）
+func AnalyzeMarketSummary(ctx *Context) *MarketSummary {
+	return analyzeMarketSummary(ctx)
+}
+
 func analyzeMarketSummary(ctx *Context) *MarketSummary {
 	summary := &MarketSummary{
 		TrendLabel:      "unknown",
@@ -61,6 +66,23 @@ func evaluateTrendDirection(data *market.Data) string {
 		return "unknown"
 	}
 
+	score := trendScore(data)
+	switch {
+	case score >= 2:
+		return "strong_bull"
+	case score >= 0.5:
+		return "bull"
+	case score <= -2:
+		return "strong_bear"
+	case score <= -0.5:
+		return "bear"
+	default:
+		return "range"
+	}
+}
+
+// trendScore 趋势打分（-3 ~ +3，正数偏多、负数偏空）
+func trendScore(data *market.Data) float64 {
 	score := 0.0
 	if data.PriceChange4h >= 2 {
 		score += 1.0
@@ -91,19 +113,7 @@ func evaluateTrendDirection(data *market.Data) string {
 	} else if data.CurrentRSI7 <= 35 {
 		score -= 0.5
 	}
-
-	switch {
-	case score >= 2:
-		return "strong_bull"
-	case score >= 0.5:
-		return "bull"
-	case score <= -2:
-		return "strong_bear"
-	case score <= -0.5:
-		return "bear"
-	default:
-		return "range"
-	}
+	return score
 }
 
 func evaluateVolatilityLevel(data *market.Data) string {
@@ -111,6 +121,21 @@ func evaluateVolatilityLevel(data *market.Data) string {
 		return "unknown"
 	}
 
+	ratio := volatilityRatio(data)
+	switch {
+	case ratio >= 1.8:
+		return "extreme"
+	case ratio >= 1.3:
+		return "high"
+	case ratio <= 0.7:
+		return "low"
+	default:
+		return "normal"
+	}
+}
+
+// volatilityRatio 短周期ATR相对4小时ATR的比值（缺少4小时ATR时与1%价格比较，数据不足时为1）
+func volatilityRatio(data *market.Data) float64 {
 	atrFast := 0.0
 	if data.IntradaySeries != nil {
 		atrFast = data.IntradaySeries.ATR14
@@ -128,21 +153,28 @@ func evaluateVolatilityLevel(data *market.Data) string {
 	case atrFast > 0 && data.CurrentPrice > 0:
 		ratio = atrFast / (data.CurrentPrice * 0.01) // 与1%价格比较
 	}
-
-	switch {
-	case ratio >= 1.8:
-		return "extreme"
-	case ratio >= 1.3:
-		return "high"
-	case ratio <= 0.7:
-		return "low"
-	default:
-		return "normal"
-	}
+	return ratio
 }
 
 func evaluateLiquidityLevel(data *market.Data) string {
-	if data == nil || data.LongerTermContext == nil {
+	if data == nil {
+		return "unknown"
+	}
+
+	// 优先使用盘口深度：价差与计划仓位的预估滑点
+	if ob := data.OrderBook; ob != nil && ob.SizeUSD > 0 {
+		slippage := math.Max(ob.BuySlippageBps, ob.SellSlippageBps)
+		switch {
+		case ob.Unfillable || ob.SpreadBps >= 5 || slippage >= 10:
+			return "low"
+		case ob.SpreadBps <= 1 && slippage <= 2:
+			return "high"
+		default:
+			return "normal"
+		}
+	}
+
+	if data.LongerTermContext == nil {
 		return "unknown"
 	}
 
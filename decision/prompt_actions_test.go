@@ -9,7 +9,7 @@ import (
 // This test verifies fix for issue #982/#984
 func TestPromptContainsAllValidActions(t *testing.T) {
 	// Generate the prompt
-	prompt := buildSystemPrompt(100.0, 5, 5, "default")
+	prompt := buildSystemPrompt(100.0, 5, 5, "", "default")
 
 	// Define all 9 valid actions that must be present in the prompt
 	validActions := []string{
@@ -114,7 +114,7 @@ func TestValidateDecisionAcceptsAllActions(t *testing.T) {
 
 // TestPromptAndValidationInSync verifies that prompt and validation use the same action set
 func TestPromptAndValidationInSync(t *testing.T) {
-	prompt := buildSystemPrompt(100.0, 5, 5, "default")
+	prompt := buildSystemPrompt(100.0, 5, 5, "", "default")
 
 	// Expected actions from validateDecision
 	expectedActions := []string{
@@ -0,0 +1,235 @@
+package decision
+
+import (
+	"fmt"
+	"log"
+	"nofx/mcp"
+)
+
+// PromptUsage 提示词 token 用量与压缩情况（写入决策日志）
+type PromptUsage struct {
+	Provider        string   `json:"provider"`
+	Budget          int      `json:"budget"`
+	SystemTokens    int      `json:"system_tokens"`
+	UserTokens      int      `json:"user_tokens"`
+	TotalTokens     int      `json:"total_tokens"`
+	DroppedSections []string `json:"dropped_sections,omitempty"` // 被降级或省略的段落，例如 "candidate:XRPUSDT:patterns"
+	OverBudget      bool     `json:"over_budget,omitempty"`      // 压缩到底仍超出预算
+}
+
+// promptBudgetFor 交易员配置的预算（<=0 时使用服务商默认预算）
+func promptBudgetFor(ctx *Context) int {
+	if ctx.PromptTokenBudget > 0 {
+		return ctx.PromptTokenBudget
+	}
+	return mcp.DefaultPromptTokenBudget(ctx.AIProvider)
+}
+
+// degradeStep 一次降级操作，返回 false 表示没有可降级的内容（跳过，不记录）
+type degradeStep struct {
+	section string
+	apply   func(d *UserPromptData) bool
+}
+
+// buildDegradeSteps 按优先级从低到高生成降级步骤
+// 优先级：持仓 > BTC > 排名靠前的候选币种 > 排名靠后的候选币种，
+// 同一层级内先去掉K线可视化，再把形态分析和完整行情压缩为一行摘要，最后整体省略；
+// 历史复盘经验在省略候选币种之前按相关度从低到高省略
+func buildDegradeSteps(d *UserPromptData) []degradeStep {
+	var steps []degradeStep
+
+	for i := len(d.Candidates) - 1; i >= 0; i-- {
+		symbol := d.Candidates[i].Symbol
+		steps = append(steps, degradeStep{"candidate:" + symbol + ":visualization", func(d *UserPromptData) bool {
+			return stripVisualizations(candidateMarket(d, symbol))
+		}})
+	}
+	steps = append(steps, degradeStep{"btc:visualization", func(d *UserPromptData) bool {
+		return stripVisualizations(d.BTC)
+	}})
+	for i := len(d.Candidates) - 1; i >= 0; i-- {
+		symbol := d.Candidates[i].Symbol
+		steps = append(steps, degradeStep{"candidate:" + symbol + ":patterns", func(d *UserPromptData) bool {
+			return stripPatterns(candidateMarket(d, symbol))
+		}})
+	}
+	for i := len(d.Candidates) - 1; i >= 0; i-- {
+		symbol := d.Candidates[i].Symbol
+		steps = append(steps, degradeStep{"candidate:" + symbol + ":market", func(d *UserPromptData) bool {
+			view := candidateMarket(d, symbol)
+			if view == nil || (view.Market == "" && view.Indicators == "") {
+				return false
+			}
+			view.Market, view.Indicators = "", ""
+			return true
+		}})
+	}
+	for i := len(d.Lessons) - 1; i >= 0; i-- {
+		steps = append(steps, degradeStep{fmt.Sprintf("lesson:%d", d.Lessons[i].ID), func(d *UserPromptData) bool {
+			if len(d.Lessons) == 0 {
+				return false
+			}
+			d.Lessons = d.Lessons[:len(d.Lessons)-1]
+			return true
+		}})
+	}
+	for i := len(d.Candidates) - 1; i >= 0; i-- {
+		symbol := d.Candidates[i].Symbol
+		steps = append(steps, degradeStep{"candidate:" + symbol, func(d *UserPromptData) bool {
+			for j := range d.Candidates {
+				if d.Candidates[j].Symbol == symbol {
+					d.Candidates = append(d.Candidates[:j], d.Candidates[j+1:]...)
+					d.OmittedCandidates = append([]string{symbol}, d.OmittedCandidates...)
+					d.MarketDataCount--
+					return true
+				}
+			}
+			return false
+		}})
+	}
+	steps = append(steps, degradeStep{"btc:patterns", func(d *UserPromptData) bool {
+		return stripPatterns(d.BTC)
+	}})
+	for i := len(d.Positions) - 1; i >= 0; i-- {
+		symbol := d.Positions[i].Symbol
+		steps = append(steps, degradeStep{"position:" + symbol + ":visualization", func(d *UserPromptData) bool {
+			return stripVisualizations(positionMarket(d, symbol))
+		}})
+	}
+	for i := len(d.Positions) - 1; i >= 0; i-- {
+		symbol := d.Positions[i].Symbol
+		steps = append(steps, degradeStep{"position:" + symbol + ":patterns", func(d *UserPromptData) bool {
+			return stripPatterns(positionMarket(d, symbol))
+		}})
+	}
+	return steps
+}
+
+func candidateMarket(d *UserPromptData, symbol string) *SymbolView {
+	for _, c := range d.Candidates {
+		if c.Symbol == symbol {
+			return c.Market
+		}
+	}
+	return nil
+}
+
+func positionMarket(d *UserPromptData, symbol string) *SymbolView {
+	for _, p := range d.Positions {
+		if p.Symbol == symbol {
+			return p.Market
+		}
+	}
+	return nil
+}
+
+func stripVisualizations(view *SymbolView) bool {
+	if view == nil {
+		return false
+	}
+	stripped := false
+	for i := range view.Patterns {
+		if view.Patterns[i].Visualization != "" {
+			view.Patterns[i].Visualization = ""
+			stripped = true
+		}
+	}
+	return stripped
+}
+
+func stripPatterns(view *SymbolView) bool {
+	if view == nil || (len(view.Patterns) == 0 && view.LegacyPattern == "") {
+		return false
+	}
+	view.Patterns, view.LegacyPattern = nil, ""
+	return true
+}
+
+// renderWithinBudget 渲染模板，超出预算时按优先级逐步降级直到满足预算
+func renderWithinBudget(t *UserPromptTemplate, data *UserPromptData, usage *PromptUsage) (string, error) {
+	prompt, err := t.execute(data)
+	if err != nil {
+		return "", err
+	}
+	usage.UserTokens = mcp.EstimateTokens(usage.Provider, prompt)
+	limit := usage.Budget - usage.SystemTokens
+	if usage.UserTokens <= limit {
+		return prompt, nil
+	}
+
+	for _, step := range buildDegradeSteps(data) {
+		if !step.apply(data) {
+			continue
+		}
+		usage.DroppedSections = append(usage.DroppedSections, step.section)
+		if prompt, err = t.execute(data); err != nil {
+			return "", err
+		}
+		usage.UserTokens = mcp.EstimateTokens(usage.Provider, prompt)
+		if usage.UserTokens <= limit {
+			return prompt, nil
+		}
+	}
+	usage.OverBudget = true
+	return prompt, nil
+}
+
+// newPromptUsage 按交易上下文的服务商与预算初始化用量统计
+func newPromptUsage(ctx *Context, systemPrompt string) *PromptUsage {
+	usage := &PromptUsage{
+		Provider: ctx.AIProvider,
+		Budget:   promptBudgetFor(ctx),
+	}
+	usage.SystemTokens = mcp.EstimateTokens(usage.Provider, systemPrompt)
+	return usage
+}
+
+// RenderWithinBudget 在交易员的 token 预算内渲染模板（用于预览未保存的模板草稿）
+func (t *UserPromptTemplate) RenderWithinBudget(ctx *Context, systemPrompt string) (string, *PromptUsage, error) {
+	usage := newPromptUsage(ctx, systemPrompt)
+	prompt, err := renderWithinBudget(t, buildUserPromptData(ctx), usage)
+	if err != nil {
+		return "", nil, err
+	}
+	usage.TotalTokens = usage.SystemTokens + usage.UserTokens
+	return prompt, usage, nil
+}
+
+// renderUserPromptWithBudget 在 token 预算内渲染用户提示词，并把用量记录到 ctx.PromptUsage
+// 模板缺失或渲染失败时回退到内置格式
+func renderUserPromptWithBudget(ctx *Context, systemPrompt string) string {
+	usage := newPromptUsage(ctx, systemPrompt)
+
+	name := ctx.UserPromptTemplate
+	if name == "" {
+		name = DefaultUserPromptTemplate
+	}
+	var prompt string
+	tmpl, err := GetUserPromptTemplate(name)
+	if err == nil {
+		prompt, err = renderWithinBudget(tmpl, buildUserPromptData(ctx), usage)
+	}
+	if err != nil {
+		// 内置格式不支持分段降级，只统计用量
+		log.Printf("⚠️  %v，使用内置用户提示词格式", err)
+		prompt = buildUserPrompt(ctx)
+		usage.DroppedSections = nil
+		usage.UserTokens = mcp.EstimateTokens(usage.Provider, prompt)
+		usage.OverBudget = usage.SystemTokens+usage.UserTokens > usage.Budget
+	}
+
+	usage.TotalTokens = usage.SystemTokens + usage.UserTokens
+	ctx.PromptUsage = usage
+	if len(usage.DroppedSections) > 0 || usage.OverBudget {
+		log.Printf("✂️  提示词压缩: %d/%d tokens，降级%d个段落%s",
+			usage.TotalTokens, usage.Budget, len(usage.DroppedSections), overBudgetNote(usage.OverBudget))
+	}
+	return prompt
+}
+
+func overBudgetNote(over bool) string {
+	if over {
+		return "（⚠️ 仍超出预算）"
+	}
+	return ""
+}
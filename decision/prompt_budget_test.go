@@ -0,0 +1,79 @@
+package decision
+
+import (
+	"fmt"
+	"nofx/market"
+	"nofx/mcp"
+	"strings"
+	"testing"
+)
+
+// budgetTestContext 在基础上下文上追加若干带形态分析的候选币种
+func budgetTestContext() *Context {
+	ctx := userPromptTestContext()
+	for i := 1; i <= 5; i++ {
+		symbol := fmt.Sprintf("ALT%dUSDT", i)
+		ctx.CandidateCoins = append(ctx.CandidateCoins, CandidateCoin{Symbol: symbol, Sources: []string{"ai500"}})
+		ctx.MarketDataMap[symbol] = &market.Data{Symbol: symbol, CurrentPrice: float64(i), CurrentRSI7: 50}
+		ctx.MultiTimeframeAnalysisMap[symbol] = map[string]*PatternAnalysis{
+			"30m": {Symbol: symbol, Interval: "30m", Summary: "上涨", Recommendation: "关注突破"},
+		}
+	}
+	return ctx
+}
+
+func TestRenderWithinBudget(t *testing.T) {
+	tmpl := loadTestUserPrompt(t, DefaultUserPromptTemplate)
+	ctx := budgetTestContext()
+	full, err := tmpl.Render(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fullTokens := mcp.EstimateTokens("", full)
+
+	t.Run("within budget", func(t *testing.T) {
+		usage := &PromptUsage{Budget: fullTokens}
+		got, err := renderWithinBudget(tmpl, buildUserPromptData(ctx), usage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != full || len(usage.DroppedSections) != 0 || usage.OverBudget {
+			t.Errorf("预算充足时不应压缩: %+v", usage)
+		}
+	})
+
+	t.Run("lowest ranked candidate degraded first", func(t *testing.T) {
+		usage := &PromptUsage{Budget: fullTokens - 1}
+		got, err := renderWithinBudget(tmpl, buildUserPromptData(ctx), usage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(usage.DroppedSections) != 1 || usage.DroppedSections[0] != "candidate:ALT5USDT:patterns" {
+			t.Errorf("应先压缩排名最后的候选币种形态分析: %v", usage.DroppedSections)
+		}
+		if usage.UserTokens > usage.Budget || strings.Count(got, "关注突破") != 4 {
+			t.Errorf("压缩结果错误: %d tokens", usage.UserTokens)
+		}
+	})
+
+	t.Run("positions kept when budget exhausted", func(t *testing.T) {
+		usage := &PromptUsage{Budget: 1}
+		got, err := renderWithinBudget(tmpl, buildUserPromptData(ctx), usage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !usage.OverBudget {
+			t.Error("预算无法满足时应标记 OverBudget")
+		}
+		if !strings.Contains(got, "1. SOLUSDT LONG | 入场价") || !strings.Contains(got, "SOLUSDT: 150.25") {
+			t.Errorf("持仓信息不应被删除:\n%s", got)
+		}
+		if strings.Contains(got, "### 1. ETHUSDT") || !strings.Contains(got, "ETHUSDT, ALT1USDT, ALT2USDT, ALT3USDT, ALT4USDT, ALT5USDT") {
+			t.Errorf("候选币种应全部省略并按排名列出:\n%s", got)
+		}
+		last := usage.DroppedSections[len(usage.DroppedSections)-1]
+		if last != "position:SOLUSDT:patterns" {
+			t.Errorf("持仓形态分析应最后被压缩: %v", usage.DroppedSections)
+		}
+	})
+}
@@ -16,6 +16,8 @@ type PromptTemplate struct {
 	Content     string            // 模板内容
 	DisplayName map[string]string // 显示名称（多语言）{"zh": "中文名", "en": "English Name"}
 	Description map[string]string // 描述（多语言）
+	Version     string            // 内容版本哈希（见 PromptVersionHash）
+	Owner       string            // 所属用户ID（为空表示系统内置模板，只读）
 }
 
 // TemplateMetadata 模板元数据配置
@@ -108,6 +110,7 @@ func (pm *PromptManager) LoadTemplates(dir string) error {
 		template := &PromptTemplate{
 			Name:    templateName,
 			Content: string(content),
+			Version: PromptVersionHash(string(content)),
 		}
 
 		// 如果有配置元数据，填充显示名称和描述
@@ -205,28 +208,10 @@ func ReloadPromptTemplates() error {
 	return globalPromptManager.ReloadTemplates(promptsDir)
 }
 
-// SavePromptTemplate 保存提示词模板到文件并重新加载
+// SavePromptTemplate 保存提示词模板到文件并重新加载（记录为未署名的新版本）
 func SavePromptTemplate(name, content string) error {
-	if name == "" {
-		return fmt.Errorf("模板名称不能为空")
-	}
-	if content == "" {
-		return fmt.Errorf("模板内容不能为空")
-	}
-
-	// 保存到文件
-	filePath := filepath.Join(promptsDir, name+".txt")
-	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
-		return fmt.Errorf("保存模板文件失败: %w", err)
-	}
-
-	// 重新加载模板
-	if err := ReloadPromptTemplates(); err != nil {
-		log.Printf("⚠️  保存成功但重新加载失败: %v", err)
-	}
-
-	log.Printf("✓ 已保存提示词模板: %s", name)
-	return nil
+	_, err := SavePromptTemplateVersion(name, content, "", "")
+	return err
 }
 
 // DeletePromptTemplate 删除提示词模板文件并重新加载
@@ -240,6 +225,14 @@ func DeletePromptTemplate(name string) error {
 		return fmt.Errorf("不能删除系统模板: default")
 	}
 
+	// 删除前确保当前内容已记录到版本历史，删除后仍可通过回滚恢复
+	promptVersionMu.Lock()
+	_, err := ensureBaselineVersion(name)
+	promptVersionMu.Unlock()
+	if err != nil {
+		return err
+	}
+
 	// 删除文件
 	filePath := filepath.Join(promptsDir, name+".txt")
 	if err := os.Remove(filePath); err != nil {
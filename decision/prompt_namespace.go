@@ -0,0 +1,66 @@
+package decision
+
+import (
+	"log"
+	"sync"
+)
+
+// PromptTemplateStore 用户自建系统提示词模板的存储（由数据库实现，按用户隔离）
+type PromptTemplateStore interface {
+	GetPromptTemplateContent(userID, name string) (string, error)
+}
+
+var (
+	promptTemplateStore   PromptTemplateStore
+	promptTemplateStoreMu sync.RWMutex
+)
+
+// SetPromptTemplateStore 设置用户模板存储（nil 表示只使用系统内置模板）
+func SetPromptTemplateStore(store PromptTemplateStore) {
+	promptTemplateStoreMu.Lock()
+	defer promptTemplateStoreMu.Unlock()
+	promptTemplateStore = store
+}
+
+func getPromptTemplateStore() PromptTemplateStore {
+	promptTemplateStoreMu.RLock()
+	defer promptTemplateStoreMu.RUnlock()
+	return promptTemplateStore
+}
+
+// ResolvePromptTemplate 在用户的命名空间中解析系统提示词模板
+// 先查找用户自己的模板，找不到时使用系统内置模板；其他用户共享的模板需要先复制到自己的命名空间
+func ResolvePromptTemplate(ownerID, name string) (*PromptTemplate, error) {
+	if store := getPromptTemplateStore(); store != nil && ownerID != "" {
+		if content, err := store.GetPromptTemplateContent(ownerID, name); err == nil {
+			return &PromptTemplate{
+				Name:        name,
+				Content:     content,
+				DisplayName: map[string]string{"zh": name, "en": name},
+				Description: map[string]string{"zh": "", "en": ""},
+				Version:     PromptVersionHash(content),
+				Owner:       ownerID,
+			}, nil
+		}
+	}
+	return GetPromptTemplate(name)
+}
+
+// TemplateExistsFor 检查模板在用户的命名空间中是否存在（包括系统内置模板）
+func TemplateExistsFor(ownerID, name string) bool {
+	_, err := ResolvePromptTemplate(ownerID, name)
+	return err == nil
+}
+
+// lookupSystemPromptTemplate 解析交易员使用的模板，不存在时回退到 default
+func lookupSystemPromptTemplate(ownerID, name string) (*PromptTemplate, error) {
+	if name == "" {
+		name = "default"
+	}
+	template, err := ResolvePromptTemplate(ownerID, name)
+	if err != nil {
+		log.Printf("⚠️  提示词模板 '%s' 不存在，使用 default: %v", name, err)
+		return GetPromptTemplate("default")
+	}
+	return template, nil
+}
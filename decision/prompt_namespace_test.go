@@ -0,0 +1,59 @@
+package decision
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakePromptTemplateStore 按 userID/name 存放用户模板
+type fakePromptTemplateStore map[string]string
+
+func (f fakePromptTemplateStore) GetPromptTemplateContent(userID, name string) (string, error) {
+	if content, ok := f[userID+"/"+name]; ok {
+		return content, nil
+	}
+	return "", fmt.Errorf("模板不存在: %s", name)
+}
+
+// TestResolvePromptTemplate_Namespace 测试模板按交易员所属用户解析，用户之间相互隔离
+func TestResolvePromptTemplate_Namespace(t *testing.T) {
+	originalDir := promptsDir
+	defer func() {
+		promptsDir = originalDir
+		globalPromptManager.ReloadTemplates(originalDir)
+	}()
+	promptsDir = t.TempDir()
+	if err := os.WriteFile(filepath.Join(promptsDir, "default.txt"), []byte("系统默认策略"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReloadPromptTemplates(); err != nil {
+		t.Fatal(err)
+	}
+
+	SetPromptTemplateStore(fakePromptTemplateStore{"alice/scalp": "ALICE 剥头皮策略"})
+	defer SetPromptTemplateStore(nil)
+
+	tmpl, err := ResolvePromptTemplate("alice", "scalp")
+	if err != nil || tmpl.Owner != "alice" || tmpl.Version != PromptVersionHash("ALICE 剥头皮策略") {
+		t.Fatalf("应解析到 alice 的模板: %+v %v", tmpl, err)
+	}
+	if TemplateExistsFor("bob", "scalp") {
+		t.Errorf("其他用户不应看到 alice 的模板")
+	}
+	if !TemplateExistsFor("alice", "default") || !TemplateExistsFor("", "default") {
+		t.Errorf("系统内置模板对所有用户可见")
+	}
+
+	if prompt := buildSystemPrompt(1000, 5, 3, "alice", "scalp"); !strings.HasPrefix(prompt, "ALICE 剥头皮策略") {
+		t.Errorf("alice 的交易员应使用自己的模板")
+	}
+	if prompt := buildSystemPrompt(1000, 5, 3, "bob", "scalp"); !strings.HasPrefix(prompt, "系统默认策略") {
+		t.Errorf("bob 的交易员不应使用 alice 的模板，应回退到 default")
+	}
+	if _, name, version := buildVersionedSystemPrompt(1000, 5, 3, "", false, "alice", "scalp"); name != "scalp" || version != PromptVersionHash("ALICE 剥头皮策略") {
+		t.Errorf("决策记录应标记用户模板版本: %s@%s", name, version)
+	}
+}
@@ -0,0 +1,320 @@
+package decision
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// promptVersionsSubdir 模板版本历史目录（位于 promptsDir 下，每个模板一个只追加的 .jsonl 文件）
+const promptVersionsSubdir = "versions"
+
+// promptVersionHashLen 版本哈希长度（sha256 前12位十六进制）
+const promptVersionHashLen = 12
+
+// PromptTemplateVersion 系统提示词模板的一个不可变版本
+type PromptTemplateVersion struct {
+	Template  string    `json:"template"`
+	Hash      string    `json:"hash"`   // 内容哈希，同样的内容始终得到同样的版本号
+	Author    string    `json:"author"` // 修改人（邮箱或用户ID，"system" 表示迁移前已有的内容）
+	Note      string    `json:"note"`   // 修改说明
+	CreatedAt time.Time `json:"created_at"`
+	Content   string    `json:"content,omitempty"`
+}
+
+// PromptDiffLine 版本对比的一行（Op: "=" 未变, "-" 删除, "+" 新增）
+type PromptDiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// promptVersionMu 串行化模板保存与版本追加，保证文件内容与版本历史一致
+var promptVersionMu sync.Mutex
+
+// PromptVersionHash 计算模板内容的版本哈希
+func PromptVersionHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])[:promptVersionHashLen]
+}
+
+func promptVersionsPath(name string) string {
+	return filepath.Join(promptsDir, promptVersionsSubdir, name+".jsonl")
+}
+
+// readPromptVersions 读取模板的全部版本（按时间从旧到新）
+func readPromptVersions(name string) ([]*PromptTemplateVersion, error) {
+	f, err := os.Open(promptVersionsPath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("读取模板版本历史失败: %w", err)
+	}
+	defer f.Close()
+
+	var versions []*PromptTemplateVersion
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var v PromptTemplateVersion
+		if err := json.Unmarshal([]byte(line), &v); err != nil {
+			log.Printf("⚠️  跳过损坏的模板版本记录 (%s): %v", name, err)
+			continue
+		}
+		versions = append(versions, &v)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取模板版本历史失败: %w", err)
+	}
+	return versions, nil
+}
+
+// appendPromptVersion 追加一个版本记录（历史文件只追加，不修改已有记录）
+func appendPromptVersion(v *PromptTemplateVersion) error {
+	path := promptVersionsPath(v.Template)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("创建版本目录失败: %w", err)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("写入模板版本失败: %w", err)
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// ensureBaselineVersion 确保模板文件的当前内容已记录在版本历史中
+// 无历史时记为初始版本；文件在磁盘上被直接修改过时补记一个版本，避免覆盖后丢失
+func ensureBaselineVersion(name string) ([]*PromptTemplateVersion, error) {
+	versions, err := readPromptVersions(name)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(filepath.Join(promptsDir, name+".txt"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return versions, nil
+		}
+		return nil, fmt.Errorf("读取模板文件失败: %w", err)
+	}
+	hash := PromptVersionHash(string(content))
+	if n := len(versions); n > 0 && versions[n-1].Hash == hash {
+		return versions, nil
+	}
+
+	note := "初始版本"
+	if len(versions) > 0 {
+		note = "文件被直接修改"
+	}
+	baseline := &PromptTemplateVersion{
+		Template:  name,
+		Hash:      hash,
+		Author:    "system",
+		Note:      note,
+		CreatedAt: time.Now(),
+		Content:   string(content),
+	}
+	if err := appendPromptVersion(baseline); err != nil {
+		return nil, err
+	}
+	return append(versions, baseline), nil
+}
+
+// SavePromptTemplateVersion 保存模板内容并记录为新版本
+// 内容与最新版本相同时不重复记录，直接返回最新版本
+func SavePromptTemplateVersion(name, content, author, note string) (*PromptTemplateVersion, error) {
+	if name == "" {
+		return nil, fmt.Errorf("模板名称不能为空")
+	}
+	if content == "" {
+		return nil, fmt.Errorf("模板内容不能为空")
+	}
+
+	promptVersionMu.Lock()
+	versions, err := ensureBaselineVersion(name)
+	if err != nil {
+		promptVersionMu.Unlock()
+		return nil, err
+	}
+
+	filePath := filepath.Join(promptsDir, name+".txt")
+	if err := os.WriteFile(filePath, []byte(content), 0644); err != nil {
+		promptVersionMu.Unlock()
+		return nil, fmt.Errorf("保存模板文件失败: %w", err)
+	}
+
+	hash := PromptVersionHash(content)
+	version := &PromptTemplateVersion{
+		Template:  name,
+		Hash:      hash,
+		Author:    author,
+		Note:      note,
+		CreatedAt: time.Now(),
+		Content:   content,
+	}
+	if n := len(versions); n > 0 && versions[n-1].Hash == hash {
+		version = versions[n-1]
+	} else if err := appendPromptVersion(version); err != nil {
+		promptVersionMu.Unlock()
+		return nil, err
+	}
+	promptVersionMu.Unlock()
+
+	if err := ReloadPromptTemplates(); err != nil {
+		log.Printf("⚠️  保存成功但重新加载失败: %v", err)
+	}
+	log.Printf("✓ 已保存提示词模板: %s (版本 %s)", name, version.Hash)
+	return version, nil
+}
+
+// ListPromptTemplateVersions 获取模板的版本历史（按时间从新到旧，不含内容）
+func ListPromptTemplateVersions(name string) ([]*PromptTemplateVersion, error) {
+	promptVersionMu.Lock()
+	versions, err := ensureBaselineVersion(name)
+	promptVersionMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("模板不存在: %s", name)
+	}
+
+	result := make([]*PromptTemplateVersion, 0, len(versions))
+	for i := len(versions) - 1; i >= 0; i-- {
+		v := *versions[i]
+		v.Content = ""
+		result = append(result, &v)
+	}
+	return result, nil
+}
+
+// GetPromptTemplateVersion 获取指定版本（hash 支持前缀匹配，多次出现时取最新一条）
+func GetPromptTemplateVersion(name, hash string) (*PromptTemplateVersion, error) {
+	if hash == "" {
+		return nil, fmt.Errorf("版本号不能为空")
+	}
+	promptVersionMu.Lock()
+	versions, err := ensureBaselineVersion(name)
+	promptVersionMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	var found *PromptTemplateVersion
+	for i := len(versions) - 1; i >= 0; i-- {
+		if !strings.HasPrefix(versions[i].Hash, hash) {
+			continue
+		}
+		if found != nil && found.Hash != versions[i].Hash {
+			return nil, fmt.Errorf("版本号 %s 不唯一，请提供更长的前缀", hash)
+		}
+		if found == nil {
+			found = versions[i]
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("模板 %s 不存在版本: %s", name, hash)
+	}
+	return found, nil
+}
+
+// RollbackPromptTemplate 回滚模板到指定版本（作为新版本记录，历史不会被改写；已删除的模板也可恢复）
+func RollbackPromptTemplate(name, hash, author, note string) (*PromptTemplateVersion, error) {
+	target, err := GetPromptTemplateVersion(name, hash)
+	if err != nil {
+		return nil, err
+	}
+	if note == "" {
+		note = fmt.Sprintf("回滚到版本 %s", target.Hash)
+	}
+	return SavePromptTemplateVersion(name, target.Content, author, note)
+}
+
+// DiffPromptTemplateVersions 对比两个版本的内容（to 为空时与当前内容对比）
+func DiffPromptTemplateVersions(name, from, to string) ([]PromptDiffLine, error) {
+	fromVersion, err := GetPromptTemplateVersion(name, from)
+	if err != nil {
+		return nil, err
+	}
+	var toContent string
+	if to == "" {
+		current, err := GetPromptTemplate(name)
+		if err != nil {
+			return nil, err
+		}
+		toContent = current.Content
+	} else {
+		toVersion, err := GetPromptTemplateVersion(name, to)
+		if err != nil {
+			return nil, err
+		}
+		toContent = toVersion.Content
+	}
+	return diffLines(fromVersion.Content, toContent), nil
+}
+
+// DiffPromptContents 逐行对比两段模板内容（用户模板的版本对比）
+func DiffPromptContents(from, to string) []PromptDiffLine {
+	return diffLines(from, to)
+}
+
+// diffLines 基于最长公共子序列的逐行对比
+func diffLines(a, b string) []PromptDiffLine {
+	al, bl := strings.Split(a, "\n"), strings.Split(b, "\n")
+
+	// lcs[i][j] = al[i:] 与 bl[j:] 的最长公共子序列长度
+	lcs := make([][]int, len(al)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bl)+1)
+	}
+	for i := len(al) - 1; i >= 0; i-- {
+		for j := len(bl) - 1; j >= 0; j-- {
+			if al[i] == bl[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out []PromptDiffLine
+	i, j := 0, 0
+	for i < len(al) && j < len(bl) {
+		switch {
+		case al[i] == bl[j]:
+			out = append(out, PromptDiffLine{Op: "=", Text: al[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, PromptDiffLine{Op: "-", Text: al[i]})
+			i++
+		default:
+			out = append(out, PromptDiffLine{Op: "+", Text: bl[j]})
+			j++
+		}
+	}
+	for ; i < len(al); i++ {
+		out = append(out, PromptDiffLine{Op: "-", Text: al[i]})
+	}
+	for ; j < len(bl); j++ {
+		out = append(out, PromptDiffLine{Op: "+", Text: bl[j]})
+	}
+	return out
+}
@@ -0,0 +1,114 @@
+package decision
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestPromptTemplateVersions 保存、历史、对比、回滚与删除后恢复的完整流程
+func TestPromptTemplateVersions(t *testing.T) {
+	originalDir := promptsDir
+	defer func() {
+		promptsDir = originalDir
+		globalPromptManager.ReloadTemplates(originalDir)
+	}()
+	tempDir := t.TempDir()
+	promptsDir = tempDir
+
+	// 版本化之前就存在的模板：第一次保存时应补记初始版本
+	v0Content := "规则A\n规则B"
+	if err := os.WriteFile(filepath.Join(tempDir, "strategy.txt"), []byte(v0Content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	v1, err := SavePromptTemplateVersion("strategy", "规则A\n规则C\n规则D", "alice@example.com", "收紧止损")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := SavePromptTemplateVersion("strategy", "规则A\n规则C\n规则D", "bob@example.com", ""); again.Hash != v1.Hash || again.Author != "alice@example.com" {
+		t.Error("内容未变化时不应记录新版本")
+	}
+
+	versions, err := ListPromptTemplateVersions("strategy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || versions[0].Hash != v1.Hash || versions[1].Author != "system" || versions[1].Hash != PromptVersionHash(v0Content) {
+		t.Fatalf("版本历史错误: %+v", versions)
+	}
+	if versions[0].Content != "" {
+		t.Error("版本列表不应包含内容")
+	}
+	if tmpl, _ := GetPromptTemplate("strategy"); tmpl.Version != v1.Hash {
+		t.Errorf("当前模板版本应为 %s, got %s", v1.Hash, tmpl.Version)
+	}
+
+	lines, err := DiffPromptTemplateVersions("strategy", versions[1].Hash, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PromptDiffLine{{"=", "规则A"}, {"-", "规则B"}, {"+", "规则C"}, {"+", "规则D"}}
+	if len(lines) != len(want) {
+		t.Fatalf("diff 行数错误: %+v", lines)
+	}
+	for i := range want {
+		if lines[i] != want[i] {
+			t.Errorf("diff[%d] = %+v, want %+v", i, lines[i], want[i])
+		}
+	}
+
+	// 回滚作为新版本记录，历史不被改写
+	rolled, err := RollbackPromptTemplate("strategy", versions[1].Hash[:6], "alice@example.com", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rolled.Hash != versions[1].Hash || rolled.Note == "" {
+		t.Errorf("回滚版本错误: %+v", rolled)
+	}
+	if tmpl, _ := GetPromptTemplate("strategy"); tmpl.Content != v0Content {
+		t.Errorf("回滚后内容错误: %q", tmpl.Content)
+	}
+	if versions, _ = ListPromptTemplateVersions("strategy"); len(versions) != 3 {
+		t.Errorf("回滚应追加版本记录, got %d", len(versions))
+	}
+
+	// 直接修改磁盘文件后再保存，外部修改也要留在历史中
+	if err := os.WriteFile(filepath.Join(tempDir, "strategy.txt"), []byte("手工修改"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := SavePromptTemplateVersion("strategy", "规则E", "alice@example.com", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetPromptTemplateVersion("strategy", PromptVersionHash("手工修改")); err != nil {
+		t.Errorf("外部修改应被补记为版本: %v", err)
+	}
+
+	// 删除后仍可从历史恢复
+	if err := DeletePromptTemplate("strategy"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RollbackPromptTemplate("strategy", v1.Hash, "alice@example.com", "恢复"); err != nil {
+		t.Fatal(err)
+	}
+	if tmpl, err := GetPromptTemplate("strategy"); err != nil || tmpl.Version != v1.Hash {
+		t.Errorf("删除后回滚应恢复模板: %v", err)
+	}
+
+	if _, name, version := buildVersionedSystemPrompt(1000, 10, 5, "", false, "", "strategy"); name != "strategy" || version != v1.Hash {
+		t.Errorf("buildVersionedSystemPrompt = %s@%s", name, version)
+	}
+	if _, name, version := buildVersionedSystemPrompt(1000, 10, 5, "只用自定义", true, "", "strategy"); name != "custom" || version != PromptVersionHash("只用自定义") {
+		t.Errorf("覆盖基础prompt时应记为 custom: %s@%s", name, version)
+	}
+
+	// 附加个性化策略时版本同时覆盖模板与自定义提示词，不同自定义提示词的版本不同
+	prompt, name, withA := buildVersionedSystemPrompt(1000, 10, 5, "只做BTC", false, "", "strategy")
+	_, _, withB := buildVersionedSystemPrompt(1000, 10, 5, "只做ETH", false, "", "strategy")
+	if name != "strategy" || withA != v1.Hash+"+"+PromptVersionHash("只做BTC") || withA == withB {
+		t.Errorf("附加个性化策略时版本应包含自定义提示词哈希: %s %s", withA, withB)
+	}
+	if !strings.Contains(prompt, "只做BTC") {
+		t.Errorf("提示词应包含个性化策略")
+	}
+}
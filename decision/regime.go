@@ -0,0 +1,308 @@
+package decision
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 市场状态（由 BTC 走势与市场广度识别）
+const (
+	RegimeUnknown        = "unknown"
+	RegimeTrending       = "trending"        // 趋势行情
+	RegimeRanging        = "ranging"         // 震荡行情
+	RegimeHighVolatility = "high_volatility" // 高波动
+	RegimeRiskOff        = "risk_off"        // 普跌避险
+)
+
+// ValidRegimes 可配置策略的市场状态
+var ValidRegimes = []string{RegimeTrending, RegimeRanging, RegimeHighVolatility, RegimeRiskOff}
+
+const (
+	// DefaultRegimeConfirmCycles 新状态需要连续出现的周期数才会切换（进入避险状态除外）
+	DefaultRegimeConfirmCycles = 2
+	// maxRegimeTransitions 保留的最近状态切换记录数
+	maxRegimeTransitions = 50
+	// minBreadthSymbols 计算市场广度至少需要的币种数
+	minBreadthSymbols = 5
+)
+
+// RegimeLabelCN 市场状态中文名称
+func RegimeLabelCN(regime string) string {
+	switch regime {
+	case RegimeTrending:
+		return "趋势"
+	case RegimeRanging:
+		return "震荡"
+	case RegimeHighVolatility:
+		return "高波动"
+	case RegimeRiskOff:
+		return "普跌避险"
+	default:
+		return "未知"
+	}
+}
+
+// IsValidRegime 检查市场状态名称是否可配置策略
+func IsValidRegime(regime string) bool {
+	for _, r := range ValidRegimes {
+		if r == regime {
+			return true
+		}
+	}
+	return false
+}
+
+// RegimeSignals 市场状态识别所用的指标
+type RegimeSignals struct {
+	BTCChange1h     float64 `json:"btc_change_1h"`
+	BTCChange4h     float64 `json:"btc_change_4h"`
+	TrendScore      float64 `json:"trend_score"`      // BTC 趋势打分（-3 ~ +3）
+	VolatilityRatio float64 `json:"volatility_ratio"` // BTC 短周期ATR / 4小时ATR
+	Breadth         float64 `json:"breadth"`          // 4小时上涨币种占比（%，币种不足时为 -1）
+	Symbols         int     `json:"symbols"`          // 参与广度计算的币种数
+}
+
+// RegimePolicy 某一市场状态下的交易策略
+type RegimePolicy struct {
+	SystemPromptTemplate string `json:"system_prompt_template,omitempty"` // 切换到的系统提示词模板（为空沿用交易员配置）
+	MaxLeverage          int    `json:"max_leverage,omitempty"`           // 开仓杠杆上限（0=不限制）
+	NoNewEntries         bool   `json:"no_new_entries,omitempty"`         // 禁止新开仓（只管理现有持仓）
+}
+
+// ParseRegimePolicies 解析并校验市场状态策略配置（JSON 对象：状态 → 策略），空字符串表示未配置
+func ParseRegimePolicies(raw string) (map[string]RegimePolicy, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	var policies map[string]RegimePolicy
+	if err := json.Unmarshal([]byte(raw), &policies); err != nil {
+		return nil, fmt.Errorf("市场状态策略格式错误: %w", err)
+	}
+	for regime, policy := range policies {
+		if !IsValidRegime(regime) {
+			return nil, fmt.Errorf("无效的市场状态 %q（可选: %s）", regime, strings.Join(ValidRegimes, ", "))
+		}
+		if policy.MaxLeverage < 0 {
+			return nil, fmt.Errorf("市场状态 %s 的杠杆上限不能为负数", regime)
+		}
+	}
+	if len(policies) == 0 {
+		return nil, nil
+	}
+	return policies, nil
+}
+
+// RegimeState 当前市场状态
+type RegimeState struct {
+	Regime   string        `json:"regime"`             // 确认后的市场状态
+	Raw      string        `json:"raw"`                // 本周期的识别结果（未经确认）
+	Previous string        `json:"previous,omitempty"` // 本周期切换前的状态
+	Changed  bool          `json:"changed"`            // 本周期是否发生切换
+	Since    time.Time     `json:"since"`              // 进入当前状态的时间
+	Pending  string        `json:"pending,omitempty"`  // 等待确认的新状态
+	Signals  RegimeSignals `json:"signals"`
+}
+
+// RegimeTransition 市场状态切换记录
+type RegimeTransition struct {
+	From    string        `json:"from"`
+	To      string        `json:"to"`
+	At      time.Time     `json:"at"`
+	Signals RegimeSignals `json:"signals"`
+}
+
+// RegimeDetector 带滞后的市场状态识别器（每个交易员一个）
+// 滞后：各状态的退出阈值比进入阈值宽松，且新状态需连续出现 confirmCycles 个周期才切换；进入避险状态立即生效。
+type RegimeDetector struct {
+	mu            sync.Mutex
+	confirmCycles int
+	current       string
+	since         time.Time
+	pending       string
+	pendingCount  int
+	lastRaw       string
+	lastSignals   RegimeSignals
+	transitions   []RegimeTransition
+}
+
+// NewRegimeDetector 创建市场状态识别器（confirmCycles<=0 时使用默认值）
+func NewRegimeDetector(confirmCycles int) *RegimeDetector {
+	if confirmCycles <= 0 {
+		confirmCycles = DefaultRegimeConfirmCycles
+	}
+	return &RegimeDetector{confirmCycles: confirmCycles, current: RegimeUnknown}
+}
+
+// Observe 记录本周期的识别结果并返回确认后的状态
+func (r *RegimeDetector) Observe(ctx *Context, now time.Time) RegimeState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	signals, ok := computeRegimeSignals(ctx)
+	raw := RegimeUnknown
+	if ok {
+		raw = classifyRegime(signals, r.current)
+	}
+	r.lastRaw, r.lastSignals = raw, signals
+
+	state := RegimeState{Raw: raw, Signals: signals}
+	switch {
+	case raw == RegimeUnknown || raw == r.current:
+		// 数据不足时保持原状态
+		r.pending, r.pendingCount = "", 0
+	case r.current == RegimeUnknown || raw == RegimeRiskOff:
+		r.switchTo(raw, now, signals, &state)
+	default:
+		if raw == r.pending {
+			r.pendingCount++
+		} else {
+			r.pending, r.pendingCount = raw, 1
+		}
+		if r.pendingCount >= r.confirmCycles {
+			r.switchTo(raw, now, signals, &state)
+		}
+	}
+
+	state.Regime = r.current
+	state.Since = r.since
+	state.Pending = r.pending
+	return state
+}
+
+// switchTo 切换到新状态并记录
+func (r *RegimeDetector) switchTo(regime string, now time.Time, signals RegimeSignals, state *RegimeState) {
+	state.Previous = r.current
+	state.Changed = true
+	r.transitions = append(r.transitions, RegimeTransition{From: r.current, To: regime, At: now, Signals: signals})
+	if len(r.transitions) > maxRegimeTransitions {
+		r.transitions = r.transitions[len(r.transitions)-maxRegimeTransitions:]
+	}
+	r.current, r.since = regime, now
+	r.pending, r.pendingCount = "", 0
+}
+
+// Current 当前状态（不记录新的识别结果）
+func (r *RegimeDetector) Current() RegimeState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return RegimeState{Regime: r.current, Raw: r.lastRaw, Since: r.since, Pending: r.pending, Signals: r.lastSignals}
+}
+
+// Transitions 最近的状态切换记录（按时间顺序）
+func (r *RegimeDetector) Transitions() []RegimeTransition {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]RegimeTransition{}, r.transitions...)
+}
+
+// computeRegimeSignals 由 BTC 行情与全部已获取币种的4小时涨跌计算识别指标
+func computeRegimeSignals(ctx *Context) (RegimeSignals, bool) {
+	signals := RegimeSignals{Breadth: -1}
+	if ctx == nil || len(ctx.MarketDataMap) == 0 {
+		return signals, false
+	}
+	btc := selectPrimaryMarketData(ctx.MarketDataMap)
+	if btc == nil {
+		return signals, false
+	}
+	signals.BTCChange1h = btc.PriceChange1h
+	signals.BTCChange4h = btc.PriceChange4h
+	signals.TrendScore = trendScore(btc)
+	signals.VolatilityRatio = volatilityRatio(btc)
+
+	up := 0
+	for _, data := range ctx.MarketDataMap {
+		if data == nil {
+			continue
+		}
+		signals.Symbols++
+		if data.PriceChange4h > 0 {
+			up++
+		}
+	}
+	if signals.Symbols >= minBreadthSymbols {
+		signals.Breadth = float64(up) / float64(signals.Symbols) * 100
+	}
+	return signals, true
+}
+
+// classifyRegime 按优先级识别市场状态：普跌避险 > 高波动 > 趋势 > 震荡
+// current 为当前状态，用于退出阈值（比进入阈值宽松，避免在阈值附近来回切换）
+func classifyRegime(s RegimeSignals, current string) string {
+	hasBreadth := s.Breadth >= 0
+
+	// 普跌避险：BTC 4小时大跌且多数币种下跌，或 BTC 1小时急跌
+	riskOff := (s.BTCChange4h <= -3 && (!hasBreadth || s.Breadth <= 30)) || s.BTCChange1h <= -2
+	if current == RegimeRiskOff {
+		riskOff = s.BTCChange4h <= -1.5 || (hasBreadth && s.Breadth <= 40)
+	}
+	if riskOff {
+		return RegimeRiskOff
+	}
+
+	volThreshold := 1.8
+	if current == RegimeHighVolatility {
+		volThreshold = 1.5
+	}
+	if s.VolatilityRatio >= volThreshold {
+		return RegimeHighVolatility
+	}
+
+	// 趋势：BTC 趋势明确且市场广度同向
+	trending := math.Abs(s.TrendScore) >= 1.5 &&
+		(!hasBreadth || (s.TrendScore > 0 && s.Breadth >= 55) || (s.TrendScore < 0 && s.Breadth <= 45))
+	if current == RegimeTrending {
+		trending = math.Abs(s.TrendScore) >= 1
+	}
+	if trending {
+		return RegimeTrending
+	}
+	return RegimeRanging
+}
+
+// applyMarketRegime 识别市场状态并选择对应策略（在构建提示词前调用，可能切换系统提示词模板）
+func applyMarketRegime(ctx *Context, templateName string) string {
+	if ctx.RegimeDetector == nil {
+		return templateName
+	}
+	var state RegimeState
+	if ctx.RegimePreview {
+		// 提示词预览不推进识别器状态
+		state = ctx.RegimeDetector.Current()
+	} else {
+		state = ctx.RegimeDetector.Observe(ctx, time.Now())
+		if state.Changed {
+			log.Printf("🧭 市场状态切换: %s → %s (BTC 1h %+.2f%%, 4h %+.2f%%, 趋势分 %.1f, 波动比 %.2f, 广度 %.0f%%)",
+				RegimeLabelCN(state.Previous), RegimeLabelCN(state.Regime), state.Signals.BTCChange1h, state.Signals.BTCChange4h,
+				state.Signals.TrendScore, state.Signals.VolatilityRatio, state.Signals.Breadth)
+		}
+	}
+	ctx.Regime = &state
+
+	policy, ok := ctx.RegimePolicies[state.Regime]
+	if !ok {
+		return templateName
+	}
+	ctx.RegimePolicy = &policy
+
+	if ctx.MarketSummary != nil {
+		note := fmt.Sprintf("市场状态：%s", RegimeLabelCN(state.Regime))
+		if policy.NoNewEntries {
+			note += "，当前策略禁止新开仓，只管理现有持仓"
+		}
+		if policy.MaxLeverage > 0 {
+			note += fmt.Sprintf("，开仓杠杆上限 %dx", policy.MaxLeverage)
+		}
+		ctx.MarketSummary.Notes = append(ctx.MarketSummary.Notes, note)
+	}
+	if policy.SystemPromptTemplate != "" {
+		return policy.SystemPromptTemplate
+	}
+	return templateName
+}
@@ -0,0 +1,160 @@
+package decision
+
+import (
+	"fmt"
+	"nofx/market"
+	"strings"
+	"testing"
+	"time"
+)
+
+// regimeContext 构造 BTC 行情与 total 个币种（其中 up 个4小时上涨）的上下文
+func regimeContext(btc *market.Data, up, total int) *Context {
+	dataMap := map[string]*market.Data{"BTCUSDT": btc}
+	for i := 1; i < total; i++ {
+		change := -1.0
+		if i < up {
+			change = 1.0
+		}
+		symbol := fmt.Sprintf("ALT%dUSDT", i)
+		dataMap[symbol] = &market.Data{Symbol: symbol, PriceChange4h: change}
+	}
+	return &Context{MarketDataMap: dataMap}
+}
+
+func trendingBTC() *market.Data {
+	return &market.Data{Symbol: "BTCUSDT", CurrentPrice: 100, CurrentEMA20: 95, PriceChange1h: 1, PriceChange4h: 3, CurrentMACD: 1, CurrentRSI7: 70}
+}
+
+func rangingBTC() *market.Data {
+	return &market.Data{Symbol: "BTCUSDT", CurrentPrice: 100, CurrentEMA20: 99, PriceChange1h: 0.2, PriceChange4h: 0.5, CurrentRSI7: 50}
+}
+
+func riskOffBTC() *market.Data {
+	return &market.Data{Symbol: "BTCUSDT", CurrentPrice: 100, CurrentEMA20: 105, PriceChange1h: -1, PriceChange4h: -4, CurrentMACD: -1, CurrentRSI7: 25}
+}
+
+// TestClassifyRegime 测试各市场状态的进入阈值与更宽松的退出阈值
+func TestClassifyRegime(t *testing.T) {
+	tests := []struct {
+		name    string
+		signals RegimeSignals
+		current string
+		want    string
+	}{
+		{"4小时大跌且多数下跌", RegimeSignals{BTCChange4h: -3.5, Breadth: 20, VolatilityRatio: 1}, RegimeUnknown, RegimeRiskOff},
+		{"1小时急跌", RegimeSignals{BTCChange1h: -2.5, Breadth: 60, VolatilityRatio: 1}, RegimeRanging, RegimeRiskOff},
+		{"广度未恶化不进入避险", RegimeSignals{BTCChange4h: -3.5, Breadth: 50, VolatilityRatio: 1}, RegimeRanging, RegimeRanging},
+		{"避险状态未充分恢复", RegimeSignals{BTCChange4h: -2, Breadth: 50, VolatilityRatio: 1}, RegimeRiskOff, RegimeRiskOff},
+		{"避险状态恢复", RegimeSignals{BTCChange4h: -1, Breadth: 50, VolatilityRatio: 1}, RegimeRiskOff, RegimeRanging},
+		{"高波动进入", RegimeSignals{VolatilityRatio: 1.9, Breadth: -1}, RegimeRanging, RegimeHighVolatility},
+		{"高波动未达进入阈值", RegimeSignals{VolatilityRatio: 1.6, Breadth: -1}, RegimeRanging, RegimeRanging},
+		{"高波动保持", RegimeSignals{VolatilityRatio: 1.6, Breadth: -1}, RegimeHighVolatility, RegimeHighVolatility},
+		{"上涨趋势且广度同向", RegimeSignals{TrendScore: 2, Breadth: 70, VolatilityRatio: 1}, RegimeRanging, RegimeTrending},
+		{"趋势与广度背离", RegimeSignals{TrendScore: 2, Breadth: 30, VolatilityRatio: 1}, RegimeRanging, RegimeRanging},
+		{"趋势减弱但未跌破退出阈值", RegimeSignals{TrendScore: 1, Breadth: 50, VolatilityRatio: 1}, RegimeTrending, RegimeTrending},
+		{"趋势消失", RegimeSignals{TrendScore: 0.5, Breadth: 50, VolatilityRatio: 1}, RegimeTrending, RegimeRanging},
+	}
+	for _, tt := range tests {
+		if got := classifyRegime(tt.signals, tt.current); got != tt.want {
+			t.Errorf("%s: 期望 %s，实际 %s", tt.name, tt.want, got)
+		}
+	}
+}
+
+// TestRegimeDetectorHysteresis 测试新状态需连续确认才切换，进入避险状态立即生效
+func TestRegimeDetectorHysteresis(t *testing.T) {
+	detector := NewRegimeDetector(2)
+	now := time.Now()
+
+	state := detector.Observe(regimeContext(trendingBTC(), 8, 10), now)
+	if state.Regime != RegimeTrending || !state.Changed || state.Previous != RegimeUnknown {
+		t.Fatalf("首次识别应直接生效: %+v", state)
+	}
+
+	state = detector.Observe(regimeContext(rangingBTC(), 5, 10), now.Add(time.Minute))
+	if state.Regime != RegimeTrending || state.Changed || state.Raw != RegimeRanging || state.Pending != RegimeRanging {
+		t.Fatalf("新状态未确认前应保持原状态: %+v", state)
+	}
+	state = detector.Observe(regimeContext(rangingBTC(), 5, 10), now.Add(2*time.Minute))
+	if state.Regime != RegimeRanging || !state.Changed || state.Previous != RegimeTrending {
+		t.Fatalf("连续确认后应切换: %+v", state)
+	}
+
+	state = detector.Observe(&Context{}, now.Add(3*time.Minute))
+	if state.Regime != RegimeRanging || state.Changed {
+		t.Fatalf("数据不足时应保持原状态: %+v", state)
+	}
+
+	state = detector.Observe(regimeContext(riskOffBTC(), 1, 10), now.Add(4*time.Minute))
+	if state.Regime != RegimeRiskOff || !state.Changed {
+		t.Fatalf("进入避险状态应立即生效: %+v", state)
+	}
+
+	transitions := detector.Transitions()
+	if len(transitions) != 3 || transitions[2].From != RegimeRanging || transitions[2].To != RegimeRiskOff {
+		t.Errorf("状态切换记录不正确: %+v", transitions)
+	}
+	if current := detector.Current(); current.Regime != RegimeRiskOff || !current.Since.Equal(now.Add(4*time.Minute)) {
+		t.Errorf("当前状态不正确: %+v", current)
+	}
+}
+
+// TestApplyMarketRegime 测试按市场状态切换系统提示词模板并在市场概览中提示策略
+func TestApplyMarketRegime(t *testing.T) {
+	ctx := regimeContext(riskOffBTC(), 1, 10)
+	ctx.MarketSummary = &MarketSummary{}
+	ctx.RegimeDetector = NewRegimeDetector(0)
+	ctx.RegimePolicies = map[string]RegimePolicy{
+		RegimeRiskOff:  {SystemPromptTemplate: "conservative", NoNewEntries: true},
+		RegimeTrending: {MaxLeverage: 3},
+	}
+
+	if got := applyMarketRegime(ctx, "adaptive"); got != "conservative" {
+		t.Errorf("避险状态应切换到 conservative 模板，实际 %s", got)
+	}
+	if ctx.Regime == nil || ctx.Regime.Regime != RegimeRiskOff || ctx.RegimePolicy == nil || !ctx.RegimePolicy.NoNewEntries {
+		t.Fatalf("上下文应记录市场状态与策略: %+v %+v", ctx.Regime, ctx.RegimePolicy)
+	}
+	if len(ctx.MarketSummary.Notes) != 1 || !strings.Contains(ctx.MarketSummary.Notes[0], "禁止新开仓") {
+		t.Errorf("市场概览应提示状态策略: %v", ctx.MarketSummary.Notes)
+	}
+
+	// 预览不推进识别器状态
+	preview := regimeContext(rangingBTC(), 5, 10)
+	preview.RegimeDetector = ctx.RegimeDetector
+	preview.RegimePreview = true
+	applyMarketRegime(preview, "adaptive")
+	applyMarketRegime(preview, "adaptive")
+	if preview.Regime.Regime != RegimeRiskOff || len(ctx.RegimeDetector.Transitions()) != 1 {
+		t.Errorf("预览不应改变市场状态: %+v", preview.Regime)
+	}
+
+	// 没有对应策略时沿用原模板
+	ranging := regimeContext(rangingBTC(), 5, 10)
+	ranging.RegimeDetector = NewRegimeDetector(0)
+	ranging.RegimePolicies = ctx.RegimePolicies
+	if got := applyMarketRegime(ranging, "adaptive"); got != "adaptive" || ranging.RegimePolicy != nil {
+		t.Errorf("无策略时应沿用原模板，实际 %s", got)
+	}
+}
+
+// TestParseRegimePolicies 测试市场状态策略解析与校验
+func TestParseRegimePolicies(t *testing.T) {
+	policies, err := ParseRegimePolicies(`{"risk_off":{"no_new_entries":true},"high_volatility":{"max_leverage":3}}`)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if !policies[RegimeRiskOff].NoNewEntries || policies[RegimeHighVolatility].MaxLeverage != 3 {
+		t.Errorf("解析结果不正确: %+v", policies)
+	}
+
+	if policies, err := ParseRegimePolicies(""); err != nil || policies != nil {
+		t.Errorf("空配置应返回 nil: %+v, %v", policies, err)
+	}
+	for _, raw := range []string{`{"bull":{}}`, `{"trending":{"max_leverage":-1}}`, `[1]`} {
+		if _, err := ParseRegimePolicies(raw); err == nil {
+			t.Errorf("%s 应校验失败", raw)
+		}
+	}
+}
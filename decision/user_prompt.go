@@ -0,0 +1,403 @@
+package decision
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"nofx/logger"
+	"nofx/market"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// DefaultUserPromptTemplate 默认用户提示词模板名称（交易员未选择模板时使用）
+const DefaultUserPromptTemplate = "default"
+
+// userPromptsDir 用户提示词模板目录（*.tmpl，text/template 语法）
+var userPromptsDir = filepath.Join("prompts", "user")
+
+// patternIntervalOrder K线形态分析的展示顺序（短周期到长周期）
+var patternIntervalOrder = []string{"1m", "3m", "5m", "15m", "30m", "1h", "2h", "4h", "6h", "8h", "12h", "1d", "3d", "1w", "1M"}
+
+// UserPromptData 用户提示词模板的数据模型（模板中以 "." 访问，字段说明见 prompts/user/README.md）
+type UserPromptData struct {
+	Time              string          // 当前时间 "2006-01-02 15:04:05"
+	CallCount         int             // 决策周期序号
+	RuntimeMinutes    int             // 运行时长（分钟）
+	Account           AccountView     // 账户信息
+	BTC               *SymbolView     // BTC 行情（无数据时为 nil）
+	MarketSummary     *MarketSummary  // 全局市场状态（可能为 nil）
+	Positions         []PositionView  // 当前持仓
+	Candidates        []CandidateView // 有行情数据的候选币种
+	OmittedCandidates []string        // 因 token 预算被省略的候选币种
+	MarketDataCount   int             // 获取到行情数据的币种数（含持仓与 BTC）
+	HasPerformance    bool            // 是否有历史表现数据
+	SharpeRatio       float64         // 夏普比率
+	RecentTrades      []TradeView     // 近期已平仓交易
+	Lessons           []LessonView    // 与当前行情相关的历史复盘经验（按相关度排序）
+	Timeframes        []string        // 交易员配置的时间周期
+	Context           *Context        // 原始交易上下文（高级用法）
+}
+
+// AccountView 账户信息（附可用余额占比）
+type AccountView struct {
+	AccountInfo
+	AvailablePct float64 // 可用余额占净值百分比
+}
+
+// SymbolView 单个币种的行情视图
+type SymbolView struct {
+	Symbol           string
+	Price            float64                  // 实时价格（无实时价格时为最新收盘价）
+	Change1h         float64                  // 1小时涨跌幅（%）
+	Change4h         float64                  // 4小时涨跌幅（%）
+	MACD             float64                  // 3分钟 MACD
+	RSI7             float64                  // 3分钟 RSI(7)
+	Data             *market.Data             // 完整行情数据（仅有K线形态分析时为 nil）
+	Market           string                   // market.Format 输出的完整行情段落
+	Indicators       string                   // 交易员所选指标段落（未选择指标时为空）
+	IndicatorResults []market.IndicatorResult // 交易员所选指标的计算结果
+	Patterns         []PatternView            // 多时间周期K线形态分析（按周期从短到长）
+	LegacyPattern    string                   // 单周期形态分析（无多周期分析时使用）
+}
+
+// PatternView 某个时间周期的K线形态分析
+type PatternView struct {
+	Interval      string
+	Analysis      string // 形态、支撑阻力等分析文本
+	Visualization string // K线可视化（仅关键周期）
+}
+
+// ProtectiveOrder 持仓的止损/止盈挂单
+type ProtectiveOrder struct {
+	OpenOrderInfo
+	Kind string // "stop_loss" 或 "take_profit"
+}
+
+// PositionView 持仓视图
+type PositionView struct {
+	PositionInfo
+	Index            int               // 从1开始的序号
+	Value            float64           // 仓位价值（USDT）
+	HoldingMinutes   int64             // 持仓时长（分钟），未知时为 -1
+	ProtectiveOrders []ProtectiveOrder // 止损/止盈挂单（按挂单顺序）
+	HasStopLoss      bool
+	Market           *SymbolView // 行情与K线形态分析（无行情数据时 Market.Data 为 nil）
+}
+
+// CandidateView 候选币种视图
+type CandidateView struct {
+	Index     int // 从1开始的序号
+	Symbol    string
+	Sources   []string // 来源: "ai500" / "oi_top"
+	SourceTag string   // 来源标注，例如 " (OI_Top持仓增长)"
+	Market    *SymbolView
+}
+
+// TradeView 近期交易视图
+type TradeView struct {
+	logger.TradeOutcome
+	Index int  // 从1开始的序号
+	Win   bool // 是否盈利（PnL >= 0）
+}
+
+// LessonView 复盘经验视图
+type LessonView struct {
+	TradeLesson
+	Line string // 默认格式的一行文本
+}
+
+// userPromptFuncs 模板可用的辅助函数
+var userPromptFuncs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	"join":  strings.Join,
+	"add":   func(a, b int) int { return a + b },
+	"abs":   math.Abs,
+	// holding 将分钟数格式化为 "X分钟" 或 "X小时Y分钟"
+	"holding": func(minutes int64) string {
+		if minutes < 60 {
+			return fmt.Sprintf("%d分钟", minutes)
+		}
+		return fmt.Sprintf("%d小时%d分钟", minutes/60, minutes%60)
+	},
+}
+
+// UserPromptTemplate 用户提示词模板
+type UserPromptTemplate struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+
+	tmpl *template.Template
+}
+
+// ParseUserPromptTemplate 解析用户提示词模板（语法错误时返回错误）
+func ParseUserPromptTemplate(name, content string) (*UserPromptTemplate, error) {
+	tmpl, err := template.New(name).Funcs(userPromptFuncs).Option("missingkey=error").Parse(content)
+	if err != nil {
+		return nil, fmt.Errorf("解析用户提示词模板 %s 失败: %w", name, err)
+	}
+	return &UserPromptTemplate{Name: name, Content: content, tmpl: tmpl}, nil
+}
+
+// Render 使用交易上下文渲染模板
+func (t *UserPromptTemplate) Render(ctx *Context) (string, error) {
+	return t.execute(buildUserPromptData(ctx))
+}
+
+func (t *UserPromptTemplate) execute(data *UserPromptData) (string, error) {
+	var sb strings.Builder
+	if err := t.tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("渲染用户提示词模板 %s 失败: %w", t.Name, err)
+	}
+	return sb.String(), nil
+}
+
+// UserPromptManager 用户提示词模板管理器
+type UserPromptManager struct {
+	templates map[string]*UserPromptTemplate
+	mu        sync.RWMutex
+}
+
+var globalUserPromptManager = NewUserPromptManager()
+
+func init() {
+	if err := globalUserPromptManager.LoadTemplates(userPromptsDir); err != nil {
+		log.Printf("⚠️  加载用户提示词模板失败: %v", err)
+	} else {
+		log.Printf("✓ 已加载 %d 个用户提示词模板", len(globalUserPromptManager.GetAllTemplateNames()))
+	}
+}
+
+// NewUserPromptManager 创建用户提示词模板管理器
+func NewUserPromptManager() *UserPromptManager {
+	return &UserPromptManager{templates: make(map[string]*UserPromptTemplate)}
+}
+
+// LoadTemplates 从目录加载所有 *.tmpl 模板（替换已加载的模板）
+func (pm *UserPromptManager) LoadTemplates(dir string) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return fmt.Errorf("用户提示词目录不存在: %s", dir)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return fmt.Errorf("扫描用户提示词目录失败: %w", err)
+	}
+
+	templates := make(map[string]*UserPromptTemplate, len(files))
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			log.Printf("⚠️  读取用户提示词模板失败 %s: %v", file, err)
+			continue
+		}
+		name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		tmpl, err := ParseUserPromptTemplate(name, string(content))
+		if err != nil {
+			log.Printf("⚠️  %v", err)
+			continue
+		}
+		templates[name] = tmpl
+	}
+
+	pm.mu.Lock()
+	pm.templates = templates
+	pm.mu.Unlock()
+	return nil
+}
+
+// GetTemplate 获取指定名称的用户提示词模板
+func (pm *UserPromptManager) GetTemplate(name string) (*UserPromptTemplate, error) {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	tmpl, exists := pm.templates[name]
+	if !exists {
+		return nil, fmt.Errorf("用户提示词模板不存在: %s", name)
+	}
+	return tmpl, nil
+}
+
+// GetAllTemplateNames 获取所有模板名称（已排序）
+func (pm *UserPromptManager) GetAllTemplateNames() []string {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	names := make([]string, 0, len(pm.templates))
+	for name := range pm.templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// === 全局函数（供外部调用）===
+
+// GetUserPromptTemplate 获取指定名称的用户提示词模板（全局函数）
+func GetUserPromptTemplate(name string) (*UserPromptTemplate, error) {
+	return globalUserPromptManager.GetTemplate(name)
+}
+
+// GetAllUserPromptTemplateNames 获取所有用户提示词模板名称（全局函数）
+func GetAllUserPromptTemplateNames() []string {
+	return globalUserPromptManager.GetAllTemplateNames()
+}
+
+// ReloadUserPromptTemplates 重新加载用户提示词模板（全局函数）
+func ReloadUserPromptTemplates() error {
+	return globalUserPromptManager.LoadTemplates(userPromptsDir)
+}
+
+// UserPromptTemplateExists 检查用户提示词模板是否存在
+func UserPromptTemplateExists(name string) bool {
+	_, err := GetUserPromptTemplate(name)
+	return err == nil
+}
+
+// buildUserPromptData 由交易上下文构建模板数据
+func buildUserPromptData(ctx *Context) *UserPromptData {
+	data := &UserPromptData{
+		Time:            ctx.CurrentTime,
+		CallCount:       ctx.CallCount,
+		RuntimeMinutes:  ctx.RuntimeMinutes,
+		Account:         AccountView{AccountInfo: ctx.Account},
+		MarketSummary:   ctx.MarketSummary,
+		MarketDataCount: len(ctx.MarketDataMap),
+		Timeframes:      ctx.Timeframes,
+		Context:         ctx,
+	}
+	if ctx.Account.TotalEquity != 0 {
+		data.Account.AvailablePct = ctx.Account.AvailableBalance / ctx.Account.TotalEquity * 100
+	}
+
+	if _, ok := ctx.MarketDataMap["BTCUSDT"]; ok {
+		data.BTC = buildSymbolView(ctx, "BTCUSDT", []string{"1m", "15m", "1h", "4h", "1d"})
+	}
+
+	keyIntervals := []string{"1m", "1h", "4h", "1d"}
+	now := time.Now().UnixMilli()
+	for i, pos := range ctx.Positions {
+		view := PositionView{
+			PositionInfo:   pos,
+			Index:          i + 1,
+			Value:          math.Abs(pos.Quantity) * pos.MarkPrice,
+			HoldingMinutes: -1,
+		}
+		if pos.UpdateTime > 0 {
+			view.HoldingMinutes = (now - pos.UpdateTime) / (1000 * 60)
+		}
+		for _, order := range ctx.OpenOrders {
+			if order.Symbol != pos.Symbol {
+				continue
+			}
+			switch order.Type {
+			case "STOP_MARKET", "STOP":
+				view.ProtectiveOrders = append(view.ProtectiveOrders, ProtectiveOrder{OpenOrderInfo: order, Kind: "stop_loss"})
+				view.HasStopLoss = true
+			case "TAKE_PROFIT_MARKET", "TAKE_PROFIT":
+				view.ProtectiveOrders = append(view.ProtectiveOrders, ProtectiveOrder{OpenOrderInfo: order, Kind: "take_profit"})
+			}
+		}
+		if _, ok := ctx.MarketDataMap[pos.Symbol]; ok {
+			view.Market = buildSymbolView(ctx, pos.Symbol, keyIntervals)
+		} else {
+			view.Market = buildPatternOnlyView(ctx, pos.Symbol, keyIntervals)
+		}
+		data.Positions = append(data.Positions, view)
+	}
+
+	for _, coin := range ctx.CandidateCoins {
+		if _, ok := ctx.MarketDataMap[coin.Symbol]; !ok {
+			continue
+		}
+		view := CandidateView{
+			Index:   len(data.Candidates) + 1,
+			Symbol:  coin.Symbol,
+			Sources: coin.Sources,
+			Market:  buildSymbolView(ctx, coin.Symbol, keyIntervals),
+		}
+		if len(coin.Sources) > 1 {
+			view.SourceTag = " (AI500+OI_Top双重信号)"
+		} else if len(coin.Sources) == 1 && coin.Sources[0] == "oi_top" {
+			view.SourceTag = " (OI_Top持仓增长)"
+		}
+		data.Candidates = append(data.Candidates, view)
+	}
+
+	if sharpe, trades, ok := parsePerformance(ctx); ok {
+		data.HasPerformance = true
+		data.SharpeRatio = sharpe
+		for i, trade := range trades {
+			data.RecentTrades = append(data.RecentTrades, TradeView{TradeOutcome: trade, Index: i + 1, Win: trade.PnL >= 0})
+		}
+	}
+	for _, lesson := range SelectRelevantLessons(ctx, MaxPromptLessons) {
+		data.Lessons = append(data.Lessons, LessonView{TradeLesson: lesson, Line: formatLessonLine(lesson)})
+	}
+	return data
+}
+
+// parsePerformance 从历史表现中取出夏普比率与近期已平仓交易（Performance 为空或格式不符时 ok=false）
+func parsePerformance(ctx *Context) (sharpe float64, trades []logger.TradeOutcome, ok bool) {
+	if ctx.Performance == nil {
+		return 0, nil, false
+	}
+	var perf struct {
+		SharpeRatio  float64               `json:"sharpe_ratio"`
+		RecentTrades []logger.TradeOutcome `json:"recent_trades"`
+	}
+	jsonData, err := json.Marshal(ctx.Performance)
+	if err != nil || json.Unmarshal(jsonData, &perf) != nil {
+		return 0, nil, false
+	}
+	return perf.SharpeRatio, perf.RecentTrades, true
+}
+
+// buildSymbolView 构建币种行情视图，vizIntervals 为需要附带K线可视化的周期
+func buildSymbolView(ctx *Context, symbol string, vizIntervals []string) *SymbolView {
+	view := buildPatternOnlyView(ctx, symbol, vizIntervals)
+	data := ctx.MarketDataMap[symbol]
+	view.Data = data
+	view.Price = data.RealtimePrice
+	if view.Price <= 0 {
+		view.Price = data.CurrentPrice
+	}
+	view.Change1h = data.PriceChange1h
+	view.Change4h = data.PriceChange4h
+	view.MACD = data.CurrentMACD
+	view.RSI7 = data.CurrentRSI7
+	view.Market = market.Format(data)
+	view.IndicatorResults = ctx.IndicatorResultMap[symbol]
+	view.Indicators = market.FormatIndicators(view.IndicatorResults)
+	return view
+}
+
+// buildPatternOnlyView 构建只含K线形态分析的视图
+func buildPatternOnlyView(ctx *Context, symbol string, vizIntervals []string) *SymbolView {
+	view := &SymbolView{Symbol: symbol}
+	if analyses := ctx.MultiTimeframeAnalysisMap[symbol]; len(analyses) > 0 {
+		for _, interval := range patternIntervalOrder {
+			analysis, exists := analyses[interval]
+			if !exists {
+				continue
+			}
+			pattern := PatternView{Interval: interval, Analysis: analysis.FormatForPrompt()}
+			if slices.Contains(vizIntervals, interval) {
+				if klines, err := market.GetHistoricalKlines(symbol, interval, 50); err == nil && len(klines) > 0 {
+					pattern.Visualization = FormatKlineVisualization(klines, symbol, interval, 50)
+				}
+			}
+			view.Patterns = append(view.Patterns, pattern)
+		}
+	} else if analysis, ok := ctx.PatternAnalysisMap[symbol]; ok {
+		view.LegacyPattern = analysis.FormatForPrompt()
+	}
+	return view
+}
@@ -0,0 +1,120 @@
+package decision
+
+import (
+	"nofx/logger"
+	"nofx/market"
+	"strings"
+	"testing"
+	"time"
+)
+
+// userPromptTestContext 构建不依赖网络的交易上下文（形态分析周期不在K线可视化周期内）
+func userPromptTestContext() *Context {
+	btc := &market.Data{Symbol: "BTCUSDT", CurrentPrice: 65000, RealtimePrice: 65010.5, PriceChange1h: 0.35, PriceChange4h: -1.2, CurrentMACD: 12.3456, CurrentRSI7: 55.5, FundingRate: 0.0001}
+	sol := &market.Data{Symbol: "SOLUSDT", CurrentPrice: 150.25, PriceChange1h: -0.5, PriceChange4h: 2.1, CurrentMACD: -0.1234, CurrentRSI7: 42}
+	eth := &market.Data{Symbol: "ETHUSDT", CurrentPrice: 3200, PriceChange1h: 0.1, PriceChange4h: 0.2, CurrentMACD: 1.5, CurrentRSI7: 61}
+	pattern := func(symbol, interval string) *PatternAnalysis {
+		return &PatternAnalysis{Symbol: symbol, Interval: interval, Summary: "震荡", Recommendation: "观望"}
+	}
+
+	return &Context{
+		CurrentTime:    "2025-01-01 12:00:00",
+		RuntimeMinutes: 95,
+		CallCount:      7,
+		Account:        AccountInfo{TotalEquity: 1000, AvailableBalance: 600, TotalPnLPct: 3.5, MarginUsedPct: 40, PositionCount: 1},
+		Positions: []PositionInfo{{
+			Symbol: "SOLUSDT", Side: "long", EntryPrice: 140, MarkPrice: 150.25, Quantity: 2, Leverage: 5,
+			UnrealizedPnL: 20.5, UnrealizedPnLPct: 36.6, PeakPnLPct: 40, LiquidationPrice: 115, MarginUsed: 56,
+			UpdateTime: time.Now().Add(-90*time.Minute - 30*time.Second).UnixMilli(),
+		}},
+		OpenOrders: []OpenOrderInfo{
+			{Symbol: "SOLUSDT", Type: "TAKE_PROFIT_MARKET", Side: "SELL", StopPrice: 170},
+			{Symbol: "SOLUSDT", Type: "STOP_MARKET", Side: "SELL", StopPrice: 135},
+		},
+		CandidateCoins: []CandidateCoin{
+			{Symbol: "ETHUSDT", Sources: []string{"ai500", "oi_top"}},
+			{Symbol: "DOGEUSDT", Sources: []string{"ai500"}}, // 无行情数据，不显示
+		},
+		MarketDataMap: map[string]*market.Data{"BTCUSDT": btc, "SOLUSDT": sol, "ETHUSDT": eth},
+		MarketSummary: &MarketSummary{TrendLabel: "bullish", VolatilityLabel: "normal", LiquidityLabel: "high", SuggestedAction: "trend_follow", Notes: []string{"保证金使用率较高"}},
+		PatternAnalysisMap: map[string]*PatternAnalysis{
+			"BTCUSDT": pattern("BTCUSDT", "1h"),
+		},
+		MultiTimeframeAnalysisMap: map[string]map[string]*PatternAnalysis{
+			"SOLUSDT": {"15m": pattern("SOLUSDT", "15m"), "5m": pattern("SOLUSDT", "5m")},
+			"ETHUSDT": {"30m": pattern("ETHUSDT", "30m")},
+		},
+		Performance: &logger.PerformanceAnalysis{
+			SharpeRatio: 1.23,
+			RecentTrades: []logger.TradeOutcome{
+				{Symbol: "BTCUSDT", Side: "short", Leverage: 10, OpenPrice: 66000, ClosePrice: 65500, PnL: 12.5, PnLPct: 7.6, Duration: "1h20m",
+					OpenTime: time.Date(2024, 12, 31, 8, 0, 0, 0, time.UTC), CloseTime: time.Date(2024, 12, 31, 9, 20, 0, 0, time.UTC)},
+				{Symbol: "ETHUSDT", Side: "long", Leverage: 5, OpenPrice: 3300, ClosePrice: 3250, PnL: -7.5, PnLPct: -7.6, Duration: "35m", WasStopLoss: true,
+					OpenTime: time.Date(2024, 12, 31, 10, 0, 0, 0, time.UTC), CloseTime: time.Date(2024, 12, 31, 10, 35, 0, 0, time.UTC)},
+			},
+		},
+		Lessons: []TradeLesson{
+			{ID: 1, Symbol: "SOLUSDT", Side: "long", Regime: "bullish/normal", Setup: "回踩支撑", Lesson: "趋势中回踩支撑做多胜率高", PnLPct: 12.3},
+			{ID: 2, Symbol: "XRPUSDT", Side: "short", Lesson: "与当前行情无关的经验", PnLPct: -4},
+		},
+	}
+}
+
+func loadTestUserPrompt(t *testing.T, name string) *UserPromptTemplate {
+	t.Helper()
+	pm := NewUserPromptManager()
+	if err := pm.LoadTemplates("../prompts/user"); err != nil {
+		t.Fatalf("加载用户提示词模板失败: %v", err)
+	}
+	tmpl, err := pm.GetTemplate(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tmpl
+}
+
+// TestDefaultUserPromptTemplate_MatchesBuiltin 默认模板与内置格式输出一致
+func TestDefaultUserPromptTemplate_MatchesBuiltin(t *testing.T) {
+	contexts := map[string]*Context{"full": userPromptTestContext()}
+	empty := userPromptTestContext()
+	empty.Positions, empty.OpenOrders, empty.Performance, empty.MarketSummary = nil, nil, nil, nil
+	empty.Account.AvailableBalance = 400
+	delete(empty.MarketDataMap, "BTCUSDT")
+	contexts["no_positions"] = empty
+
+	tmpl := loadTestUserPrompt(t, DefaultUserPromptTemplate)
+	for name, ctx := range contexts {
+		got, err := tmpl.Render(ctx)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if want := buildUserPrompt(ctx); got != want {
+			t.Errorf("%s: 默认模板输出与内置格式不一致\n--- template ---\n%s\n--- builtin ---\n%s", name, got, want)
+		}
+	}
+}
+
+func TestUserPromptTemplate_CustomLayout(t *testing.T) {
+	tmpl, err := ParseUserPromptTemplate("compact", `{{range .Positions}}{{.Symbol}} {{upper .Side}} {{holding .HoldingMinutes}} SL={{.HasStopLoss}}
+{{end}}{{range .Candidates}}{{.Index}}:{{.Symbol}}{{.SourceTag}}
+{{end}}trades={{len .RecentTrades}} sharpe={{printf "%.2f" .SharpeRatio}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := tmpl.Render(userPromptTestContext())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "SOLUSDT LONG 1小时30分钟 SL=true\n1:ETHUSDT (AI500+OI_Top双重信号)\ntrades=2 sharpe=1.23"
+	if got != want {
+		t.Errorf("自定义模板输出错误:\n%s", got)
+	}
+
+	if _, err := ParseUserPromptTemplate("broken", "{{if .Positions}}"); err == nil {
+		t.Error("语法错误的模板应解析失败")
+	}
+	bad, _ := ParseUserPromptTemplate("bad_field", "{{.NoSuchField}}")
+	if _, err := bad.Render(userPromptTestContext()); err == nil || !strings.Contains(err.Error(), "bad_field") {
+		t.Errorf("引用不存在的字段应渲染失败: %v", err)
+	}
+}
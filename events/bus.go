@@ -0,0 +1,139 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// 事件类型
+const (
+	TypeCycleStarted   = "cycle_started"   // AI决策周期开始
+	TypeCycleFinished  = "cycle_finished"  // AI决策周期结束
+	TypeDecision       = "decision"        // AI产生决策
+	TypeOrderPlaced    = "order_placed"    // 挂单（止损/止盈）
+	TypeOrderFilled    = "order_filled"    // 市价单成交（开仓/平仓/部分平仓）
+	TypePositionOpened = "position_opened" // 开仓
+	TypePositionClosed = "position_closed" // 平仓（含止损止盈触发、强平、回撤保护、手动）
+	TypePositionUpdate = "position_update" // 回撤监控的持仓盈亏快照
+	TypeRiskVeto       = "risk_veto"       // 风控/运行模式否决决策
+	TypeBalanceUpdate  = "balance_update"  // 账户余额更新
+	TypeRegimeChange   = "regime_change"   // 市场状态切换
+)
+
+// Event 交易员推送事件
+type Event struct {
+	ID         uint64      `json:"id"` // 由 Bus 分配的递增序号（SSE 的 Last-Event-ID）
+	Type       string      `json:"type"`
+	TraderID   string      `json:"trader_id"`
+	TraderName string      `json:"trader_name"`
+	UserID     string      `json:"-"` // 仅用于按用户投递，不对外输出
+	Timestamp  time.Time   `json:"timestamp"`
+	Data       interface{} `json:"data"`
+}
+
+// Filter 订阅过滤条件
+type Filter func(e Event) bool
+
+// Bus 进程内事件总线：非阻塞发布，保留最近事件用于断线重连补发
+type Bus struct {
+	mu          sync.Mutex
+	nextID      uint64
+	history     []Event
+	historySize int
+	subscribers map[*Subscription]struct{}
+}
+
+// Subscription 事件订阅（消费过慢时会被关闭，客户端应携带最后的事件ID重连）
+type Subscription struct {
+	C      <-chan Event
+	ch     chan Event
+	filter Filter
+	bus    *Bus
+	closed bool
+}
+
+// NewBus 创建事件总线，historySize 为保留的最近事件数
+func NewBus(historySize int) *Bus {
+	if historySize <= 0 {
+		historySize = 500
+	}
+	return &Bus{
+		historySize: historySize,
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish 发布事件（不会阻塞发布方）
+func (b *Bus) Publish(e Event) {
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	e.ID = b.nextID
+	b.history = append(b.history, e)
+	if len(b.history) > b.historySize {
+		b.history = b.history[len(b.history)-b.historySize:]
+	}
+
+	for sub := range b.subscribers {
+		if sub.filter != nil && !sub.filter(e) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			// 缓冲区已满：关闭订阅，由客户端按最后事件ID重连补发
+			b.removeLocked(sub)
+		}
+	}
+}
+
+// Subscribe 订阅事件，lastID > 0 时同时返回之后错过的历史事件（与订阅原子完成，不丢事件）
+func (b *Bus) Subscribe(filter Filter, lastID uint64, bufferSize int) (*Subscription, []Event) {
+	if bufferSize <= 0 {
+		bufferSize = 64
+	}
+	ch := make(chan Event, bufferSize)
+	sub := &Subscription{C: ch, ch: ch, filter: filter, bus: b}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var missed []Event
+	if lastID > 0 {
+		for _, e := range b.history {
+			if e.ID > lastID && (filter == nil || filter(e)) {
+				missed = append(missed, e)
+			}
+		}
+	}
+	b.subscribers[sub] = struct{}{}
+	return sub, missed
+}
+
+// Close 取消订阅
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.removeLocked(s)
+}
+
+func (b *Bus) removeLocked(sub *Subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	delete(b.subscribers, sub)
+	close(sub.ch)
+}
+
+// SubscriberCount 当前订阅数
+func (b *Bus) SubscriberCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subscribers)
+}
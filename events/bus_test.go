@@ -0,0 +1,62 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBus_FilterAndOrder(t *testing.T) {
+	bus := NewBus(10)
+	sub, missed := bus.Subscribe(func(e Event) bool { return e.UserID == "u1" }, 0, 8)
+	defer sub.Close()
+	assert.Empty(t, missed)
+
+	bus.Publish(Event{Type: TypeCycleStarted, UserID: "u1"})
+	bus.Publish(Event{Type: TypeCycleStarted, UserID: "u2"})
+	bus.Publish(Event{Type: TypeCycleFinished, UserID: "u1"})
+
+	first := <-sub.C
+	second := <-sub.C
+	assert.Equal(t, TypeCycleStarted, first.Type)
+	assert.Equal(t, TypeCycleFinished, second.Type)
+	assert.Equal(t, uint64(1), first.ID)
+	assert.Equal(t, uint64(3), second.ID)
+	assert.False(t, first.Timestamp.IsZero())
+	assert.Len(t, sub.C, 0, "其他用户的事件不应投递")
+}
+
+func TestBus_ReplayMissedEvents(t *testing.T) {
+	bus := NewBus(3)
+	for i := 0; i < 5; i++ {
+		bus.Publish(Event{Type: TypeBalanceUpdate, UserID: "u1"})
+	}
+
+	sub, missed := bus.Subscribe(nil, 3, 8)
+	defer sub.Close()
+	require.Len(t, missed, 2)
+	assert.Equal(t, uint64(4), missed[0].ID)
+	assert.Equal(t, uint64(5), missed[1].ID)
+
+	// 超出历史范围时只能补发保留的部分
+	sub2, missed2 := bus.Subscribe(nil, 1, 8)
+	defer sub2.Close()
+	assert.Len(t, missed2, 3)
+}
+
+func TestBus_SlowSubscriberClosed(t *testing.T) {
+	bus := NewBus(10)
+	sub, _ := bus.Subscribe(nil, 0, 1)
+
+	bus.Publish(Event{Type: TypeDecision})
+	bus.Publish(Event{Type: TypeDecision})
+
+	_, ok := <-sub.C
+	assert.True(t, ok)
+	_, ok = <-sub.C
+	assert.False(t, ok, "缓冲区满后订阅应被关闭")
+	assert.Equal(t, 0, bus.SubscriberCount())
+
+	sub.Close() // 重复关闭安全
+}
@@ -0,0 +1,266 @@
+package logger
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxAttributionRecords 生成归因报告时最多读取的决策记录数
+const maxAttributionRecords = 10000
+
+// EntrySnapshot 开仓时的行情快照（指标与形态）
+type EntrySnapshot struct {
+	Price       float64             `json:"price"`
+	Change1h    float64             `json:"change_1h"`
+	Change4h    float64             `json:"change_4h"`
+	RSI7        float64             `json:"rsi7"`
+	MACD        float64             `json:"macd"`
+	FundingRate float64             `json:"funding_rate"`
+	Regime      string              `json:"regime,omitempty"`     // 市场状态（趋势/波动）
+	Indicators  map[string]float64  `json:"indicators,omitempty"` // 自定义指标最新值（周期 指标 线 → 值）
+	Timeframes  []TimeframeSnapshot `json:"timeframes,omitempty"`
+}
+
+// TimeframeSnapshot 单个周期的形态识别结果
+type TimeframeSnapshot struct {
+	Interval string   `json:"interval"`
+	Bias     string   `json:"bias"` // bullish/bearish/neutral
+	Patterns []string `json:"patterns,omitempty"`
+}
+
+// attachEntry 将开仓决策的信心度、风险与行情快照附加到交易结果
+func (t *TradeOutcome) attachEntry(action DecisionAction) {
+	t.Confidence = action.Confidence
+	t.RiskUSD = action.RiskUSD
+	t.OpenReason = action.Reason
+	t.Entry = action.Entry
+}
+
+// AttributionBucket 按某一维度聚合的交易表现
+type AttributionBucket struct {
+	Key       string  `json:"key"`
+	Trades    int     `json:"trades"`
+	Wins      int     `json:"wins"`
+	Losses    int     `json:"losses"`
+	WinRate   float64 `json:"win_rate"` // 胜率（%）
+	TotalPnL  float64 `json:"total_pnl"`
+	AvgPnL    float64 `json:"avg_pnl"`
+	AvgPnLPct float64 `json:"avg_pnl_pct"`
+
+	totalPnLPct float64
+}
+
+// AttributionReport 交易归因报告
+type AttributionReport struct {
+	TraderID     string               `json:"trader_id"`
+	TotalTrades  int                  `json:"total_trades"`
+	TotalPnL     float64              `json:"total_pnl"`
+	ByConfidence []*AttributionBucket `json:"by_confidence"` // 按信心度区间
+	ByPattern    []*AttributionBucket `json:"by_pattern"`    // 按开仓时出现的形态
+	ByTimeframe  []*AttributionBucket `json:"by_timeframe"`  // 按周期方向是否与开仓方向一致（如 4h:aligned）
+	ByRegime     []*AttributionBucket `json:"by_regime"`     // 按开仓时的市场状态
+	Trades       []TradeOutcome       `json:"trades"`
+	GeneratedAt  time.Time            `json:"generated_at"`
+}
+
+// confidenceBuckets 信心度区间（按顺序输出）
+var confidenceBuckets = []string{"unknown", "<60", "60-69", "70-79", "80-89", "90+"}
+
+// confidenceBucket 信心度所属区间（旧记录没有信心度时为 unknown）
+func confidenceBucket(confidence int) string {
+	switch {
+	case confidence <= 0:
+		return "unknown"
+	case confidence < 60:
+		return "<60"
+	case confidence < 70:
+		return "60-69"
+	case confidence < 80:
+		return "70-79"
+	case confidence < 90:
+		return "80-89"
+	default:
+		return "90+"
+	}
+}
+
+// timeframeAlignment 周期形态方向相对开仓方向：aligned/against/neutral
+func timeframeAlignment(side, bias string) string {
+	switch {
+	case bias == "bullish" && side == "long", bias == "bearish" && side == "short":
+		return "aligned"
+	case bias == "bullish" || bias == "bearish":
+		return "against"
+	default:
+		return "neutral"
+	}
+}
+
+// BuildAttributionReport 将已平仓交易与开仓决策关联并按信心度、形态、周期、市场状态聚合
+func BuildAttributionReport(traderID string, trades []TradeOutcome) *AttributionReport {
+	report := &AttributionReport{
+		TraderID:    traderID,
+		TotalTrades: len(trades),
+		Trades:      trades,
+		GeneratedAt: time.Now(),
+	}
+	if report.Trades == nil {
+		report.Trades = []TradeOutcome{}
+	}
+
+	confidence := map[string]*AttributionBucket{}
+	patterns := map[string]*AttributionBucket{}
+	timeframes := map[string]*AttributionBucket{}
+	regimes := map[string]*AttributionBucket{}
+
+	for _, trade := range trades {
+		report.TotalPnL += trade.PnL
+		addToBucket(confidence, confidenceBucket(trade.Confidence), trade)
+
+		regime := "unknown"
+		if trade.Entry != nil && trade.Entry.Regime != "" {
+			regime = trade.Entry.Regime
+		}
+		addToBucket(regimes, regime, trade)
+		if trade.Entry == nil {
+			continue
+		}
+
+		// 同一笔交易在多个周期出现同一形态时只计一次
+		seen := map[string]bool{}
+		for _, tf := range trade.Entry.Timeframes {
+			addToBucket(timeframes, tf.Interval+":"+timeframeAlignment(trade.Side, tf.Bias), trade)
+			for _, name := range tf.Patterns {
+				if !seen[name] {
+					seen[name] = true
+					addToBucket(patterns, name, trade)
+				}
+			}
+		}
+	}
+
+	report.ByConfidence = []*AttributionBucket{}
+	for _, key := range confidenceBuckets {
+		if bucket, ok := confidence[key]; ok {
+			report.ByConfidence = append(report.ByConfidence, finishBucket(bucket))
+		}
+	}
+	report.ByPattern = sortedBuckets(patterns)
+	report.ByTimeframe = sortedBuckets(timeframes)
+	report.ByRegime = sortedBuckets(regimes)
+	return report
+}
+
+// addToBucket 将交易计入对应分组
+func addToBucket(buckets map[string]*AttributionBucket, key string, trade TradeOutcome) {
+	bucket, ok := buckets[key]
+	if !ok {
+		bucket = &AttributionBucket{Key: key}
+		buckets[key] = bucket
+	}
+	bucket.Trades++
+	if trade.PnL > 0 {
+		bucket.Wins++
+	} else if trade.PnL < 0 {
+		bucket.Losses++
+	}
+	bucket.TotalPnL += trade.PnL
+	bucket.totalPnLPct += trade.PnLPct
+}
+
+// finishBucket 计算分组的胜率与平均值
+func finishBucket(bucket *AttributionBucket) *AttributionBucket {
+	if bucket.Trades > 0 {
+		bucket.WinRate = float64(bucket.Wins) / float64(bucket.Trades) * 100
+		bucket.AvgPnL = bucket.TotalPnL / float64(bucket.Trades)
+		bucket.AvgPnLPct = bucket.totalPnLPct / float64(bucket.Trades)
+	}
+	return bucket
+}
+
+// sortedBuckets 按总盈亏从高到低排序（相同时按名称）
+func sortedBuckets(buckets map[string]*AttributionBucket) []*AttributionBucket {
+	result := make([]*AttributionBucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		result = append(result, finishBucket(bucket))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].TotalPnL != result[j].TotalPnL {
+			return result[i].TotalPnL > result[j].TotalPnL
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result
+}
+
+// AnalyzeAttribution 读取交易员的全部决策记录并生成归因报告
+func AnalyzeAttribution(traderID string, l IDecisionLogger) (*AttributionReport, error) {
+	records, err := l.GetLatestRecords(maxAttributionRecords)
+	if err != nil {
+		return nil, fmt.Errorf("读取决策记录失败: %w", err)
+	}
+	if len(records) == 0 {
+		return BuildAttributionReport(traderID, nil), nil
+	}
+	return BuildAttributionReport(traderID, l.AnalyzeRecords(records).RecentTrades), nil
+}
+
+// WriteAttributionCSV 以 CSV 导出逐笔归因明细
+func WriteAttributionCSV(w io.Writer, trades []TradeOutcome) error {
+	writer := csv.NewWriter(w)
+	_ = writer.Write([]string{
+		"symbol", "side", "open_time", "close_time", "open_price", "close_price", "pnl", "pnl_pct",
+		"confidence", "confidence_bucket", "risk_usd", "regime", "patterns", "timeframes",
+		"rsi7", "macd", "funding_rate", "was_stop_loss", "prompt_version", "open_reason",
+	})
+	for _, trade := range trades {
+		var regime, rsi, macd, funding string
+		var patterns, timeframes []string
+		if entry := trade.Entry; entry != nil {
+			regime = entry.Regime
+			rsi = formatCSVFloat(entry.RSI7)
+			macd = formatCSVFloat(entry.MACD)
+			funding = formatCSVFloat(entry.FundingRate)
+			for _, tf := range entry.Timeframes {
+				timeframes = append(timeframes, tf.Interval+":"+timeframeAlignment(trade.Side, tf.Bias))
+				for _, name := range tf.Patterns {
+					patterns = append(patterns, tf.Interval+" "+name)
+				}
+			}
+		}
+		_ = writer.Write([]string{
+			trade.Symbol,
+			trade.Side,
+			trade.OpenTime.UTC().Format(time.RFC3339),
+			trade.CloseTime.UTC().Format(time.RFC3339),
+			formatCSVFloat(trade.OpenPrice),
+			formatCSVFloat(trade.ClosePrice),
+			formatCSVFloat(trade.PnL),
+			formatCSVFloat(trade.PnLPct),
+			strconv.Itoa(trade.Confidence),
+			confidenceBucket(trade.Confidence),
+			formatCSVFloat(trade.RiskUSD),
+			regime,
+			strings.Join(patterns, "; "),
+			strings.Join(timeframes, "; "),
+			rsi,
+			macd,
+			funding,
+			strconv.FormatBool(trade.WasStopLoss),
+			trade.PromptVersion,
+			trade.OpenReason,
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// formatCSVFloat 格式化浮点数（不丢失精度且不使用科学计数法）
+func formatCSVFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
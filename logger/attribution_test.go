@@ -0,0 +1,145 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+)
+
+// logAttributedTrade 记录一笔带开仓快照的交易（开仓周期 + 平仓周期）
+func logAttributedTrade(t *testing.T, l IDecisionLogger, symbol string, confidence int, entry *EntrySnapshot, openPrice, closePrice float64) {
+	t.Helper()
+	now := time.Now()
+	records := []*DecisionRecord{
+		{
+			Success:  true,
+			Exchange: "binance",
+			Decisions: []DecisionAction{{
+				Action: "open_long", Symbol: symbol, Quantity: 1, Leverage: 5, Price: openPrice, Timestamp: now, Success: true,
+				Reason: "突破确认", Confidence: confidence, RiskUSD: 20, Entry: entry,
+			}},
+		},
+		{
+			Success:  true,
+			Exchange: "binance",
+			Decisions: []DecisionAction{
+				{Action: "close_long", Symbol: symbol, Price: closePrice, Timestamp: now.Add(time.Minute), Success: true},
+			},
+		},
+	}
+	for _, record := range records {
+		if err := l.LogDecision(record); err != nil {
+			t.Fatalf("记录决策失败: %v", err)
+		}
+	}
+}
+
+// TestAnalyzeAttribution 测试平仓结果关联开仓决策，并按信心度、形态、周期、市场状态聚合
+func TestAnalyzeAttribution(t *testing.T) {
+	l := NewDecisionLogger(t.TempDir())
+	logAttributedTrade(t, l, "BTCUSDT", 85, &EntrySnapshot{
+		Price: 100, RSI7: 62, Regime: "uptrend/normal",
+		Timeframes: []TimeframeSnapshot{
+			{Interval: "1h", Bias: "bullish", Patterns: []string{"看涨吞没"}},
+			{Interval: "4h", Bias: "bullish", Patterns: []string{"看涨吞没", "锤子线"}},
+		},
+	}, 100, 110)
+	logAttributedTrade(t, l, "ETHUSDT", 65, &EntrySnapshot{
+		Price: 100, Regime: "range/high",
+		Timeframes: []TimeframeSnapshot{{Interval: "4h", Bias: "bearish", Patterns: []string{"黄昏星"}}},
+	}, 100, 95)
+	logAttributedTrade(t, l, "SOLUSDT", 0, nil, 100, 101) // 旧记录：没有信心度与快照
+
+	report, err := AnalyzeAttribution("t1", l)
+	if err != nil {
+		t.Fatalf("生成归因报告失败: %v", err)
+	}
+	if report.TotalTrades != 3 || len(report.Trades) != 3 {
+		t.Fatalf("应有3笔交易，实际 %d", report.TotalTrades)
+	}
+
+	var btc TradeOutcome
+	for _, trade := range report.Trades {
+		if trade.Symbol == "BTCUSDT" {
+			btc = trade
+		}
+	}
+	if btc.Confidence != 85 || btc.RiskUSD != 20 || btc.OpenReason != "突破确认" || btc.Entry == nil || btc.Entry.RSI7 != 62 {
+		t.Errorf("平仓结果应带上开仓决策信息: %+v", btc)
+	}
+
+	buckets := func(list []*AttributionBucket) map[string]*AttributionBucket {
+		m := map[string]*AttributionBucket{}
+		for _, b := range list {
+			m[b.Key] = b
+		}
+		return m
+	}
+
+	if len(report.ByConfidence) != 3 || report.ByConfidence[0].Key != "unknown" || report.ByConfidence[2].Key != "80-89" {
+		t.Errorf("信心度区间应按顺序输出: %+v", report.ByConfidence)
+	}
+	if b := buckets(report.ByConfidence)["80-89"]; b.Trades != 1 || b.WinRate != 100 || b.TotalPnL <= 0 {
+		t.Errorf("80-89 区间统计不正确: %+v", b)
+	}
+
+	patterns := buckets(report.ByPattern)
+	if b := patterns["看涨吞没"]; b == nil || b.Trades != 1 {
+		t.Errorf("同一交易多个周期出现同一形态只应计一次: %+v", b)
+	}
+	if b := patterns["黄昏星"]; b == nil || b.Losses != 1 || b.WinRate != 0 {
+		t.Errorf("黄昏星统计不正确: %+v", b)
+	}
+	if report.ByPattern[len(report.ByPattern)-1].Key != "黄昏星" {
+		t.Errorf("形态应按总盈亏从高到低排序: %+v", report.ByPattern)
+	}
+
+	timeframes := buckets(report.ByTimeframe)
+	if timeframes["4h:aligned"] == nil || timeframes["4h:against"] == nil || timeframes["1h:aligned"] == nil {
+		t.Errorf("周期方向应相对开仓方向归类: %+v", report.ByTimeframe)
+	}
+	if b := buckets(report.ByRegime)["unknown"]; b == nil || b.Trades != 1 {
+		t.Errorf("没有快照的交易应归入 unknown 市场状态: %+v", report.ByRegime)
+	}
+}
+
+// TestWriteAttributionCSV 测试逐笔归因 CSV 导出
+func TestWriteAttributionCSV(t *testing.T) {
+	trades := []TradeOutcome{
+		{
+			Symbol: "BTCUSDT", Side: "short", OpenPrice: 100, ClosePrice: 90, PnL: 10, PnLPct: 50,
+			Confidence: 72, RiskUSD: 15, OpenReason: "顶部背离, 放量",
+			Entry: &EntrySnapshot{Regime: "downtrend/high", Timeframes: []TimeframeSnapshot{
+				{Interval: "1h", Bias: "bearish", Patterns: []string{"黄昏星"}},
+				{Interval: "4h", Bias: "bullish"},
+			}},
+		},
+		{Symbol: "ETHUSDT", Side: "long", PnL: -1},
+	}
+
+	var buf bytes.Buffer
+	if err := WriteAttributionCSV(&buf, trades); err != nil {
+		t.Fatalf("导出失败: %v", err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("CSV 格式错误: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("应有表头 + 2 行，实际 %d 行", len(rows))
+	}
+
+	header := map[string]int{}
+	for i, name := range rows[0] {
+		header[name] = i
+	}
+	row := rows[1]
+	if row[header["confidence_bucket"]] != "70-79" || row[header["patterns"]] != "1h 黄昏星" ||
+		row[header["timeframes"]] != "1h:aligned; 4h:against" || row[header["open_reason"]] != "顶部背离, 放量" {
+		t.Errorf("归因明细不正确: %v", row)
+	}
+	if rows[2][header["confidence_bucket"]] != "unknown" || rows[2][header["regime"]] != "" {
+		t.Errorf("没有开仓信息的交易应留空: %v", rows[2])
+	}
+}
@@ -0,0 +1,136 @@
+package logger
+
+import (
+	"fmt"
+	"time"
+)
+
+// ConfidenceBucket 单个信心度十分位的实际表现
+type ConfidenceBucket struct {
+	Range         string  `json:"range"` // 例如 "70-79"
+	MinConfidence int     `json:"min_confidence"`
+	MaxConfidence int     `json:"max_confidence"`
+	Trades        int     `json:"trades"`
+	Wins          int     `json:"wins"`
+	Losses        int     `json:"losses"`
+	WinRate       float64 `json:"win_rate"`       // 实际胜率（%）
+	AvgWinPct     float64 `json:"avg_win_pct"`    // 盈利交易平均收益率（%，相对保证金）
+	AvgLossPct    float64 `json:"avg_loss_pct"`   // 亏损交易平均亏损率（%，正数）
+	PayoffRatio   float64 `json:"payoff_ratio"`   // 盈亏比（平均盈利 / 平均亏损）
+	Expectancy    float64 `json:"expectancy"`     // 单笔期望盈亏（USDT）
+	ExpectancyPct float64 `json:"expectancy_pct"` // 单笔期望收益率（%，相对保证金）
+	Kelly         float64 `json:"kelly"`          // 全额Kelly比例（0-1，无正期望或没有亏损交易时为0）
+}
+
+// CalibrationReport 信心度校准报告：AI给出的信心度与实际胜率/期望的对照
+type CalibrationReport struct {
+	TraderID    string              `json:"trader_id"`
+	TotalTrades int                 `json:"total_trades"`
+	RatedTrades int                 `json:"rated_trades"` // 带有信心度的交易数
+	Buckets     []*ConfidenceBucket `json:"buckets"`      // 0-9 ... 90-100 共10个区间
+	GeneratedAt time.Time           `json:"generated_at"`
+}
+
+// confidenceDecile 信心度所在的十分位（100 归入 90-100）
+func confidenceDecile(confidence int) int {
+	decile := confidence / 10
+	if decile > 9 {
+		decile = 9
+	}
+	if decile < 0 {
+		decile = 0
+	}
+	return decile
+}
+
+// KellyFraction 全额Kelly比例 f* = p - (1-p)/b（p 为胜率 0-1，b 为盈亏比），无正期望时返回0
+func KellyFraction(winRate, payoffRatio float64) float64 {
+	if payoffRatio <= 0 {
+		return 0
+	}
+	f := winRate - (1-winRate)/payoffRatio
+	if f < 0 {
+		return 0
+	}
+	if f > 1 {
+		return 1
+	}
+	return f
+}
+
+// BuildCalibrationReport 按信心度十分位统计已平仓交易的实际胜率与期望（没有信心度的旧交易不参与校准）
+func BuildCalibrationReport(traderID string, trades []TradeOutcome) *CalibrationReport {
+	report := &CalibrationReport{
+		TraderID:    traderID,
+		TotalTrades: len(trades),
+		Buckets:     make([]*ConfidenceBucket, 10),
+		GeneratedAt: time.Now(),
+	}
+	for i := range report.Buckets {
+		max := i*10 + 9
+		if i == 9 {
+			max = 100
+		}
+		report.Buckets[i] = &ConfidenceBucket{Range: fmt.Sprintf("%d-%d", i*10, max), MinConfidence: i * 10, MaxConfidence: max}
+	}
+
+	totalWinPct := make([]float64, 10)
+	totalLossPct := make([]float64, 10)
+	totalPnLPct := make([]float64, 10)
+	for _, trade := range trades {
+		if trade.Confidence <= 0 {
+			continue
+		}
+		report.RatedTrades++
+		i := confidenceDecile(trade.Confidence)
+		bucket := report.Buckets[i]
+		bucket.Trades++
+		bucket.Expectancy += trade.PnL
+		totalPnLPct[i] += trade.PnLPct
+		if trade.PnL > 0 {
+			bucket.Wins++
+			totalWinPct[i] += trade.PnLPct
+		} else if trade.PnL < 0 {
+			bucket.Losses++
+			totalLossPct[i] -= trade.PnLPct
+		}
+	}
+
+	for i, bucket := range report.Buckets {
+		if bucket.Trades == 0 {
+			continue
+		}
+		bucket.WinRate = float64(bucket.Wins) / float64(bucket.Trades) * 100
+		bucket.Expectancy /= float64(bucket.Trades)
+		bucket.ExpectancyPct = totalPnLPct[i] / float64(bucket.Trades)
+		if bucket.Wins > 0 {
+			bucket.AvgWinPct = totalWinPct[i] / float64(bucket.Wins)
+		}
+		if bucket.Losses > 0 {
+			bucket.AvgLossPct = totalLossPct[i] / float64(bucket.Losses)
+		}
+		// 没有亏损交易时盈亏比无法估计，视为样本不足，Kelly 保持为0（由仓位计算沿用AI仓位）
+		if bucket.AvgLossPct > 0 {
+			bucket.PayoffRatio = bucket.AvgWinPct / bucket.AvgLossPct
+			bucket.Kelly = KellyFraction(bucket.WinRate/100, bucket.PayoffRatio)
+		}
+	}
+	return report
+}
+
+// Bucket 返回信心度所在区间的校准结果
+func (r *CalibrationReport) Bucket(confidence int) *ConfidenceBucket {
+	return r.Buckets[confidenceDecile(confidence)]
+}
+
+// AnalyzeCalibration 读取交易员的全部决策记录并生成信心度校准报告
+func AnalyzeCalibration(traderID string, l IDecisionLogger) (*CalibrationReport, error) {
+	records, err := l.GetLatestRecords(maxAttributionRecords)
+	if err != nil {
+		return nil, fmt.Errorf("读取决策记录失败: %w", err)
+	}
+	if len(records) == 0 {
+		return BuildCalibrationReport(traderID, nil), nil
+	}
+	return BuildCalibrationReport(traderID, l.AnalyzeRecords(records).RecentTrades), nil
+}
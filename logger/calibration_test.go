@@ -0,0 +1,61 @@
+package logger
+
+import (
+	"math"
+	"testing"
+)
+
+// TestBuildCalibrationReport 测试按信心度十分位统计实际胜率、期望与Kelly比例
+func TestBuildCalibrationReport(t *testing.T) {
+	trades := []TradeOutcome{
+		{Confidence: 72, PnL: 20, PnLPct: 10},
+		{Confidence: 75, PnL: 20, PnLPct: 10},
+		{Confidence: 79, PnL: -10, PnLPct: -5},
+		{Confidence: 100, PnL: 5, PnLPct: 2},
+		{Confidence: 0, PnL: -50, PnLPct: -20}, // 旧记录没有信心度，不参与校准
+	}
+
+	report := BuildCalibrationReport("t1", trades)
+	if len(report.Buckets) != 10 || report.TotalTrades != 5 || report.RatedTrades != 4 {
+		t.Fatalf("统计数量不正确: %+v", report)
+	}
+
+	b := report.Bucket(75)
+	if b.Range != "70-79" || b.Trades != 3 || b.Wins != 2 || b.Losses != 1 {
+		t.Fatalf("70-79 区间统计不正确: %+v", b)
+	}
+	if math.Abs(b.WinRate-200.0/3) > 1e-9 || b.AvgWinPct != 10 || b.AvgLossPct != 5 || b.PayoffRatio != 2 {
+		t.Errorf("胜率或盈亏比不正确: %+v", b)
+	}
+	if math.Abs(b.Expectancy-10) > 1e-9 || math.Abs(b.ExpectancyPct-5) > 1e-9 {
+		t.Errorf("期望不正确: %+v", b)
+	}
+	// f* = p - (1-p)/b = 2/3 - (1/3)/2 = 0.5
+	if math.Abs(b.Kelly-0.5) > 1e-9 {
+		t.Errorf("Kelly比例应为0.5，实际 %.4f", b.Kelly)
+	}
+
+	if top := report.Bucket(100); top.Range != "90-100" || top.Trades != 1 || top.Kelly != 0 {
+		t.Errorf("信心度100应归入90-100区间，且没有亏损交易时Kelly为0: %+v", top)
+	}
+	if empty := report.Bucket(30); empty.Trades != 0 || empty.Kelly != 0 {
+		t.Errorf("无交易的区间应为空: %+v", empty)
+	}
+}
+
+// TestKellyFraction 测试Kelly比例在无正期望时为0且不超过1
+func TestKellyFraction(t *testing.T) {
+	tests := []struct {
+		winRate, payoff, want float64
+	}{
+		{0.6, 2, 0.4},
+		{0.25, 1, 0},
+		{0.5, 0, 0},
+		{1, 10, 1},
+	}
+	for _, tt := range tests {
+		if got := KellyFraction(tt.winRate, tt.payoff); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("KellyFraction(%.2f, %.2f) = %.4f，期望 %.4f", tt.winRate, tt.payoff, got, tt.want)
+		}
+	}
+}
@@ -28,6 +28,21 @@ type DecisionRecord struct {
 	ErrorMessage   string             `json:"error_message"`   // 错误信息（如果有）
 	// AIRequestDurationMs 记录 AI API 调用耗时（毫秒），方便评估调用性能
 	AIRequestDurationMs int64 `json:"ai_request_duration_ms,omitempty"`
+	// TriggeredBy 记录触发来源：空值表示AI决策周期，"manual" 表示用户手动操作，"approval" 表示审批提案的处理结果
+	TriggeredBy string `json:"triggered_by,omitempty"`
+	// PromptTokens 估算的提示词 token 数（system + user），PromptTokenBudget 为当时生效的预算
+	PromptTokens      int `json:"prompt_tokens,omitempty"`
+	PromptTokenBudget int `json:"prompt_token_budget,omitempty"`
+	// DroppedPromptSections 因超出 token 预算被降级或省略的段落
+	DroppedPromptSections []string `json:"dropped_prompt_sections,omitempty"`
+	// PromptTemplate/PromptTemplateVersion 系统提示词模板名称及版本哈希（用于按模板版本归因表现）
+	PromptTemplate        string `json:"prompt_template,omitempty"`
+	PromptTemplateVersion string `json:"prompt_template_version,omitempty"`
+	// Regime 本周期确认后的市场状态（trending/ranging/high_volatility/risk_off）
+	Regime string `json:"regime,omitempty"`
+	// ExperimentID/ExperimentArm 所属A/B实验及分组（对照组为 "control"，实验组为模拟下单）
+	ExperimentID  string `json:"experiment_id,omitempty"`
+	ExperimentArm string `json:"experiment_arm,omitempty"`
 }
 
 // AccountSnapshot 账户状态快照
@@ -66,6 +81,14 @@ type DecisionAction struct {
 	Reason      string    `json:"reason,omitempty"`       // AI给出的原始理由
 	CloseReason string    `json:"close_reason,omitempty"` // take_profit / stop_loss / partial_close / manual
 	PnL         float64   `json:"pnl,omitempty"`          // 平仓前的未实现盈亏（用于识别止盈/止损）
+
+	// 开仓决策的信心度、最大美元风险与开仓时的行情快照（用于交易归因）
+	Confidence int            `json:"confidence,omitempty"`
+	RiskUSD    float64        `json:"risk_usd,omitempty"`
+	Entry      *EntrySnapshot `json:"entry,omitempty"`
+
+	ProposalID     string `json:"proposal_id,omitempty"`     // 人工审批提案ID（需要审批的开仓）
+	ProposalStatus string `json:"proposal_status,omitempty"` // pending / approved / rejected / expired
 }
 
 // IDecisionLogger 决策日志记录器接口
@@ -82,6 +105,8 @@ type IDecisionLogger interface {
 	GetStatistics() (*Statistics, error)
 	// AnalyzePerformance 分析最近N个周期的交易表现
 	AnalyzePerformance(lookbackCycles int) (*PerformanceAnalysis, error)
+	// AnalyzeRecords 分析指定决策记录的交易表现
+	AnalyzeRecords(records []*DecisionRecord) *PerformanceAnalysis
 }
 
 // DecisionLogger 决策日志记录器
@@ -301,20 +326,26 @@ type Statistics struct {
 
 // TradeOutcome 单笔交易结果
 type TradeOutcome struct {
-	Symbol        string    `json:"symbol"`         // 币种
-	Side          string    `json:"side"`           // long/short
-	Quantity      float64   `json:"quantity"`       // 仓位数量
-	Leverage      int       `json:"leverage"`       // 杠杆倍数
-	OpenPrice     float64   `json:"open_price"`     // 开仓价
-	ClosePrice    float64   `json:"close_price"`    // 平仓价
-	PositionValue float64   `json:"position_value"` // 仓位价值（quantity × openPrice）
-	MarginUsed    float64   `json:"margin_used"`    // 保证金使用（positionValue / leverage）
-	PnL           float64   `json:"pn_l"`           // 盈亏（USDT）
-	PnLPct        float64   `json:"pn_l_pct"`       // 盈亏百分比（相对保证金）
-	Duration      string    `json:"duration"`       // 持仓时长
-	OpenTime      time.Time `json:"open_time"`      // 开仓时间
-	CloseTime     time.Time `json:"close_time"`     // 平仓时间
-	WasStopLoss   bool      `json:"was_stop_loss"`  // 是否止损
+	Symbol        string    `json:"symbol"`                   // 币种
+	Side          string    `json:"side"`                     // long/short
+	Quantity      float64   `json:"quantity"`                 // 仓位数量
+	Leverage      int       `json:"leverage"`                 // 杠杆倍数
+	OpenPrice     float64   `json:"open_price"`               // 开仓价
+	ClosePrice    float64   `json:"close_price"`              // 平仓价
+	PositionValue float64   `json:"position_value"`           // 仓位价值（quantity × openPrice）
+	MarginUsed    float64   `json:"margin_used"`              // 保证金使用（positionValue / leverage）
+	PnL           float64   `json:"pn_l"`                     // 盈亏（USDT）
+	PnLPct        float64   `json:"pn_l_pct"`                 // 盈亏百分比（相对保证金）
+	Duration      string    `json:"duration"`                 // 持仓时长
+	OpenTime      time.Time `json:"open_time"`                // 开仓时间
+	CloseTime     time.Time `json:"close_time"`               // 平仓时间
+	WasStopLoss   bool      `json:"was_stop_loss"`            // 是否止损
+	PromptVersion string    `json:"prompt_version,omitempty"` // 开仓时的系统提示词模板版本（模板名@版本哈希）
+	// 开仓决策信息（用于交易归因，旧记录没有时为空）
+	Confidence int            `json:"confidence,omitempty"`  // 开仓信心度
+	RiskUSD    float64        `json:"risk_usd,omitempty"`    // 开仓时的最大美元风险
+	OpenReason string         `json:"open_reason,omitempty"` // AI给出的开仓理由
+	Entry      *EntrySnapshot `json:"entry,omitempty"`       // 开仓时的行情快照
 }
 
 // PerformanceAnalysis 交易表现分析
@@ -331,6 +362,38 @@ type PerformanceAnalysis struct {
 	SymbolStats   map[string]*SymbolPerformance `json:"symbol_stats"`   // 各币种表现
 	BestSymbol    string                        `json:"best_symbol"`    // 表现最好的币种
 	WorstSymbol   string                        `json:"worst_symbol"`   // 表现最差的币种
+	// PromptVersionStats 按开仓时的系统提示词模板版本（模板名@版本哈希）统计的表现
+	PromptVersionStats map[string]*SymbolPerformance `json:"prompt_version_stats,omitempty"`
+}
+
+// promptVersionKey 决策记录对应的模板版本标识（旧记录没有版本信息时为空）
+func promptVersionKey(record *DecisionRecord) string {
+	if record.PromptTemplateVersion == "" {
+		return ""
+	}
+	return record.PromptTemplate + "@" + record.PromptTemplateVersion
+}
+
+// addPromptVersionTrade 把一笔已平仓交易计入对应模板版本的统计
+func (a *PerformanceAnalysis) addPromptVersionTrade(outcome TradeOutcome) {
+	if outcome.PromptVersion == "" {
+		return
+	}
+	if a.PromptVersionStats == nil {
+		a.PromptVersionStats = make(map[string]*SymbolPerformance)
+	}
+	stats, exists := a.PromptVersionStats[outcome.PromptVersion]
+	if !exists {
+		stats = &SymbolPerformance{Symbol: outcome.PromptVersion}
+		a.PromptVersionStats[outcome.PromptVersion] = stats
+	}
+	stats.TotalTrades++
+	stats.TotalPnL += outcome.PnL
+	if outcome.PnL > 0 {
+		stats.WinningTrades++
+	} else if outcome.PnL < 0 {
+		stats.LosingTrades++
+	}
 }
 
 // SymbolPerformance 币种表现统计
@@ -377,6 +440,23 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 		}, nil
 	}
 
+	// 为了避免开仓记录在窗口外导致匹配失败，需要先从所有历史记录中找出未平仓的持仓
+	// 获取更多历史记录来构建完整的持仓状态（使用更大的窗口）
+	allRecords, err := l.GetLatestRecords(lookbackCycles * 3) // 扩大3倍窗口
+	if err != nil {
+		allRecords = nil
+	}
+	return l.analyzeRecords(records, allRecords, 10), nil
+}
+
+// AnalyzeRecords 分析指定决策记录的交易表现（保留全部交易，用于实验报告等按条件筛选的记录）
+func (l *DecisionLogger) AnalyzeRecords(records []*DecisionRecord) *PerformanceAnalysis {
+	return l.analyzeRecords(records, nil, 0)
+}
+
+// analyzeRecords 配对开平仓记录并计算交易表现
+// allRecords 为包含更早记录的扩大窗口（仅用于补全窗口外的开仓信息），maxTrades<=0 时保留全部交易
+func (l *DecisionLogger) analyzeRecords(records, allRecords []*DecisionRecord, maxTrades int) *PerformanceAnalysis {
 	analysis := &PerformanceAnalysis{
 		RecentTrades: []TradeOutcome{},
 		SymbolStats:  make(map[string]*SymbolPerformance),
@@ -385,10 +465,7 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 	// 追踪持仓状态：symbol_side -> {side, openPrice, openTime, quantity, leverage}
 	openPositions := make(map[string]map[string]interface{})
 
-	// 为了避免开仓记录在窗口外导致匹配失败，需要先从所有历史记录中找出未平仓的持仓
-	// 获取更多历史记录来构建完整的持仓状态（使用更大的窗口）
-	allRecords, err := l.GetLatestRecords(lookbackCycles * 3) // 扩大3倍窗口
-	if err == nil && len(allRecords) > len(records) {
+	if len(allRecords) > len(records) {
 		// 先从扩大的窗口中收集所有开仓记录
 		for _, record := range allRecords {
 			for _, action := range record.Decisions {
@@ -420,11 +497,13 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 				case "open_long", "open_short":
 					// 记录开仓
 					openPositions[posKey] = map[string]interface{}{
-						"side":      side,
-						"openPrice": action.Price,
-						"openTime":  action.Timestamp,
-						"quantity":  action.Quantity,
-						"leverage":  action.Leverage,
+						"side":          side,
+						"openPrice":     action.Price,
+						"openTime":      action.Timestamp,
+						"quantity":      action.Quantity,
+						"leverage":      action.Leverage,
+						"promptVersion": promptVersionKey(record),
+						"entryAction":   action,
 					}
 				case "close_long", "close_short", "auto_close_long", "auto_close_short":
 					// Remove closed position records
@@ -476,6 +555,8 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 					"accumulatedPnL":     0.0,             // 🔧 BUG FIX：累積部分平倉盈虧
 					"partialCloseCount":  0,               // 🔧 BUG FIX：部分平倉次數
 					"partialCloseVolume": 0.0,             // 🔧 BUG FIX：部分平倉總量
+					"promptVersion":      promptVersionKey(record),
+					"entryAction":        action,
 				}
 
 			case "close_long", "close_short", "partial_close", "auto_close_long", "auto_close_short":
@@ -486,6 +567,8 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 					side := openPos["side"].(string)
 					quantity := openPos["quantity"].(float64)
 					leverage := openPos["leverage"].(int)
+					promptVersion, _ := openPos["promptVersion"].(string)
+					entryAction, _ := openPos["entryAction"].(DecisionAction)
 
 					// 🔧 BUG FIX：取得追蹤字段（若不存在則初始化）
 					remainingQty, _ := openPos["remainingQuantity"].(float64)
@@ -556,9 +639,12 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 								Duration:      action.Timestamp.Sub(openTime).String(),
 								OpenTime:      openTime,
 								CloseTime:     action.Timestamp,
+								PromptVersion: promptVersion,
 							}
+							outcome.attachEntry(entryAction)
 
 							analysis.RecentTrades = append(analysis.RecentTrades, outcome)
+							analysis.addPromptVersionTrade(outcome)
 							analysis.TotalTrades++ // 🔧 Only count when fully closed
 
 							// 分类交易
@@ -616,9 +702,12 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 							Duration:      action.Timestamp.Sub(openTime).String(),
 							OpenTime:      openTime,
 							CloseTime:     action.Timestamp,
+							PromptVersion: promptVersion,
 						}
+						outcome.attachEntry(entryAction)
 
 						analysis.RecentTrades = append(analysis.RecentTrades, outcome)
+						analysis.addPromptVersionTrade(outcome)
 						analysis.TotalTrades++
 
 						// 分类交易
@@ -697,13 +786,18 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 		}
 	}
 
+	for _, stats := range analysis.PromptVersionStats {
+		stats.WinRate = (float64(stats.WinningTrades) / float64(stats.TotalTrades)) * 100
+		stats.AvgPnL = stats.TotalPnL / float64(stats.TotalTrades)
+	}
+
 	// 只保留最近的交易（倒序：最新的在前）
-	if len(analysis.RecentTrades) > 10 {
+	if maxTrades > 0 && len(analysis.RecentTrades) > maxTrades {
 		// 反转数组，让最新的在前
 		for i, j := 0, len(analysis.RecentTrades)-1; i < j; i, j = i+1, j-1 {
 			analysis.RecentTrades[i], analysis.RecentTrades[j] = analysis.RecentTrades[j], analysis.RecentTrades[i]
 		}
-		analysis.RecentTrades = analysis.RecentTrades[:10]
+		analysis.RecentTrades = analysis.RecentTrades[:maxTrades]
 	} else if len(analysis.RecentTrades) > 0 {
 		// 反转数组
 		for i, j := 0, len(analysis.RecentTrades)-1; i < j; i, j = i+1, j-1 {
@@ -714,7 +808,7 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 	// 计算夏普比率（需要至少2个数据点）
 	analysis.SharpeRatio = l.calculateSharpeRatio(records)
 
-	return analysis, nil
+	return analysis
 }
 
 // calculateSharpeRatio 计算夏普比率
@@ -489,3 +489,49 @@ func TestFeeImpactOnPerformanceMetrics(t *testing.T) {
 		}
 	}
 }
+
+// TestAnalyzePerformance_PromptVersionStats 按开仓时的提示词模板版本归因交易表现
+func TestAnalyzePerformance_PromptVersionStats(t *testing.T) {
+	logger := NewDecisionLogger(t.TempDir())
+	base := time.Now().Add(-2 * time.Hour)
+
+	trade := func(cycle int, version, action string, price float64, at time.Time) *DecisionRecord {
+		return &DecisionRecord{
+			Exchange:              "binance",
+			CycleNumber:           cycle,
+			Timestamp:             at,
+			Success:               true,
+			PromptTemplate:        "default",
+			PromptTemplateVersion: version,
+			Decisions: []DecisionAction{{
+				Action: action, Symbol: "ETHUSDT", Quantity: 1, Leverage: 5, Price: price, Timestamp: at, Success: true,
+			}},
+		}
+	}
+	records := []*DecisionRecord{
+		trade(1, "aaa111", "open_long", 3000, base),
+		trade(2, "bbb222", "close_long", 3100, base.Add(10*time.Minute)), // 归因于开仓时的 aaa111
+		trade(3, "bbb222", "open_long", 3100, base.Add(20*time.Minute)),
+		trade(4, "bbb222", "close_long", 3000, base.Add(30*time.Minute)),
+	}
+	for _, r := range records {
+		if err := logger.LogDecision(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	analysis, err := logger.AnalyzePerformance(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	winner, loser := analysis.PromptVersionStats["default@aaa111"], analysis.PromptVersionStats["default@bbb222"]
+	if winner == nil || loser == nil || len(analysis.PromptVersionStats) != 2 {
+		t.Fatalf("模板版本统计错误: %+v", analysis.PromptVersionStats)
+	}
+	if winner.TotalTrades != 1 || winner.WinningTrades != 1 || winner.TotalPnL <= 0 {
+		t.Errorf("aaa111 统计错误: %+v", winner)
+	}
+	if loser.TotalTrades != 1 || loser.LosingTrades != 1 || loser.WinRate != 0 {
+		t.Errorf("bbb222 统计错误: %+v", loser)
+	}
+}
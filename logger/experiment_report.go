@@ -0,0 +1,292 @@
+package logger
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxExperimentRecords 生成实验报告时每个分组最多读取的决策记录数
+const maxExperimentRecords = 10000
+
+// minSignificantTrades 少于该交易笔数时显著性估计仅供参考
+const minSignificantTrades = 30
+
+// significanceLevel 显著性水平（双侧）
+const significanceLevel = 0.05
+
+// ExperimentArmInput 参与实验报告的分组
+type ExperimentArmInput struct {
+	Arm      string
+	TraderID string
+	Paper    bool // 是否为模拟下单
+	Logger   IDecisionLogger
+}
+
+// ExperimentArmStats 单个分组的表现
+type ExperimentArmStats struct {
+	Arm           string  `json:"arm"`
+	TraderID      string  `json:"trader_id"`
+	Paper         bool    `json:"paper"`
+	Cycles        int     `json:"cycles"`       // 实验期间的决策周期数
+	TotalTrades   int     `json:"total_trades"` // 已平仓交易数
+	WinningTrades int     `json:"winning_trades"`
+	WinRate       float64 `json:"win_rate"`      // 胜率（%）
+	ProfitFactor  float64 `json:"profit_factor"` // 盈亏比
+	SharpeRatio   float64 `json:"sharpe_ratio"`  // 夏普比率（按周期净值计算）
+	TotalPnL      float64 `json:"total_pnl"`
+	AvgTradePnL   float64 `json:"avg_trade_pnl"`
+	ReturnPct     float64 `json:"return_pct"` // 实验期间净值变化（%）
+
+	tradePnLs []float64
+	records   []*DecisionRecord
+}
+
+// ExperimentComparison 实验组与对照组的对比（差值均为 实验组 - 对照组）
+type ExperimentComparison struct {
+	Arm            string  `json:"arm"`
+	WinRateDiff    float64 `json:"win_rate_diff"`    // 胜率差（百分点）
+	WinRatePValue  float64 `json:"win_rate_p_value"` // 双比例 z 检验
+	AvgPnLDiff     float64 `json:"avg_pnl_diff"`     // 单笔平均盈亏差
+	AvgPnLPValue   float64 `json:"avg_pnl_p_value"`  // Welch t 检验（正态近似）
+	SharpeDiff     float64 `json:"sharpe_diff"`      // 夏普比率差
+	ComparedCycles int     `json:"compared_cycles"`  // 按时间配对成功的周期数
+	AgreedCycles   int     `json:"agreed_cycles"`    // 决策完全一致的周期数
+	Agreement      float64 `json:"agreement"`        // 决策一致率（%）
+	AgreementLow   float64 `json:"agreement_low"`    // 一致率 95% Wilson 置信区间下限（%）
+	AgreementHigh  float64 `json:"agreement_high"`   // 一致率 95% Wilson 置信区间上限（%）
+	Significant    bool    `json:"significant"`      // 胜率或平均盈亏的差异在 5% 水平上显著
+	Note           string  `json:"note,omitempty"`
+}
+
+// ExperimentReport A/B 实验报告
+type ExperimentReport struct {
+	ExperimentID string                  `json:"experiment_id"`
+	Control      *ExperimentArmStats     `json:"control"`
+	Variants     []*ExperimentArmStats   `json:"variants"`
+	Comparisons  []*ExperimentComparison `json:"comparisons"`
+	GeneratedAt  time.Time               `json:"generated_at"`
+}
+
+// BuildExperimentReport 生成实验报告
+// matchWindow 为对照组与实验组周期按时间配对的最大间隔（通常取扫描间隔的一半）
+func BuildExperimentReport(experimentID string, matchWindow time.Duration, control ExperimentArmInput, variants []ExperimentArmInput) (*ExperimentReport, error) {
+	controlStats, err := analyzeExperimentArm(experimentID, control)
+	if err != nil {
+		return nil, err
+	}
+	report := &ExperimentReport{
+		ExperimentID: experimentID,
+		Control:      controlStats,
+		Variants:     []*ExperimentArmStats{},
+		Comparisons:  []*ExperimentComparison{},
+		GeneratedAt:  time.Now(),
+	}
+	for _, variant := range variants {
+		stats, err := analyzeExperimentArm(experimentID, variant)
+		if err != nil {
+			return nil, err
+		}
+		report.Variants = append(report.Variants, stats)
+		report.Comparisons = append(report.Comparisons, compareExperimentArms(controlStats, stats, matchWindow))
+	}
+	return report, nil
+}
+
+// analyzeExperimentArm 读取分组在该实验中的决策记录并计算表现
+func analyzeExperimentArm(experimentID string, arm ExperimentArmInput) (*ExperimentArmStats, error) {
+	stats := &ExperimentArmStats{Arm: arm.Arm, TraderID: arm.TraderID, Paper: arm.Paper}
+	if arm.Logger == nil {
+		return stats, nil
+	}
+	all, err := arm.Logger.GetLatestRecords(maxExperimentRecords)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range all {
+		if record.ExperimentID == experimentID && record.ExperimentArm == arm.Arm {
+			stats.records = append(stats.records, record)
+		}
+	}
+	stats.Cycles = len(stats.records)
+	if len(stats.records) == 0 {
+		return stats, nil
+	}
+
+	analysis := arm.Logger.AnalyzeRecords(stats.records)
+	stats.TotalTrades = analysis.TotalTrades
+	stats.WinningTrades = analysis.WinningTrades
+	stats.WinRate = analysis.WinRate
+	stats.ProfitFactor = analysis.ProfitFactor
+	stats.SharpeRatio = analysis.SharpeRatio
+	for _, trade := range analysis.RecentTrades {
+		stats.tradePnLs = append(stats.tradePnLs, trade.PnL)
+		stats.TotalPnL += trade.PnL
+	}
+	if stats.TotalTrades > 0 {
+		stats.AvgTradePnL = stats.TotalPnL / float64(stats.TotalTrades)
+	}
+
+	first, last := stats.records[0].AccountState, stats.records[len(stats.records)-1].AccountState
+	if start := first.TotalBalance + first.TotalUnrealizedProfit; start > 0 {
+		end := last.TotalBalance + last.TotalUnrealizedProfit
+		stats.ReturnPct = (end - start) / start * 100
+	}
+	return stats, nil
+}
+
+// compareExperimentArms 对比实验组与对照组
+func compareExperimentArms(control, variant *ExperimentArmStats, matchWindow time.Duration) *ExperimentComparison {
+	cmp := &ExperimentComparison{
+		Arm:           variant.Arm,
+		WinRateDiff:   variant.WinRate - control.WinRate,
+		AvgPnLDiff:    variant.AvgTradePnL - control.AvgTradePnL,
+		SharpeDiff:    variant.SharpeRatio - control.SharpeRatio,
+		WinRatePValue: twoProportionPValue(control.WinningTrades, control.TotalTrades, variant.WinningTrades, variant.TotalTrades),
+		AvgPnLPValue:  welchPValue(control.tradePnLs, variant.tradePnLs),
+	}
+	cmp.Significant = cmp.WinRatePValue < significanceLevel || cmp.AvgPnLPValue < significanceLevel
+
+	cmp.ComparedCycles, cmp.AgreedCycles = decisionAgreement(control.records, variant.records, matchWindow)
+	if cmp.ComparedCycles > 0 {
+		cmp.Agreement = float64(cmp.AgreedCycles) / float64(cmp.ComparedCycles) * 100
+		low, high := wilsonInterval(cmp.AgreedCycles, cmp.ComparedCycles)
+		cmp.AgreementLow, cmp.AgreementHigh = low*100, high*100
+	}
+
+	if control.TotalTrades < minSignificantTrades || variant.TotalTrades < minSignificantTrades {
+		cmp.Note = "交易样本较少（少于30笔），显著性估计仅供参考"
+	}
+	return cmp
+}
+
+// cycleActions 提取一个AI决策周期的非观望决策（symbol:action 集合）
+// AI调用失败的周期返回 ok=false，不参与一致率统计
+func cycleActions(record *DecisionRecord) (actions []string, ok bool) {
+	if !record.Success || record.TriggeredBy != "" {
+		return nil, false
+	}
+	if record.DecisionJSON == "" {
+		return []string{}, true
+	}
+	var decisions []struct {
+		Symbol string `json:"symbol"`
+		Action string `json:"action"`
+	}
+	if err := json.Unmarshal([]byte(record.DecisionJSON), &decisions); err != nil {
+		return nil, false
+	}
+	seen := make(map[string]bool)
+	for _, d := range decisions {
+		if d.Action == "hold" || d.Action == "wait" || d.Action == "" {
+			continue
+		}
+		key := strings.ToUpper(d.Symbol) + ":" + d.Action
+		if !seen[key] {
+			seen[key] = true
+			actions = append(actions, key)
+		}
+	}
+	sort.Strings(actions)
+	return actions, true
+}
+
+// decisionAgreement 按时间配对两组的决策周期，统计决策完全一致（非观望决策集合相同）的周期数
+func decisionAgreement(control, variant []*DecisionRecord, matchWindow time.Duration) (compared, agreed int) {
+	type cycle struct {
+		at      time.Time
+		actions string
+	}
+	collect := func(records []*DecisionRecord) []cycle {
+		var cycles []cycle
+		for _, record := range records {
+			if actions, ok := cycleActions(record); ok {
+				cycles = append(cycles, cycle{record.Timestamp, strings.Join(actions, ",")})
+			}
+		}
+		sort.Slice(cycles, func(i, j int) bool { return cycles[i].at.Before(cycles[j].at) })
+		return cycles
+	}
+	a, b := collect(control), collect(variant)
+
+	j := 0
+	for _, c := range a {
+		for j < len(b) && b[j].at.Before(c.at.Add(-matchWindow)) {
+			j++
+		}
+		if j >= len(b) {
+			break
+		}
+		if diff := b[j].at.Sub(c.at); diff > matchWindow {
+			continue
+		}
+		compared++
+		if b[j].actions == c.actions {
+			agreed++
+		}
+		j++
+	}
+	return compared, agreed
+}
+
+// twoProportionPValue 双比例 z 检验的双侧 p 值（样本不足时返回 1）
+func twoProportionPValue(wins1, n1, wins2, n2 int) float64 {
+	if n1 == 0 || n2 == 0 {
+		return 1
+	}
+	p1, p2 := float64(wins1)/float64(n1), float64(wins2)/float64(n2)
+	pooled := float64(wins1+wins2) / float64(n1+n2)
+	se := math.Sqrt(pooled * (1 - pooled) * (1/float64(n1) + 1/float64(n2)))
+	if se == 0 {
+		return 1
+	}
+	return normalTwoSidedPValue((p2 - p1) / se)
+}
+
+// welchPValue Welch t 检验的双侧 p 值（以正态分布近似 t 分布，每组至少2个样本）
+func welchPValue(a, b []float64) float64 {
+	if len(a) < 2 || len(b) < 2 {
+		return 1
+	}
+	meanA, varA := meanVariance(a)
+	meanB, varB := meanVariance(b)
+	se := math.Sqrt(varA/float64(len(a)) + varB/float64(len(b)))
+	if se == 0 {
+		return 1
+	}
+	return normalTwoSidedPValue((meanB - meanA) / se)
+}
+
+// meanVariance 均值与样本方差
+func meanVariance(values []float64) (float64, float64) {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	sq := 0.0
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	return mean, sq / float64(len(values)-1)
+}
+
+func normalTwoSidedPValue(z float64) float64 {
+	return math.Erfc(math.Abs(z) / math.Sqrt2)
+}
+
+// wilsonInterval 比例的 95% Wilson 置信区间
+func wilsonInterval(successes, n int) (float64, float64) {
+	if n == 0 {
+		return 0, 0
+	}
+	const z = 1.96
+	p := float64(successes) / float64(n)
+	nf := float64(n)
+	denom := 1 + z*z/nf
+	center := (p + z*z/(2*nf)) / denom
+	margin := z * math.Sqrt(p*(1-p)/nf+z*z/(4*nf*nf)) / denom
+	return math.Max(0, center-margin), math.Min(1, center+margin)
+}
@@ -0,0 +1,125 @@
+package logger
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// logExperimentTrade 记录一个开仓周期和一个平仓周期
+func logExperimentTrade(t *testing.T, l IDecisionLogger, arm string, decisionJSON string, openPrice, closePrice, equity float64) {
+	t.Helper()
+	now := time.Now()
+	records := []*DecisionRecord{
+		{
+			Success:       true,
+			Exchange:      "binance",
+			DecisionJSON:  decisionJSON,
+			ExperimentID:  "exp1",
+			ExperimentArm: arm,
+			AccountState:  AccountSnapshot{TotalBalance: equity},
+			Decisions: []DecisionAction{
+				{Action: "open_long", Symbol: "BTCUSDT", Quantity: 1, Leverage: 5, Price: openPrice, Timestamp: now, Success: true},
+			},
+		},
+		{
+			Success:       true,
+			Exchange:      "binance",
+			DecisionJSON:  `[{"symbol":"BTCUSDT","action":"close_long"}]`,
+			ExperimentID:  "exp1",
+			ExperimentArm: arm,
+			AccountState:  AccountSnapshot{TotalBalance: equity + closePrice - openPrice},
+			Decisions: []DecisionAction{
+				{Action: "close_long", Symbol: "BTCUSDT", Price: closePrice, Timestamp: now.Add(time.Minute), Success: true},
+			},
+		},
+	}
+	for _, record := range records {
+		if err := l.LogDecision(record); err != nil {
+			t.Fatalf("记录决策失败: %v", err)
+		}
+	}
+}
+
+// TestBuildExperimentReport 测试实验报告只统计本实验的记录，并计算决策一致率
+func TestBuildExperimentReport(t *testing.T) {
+	control := NewDecisionLogger(t.TempDir())
+	variant := NewDecisionLogger(t.TempDir())
+
+	// 实验开始前的记录不计入报告
+	if err := control.LogDecision(&DecisionRecord{Success: true, AccountState: AccountSnapshot{TotalBalance: 1000}}); err != nil {
+		t.Fatal(err)
+	}
+	logExperimentTrade(t, control, "control", `[{"symbol":"BTCUSDT","action":"open_long"},{"symbol":"ETHUSDT","action":"hold"}]`, 100, 110, 1000)
+	logExperimentTrade(t, variant, "aggressive", `[{"symbol":"BTCUSDT","action":"open_long"}]`, 100, 90, 1000)
+
+	report, err := BuildExperimentReport("exp1", time.Minute,
+		ExperimentArmInput{Arm: "control", TraderID: "t1", Logger: control},
+		[]ExperimentArmInput{{Arm: "aggressive", TraderID: "t1_exp_exp1_aggressive", Paper: true, Logger: variant}})
+	if err != nil {
+		t.Fatalf("生成报告失败: %v", err)
+	}
+
+	if report.Control.Cycles != 2 || report.Control.TotalTrades != 1 || report.Control.WinRate != 100 {
+		t.Errorf("对照组统计不正确: %+v", report.Control)
+	}
+	if v := report.Variants[0]; v.TotalTrades != 1 || v.WinRate != 0 || !v.Paper || v.AvgTradePnL >= 0 {
+		t.Errorf("实验组统计不正确: %+v", v)
+	}
+	cmp := report.Comparisons[0]
+	if cmp.ComparedCycles != 2 || cmp.AgreedCycles != 2 || cmp.Agreement != 100 {
+		t.Errorf("观望决策不影响一致率，两个周期应一致: %+v", cmp)
+	}
+	if cmp.WinRateDiff != -100 || cmp.Note == "" || cmp.AgreementLow <= 0 || cmp.AgreementHigh != 100 {
+		t.Errorf("对比结果不正确: %+v", cmp)
+	}
+}
+
+// TestDecisionAgreement 测试按时间配对周期与一致判断
+func TestDecisionAgreement(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	rec := func(offset time.Duration, decisions string) *DecisionRecord {
+		return &DecisionRecord{Timestamp: base.Add(offset), Success: true, DecisionJSON: decisions}
+	}
+	control := []*DecisionRecord{
+		rec(0, `[{"symbol":"BTCUSDT","action":"open_long"}]`),
+		rec(3*time.Minute, `[{"symbol":"BTCUSDT","action":"hold"}]`),
+		rec(6*time.Minute, `[{"symbol":"ETHUSDT","action":"open_short"}]`),
+		{Timestamp: base.Add(9 * time.Minute), Success: false}, // AI调用失败，不参与统计
+	}
+	variant := []*DecisionRecord{
+		rec(20*time.Second, `[{"symbol":"btcusdt","action":"open_long"}]`),
+		rec(3*time.Minute+10*time.Second, ``),
+		rec(6*time.Minute+5*time.Second, `[{"symbol":"ETHUSDT","action":"wait"}]`),
+		rec(9*time.Minute, `[]`),
+	}
+
+	compared, agreed := decisionAgreement(control, variant, 90*time.Second)
+	if compared != 3 || agreed != 2 {
+		t.Errorf("compared=%d agreed=%d, 期望 3/2", compared, agreed)
+	}
+}
+
+// TestSignificance 测试显著性估计
+func TestSignificance(t *testing.T) {
+	if p := twoProportionPValue(50, 100, 50, 100); p != 1 {
+		t.Errorf("胜率相同时 p 值应为1: %v", p)
+	}
+	if p := twoProportionPValue(30, 100, 60, 100); p >= 0.01 {
+		t.Errorf("30%% vs 60%% (n=100) 应显著: %v", p)
+	}
+	if p := twoProportionPValue(1, 2, 2, 2); p < significanceLevel {
+		t.Errorf("小样本不应显著: %v", p)
+	}
+	if p := welchPValue([]float64{1}, []float64{2, 3}); p != 1 {
+		t.Errorf("样本不足时 p 值应为1: %v", p)
+	}
+	if p := welchPValue([]float64{-1, -2, -1.5, -0.5}, []float64{5, 6, 5.5, 6.5}); p >= 0.001 {
+		t.Errorf("均值差异明显时应显著: %v", p)
+	}
+
+	low, high := wilsonInterval(8, 10)
+	if math.Abs(low-0.4902) > 0.001 || math.Abs(high-0.9433) > 0.001 {
+		t.Errorf("Wilson 区间不正确: [%v, %v]", low, high)
+	}
+}
@@ -10,11 +10,17 @@ import (
 	"nofx/auth"
 	"nofx/config"
 	"nofx/crypto"
+	"nofx/decision"
+	"nofx/events"
 	"nofx/manager"
 	"nofx/market"
 	"nofx/pool"
+	"nofx/telegram"
+	"nofx/trader"
+	"nofx/webhook"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"syscall"
@@ -38,6 +44,11 @@ type ConfigFile struct {
 	JWTSecret          string                `json:"jwt_secret"`
 	DataKLineTime      string                `json:"data_k_line_time"`
 	Log                *config.LogConfig     `json:"log"` // 日志配置
+
+	KlineHistoryDepth    int `json:"kline_history_depth"`     // 每个币种/周期缓存的K线数量
+	KlineCacheMaxSymbols int `json:"kline_cache_max_symbols"` // K线缓存最多保留的币种数
+
+	MarketHistoryDB string `json:"market_history_db"` // 本地历史行情数据库路径（默认与配置数据库同目录的 market_history.db）
 }
 
 // loadConfigFile 读取并解析config.json文件
@@ -99,6 +110,17 @@ func syncConfigToDatabase(database *config.Database, configFile *ConfigFile) err
 		configs["altcoin_leverage"] = strconv.Itoa(configFile.Leverage.AltcoinLeverage)
 	}
 
+	// 同步K线缓存配置
+	if configFile.KlineHistoryDepth > 0 {
+		configs["kline_history_depth"] = strconv.Itoa(configFile.KlineHistoryDepth)
+	}
+	if configFile.KlineCacheMaxSymbols > 0 {
+		configs["kline_cache_max_symbols"] = strconv.Itoa(configFile.KlineCacheMaxSymbols)
+	}
+	if configFile.MarketHistoryDB != "" {
+		configs["market_history_db"] = configFile.MarketHistoryDB
+	}
+
 	// 如果JWT密钥不为空，也同步
 	if configFile.JWTSecret != "" {
 		configs["jwt_secret"] = configFile.JWTSecret
@@ -324,6 +346,9 @@ func main() {
 		log.Printf("✓ 已配置OI Top API")
 	}
 
+	// 用户自建的系统提示词模板（按交易员所属用户解析）
+	decision.SetPromptTemplateStore(database)
+
 	// 创建TraderManager
 	traderManager := manager.NewTraderManager()
 
@@ -401,16 +426,76 @@ func main() {
 
 	// 创建并启动API服务器
 	apiServer := api.NewServer(traderManager, database, cryptoService, apiPort)
+
+	// 实时事件总线：交易员发布事件，API 通过 SSE/WebSocket 按用户推送
+	eventBus := events.NewBus(1000)
+	trader.SetEventPublisher(eventBus)
+	apiServer.SetEventBus(eventBus)
+
+	// Webhook 投递：将交易事件签名推送到用户配置的外部地址（失败重试，耗尽后进入死信）
+	webhookDispatcher := webhook.NewDispatcher(database, webhook.Options{})
+	webhookDispatcher.Start(eventBus)
+	apiServer.SetWebhookDispatcher(webhookDispatcher)
 	go func() {
 		if err := apiServer.Start(); err != nil {
 			log.Printf("❌ API服务器错误: %v", err)
 		}
 	}()
 
+	// 启动Telegram机器人（复用日志推送的 Telegram 配置，审批提案推送到交易员所属用户绑定的聊天）
+	var telegramBot *telegram.Bot
+	if configFile.Log != nil && configFile.Log.Telegram != nil && configFile.Log.Telegram.Enabled {
+		tgCfg := configFile.Log.Telegram
+		telegramBot, err = telegram.NewBot(telegram.Options{
+			BotToken: tgCfg.BotToken,
+			Resolver: traderManager,
+			Traders:  &telegram.ManagerProvider{Manager: traderManager, Database: database},
+			Links:    database,
+		})
+		if err != nil {
+			log.Printf("⚠️  创建Telegram机器人失败: %v", err)
+		} else {
+			trader.SetProposalNotifier(telegramBot)
+			telegramBot.Start()
+		}
+	}
+
+	// 打开本地历史行情存储（K线/OI/资金费率持久化，重启后只回填缺口）
+	historyDBPath := filepath.Join(filepath.Dir(dbPath), "market_history.db")
+	if v, _ := database.GetSystemConfig("market_history_db"); v != "" {
+		historyDBPath = v
+	}
+	historyStore, err := market.OpenHistoryStore(historyDBPath)
+	if err != nil {
+		log.Printf("⚠️  打开历史行情数据库失败: %v，将直接从交易所获取历史数据", err)
+	} else {
+		market.SetHistoryStore(historyStore)
+		log.Printf("📦 历史行情数据库: %s", historyDBPath)
+	}
+
 	// 启动流行情数据 - 默认使用所有交易员设置的币种 如果没有设置币种 则优先使用系统默认
 	// 获取所有活跃 trader 的时间线配置（合并后的并集）
 	timeframes := database.GetAllTimeframes()
-	go market.NewWSMonitor(150, timeframes).Start(database.GetCustomCoins())
+	klineCacheConfig := market.DefaultKlineCacheConfig()
+	if v, _ := database.GetSystemConfig("kline_history_depth"); v != "" {
+		if depth, err := strconv.Atoi(v); err == nil && depth > 0 {
+			klineCacheConfig.HistoryDepth = depth
+		}
+	}
+	if v, _ := database.GetSystemConfig("kline_cache_max_symbols"); v != "" {
+		if maxSymbols, err := strconv.Atoi(v); err == nil && maxSymbols > 0 {
+			klineCacheConfig.MaxSymbols = maxSymbols
+		}
+	}
+	go market.NewWSMonitorWithConfig(150, timeframes, klineCacheConfig).Start(database.GetCustomCoins())
+	// 启动行情数据源管理器（WebSocket过期/异常时自动故障转移到REST等备用数据源）
+	market.DefaultSourceManager().Start()
+	// 清算瀑布与巨鲸成交警报
+	go func() {
+		for alert := range market.DefaultFlowMonitor().Alerts() {
+			log.Printf("🚨 [%s] %s", alert.Type, alert.Message)
+		}
+	}()
 	//go market.NewWSMonitor(150, timeframes).Start([]string{}) //这里是一个使用方式 传入空的话 则使用market市场的所有币种
 	// 设置优雅退出
 	sigChan := make(chan os.Signal, 1)
@@ -434,6 +519,14 @@ func main() {
 	traderManager.StopAll()
 	log.Println("✅ 所有交易员已停止")
 
+	if telegramBot != nil {
+		telegramBot.Stop()
+	}
+	webhookDispatcher.Stop()
+	market.DefaultSourceManager().Stop()
+	market.DefaultDepthMonitor().Stop()
+	market.DefaultFlowMonitor().Stop()
+
 	// 步骤 2: 关闭 API 服务器
 	log.Println("🛑 停止 API 服务器...")
 	if err := apiServer.Shutdown(); err != nil {
@@ -444,6 +537,12 @@ func main() {
 
 	// 步骤 3: 关闭数据库连接 (确保所有写入完成)
 	log.Println("💾 关闭数据库连接...")
+	if historyStore != nil {
+		market.SetHistoryStore(nil)
+		if err := historyStore.Close(); err != nil {
+			log.Printf("⚠️  关闭历史行情数据库失败: %v", err)
+		}
+	}
 	if err := database.Close(); err != nil {
 		log.Printf("❌ 关闭数据库失败: %v", err)
 	} else {
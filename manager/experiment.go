@@ -0,0 +1,132 @@
+package manager
+
+import (
+	"fmt"
+	"log"
+	"nofx/config"
+	"nofx/logger"
+	"nofx/trader"
+	"time"
+)
+
+// buildShadowTraders 按实验配置为对照组创建影子交易员
+func buildShadowTraders(control *trader.AutoTrader, exp *config.Experiment, database *config.Database) ([]*trader.AutoTrader, error) {
+	var models []*config.AIModelConfig
+	shadows := make([]*trader.AutoTrader, 0, len(exp.Variants))
+	for _, arm := range exp.Variants {
+		overrides := trader.ShadowOverrides{
+			SystemPromptTemplate: arm.SystemPromptTemplate,
+			UserPromptTemplate:   arm.UserPromptTemplate,
+		}
+		if arm.AIModelID != "" {
+			if models == nil {
+				var err error
+				if models, err = database.GetAIModels(exp.UserID); err != nil {
+					return nil, fmt.Errorf("获取AI模型配置失败: %w", err)
+				}
+			}
+			for _, model := range models {
+				if model.ModelID == arm.AIModelID {
+					overrides.AIModel = model
+					break
+				}
+			}
+			if overrides.AIModel == nil {
+				return nil, fmt.Errorf("实验组 %s 的AI模型 %s 不存在", arm.Name, arm.AIModelID)
+			}
+		}
+
+		shadow, err := control.NewShadowTrader(exp.ID, arm.Name, overrides)
+		if err != nil {
+			return nil, err
+		}
+		shadows = append(shadows, shadow)
+	}
+	return shadows, nil
+}
+
+// StartExperiment 为对照组交易员创建影子交易员并开始实验（对照组需已加载到内存）
+func (tm *TraderManager) StartExperiment(exp *config.Experiment, database *config.Database) error {
+	control, err := tm.GetTrader(exp.ControlTraderID)
+	if err != nil {
+		return err
+	}
+	if id, _ := control.GetExperiment(); id != "" && id != exp.ID {
+		return fmt.Errorf("交易员已在实验 %s 中", id)
+	}
+
+	shadows, err := buildShadowTraders(control, exp, database)
+	if err != nil {
+		return err
+	}
+	control.AttachExperiment(exp.ID, shadows)
+	return nil
+}
+
+// StopExperiment 结束交易员正在进行的实验（交易员未加载时忽略）
+func (tm *TraderManager) StopExperiment(traderID string) {
+	tm.mu.RLock()
+	control, exists := tm.traders[traderID]
+	tm.mu.RUnlock()
+	if exists && control != nil {
+		control.StopExperiment()
+	}
+}
+
+// restoreExperiment 交易员加载后恢复其作为对照组的进行中实验
+func restoreExperiment(at *trader.AutoTrader, database *config.Database, userID, traderID string) {
+	if database == nil {
+		return
+	}
+	exp, err := database.GetRunningExperimentForTrader(userID, traderID)
+	if err != nil || exp == nil {
+		return
+	}
+	shadows, err := buildShadowTraders(at, exp, database)
+	if err != nil {
+		log.Printf("⚠️  恢复实验 %s 失败: %v", exp.ID, err)
+		return
+	}
+	at.AttachExperiment(exp.ID, shadows)
+}
+
+// GetExperimentReport 生成实验报告（实验结束后仍可从决策日志生成）
+func (tm *TraderManager) GetExperimentReport(exp *config.Experiment) (*logger.ExperimentReport, error) {
+	control, err := tm.GetTrader(exp.ControlTraderID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 运行中的影子交易员直接使用其日志记录器，已结束的实验按目录读取
+	active := make(map[string]*trader.AutoTrader)
+	if id, _ := control.GetExperiment(); id == exp.ID {
+		for _, shadow := range control.GetShadowTraders() {
+			_, arm := shadow.GetExperiment()
+			active[arm] = shadow
+		}
+	}
+	variants := make([]logger.ExperimentArmInput, 0, len(exp.Variants))
+	for _, arm := range exp.Variants {
+		input := logger.ExperimentArmInput{
+			Arm:      arm.Name,
+			TraderID: trader.ShadowTraderID(exp.ControlTraderID, exp.ID, arm.Name),
+			Paper:    true,
+		}
+		if shadow, ok := active[arm.Name]; ok {
+			input.Logger = shadow.GetDecisionLogger()
+		} else {
+			input.Logger = logger.NewDecisionLogger(fmt.Sprintf("decision_logs/%s", input.TraderID))
+		}
+		variants = append(variants, input)
+	}
+
+	matchWindow := control.GetScanInterval() / 2
+	if matchWindow <= 0 {
+		matchWindow = time.Minute
+	}
+	return logger.BuildExperimentReport(exp.ID, matchWindow, logger.ExperimentArmInput{
+		Arm:      config.ExperimentControlArm,
+		TraderID: exp.ControlTraderID,
+		Logger:   control.GetDecisionLogger(),
+	}, variants)
+}
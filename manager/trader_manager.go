@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log"
 	"nofx/config"
+	"nofx/decision"
 	"nofx/market"
 	"nofx/trader"
 	"sort"
@@ -234,6 +235,14 @@ func (tm *TraderManager) addTraderFromDB(traderCfg *config.TraderRecord, aiModel
 		OrderStrategy:         traderCfg.OrderStrategy,        // 订单策略
 		LimitPriceOffset:      traderCfg.LimitPriceOffset,     // 限价偏移
 		LimitTimeoutSeconds:   traderCfg.LimitTimeoutSeconds,  // 限价超时
+		Indicators:            indicatorsFromRecord(traderCfg), // 指标配置
+		UserPromptTemplate:    traderCfg.UserPromptTemplate,    // 用户提示词模板
+		PromptTokenBudget:     traderCfg.PromptTokenBudget,     // 提示词 token 预算
+		AgentMode:             traderCfg.AgentMode,
+		AgentMaxSteps:         traderCfg.AgentMaxSteps,
+		PositionSizingMode:    traderCfg.PositionSizingMode,
+		KellyFraction:         traderCfg.KellyFraction,
+		RegimePolicies:        regimePoliciesFromRecord(traderCfg), // 市场状态策略
 	}
 
 	// 根据交易所类型设置API密钥
@@ -273,6 +282,19 @@ func (tm *TraderManager) addTraderFromDB(traderCfg *config.TraderRecord, aiModel
 		}
 	}
 
+	// 恢复持久化的运行模式（暂停/只减仓/清仓退出）
+	if traderCfg.TradingMode != "" && traderCfg.TradingMode != trader.TradingModeNormal {
+		if err := at.SetTradingMode(traderCfg.TradingMode); err != nil {
+			log.Printf("⚠️  恢复交易员 %s 的运行模式失败: %v", traderCfg.Name, err)
+		}
+	}
+
+	// 人工审批设置
+	at.SetApprovalSettings(approvalSettingsFromRecord(traderCfg))
+
+	// 恢复进行中的A/B实验（影子交易员）
+	restoreExperiment(at, database, userID, traderCfg.ID)
+
 	tm.traders[traderCfg.ID] = at
 	log.Printf("✓ Trader '%s' (%s + %s) 已加载到内存", traderCfg.Name, aiModelCfg.Provider, exchangeCfg.ExchangeID)
 	return nil
@@ -346,6 +368,14 @@ func (tm *TraderManager) AddTraderFromDB(traderCfg *config.TraderRecord, aiModel
 		OrderStrategy:         traderCfg.OrderStrategy,        // 订单策略
 		LimitPriceOffset:      traderCfg.LimitPriceOffset,     // 限价偏移
 		LimitTimeoutSeconds:   traderCfg.LimitTimeoutSeconds,  // 限价超时
+		Indicators:            indicatorsFromRecord(traderCfg), // 指标配置
+		UserPromptTemplate:    traderCfg.UserPromptTemplate,    // 用户提示词模板
+		PromptTokenBudget:     traderCfg.PromptTokenBudget,     // 提示词 token 预算
+		AgentMode:             traderCfg.AgentMode,
+		AgentMaxSteps:         traderCfg.AgentMaxSteps,
+		PositionSizingMode:    traderCfg.PositionSizingMode,
+		KellyFraction:         traderCfg.KellyFraction,
+		RegimePolicies:        regimePoliciesFromRecord(traderCfg), // 市场状态策略
 	}
 
 	// 根据交易所类型设置API密钥
@@ -385,11 +415,74 @@ func (tm *TraderManager) AddTraderFromDB(traderCfg *config.TraderRecord, aiModel
 		}
 	}
 
+	// 恢复持久化的运行模式（暂停/只减仓/清仓退出）
+	if traderCfg.TradingMode != "" && traderCfg.TradingMode != trader.TradingModeNormal {
+		if err := at.SetTradingMode(traderCfg.TradingMode); err != nil {
+			log.Printf("⚠️  恢复交易员 %s 的运行模式失败: %v", traderCfg.Name, err)
+		}
+	}
+
+	// 人工审批设置
+	at.SetApprovalSettings(approvalSettingsFromRecord(traderCfg))
+
+	// 恢复进行中的A/B实验（影子交易员）
+	restoreExperiment(at, database, userID, traderCfg.ID)
+
 	tm.traders[traderCfg.ID] = at
 	log.Printf("✓ Trader '%s' (%s + %s) 已添加", traderCfg.Name, aiModelCfg.Provider, exchangeCfg.ExchangeID)
 	return nil
 }
 
+// approvalSettingsFromRecord 从数据库记录构建人工审批设置
+func approvalSettingsFromRecord(traderCfg *config.TraderRecord) trader.ApprovalSettings {
+	return trader.ApprovalSettings{
+		Required:          traderCfg.ApprovalRequired,
+		MinNotional:       traderCfg.ApprovalMinNotional,
+		MinLeverage:       traderCfg.ApprovalMinLeverage,
+		ExpiryMinutes:     traderCfg.ApprovalExpiryMinutes,
+		PriceTolerancePct: traderCfg.ApprovalPriceTolerancePct,
+	}
+}
+
+// indicatorsFromRecord 解析交易员的指标配置（无效配置不影响交易员启动，只使用内置指标）
+func indicatorsFromRecord(traderCfg *config.TraderRecord) []market.IndicatorSpec {
+	indicators, err := market.ParseIndicatorSet(traderCfg.Indicators)
+	if err != nil {
+		log.Printf("⚠️  交易员 %s 的指标配置无效，已忽略: %v", traderCfg.Name, err)
+		return nil
+	}
+	return indicators
+}
+
+// regimePoliciesFromRecord 解析交易员的市场状态策略（无效配置记录日志后忽略）
+func regimePoliciesFromRecord(traderCfg *config.TraderRecord) map[string]decision.RegimePolicy {
+	policies, err := decision.ParseRegimePolicies(traderCfg.RegimePolicies)
+	if err != nil {
+		log.Printf("⚠️  交易员 %s 的市场状态策略无效，已忽略: %v", traderCfg.Name, err)
+		return nil
+	}
+	return policies
+}
+
+// ApproveProposal 批准指定trader的开仓提案（供 Telegram 等外部渠道调用）
+func (tm *TraderManager) ApproveProposal(traderID, proposalID, approvedBy string) error {
+	at, err := tm.GetTrader(traderID)
+	if err != nil {
+		return err
+	}
+	_, err = at.ApproveProposal(proposalID, approvedBy)
+	return err
+}
+
+// RejectProposal 拒绝指定trader的开仓提案
+func (tm *TraderManager) RejectProposal(traderID, proposalID, rejectedBy, note string) error {
+	at, err := tm.GetTrader(traderID)
+	if err != nil {
+		return err
+	}
+	return at.RejectProposal(proposalID, rejectedBy, note)
+}
+
 // GetTrader 获取指定ID的trader
 func (tm *TraderManager) GetTrader(id string) (*trader.AutoTrader, error) {
 	tm.mu.RLock()
@@ -463,6 +556,11 @@ func (tm *TraderManager) RemoveTrader(traderID string) error {
 		}
 	}
 
+	// 释放影子交易员（重新加载时会从数据库恢复进行中的实验）
+	if trader != nil {
+		trader.StopExperiment()
+	}
+
 	// 从map中删除
 	delete(tm.traders, traderID)
 	log.Printf("✅ 已从内存中移除交易员: %s", traderID)
@@ -1129,6 +1227,14 @@ func (tm *TraderManager) loadSingleTrader(traderCfg *config.TraderRecord, aiMode
 		LimitPriceOffset:     traderCfg.LimitPriceOffset,     // 限价偏移
 		LimitTimeoutSeconds:  traderCfg.LimitTimeoutSeconds,  // 限价超时
 		Timeframes:           traderCfg.Timeframes,            // K线时间周期配置
+		Indicators:           indicatorsFromRecord(traderCfg), // 指标配置
+		UserPromptTemplate:   traderCfg.UserPromptTemplate,    // 用户提示词模板
+		PromptTokenBudget:    traderCfg.PromptTokenBudget,     // 提示词 token 预算
+		AgentMode:            traderCfg.AgentMode,
+		AgentMaxSteps:        traderCfg.AgentMaxSteps,
+		PositionSizingMode:   traderCfg.PositionSizingMode,
+		KellyFraction:        traderCfg.KellyFraction,
+		RegimePolicies:       regimePoliciesFromRecord(traderCfg), // 市场状态策略
 		HyperliquidTestnet:   exchangeCfg.Testnet,            // Hyperliquid测试网
 	}
 
@@ -1169,6 +1275,19 @@ func (tm *TraderManager) loadSingleTrader(traderCfg *config.TraderRecord, aiMode
 		}
 	}
 
+	// 恢复持久化的运行模式（暂停/只减仓/清仓退出）
+	if traderCfg.TradingMode != "" && traderCfg.TradingMode != trader.TradingModeNormal {
+		if err := at.SetTradingMode(traderCfg.TradingMode); err != nil {
+			log.Printf("⚠️  恢复交易员 %s 的运行模式失败: %v", traderCfg.Name, err)
+		}
+	}
+
+	// 人工审批设置
+	at.SetApprovalSettings(approvalSettingsFromRecord(traderCfg))
+
+	// 恢复进行中的A/B实验（影子交易员）
+	restoreExperiment(at, database, userID, traderCfg.ID)
+
 	tm.traders[traderCfg.ID] = at
 	log.Printf("✓ Trader '%s' (%s + %s) 已为用户加载到内存", traderCfg.Name, aiModelCfg.Provider, exchangeCfg.ExchangeID)
 	return nil
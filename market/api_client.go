@@ -12,7 +12,8 @@ import (
 )
 
 const (
-	baseURL = "https://fapi.binance.com"
+	baseURL     = "https://fapi.binance.com"
+	spotBaseURL = "https://api.binance.com"
 )
 
 type APIClient struct {
@@ -57,11 +58,17 @@ func (c *APIClient) GetExchangeInfo() (*ExchangeInfo, error) {
 }
 
 func (c *APIClient) GetKlines(symbol, interval string, limit int) ([]Kline, error) {
+	return c.GetKlinesRange(symbol, interval, 0, 0, limit)
+}
+
+// GetKlinesRange retrieves klines within [startTime, endTime] (milliseconds, 0 = unbounded)
+// Binance returns at most limit (max 1500) klines starting from startTime
+func (c *APIClient) GetKlinesRange(symbol, interval string, startTime, endTime int64, limit int) ([]Kline, error) {
 	const maxRetries = 3
 	var lastErr error
 
 	for attempt := 1; attempt <= maxRetries; attempt++ {
-		klines, err := c.getKlinesAttempt(symbol, interval, limit, attempt)
+		klines, err := c.getKlinesAttempt(symbol, interval, startTime, endTime, limit, attempt)
 		if err == nil {
 			return klines, nil
 		}
@@ -78,7 +85,7 @@ func (c *APIClient) GetKlines(symbol, interval string, limit int) ([]Kline, erro
 	return nil, fmt.Errorf("failed after %d attempts: %w", maxRetries, lastErr)
 }
 
-func (c *APIClient) getKlinesAttempt(symbol, interval string, limit int, attempt int) ([]Kline, error) {
+func (c *APIClient) getKlinesAttempt(symbol, interval string, startTime, endTime int64, limit int, attempt int) ([]Kline, error) {
 	url := fmt.Sprintf("%s/fapi/v1/klines", baseURL)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
@@ -89,6 +96,12 @@ func (c *APIClient) getKlinesAttempt(symbol, interval string, limit int, attempt
 	q.Add("symbol", symbol)
 	q.Add("interval", interval)
 	q.Add("limit", strconv.Itoa(limit))
+	if startTime > 0 {
+		q.Add("startTime", strconv.FormatInt(startTime, 10))
+	}
+	if endTime > 0 {
+		q.Add("endTime", strconv.FormatInt(endTime, 10))
+	}
 	req.URL.RawQuery = q.Encode()
 
 	resp, err := c.client.Do(req)
@@ -278,15 +291,95 @@ func (c *APIClient) GetOpenInterest(symbol string) (*OIData, error) {
 	}, nil
 }
 
+// GetFundingRate 获取最新资金费率
+func (c *APIClient) GetFundingRate(symbol string) (float64, error) {
+	premium, err := c.GetPremiumIndex(symbol)
+	if err != nil {
+		return 0, err
+	}
+	return premium.PredictedRate, nil
+}
+
+// GetPremiumIndex 获取标记价格、指数价格与下次结算的预测资金费率
+func (c *APIClient) GetPremiumIndex(symbol string) (*PremiumIndex, error) {
+	url := fmt.Sprintf("%s/fapi/v1/premiumIndex?symbol=%s", baseURL, symbol)
+
+	resp, err := c.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Symbol          string `json:"symbol"`
+		MarkPrice       string `json:"markPrice"`
+		IndexPrice      string `json:"indexPrice"`
+		LastFundingRate string `json:"lastFundingRate"`
+		NextFundingTime int64  `json:"nextFundingTime"`
+		InterestRate    string `json:"interestRate"`
+		Time            int64  `json:"time"`
+	}
+
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+
+	premium := &PremiumIndex{NextFundingTime: time.UnixMilli(result.NextFundingTime)}
+	premium.MarkPrice, _ = strconv.ParseFloat(result.MarkPrice, 64)
+	premium.IndexPrice, _ = strconv.ParseFloat(result.IndexPrice, 64)
+	premium.PredictedRate, _ = strconv.ParseFloat(result.LastFundingRate, 64)
+	premium.InterestRate, _ = strconv.ParseFloat(result.InterestRate, 64)
+	return premium, nil
+}
+
+// GetSpotPrice 获取现货最新成交价（用于计算期现基差）
+func (c *APIClient) GetSpotPrice(symbol string) (float64, error) {
+	url := fmt.Sprintf("%s/api/v3/ticker/price?symbol=%s", spotBaseURL, symbol)
+
+	resp, err := c.client.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result struct {
+		Symbol string `json:"symbol"`
+		Price  string `json:"price"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(result.Price, 64)
+}
+
 // GetOpenInterestHistory retrieves historical OI data (for backfilling on startup)
 // period: "5m", "15m", "30m", "1h", "2h", "4h", "6h", "12h", "1d"
 // limit: default 30, max 500 (we need 20 15-minute data points = 5 hours)
 func (c *APIClient) GetOpenInterestHistory(symbol string, period string, limit int) ([]OISnapshot, error) {
+	return c.GetOpenInterestHistoryRange(symbol, period, 0, 0, limit)
+}
+
+// GetOpenInterestHistoryRange retrieves historical OI data within [startTime, endTime] (milliseconds, 0 = unbounded)
+// Binance only keeps the latest 30 days of OI history
+func (c *APIClient) GetOpenInterestHistoryRange(symbol string, period string, startTime, endTime int64, limit int) ([]OISnapshot, error) {
 	const maxRetries = 3
 	var lastErr error
 
 	for attempt := 1; attempt <= maxRetries; attempt++ {
-		snapshots, err := c.getOpenInterestHistoryAttempt(symbol, period, limit, attempt)
+		snapshots, err := c.getOpenInterestHistoryAttempt(symbol, period, startTime, endTime, limit, attempt)
 		if err == nil {
 			return snapshots, nil
 		}
@@ -303,7 +396,7 @@ func (c *APIClient) GetOpenInterestHistory(symbol string, period string, limit i
 	return nil, fmt.Errorf("failed after %d attempts: %w", maxRetries, lastErr)
 }
 
-func (c *APIClient) getOpenInterestHistoryAttempt(symbol string, period string, limit int, attempt int) ([]OISnapshot, error) {
+func (c *APIClient) getOpenInterestHistoryAttempt(symbol string, period string, startTime, endTime int64, limit int, attempt int) ([]OISnapshot, error) {
 	url := fmt.Sprintf("%s/futures/data/openInterestHist", baseURL)
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
@@ -314,6 +407,12 @@ func (c *APIClient) getOpenInterestHistoryAttempt(symbol string, period string,
 	q.Add("symbol", symbol)
 	q.Add("period", period)
 	q.Add("limit", strconv.Itoa(limit))
+	if startTime > 0 {
+		q.Add("startTime", strconv.FormatInt(startTime, 10))
+	}
+	if endTime > 0 {
+		q.Add("endTime", strconv.FormatInt(endTime, 10))
+	}
 	req.URL.RawQuery = q.Encode()
 
 	resp, err := c.client.Do(req)
@@ -369,3 +468,93 @@ func (c *APIClient) getOpenInterestHistoryAttempt(symbol string, period string,
 
 	return snapshots, nil
 }
+
+// GetFundingRateHistory retrieves settled funding rates within [startTime, endTime] (milliseconds, 0 = unbounded)
+func (c *APIClient) GetFundingRateHistory(symbol string, startTime, endTime int64, limit int) ([]FundingRatePoint, error) {
+	url := fmt.Sprintf("%s/fapi/v1/fundingRate", baseURL)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create request failed: %w", err)
+	}
+
+	q := req.URL.Query()
+	q.Add("symbol", symbol)
+	q.Add("limit", strconv.Itoa(limit))
+	if startTime > 0 {
+		q.Add("startTime", strconv.FormatInt(startTime, 10))
+	}
+	if endTime > 0 {
+		q.Add("endTime", strconv.FormatInt(endTime, 10))
+	}
+	req.URL.RawQuery = q.Encode()
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response body failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var binanceErr BinanceErrorResponse
+		if json.Unmarshal(body, &binanceErr) == nil && binanceErr.Code != 0 {
+			return nil, &binanceErr
+		}
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
+	}
+
+	var histData []struct {
+		Symbol      string `json:"symbol"`
+		FundingRate string `json:"fundingRate"`
+		FundingTime int64  `json:"fundingTime"`
+	}
+	if err := json.Unmarshal(body, &histData); err != nil {
+		return nil, fmt.Errorf("parse funding rate history JSON failed: %w", err)
+	}
+
+	points := make([]FundingRatePoint, 0, len(histData))
+	for _, item := range histData {
+		rate, _ := strconv.ParseFloat(item.FundingRate, 64)
+		points = append(points, FundingRatePoint{
+			Rate:      rate,
+			Timestamp: time.UnixMilli(item.FundingTime),
+			Settled:   true,
+		})
+	}
+
+	return points, nil
+}
+
+// GetOrderBook retrieves an order book snapshot (limit: 5, 10, 20, 50, 100, 500, 1000)
+func (c *APIClient) GetOrderBook(symbol string, limit int) (*DepthSnapshot, error) {
+	url := fmt.Sprintf("%s/fapi/v1/depth?symbol=%s&limit=%d", baseURL, symbol, limit)
+
+	resp, err := c.client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response body failed: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var binanceErr BinanceErrorResponse
+		if json.Unmarshal(body, &binanceErr) == nil && binanceErr.Code != 0 {
+			return nil, &binanceErr
+		}
+		return nil, fmt.Errorf("HTTP %d: %s", resp.StatusCode, string(body))
+	}
+
+	var snapshot DepthSnapshot
+	if err := json.Unmarshal(body, &snapshot); err != nil {
+		return nil, fmt.Errorf("parse order book JSON failed: %w", err)
+	}
+	return &snapshot, nil
+}
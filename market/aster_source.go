@@ -0,0 +1,363 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	asterBaseURL   = "https://fapi.asterdex.com"
+	asterStreamURL = "wss://fstream.asterdex.com/stream"
+
+	// asterMarkFreshness WebSocket 标记价格的有效期，超过后回退到 REST
+	asterMarkFreshness = 15 * time.Second
+)
+
+// AsterSource Aster 行情数据源（REST 接口与币安 fapi 兼容，标记价格/资金费率通过 WebSocket 实时推送）
+type AsterSource struct {
+	baseURL string
+	client  *http.Client
+	stream  *asterMarkStream
+	oi      *oiTracker
+
+	mu      sync.Mutex
+	latency time.Duration
+}
+
+// NewAsterSource 创建 Aster 数据源
+func NewAsterSource() *AsterSource {
+	return newAsterSourceWithURL(asterBaseURL, asterStreamURL)
+}
+
+func newAsterSourceWithURL(baseURL, streamURL string) *AsterSource {
+	return &AsterSource{
+		baseURL: baseURL,
+		client:  &http.Client{Timeout: 15 * time.Second},
+		stream:  newAsterMarkStream(streamURL),
+		oi:      newOITracker(),
+	}
+}
+
+// GetName 获取数据源名称
+func (s *AsterSource) GetName() string {
+	return "aster"
+}
+
+// GetKlines 获取K线数据
+func (s *AsterSource) GetKlines(symbol, interval string, limit int) ([]Kline, error) {
+	params := url.Values{}
+	params.Set("symbol", Normalize(symbol))
+	params.Set("interval", interval)
+	params.Set("limit", strconv.Itoa(limit))
+
+	var raw []KlineResponse
+	if err := s.get("/fapi/v3/klines", params, &raw); err != nil {
+		return nil, err
+	}
+
+	klines := make([]Kline, 0, len(raw))
+	for _, kr := range raw {
+		kline, err := parseKline(kr)
+		if err != nil {
+			continue
+		}
+		klines = append(klines, kline)
+	}
+	if len(klines) == 0 {
+		return nil, fmt.Errorf("Aster未返回%s的K线数据", symbol)
+	}
+	return klines, nil
+}
+
+// GetTicker 获取最新价格（优先使用 WebSocket 标记价格）
+func (s *AsterSource) GetTicker(symbol string) (*Ticker, error) {
+	symbol = Normalize(symbol)
+	s.stream.watch(symbol)
+	if mark, ok := s.stream.get(symbol); ok {
+		return &Ticker{Symbol: symbol, LastPrice: mark.price, Timestamp: mark.updatedAt.UnixMilli()}, nil
+	}
+
+	premium, err := s.premiumIndex(symbol)
+	if err != nil {
+		return nil, err
+	}
+	return &Ticker{Symbol: symbol, LastPrice: premium.price, Timestamp: time.Now().UnixMilli()}, nil
+}
+
+// GetOpenInterest 获取持仓量
+func (s *AsterSource) GetOpenInterest(symbol string) (*OIData, error) {
+	symbol = Normalize(symbol)
+	params := url.Values{}
+	params.Set("symbol", symbol)
+
+	var result struct {
+		OpenInterest string `json:"openInterest"`
+	}
+	if err := s.get("/fapi/v1/openInterest", params, &result); err != nil {
+		return nil, err
+	}
+	oi, err := strconv.ParseFloat(result.OpenInterest, 64)
+	if err != nil {
+		return nil, fmt.Errorf("解析持仓量失败: %w", err)
+	}
+	return s.oi.record(symbol, oi, time.Now()), nil
+}
+
+// GetFundingRate 获取资金费率（优先使用 WebSocket 推送）
+func (s *AsterSource) GetFundingRate(symbol string) (float64, error) {
+	symbol = Normalize(symbol)
+	s.stream.watch(symbol)
+	if mark, ok := s.stream.get(symbol); ok {
+		return mark.funding, nil
+	}
+
+	premium, err := s.premiumIndex(symbol)
+	if err != nil {
+		return 0, err
+	}
+	return premium.funding, nil
+}
+
+// HealthCheck 健康检查
+func (s *AsterSource) HealthCheck() error {
+	var result map[string]interface{}
+	return s.get("/fapi/v3/time", nil, &result)
+}
+
+// GetLatency 获取最近一次请求的延迟
+func (s *AsterSource) GetLatency() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.latency
+}
+
+// Close 关闭 WebSocket 连接
+func (s *AsterSource) Close() {
+	s.stream.close()
+}
+
+// premiumIndex 通过 REST 获取标记价格与资金费率
+func (s *AsterSource) premiumIndex(symbol string) (asterMark, error) {
+	params := url.Values{}
+	params.Set("symbol", symbol)
+
+	var result struct {
+		MarkPrice       string `json:"markPrice"`
+		LastFundingRate string `json:"lastFundingRate"`
+	}
+	if err := s.get("/fapi/v3/premiumIndex", params, &result); err != nil {
+		return asterMark{}, err
+	}
+	price, _ := strconv.ParseFloat(result.MarkPrice, 64)
+	if price <= 0 {
+		return asterMark{}, fmt.Errorf("Aster未返回%s的有效价格", symbol)
+	}
+	funding, _ := strconv.ParseFloat(result.LastFundingRate, 64)
+	return asterMark{price: price, funding: funding, updatedAt: time.Now()}, nil
+}
+
+// get 调用 REST 接口
+func (s *AsterSource) get(path string, params url.Values, result interface{}) error {
+	endpoint := s.baseURL + path
+	if len(params) > 0 {
+		endpoint += "?" + params.Encode()
+	}
+
+	start := time.Now()
+	resp, err := s.client.Get(endpoint)
+	if err != nil {
+		return fmt.Errorf("Aster请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取Aster响应失败: %w", err)
+	}
+	s.mu.Lock()
+	s.latency = time.Since(start)
+	s.mu.Unlock()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr BinanceErrorResponse
+		if json.Unmarshal(body, &apiErr) == nil && apiErr.Code != 0 {
+			return fmt.Errorf("Aster API error (code %d): %s", apiErr.Code, apiErr.Msg)
+		}
+		return fmt.Errorf("Aster HTTP %d: %s", resp.StatusCode, string(body[:min(200, len(body))]))
+	}
+	if err := json.Unmarshal(body, result); err != nil {
+		return fmt.Errorf("解析Aster响应失败: %w", err)
+	}
+	return nil
+}
+
+// asterMark 标记价格快照
+type asterMark struct {
+	price     float64
+	funding   float64
+	updatedAt time.Time
+}
+
+// asterMarkStream 按需订阅 <symbol>@markPrice@1s，断线后自动重连并恢复订阅
+type asterMarkStream struct {
+	url string
+
+	mu      sync.Mutex
+	conn    *websocket.Conn
+	symbols map[string]bool
+	marks   map[string]asterMark
+	started bool
+	closed  bool
+	done    chan struct{}
+}
+
+func newAsterMarkStream(url string) *asterMarkStream {
+	return &asterMarkStream{
+		url:     url,
+		symbols: make(map[string]bool),
+		marks:   make(map[string]asterMark),
+		done:    make(chan struct{}),
+	}
+}
+
+// watch 订阅币种的标记价格（首次调用时启动连接）
+func (m *asterMarkStream) watch(symbol string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed || m.symbols[symbol] {
+		return
+	}
+	m.symbols[symbol] = true
+	if !m.started {
+		m.started = true
+		go m.run()
+		return
+	}
+	if m.conn != nil {
+		if err := m.conn.WriteJSON(asterSubscribeMessage([]string{symbol})); err != nil {
+			log.Printf("⚠️ [Aster行情] 订阅 %s 标记价格失败: %v", symbol, err)
+		}
+	}
+}
+
+// get 获取未过期的标记价格
+func (m *asterMarkStream) get(symbol string) (asterMark, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mark, ok := m.marks[symbol]
+	if !ok || time.Since(mark.updatedAt) > asterMarkFreshness {
+		return asterMark{}, false
+	}
+	return mark, true
+}
+
+func (m *asterMarkStream) close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return
+	}
+	m.closed = true
+	close(m.done)
+	if m.conn != nil {
+		m.conn.Close()
+	}
+}
+
+// run 连接并读取推送，断线后 3 秒重连
+func (m *asterMarkStream) run() {
+	for {
+		if err := m.connectAndRead(); err != nil {
+			log.Printf("⚠️ [Aster行情] WebSocket断开: %v，3秒后重连", err)
+		}
+		select {
+		case <-m.done:
+			return
+		case <-time.After(3 * time.Second):
+		}
+	}
+}
+
+func (m *asterMarkStream) connectAndRead() error {
+	dialer := websocket.Dialer{HandshakeTimeout: 10 * time.Second}
+	conn, _, err := dialer.Dial(m.url, nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	symbols := make([]string, 0, len(m.symbols))
+	for symbol := range m.symbols {
+		symbols = append(symbols, symbol)
+	}
+	m.conn = conn
+	err = conn.WriteJSON(asterSubscribeMessage(symbols))
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		m.mu.Lock()
+		m.conn = nil
+		m.mu.Unlock()
+	}()
+
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		m.handleMessage(message)
+	}
+}
+
+func (m *asterMarkStream) handleMessage(message []byte) {
+	var msg struct {
+		Data struct {
+			Event       string `json:"e"`
+			Symbol      string `json:"s"`
+			MarkPrice   string `json:"p"`
+			FundingRate string `json:"r"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(message, &msg); err != nil || msg.Data.Event != "markPriceUpdate" {
+		return
+	}
+	price, _ := strconv.ParseFloat(msg.Data.MarkPrice, 64)
+	if price <= 0 {
+		return
+	}
+	funding, _ := strconv.ParseFloat(msg.Data.FundingRate, 64)
+
+	m.mu.Lock()
+	m.marks[msg.Data.Symbol] = asterMark{price: price, funding: funding, updatedAt: time.Now()}
+	m.mu.Unlock()
+}
+
+func asterSubscribeMessage(symbols []string) map[string]interface{} {
+	streams := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		streams[i] = strings.ToLower(symbol) + "@markPrice@1s"
+	}
+	return map[string]interface{}{
+		"method": "SUBSCRIBE",
+		"params": streams,
+		"id":     time.Now().UnixNano(),
+	}
+}
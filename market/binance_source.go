@@ -0,0 +1,174 @@
+package market
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// wsKlineMaxAge WebSocket K线缓存的最大允许时长，超过后视为过期并故障转移到下一个数据源
+	wsKlineMaxAge = 3 * time.Minute
+
+	// wsHealthMaxSilence WebSocket 无任何推送的最大时长，超过后健康检查失败
+	wsHealthMaxSilence = 2 * time.Minute
+)
+
+// BinanceWSSource 币安 WebSocket 数据源（读取 WSMonitorCli 缓存，主数据源）
+type BinanceWSSource struct{}
+
+// NewBinanceWSSource 创建币安 WebSocket 数据源
+func NewBinanceWSSource() *BinanceWSSource {
+	return &BinanceWSSource{}
+}
+
+// GetName 获取数据源名称
+func (s *BinanceWSSource) GetName() string {
+	return "binance-ws"
+}
+
+// GetKlines 从WebSocket缓存获取K线（缓存过期时返回错误以触发故障转移）
+func (s *BinanceWSSource) GetKlines(symbol, interval string, limit int) ([]Kline, error) {
+	monitor := WSMonitorCli
+	if monitor == nil {
+		return nil, fmt.Errorf("WebSocket监控未启动")
+	}
+	symbol = Normalize(symbol)
+
+	klines, receivedAt, ok := monitor.GetCachedKlines(symbol, interval)
+	if !ok {
+		// 未缓存的币种/周期：由 GetCurrentKlines 通过API初始化缓存并动态订阅
+		return monitor.GetCurrentKlines(symbol, interval)
+	}
+	if age := time.Since(receivedAt); age > wsKlineMaxAge {
+		return nil, fmt.Errorf("%s %s WebSocket数据已过期 (%.1f分钟未更新)", symbol, interval, age.Minutes())
+	}
+	if len(klines) == 0 {
+		return nil, fmt.Errorf("%s %s WebSocket缓存为空", symbol, interval)
+	}
+	if limit > 0 && len(klines) > limit {
+		klines = klines[len(klines)-limit:]
+	}
+	return klines, nil
+}
+
+// GetTicker 使用WebSocket缓存中最新K线的收盘价
+func (s *BinanceWSSource) GetTicker(symbol string) (*Ticker, error) {
+	monitor := WSMonitorCli
+	if monitor == nil {
+		return nil, fmt.Errorf("WebSocket监控未启动")
+	}
+	symbol = Normalize(symbol)
+
+	for _, interval := range []string{"1m", "3m"} {
+		klines, receivedAt, ok := monitor.GetCachedKlines(symbol, interval)
+		if !ok || len(klines) == 0 || time.Since(receivedAt) > wsKlineMaxAge {
+			continue
+		}
+		last := klines[len(klines)-1]
+		return &Ticker{
+			Symbol:    symbol,
+			LastPrice: last.Close,
+			Volume:    last.Volume,
+			Timestamp: receivedAt.UnixMilli(),
+		}, nil
+	}
+	return nil, fmt.Errorf("%s 无有效的WebSocket价格", symbol)
+}
+
+// HealthCheck 检查WebSocket是否持续有推送
+func (s *BinanceWSSource) HealthCheck() error {
+	monitor := WSMonitorCli
+	if monitor == nil {
+		return fmt.Errorf("WebSocket监控未启动")
+	}
+	last := monitor.LastMessageAt()
+	if last.IsZero() {
+		return fmt.Errorf("尚未收到WebSocket推送")
+	}
+	if silence := time.Since(last); silence > wsHealthMaxSilence {
+		return fmt.Errorf("WebSocket已%.0f秒无推送", silence.Seconds())
+	}
+	return nil
+}
+
+// GetLatency 获取延迟（本地缓存，无网络延迟）
+func (s *BinanceWSSource) GetLatency() time.Duration {
+	return 0
+}
+
+// cached 标记为缓存数据源（GetFresh 强制获取最新数据时跳过）
+func (s *BinanceWSSource) cached() bool {
+	return true
+}
+
+// BinanceAPISource 币安 REST 数据源
+type BinanceAPISource struct {
+	client *APIClient
+
+	mu      sync.Mutex
+	latency time.Duration
+}
+
+// NewBinanceAPISource 创建币安 REST 数据源
+func NewBinanceAPISource() *BinanceAPISource {
+	return &BinanceAPISource{client: NewAPIClient()}
+}
+
+// GetName 获取数据源名称
+func (s *BinanceAPISource) GetName() string {
+	return "binance-api"
+}
+
+// GetKlines 获取K线数据
+func (s *BinanceAPISource) GetKlines(symbol, interval string, limit int) ([]Kline, error) {
+	return loadHistoricalKlines(s.client, Normalize(symbol), interval, limit)
+}
+
+// GetTicker 获取最新价格
+func (s *BinanceAPISource) GetTicker(symbol string) (*Ticker, error) {
+	symbol = Normalize(symbol)
+	price, err := s.client.GetCurrentPrice(symbol)
+	if err != nil {
+		return nil, err
+	}
+	return &Ticker{Symbol: symbol, LastPrice: price, Timestamp: time.Now().UnixMilli()}, nil
+}
+
+// GetOpenInterest 获取持仓量（使用 WSMonitorCli 的OI历史计算4小时变化率）
+func (s *BinanceAPISource) GetOpenInterest(symbol string) (*OIData, error) {
+	symbol = Normalize(symbol)
+	oiData, err := s.client.GetOpenInterest(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	oiData.ActualPeriod = "N/A"
+	if WSMonitorCli != nil {
+		oiData.Change4h, oiData.ActualPeriod = WSMonitorCli.CalculateOIChange4h(symbol, oiData.Latest)
+		oiData.Historical = WSMonitorCli.GetOIHistory(symbol)
+	}
+	return oiData, nil
+}
+
+// GetFundingRate 获取资金费率
+func (s *BinanceAPISource) GetFundingRate(symbol string) (float64, error) {
+	return s.client.GetFundingRate(Normalize(symbol))
+}
+
+// HealthCheck 健康检查
+func (s *BinanceAPISource) HealthCheck() error {
+	start := time.Now()
+	_, err := s.client.GetCurrentPrice("BTCUSDT")
+	s.mu.Lock()
+	s.latency = time.Since(start)
+	s.mu.Unlock()
+	return err
+}
+
+// GetLatency 获取最近一次健康检查的延迟
+func (s *BinanceAPISource) GetLatency() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.latency
+}
@@ -42,11 +42,25 @@ func (c *CombinedStreamsClient) Connect() error {
 
 	c.mu.Lock()
 	c.conn = conn
+	streams := make([]string, 0, len(c.subscribers))
+	for stream := range c.subscribers {
+		streams = append(streams, stream)
+	}
 	c.mu.Unlock()
 
 	log.Println("组合流WebSocket连接成功")
 	go c.readMessages()
 
+	// 重连后恢复已有订阅
+	if len(streams) > 0 {
+		for _, batch := range c.splitIntoBatches(streams, c.batchSize) {
+			if err := c.subscribeStreams(batch); err != nil {
+				log.Printf("⚠️  恢复订阅失败: %v", err)
+				break
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -110,6 +124,25 @@ func (c *CombinedStreamsClient) subscribeStreams(streams []string) error {
 	return c.conn.WriteJSON(subscribeMsg)
 }
 
+// unsubscribeStreams 取消订阅多个流
+func (c *CombinedStreamsClient) unsubscribeStreams(streams []string) error {
+	unsubscribeMsg := map[string]interface{}{
+		"method": "UNSUBSCRIBE",
+		"params": streams,
+		"id":     time.Now().UnixNano(),
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.conn == nil {
+		return fmt.Errorf("WebSocket未连接")
+	}
+
+	log.Printf("取消订阅流: %v", streams)
+	return c.conn.WriteJSON(unsubscribeMsg)
+}
+
 func (c *CombinedStreamsClient) readMessages() {
 	for {
 		select {
@@ -148,11 +181,11 @@ func (c *CombinedStreamsClient) handleCombinedMessage(message []byte) {
 		return
 	}
 
+	// 持读锁发送（非阻塞），避免与 RemoveSubscriber 关闭通道竞争
 	c.mu.RLock()
-	ch, exists := c.subscribers[combinedMsg.Stream]
-	c.mu.RUnlock()
+	defer c.mu.RUnlock()
 
-	if exists {
+	if ch, exists := c.subscribers[combinedMsg.Stream]; exists {
 		select {
 		case ch <- combinedMsg.Data:
 		default:
@@ -164,11 +197,25 @@ func (c *CombinedStreamsClient) handleCombinedMessage(message []byte) {
 func (c *CombinedStreamsClient) AddSubscriber(stream string, bufferSize int) <-chan []byte {
 	ch := make(chan []byte, bufferSize)
 	c.mu.Lock()
+	if old, exists := c.subscribers[stream]; exists {
+		close(old)
+	}
 	c.subscribers[stream] = ch
 	c.mu.Unlock()
 	return ch
 }
 
+// RemoveSubscriber 移除订阅者并关闭其通道
+func (c *CombinedStreamsClient) RemoveSubscriber(stream string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if ch, exists := c.subscribers[stream]; exists {
+		close(ch)
+		delete(c.subscribers, stream)
+	}
+}
+
 func (c *CombinedStreamsClient) handleReconnect() {
 	if !c.reconnect {
 		return
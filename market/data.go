@@ -1,12 +1,9 @@
 package market
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"log"
 	"math"
-	"net/http"
 	"strconv"
 	"strings"
 	"sync"
@@ -31,15 +28,16 @@ func Get(symbol string) (*Data, error) {
 	var err error
 	// 标准化symbol
 	symbol = Normalize(symbol)
+	sources := DefaultSourceManager()
 
 	// 获取1分钟K线数据（用于实时价格）
-	klines1m, err := WSMonitorCli.GetCurrentKlines(symbol, "1m")
+	klines1m, err := sources.GetKlinesWithFallback(symbol, "1m", 100)
 	if err != nil {
 		log.Printf("⚠️  WARNING: %s 获取1分钟K线失败: %v，使用3分钟K线作为实时价格", symbol, err)
 	}
 
 	// 获取3分钟K线数据 (最近10个) - 主数据源
-	klines3m, err = WSMonitorCli.GetCurrentKlines(symbol, "3m")
+	klines3m, err = sources.GetKlinesWithFallback(symbol, "3m", 100)
 	if err != nil {
 		return nil, fmt.Errorf("获取3分钟K线失败: %v", err)
 	}
@@ -51,19 +49,19 @@ func Get(symbol string) (*Data, error) {
 	}
 
 	// 获取15分钟K线数据 (最近40个) - 短期趋势 (PR #798)
-	klines15m, err = WSMonitorCli.GetCurrentKlines(symbol, "15m")
+	klines15m, err = sources.GetKlinesWithFallback(symbol, "15m", 100)
 	if err != nil {
 		return nil, fmt.Errorf("获取15分钟K线失败: %v", err)
 	}
 
 	// 获取1小时K线数据 (最近60个) - 中期趋势 (PR #798)
-	klines1h, err = WSMonitorCli.GetCurrentKlines(symbol, "1h")
+	klines1h, err = sources.GetKlinesWithFallback(symbol, "1h", 100)
 	if err != nil {
 		return nil, fmt.Errorf("获取1小时K线失败: %v", err)
 	}
 
 	// 获取4小时K线数据 (最近60个) - 长期趋势
-	klines4h, err = WSMonitorCli.GetCurrentKlines(symbol, "4h")
+	klines4h, err = sources.GetKlinesWithFallback(symbol, "4h", 100)
 	if err != nil {
 		return nil, fmt.Errorf("获取4小时K线失败: %v", err)
 	}
@@ -75,42 +73,12 @@ func Get(symbol string) (*Data, error) {
 	}
 
 	// 获取日线K线数据 (最近90个) - 极长期趋势和极端位置判断
-	klines1d, err = WSMonitorCli.GetCurrentKlines(symbol, "1d")
+	klines1d, err = sources.GetKlinesWithFallback(symbol, "1d", 100)
 	if err != nil {
 		log.Printf("⚠️  WARNING: %s 获取日线K线失败: %v，将继续处理但缺少日线数据", symbol, err)
 		klines1d = nil // 日线数据失败不影响整体流程
 	}
 
-	// ⚡ 关键修复：优先使用1分钟K线价格作为当前价格（最实时）
-	currentPrice := klines3m[len(klines3m)-1].Close // 3分钟K线价格（用于指标计算）
-	realtimePrice := currentPrice
-	if klines1m != nil && len(klines1m) > 0 {
-		realtimePrice = klines1m[len(klines1m)-1].Close // 1分钟K线价格（最实时）
-		// ⚡ 关键：使用1分钟K线价格作为CurrentPrice，确保AI决策基于最新价格
-		currentPrice = realtimePrice
-	}
-
-	currentEMA20 := calculateEMA(klines3m, 20)
-	currentMACD := calculateMACD(klines1h)
-	currentRSI7 := calculateRSI(klines1h, 7)
-
-	// 计算价格变化百分比
-	priceChange1h := 0.0
-	if len(klines1h) >= 2 {
-		price1hAgo := klines1h[len(klines1h)-2].Close
-		if price1hAgo > 0 {
-			priceChange1h = ((currentPrice - price1hAgo) / price1hAgo) * 100
-		}
-	}
-
-	priceChange4h := 0.0
-	if len(klines4h) >= 2 {
-		price4hAgo := klines4h[len(klines4h)-2].Close
-		if price4hAgo > 0 {
-			priceChange4h = ((currentPrice - price4hAgo) / price4hAgo) * 100
-		}
-	}
-
 	// 获取OI数据
 	oiData, err := getOpenInterestData(symbol)
 	if err != nil {
@@ -121,42 +89,7 @@ func Get(symbol string) (*Data, error) {
 	// 获取Funding Rate
 	fundingRate, _ := getFundingRate(symbol)
 
-	// 计算日内系列数据 (3分钟)
-	intradayData := calculateIntradaySeries(klines3m)
-
-	// 计算15分钟系列数据
-	midTermData15m := calculateMidTermSeries15m(klines15m)
-
-	// 计算1小时系列数据
-	midTermData1h := calculateMidTermSeries1h(klines1h)
-
-	// 计算长期数据 (4小时)
-	longerTermData := calculateLongerTermData(klines4h)
-
-	// 计算日线数据（如果可用）
-	var dailyData *DailyData
-	if len(klines1d) > 0 {
-		dailyData = calculateDailyData(klines1d)
-	}
-
-	return &Data{
-		Symbol:            symbol,
-		CurrentPrice:      currentPrice,
-		RealtimePrice:     realtimePrice,
-		PriceChange1h:     priceChange1h,
-		PriceChange4h:     priceChange4h,
-		CurrentEMA20:      currentEMA20,
-		CurrentMACD:       currentMACD,
-		CurrentRSI7:       currentRSI7,
-		OpenInterest:      oiData,
-		FundingRate:       fundingRate,
-		IntradaySeries:    intradayData,
-		MidTermSeries15m:  midTermData15m,
-		MidTermSeries1h:   midTermData1h,
-		LongerTermContext: longerTermData,
-		DailyContext:      dailyData,
-		RawKlines1h:       klines1h, // 保存原始1小时K线数据，用于K线形态分析
-	}, nil
+	return buildData(symbol, klines1m, klines3m, klines15m, klines1h, klines4h, klines1d, oiData, fundingRate), nil
 }
 
 // GetFresh 强制从API获取最新市场数据（用于AI决策，确保使用最新价格）
@@ -167,33 +100,33 @@ func GetFresh(symbol string) (*Data, error) {
 	// 标准化symbol
 	symbol = Normalize(symbol)
 
-	// 创建API客户端，强制从API获取最新数据
-	apiClient := NewAPIClient()
+	// 通过数据源管理器强制从API获取最新数据（跳过WebSocket缓存，主API失败时自动故障转移）
+	sources := DefaultSourceManager()
 
 	log.Printf("🔄 [GetFresh] 强制从API获取 %s 的最新市场数据（用于AI决策）", symbol)
 
 	// 强制从API获取所有K线数据（不使用WebSocket缓存）
-	klines1m, err = apiClient.GetKlines(symbol, "1m", 100)
+	klines1m, err = sources.GetFreshKlinesWithFallback(symbol, "1m", 100)
 	if err != nil {
 		return nil, fmt.Errorf("获取1分钟K线失败: %v", err)
 	}
 
-	klines3m, err = apiClient.GetKlines(symbol, "3m", 100)
+	klines3m, err = sources.GetFreshKlinesWithFallback(symbol, "3m", 100)
 	if err != nil {
 		return nil, fmt.Errorf("获取3分钟K线失败: %v", err)
 	}
 
-	klines15m, err = apiClient.GetKlines(symbol, "15m", 100)
+	klines15m, err = sources.GetFreshKlinesWithFallback(symbol, "15m", 100)
 	if err != nil {
 		return nil, fmt.Errorf("获取15分钟K线失败: %v", err)
 	}
 
-	klines1h, err = apiClient.GetKlines(symbol, "1h", 100)
+	klines1h, err = sources.GetFreshKlinesWithFallback(symbol, "1h", 100)
 	if err != nil {
 		return nil, fmt.Errorf("获取1小时K线失败: %v", err)
 	}
 
-	klines4h, err = apiClient.GetKlines(symbol, "4h", 100)
+	klines4h, err = sources.GetFreshKlinesWithFallback(symbol, "4h", 100)
 	if err != nil {
 		return nil, fmt.Errorf("获取4小时K线失败: %v", err)
 	}
@@ -205,12 +138,32 @@ func GetFresh(symbol string) (*Data, error) {
 	}
 
 	// 获取日线K线数据
-	klines1d, err = apiClient.GetKlines(symbol, "1d", 100)
+	klines1d, err = sources.GetFreshKlinesWithFallback(symbol, "1d", 100)
 	if err != nil {
 		log.Printf("⚠️  WARNING: %s 获取日线K线失败: %v，将继续处理但缺少日线数据", symbol, err)
 		klines1d = nil
 	}
 
+	if len(klines1m) > 0 {
+		log.Printf("✅ [GetFresh] %s 使用1分钟K线价格: %.2f", symbol, klines1m[len(klines1m)-1].Close)
+	} else {
+		log.Printf("⚠️ [GetFresh] %s 1分钟K线不可用，使用3分钟K线价格: %.2f", symbol, klines3m[len(klines3m)-1].Close)
+	}
+
+	// 获取OI数据
+	oiData, err := getOpenInterestData(symbol)
+	if err != nil {
+		oiData = &OIData{Latest: 0, Average: 0, ActualPeriod: "N/A"}
+	}
+
+	// 获取Funding Rate
+	fundingRate, _ := getFundingRate(symbol)
+
+	return buildData(symbol, klines1m, klines3m, klines15m, klines1h, klines4h, klines1d, oiData, fundingRate), nil
+}
+
+// buildData 由各周期K线、OI和资金费率计算指标并组装市场数据（klines3m、klines4h 不能为空）
+func buildData(symbol string, klines1m, klines3m, klines15m, klines1h, klines4h, klines1d []Kline, oiData *OIData, fundingRate float64) *Data {
 	// ⚡ 关键修复：优先使用1分钟K线价格作为当前价格（最实时）
 	// 如果1分钟K线不可用，回退到3分钟K线价格
 	currentPrice := klines3m[len(klines3m)-1].Close // 3分钟K线价格（用于指标计算）
@@ -219,9 +172,6 @@ func GetFresh(symbol string) (*Data, error) {
 		realtimePrice = klines1m[len(klines1m)-1].Close // 1分钟K线价格（最实时）
 		// ⚡ 关键：使用1分钟K线价格作为CurrentPrice，确保AI决策基于最新价格
 		currentPrice = realtimePrice
-		log.Printf("✅ [GetFresh] %s 使用1分钟K线价格: %.2f", symbol, realtimePrice)
-	} else {
-		log.Printf("⚠️ [GetFresh] %s 1分钟K线不可用，使用3分钟K线价格: %.2f", symbol, currentPrice)
 	}
 
 	currentEMA20 := calculateEMA(klines3m, 20)
@@ -245,21 +195,13 @@ func GetFresh(symbol string) (*Data, error) {
 		}
 	}
 
-	// 获取OI数据
-	oiData, err := getOpenInterestData(symbol)
-	if err != nil {
-		oiData = &OIData{Latest: 0, Average: 0, ActualPeriod: "N/A"}
-	}
-
-	// 获取Funding Rate
-	fundingRate, _ := getFundingRate(symbol)
-
-	// 计算日内系列数据
+	// 计算各周期系列数据
 	intradayData := calculateIntradaySeries(klines3m)
 	midTermData15m := calculateMidTermSeries15m(klines15m)
 	midTermData1h := calculateMidTermSeries1h(klines1h)
 	longerTermData := calculateLongerTermData(klines4h)
 
+	// 计算日线数据（如果可用）
 	var dailyData *DailyData
 	if len(klines1d) > 0 {
 		dailyData = calculateDailyData(klines1d)
@@ -282,7 +224,7 @@ func GetFresh(symbol string) (*Data, error) {
 		LongerTermContext: longerTermData,
 		DailyContext:      dailyData,
 		RawKlines1h:       klines1h, // 保存原始1小时K线数据，用于K线形态分析
-	}, nil
+	}
 }
 
 // calculateEMA 计算EMA
@@ -669,54 +611,8 @@ func getOpenInterestData(symbol string) (*OIData, error) {
 		log.Printf("⚠️  [OI缓存不可用] Symbol: %s, WSMonitorCli为nil", symbol)
 	}
 
-	// ⚠️ 降级：缓存不存在时才调用 API（仅冷启动或缓存失效）
-	url := fmt.Sprintf("https://fapi.binance.com/fapi/v1/openInterest?symbol=%s", symbol)
-
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var result struct {
-		OpenInterest string `json:"openInterest"`
-		Symbol       string `json:"symbol"`
-		Time         int64  `json:"time"`
-	}
-
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, err
-	}
-
-	oi, _ := strconv.ParseFloat(result.OpenInterest, 64)
-
-	// 计算4小时变化率
-	var change4h float64
-	var actualPeriod string
-	if WSMonitorCli != nil {
-		change4h, actualPeriod = WSMonitorCli.CalculateOIChange4h(symbol, oi)
-	} else {
-		actualPeriod = "N/A"
-	}
-
-	// 获取历史数据
-	var history []OISnapshot
-	if WSMonitorCli != nil {
-		history = WSMonitorCli.GetOIHistory(symbol)
-	}
-
-	return &OIData{
-		Latest:       oi,
-		Average:      oi * 0.999,
-		Change4h:     change4h,
-		ActualPeriod: actualPeriod,
-		Historical:   history,
-	}, nil
+	// ⚠️ 降级：缓存不存在时才通过数据源管理器调用 API（仅冷启动或缓存失效）
+	return DefaultSourceManager().GetOpenInterestWithFallback(symbol)
 }
 
 // getFundingRate 获取资金费率（优化：使用 1 小时缓存）
@@ -734,42 +630,25 @@ func getFundingRate(symbol string) (float64, error) {
 		}
 	}
 
-	// ⚠️ 缓存过期或不存在，调用 API
-	url := fmt.Sprintf("https://fapi.binance.com/fapi/v1/premiumIndex?symbol=%s", symbol)
-
-	resp, err := http.Get(url)
-	if err != nil {
-		return 0, err
-	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
+	// ⚠️ 缓存过期或不存在，通过数据源管理器调用 API
+	rate, err := DefaultSourceManager().GetFundingRateWithFallback(symbol)
 	if err != nil {
 		return 0, err
 	}
 
-	var result struct {
-		Symbol          string `json:"symbol"`
-		MarkPrice       string `json:"markPrice"`
-		IndexPrice      string `json:"indexPrice"`
-		LastFundingRate string `json:"lastFundingRate"`
-		NextFundingTime int64  `json:"nextFundingTime"`
-		InterestRate    string `json:"interestRate"`
-		Time            int64  `json:"time"`
-	}
-
-	if err := json.Unmarshal(body, &result); err != nil {
-		return 0, err
-	}
-
-	rate, _ := strconv.ParseFloat(result.LastFundingRate, 64)
-
 	// ✅ 更新缓存
 	fundingRateMap.Store(symbol, &FundingRateCache{
 		Rate:      rate,
 		UpdatedAt: time.Now(),
 	})
 
+	// 实时费率快照写入本地历史存储
+	if store := GetHistoryStore(); store != nil {
+		if err := store.SaveFundingRates(symbol, []FundingRatePoint{{Rate: rate, Timestamp: time.Now()}}); err != nil {
+			log.Printf("⚠️  保存 %s 资金费率失败: %v", symbol, err)
+		}
+	}
+
 	return rate, nil
 }
 
@@ -813,6 +692,18 @@ func Format(data *Data) string {
 
 	sb.WriteString(fmt.Sprintf("Funding Rate: %.2e\n\n", data.FundingRate))
 
+	if f := data.Funding; f != nil {
+		sb.WriteString(formatFunding(f))
+	}
+
+	if ob := data.OrderBook; ob != nil {
+		sb.WriteString(formatOrderBook(ob))
+	}
+
+	if flow := data.Flow; flow != nil {
+		sb.WriteString(formatTradeFlow(flow))
+	}
+
 	if data.IntradaySeries != nil {
 		sb.WriteString("Intraday series (3‑minute intervals, oldest → latest):\n\n")
 
@@ -987,6 +878,40 @@ func formatFloatSlice(values []float64) string {
 	return "[" + strings.Join(strValues, ", ") + "]"
 }
 
+// formatOrderBook 格式化盘口流动性指标
+func formatOrderBook(ob *OrderBookMetrics) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Order Book: spread = %.2f bps | depth ±0.5%%: bid $%s / ask $%s (imbalance %+.2f) | depth ±1%%: bid $%s / ask $%s (imbalance %+.2f)",
+		ob.SpreadBps, formatCompactUSD(ob.BidDepth05), formatCompactUSD(ob.AskDepth05), ob.Imbalance05,
+		formatCompactUSD(ob.BidDepth1), formatCompactUSD(ob.AskDepth1), ob.Imbalance1))
+	if ob.Truncated {
+		sb.WriteString(" [visible book < ±1%, depth may be understated]")
+	}
+	sb.WriteString("\n")
+	if ob.SizeUSD > 0 {
+		sb.WriteString(fmt.Sprintf("Estimated slippage for $%s market order: buy %.2f bps / sell %.2f bps",
+			formatCompactUSD(ob.SizeUSD), ob.BuySlippageBps, ob.SellSlippageBps))
+		if ob.Unfillable {
+			sb.WriteString(" [book too thin to fill fully]")
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// formatCompactUSD 以 K/M 为单位格式化金额
+func formatCompactUSD(v float64) string {
+	switch {
+	case v >= 1_000_000:
+		return fmt.Sprintf("%.2fM", v/1_000_000)
+	case v >= 1_000:
+		return fmt.Sprintf("%.1fK", v/1_000)
+	default:
+		return fmt.Sprintf("%.0f", v)
+	}
+}
+
 // Normalize 标准化symbol,确保是USDT交易对
 func Normalize(symbol string) string {
 	symbol = strings.ToUpper(symbol)
@@ -16,6 +16,16 @@ type DataSource interface {
 	GetLatency() time.Duration                                     // 获取延迟
 }
 
+// OpenInterestSource 可提供持仓量的数据源（可选能力）
+type OpenInterestSource interface {
+	GetOpenInterest(symbol string) (*OIData, error)
+}
+
+// FundingRateSource 可提供资金费率的数据源（可选能力，返回 8 小时等效费率，与币安口径一致）
+type FundingRateSource interface {
+	GetFundingRate(symbol string) (float64, error)
+}
+
 // DataSourceStatus 数据源状态
 type DataSourceStatus struct {
 	Name          string        // 数据源名称
@@ -25,6 +35,7 @@ type DataSourceStatus struct {
 	FailureCount  int           // 连续失败次数
 	SuccessCount  int           // 总成功次数
 	TotalRequests int           // 总请求次数
+	LastError     string        // 最近一次失败原因
 }
 
 // DataSourceManager 数据源管理器
@@ -94,22 +105,26 @@ func (dsm *DataSourceManager) Stop() {
 
 // performHealthCheck 执行健康检查
 func (dsm *DataSourceManager) performHealthCheck() {
-	dsm.mu.Lock()
-	defer dsm.mu.Unlock()
+	dsm.mu.RLock()
+	sources := make([]DataSource, len(dsm.sources))
+	copy(sources, dsm.sources)
+	dsm.mu.RUnlock()
 
 	log.Println("🔍 执行数据源健康检查...")
 
-	for _, source := range dsm.sources {
-		status := dsm.statuses[source.GetName()]
-		status.LastCheckTime = time.Now()
-
+	// 健康检查涉及网络请求，不持锁执行，避免阻塞行情读取
+	for _, source := range sources {
 		start := time.Now()
 		err := source.HealthCheck()
 		latency := time.Since(start)
 
+		dsm.mu.Lock()
+		status := dsm.statuses[source.GetName()]
+		status.LastCheckTime = time.Now()
 		if err != nil {
 			status.Healthy = false
 			status.FailureCount++
+			status.LastError = err.Error()
 			log.Printf("❌ 数据源 %s 健康检查失败: %v (连续失败 %d 次)",
 				source.GetName(), err, status.FailureCount)
 		} else {
@@ -120,8 +135,12 @@ func (dsm *DataSourceManager) performHealthCheck() {
 			log.Printf("✅ 数据源 %s 健康检查成功 (延迟: %v)",
 				source.GetName(), latency)
 		}
+		dsm.mu.Unlock()
 	}
 
+	dsm.mu.RLock()
+	defer dsm.mu.RUnlock()
+
 	// 打印健康摘要
 	healthy, total := dsm.getHealthySummary()
 	log.Printf("📊 数据源健康状态: %d/%d 健康", healthy, total)
@@ -166,80 +185,160 @@ func (dsm *DataSourceManager) GetHealthySource() (DataSource, error) {
 	return dsm.sources[0], nil
 }
 
-// GetKlinesWithFallback 获取K线数据（带故障转移）
-func (dsm *DataSourceManager) GetKlinesWithFallback(symbol, interval string, limit int) ([]Kline, error) {
-	dsm.mu.Lock()
-	sources := make([]DataSource, len(dsm.sources))
-	copy(sources, dsm.sources)
-	dsm.mu.Unlock()
+// cachedSource 基于本地缓存的数据源（如 WebSocket），GetFresh 强制获取最新数据时跳过
+type cachedSource interface {
+	cached() bool
+}
 
-	var lastErr error
+// requestFailureThreshold 请求连续失败次数达到阈值后将数据源标记为不健康（等待下一次健康检查恢复）
+const requestFailureThreshold = 3
 
-	// 尝试所有健康的数据源
-	for _, source := range sources {
-		dsm.mu.RLock()
-		status := dsm.statuses[source.GetName()]
-		healthy := status.Healthy
-		dsm.mu.RUnlock()
-
-		if !healthy {
-			continue // 跳过不健康的数据源
+// withFallback 按注册顺序依次尝试健康的数据源，直到 fn 成功
+// supports 返回 false 的数据源直接跳过；没有健康数据源时强制尝试全部候选
+func (dsm *DataSourceManager) withFallback(op string, skipCached bool, supports func(DataSource) bool, fn func(DataSource) error) error {
+	dsm.mu.RLock()
+	var healthy, unhealthy []DataSource
+	for _, source := range dsm.sources {
+		if skipCached {
+			if cs, ok := source.(cachedSource); ok && cs.cached() {
+				continue
+			}
 		}
+		if supports != nil && !supports(source) {
+			continue
+		}
+		if dsm.statuses[source.GetName()].Healthy {
+			healthy = append(healthy, source)
+		} else {
+			unhealthy = append(unhealthy, source)
+		}
+	}
+	dsm.mu.RUnlock()
 
-		klines, err := source.GetKlines(symbol, interval, limit)
-
-		dsm.mu.Lock()
-		status.TotalRequests++
-		dsm.mu.Unlock()
-
-		if err == nil && len(klines) > 0 {
-			log.Printf("✅ 从 %s 获取 %s %s K线数据成功 (%d 条)",
-				source.GetName(), symbol, interval, len(klines))
-			return klines, nil
+	candidates := healthy
+	if len(candidates) == 0 {
+		if len(unhealthy) == 0 {
+			return fmt.Errorf("没有可用的数据源")
 		}
+		log.Printf("⚠️  所有数据源都不健康，强制尝试全部数据源 (%s)", op)
+		candidates = unhealthy
+	}
 
+	var lastErr error
+	for i, source := range candidates {
+		err := fn(source)
+		dsm.recordRequest(source.GetName(), err)
+		if err == nil {
+			if i > 0 {
+				log.Printf("🔀 %s 已故障转移到数据源 %s", op, source.GetName())
+			}
+			return nil
+		}
 		lastErr = err
-		log.Printf("⚠️  从 %s 获取数据失败: %v，尝试下一个数据源...",
-			source.GetName(), err)
+		log.Printf("⚠️  从 %s 获取 %s 失败: %v，尝试下一个数据源...", source.GetName(), op, err)
 	}
 
-	return nil, fmt.Errorf("所有数据源都失败: %w", lastErr)
+	return fmt.Errorf("所有数据源都失败: %w", lastErr)
 }
 
-// GetTickerWithFallback 获取ticker数据（带故障转移）
-func (dsm *DataSourceManager) GetTickerWithFallback(symbol string) (*Ticker, error) {
+// recordRequest 记录请求结果，连续失败达到阈值时标记数据源不健康
+func (dsm *DataSourceManager) recordRequest(name string, err error) {
 	dsm.mu.Lock()
-	sources := make([]DataSource, len(dsm.sources))
-	copy(sources, dsm.sources)
-	dsm.mu.Unlock()
+	defer dsm.mu.Unlock()
 
-	var lastErr error
+	status := dsm.statuses[name]
+	status.TotalRequests++
+	if err == nil {
+		status.SuccessCount++
+		status.FailureCount = 0
+		return
+	}
 
-	// 尝试所有健康的数据源
-	for _, source := range sources {
-		dsm.mu.RLock()
-		status := dsm.statuses[source.GetName()]
-		healthy := status.Healthy
-		dsm.mu.RUnlock()
+	status.FailureCount++
+	status.LastError = err.Error()
+	if status.Healthy && status.FailureCount >= requestFailureThreshold {
+		status.Healthy = false
+		log.Printf("❌ 数据源 %s 连续失败 %d 次，标记为不健康", name, status.FailureCount)
+	}
+}
 
-		if !healthy {
-			continue
-		}
+// GetKlinesWithFallback 获取K线数据（带故障转移）
+func (dsm *DataSourceManager) GetKlinesWithFallback(symbol, interval string, limit int) ([]Kline, error) {
+	return dsm.getKlines(symbol, interval, limit, false)
+}
 
-		ticker, err := source.GetTicker(symbol)
+// GetFreshKlinesWithFallback 获取K线数据（跳过缓存数据源，带故障转移）
+func (dsm *DataSourceManager) GetFreshKlinesWithFallback(symbol, interval string, limit int) ([]Kline, error) {
+	return dsm.getKlines(symbol, interval, limit, true)
+}
 
-		dsm.mu.Lock()
-		status.TotalRequests++
-		dsm.mu.Unlock()
+func (dsm *DataSourceManager) getKlines(symbol, interval string, limit int, skipCached bool) ([]Kline, error) {
+	var klines []Kline
+	err := dsm.withFallback(fmt.Sprintf("%s %s K线", symbol, interval), skipCached, nil, func(source DataSource) error {
+		result, err := source.GetKlines(symbol, interval, limit)
+		if err != nil {
+			return err
+		}
+		if len(result) == 0 {
+			return fmt.Errorf("K线数据为空")
+		}
+		klines = result
+		return nil
+	})
+	return klines, err
+}
 
-		if err == nil && ticker != nil {
-			return ticker, nil
+// GetTickerWithFallback 获取ticker数据（带故障转移）
+func (dsm *DataSourceManager) GetTickerWithFallback(symbol string) (*Ticker, error) {
+	var ticker *Ticker
+	err := dsm.withFallback(symbol+" ticker", false, nil, func(source DataSource) error {
+		result, err := source.GetTicker(symbol)
+		if err != nil {
+			return err
+		}
+		if result == nil {
+			return fmt.Errorf("ticker数据为空")
 		}
+		ticker = result
+		return nil
+	})
+	return ticker, err
+}
 
-		lastErr = err
+// GetOpenInterestWithFallback 获取持仓量（仅使用实现了 OpenInterestSource 的数据源）
+func (dsm *DataSourceManager) GetOpenInterestWithFallback(symbol string) (*OIData, error) {
+	var oiData *OIData
+	supports := func(source DataSource) bool {
+		_, ok := source.(OpenInterestSource)
+		return ok
 	}
+	err := dsm.withFallback(symbol+" 持仓量", false, supports, func(source DataSource) error {
+		result, err := source.(OpenInterestSource).GetOpenInterest(symbol)
+		if err != nil {
+			return err
+		}
+		oiData = result
+		return nil
+	})
+	return oiData, err
+}
 
-	return nil, fmt.Errorf("所有数据源都失败: %w", lastErr)
+// GetFundingRateWithFallback 获取资金费率（仅使用实现了 FundingRateSource 的数据源）
+func (dsm *DataSourceManager) GetFundingRateWithFallback(symbol string) (float64, error) {
+	var rate float64
+	supports := func(source DataSource) bool {
+		_, ok := source.(FundingRateSource)
+		return ok
+	}
+	err := dsm.withFallback(symbol+" 资金费率", false, supports, func(source DataSource) error {
+		result, err := source.(FundingRateSource).GetFundingRate(symbol)
+		if err != nil {
+			return err
+		}
+		rate = result
+		return nil
+	})
+	return rate, err
 }
 
 // GetStatus 获取所有数据源的状态
@@ -258,6 +357,7 @@ func (dsm *DataSourceManager) GetStatus() map[string]*DataSourceStatus {
 			FailureCount:  status.FailureCount,
 			SuccessCount:  status.SuccessCount,
 			TotalRequests: status.TotalRequests,
+			LastError:     status.LastError,
 		}
 	}
 
@@ -325,3 +425,38 @@ func abs(x float64) float64 {
 	}
 	return x
 }
+
+// priceConsistencyMaxDeviation 多数据源价格允许的最大偏差（超过时本周期跳过该币种）
+const priceConsistencyMaxDeviation = 0.01
+
+// checkPriceConsistency 校验多数据源价格一致性，偏差过大时返回错误（数据源不足时不校验）
+func checkPriceConsistency(dsm *DataSourceManager, symbol string) error {
+	consistent, prices, err := dsm.VerifyPriceConsistency(symbol, priceConsistencyMaxDeviation)
+	if err != nil || consistent {
+		return nil
+	}
+	return fmt.Errorf("%s 多数据源价格不一致 %v，本周期跳过", symbol, prices)
+}
+
+// CheckPriceConsistency 使用全局数据源管理器校验多数据源价格一致性
+// 只用于决策时筛选候选币种，不能用于平仓、止损止盈等退出操作（价格错位时恰恰需要能退出）
+func CheckPriceConsistency(symbol string) error {
+	return checkPriceConsistency(DefaultSourceManager(), Normalize(symbol))
+}
+
+var (
+	defaultSourceManager     *DataSourceManager
+	defaultSourceManagerOnce sync.Once
+)
+
+// DefaultSourceManager 获取全局数据源管理器（首次调用时注册默认数据源）
+// 顺序：币安WebSocket（主）→ 币安REST → Hyperliquid（独立场所，用于兜底与价格交叉校验）
+func DefaultSourceManager() *DataSourceManager {
+	defaultSourceManagerOnce.Do(func() {
+		defaultSourceManager = NewDataSourceManager(30 * time.Second)
+		defaultSourceManager.AddSource(NewBinanceWSSource())
+		defaultSourceManager.AddSource(NewBinanceAPISource())
+		defaultSourceManager.AddSource(SourceForExchange("hyperliquid", false))
+	})
+	return defaultSourceManager
+}
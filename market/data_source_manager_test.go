@@ -491,3 +491,186 @@ func TestStartStop(t *testing.T) {
 
 	t.Logf("✅ Start/Stop cycle completed successfully")
 }
+
+// mockCachedSource is a MockDataSource flagged as cache-backed (like the WebSocket source)
+type mockCachedSource struct {
+	MockDataSource
+}
+
+func (m *mockCachedSource) cached() bool {
+	return true
+}
+
+// mockFullSource is a MockDataSource that also provides open interest and funding rate
+type mockFullSource struct {
+	MockDataSource
+	oi      float64
+	funding float64
+}
+
+func (m *mockFullSource) GetOpenInterest(symbol string) (*OIData, error) {
+	return &OIData{Latest: m.oi}, nil
+}
+
+func (m *mockFullSource) GetFundingRate(symbol string) (float64, error) {
+	return m.funding, nil
+}
+
+// TestGetKlinesWithFallback_MarksUnhealthy tests that repeated request failures mark a source unhealthy
+func TestGetKlinesWithFallback_MarksUnhealthy(t *testing.T) {
+	dsm := NewDataSourceManager(10 * time.Second)
+
+	primary := &MockDataSource{name: "primary", healthy: true, failKlines: true}
+	backup := &MockDataSource{name: "backup", healthy: true, klinesData: []Kline{{Close: 1}}}
+	dsm.AddSource(primary)
+	dsm.AddSource(backup)
+
+	for i := 0; i < requestFailureThreshold; i++ {
+		if _, err := dsm.GetKlinesWithFallback("BTCUSDT", "3m", 10); err != nil {
+			t.Fatalf("fallback should succeed: %v", err)
+		}
+	}
+
+	status := dsm.GetStatus()["primary"]
+	if status.Healthy || status.LastError == "" {
+		t.Errorf("primary should be unhealthy after %d failures: %+v", requestFailureThreshold, status)
+	}
+
+	// Unhealthy primary is skipped without being requested
+	dsm.GetKlinesWithFallback("BTCUSDT", "3m", 10)
+	if got := dsm.GetStatus()["primary"].TotalRequests; got != requestFailureThreshold {
+		t.Errorf("unhealthy primary should be skipped, requests=%d", got)
+	}
+	if got := dsm.GetStatus()["backup"].SuccessCount; got != requestFailureThreshold+1 {
+		t.Errorf("backup should serve all requests, successes=%d", got)
+	}
+
+	// A successful health check restores the primary
+	primary.failKlines = false
+	dsm.performHealthCheck()
+	if !dsm.GetStatus()["primary"].Healthy {
+		t.Error("primary should be healthy after health check")
+	}
+}
+
+// TestGetKlinesWithFallback_AllUnhealthy tests that unhealthy sources are still tried as a last resort
+func TestGetKlinesWithFallback_AllUnhealthy(t *testing.T) {
+	dsm := NewDataSourceManager(10 * time.Second)
+
+	mock1 := &MockDataSource{name: "source1", healthy: false, klinesData: []Kline{{Close: 1}}}
+	dsm.AddSource(mock1)
+	dsm.performHealthCheck()
+
+	klines, err := dsm.GetKlinesWithFallback("BTCUSDT", "3m", 10)
+	if err != nil || len(klines) != 1 {
+		t.Errorf("should fall back to unhealthy source: %v %v", klines, err)
+	}
+}
+
+// TestGetFreshKlinesWithFallback_SkipsCached tests that fresh reads bypass cache-backed sources
+func TestGetFreshKlinesWithFallback_SkipsCached(t *testing.T) {
+	dsm := NewDataSourceManager(10 * time.Second)
+
+	cache := &mockCachedSource{MockDataSource{name: "cache", healthy: true, klinesData: []Kline{{Close: 1}}}}
+	api := &MockDataSource{name: "api", healthy: true, klinesData: []Kline{{Close: 2}}}
+	dsm.AddSource(cache)
+	dsm.AddSource(api)
+
+	if klines, _ := dsm.GetKlinesWithFallback("BTCUSDT", "3m", 10); klines[0].Close != 1 {
+		t.Errorf("regular read should use cache source first, got %v", klines)
+	}
+	if klines, _ := dsm.GetFreshKlinesWithFallback("BTCUSDT", "3m", 10); klines[0].Close != 2 {
+		t.Errorf("fresh read should skip cache source, got %v", klines)
+	}
+}
+
+// TestOpenInterestAndFundingWithFallback tests that only capable sources are used
+func TestOpenInterestAndFundingWithFallback(t *testing.T) {
+	dsm := NewDataSourceManager(10 * time.Second)
+
+	dsm.AddSource(&MockDataSource{name: "klines-only", healthy: true})
+	dsm.AddSource(&mockFullSource{MockDataSource: MockDataSource{name: "full", healthy: true}, oi: 1234, funding: 0.0003})
+
+	oi, err := dsm.GetOpenInterestWithFallback("BTCUSDT")
+	if err != nil || oi.Latest != 1234 {
+		t.Errorf("open interest should come from capable source: %+v %v", oi, err)
+	}
+	rate, err := dsm.GetFundingRateWithFallback("BTCUSDT")
+	if err != nil || rate != 0.0003 {
+		t.Errorf("funding rate should come from capable source: %v %v", rate, err)
+	}
+	if got := dsm.GetStatus()["klines-only"].TotalRequests; got != 0 {
+		t.Errorf("incapable source should not be requested, requests=%d", got)
+	}
+
+	empty := NewDataSourceManager(10 * time.Second)
+	empty.AddSource(&MockDataSource{name: "klines-only", healthy: true})
+	if _, err := empty.GetFundingRateWithFallback("BTCUSDT"); err == nil {
+		t.Error("expected error without funding rate source")
+	}
+}
+
+// TestCheckPriceConsistency tests skipping symbols when sources disagree
+func TestCheckPriceConsistency(t *testing.T) {
+	dsm := NewDataSourceManager(10 * time.Second)
+	dsm.AddSource(&MockDataSource{name: "source1", healthy: true, tickerData: &Ticker{LastPrice: 50000}})
+	dsm.AddSource(&MockDataSource{name: "source2", healthy: true, tickerData: &Ticker{LastPrice: 50100}})
+	if err := checkPriceConsistency(dsm, "BTCUSDT"); err != nil {
+		t.Errorf("consistent prices should pass: %v", err)
+	}
+
+	dsm.AddSource(&MockDataSource{name: "source3", healthy: true, tickerData: &Ticker{LastPrice: 60000}})
+	if err := checkPriceConsistency(dsm, "BTCUSDT"); err == nil {
+		t.Error("inconsistent prices should skip symbol")
+	}
+
+	single := NewDataSourceManager(10 * time.Second)
+	single.AddSource(&MockDataSource{name: "source1", healthy: true, tickerData: &Ticker{LastPrice: 50000}})
+	if err := checkPriceConsistency(single, "BTCUSDT"); err != nil {
+		t.Errorf("insufficient sources should not block: %v", err)
+	}
+}
+
+// TestBinanceWSSource tests stale WebSocket detection
+func TestBinanceWSSource(t *testing.T) {
+	original := WSMonitorCli
+	defer func() { WSMonitorCli = original }()
+
+	source := NewBinanceWSSource()
+	WSMonitorCli = nil
+	if _, err := source.GetKlines("BTCUSDT", "3m", 10); err == nil {
+		t.Error("expected error when monitor is not running")
+	}
+	if err := source.HealthCheck(); err == nil {
+		t.Error("health check should fail when monitor is not running")
+	}
+
+	monitor := &WSMonitor{}
+	WSMonitorCli = monitor
+	klines := []Kline{{OpenTime: 1, Close: 100}, {OpenTime: 2, Close: 101}, {OpenTime: 3, Close: 102}}
+	monitor.klines.store("BTCUSDT", "3m", klines, time.Now())
+	monitor.klines.store("BTCUSDT", "1m", klines, time.Now().Add(-time.Hour))
+
+	got, err := source.GetKlines("btcusdt", "3m", 2)
+	if err != nil || len(got) != 2 || got[1].Close != 102 {
+		t.Errorf("fresh cache should be served: %v %v", got, err)
+	}
+	if _, err := source.GetKlines("BTCUSDT", "1m", 10); err == nil {
+		t.Error("stale cache should trigger failover")
+	}
+	if ticker, err := source.GetTicker("BTCUSDT"); err != nil || ticker.LastPrice != 102 {
+		t.Errorf("ticker should skip stale 1m and use 3m: %+v %v", ticker, err)
+	}
+
+	if err := source.HealthCheck(); err == nil {
+		t.Error("health check should fail before any push")
+	}
+	monitor.lastMessageAt.Store(time.Now().UnixNano())
+	if err := source.HealthCheck(); err != nil {
+		t.Errorf("health check should pass after push: %v", err)
+	}
+	monitor.lastMessageAt.Store(time.Now().Add(-wsHealthMaxSilence - time.Second).UnixNano())
+	if err := source.HealthCheck(); err == nil {
+		t.Error("health check should fail after silence")
+	}
+}
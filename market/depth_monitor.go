@@ -0,0 +1,310 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	depthSnapshotLimit   = 1000             // 本地盘口初始化快照档位数
+	depthFallbackLimit   = 500              // 未维护本地盘口时一次性快照档位数
+	depthStreamSpeed     = "500ms"          // 增量深度推送频率
+	orderBookMaxAge      = 30 * time.Second // 超过该时长未更新的本地盘口视为过期
+	depthResyncRetries   = 3
+	depthResyncRetryWait = time.Second
+	depthResyncInterval  = 5 * time.Second // 同步失败后再次尝试的最小间隔
+	depthConnectBackoff  = time.Minute     // 连接失败后的重试间隔（避免每个币种都等待连接超时）
+)
+
+var (
+	defaultDepthMonitor     *DepthMonitor
+	defaultDepthMonitorOnce sync.Once
+)
+
+// DefaultDepthMonitor 全局盘口监控（首次订阅时连接 WebSocket）
+func DefaultDepthMonitor() *DepthMonitor {
+	defaultDepthMonitorOnce.Do(func() {
+		apiClient := NewAPIClient()
+		defaultDepthMonitor = NewDepthMonitor(func(symbol string) (*DepthSnapshot, error) {
+			return apiClient.GetOrderBook(symbol, depthSnapshotLimit)
+		})
+	})
+	return defaultDepthMonitor
+}
+
+// trackedBook 被引用的本地盘口
+type trackedBook struct {
+	book       *OrderBook
+	refs       int
+	resyncing  bool
+	lastResync time.Time
+}
+
+// DepthMonitor 订阅增量深度流并为交易中的币种维护本地订单簿
+type DepthMonitor struct {
+	mu        sync.Mutex
+	client    *CombinedStreamsClient
+	connected bool
+	failedAt  time.Time // 最近一次连接失败时间
+	books     map[string]*trackedBook
+	snapshot  func(symbol string) (*DepthSnapshot, error)
+}
+
+// NewDepthMonitor 创建盘口监控，snapshot 用于获取 REST 深度快照
+func NewDepthMonitor(snapshot func(symbol string) (*DepthSnapshot, error)) *DepthMonitor {
+	return &DepthMonitor{
+		books:    make(map[string]*trackedBook),
+		snapshot: snapshot,
+	}
+}
+
+// depthStreamName 增量深度组合流名称
+func depthStreamName(symbol string) string {
+	return fmt.Sprintf("%s@depth@%s", strings.ToLower(symbol), depthStreamSpeed)
+}
+
+// ensureConnected 首次使用时建立组合流连接（调用方持锁）
+func (m *DepthMonitor) ensureConnected() error {
+	if m.connected {
+		return nil
+	}
+	if time.Since(m.failedAt) < depthConnectBackoff {
+		return fmt.Errorf("深度流连接失败，%s 后重试", depthConnectBackoff)
+	}
+	if m.client == nil {
+		m.client = NewCombinedStreamsClient(50)
+	}
+	if err := m.client.Connect(); err != nil {
+		m.failedAt = time.Now()
+		return err
+	}
+	m.connected = true
+	return nil
+}
+
+// Acquire 增加币种盘口引用，首次引用时订阅深度流并拉取快照
+func (m *DepthMonitor) Acquire(symbol string) error {
+	symbol = Normalize(symbol)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if tracked, ok := m.books[symbol]; ok {
+		tracked.refs++
+		return nil
+	}
+	if err := m.ensureConnected(); err != nil {
+		return fmt.Errorf("连接深度流失败: %w", err)
+	}
+
+	tracked := &trackedBook{book: NewOrderBook(symbol), refs: 1, resyncing: true}
+	m.books[symbol] = tracked
+
+	stream := depthStreamName(symbol)
+	ch := m.client.AddSubscriber(stream, 1000)
+	go m.handleDepthEvents(symbol, tracked, ch)
+	if err := m.client.subscribeStreams([]string{stream}); err != nil {
+		m.client.RemoveSubscriber(stream)
+		delete(m.books, symbol)
+		return fmt.Errorf("订阅 %s 深度流失败: %w", symbol, err)
+	}
+
+	// 先订阅再拉取快照，快照到达前的增量事件会被缓存
+	go m.resync(symbol, tracked)
+	return nil
+}
+
+// Release 减少币种盘口引用，引用为0时取消订阅并丢弃本地盘口
+func (m *DepthMonitor) Release(symbol string) {
+	symbol = Normalize(symbol)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tracked, ok := m.books[symbol]
+	if !ok {
+		return
+	}
+	tracked.refs--
+	if tracked.refs > 0 {
+		return
+	}
+
+	delete(m.books, symbol)
+	if m.client != nil {
+		stream := depthStreamName(symbol)
+		m.client.RemoveSubscriber(stream)
+		if err := m.client.unsubscribeStreams([]string{stream}); err != nil {
+			log.Printf("⚠️  取消订阅 %s 深度流失败: %v", symbol, err)
+		}
+	}
+}
+
+// handleDepthEvents 处理增量深度推送
+func (m *DepthMonitor) handleDepthEvents(symbol string, tracked *trackedBook, ch <-chan []byte) {
+	for data := range ch {
+		var ev depthEvent
+		if err := json.Unmarshal(data, &ev); err != nil {
+			log.Printf("解析 %s 深度数据失败: %v", symbol, err)
+			continue
+		}
+		m.applyEvent(symbol, tracked, ev)
+	}
+}
+
+// applyEvent 应用增量事件，事件不连续或上次同步失败时重新同步
+func (m *DepthMonitor) applyEvent(symbol string, tracked *trackedBook, ev depthEvent) {
+	err := tracked.book.ApplyEvent(ev)
+	if err == nil && tracked.book.Synced() {
+		return
+	}
+
+	m.mu.Lock()
+	start := !tracked.resyncing && time.Since(tracked.lastResync) >= depthResyncInterval
+	if start {
+		tracked.resyncing = true
+	}
+	m.mu.Unlock()
+	if start {
+		if err == errOrderBookGap {
+			log.Printf("⚠️  %s 盘口增量事件不连续，重新同步", symbol)
+		}
+		go m.resync(symbol, tracked)
+	}
+}
+
+// resync 拉取快照并与缓存的增量事件衔接
+func (m *DepthMonitor) resync(symbol string, tracked *trackedBook) {
+	defer func() {
+		m.mu.Lock()
+		tracked.resyncing = false
+		tracked.lastResync = time.Now()
+		m.mu.Unlock()
+	}()
+
+	for attempt := 1; attempt <= depthResyncRetries; attempt++ {
+		snapshot, err := m.snapshot(symbol)
+		if err == nil {
+			if err = tracked.book.ApplySnapshot(snapshot); err == nil {
+				return
+			}
+		}
+		if attempt < depthResyncRetries {
+			time.Sleep(depthResyncRetryWait)
+		} else {
+			log.Printf("❌ 同步 %s 盘口失败: %v", symbol, err)
+		}
+	}
+}
+
+// Metrics 读取本地盘口指标（未维护、未同步或已过期时返回 false）
+func (m *DepthMonitor) Metrics(symbol string, sizeUSD float64) (*OrderBookMetrics, bool) {
+	m.mu.Lock()
+	tracked, ok := m.books[Normalize(symbol)]
+	m.mu.Unlock()
+	if !ok || !tracked.book.Synced() || time.Since(tracked.book.UpdatedAt()) > orderBookMaxAge {
+		return nil, false
+	}
+	return tracked.book.Metrics(sizeUSD)
+}
+
+// Tracked 当前维护的币种数
+func (m *DepthMonitor) Tracked() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.books)
+}
+
+// Stop 关闭深度流连接
+func (m *DepthMonitor) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.client != nil && m.connected {
+		m.client.Close()
+		m.connected = false
+	}
+}
+
+// GetOrderBookMetrics 获取盘口指标：优先使用本地维护的盘口，否则一次性拉取 REST 快照
+func GetOrderBookMetrics(symbol string, sizeUSD float64) (*OrderBookMetrics, error) {
+	symbol = Normalize(symbol)
+	if metrics, ok := DefaultDepthMonitor().Metrics(symbol, sizeUSD); ok {
+		return metrics, nil
+	}
+
+	snapshot, err := NewAPIClient().GetOrderBook(symbol, depthFallbackLimit)
+	if err != nil {
+		return nil, err
+	}
+	metrics, ok := metricsFromSnapshot(symbol, snapshot, sizeUSD)
+	if !ok {
+		return nil, fmt.Errorf("%s 盘口为空", symbol)
+	}
+	return metrics, nil
+}
+
+// symbolTracker 按币种引用计数的行情订阅
+type symbolTracker interface {
+	Acquire(symbol string) error
+	Release(symbol string)
+}
+
+// symbolLease 交易员持有的币种订阅集合
+type symbolLease struct {
+	mu      sync.Mutex
+	tracker symbolTracker
+	symbols map[string]bool
+}
+
+// OrderBookLease 交易员持有的盘口订阅集合（按币种引用计数，交易员停止后释放）
+type OrderBookLease struct {
+	symbolLease
+}
+
+// NewOrderBookLease 创建盘口订阅集合
+func NewOrderBookLease(monitor *DepthMonitor) *OrderBookLease {
+	return &OrderBookLease{symbolLease{tracker: monitor, symbols: make(map[string]bool)}}
+}
+
+// Update 将订阅集合更新为 symbols
+func (l *symbolLease) Update(symbols []string) {
+	wanted := make(map[string]bool, len(symbols))
+	for _, symbol := range symbols {
+		wanted[Normalize(symbol)] = true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for symbol := range l.symbols {
+		if !wanted[symbol] {
+			l.tracker.Release(symbol)
+			delete(l.symbols, symbol)
+		}
+	}
+	for symbol := range wanted {
+		if l.symbols[symbol] {
+			continue
+		}
+		if err := l.tracker.Acquire(symbol); err != nil {
+			log.Printf("⚠️  %v", err)
+			break // 连接不可用时其余币种同样会失败
+		}
+		l.symbols[symbol] = true
+	}
+}
+
+// Release 释放全部引用
+func (l *symbolLease) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for symbol := range l.symbols {
+		l.tracker.Release(symbol)
+		delete(l.symbols, symbol)
+	}
+}
@@ -0,0 +1,230 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeHyperliquidServer 模拟 Hyperliquid info API
+func fakeHyperliquidServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Type string `json:"type"`
+			Req  struct {
+				Coin     string `json:"coin"`
+				Interval string `json:"interval"`
+			} `json:"req"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		switch req.Type {
+		case "candleSnapshot":
+			if req.Req.Coin != "ETH" {
+				w.Write([]byte(`[]`))
+				return
+			}
+			var candles []map[string]interface{}
+			now := time.Now().UnixMilli()
+			for i := 0; i < 120; i++ {
+				price := 3000 + float64(i)
+				candles = append(candles, map[string]interface{}{
+					"t": now - int64(120-i)*60000, "T": now - int64(119-i)*60000 - 1,
+					"s": "ETH", "i": req.Req.Interval,
+					"o": fmt.Sprint(price - 1), "c": fmt.Sprint(price),
+					"h": fmt.Sprint(price + 2), "l": fmt.Sprint(price - 2),
+					"v": "10", "n": 5,
+				})
+			}
+			json.NewEncoder(w).Encode(candles)
+		case "metaAndAssetCtxs":
+			w.Write([]byte(`[{"universe":[{"name":"BTC"},{"name":"ETH"}]},[
+				{"funding":"0.00001","openInterest":"100","markPx":"65000","midPx":"65001"},
+				{"funding":"0.0000125","openInterest":"20000","markPx":"3119","midPx":"3119.5","dayNtlVlm":"1000000"}]]`))
+		case "allMids":
+			w.Write([]byte(`{"BTC":"65001","ETH":"3119.5"}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHyperliquidSource(t *testing.T) {
+	source := newHyperliquidSourceWithURL(fakeHyperliquidServer(t).URL)
+
+	klines, err := source.GetKlines("ETHUSDT", "1h", 100)
+	if err != nil {
+		t.Fatalf("获取K线失败: %v", err)
+	}
+	if len(klines) != 100 || klines[len(klines)-1].Close != 3119 || klines[0].Volume != 10 {
+		t.Errorf("K线解析错误: %d %+v", len(klines), klines[len(klines)-1])
+	}
+	if _, err := source.GetKlines("ETHUSDT", "7m", 10); err == nil {
+		t.Errorf("不支持的周期应返回错误")
+	}
+
+	ticker, err := source.GetTicker("ETHUSDT")
+	if err != nil || ticker.LastPrice != 3119.5 {
+		t.Errorf("应使用中间价: %+v %v", ticker, err)
+	}
+	rate, err := source.GetFundingRate("ETHUSDT")
+	if err != nil || fmt.Sprintf("%.6f", rate) != "0.000100" {
+		t.Errorf("资金费率应换算为8小时费率: %v %v", rate, err)
+	}
+	oi, err := source.GetOpenInterest("ETHUSDT")
+	if err != nil || oi.Latest != 20000 {
+		t.Errorf("持仓量错误: %+v %v", oi, err)
+	}
+	if _, err := source.GetTicker("NOPEUSDT"); err == nil {
+		t.Errorf("不支持的币种应返回错误")
+	}
+	if err := source.HealthCheck(); err != nil {
+		t.Errorf("健康检查失败: %v", err)
+	}
+}
+
+func TestGetFromSource_Hyperliquid(t *testing.T) {
+	source := newHyperliquidSourceWithURL(fakeHyperliquidServer(t).URL)
+
+	data, err := GetFromSource(source, "ethusdt")
+	if err != nil {
+		t.Fatalf("获取市场数据失败: %v", err)
+	}
+	if data.Symbol != "ETHUSDT" || data.CurrentPrice != 3119 {
+		t.Errorf("价格应来自数据源K线: %+v", data)
+	}
+	if data.OpenInterest == nil || data.OpenInterest.Latest != 20000 {
+		t.Errorf("持仓量应来自数据源: %+v", data.OpenInterest)
+	}
+	if data.FundingRate == 0 || data.LongerTermContext == nil || data.DailyContext == nil {
+		t.Errorf("资金费率与长周期数据不应为空: %+v", data)
+	}
+
+	if _, err := GetFromSource(source, "BTCUSDT"); err == nil {
+		t.Errorf("缺少K线时应返回错误")
+	}
+}
+
+func TestAsterSource(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	subscribed := make(chan []string, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/stream":
+			conn, err := upgrader.Upgrade(w, r, nil)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			var msg struct {
+				Params []string `json:"params"`
+			}
+			if conn.ReadJSON(&msg) != nil {
+				return
+			}
+			subscribed <- msg.Params
+			conn.WriteJSON(map[string]interface{}{
+				"stream": "btcusdt@markPrice@1s",
+				"data":   map[string]interface{}{"e": "markPriceUpdate", "s": "BTCUSDT", "p": "64000.5", "r": "0.0002"},
+			})
+			conn.ReadMessage() // 保持连接直到客户端关闭
+		case "/fapi/v3/klines":
+			w.Write([]byte(`[[1700000000000,"100","101","99","100.5","12",1700000059999,"1200",7,"6","600"]]`))
+		case "/fapi/v3/premiumIndex":
+			w.Write([]byte(`{"symbol":"BTCUSDT","markPrice":"63990","lastFundingRate":"0.0001"}`))
+		case "/fapi/v1/openInterest":
+			w.Write([]byte(`{"symbol":"BTCUSDT","openInterest":"5000"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code":-1121,"msg":"Invalid symbol."}`))
+		}
+	}))
+	defer server.Close()
+
+	source := newAsterSourceWithURL(server.URL, "ws"+strings.TrimPrefix(server.URL, "http")+"/stream")
+	defer source.Close()
+
+	klines, err := source.GetKlines("BTCUSDT", "3m", 1)
+	if err != nil || len(klines) != 1 || klines[0].Close != 100.5 || klines[0].Trades != 7 {
+		t.Fatalf("K线解析错误: %+v %v", klines, err)
+	}
+
+	// WebSocket 尚未推送时回退到 REST
+	ticker, err := source.GetTicker("BTCUSDT")
+	if err != nil || ticker.LastPrice != 63990 {
+		t.Errorf("应回退到REST标记价格: %+v %v", ticker, err)
+	}
+	select {
+	case params := <-subscribed:
+		if len(params) != 1 || params[0] != "btcusdt@markPrice@1s" {
+			t.Errorf("订阅流错误: %v", params)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("未订阅标记价格")
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		rate, _ := source.GetFundingRate("BTCUSDT")
+		if rate == 0.0002 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("未使用WebSocket推送的资金费率: %v", rate)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if ticker, _ := source.GetTicker("BTCUSDT"); ticker.LastPrice != 64000.5 {
+		t.Errorf("应使用WebSocket标记价格: %+v", ticker)
+	}
+
+	oi, err := source.GetOpenInterest("BTCUSDT")
+	if err != nil || oi.Latest != 5000 {
+		t.Errorf("持仓量错误: %+v %v", oi, err)
+	}
+	if err := source.get("/fapi/v3/unknown", nil, &struct{}{}); err == nil || !strings.Contains(err.Error(), "-1121") {
+		t.Errorf("应解析API错误: %v", err)
+	}
+}
+
+func TestOITracker_Change4h(t *testing.T) {
+	tracker := newOITracker()
+	start := time.Now().Add(-5 * time.Hour)
+
+	if data := tracker.record("BTCUSDT", 100, start); data.ActualPeriod != "0m" || data.Change4h != 0 {
+		t.Errorf("首个快照无变化率: %+v", data)
+	}
+	tracker.record("BTCUSDT", 110, start.Add(30*time.Second)) // 间隔过短，覆盖上一条
+	data := tracker.record("BTCUSDT", 120, start.Add(2*time.Hour))
+	if data.ActualPeriod != "2.0h" || len(data.Historical) != 2 {
+		t.Errorf("不足4小时应使用最早快照: %+v", data)
+	}
+	data = tracker.record("BTCUSDT", 132, start.Add(6*time.Hour))
+	if data.ActualPeriod != "4h" || fmt.Sprintf("%.1f", data.Change4h) != "10.0" {
+		t.Errorf("应与4小时前的快照比较: %+v", data)
+	}
+}
+
+func TestSourceForExchange(t *testing.T) {
+	if SourceForExchange("binance", false) != nil {
+		t.Errorf("币安应使用既有行情流程")
+	}
+	hl := SourceForExchange("hyperliquid", true)
+	if hl == nil || hl.(*HyperliquidSource).infoURL != hyperliquidTestnetInfoURL {
+		t.Errorf("测试网应使用测试网地址")
+	}
+	if SourceForExchange("hyperliquid", true) != hl {
+		t.Errorf("同一交易所应共享数据源")
+	}
+	if SourceForExchange("aster", false).GetName() != "aster" {
+		t.Errorf("Aster数据源错误")
+	}
+}
@@ -0,0 +1,213 @@
+package market
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	fundingHistoryPoints        = 9               // 保留最近9次结算（8小时周期约3天）
+	fundingInfoTTL              = time.Minute     // 资金费率概况缓存时间（基差与预测费率变化较快）
+	defaultFundingIntervalHours = 8.0             // 币安默认结算周期
+	fundingSpotRetryWait        = 5 * time.Minute // 现货价格获取失败（无现货交易对）后的重试间隔
+)
+
+// PremiumIndex 标记价格、指数价格与预测资金费率（/fapi/v1/premiumIndex）
+type PremiumIndex struct {
+	MarkPrice       float64
+	IndexPrice      float64
+	PredictedRate   float64 // 下次结算的预测资金费率
+	InterestRate    float64
+	NextFundingTime time.Time
+}
+
+// FundingInfo 资金费率概况：历史结算、预测费率、年化成本与期现基差
+type FundingInfo struct {
+	Symbol          string             `json:"symbol"`
+	PredictedRate   float64            `json:"predicted_rate"`    // 下次结算的预测费率（正值多头付费）
+	NextFundingTime time.Time          `json:"next_funding_time"` // 下次结算时间
+	IntervalHours   float64            `json:"interval_hours"`    // 结算周期（小时）
+	History         []FundingRatePoint `json:"history"`           // 最近已结算费率（升序）
+	AverageRate     float64            `json:"average_rate"`      // 历史结算费率均值
+	AnnualizedPct   float64            `json:"annualized_pct"`    // 按预测费率折算的年化资金成本（%，正值多头付费）
+	MarkPrice       float64            `json:"mark_price"`
+	IndexPrice      float64            `json:"index_price"`
+	SpotPrice       float64            `json:"spot_price"`   // 现货价格（无现货交易对时为0）
+	BasisBps        float64            `json:"basis_bps"`    // 期现基差 (mark-spot)/spot（基点），无现货时使用指数价格
+	BasisSource     string             `json:"basis_source"` // 基差参照: "spot" 或 "index"
+	UpdatedAt       time.Time          `json:"updated_at"`
+}
+
+// TimeToFunding 距下次结算的时间
+func (f *FundingInfo) TimeToFunding() time.Duration {
+	if f.NextFundingTime.IsZero() {
+		return 0
+	}
+	return time.Until(f.NextFundingTime)
+}
+
+// AdverseRate 对指定方向不利的预测费率（正值表示该方向需要支付资金费）
+func (f *FundingInfo) AdverseRate(long bool) float64 {
+	if long {
+		return f.PredictedRate
+	}
+	return -f.PredictedRate
+}
+
+// fundingFetcher 资金费率相关的交易所接口
+type fundingFetcher interface {
+	GetPremiumIndex(symbol string) (*PremiumIndex, error)
+	GetSpotPrice(symbol string) (float64, error)
+	GetFundingRateHistory(symbol string, startTime, endTime int64, limit int) ([]FundingRatePoint, error)
+}
+
+// fundingInfoCache 资金费率概况缓存（按币种）
+type fundingInfoCache struct {
+	mu          sync.Mutex
+	entries     map[string]*FundingInfo
+	spotMissing map[string]time.Time // 无现货交易对的币种及发现时间
+}
+
+var defaultFundingCache = &fundingInfoCache{
+	entries:     make(map[string]*FundingInfo),
+	spotMissing: make(map[string]time.Time),
+}
+
+// GetFundingInfo 获取币种的资金费率概况（缓存1分钟）
+func GetFundingInfo(symbol string) (*FundingInfo, error) {
+	return defaultFundingCache.get(NewAPIClient(), Normalize(symbol))
+}
+
+func (c *fundingInfoCache) get(fetcher fundingFetcher, symbol string) (*FundingInfo, error) {
+	c.mu.Lock()
+	if cached, ok := c.entries[symbol]; ok && time.Since(cached.UpdatedAt) < fundingInfoTTL {
+		c.mu.Unlock()
+		return cached, nil
+	}
+	skipSpot := time.Since(c.spotMissing[symbol]) < fundingSpotRetryWait
+	c.mu.Unlock()
+
+	info, err := loadFundingInfo(fetcher, symbol, skipSpot)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.entries[symbol] = info
+	if !skipSpot && info.SpotPrice <= 0 {
+		c.spotMissing[symbol] = time.Now()
+	}
+	c.mu.Unlock()
+	return info, nil
+}
+
+// loadFundingInfo 从交易所加载资金费率概况；现货价格获取失败时基差改用指数价格
+func loadFundingInfo(fetcher fundingFetcher, symbol string, skipSpot bool) (*FundingInfo, error) {
+	premium, err := fetcher.GetPremiumIndex(symbol)
+	if err != nil {
+		return nil, fmt.Errorf("获取 %s 预测资金费率失败: %w", symbol, err)
+	}
+
+	info := &FundingInfo{
+		Symbol:          symbol,
+		PredictedRate:   premium.PredictedRate,
+		NextFundingTime: premium.NextFundingTime,
+		MarkPrice:       premium.MarkPrice,
+		IndexPrice:      premium.IndexPrice,
+		IntervalHours:   defaultFundingIntervalHours,
+		UpdatedAt:       time.Now(),
+	}
+
+	history, err := fetcher.GetFundingRateHistory(symbol, 0, 0, fundingHistoryPoints)
+	if err != nil {
+		log.Printf("⚠️  获取 %s 资金费率历史失败: %v", symbol, err)
+	} else {
+		sort.Slice(history, func(i, j int) bool { return history[i].Timestamp.Before(history[j].Timestamp) })
+		info.History = history
+		if store := GetHistoryStore(); store != nil && len(history) > 0 {
+			if err := store.SaveFundingRates(symbol, history); err != nil {
+				log.Printf("⚠️  保存 %s 资金费率历史失败: %v", symbol, err)
+			}
+		}
+	}
+	if len(info.History) > 0 {
+		sum := 0.0
+		for _, p := range info.History {
+			sum += p.Rate
+		}
+		info.AverageRate = sum / float64(len(info.History))
+	}
+	if hours := fundingIntervalHours(info.History); hours > 0 {
+		info.IntervalHours = hours
+	}
+	info.AnnualizedPct = info.PredictedRate * (24 / info.IntervalHours) * 365 * 100
+
+	if !skipSpot {
+		if spot, err := fetcher.GetSpotPrice(symbol); err == nil {
+			info.SpotPrice = spot
+		}
+	}
+	switch {
+	case info.SpotPrice > 0:
+		info.BasisSource = "spot"
+		info.BasisBps = (info.MarkPrice - info.SpotPrice) / info.SpotPrice * 10000
+	case info.IndexPrice > 0:
+		info.BasisSource = "index"
+		info.BasisBps = (info.MarkPrice - info.IndexPrice) / info.IndexPrice * 10000
+	}
+	return info, nil
+}
+
+// fundingIntervalHours 由最近两次结算的时间间隔推断结算周期（币安部分币种为1h/4h）
+func fundingIntervalHours(history []FundingRatePoint) float64 {
+	if len(history) < 2 {
+		return 0
+	}
+	last := history[len(history)-1].Timestamp
+	prev := history[len(history)-2].Timestamp
+	hours := math.Round(last.Sub(prev).Hours())
+	if hours < 1 || hours > 24 {
+		return 0
+	}
+	return hours
+}
+
+// formatFunding 格式化资金费率概况
+func formatFunding(f *FundingInfo) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Funding: predicted next = %.4f%% (annualized %+.1f%%, every %.0fh)",
+		f.PredictedRate*100, f.AnnualizedPct, f.IntervalHours))
+	if ttf := f.TimeToFunding(); ttf > 0 {
+		sb.WriteString(fmt.Sprintf(" | next settlement in %s", formatCountdown(ttf)))
+	}
+	sb.WriteString("\n")
+	if len(f.History) > 0 {
+		rates := make([]string, len(f.History))
+		for i, p := range f.History {
+			rates[i] = fmt.Sprintf("%.4f%%", p.Rate*100)
+		}
+		sb.WriteString(fmt.Sprintf("Funding history (last %d settlements, oldest → latest): [%s] | average %.4f%%\n",
+			len(f.History), strings.Join(rates, ", "), f.AverageRate*100))
+	}
+	if f.BasisSource != "" {
+		sb.WriteString(fmt.Sprintf("Basis (perp mark vs %s): %+.2f bps\n", f.BasisSource, f.BasisBps))
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// formatCountdown 格式化倒计时，例如 "2h15m"
+func formatCountdown(d time.Duration) string {
+	d = d.Round(time.Minute)
+	h := int(d.Hours())
+	m := int(d.Minutes()) % 60
+	if h > 0 {
+		return fmt.Sprintf("%dh%02dm", h, m)
+	}
+	return fmt.Sprintf("%dm", m)
+}
@@ -0,0 +1,95 @@
+package market
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeFundingFetcher 模拟资金费率相关接口
+type fakeFundingFetcher struct {
+	premium   PremiumIndex
+	spot      float64
+	spotErr   error
+	history   []FundingRatePoint
+	spotCalls int
+}
+
+func (f *fakeFundingFetcher) GetPremiumIndex(symbol string) (*PremiumIndex, error) {
+	p := f.premium
+	return &p, nil
+}
+
+func (f *fakeFundingFetcher) GetSpotPrice(symbol string) (float64, error) {
+	f.spotCalls++
+	return f.spot, f.spotErr
+}
+
+func (f *fakeFundingFetcher) GetFundingRateHistory(symbol string, startTime, endTime int64, limit int) ([]FundingRatePoint, error) {
+	return f.history, nil
+}
+
+func TestLoadFundingInfo(t *testing.T) {
+	now := time.Now().Truncate(time.Hour)
+	fetcher := &fakeFundingFetcher{
+		premium: PremiumIndex{MarkPrice: 100.5, IndexPrice: 100.2, PredictedRate: 0.0002, NextFundingTime: now.Add(2 * time.Hour)},
+		spot:    100,
+		// 4小时结算周期，返回顺序乱序
+		history: []FundingRatePoint{
+			{Rate: 0.0003, Timestamp: now.Add(-4 * time.Hour), Settled: true},
+			{Rate: 0.0001, Timestamp: now.Add(-8 * time.Hour), Settled: true},
+		},
+	}
+
+	info, err := loadFundingInfo(fetcher, "SOLUSDT", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.IntervalHours != 4 || info.History[0].Rate != 0.0001 || math.Abs(info.AverageRate-0.0002) > 1e-12 {
+		t.Errorf("应按升序保存历史并推断4小时结算周期: %+v", info)
+	}
+	// 0.02% × 6次/天 × 365 = 43.8%
+	if math.Abs(info.AnnualizedPct-43.8) > 1e-9 {
+		t.Errorf("年化资金成本错误: %v", info.AnnualizedPct)
+	}
+	if info.BasisSource != "spot" || math.Abs(info.BasisBps-50) > 1e-9 {
+		t.Errorf("应按现货价格计算基差: %s %v", info.BasisSource, info.BasisBps)
+	}
+	if info.AdverseRate(true) <= 0 || info.AdverseRate(false) >= 0 {
+		t.Error("正费率对多头不利、对空头有利")
+	}
+
+	text := formatFunding(info)
+	for _, want := range []string{"predicted next = 0.0200%", "every 4h", "next settlement in", "[0.0100%, 0.0300%]", "Basis (perp mark vs spot): +50.00 bps"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("提示词缺少 %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestFundingInfoCache_SpotFallback(t *testing.T) {
+	cache := &fundingInfoCache{entries: make(map[string]*FundingInfo), spotMissing: make(map[string]time.Time)}
+	fetcher := &fakeFundingFetcher{
+		premium: PremiumIndex{MarkPrice: 10.1, IndexPrice: 10, PredictedRate: -0.0001},
+		spotErr: errors.New("invalid symbol"),
+	}
+
+	info, err := cache.get(fetcher, "NEWUSDT")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.BasisSource != "index" || math.Abs(info.BasisBps-100) > 1e-9 || info.IntervalHours != defaultFundingIntervalHours {
+		t.Errorf("无现货交易对时应使用指数价格计算基差: %+v", info)
+	}
+
+	// 缓存过期后重新加载，短时间内不再请求现货价格
+	info.UpdatedAt = time.Now().Add(-2 * fundingInfoTTL)
+	if _, err := cache.get(fetcher, "NEWUSDT"); err != nil {
+		t.Fatal(err)
+	}
+	if fetcher.spotCalls != 1 {
+		t.Errorf("无现货交易对的币种不应重复请求现货价格: calls=%d", fetcher.spotCalls)
+	}
+}
@@ -0,0 +1,357 @@
+package market
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	backfillPageSize      = 1000          // 每次回填请求的最大条数
+	backfillMaxPages      = 50            // 单个缺口最多回填的页数（防止一次性下载过多数据）
+	fundingSettleInterval = 8 * time.Hour // 资金费率结算周期
+	oiHistoryPeriod       = "15m"         // OI历史回填粒度（与 OIUpdateInterval 对齐）
+)
+
+// ErrHistoryStoreDisabled 未启用本地历史存储
+var ErrHistoryStoreDisabled = errors.New("未启用本地历史数据存储")
+
+// historyFetcher 历史数据回填来源（由 APIClient 实现）
+type historyFetcher interface {
+	GetKlinesRange(symbol, interval string, startTime, endTime int64, limit int) ([]Kline, error)
+	GetOpenInterestHistoryRange(symbol, period string, startTime, endTime int64, limit int) ([]OISnapshot, error)
+	GetFundingRateHistory(symbol string, startTime, endTime int64, limit int) ([]FundingRatePoint, error)
+}
+
+// BackfillKlines 从交易所回填 [start, end] 内缺失的K线，返回回填条数
+func (s *HistoryStore) BackfillKlines(fetcher historyFetcher, symbol, interval string, start, end time.Time) (int, error) {
+	gaps, err := s.KlineGaps(symbol, interval, start, end)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, gap := range gaps {
+		n, err := s.backfillKlineGap(fetcher, symbol, interval, gap)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// backfillKlineGap 分页回填单个缺口
+func (s *HistoryStore) backfillKlineGap(fetcher historyFetcher, symbol, interval string, gap HistoryGap) (int, error) {
+	step, _ := intervalDuration(interval)
+	cursor, endMs := gap.From.UnixMilli(), gap.To.UnixMilli()
+
+	total := 0
+	for page := 0; page < backfillMaxPages && cursor <= endMs; page++ {
+		klines, err := fetcher.GetKlinesRange(symbol, interval, cursor, endMs, backfillPageSize)
+		if err != nil {
+			return total, err
+		}
+
+		// 起始处的缺口：交易所返回的第一根K线之前没有数据（币种上市前），之后不再回填
+		if page == 0 && gap.leading {
+			if len(klines) == 0 {
+				s.markNoDataBefore(symbol, interval, endMs+1)
+			} else if klines[0].OpenTime-cursor >= step.Milliseconds() {
+				s.markNoDataBefore(symbol, interval, klines[0].OpenTime)
+			}
+		}
+		if len(klines) == 0 {
+			break
+		}
+
+		if err := s.SaveKlines(symbol, interval, klines); err != nil {
+			return total, err
+		}
+		total += len(klines)
+
+		if len(klines) < backfillPageSize {
+			break
+		}
+		cursor = klines[len(klines)-1].OpenTime + 1
+	}
+	return total, nil
+}
+
+// SyncKlines 回填最近 limit 根K线的缺口并返回
+// refreshTail 为 true 时同时从交易所刷新未收盘的最新K线（没有实时 WebSocket 数据时使用）
+func (s *HistoryStore) SyncKlines(fetcher historyFetcher, symbol, interval string, limit int, refreshTail bool) ([]Kline, error) {
+	step, ok := intervalDuration(interval)
+	if !ok {
+		return nil, fmt.Errorf("不支持的K线周期: %s", interval)
+	}
+
+	now := time.Now()
+	start := now.Add(-time.Duration(limit+1) * step)
+	filled, backfillErr := s.BackfillKlines(fetcher, symbol, interval, start, now)
+	if backfillErr != nil {
+		log.Printf("⚠️  回填 %s %s K线缺口失败: %v", symbol, interval, backfillErr)
+	}
+
+	// 回填范围截止到当前时间，已包含未收盘K线；否则单独刷新最新两根
+	if refreshTail && filled == 0 && backfillErr == nil {
+		tail, err := fetcher.GetKlinesRange(symbol, interval, 0, 0, 2)
+		if err != nil {
+			log.Printf("⚠️  刷新 %s %s 最新K线失败: %v", symbol, interval, err)
+		} else if err := s.SaveKlines(symbol, interval, tail); err != nil {
+			log.Printf("⚠️  保存 %s %s K线失败: %v", symbol, interval, err)
+		}
+	}
+
+	klines, err := s.QueryKlines(symbol, interval, time.Time{}, time.Time{}, limit)
+	if err != nil {
+		return nil, err
+	}
+	if len(klines) == 0 && backfillErr != nil {
+		return nil, backfillErr
+	}
+	return klines, nil
+}
+
+// SyncOIHistory 返回 since 之后的OI快照，最新快照早于一个采样周期时从交易所回填
+func (s *HistoryStore) SyncOIHistory(fetcher historyFetcher, symbol string, since time.Time) ([]OISnapshot, error) {
+	now := time.Now()
+	stored, err := s.QueryOIHistory(symbol, since, now)
+	if err != nil {
+		return nil, err
+	}
+	if len(stored) > 0 && now.Sub(stored[len(stored)-1].Timestamp) <= OIUpdateInterval {
+		return stored, nil
+	}
+
+	from := since
+	if len(stored) > 0 {
+		from = stored[len(stored)-1].Timestamp.Add(time.Millisecond)
+	}
+	fetched, err := fetcher.GetOpenInterestHistoryRange(symbol, oiHistoryPeriod, from.UnixMilli(), now.UnixMilli(), 500)
+	if err != nil {
+		if len(stored) > 0 {
+			log.Printf("⚠️  回填 %s OI历史失败: %v，使用本地数据", symbol, err)
+			return stored, nil
+		}
+		return nil, err
+	}
+	if err := s.SaveOISnapshots(symbol, fetched); err != nil {
+		return nil, err
+	}
+	return s.QueryOIHistory(symbol, since, now)
+}
+
+// SyncFundingRates 回填 [start, end] 内缺失的已结算资金费率并返回范围内全部数据
+func (s *HistoryStore) SyncFundingRates(fetcher historyFetcher, symbol string, start, end time.Time) ([]FundingRatePoint, error) {
+	points, err := s.QueryFundingRates(symbol, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	var settled []time.Time
+	for _, point := range points {
+		if point.Settled {
+			settled = append(settled, point.Timestamp)
+		}
+	}
+
+	// 仅检测首尾缺口（资金费率按固定周期结算，中间缺口来自停机，也总出现在尾部）
+	var ranges [][2]time.Time
+	switch {
+	case len(settled) == 0:
+		ranges = append(ranges, [2]time.Time{start, end})
+	default:
+		if settled[0].Sub(start) >= fundingSettleInterval {
+			ranges = append(ranges, [2]time.Time{start, settled[0].Add(-time.Millisecond)})
+		}
+		if last := settled[len(settled)-1]; end.Sub(last) >= fundingSettleInterval {
+			ranges = append(ranges, [2]time.Time{last.Add(time.Millisecond), end})
+		}
+	}
+	if len(ranges) == 0 {
+		return points, nil
+	}
+
+	for _, r := range ranges {
+		cursor := r[0].UnixMilli()
+		for page := 0; page < backfillMaxPages; page++ {
+			fetched, err := fetcher.GetFundingRateHistory(symbol, cursor, r[1].UnixMilli(), backfillPageSize)
+			if err != nil {
+				if len(points) > 0 {
+					log.Printf("⚠️  回填 %s 资金费率历史失败: %v，使用本地数据", symbol, err)
+					return points, nil
+				}
+				return nil, err
+			}
+			if err := s.SaveFundingRates(symbol, fetched); err != nil {
+				return nil, err
+			}
+			if len(fetched) < backfillPageSize {
+				break
+			}
+			cursor = fetched[len(fetched)-1].Timestamp.UnixMilli() + 1
+		}
+	}
+	return s.QueryFundingRates(symbol, start, end)
+}
+
+// loadHistoricalKlines 加载最近 limit 根K线（优先本地存储并回填缺口，未启用存储时直接请求交易所）
+func loadHistoricalKlines(fetcher historyFetcher, symbol, interval string, limit int) ([]Kline, error) {
+	if store := GetHistoryStore(); store != nil {
+		klines, err := store.SyncKlines(fetcher, symbol, interval, limit, true)
+		if err == nil && len(klines) > 0 {
+			return klines, nil
+		}
+		if err != nil {
+			log.Printf("⚠️  从本地存储加载 %s %s K线失败: %v，回退到API", symbol, interval, err)
+		}
+	}
+	return fetcher.GetKlinesRange(symbol, interval, 0, 0, limit)
+}
+
+// loadOIHistory 加载最近的OI历史快照（优先本地存储并回填缺口）
+func loadOIHistory(fetcher historyFetcher, symbol string) ([]OISnapshot, error) {
+	if store := GetHistoryStore(); store != nil {
+		since := time.Now().Add(-OIHistoryMaxSize * OIUpdateInterval)
+		history, err := store.SyncOIHistory(fetcher, symbol, since)
+		if err == nil && len(history) > 0 {
+			if len(history) > OIHistoryMaxSize {
+				history = history[len(history)-OIHistoryMaxSize:]
+			}
+			return history, nil
+		}
+		if err != nil {
+			log.Printf("⚠️  从本地存储加载 %s OI历史失败: %v，回退到API", symbol, err)
+		}
+	}
+	return fetcher.GetOpenInterestHistoryRange(symbol, oiHistoryPeriod, 0, 0, OIHistoryMaxSize)
+}
+
+// GetHistoricalKlines 获取最近 limit 根K线
+// 历史部分来自本地存储（自动回填缺口），最新K线优先使用 WebSocket 实时缓存；未启用存储时直接请求交易所
+func GetHistoricalKlines(symbol, interval string, limit int) ([]Kline, error) {
+	symbol = Normalize(symbol)
+	if !IsValidInterval(interval) {
+		return nil, fmt.Errorf("不支持的K线周期: %s", interval)
+	}
+
+	apiClient := NewAPIClient()
+	store := GetHistoryStore()
+	if store == nil {
+		return apiClient.GetKlines(symbol, interval, limit)
+	}
+
+	live, hasLive := liveKlines(symbol, interval)
+	klines, err := store.SyncKlines(apiClient, symbol, interval, limit, !hasLive)
+	if err != nil || len(klines) == 0 {
+		if err != nil {
+			log.Printf("⚠️  从本地存储加载 %s %s K线失败: %v，回退到API", symbol, interval, err)
+		}
+		return apiClient.GetKlines(symbol, interval, limit)
+	}
+	if hasLive {
+		klines = mergeKlines(klines, live, limit)
+	}
+	return klines, nil
+}
+
+// EstimateKlineCount 估算时间范围内的K线数量（end 晚于当前时间时按当前时间计算，用于回填前限制请求规模）
+func EstimateKlineCount(interval string, start, end time.Time) int {
+	duration, ok := intervalDuration(interval)
+	if !ok {
+		return 0
+	}
+	if now := time.Now(); end.IsZero() || end.After(now) {
+		end = now
+	}
+	if !start.Before(end) {
+		return 0
+	}
+	return int(end.Sub(start)/duration) + 1
+}
+
+// GetHistoricalKlinesRange 按开盘时间范围获取K线（自动回填缺口，供离线回测使用）
+func GetHistoricalKlinesRange(symbol, interval string, start, end time.Time) ([]Kline, error) {
+	store := GetHistoryStore()
+	if store == nil {
+		return nil, ErrHistoryStoreDisabled
+	}
+	symbol = Normalize(symbol)
+	if end.IsZero() || end.After(time.Now()) {
+		end = time.Now()
+	}
+
+	if _, err := store.BackfillKlines(NewAPIClient(), symbol, interval, start, end); err != nil {
+		log.Printf("⚠️  回填 %s %s K线缺口失败: %v，返回本地已有数据", symbol, interval, err)
+	}
+	return store.QueryKlines(symbol, interval, start, end, 0)
+}
+
+// GetOIHistoryRange 按时间范围获取OI快照（自动回填，交易所仅保留最近30天）
+func GetOIHistoryRange(symbol string, start, end time.Time) ([]OISnapshot, error) {
+	store := GetHistoryStore()
+	if store == nil {
+		return nil, ErrHistoryStoreDisabled
+	}
+	symbol = Normalize(symbol)
+
+	history, err := store.SyncOIHistory(NewAPIClient(), symbol, start)
+	if err != nil {
+		return nil, err
+	}
+	result := history[:0]
+	for _, snapshot := range history {
+		if end.IsZero() || !snapshot.Timestamp.After(end) {
+			result = append(result, snapshot)
+		}
+	}
+	return result, nil
+}
+
+// GetFundingRateHistoryRange 按时间范围获取资金费率（自动回填已结算费率）
+func GetFundingRateHistoryRange(symbol string, start, end time.Time) ([]FundingRatePoint, error) {
+	store := GetHistoryStore()
+	if store == nil {
+		return nil, ErrHistoryStoreDisabled
+	}
+	symbol = Normalize(symbol)
+	if end.IsZero() || end.After(time.Now()) {
+		end = time.Now()
+	}
+	return store.SyncFundingRates(NewAPIClient(), symbol, start, end)
+}
+
+// liveKlines 读取未过期的 WebSocket 实时K线
+func liveKlines(symbol, interval string) ([]Kline, bool) {
+	monitor := WSMonitorCli
+	if monitor == nil {
+		return nil, false
+	}
+	klines, receivedAt, ok := monitor.GetCachedKlines(symbol, interval)
+	if !ok || len(klines) == 0 || time.Since(receivedAt) > wsKlineMaxAge {
+		return nil, false
+	}
+	return klines, true
+}
+
+// mergeKlines 用较新的K线覆盖/追加到历史K线末尾，保留最近 limit 根
+func mergeKlines(history, recent []Kline, limit int) []Kline {
+	if len(recent) == 0 {
+		return history
+	}
+
+	first := recent[0].OpenTime
+	merged := make([]Kline, 0, len(history)+len(recent))
+	for _, k := range history {
+		if k.OpenTime < first {
+			merged = append(merged, k)
+		}
+	}
+	merged = append(merged, recent...)
+	if limit > 0 && len(merged) > limit {
+		merged = merged[len(merged)-limit:]
+	}
+	return merged
+}
@@ -0,0 +1,458 @@
+package market
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// HistoryStore 本地历史行情存储（SQLite）
+// 保存K线、OI快照与资金费率，重启后无需重新下载，并支持按时间范围查询（供API、形态分析与离线回测使用）
+type HistoryStore struct {
+	db *sql.DB
+
+	mu           sync.Mutex
+	noDataBefore map[klineKey]int64 // 交易所在该时间之前没有K线（新上市币种），避免重复回填
+}
+
+// HistoryGap 历史数据缺口 [From, To]
+type HistoryGap struct {
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	leading bool      // 位于查询范围起始处（可能是币种上市前）
+}
+
+// HistoryStoreStats 历史存储统计
+type HistoryStoreStats struct {
+	Klines       int `json:"klines"`        // K线条数
+	Series       int `json:"series"`        // (币种, 周期) 数
+	OISnapshots  int `json:"oi_snapshots"`  // OI快照数
+	FundingRates int `json:"funding_rates"` // 资金费率数据点数
+}
+
+var historyStore atomic.Pointer[HistoryStore]
+
+// SetHistoryStore 设置全局历史存储（nil 表示禁用）
+func SetHistoryStore(store *HistoryStore) {
+	historyStore.Store(store)
+}
+
+// GetHistoryStore 获取全局历史存储（未启用时返回 nil）
+func GetHistoryStore() *HistoryStore {
+	return historyStore.Load()
+}
+
+// OpenHistoryStore 打开（不存在时创建）历史行情数据库
+func OpenHistoryStore(path string) (*HistoryStore, error) {
+	if dir := filepath.Dir(path); dir != "." && dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("创建历史数据目录失败: %w", err)
+		}
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("打开历史数据库失败: %w", err)
+	}
+	// SQLite 单写者，串行化访问避免 SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	pragmas := []string{
+		"PRAGMA journal_mode=WAL",
+		"PRAGMA synchronous=NORMAL",
+		"PRAGMA busy_timeout=5000",
+	}
+	for _, pragma := range pragmas {
+		if _, err := db.Exec(pragma); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("设置历史数据库参数失败 (%s): %w", pragma, err)
+		}
+	}
+
+	tables := []string{
+		`CREATE TABLE IF NOT EXISTS klines (
+			symbol TEXT NOT NULL,
+			timeframe TEXT NOT NULL,
+			open_time INTEGER NOT NULL,
+			open REAL NOT NULL,
+			high REAL NOT NULL,
+			low REAL NOT NULL,
+			close REAL NOT NULL,
+			volume REAL NOT NULL,
+			close_time INTEGER NOT NULL,
+			quote_volume REAL NOT NULL DEFAULT 0,
+			trades INTEGER NOT NULL DEFAULT 0,
+			taker_buy_base_volume REAL NOT NULL DEFAULT 0,
+			taker_buy_quote_volume REAL NOT NULL DEFAULT 0,
+			closed INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (symbol, timeframe, open_time)
+		) WITHOUT ROWID`,
+		`CREATE TABLE IF NOT EXISTS open_interest (
+			symbol TEXT NOT NULL,
+			timestamp INTEGER NOT NULL,
+			value REAL NOT NULL,
+			PRIMARY KEY (symbol, timestamp)
+		) WITHOUT ROWID`,
+		`CREATE TABLE IF NOT EXISTS funding_rates (
+			symbol TEXT NOT NULL,
+			timestamp INTEGER NOT NULL,
+			rate REAL NOT NULL,
+			settled INTEGER NOT NULL DEFAULT 0,
+			PRIMARY KEY (symbol, timestamp)
+		) WITHOUT ROWID`,
+	}
+	for _, table := range tables {
+		if _, err := db.Exec(table); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("创建历史数据表失败: %w", err)
+		}
+	}
+
+	return &HistoryStore{db: db, noDataBefore: make(map[klineKey]int64)}, nil
+}
+
+// Close 关闭数据库
+func (s *HistoryStore) Close() error {
+	return s.db.Close()
+}
+
+// SaveKlines 保存K线（已收盘的K线不会被未收盘的数据覆盖）
+func (s *HistoryStore) SaveKlines(symbol, interval string, klines []Kline) error {
+	if len(klines) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO klines (symbol, timeframe, open_time, open, high, low, close, volume, close_time,
+			quote_volume, trades, taker_buy_base_volume, taker_buy_quote_volume, closed)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (symbol, timeframe, open_time) DO UPDATE SET
+			open = excluded.open, high = excluded.high, low = excluded.low, close = excluded.close,
+			volume = excluded.volume, close_time = excluded.close_time, quote_volume = excluded.quote_volume,
+			trades = excluded.trades, taker_buy_base_volume = excluded.taker_buy_base_volume,
+			taker_buy_quote_volume = excluded.taker_buy_quote_volume, closed = excluded.closed
+		WHERE klines.closed = 0 OR excluded.closed = 1
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	nowMs := time.Now().UnixMilli()
+	for _, k := range klines {
+		closed := 0
+		if k.CloseTime > 0 && k.CloseTime < nowMs {
+			closed = 1
+		}
+		if _, err := stmt.Exec(symbol, interval, k.OpenTime, k.Open, k.High, k.Low, k.Close, k.Volume, k.CloseTime,
+			k.QuoteVolume, k.Trades, k.TakerBuyBaseVolume, k.TakerBuyQuoteVolume, closed); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// QueryKlines 按开盘时间范围查询K线（升序；start/end 为零值表示不限；limit>0 时返回范围内最近的 limit 根）
+func (s *HistoryStore) QueryKlines(symbol, interval string, start, end time.Time, limit int) ([]Kline, error) {
+	startMs, endMs := rangeMillis(start, end)
+	query := `
+		SELECT open_time, open, high, low, close, volume, close_time,
+			quote_volume, trades, taker_buy_base_volume, taker_buy_quote_volume
+		FROM klines
+		WHERE symbol = ? AND timeframe = ? AND open_time >= ? AND open_time <= ?
+		ORDER BY open_time DESC`
+	args := []interface{}{symbol, interval, startMs, endMs}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var klines []Kline
+	for rows.Next() {
+		var k Kline
+		if err := rows.Scan(&k.OpenTime, &k.Open, &k.High, &k.Low, &k.Close, &k.Volume, &k.CloseTime,
+			&k.QuoteVolume, &k.Trades, &k.TakerBuyBaseVolume, &k.TakerBuyQuoteVolume); err != nil {
+			return nil, err
+		}
+		klines = append(klines, k)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 倒序查询后翻转为升序
+	for i, j := 0, len(klines)-1; i < j; i, j = i+1, j-1 {
+		klines[i], klines[j] = klines[j], klines[i]
+	}
+	return klines, nil
+}
+
+// KlineGaps 检测 [start, end] 内缺失的已收盘K线
+func (s *HistoryStore) KlineGaps(symbol, interval string, start, end time.Time) ([]HistoryGap, error) {
+	step, ok := intervalDuration(interval)
+	if !ok {
+		return nil, fmt.Errorf("不支持的K线周期: %s", interval)
+	}
+	stepMs := step.Milliseconds()
+	startMs, endMs := start.UnixMilli(), end.UnixMilli()
+
+	rows, err := s.db.Query(`
+		SELECT open_time FROM klines
+		WHERE symbol = ? AND timeframe = ? AND closed = 1 AND open_time >= ? AND open_time <= ?
+		ORDER BY open_time`, symbol, interval, startMs, endMs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var openTimes []int64
+	for rows.Next() {
+		var t int64
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		openTimes = append(openTimes, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var gaps []HistoryGap
+	addGap := func(from, to int64, leading bool) {
+		gaps = append(gaps, HistoryGap{From: time.UnixMilli(from), To: time.UnixMilli(to), leading: leading})
+	}
+
+	if len(openTimes) == 0 {
+		addGap(startMs, endMs, true)
+	} else {
+		if openTimes[0]-startMs >= stepMs {
+			addGap(startMs, openTimes[0]-1, true)
+		}
+		for i := 1; i < len(openTimes); i++ {
+			if openTimes[i]-openTimes[i-1] > stepMs {
+				addGap(openTimes[i-1]+stepMs, openTimes[i]-1, false)
+			}
+		}
+		// 最后一根已收盘K线之后还有完整收盘的K线未保存
+		if last := openTimes[len(openTimes)-1]; last+2*stepMs <= endMs {
+			addGap(last+stepMs, endMs, false)
+		}
+	}
+
+	// 跳过已确认交易所没有数据的时间段（币种上市前）
+	s.mu.Lock()
+	before := s.noDataBefore[klineKey{symbol, interval}]
+	s.mu.Unlock()
+	if before == 0 {
+		return gaps, nil
+	}
+	filtered := gaps[:0]
+	for _, gap := range gaps {
+		if gap.To.UnixMilli() < before {
+			continue
+		}
+		if gap.From.UnixMilli() < before {
+			gap.From = time.UnixMilli(before)
+		}
+		filtered = append(filtered, gap)
+	}
+	return filtered, nil
+}
+
+// SaveOISnapshots 保存OI快照
+func (s *HistoryStore) SaveOISnapshots(symbol string, snapshots []OISnapshot) error {
+	if len(snapshots) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT OR REPLACE INTO open_interest (symbol, timestamp, value) VALUES (?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, snapshot := range snapshots {
+		if _, err := stmt.Exec(symbol, snapshot.Timestamp.UnixMilli(), snapshot.Value); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// QueryOIHistory 按时间范围查询OI快照（升序）
+func (s *HistoryStore) QueryOIHistory(symbol string, start, end time.Time) ([]OISnapshot, error) {
+	startMs, endMs := rangeMillis(start, end)
+	rows, err := s.db.Query(`
+		SELECT timestamp, value FROM open_interest
+		WHERE symbol = ? AND timestamp >= ? AND timestamp <= ?
+		ORDER BY timestamp`, symbol, startMs, endMs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snapshots []OISnapshot
+	for rows.Next() {
+		var ts int64
+		var snapshot OISnapshot
+		if err := rows.Scan(&ts, &snapshot.Value); err != nil {
+			return nil, err
+		}
+		snapshot.Timestamp = time.UnixMilli(ts)
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, rows.Err()
+}
+
+// SaveFundingRates 保存资金费率（已结算的费率不会被实时快照覆盖）
+func (s *HistoryStore) SaveFundingRates(symbol string, points []FundingRatePoint) error {
+	if len(points) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`
+		INSERT INTO funding_rates (symbol, timestamp, rate, settled) VALUES (?, ?, ?, ?)
+		ON CONFLICT (symbol, timestamp) DO UPDATE SET rate = excluded.rate, settled = excluded.settled
+		WHERE funding_rates.settled = 0 OR excluded.settled = 1`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, point := range points {
+		settled := 0
+		if point.Settled {
+			settled = 1
+		}
+		if _, err := stmt.Exec(symbol, point.Timestamp.UnixMilli(), point.Rate, settled); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// QueryFundingRates 按时间范围查询资金费率（升序，包含已结算费率与实时快照）
+func (s *HistoryStore) QueryFundingRates(symbol string, start, end time.Time) ([]FundingRatePoint, error) {
+	startMs, endMs := rangeMillis(start, end)
+	rows, err := s.db.Query(`
+		SELECT timestamp, rate, settled FROM funding_rates
+		WHERE symbol = ? AND timestamp >= ? AND timestamp <= ?
+		ORDER BY timestamp`, symbol, startMs, endMs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var points []FundingRatePoint
+	for rows.Next() {
+		var ts int64
+		var point FundingRatePoint
+		if err := rows.Scan(&ts, &point.Rate, &point.Settled); err != nil {
+			return nil, err
+		}
+		point.Timestamp = time.UnixMilli(ts)
+		points = append(points, point)
+	}
+	return points, rows.Err()
+}
+
+// Stats 获取存储统计
+func (s *HistoryStore) Stats() (HistoryStoreStats, error) {
+	var stats HistoryStoreStats
+	err := s.db.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM klines),
+			(SELECT COUNT(*) FROM (SELECT DISTINCT symbol, timeframe FROM klines)),
+			(SELECT COUNT(*) FROM open_interest),
+			(SELECT COUNT(*) FROM funding_rates)`).
+		Scan(&stats.Klines, &stats.Series, &stats.OISnapshots, &stats.FundingRates)
+	return stats, err
+}
+
+// markNoDataBefore 记录交易所在该时间之前没有K线
+func (s *HistoryStore) markNoDataBefore(symbol, interval string, ms int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ms > s.noDataBefore[klineKey{symbol, interval}] {
+		s.noDataBefore[klineKey{symbol, interval}] = ms
+	}
+}
+
+// rangeMillis 将时间范围转换为毫秒（零值表示不限）
+func rangeMillis(start, end time.Time) (int64, int64) {
+	startMs, endMs := int64(0), int64(1<<62)
+	if !start.IsZero() {
+		startMs = start.UnixMilli()
+	}
+	if !end.IsZero() {
+		endMs = end.UnixMilli()
+	}
+	return startMs, endMs
+}
+
+// intervalDuration K线周期时长（1M 按最长的31天计算）
+func intervalDuration(interval string) (time.Duration, bool) {
+	switch interval {
+	case "1m":
+		return time.Minute, true
+	case "3m":
+		return 3 * time.Minute, true
+	case "5m":
+		return 5 * time.Minute, true
+	case "15m":
+		return 15 * time.Minute, true
+	case "30m":
+		return 30 * time.Minute, true
+	case "1h":
+		return time.Hour, true
+	case "2h":
+		return 2 * time.Hour, true
+	case "4h":
+		return 4 * time.Hour, true
+	case "6h":
+		return 6 * time.Hour, true
+	case "8h":
+		return 8 * time.Hour, true
+	case "12h":
+		return 12 * time.Hour, true
+	case "1d":
+		return 24 * time.Hour, true
+	case "3d":
+		return 72 * time.Hour, true
+	case "1w":
+		return 7 * 24 * time.Hour, true
+	case "1M":
+		return 31 * 24 * time.Hour, true
+	}
+	return 0, false
+}
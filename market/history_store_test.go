@@ -0,0 +1,234 @@
+package market
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeHistoryFetcher 模拟交易所历史数据接口
+type fakeHistoryFetcher struct {
+	klines       []Kline // 交易所全部K线（升序）
+	oi           []OISnapshot
+	funding      []FundingRatePoint
+	klineCalls   int
+	oiCalls      int
+	fundingCalls int
+}
+
+func (f *fakeHistoryFetcher) GetKlinesRange(symbol, interval string, startTime, endTime int64, limit int) ([]Kline, error) {
+	f.klineCalls++
+	var result []Kline
+	for _, k := range f.klines {
+		if startTime > 0 && k.OpenTime < startTime {
+			continue
+		}
+		if endTime > 0 && k.OpenTime > endTime {
+			continue
+		}
+		result = append(result, k)
+	}
+	if startTime == 0 && len(result) > limit {
+		return result[len(result)-limit:], nil
+	}
+	if len(result) > limit {
+		result = result[:limit]
+	}
+	return result, nil
+}
+
+func (f *fakeHistoryFetcher) GetOpenInterestHistoryRange(symbol, period string, startTime, endTime int64, limit int) ([]OISnapshot, error) {
+	f.oiCalls++
+	var result []OISnapshot
+	for _, s := range f.oi {
+		if ms := s.Timestamp.UnixMilli(); ms >= startTime && (endTime == 0 || ms <= endTime) {
+			result = append(result, s)
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeHistoryFetcher) GetFundingRateHistory(symbol string, startTime, endTime int64, limit int) ([]FundingRatePoint, error) {
+	f.fundingCalls++
+	var result []FundingRatePoint
+	for _, p := range f.funding {
+		if ms := p.Timestamp.UnixMilli(); ms >= startTime && ms <= endTime {
+			result = append(result, p)
+		}
+	}
+	return result, nil
+}
+
+// hourlyKlines 生成截止到当前小时（含未收盘K线）的 n 根1小时K线
+func hourlyKlines(n int) []Kline {
+	current := time.Now().Truncate(time.Hour)
+	klines := make([]Kline, n)
+	for i := range klines {
+		open := current.Add(-time.Duration(n-1-i) * time.Hour)
+		klines[i] = Kline{
+			OpenTime:  open.UnixMilli(),
+			CloseTime: open.Add(time.Hour).UnixMilli() - 1,
+			Close:     float64(100 + i),
+		}
+	}
+	return klines
+}
+
+func openTestHistoryStore(t *testing.T) *HistoryStore {
+	t.Helper()
+	store, err := OpenHistoryStore(filepath.Join(t.TempDir(), "history", "market.db"))
+	if err != nil {
+		t.Fatalf("打开历史存储失败: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestHistoryStore_KlineGapsAndBackfill(t *testing.T) {
+	store := openTestHistoryStore(t)
+	all := hourlyKlines(30)
+	fetcher := &fakeHistoryFetcher{klines: all}
+
+	// 本地只有前10根和第15~20根
+	store.SaveKlines("BTCUSDT", "1h", all[:10])
+	store.SaveKlines("BTCUSDT", "1h", all[15:20])
+
+	start, end := time.UnixMilli(all[0].OpenTime), time.Now()
+	gaps, err := store.KlineGaps("BTCUSDT", "1h", start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gaps) != 2 || gaps[0].From.UnixMilli() != all[10].OpenTime || gaps[1].From.UnixMilli() != all[20].OpenTime {
+		t.Fatalf("应检测到中间与尾部两个缺口: %+v", gaps)
+	}
+
+	n, err := store.BackfillKlines(fetcher, "BTCUSDT", "1h", start, end)
+	if err != nil || n != 15 {
+		t.Fatalf("应回填15根K线（含未收盘K线）: n=%d err=%v", n, err)
+	}
+	if gaps, _ := store.KlineGaps("BTCUSDT", "1h", start, end); len(gaps) != 0 {
+		t.Errorf("回填后不应再有缺口: %+v", gaps)
+	}
+
+	klines, err := store.QueryKlines("BTCUSDT", "1h", time.UnixMilli(all[5].OpenTime), time.UnixMilli(all[9].OpenTime), 0)
+	if err != nil || len(klines) != 5 || klines[0].Close != all[5].Close {
+		t.Errorf("按范围查询错误: %+v err=%v", klines, err)
+	}
+	latest, _ := store.QueryKlines("BTCUSDT", "1h", time.Time{}, time.Time{}, 3)
+	if len(latest) != 3 || latest[2].OpenTime != all[29].OpenTime {
+		t.Errorf("limit 应返回最近的K线（升序）: %+v", latest)
+	}
+}
+
+func TestHistoryStore_SyncKlinesAvoidsRedownload(t *testing.T) {
+	store := openTestHistoryStore(t)
+	fetcher := &fakeHistoryFetcher{klines: hourlyKlines(50)}
+
+	klines, err := store.SyncKlines(fetcher, "ETHUSDT", "1h", 20, true)
+	if err != nil || len(klines) != 20 {
+		t.Fatalf("首次同步应返回20根K线: %d err=%v", len(klines), err)
+	}
+
+	// 再次同步：历史已在本地，只刷新未收盘K线
+	fetcher.klineCalls = 0
+	fetcher.klines[len(fetcher.klines)-1].Close = 999
+	klines, err = store.SyncKlines(fetcher, "ETHUSDT", "1h", 20, true)
+	if err != nil || fetcher.klineCalls != 1 {
+		t.Fatalf("已有历史时只应请求最新K线: calls=%d err=%v", fetcher.klineCalls, err)
+	}
+	if klines[len(klines)-1].Close != 999 {
+		t.Errorf("未收盘K线应被刷新: %+v", klines[len(klines)-1])
+	}
+
+	// 已收盘K线不会被覆盖为未收盘状态
+	closed := klines[len(klines)-2]
+	closed.CloseTime = time.Now().Add(time.Hour).UnixMilli()
+	closed.Close = -1
+	store.SaveKlines("ETHUSDT", "1h", []Kline{closed})
+	again, _ := store.QueryKlines("ETHUSDT", "1h", time.Time{}, time.Time{}, 2)
+	if again[0].Close == -1 {
+		t.Error("已收盘K线不应被未收盘数据覆盖")
+	}
+}
+
+func TestHistoryStore_NewListingNotRefetched(t *testing.T) {
+	store := openTestHistoryStore(t)
+	fetcher := &fakeHistoryFetcher{klines: hourlyKlines(5)} // 新上市币种只有5根K线
+
+	klines, err := store.SyncKlines(fetcher, "NEWUSDT", "1h", 100, false)
+	if err != nil || len(klines) != 5 {
+		t.Fatalf("应返回全部5根K线: %d err=%v", len(klines), err)
+	}
+
+	fetcher.klineCalls = 0
+	if _, err := store.SyncKlines(fetcher, "NEWUSDT", "1h", 100, false); err != nil {
+		t.Fatal(err)
+	}
+	if fetcher.klineCalls != 0 {
+		t.Errorf("上市前的时间段不应重复回填: calls=%d", fetcher.klineCalls)
+	}
+}
+
+func TestHistoryStore_OIAndFunding(t *testing.T) {
+	store := openTestHistoryStore(t)
+	now := time.Now()
+	fetcher := &fakeHistoryFetcher{}
+	for i := 20; i >= 1; i-- {
+		fetcher.oi = append(fetcher.oi, OISnapshot{Value: float64(1000 + i), Timestamp: now.Add(-time.Duration(i) * 15 * time.Minute)})
+	}
+	for i := 9; i >= 1; i-- {
+		fetcher.funding = append(fetcher.funding, FundingRatePoint{Rate: 0.0001 * float64(i), Timestamp: now.Add(-time.Duration(i) * 8 * time.Hour), Settled: true})
+	}
+
+	since := now.Add(-5 * time.Hour)
+	history, err := store.SyncOIHistory(fetcher, "BTCUSDT", since)
+	if err != nil || len(history) != 20 {
+		t.Fatalf("应回填20个OI快照: %d err=%v", len(history), err)
+	}
+	// 新采集的快照落盘后无需再次回填
+	store.SaveOISnapshots("BTCUSDT", []OISnapshot{{Value: 2000, Timestamp: now}})
+	fetcher.oiCalls = 0
+	if history, _ = store.SyncOIHistory(fetcher, "BTCUSDT", since); len(history) != 21 || fetcher.oiCalls != 0 {
+		t.Errorf("本地数据足够新时不应请求交易所: len=%d calls=%d", len(history), fetcher.oiCalls)
+	}
+
+	// 实时快照与已结算费率共存，已结算费率不被快照覆盖
+	store.SaveFundingRates("BTCUSDT", []FundingRatePoint{{Rate: 0.5, Timestamp: now}})
+	points, err := store.SyncFundingRates(fetcher, "BTCUSDT", now.Add(-3*24*time.Hour), now)
+	if err != nil || len(points) != 10 {
+		t.Fatalf("应包含9个已结算费率与1个实时快照: %d err=%v", len(points), err)
+	}
+	store.SaveFundingRates("BTCUSDT", []FundingRatePoint{{Rate: 9, Timestamp: points[0].Timestamp}})
+	points, _ = store.QueryFundingRates("BTCUSDT", points[0].Timestamp, points[0].Timestamp)
+	if len(points) != 1 || !points[0].Settled || points[0].Rate == 9 {
+		t.Errorf("已结算费率不应被实时快照覆盖: %+v", points)
+	}
+
+	stats, err := store.Stats()
+	if err != nil || stats.OISnapshots != 21 || stats.FundingRates != 10 {
+		t.Errorf("统计错误: %+v err=%v", stats, err)
+	}
+}
+
+func TestMergeKlines(t *testing.T) {
+	history := testKlines(5) // OpenTime 0..4
+	recent := []Kline{{OpenTime: 4, Close: 500}, {OpenTime: 5, Close: 600}}
+
+	merged := mergeKlines(history, recent, 5)
+	if len(merged) != 5 || merged[0].OpenTime != 1 || merged[3].Close != 500 || merged[4].Close != 600 {
+		t.Errorf("实时K线应覆盖并追加到历史末尾: %+v", merged)
+	}
+}
+
+func TestEstimateKlineCount(t *testing.T) {
+	start := time.Now().Add(-100 * 24 * time.Hour)
+	if n := EstimateKlineCount("1m", start, start.Add(24*time.Hour)); n != 1441 {
+		t.Errorf("1天的1m K线应约为1441根, got %d", n)
+	}
+	if n := EstimateKlineCount("1m", start, time.Now().Add(time.Hour)); n <= 5000 {
+		t.Errorf("end 晚于当前时间时应按当前时间估算且超过上限, got %d", n)
+	}
+	if n := EstimateKlineCount("bad", start, time.Now()); n != 0 {
+		t.Errorf("未知周期应返回0, got %d", n)
+	}
+}
@@ -0,0 +1,281 @@
+package market
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	hyperliquidInfoURL        = "https://api.hyperliquid.xyz/info"
+	hyperliquidTestnetInfoURL = "https://api.hyperliquid-testnet.xyz/info"
+
+	// hyperliquidCtxCacheTTL 资产上下文（持仓量/资金费率/标记价格）缓存时间，一个决策周期内多个币种共用一次请求
+	hyperliquidCtxCacheTTL = 15 * time.Second
+
+	// hyperliquidFundingPeriods Hyperliquid 每小时结算资金费，乘以 8 换算为与币安一致的 8 小时费率
+	hyperliquidFundingPeriods = 8
+)
+
+// hyperliquidIntervals Hyperliquid 支持的K线周期
+var hyperliquidIntervals = map[string]time.Duration{
+	"1m": time.Minute, "3m": 3 * time.Minute, "5m": 5 * time.Minute, "15m": 15 * time.Minute,
+	"30m": 30 * time.Minute, "1h": time.Hour, "2h": 2 * time.Hour, "4h": 4 * time.Hour,
+	"8h": 8 * time.Hour, "12h": 12 * time.Hour, "1d": 24 * time.Hour, "3d": 72 * time.Hour,
+	"1w": 7 * 24 * time.Hour,
+}
+
+// HyperliquidSource Hyperliquid 行情数据源（info API：candleSnapshot / allMids / metaAndAssetCtxs）
+type HyperliquidSource struct {
+	infoURL string
+	client  *http.Client
+	oi      *oiTracker
+
+	mu         sync.Mutex
+	latency    time.Duration
+	ctxCache   map[string]hyperliquidAssetCtx
+	ctxFetched time.Time
+}
+
+// hyperliquidAssetCtx 单个资产的实时上下文
+type hyperliquidAssetCtx struct {
+	Funding      string `json:"funding"`
+	OpenInterest string `json:"openInterest"`
+	MarkPx       string `json:"markPx"`
+	MidPx        string `json:"midPx"`
+	DayNtlVlm    string `json:"dayNtlVlm"`
+}
+
+// NewHyperliquidSource 创建 Hyperliquid 数据源
+func NewHyperliquidSource(testnet bool) *HyperliquidSource {
+	url := hyperliquidInfoURL
+	if testnet {
+		url = hyperliquidTestnetInfoURL
+	}
+	return newHyperliquidSourceWithURL(url)
+}
+
+func newHyperliquidSourceWithURL(url string) *HyperliquidSource {
+	return &HyperliquidSource{
+		infoURL: url,
+		client:  &http.Client{Timeout: 15 * time.Second},
+		oi:      newOITracker(),
+	}
+}
+
+// GetName 获取数据源名称
+func (s *HyperliquidSource) GetName() string {
+	return "hyperliquid"
+}
+
+// hyperliquidCoin 将标准symbol转换为Hyperliquid币种名（BTCUSDT -> BTC）
+func hyperliquidCoin(symbol string) string {
+	return strings.TrimSuffix(Normalize(symbol), "USDT")
+}
+
+// GetKlines 获取K线数据
+func (s *HyperliquidSource) GetKlines(symbol, interval string, limit int) ([]Kline, error) {
+	step, ok := hyperliquidIntervals[interval]
+	if !ok {
+		return nil, fmt.Errorf("Hyperliquid不支持的K线周期: %s", interval)
+	}
+	if limit <= 0 {
+		limit = sourceKlineLimit
+	}
+
+	end := time.Now()
+	start := end.Add(-step * time.Duration(limit+1))
+	var candles []struct {
+		OpenTime  int64  `json:"t"`
+		CloseTime int64  `json:"T"`
+		Open      string `json:"o"`
+		High      string `json:"h"`
+		Low       string `json:"l"`
+		Close     string `json:"c"`
+		Volume    string `json:"v"`
+		Trades    int    `json:"n"`
+	}
+	err := s.post(map[string]interface{}{
+		"type": "candleSnapshot",
+		"req": map[string]interface{}{
+			"coin":      hyperliquidCoin(symbol),
+			"interval":  interval,
+			"startTime": start.UnixMilli(),
+			"endTime":   end.UnixMilli(),
+		},
+	}, &candles)
+	if err != nil {
+		return nil, err
+	}
+	if len(candles) == 0 {
+		return nil, fmt.Errorf("Hyperliquid未返回%s的K线数据", symbol)
+	}
+
+	klines := make([]Kline, 0, len(candles))
+	for _, c := range candles {
+		k := Kline{OpenTime: c.OpenTime, CloseTime: c.CloseTime, Trades: c.Trades}
+		k.Open, _ = strconv.ParseFloat(c.Open, 64)
+		k.High, _ = strconv.ParseFloat(c.High, 64)
+		k.Low, _ = strconv.ParseFloat(c.Low, 64)
+		k.Close, _ = strconv.ParseFloat(c.Close, 64)
+		k.Volume, _ = strconv.ParseFloat(c.Volume, 64)
+		k.QuoteVolume = k.Volume * k.Close // 近似成交额（Hyperliquid 只返回基础币成交量）
+		klines = append(klines, k)
+	}
+	if len(klines) > limit {
+		klines = klines[len(klines)-limit:]
+	}
+	return klines, nil
+}
+
+// GetTicker 获取最新价格（优先使用中间价）
+func (s *HyperliquidSource) GetTicker(symbol string) (*Ticker, error) {
+	ctx, err := s.assetCtx(symbol)
+	if err != nil {
+		return nil, err
+	}
+	price, _ := strconv.ParseFloat(ctx.MidPx, 64)
+	if price <= 0 {
+		price, _ = strconv.ParseFloat(ctx.MarkPx, 64)
+	}
+	if price <= 0 {
+		return nil, fmt.Errorf("Hyperliquid未返回%s的有效价格", symbol)
+	}
+	volume, _ := strconv.ParseFloat(ctx.DayNtlVlm, 64)
+	return &Ticker{
+		Symbol:    Normalize(symbol),
+		LastPrice: price,
+		Volume:    volume,
+		Timestamp: time.Now().UnixMilli(),
+	}, nil
+}
+
+// GetOpenInterest 获取持仓量（币数量，与币安口径一致）
+func (s *HyperliquidSource) GetOpenInterest(symbol string) (*OIData, error) {
+	ctx, err := s.assetCtx(symbol)
+	if err != nil {
+		return nil, err
+	}
+	oi, err := strconv.ParseFloat(ctx.OpenInterest, 64)
+	if err != nil {
+		return nil, fmt.Errorf("解析持仓量失败: %w", err)
+	}
+	return s.oi.record(Normalize(symbol), oi, time.Now()), nil
+}
+
+// GetFundingRate 获取资金费率（换算为8小时等效费率）
+func (s *HyperliquidSource) GetFundingRate(symbol string) (float64, error) {
+	ctx, err := s.assetCtx(symbol)
+	if err != nil {
+		return 0, err
+	}
+	rate, err := strconv.ParseFloat(ctx.Funding, 64)
+	if err != nil {
+		return 0, fmt.Errorf("解析资金费率失败: %w", err)
+	}
+	return rate * hyperliquidFundingPeriods, nil
+}
+
+// HealthCheck 健康检查
+func (s *HyperliquidSource) HealthCheck() error {
+	var mids map[string]string
+	return s.post(map[string]string{"type": "allMids"}, &mids)
+}
+
+// GetLatency 获取最近一次请求的延迟
+func (s *HyperliquidSource) GetLatency() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.latency
+}
+
+// assetCtx 获取资产上下文（带短时缓存）
+func (s *HyperliquidSource) assetCtx(symbol string) (hyperliquidAssetCtx, error) {
+	coin := hyperliquidCoin(symbol)
+
+	s.mu.Lock()
+	if s.ctxCache != nil && time.Since(s.ctxFetched) < hyperliquidCtxCacheTTL {
+		ctx, ok := s.ctxCache[coin]
+		s.mu.Unlock()
+		if !ok {
+			return hyperliquidAssetCtx{}, fmt.Errorf("Hyperliquid不支持币种: %s", coin)
+		}
+		return ctx, nil
+	}
+	s.mu.Unlock()
+
+	var resp []json.RawMessage
+	if err := s.post(map[string]string{"type": "metaAndAssetCtxs"}, &resp); err != nil {
+		return hyperliquidAssetCtx{}, err
+	}
+	if len(resp) != 2 {
+		return hyperliquidAssetCtx{}, fmt.Errorf("metaAndAssetCtxs 响应格式错误")
+	}
+	var meta struct {
+		Universe []struct {
+			Name string `json:"name"`
+		} `json:"universe"`
+	}
+	var ctxs []hyperliquidAssetCtx
+	if err := json.Unmarshal(resp[0], &meta); err != nil {
+		return hyperliquidAssetCtx{}, fmt.Errorf("解析资产元数据失败: %w", err)
+	}
+	if err := json.Unmarshal(resp[1], &ctxs); err != nil {
+		return hyperliquidAssetCtx{}, fmt.Errorf("解析资产上下文失败: %w", err)
+	}
+
+	cache := make(map[string]hyperliquidAssetCtx, len(ctxs))
+	for i, asset := range meta.Universe {
+		if i < len(ctxs) {
+			cache[asset.Name] = ctxs[i]
+		}
+	}
+
+	s.mu.Lock()
+	s.ctxCache = cache
+	s.ctxFetched = time.Now()
+	s.mu.Unlock()
+
+	ctx, ok := cache[coin]
+	if !ok {
+		return hyperliquidAssetCtx{}, fmt.Errorf("Hyperliquid不支持币种: %s", coin)
+	}
+	return ctx, nil
+}
+
+// post 调用 info API
+func (s *HyperliquidSource) post(request interface{}, result interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	resp, err := s.client.Post(s.infoURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("Hyperliquid请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("读取Hyperliquid响应失败: %w", err)
+	}
+	s.mu.Lock()
+	s.latency = time.Since(start)
+	s.mu.Unlock()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Hyperliquid HTTP %d: %s", resp.StatusCode, string(data[:min(200, len(data))]))
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("解析Hyperliquid响应失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,615 @@
+package market
+
+import (
+	"math"
+	"time"
+)
+
+// 内置指标库：所有序列与K线对齐，预热期为 NaN
+func init() {
+	RegisterIndicator(&IndicatorDef{
+		Name:        "ema",
+		Title:       "EMA",
+		Description: "指数移动平均线",
+		Params:      []IndicatorParam{{Name: "period", Default: 20, Min: 2, Max: 500, Integer: true, Description: "周期"}},
+		Outputs:     []string{"ema"},
+		lookback:    func(p IndicatorParams) int { return p.Int("period") - 1 },
+		compute: func(klines []Kline, p IndicatorParams) []IndicatorLine {
+			return []IndicatorLine{{Name: "ema", Values: emaSeries(closeSeries(klines), p.Int("period"))}}
+		},
+	})
+
+	RegisterIndicator(&IndicatorDef{
+		Name:        "rsi",
+		Title:       "RSI",
+		Description: "相对强弱指数（Wilder平滑）",
+		Params:      []IndicatorParam{{Name: "period", Default: 14, Min: 2, Max: 100, Integer: true, Description: "周期"}},
+		Outputs:     []string{"rsi"},
+		lookback:    func(p IndicatorParams) int { return p.Int("period") },
+		compute: func(klines []Kline, p IndicatorParams) []IndicatorLine {
+			return []IndicatorLine{{Name: "rsi", Values: rsiSeries(closeSeries(klines), p.Int("period"))}}
+		},
+	})
+
+	RegisterIndicator(&IndicatorDef{
+		Name:        "macd",
+		Title:       "MACD",
+		Description: "指数平滑异同移动平均线",
+		Params: []IndicatorParam{
+			{Name: "fast", Default: 12, Min: 2, Max: 100, Integer: true, Description: "快线周期"},
+			{Name: "slow", Default: 26, Min: 3, Max: 200, Integer: true, Description: "慢线周期"},
+			{Name: "signal", Default: 9, Min: 2, Max: 100, Integer: true, Description: "信号线周期"},
+		},
+		Outputs:  []string{"macd", "signal", "histogram"},
+		lookback: func(p IndicatorParams) int { return p.Int("slow") + p.Int("signal") - 2 },
+		compute: func(klines []Kline, p IndicatorParams) []IndicatorLine {
+			closes := closeSeries(klines)
+			fast := emaSeries(closes, p.Int("fast"))
+			slow := emaSeries(closes, p.Int("slow"))
+			macd := make([]float64, len(closes))
+			for i := range macd {
+				macd[i] = fast[i] - slow[i]
+			}
+			signal := emaSeries(macd, p.Int("signal"))
+			hist := make([]float64, len(closes))
+			for i := range hist {
+				hist[i] = macd[i] - signal[i]
+			}
+			return []IndicatorLine{{Name: "macd", Values: macd}, {Name: "signal", Values: signal}, {Name: "histogram", Values: hist}}
+		},
+	})
+
+	RegisterIndicator(&IndicatorDef{
+		Name:        "atr",
+		Title:       "ATR",
+		Description: "平均真实波幅（Wilder平滑）",
+		Params:      []IndicatorParam{{Name: "period", Default: 14, Min: 2, Max: 100, Integer: true, Description: "周期"}},
+		Outputs:     []string{"atr"},
+		lookback:    func(p IndicatorParams) int { return p.Int("period") },
+		compute: func(klines []Kline, p IndicatorParams) []IndicatorLine {
+			return []IndicatorLine{{Name: "atr", Values: atrSeries(klines, p.Int("period"))}}
+		},
+	})
+
+	RegisterIndicator(&IndicatorDef{
+		Name:        "bollinger",
+		Title:       "Bollinger Bands",
+		Description: "布林带（%b: 价格在带内的位置，bandwidth: 带宽占中轨百分比）",
+		Params: []IndicatorParam{
+			{Name: "period", Default: 20, Min: 2, Max: 200, Integer: true, Description: "周期"},
+			{Name: "stddev", Default: 2, Min: 0.5, Max: 5, Description: "标准差倍数"},
+		},
+		Outputs:  []string{"upper", "middle", "lower", "percent_b", "bandwidth"},
+		lookback: func(p IndicatorParams) int { return p.Int("period") - 1 },
+		compute:  computeBollinger,
+	})
+
+	RegisterIndicator(&IndicatorDef{
+		Name:        "vwap",
+		Title:       "VWAP",
+		Description: "成交量加权平均价（period=0 按UTC自然日重置，>0 为滚动N根K线）",
+		Params:      []IndicatorParam{{Name: "period", Default: 0, Min: 0, Max: 500, Integer: true, Description: "滚动周期（0=按日重置）"}},
+		Outputs:     []string{"vwap"},
+		lookback:    func(p IndicatorParams) int { return max(p.Int("period")-1, 0) },
+		compute:     computeVWAP,
+	})
+
+	RegisterIndicator(&IndicatorDef{
+		Name:        "stoch_rsi",
+		Title:       "Stochastic RSI",
+		Description: "随机RSI（0~100）",
+		Params: []IndicatorParam{
+			{Name: "rsi_period", Default: 14, Min: 2, Max: 100, Integer: true, Description: "RSI周期"},
+			{Name: "stoch_period", Default: 14, Min: 2, Max: 100, Integer: true, Description: "随机指标周期"},
+			{Name: "k", Default: 3, Min: 1, Max: 20, Integer: true, Description: "%K平滑周期"},
+			{Name: "d", Default: 3, Min: 1, Max: 20, Integer: true, Description: "%D平滑周期"},
+		},
+		Outputs: []string{"k", "d"},
+		lookback: func(p IndicatorParams) int {
+			return p.Int("rsi_period") + p.Int("stoch_period") + p.Int("k") + p.Int("d") - 3
+		},
+		compute: computeStochRSI,
+	})
+
+	RegisterIndicator(&IndicatorDef{
+		Name:        "adx",
+		Title:       "ADX",
+		Description: "平均趋向指数（趋势强度）与 +DI/-DI",
+		Params:      []IndicatorParam{{Name: "period", Default: 14, Min: 2, Max: 100, Integer: true, Description: "周期"}},
+		Outputs:     []string{"adx", "plus_di", "minus_di"},
+		lookback:    func(p IndicatorParams) int { return 2*p.Int("period") - 1 },
+		compute:     computeADX,
+	})
+
+	RegisterIndicator(&IndicatorDef{
+		Name:        "obv",
+		Title:       "OBV",
+		Description: "能量潮（累计成交量，关注方向与背离）",
+		Outputs:     []string{"obv"},
+		lookback:    func(p IndicatorParams) int { return 0 },
+		compute:     computeOBV,
+	})
+
+	RegisterIndicator(&IndicatorDef{
+		Name:        "supertrend",
+		Title:       "Supertrend",
+		Description: "超级趋势（direction: 1=多头，-1=空头）",
+		Params: []IndicatorParam{
+			{Name: "period", Default: 10, Min: 2, Max: 100, Integer: true, Description: "ATR周期"},
+			{Name: "multiplier", Default: 3, Min: 0.5, Max: 10, Description: "ATR倍数"},
+		},
+		Outputs:  []string{"supertrend", "direction"},
+		lookback: func(p IndicatorParams) int { return p.Int("period") },
+		compute:  computeSupertrend,
+	})
+
+	RegisterIndicator(&IndicatorDef{
+		Name:        "ichimoku",
+		Title:       "Ichimoku",
+		Description: "一目均衡表（span_a/span_b 为当前K线对应的云层）",
+		Params: []IndicatorParam{
+			{Name: "tenkan", Default: 9, Min: 2, Max: 100, Integer: true, Description: "转换线周期"},
+			{Name: "kijun", Default: 26, Min: 2, Max: 200, Integer: true, Description: "基准线周期（云层位移）"},
+			{Name: "senkou", Default: 52, Min: 2, Max: 300, Integer: true, Description: "先行带B周期"},
+		},
+		Outputs: []string{"tenkan", "kijun", "span_a", "span_b"},
+		lookback: func(p IndicatorParams) int {
+			return max(p.Int("senkou"), p.Int("kijun"), p.Int("tenkan")) + p.Int("kijun") - 1
+		},
+		compute: computeIchimoku,
+	})
+
+	RegisterIndicator(&IndicatorDef{
+		Name:        "volume_profile",
+		Title:       "Volume Profile",
+		Description: "成交量分布（POC 成交最密集价位，VAH/VAL 价值区上下沿）",
+		Params: []IndicatorParam{
+			{Name: "bars", Default: 100, Min: 10, Max: 1000, Integer: true, Description: "统计的K线数"},
+			{Name: "bins", Default: 24, Min: 5, Max: 200, Integer: true, Description: "价格分箱数"},
+			{Name: "value_area", Default: 70, Min: 10, Max: 100, Description: "价值区成交量占比（%）"},
+		},
+		Outputs:  []string{"poc", "vah", "val"},
+		lookback: func(p IndicatorParams) int { return p.Int("bars") - 1 },
+		compute:  computeVolumeProfile,
+	})
+}
+
+// nanSeries 创建全为 NaN 的序列
+func nanSeries(n int) []float64 {
+	s := make([]float64, n)
+	for i := range s {
+		s[i] = math.NaN()
+	}
+	return s
+}
+
+func closeSeries(klines []Kline) []float64 {
+	closes := make([]float64, len(klines))
+	for i, k := range klines {
+		closes[i] = k.Close
+	}
+	return closes
+}
+
+// firstValid 第一个非 NaN 值的下标
+func firstValid(values []float64) int {
+	for i, v := range values {
+		if !math.IsNaN(v) {
+			return i
+		}
+	}
+	return len(values)
+}
+
+// smaSeries 简单移动平均（跳过前导 NaN）
+func smaSeries(values []float64, period int) []float64 {
+	out := nanSeries(len(values))
+	start := firstValid(values)
+	sum := 0.0
+	for i := start; i < len(values); i++ {
+		sum += values[i]
+		if i-start >= period {
+			sum -= values[i-period]
+		}
+		if i-start >= period-1 {
+			out[i] = sum / float64(period)
+		}
+	}
+	return out
+}
+
+// emaSeries 指数移动平均（以SMA为初始值，与 calculateEMA 口径一致）
+func emaSeries(values []float64, period int) []float64 {
+	out := nanSeries(len(values))
+	start := firstValid(values)
+	if len(values)-start < period {
+		return out
+	}
+	sum := 0.0
+	for i := start; i < start+period; i++ {
+		sum += values[i]
+	}
+	ema := sum / float64(period)
+	out[start+period-1] = ema
+	multiplier := 2.0 / float64(period+1)
+	for i := start + period; i < len(values); i++ {
+		ema = (values[i]-ema)*multiplier + ema
+		out[i] = ema
+	}
+	return out
+}
+
+// rsiSeries RSI序列（Wilder平滑，与 calculateRSI 口径一致）
+func rsiSeries(closes []float64, period int) []float64 {
+	out := nanSeries(len(closes))
+	if len(closes) <= period {
+		return out
+	}
+	gains, losses := 0.0, 0.0
+	for i := 1; i <= period; i++ {
+		change := closes[i] - closes[i-1]
+		if change > 0 {
+			gains += change
+		} else {
+			losses -= change
+		}
+	}
+	avgGain := gains / float64(period)
+	avgLoss := losses / float64(period)
+	out[period] = rsiValue(avgGain, avgLoss)
+	for i := period + 1; i < len(closes); i++ {
+		gain, loss := 0.0, 0.0
+		if change := closes[i] - closes[i-1]; change > 0 {
+			gain = change
+		} else {
+			loss = -change
+		}
+		avgGain = (avgGain*float64(period-1) + gain) / float64(period)
+		avgLoss = (avgLoss*float64(period-1) + loss) / float64(period)
+		out[i] = rsiValue(avgGain, avgLoss)
+	}
+	return out
+}
+
+func rsiValue(avgGain, avgLoss float64) float64 {
+	if avgLoss == 0 {
+		return 100
+	}
+	return 100 - 100/(1+avgGain/avgLoss)
+}
+
+// trueRange 第 i 根K线的真实波幅（i>0）
+func trueRange(klines []Kline, i int) float64 {
+	prevClose := klines[i-1].Close
+	return math.Max(klines[i].High-klines[i].Low, math.Max(math.Abs(klines[i].High-prevClose), math.Abs(klines[i].Low-prevClose)))
+}
+
+// atrSeries ATR序列（Wilder平滑，与 calculateATR 口径一致）
+func atrSeries(klines []Kline, period int) []float64 {
+	out := nanSeries(len(klines))
+	if len(klines) <= period {
+		return out
+	}
+	sum := 0.0
+	for i := 1; i <= period; i++ {
+		sum += trueRange(klines, i)
+	}
+	atr := sum / float64(period)
+	out[period] = atr
+	for i := period + 1; i < len(klines); i++ {
+		atr = (atr*float64(period-1) + trueRange(klines, i)) / float64(period)
+		out[i] = atr
+	}
+	return out
+}
+
+func computeBollinger(klines []Kline, p IndicatorParams) []IndicatorLine {
+	period, k := p.Int("period"), p["stddev"]
+	closes := closeSeries(klines)
+	middle := smaSeries(closes, period)
+	upper, lower := nanSeries(len(closes)), nanSeries(len(closes))
+	percentB, bandwidth := nanSeries(len(closes)), nanSeries(len(closes))
+	for i := period - 1; i < len(closes); i++ {
+		variance := 0.0
+		for j := i - period + 1; j <= i; j++ {
+			d := closes[j] - middle[i]
+			variance += d * d
+		}
+		sd := math.Sqrt(variance / float64(period))
+		upper[i] = middle[i] + k*sd
+		lower[i] = middle[i] - k*sd
+		if width := upper[i] - lower[i]; width > 0 {
+			percentB[i] = (closes[i] - lower[i]) / width
+		} else {
+			percentB[i] = 0.5
+		}
+		if middle[i] != 0 {
+			bandwidth[i] = (upper[i] - lower[i]) / middle[i] * 100
+		}
+	}
+	return []IndicatorLine{
+		{Name: "upper", Values: upper}, {Name: "middle", Values: middle}, {Name: "lower", Values: lower},
+		{Name: "percent_b", Values: percentB}, {Name: "bandwidth", Values: bandwidth},
+	}
+}
+
+func computeVWAP(klines []Kline, p IndicatorParams) []IndicatorLine {
+	period := p.Int("period")
+	out := nanSeries(len(klines))
+	typical := func(k Kline) float64 { return (k.High + k.Low + k.Close) / 3 }
+
+	if period > 0 {
+		pv, vol := 0.0, 0.0
+		for i, k := range klines {
+			pv += typical(k) * k.Volume
+			vol += k.Volume
+			if i >= period {
+				old := klines[i-period]
+				pv -= typical(old) * old.Volume
+				vol -= old.Volume
+			}
+			if i >= period-1 && vol > 0 {
+				out[i] = pv / vol
+			}
+		}
+	} else {
+		pv, vol := 0.0, 0.0
+		day := int64(-1)
+		for i, k := range klines {
+			if d := k.OpenTime / int64(24*time.Hour/time.Millisecond); d != day {
+				day, pv, vol = d, 0, 0
+			}
+			pv += typical(k) * k.Volume
+			vol += k.Volume
+			if vol > 0 {
+				out[i] = pv / vol
+			}
+		}
+	}
+	return []IndicatorLine{{Name: "vwap", Values: out}}
+}
+
+func computeStochRSI(klines []Kline, p IndicatorParams) []IndicatorLine {
+	rsi := rsiSeries(closeSeries(klines), p.Int("rsi_period"))
+	period := p.Int("stoch_period")
+	stoch := nanSeries(len(rsi))
+	start := firstValid(rsi)
+	for i := start + period - 1; i < len(rsi); i++ {
+		lo, hi := rsi[i], rsi[i]
+		for j := i - period + 1; j < i; j++ {
+			lo = math.Min(lo, rsi[j])
+			hi = math.Max(hi, rsi[j])
+		}
+		if hi > lo {
+			stoch[i] = (rsi[i] - lo) / (hi - lo) * 100
+		} else {
+			stoch[i] = 50
+		}
+	}
+	k := smaSeries(stoch, p.Int("k"))
+	d := smaSeries(k, p.Int("d"))
+	return []IndicatorLine{{Name: "k", Values: k}, {Name: "d", Values: d}}
+}
+
+func computeADX(klines []Kline, p IndicatorParams) []IndicatorLine {
+	period := p.Int("period")
+	n := len(klines)
+	adx, plusDI, minusDI := nanSeries(n), nanSeries(n), nanSeries(n)
+	if n <= period {
+		return []IndicatorLine{{Name: "adx", Values: adx}, {Name: "plus_di", Values: plusDI}, {Name: "minus_di", Values: minusDI}}
+	}
+
+	dm := func(i int) (float64, float64) {
+		up := klines[i].High - klines[i-1].High
+		down := klines[i-1].Low - klines[i].Low
+		plus, minus := 0.0, 0.0
+		if up > down && up > 0 {
+			plus = up
+		}
+		if down > up && down > 0 {
+			minus = down
+		}
+		return plus, minus
+	}
+
+	var sTR, sPlus, sMinus float64
+	dx := nanSeries(n)
+	for i := 1; i < n; i++ {
+		tr := trueRange(klines, i)
+		plus, minus := dm(i)
+		if i <= period {
+			sTR += tr
+			sPlus += plus
+			sMinus += minus
+			if i < period {
+				continue
+			}
+		} else {
+			sTR = sTR - sTR/float64(period) + tr
+			sPlus = sPlus - sPlus/float64(period) + plus
+			sMinus = sMinus - sMinus/float64(period) + minus
+		}
+		if sTR > 0 {
+			plusDI[i] = 100 * sPlus / sTR
+			minusDI[i] = 100 * sMinus / sTR
+		} else {
+			plusDI[i], minusDI[i] = 0, 0
+		}
+		if sum := plusDI[i] + minusDI[i]; sum > 0 {
+			dx[i] = 100 * math.Abs(plusDI[i]-minusDI[i]) / sum
+		} else {
+			dx[i] = 0
+		}
+	}
+
+	// ADX：首个值为 period 个 DX 的均值，之后 Wilder 平滑
+	first := 2*period - 1
+	if first < n {
+		sum := 0.0
+		for i := period; i <= first; i++ {
+			sum += dx[i]
+		}
+		adx[first] = sum / float64(period)
+		for i := first + 1; i < n; i++ {
+			adx[i] = (adx[i-1]*float64(period-1) + dx[i]) / float64(period)
+		}
+	}
+	return []IndicatorLine{{Name: "adx", Values: adx}, {Name: "plus_di", Values: plusDI}, {Name: "minus_di", Values: minusDI}}
+}
+
+func computeOBV(klines []Kline, _ IndicatorParams) []IndicatorLine {
+	out := make([]float64, len(klines))
+	for i := 1; i < len(klines); i++ {
+		out[i] = out[i-1]
+		switch {
+		case klines[i].Close > klines[i-1].Close:
+			out[i] += klines[i].Volume
+		case klines[i].Close < klines[i-1].Close:
+			out[i] -= klines[i].Volume
+		}
+	}
+	return []IndicatorLine{{Name: "obv", Values: out}}
+}
+
+func computeSupertrend(klines []Kline, p IndicatorParams) []IndicatorLine {
+	period, mult := p.Int("period"), p["multiplier"]
+	atr := atrSeries(klines, period)
+	n := len(klines)
+	trend, direction := nanSeries(n), nanSeries(n)
+
+	var finalUpper, finalLower float64
+	dir := 1.0
+	for i := period; i < n; i++ {
+		hl2 := (klines[i].High + klines[i].Low) / 2
+		upper := hl2 + mult*atr[i]
+		lower := hl2 - mult*atr[i]
+		if i > period {
+			prevClose := klines[i-1].Close
+			if upper > finalUpper && prevClose <= finalUpper {
+				upper = finalUpper
+			}
+			if lower < finalLower && prevClose >= finalLower {
+				lower = finalLower
+			}
+			if dir == 1 && klines[i].Close < lower {
+				dir = -1
+			} else if dir == -1 && klines[i].Close > upper {
+				dir = 1
+			}
+		} else if klines[i].Close < hl2 {
+			dir = -1
+		}
+		finalUpper, finalLower = upper, lower
+		if dir == 1 {
+			trend[i] = finalLower
+		} else {
+			trend[i] = finalUpper
+		}
+		direction[i] = dir
+	}
+	return []IndicatorLine{{Name: "supertrend", Values: trend}, {Name: "direction", Values: direction}}
+}
+
+// donchianMid 第 i 根K线向前 period 根的最高价与最低价中点（数据不足时为 NaN）
+func donchianMid(klines []Kline, i, period int) float64 {
+	if i < period-1 {
+		return math.NaN()
+	}
+	hi, lo := klines[i].High, klines[i].Low
+	for j := i - period + 1; j < i; j++ {
+		hi = math.Max(hi, klines[j].High)
+		lo = math.Min(lo, klines[j].Low)
+	}
+	return (hi + lo) / 2
+}
+
+func computeIchimoku(klines []Kline, p IndicatorParams) []IndicatorLine {
+	tenkanP, kijunP, senkouP := p.Int("tenkan"), p.Int("kijun"), p.Int("senkou")
+	n := len(klines)
+	tenkan, kijun := nanSeries(n), nanSeries(n)
+	for i := range klines {
+		tenkan[i] = donchianMid(klines, i, tenkanP)
+		kijun[i] = donchianMid(klines, i, kijunP)
+	}
+	// 先行带向前位移 kijun 根：当前K线的云层由 kijun 根之前的数据计算
+	spanA, spanB := nanSeries(n), nanSeries(n)
+	for i := kijunP; i < n; i++ {
+		j := i - kijunP
+		spanA[i] = (tenkan[j] + kijun[j]) / 2
+		spanB[i] = donchianMid(klines, j, senkouP)
+	}
+	return []IndicatorLine{
+		{Name: "tenkan", Values: tenkan}, {Name: "kijun", Values: kijun},
+		{Name: "span_a", Values: spanA}, {Name: "span_b", Values: spanB},
+	}
+}
+
+// computeVolumeProfile 统计最近 bars 根K线的成交量分布（每根K线成交量按高低价区间均匀分配到价格箱）
+func computeVolumeProfile(klines []Kline, p IndicatorParams) []IndicatorLine {
+	bars, bins := p.Int("bars"), p.Int("bins")
+	if len(klines) > bars {
+		klines = klines[len(klines)-bars:]
+	}
+	lo, hi := klines[0].Low, klines[0].High
+	for _, k := range klines {
+		lo = math.Min(lo, k.Low)
+		hi = math.Max(hi, k.High)
+	}
+	if hi <= lo {
+		return []IndicatorLine{{Name: "poc", Values: []float64{hi}}, {Name: "vah", Values: []float64{hi}}, {Name: "val", Values: []float64{lo}}}
+	}
+
+	step := (hi - lo) / float64(bins)
+	volumes := make([]float64, bins)
+	binOf := func(price float64) int { return min(int((price-lo)/step), bins-1) }
+	for _, k := range klines {
+		first, last := binOf(k.Low), binOf(k.High)
+		if k.High <= k.Low {
+			volumes[first] += k.Volume
+			continue
+		}
+		for b := first; b <= last; b++ {
+			binLo := lo + float64(b)*step
+			overlap := math.Min(k.High, binLo+step) - math.Max(k.Low, binLo)
+			if overlap > 0 {
+				volumes[b] += k.Volume * overlap / (k.High - k.Low)
+			}
+		}
+	}
+
+	poc, total := 0, 0.0
+	for b, v := range volumes {
+		total += v
+		if v > volumes[poc] {
+			poc = b
+		}
+	}
+
+	// 价值区：从 POC 向两侧扩展，每次并入成交量更大的一侧，直到覆盖 value_area%
+	target := total * p["value_area"] / 100
+	low, high := poc, poc
+	covered := volumes[poc]
+	for covered < target && (low > 0 || high < bins-1) {
+		below, above := -1.0, -1.0
+		if low > 0 {
+			below = volumes[low-1]
+		}
+		if high < bins-1 {
+			above = volumes[high+1]
+		}
+		if above >= below {
+			high++
+			covered += above
+		} else {
+			low--
+			covered += below
+		}
+	}
+
+	mid := func(b int) float64 { return lo + (float64(b)+0.5)*step }
+	return []IndicatorLine{
+		{Name: "poc", Values: []float64{mid(poc)}},
+		{Name: "vah", Values: []float64{lo + float64(high+1)*step}},
+		{Name: "val", Values: []float64{lo + float64(low)*step}},
+	}
+}
@@ -0,0 +1,330 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	defaultIndicatorPoints = 10  // 默认输出最近的指标值个数
+	maxIndicatorPoints     = 50  // 单个指标最多输出的值个数（控制提示词长度）
+	maxIndicatorSpecs      = 20  // 单个交易员最多选择的指标数
+	minIndicatorKlines     = 100 // 计算指标时至少获取的K线数（EMA类指标需要足够的预热数据）
+	maxIndicatorKlines     = 1000
+)
+
+// IndicatorParam 指标参数定义
+type IndicatorParam struct {
+	Name        string  `json:"name"`
+	Default     float64 `json:"default"`
+	Min         float64 `json:"min"`
+	Max         float64 `json:"max"`
+	Integer     bool    `json:"integer"`
+	Description string  `json:"description"`
+}
+
+// IndicatorParams 指标参数取值
+type IndicatorParams map[string]float64
+
+// Int 整数参数
+func (p IndicatorParams) Int(name string) int {
+	return int(math.Round(p[name]))
+}
+
+// IndicatorLine 指标输出的一条序列（与K线对齐，预热期为 NaN）
+type IndicatorLine struct {
+	Name   string    `json:"name"`
+	Values []float64 `json:"values"`
+}
+
+// IndicatorDef 指标定义
+type IndicatorDef struct {
+	Name        string           `json:"name"`
+	Title       string           `json:"title"`
+	Description string           `json:"description"`
+	Params      []IndicatorParam `json:"params"`
+	Outputs     []string         `json:"outputs"`
+
+	lookback func(p IndicatorParams) int                             // 首个有效值之前需要的K线数
+	compute  func(klines []Kline, p IndicatorParams) []IndicatorLine // 按 Outputs 顺序返回序列
+}
+
+var indicatorRegistry = make(map[string]*IndicatorDef)
+
+// RegisterIndicator 注册指标（名称重复时 panic）
+func RegisterIndicator(def *IndicatorDef) {
+	if _, exists := indicatorRegistry[def.Name]; exists {
+		panic(fmt.Sprintf("指标 %s 重复注册", def.Name))
+	}
+	indicatorRegistry[def.Name] = def
+}
+
+// LookupIndicator 按名称查找指标
+func LookupIndicator(name string) (*IndicatorDef, bool) {
+	def, ok := indicatorRegistry[name]
+	return def, ok
+}
+
+// Indicators 所有已注册的指标（按名称排序）
+func Indicators() []*IndicatorDef {
+	defs := make([]*IndicatorDef, 0, len(indicatorRegistry))
+	for _, def := range indicatorRegistry {
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs
+}
+
+// IndicatorSpec 交易员选择的指标（某个时间周期上的一个指标及其参数）
+type IndicatorSpec struct {
+	Name      string          `json:"name"`
+	Timeframe string          `json:"timeframe"`
+	Params    IndicatorParams `json:"params,omitempty"`
+	Points    int             `json:"points,omitempty"` // 输出最近多少个值（默认10）
+}
+
+// Label 提示词中显示的指标名称，例如 "Bollinger Bands(20, 2)"
+func (s IndicatorSpec) Label() string {
+	def, ok := LookupIndicator(s.Name)
+	if !ok {
+		return s.Name
+	}
+	if len(def.Params) == 0 {
+		return def.Title
+	}
+	values := make([]string, len(def.Params))
+	for i, param := range def.Params {
+		values[i] = strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.4f", s.Params[param.Name]), "0"), ".")
+	}
+	return fmt.Sprintf("%s(%s)", def.Title, strings.Join(values, ", "))
+}
+
+// klinesNeeded 计算该指标需要获取的K线数
+func (s IndicatorSpec) klinesNeeded() int {
+	def, ok := LookupIndicator(s.Name)
+	if !ok {
+		return minIndicatorKlines
+	}
+	// 预热期的3倍，使 EMA/Wilder 平滑类指标收敛
+	n := def.lookback(s.Params)*3 + s.Points
+	if n < minIndicatorKlines {
+		n = minIndicatorKlines
+	}
+	if n > maxIndicatorKlines {
+		n = maxIndicatorKlines
+	}
+	return n
+}
+
+// ParseIndicatorSet 解析并校验交易员的指标配置（JSON数组），补全默认参数；空字符串返回 nil
+func ParseIndicatorSet(raw string) ([]IndicatorSpec, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	var specs []IndicatorSpec
+	if err := json.Unmarshal([]byte(raw), &specs); err != nil {
+		return nil, fmt.Errorf("指标配置格式错误: %w", err)
+	}
+	if len(specs) > maxIndicatorSpecs {
+		return nil, fmt.Errorf("最多选择 %d 个指标", maxIndicatorSpecs)
+	}
+
+	seen := make(map[string]bool, len(specs))
+	for i := range specs {
+		spec := &specs[i]
+		spec.Name = strings.ToLower(strings.TrimSpace(spec.Name))
+		spec.Timeframe = strings.TrimSpace(spec.Timeframe)
+
+		def, ok := LookupIndicator(spec.Name)
+		if !ok {
+			return nil, fmt.Errorf("不支持的指标: %s", spec.Name)
+		}
+		if !IsValidInterval(spec.Timeframe) {
+			return nil, fmt.Errorf("指标 %s 的时间周期无效: %s", spec.Name, spec.Timeframe)
+		}
+		if spec.Points == 0 {
+			spec.Points = defaultIndicatorPoints
+		}
+		if spec.Points < 1 || spec.Points > maxIndicatorPoints {
+			return nil, fmt.Errorf("指标 %s 的输出个数需在 1~%d 之间", spec.Name, maxIndicatorPoints)
+		}
+
+		params := make(IndicatorParams, len(def.Params))
+		for name := range spec.Params {
+			if !def.hasParam(name) {
+				return nil, fmt.Errorf("指标 %s 不支持参数 %s", spec.Name, name)
+			}
+		}
+		for _, param := range def.Params {
+			value, set := spec.Params[param.Name]
+			if !set {
+				value = param.Default
+			}
+			if value < param.Min || value > param.Max {
+				return nil, fmt.Errorf("指标 %s 参数 %s 需在 %g~%g 之间", spec.Name, param.Name, param.Min, param.Max)
+			}
+			if param.Integer && value != math.Trunc(value) {
+				return nil, fmt.Errorf("指标 %s 参数 %s 必须为整数", spec.Name, param.Name)
+			}
+			params[param.Name] = value
+		}
+		spec.Params = params
+
+		key := spec.Timeframe + "|" + spec.Label()
+		if seen[key] {
+			return nil, fmt.Errorf("指标 %s 在 %s 上重复配置", spec.Label(), spec.Timeframe)
+		}
+		seen[key] = true
+	}
+	return specs, nil
+}
+
+// NormalizeIndicatorSet 校验指标配置并返回补全默认参数后的 JSON（空配置返回空字符串）
+func NormalizeIndicatorSet(raw string) (string, error) {
+	specs, err := ParseIndicatorSet(raw)
+	if err != nil || len(specs) == 0 {
+		return "", err
+	}
+	data, err := json.Marshal(specs)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// IndicatorTimeframes 指标配置涉及的时间周期（去重，保持配置顺序）
+func IndicatorTimeframes(specs []IndicatorSpec) []string {
+	seen := make(map[string]bool)
+	var timeframes []string
+	for _, spec := range specs {
+		if !seen[spec.Timeframe] {
+			seen[spec.Timeframe] = true
+			timeframes = append(timeframes, spec.Timeframe)
+		}
+	}
+	return timeframes
+}
+
+func (d *IndicatorDef) hasParam(name string) bool {
+	for _, param := range d.Params {
+		if param.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// IndicatorResult 指标计算结果（只保留最近 Points 个有效值）
+type IndicatorResult struct {
+	Spec  IndicatorSpec   `json:"spec"`
+	Lines []IndicatorLine `json:"lines"`
+}
+
+// ComputeIndicator 基于K线计算单个指标
+func ComputeIndicator(klines []Kline, spec IndicatorSpec) (*IndicatorResult, error) {
+	def, ok := LookupIndicator(spec.Name)
+	if !ok {
+		return nil, fmt.Errorf("不支持的指标: %s", spec.Name)
+	}
+	if need := def.lookback(spec.Params) + 1; len(klines) < need {
+		return nil, fmt.Errorf("%s K线数据不足（%d根，至少需要%d根）", spec.Label(), len(klines), need)
+	}
+
+	points := spec.Points
+	if points <= 0 {
+		points = defaultIndicatorPoints
+	}
+	lines := def.compute(klines, spec.Params)
+	result := &IndicatorResult{Spec: spec, Lines: make([]IndicatorLine, 0, len(lines))}
+	for _, line := range lines {
+		result.Lines = append(result.Lines, IndicatorLine{Name: line.Name, Values: lastValid(line.Values, points)})
+	}
+	return result, nil
+}
+
+// ComputeIndicatorSet 计算交易员选择的全部指标，每个时间周期只获取一次K线
+// fetch 按时间周期获取K线；某个时间周期获取失败时跳过其上的指标
+func ComputeIndicatorSet(specs []IndicatorSpec, fetch func(timeframe string, limit int) ([]Kline, error)) ([]IndicatorResult, error) {
+	limits := make(map[string]int)
+	for _, spec := range specs {
+		if n := spec.klinesNeeded(); n > limits[spec.Timeframe] {
+			limits[spec.Timeframe] = n
+		}
+	}
+
+	klinesByTF := make(map[string][]Kline, len(limits))
+	var errs []string
+	for _, tf := range IndicatorTimeframes(specs) {
+		klines, err := fetch(tf, limits[tf])
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", tf, err))
+			continue
+		}
+		klinesByTF[tf] = klines
+	}
+
+	results := make([]IndicatorResult, 0, len(specs))
+	for _, spec := range specs {
+		klines, ok := klinesByTF[spec.Timeframe]
+		if !ok {
+			continue
+		}
+		result, err := ComputeIndicator(klines, spec)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		results = append(results, *result)
+	}
+	if len(errs) > 0 {
+		return results, fmt.Errorf("部分指标计算失败: %s", strings.Join(errs, "; "))
+	}
+	return results, nil
+}
+
+// FormatIndicators 按交易员的指标配置生成提示词段落
+func FormatIndicators(results []IndicatorResult) string {
+	if len(results) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("Selected indicators (oldest → latest):\n\n")
+	for _, result := range results {
+		parts := make([]string, 0, len(result.Lines))
+		for _, line := range result.Lines {
+			parts = append(parts, fmt.Sprintf("%s = %s", line.Name, formatIndicatorValues(line.Values)))
+		}
+		sb.WriteString(fmt.Sprintf("[%s] %s: %s\n", result.Spec.Timeframe, result.Spec.Label(), strings.Join(parts, " | ")))
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// formatIndicatorValues 格式化指标值（按绝对值选择精度，单个值不加括号）
+func formatIndicatorValues(values []float64) string {
+	strValues := make([]string, len(values))
+	for i, v := range values {
+		s := formatPriceWithDynamicPrecision(math.Abs(v))
+		if v < 0 {
+			s = "-" + s
+		}
+		strValues[i] = s
+	}
+	if len(strValues) == 1 {
+		return strValues[0]
+	}
+	return "[" + strings.Join(strValues, ", ") + "]"
+}
+
+// lastValid 取序列末尾最多 n 个有效值（跳过预热期的 NaN）
+func lastValid(values []float64, n int) []float64 {
+	start := len(values)
+	for start > 0 && len(values)-start < n && !math.IsNaN(values[start-1]) {
+		start--
+	}
+	return append([]float64(nil), values[start:]...)
+}
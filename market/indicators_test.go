@@ -0,0 +1,187 @@
+package market
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// waveKlines 生成带波动的K线（上涨趋势叠加正弦波动）
+func waveKlines(n int, slope float64) []Kline {
+	klines := make([]Kline, n)
+	for i := range klines {
+		base := 100 + slope*float64(i) + 5*math.Sin(float64(i)/3)
+		klines[i] = Kline{
+			OpenTime: int64(i) * 3600_000,
+			Open:     base - 0.5,
+			High:     base + 1.5,
+			Low:      base - 1.5,
+			Close:    base,
+			Volume:   1000 + float64(i%7)*100,
+		}
+	}
+	return klines
+}
+
+func lastValue(t *testing.T, result *IndicatorResult, line string) float64 {
+	t.Helper()
+	for _, l := range result.Lines {
+		if l.Name == line && len(l.Values) > 0 {
+			return l.Values[len(l.Values)-1]
+		}
+	}
+	t.Fatalf("%s 缺少输出 %s", result.Spec.Name, line)
+	return 0
+}
+
+func mustCompute(t *testing.T, klines []Kline, raw string) *IndicatorResult {
+	t.Helper()
+	specs, err := ParseIndicatorSet(raw)
+	if err != nil || len(specs) != 1 {
+		t.Fatalf("解析指标配置失败: %v", err)
+	}
+	result, err := ComputeIndicator(klines, specs[0])
+	if err != nil {
+		t.Fatalf("计算指标失败: %v", err)
+	}
+	return result
+}
+
+func TestIndicators_MatchLegacyCalculations(t *testing.T) {
+	klines := waveKlines(200, 0.2)
+	cases := []struct {
+		raw    string
+		line   string
+		legacy float64
+	}{
+		{`[{"name":"ema","timeframe":"1h","params":{"period":20}}]`, "ema", calculateEMA(klines, 20)},
+		{`[{"name":"rsi","timeframe":"1h","params":{"period":7}}]`, "rsi", calculateRSI(klines, 7)},
+		{`[{"name":"atr","timeframe":"1h"}]`, "atr", calculateATR(klines, 14)},
+		{`[{"name":"macd","timeframe":"1h"}]`, "macd", calculateMACD(klines)},
+	}
+	for _, c := range cases {
+		result := mustCompute(t, klines, c.raw)
+		if got := lastValue(t, result, c.line); math.Abs(got-c.legacy) > 1e-9 {
+			t.Errorf("%s 与原有计算不一致: got %.6f want %.6f", c.raw, got, c.legacy)
+		}
+		if n := len(result.Lines[0].Values); n != defaultIndicatorPoints {
+			t.Errorf("%s 默认应输出 %d 个值，实际 %d", c.raw, defaultIndicatorPoints, n)
+		}
+	}
+}
+
+func TestIndicators_TrendReadings(t *testing.T) {
+	up := waveKlines(300, 1.5)
+
+	if dir := lastValue(t, mustCompute(t, up, `[{"name":"supertrend","timeframe":"1h"}]`), "direction"); dir != 1 {
+		t.Errorf("强上涨趋势 Supertrend 方向应为多头: %v", dir)
+	}
+	adx := mustCompute(t, up, `[{"name":"adx","timeframe":"1h"}]`)
+	if lastValue(t, adx, "plus_di") <= lastValue(t, adx, "minus_di") || lastValue(t, adx, "adx") < 25 {
+		t.Errorf("强上涨趋势应 +DI > -DI 且 ADX > 25: %+v", adx.Lines)
+	}
+	ichimoku := mustCompute(t, up, `[{"name":"ichimoku","timeframe":"1h"}]`)
+	if up[len(up)-1].Close <= math.Max(lastValue(t, ichimoku, "span_a"), lastValue(t, ichimoku, "span_b")) {
+		t.Errorf("强上涨趋势价格应位于云层上方: %+v", ichimoku.Lines)
+	}
+	stoch := mustCompute(t, up, `[{"name":"stoch_rsi","timeframe":"1h"}]`)
+	if k := lastValue(t, stoch, "k"); k < 0 || k > 100 {
+		t.Errorf("Stochastic RSI 应在 0~100 之间: %v", k)
+	}
+}
+
+func TestIndicators_BandsVolumeAndVWAP(t *testing.T) {
+	klines := waveKlines(150, 0)
+
+	boll := mustCompute(t, klines, `[{"name":"bollinger","timeframe":"1h","params":{"period":20,"stddev":2}}]`)
+	upper, middle, lower := lastValue(t, boll, "upper"), lastValue(t, boll, "middle"), lastValue(t, boll, "lower")
+	if !(upper > middle && middle > lower) || math.Abs((upper-middle)-(middle-lower)) > 1e-9 {
+		t.Errorf("布林带上下轨应关于中轨对称: %v %v %v", upper, middle, lower)
+	}
+
+	// 单日内的 VWAP 等于典型价格按成交量加权
+	day := []Kline{
+		{OpenTime: 0, High: 11, Low: 9, Close: 10, Volume: 1},
+		{OpenTime: 3600_000, High: 21, Low: 19, Close: 20, Volume: 3},
+	}
+	if vwap := lastValue(t, mustCompute(t, day, `[{"name":"vwap","timeframe":"1h"}]`), "vwap"); math.Abs(vwap-17.5) > 1e-9 {
+		t.Errorf("VWAP 计算错误: %v", vwap)
+	}
+
+	obv := mustCompute(t, day, `[{"name":"obv","timeframe":"1h"}]`)
+	if got := lastValue(t, obv, "obv"); got != 3 {
+		t.Errorf("OBV 计算错误: %v", got)
+	}
+
+	// 成交量集中在 100 附近
+	profile := make([]Kline, 0, 30)
+	for i := 0; i < 30; i++ {
+		k := Kline{High: 101, Low: 99, Close: 100, Volume: 100}
+		if i%10 == 0 {
+			k = Kline{High: 120, Low: 80, Close: 100, Volume: 1}
+		}
+		profile = append(profile, k)
+	}
+	vp := mustCompute(t, profile, `[{"name":"volume_profile","timeframe":"1h","params":{"bars":30,"bins":40}}]`)
+	poc, vah, val := lastValue(t, vp, "poc"), lastValue(t, vp, "vah"), lastValue(t, vp, "val")
+	if math.Abs(poc-100) > 1 || val > 99.5 || vah < 100.5 || vah-val > 5 {
+		t.Errorf("成交量分布计算错误: poc=%v vah=%v val=%v", poc, vah, val)
+	}
+}
+
+func TestParseIndicatorSet_Validation(t *testing.T) {
+	specs, err := ParseIndicatorSet(`[{"name":"Bollinger","timeframe":"4h","params":{"stddev":2.5},"points":5}]`)
+	if err != nil || len(specs) != 1 || specs[0].Params["period"] != 20 || specs[0].Label() != "Bollinger Bands(20, 2.5)" {
+		t.Fatalf("应补全默认参数: %+v err=%v", specs, err)
+	}
+
+	invalid := []string{
+		`[{"name":"unknown","timeframe":"1h"}]`,
+		`[{"name":"rsi","timeframe":"7m"}]`,
+		`[{"name":"rsi","timeframe":"1h","params":{"period":1}}]`,
+		`[{"name":"rsi","timeframe":"1h","params":{"period":14.5}}]`,
+		`[{"name":"rsi","timeframe":"1h","params":{"length":14}}]`,
+		`[{"name":"rsi","timeframe":"1h","points":100}]`,
+		`[{"name":"rsi","timeframe":"1h"},{"name":"rsi","timeframe":"1h","params":{"period":14}}]`,
+		`{"name":"rsi"}`,
+	}
+	for _, raw := range invalid {
+		if _, err := ParseIndicatorSet(raw); err == nil {
+			t.Errorf("应拒绝无效配置: %s", raw)
+		}
+	}
+
+	if normalized, err := NormalizeIndicatorSet("  "); err != nil || normalized != "" {
+		t.Errorf("空配置应返回空字符串: %q err=%v", normalized, err)
+	}
+}
+
+func TestComputeIndicatorSet_FetchesOncePerTimeframe(t *testing.T) {
+	specs, err := ParseIndicatorSet(`[
+		{"name":"ema","timeframe":"1h","params":{"period":50}},
+		{"name":"rsi","timeframe":"1h"},
+		{"name":"adx","timeframe":"4h"}
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fetches := make(map[string]int)
+	results, err := ComputeIndicatorSet(specs, func(tf string, limit int) ([]Kline, error) {
+		fetches[tf]++
+		if tf == "1h" && limit != 49*3+defaultIndicatorPoints {
+			t.Errorf("1h 应按最长预热期获取K线: limit=%d", limit)
+		}
+		return waveKlines(limit, 0.5), nil
+	})
+	if err != nil || len(results) != 3 || fetches["1h"] != 1 || fetches["4h"] != 1 {
+		t.Fatalf("每个时间周期应只获取一次K线: fetches=%v results=%d err=%v", fetches, len(results), err)
+	}
+
+	text := FormatIndicators(results)
+	for _, want := range []string{"[1h] EMA(50): ema = [", "[1h] RSI(14): rsi = [", "[4h] ADX(14): adx = ["} {
+		if !strings.Contains(text, want) {
+			t.Errorf("提示词缺少 %q:\n%s", want, text)
+		}
+	}
+}
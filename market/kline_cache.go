@@ -0,0 +1,292 @@
+package market
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// BinanceIntervals 币安支持的全部K线周期
+var BinanceIntervals = []string{"1m", "3m", "5m", "15m", "30m", "1h", "2h", "4h", "6h", "8h", "12h", "1d", "3d", "1w", "1M"}
+
+// IsValidInterval 是否为币安支持的K线周期
+func IsValidInterval(interval string) bool {
+	for _, iv := range BinanceIntervals {
+		if iv == interval {
+			return true
+		}
+	}
+	return false
+}
+
+// KlineCacheConfig K线缓存配置
+type KlineCacheConfig struct {
+	HistoryDepth int           // 每个 (币种, 周期) 保留的K线数量
+	MaxSymbols   int           // 最多缓存的币种数，超出后淘汰最久未读取且无引用的币种
+	IdleTTL      time.Duration // 无引用的缓存超过该时长未被读取则淘汰并取消订阅
+}
+
+// DefaultKlineCacheConfig 默认K线缓存配置
+func DefaultKlineCacheConfig() KlineCacheConfig {
+	return KlineCacheConfig{
+		HistoryDepth: 100,
+		MaxSymbols:   SafeMaxSymbols,
+		IdleTTL:      30 * time.Minute,
+	}
+}
+
+// withDefaults 未设置的字段使用默认值
+func (c KlineCacheConfig) withDefaults() KlineCacheConfig {
+	def := DefaultKlineCacheConfig()
+	if c.HistoryDepth <= 0 {
+		c.HistoryDepth = def.HistoryDepth
+	}
+	if c.MaxSymbols <= 0 {
+		c.MaxSymbols = def.MaxSymbols
+	}
+	if c.IdleTTL <= 0 {
+		c.IdleTTL = def.IdleTTL
+	}
+	return c
+}
+
+// klineKey K线缓存键
+type klineKey struct {
+	symbol   string
+	interval string
+}
+
+// stream 对应的币安组合流名称
+func (k klineKey) stream() string {
+	return klineStreamName(k.symbol, k.interval)
+}
+
+// klineSlot 单个 (币种, 周期) 的缓存与订阅状态
+type klineSlot struct {
+	KlineCacheEntry
+	lastAccess time.Time // 最近一次被读取的时间（用于淘汰）
+	refs       int       // 交易员引用计数
+	subscribed bool      // 是否已订阅 WebSocket 流
+}
+
+// klineCache 按 (币种, 周期) 存储K线，零值可用
+type klineCache struct {
+	mu     sync.Mutex
+	config KlineCacheConfig
+	slots  map[klineKey]*klineSlot
+}
+
+// configure 设置缓存配置
+func (c *klineCache) configure(config KlineCacheConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.config = config.withDefaults()
+}
+
+// slot 获取或创建缓存槽（调用方持锁）
+func (c *klineCache) slot(key klineKey, now time.Time) *klineSlot {
+	if c.slots == nil {
+		c.slots = make(map[klineKey]*klineSlot)
+	}
+	s, ok := c.slots[key]
+	if !ok {
+		s = &klineSlot{lastAccess: now}
+		c.slots[key] = s
+	}
+	return s
+}
+
+// depth 历史深度（调用方持锁）
+func (c *klineCache) depth() int {
+	if c.config.HistoryDepth <= 0 {
+		return DefaultKlineCacheConfig().HistoryDepth
+	}
+	return c.config.HistoryDepth
+}
+
+// get 读取K线副本（没有数据时返回 false）
+func (c *klineCache) get(symbol, interval string) (KlineCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.slots[klineKey{symbol, interval}]
+	if !ok || len(s.Klines) == 0 {
+		return KlineCacheEntry{}, false
+	}
+	s.lastAccess = time.Now()
+
+	klines := make([]Kline, len(s.Klines))
+	copy(klines, s.Klines)
+	return KlineCacheEntry{Klines: klines, ReceivedAt: s.ReceivedAt}, true
+}
+
+// store 用完整历史替换缓存
+func (c *klineCache) store(symbol, interval string, klines []Kline, receivedAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if depth := c.depth(); len(klines) > depth {
+		klines = klines[len(klines)-depth:]
+	}
+	s := c.slot(klineKey{symbol, interval}, receivedAt)
+	s.Klines = append([]Kline(nil), klines...)
+	s.ReceivedAt = receivedAt
+}
+
+// update 合并一根推送的K线（没有历史数据时忽略，等待历史加载）
+func (c *klineCache) update(symbol, interval string, kline Kline, receivedAt time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.slots[klineKey{symbol, interval}]
+	if !ok || len(s.Klines) == 0 {
+		return false
+	}
+	if last := len(s.Klines) - 1; s.Klines[last].OpenTime == kline.OpenTime {
+		s.Klines[last] = kline
+	} else {
+		s.Klines = append(s.Klines, kline)
+		if depth := c.depth(); len(s.Klines) > depth {
+			s.Klines = append([]Kline(nil), s.Klines[len(s.Klines)-depth:]...)
+		}
+	}
+	s.ReceivedAt = receivedAt
+	return true
+}
+
+// acquire 引用计数+1，返回是否有历史数据
+func (c *klineCache) acquire(key klineKey) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.slot(key, time.Now())
+	s.refs++
+	return len(s.Klines) > 0
+}
+
+// release 引用计数-1
+func (c *klineCache) release(key klineKey) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if s, ok := c.slots[key]; ok && s.refs > 0 {
+		s.refs--
+	}
+}
+
+// markSubscribed 标记已订阅，返回 true 表示本次需要发起订阅
+func (c *klineCache) markSubscribed(key klineKey) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := c.slot(key, time.Now())
+	if s.subscribed {
+		return false
+	}
+	s.subscribed = true
+	return true
+}
+
+// refs 获取引用计数
+func (c *klineCache) refs(key klineKey) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if s, ok := c.slots[key]; ok {
+		return s.refs
+	}
+	return 0
+}
+
+// evict 淘汰无引用的缓存，返回需要取消订阅的键
+// 1. 无引用且超过 IdleTTL 未读取的槽位
+// 2. 币种数超过 MaxSymbols 时，按最近读取时间淘汰无引用的币种
+func (c *klineCache) evict(now time.Time) []klineKey {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	config := c.config.withDefaults()
+	var unsubscribe []klineKey
+	remove := func(key klineKey) {
+		if c.slots[key].subscribed {
+			unsubscribe = append(unsubscribe, key)
+		}
+		delete(c.slots, key)
+	}
+
+	for key, s := range c.slots {
+		if s.refs == 0 && now.Sub(s.lastAccess) > config.IdleTTL {
+			remove(key)
+		}
+	}
+
+	// 按币种汇总引用与最近读取时间
+	type symbolUsage struct {
+		lastAccess time.Time
+		refs       int
+		keys       []klineKey
+	}
+	usage := make(map[string]*symbolUsage)
+	for key, s := range c.slots {
+		u, ok := usage[key.symbol]
+		if !ok {
+			u = &symbolUsage{}
+			usage[key.symbol] = u
+		}
+		u.refs += s.refs
+		u.keys = append(u.keys, key)
+		if s.lastAccess.After(u.lastAccess) {
+			u.lastAccess = s.lastAccess
+		}
+	}
+	if len(usage) <= config.MaxSymbols {
+		return unsubscribe
+	}
+
+	candidates := make([]string, 0, len(usage))
+	for symbol, u := range usage {
+		if u.refs == 0 {
+			candidates = append(candidates, symbol)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return usage[candidates[i]].lastAccess.Before(usage[candidates[j]].lastAccess)
+	})
+	excess := len(usage) - config.MaxSymbols
+	for _, symbol := range candidates {
+		if excess <= 0 {
+			break
+		}
+		for _, key := range usage[symbol].keys {
+			remove(key)
+		}
+		excess--
+	}
+	return unsubscribe
+}
+
+// KlineCacheStats K线缓存统计
+type KlineCacheStats struct {
+	Symbols    int `json:"symbols"`    // 缓存的币种数
+	Series     int `json:"series"`     // 缓存的 (币种, 周期) 数
+	Subscribed int `json:"subscribed"` // 已订阅的 WebSocket 流数
+	Klines     int `json:"klines"`     // 缓存的K线总数
+}
+
+// stats 获取缓存统计
+func (c *klineCache) stats() KlineCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	symbols := make(map[string]bool)
+	stats := KlineCacheStats{Series: len(c.slots)}
+	for key, s := range c.slots {
+		symbols[key.symbol] = true
+		stats.Klines += len(s.Klines)
+		if s.subscribed {
+			stats.Subscribed++
+		}
+	}
+	stats.Symbols = len(symbols)
+	return stats
+}
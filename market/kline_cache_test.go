@@ -0,0 +1,159 @@
+package market
+
+import (
+	"testing"
+	"time"
+)
+
+func testKlines(n int) []Kline {
+	klines := make([]Kline, n)
+	for i := range klines {
+		klines[i] = Kline{OpenTime: int64(i), Close: float64(100 + i)}
+	}
+	return klines
+}
+
+func TestKlineCache_DepthAndUpdate(t *testing.T) {
+	var cache klineCache
+	cache.configure(KlineCacheConfig{HistoryDepth: 5})
+
+	// 任意币安周期均可缓存
+	cache.store("BTCUSDT", "6h", testKlines(8), time.Now())
+	entry, ok := cache.get("BTCUSDT", "6h")
+	if !ok || len(entry.Klines) != 5 || entry.Klines[0].OpenTime != 3 {
+		t.Fatalf("应只保留最近5根K线: %+v", entry.Klines)
+	}
+
+	// 同一根K线更新，新K线追加并保持深度
+	cache.update("BTCUSDT", "6h", Kline{OpenTime: 7, Close: 200}, time.Now())
+	cache.update("BTCUSDT", "6h", Kline{OpenTime: 8, Close: 201}, time.Now())
+	entry, _ = cache.get("BTCUSDT", "6h")
+	if len(entry.Klines) != 5 || entry.Klines[3].Close != 200 || entry.Klines[4].Close != 201 {
+		t.Errorf("K线合并错误: %+v", entry.Klines)
+	}
+
+	// 返回的是副本
+	entry.Klines[0].Close = -1
+	if again, _ := cache.get("BTCUSDT", "6h"); again.Klines[0].Close == -1 {
+		t.Error("get 应返回副本")
+	}
+
+	// 没有历史时忽略推送
+	if cache.update("ETHUSDT", "12h", Kline{OpenTime: 1}, time.Now()) {
+		t.Error("无历史数据时不应写入单根K线")
+	}
+	if _, ok := cache.get("ETHUSDT", "12h"); ok {
+		t.Error("无历史数据时不应返回缓存")
+	}
+}
+
+func TestKlineCache_EvictIdleUnreferenced(t *testing.T) {
+	var cache klineCache
+	cache.configure(KlineCacheConfig{IdleTTL: time.Minute})
+
+	now := time.Now()
+	cache.store("BTCUSDT", "5m", testKlines(3), now)
+	cache.store("ETHUSDT", "5m", testKlines(3), now)
+	cache.markSubscribed(klineKey{"ETHUSDT", "5m"})
+	cache.acquire(klineKey{"BTCUSDT", "5m"})
+
+	evicted := cache.evict(now.Add(2 * time.Minute))
+	if len(evicted) != 1 || evicted[0] != (klineKey{"ETHUSDT", "5m"}) {
+		t.Errorf("应淘汰并取消订阅无引用的闲置缓存: %v", evicted)
+	}
+	if _, ok := cache.get("BTCUSDT", "5m"); !ok {
+		t.Error("有引用的缓存不应被淘汰")
+	}
+
+	cache.release(klineKey{"BTCUSDT", "5m"})
+	cache.evict(time.Now().Add(30 * time.Second)) // 刚被读取，未闲置
+	if _, ok := cache.get("BTCUSDT", "5m"); !ok {
+		t.Error("最近读取过的缓存不应被淘汰")
+	}
+	cache.evict(time.Now().Add(2 * time.Minute))
+	if stats := cache.stats(); stats.Series != 0 {
+		t.Errorf("释放引用后闲置缓存应被淘汰: %+v", stats)
+	}
+}
+
+func TestKlineCache_EvictMaxSymbols(t *testing.T) {
+	var cache klineCache
+	cache.configure(KlineCacheConfig{MaxSymbols: 2, IdleTTL: time.Hour})
+
+	base := time.Now()
+	for i, symbol := range []string{"AUSDT", "BUSDT", "CUSDT", "DUSDT"} {
+		cache.store(symbol, "1h", testKlines(2), base)
+		cache.store(symbol, "4h", testKlines(2), base)
+		cache.slots[klineKey{symbol, "1h"}].lastAccess = base.Add(time.Duration(i) * time.Second)
+	}
+	cache.acquire(klineKey{"AUSDT", "4h"}) // 最久未读取但有引用
+
+	cache.evict(base.Add(10 * time.Second))
+	stats := cache.stats()
+	if stats.Symbols != 2 || stats.Series != 4 {
+		t.Fatalf("应淘汰到最多2个币种: %+v", stats)
+	}
+	for _, symbol := range []string{"AUSDT", "DUSDT"} {
+		if _, ok := cache.get(symbol, "1h"); !ok {
+			t.Errorf("%s 应保留（有引用或最近使用）", symbol)
+		}
+	}
+}
+
+func TestKlineLease_SharedRefCounts(t *testing.T) {
+	original := WSMonitorCli
+	defer func() { WSMonitorCli = original }()
+
+	monitor := &WSMonitor{}
+	WSMonitorCli = monitor
+	for _, interval := range []string{"5m", "2h", "1w"} {
+		monitor.klines.store("BTCUSDT", interval, testKlines(3), time.Now())
+	}
+
+	lease1 := NewKlineLease()
+	lease2 := NewKlineLease()
+	lease1.Update([]string{"btc"}, []string{"5m", "2h", "7m"})
+	lease2.Update([]string{"BTCUSDT"}, []string{"2h", "1w"})
+
+	if lease1.Size() != 2 || lease2.Size() != 2 {
+		t.Fatalf("不支持的周期应被忽略: %d %d", lease1.Size(), lease2.Size())
+	}
+	if refs := monitor.klines.refs(klineKey{"BTCUSDT", "2h"}); refs != 2 {
+		t.Errorf("两个交易员应共享同一订阅: refs=%d", refs)
+	}
+
+	lease1.Update([]string{"BTCUSDT"}, []string{"5m"})
+	if refs := monitor.klines.refs(klineKey{"BTCUSDT", "2h"}); refs != 1 {
+		t.Errorf("移除的周期应释放引用: refs=%d", refs)
+	}
+
+	lease2.Release()
+	if refs := monitor.klines.refs(klineKey{"BTCUSDT", "2h"}); refs != 0 || lease2.Size() != 0 {
+		t.Errorf("Release 应释放全部引用: refs=%d", refs)
+	}
+	if refs := monitor.klines.refs(klineKey{"BTCUSDT", "5m"}); refs != 1 {
+		t.Errorf("其他交易员的引用不受影响: refs=%d", refs)
+	}
+}
+
+func TestWSMonitor_ProcessKlineUpdate_AnyInterval(t *testing.T) {
+	monitor := &WSMonitor{}
+	monitor.klines.store("BTCUSDT", "30m", testKlines(2), time.Now().Add(-time.Hour))
+
+	var ws KlineWSData
+	ws.Kline.StartTime = 5
+	ws.Kline.ClosePrice = "123.5"
+	monitor.processKlineUpdate("BTCUSDT", ws, "30m")
+
+	klines, receivedAt, ok := monitor.GetCachedKlines("btcusdt", "30m")
+	if !ok || len(klines) != 3 || klines[2].Close != 123.5 || time.Since(receivedAt) > time.Minute {
+		t.Errorf("30m 推送应合并到缓存: %+v", klines)
+	}
+	if monitor.LastMessageAt().IsZero() {
+		t.Error("推送应更新最近消息时间")
+	}
+
+	if _, err := monitor.GetCurrentKlines("BTCUSDT", "7m"); err == nil {
+		t.Error("不支持的周期应返回错误")
+	}
+}
@@ -0,0 +1,97 @@
+package market
+
+import (
+	"log"
+	"sync"
+)
+
+// KlineLease 交易员持有的K线订阅集合
+// 按 (币种, 周期) 引用计数，多个交易员使用不同时间线时共享同一订阅；交易员停止后释放
+type KlineLease struct {
+	mu   sync.Mutex
+	keys map[klineKey]bool
+}
+
+// NewKlineLease 创建K线订阅集合
+func NewKlineLease() *KlineLease {
+	return &KlineLease{keys: make(map[klineKey]bool)}
+}
+
+// Update 将订阅集合更新为 symbols × intervals：新增的引用+1（按需加载历史并订阅），移除的引用-1
+// WebSocket 监控未启动时不做任何操作
+func (l *KlineLease) Update(symbols, intervals []string) {
+	monitor := WSMonitorCli
+	if monitor == nil {
+		return
+	}
+
+	wanted := make(map[klineKey]bool, len(symbols)*len(intervals))
+	for _, symbol := range symbols {
+		for _, interval := range intervals {
+			if !IsValidInterval(interval) {
+				continue
+			}
+			wanted[klineKey{Normalize(symbol), interval}] = true
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var added []klineKey
+	for key := range wanted {
+		if !l.keys[key] {
+			added = append(added, key)
+		}
+	}
+	for key := range l.keys {
+		if !wanted[key] {
+			monitor.ReleaseKlines(key.symbol, key.interval)
+			delete(l.keys, key)
+		}
+	}
+
+	// 并发加载新增的K线历史（限制并发数）
+	var wg sync.WaitGroup
+	var keysMu sync.Mutex
+	semaphore := make(chan struct{}, 5)
+	for _, key := range added {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(key klineKey) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			if err := monitor.AcquireKlines(key.symbol, key.interval); err != nil {
+				log.Printf("⚠️  %v", err)
+				return
+			}
+			keysMu.Lock()
+			l.keys[key] = true
+			keysMu.Unlock()
+		}(key)
+	}
+	wg.Wait()
+}
+
+// Release 释放全部引用
+func (l *KlineLease) Release() {
+	monitor := WSMonitorCli
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for key := range l.keys {
+		if monitor != nil {
+			monitor.ReleaseKlines(key.symbol, key.interval)
+		}
+		delete(l.keys, key)
+	}
+}
+
+// Size 当前持有的 (币种, 周期) 数量
+func (l *KlineLease) Size() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.keys)
+}
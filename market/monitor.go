@@ -6,6 +6,7 @@ import (
 	"log"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -24,25 +25,22 @@ type KlineCacheEntry struct {
 }
 
 type WSMonitor struct {
-	wsClient        *WSClient
-	combinedClient  *CombinedStreamsClient
-	symbols         []string
-	timeframes      []string // 动态配置的时间线
-	featuresMap     sync.Map
-	alertsChan      chan Alert
-	klineDataMap1m  sync.Map      // 存储每个交易对的1分钟K线历史数据 (KlineCacheEntry)
-	klineDataMap3m  sync.Map      // 存储每个交易对的3分钟K线历史数据 (KlineCacheEntry)
-	klineDataMap15m sync.Map      // 存储每个交易对的15分钟K线历史数据 (KlineCacheEntry)
-	klineDataMap1h  sync.Map      // 存储每个交易对的1小时K线历史数据 (KlineCacheEntry)
-	klineDataMap4h  sync.Map      // 存储每个交易对的4小时K线历史数据 (KlineCacheEntry)
-	klineDataMap1d  sync.Map      // 存储每个交易对的日线K线历史数据 (KlineCacheEntry)
-	tickerDataMap   sync.Map      // 存储每个交易对的ticker数据
-	oiHistoryMap    sync.Map      // P0修复：存储OI历史数据 map[symbol][]OISnapshot
-	oiStopChan      chan struct{} // P0修复：OI监控停止信号通道
-	batchSize       int
-	filterSymbols   sync.Map // 使用sync.Map来存储需要监控的币种和其状态
-	symbolStats     sync.Map // 存储币种统计信息
-	FilterSymbol    []string //经过筛选的币种
+	wsClient       *WSClient
+	combinedClient *CombinedStreamsClient
+	symbols        []string
+	timeframes     []string // 动态配置的时间线
+	featuresMap    sync.Map
+	alertsChan     chan Alert
+	klines         klineCache    // 按 (币种, 周期) 存储K线历史数据，支持任意币安周期
+	tickerDataMap  sync.Map      // 存储每个交易对的ticker数据
+	oiHistoryMap   sync.Map      // P0修复：存储OI历史数据 map[symbol][]OISnapshot
+	oiStopChan     chan struct{} // P0修复：OI监控停止信号通道
+	batchSize      int
+	filterSymbols  sync.Map      // 使用sync.Map来存储需要监控的币种和其状态
+	symbolStats    sync.Map      // 存储币种统计信息
+	FilterSymbol   []string      //经过筛选的币种
+	lastMessageAt  atomic.Int64  // 最近一次收到K线推送的时间（UnixNano），用于数据源健康检查
+	cacheStopChan  chan struct{} // K线缓存淘汰停止信号
 }
 type SymbolStats struct {
 	LastActiveTime   time.Time
@@ -55,6 +53,22 @@ type SymbolStats struct {
 var WSMonitorCli *WSMonitor
 
 func NewWSMonitor(batchSize int, timeframes []string) *WSMonitor {
+	return NewWSMonitorWithConfig(batchSize, timeframes, DefaultKlineCacheConfig())
+}
+
+// NewWSMonitorWithConfig 创建WebSocket监控器（可配置K线缓存深度与容量）
+func NewWSMonitorWithConfig(batchSize int, timeframes []string, cacheConfig KlineCacheConfig) *WSMonitor {
+	// 过滤币安不支持的时间线
+	valid := make([]string, 0, len(timeframes))
+	for _, tf := range timeframes {
+		if IsValidInterval(tf) {
+			valid = append(valid, tf)
+		} else {
+			log.Printf("⚠️  忽略不支持的时间线: %s", tf)
+		}
+	}
+	timeframes = valid
+
 	// 如果没有指定时间线，使用默认值
 	if len(timeframes) == 0 {
 		timeframes = []string{"15m", "1h", "4h"}
@@ -66,8 +80,12 @@ func NewWSMonitor(batchSize int, timeframes []string) *WSMonitor {
 		alertsChan:     make(chan Alert, 1000),
 		batchSize:      batchSize,
 		timeframes:     timeframes,
+		cacheStopChan:  make(chan struct{}),
 	}
-	log.Printf("📊 WSMonitor 初始化，使用时间线: %v", timeframes)
+	WSMonitorCli.klines.configure(cacheConfig)
+	cacheConfig = cacheConfig.withDefaults()
+	log.Printf("📊 WSMonitor 初始化，使用时间线: %v（K线深度 %d，最多缓存 %d 个币种）",
+		timeframes, cacheConfig.HistoryDepth, cacheConfig.MaxSymbols)
 	return WSMonitorCli
 }
 
@@ -148,12 +166,6 @@ func (m *WSMonitor) initializeHistoricalData() error {
 
 			// 动态加载配置的时间线
 			for _, tf := range m.timeframes {
-				klineDataMap := m.getKlineDataMap(tf)
-				if klineDataMap == nil {
-					log.Printf("⚠️  未知的时间线: %s", tf)
-					continue
-				}
-
 				// 对 4h 使用重试机制（P0修复）
 				var klines []Kline
 				var err error
@@ -163,7 +175,7 @@ func (m *WSMonitor) initializeHistoricalData() error {
 				}
 
 				for retry := 0; retry < maxRetries; retry++ {
-					klines, err = apiClient.GetKlines(s, tf, 100)
+					klines, err = loadHistoricalKlines(apiClient, s, tf, m.historyDepth())
 					if err == nil && len(klines) > 0 {
 						break
 					}
@@ -180,15 +192,15 @@ func (m *WSMonitor) initializeHistoricalData() error {
 						log.Printf("获取 %s %s历史数据失败: %v", s, tf, err)
 					}
 				} else if len(klines) > 0 {
-					klineDataMap.Store(s, klines)
+					m.klines.store(strings.ToUpper(s), tf, klines, time.Now())
 					log.Printf("✅ 已加载 %s 的历史K线数据-%s: %d 条", s, tf, len(klines))
 				} else {
 					log.Printf("⚠️  WARNING: %s %s数据为空（API返回成功但无数据）", s, tf)
 				}
 			}
 
-			// 🚀 优化：回填历史OI数据（15分钟粒度，最近20个数据点 = 5小时）
-			oiHistory, err := apiClient.GetOpenInterestHistory(s, "15m", 20)
+			// 🚀 优化：回填历史OI数据（15分钟粒度，最近20个数据点 = 5小时；优先使用本地存储，仅回填停机期间的缺口）
+			oiHistory, err := loadOIHistory(apiClient, s)
 			normalizedSymbol := strings.ToUpper(s)
 
 			if err != nil || len(oiHistory) == 0 {
@@ -250,23 +262,55 @@ func (m *WSMonitor) Start(coins []string) {
 
 	// P0修复：启动OI定期监控（每15分钟采样，用于计算4小时变化率）
 	m.StartOIMonitoring()
+
+	// 定期淘汰无引用的K线缓存并取消订阅
+	go m.runKlineCacheEviction()
+}
+
+// klineStreamName K线组合流名称
+func klineStreamName(symbol, interval string) string {
+	return fmt.Sprintf("%s@kline_%s", strings.ToLower(symbol), interval)
+}
+
+// historyDepth 每个 (币种, 周期) 保留的K线数量
+func (m *WSMonitor) historyDepth() int {
+	m.klines.mu.Lock()
+	defer m.klines.mu.Unlock()
+	return m.klines.depth()
 }
 
 // subscribeSymbol 注册监听
 func (m *WSMonitor) subscribeSymbol(symbol, st string) []string {
 	var streams []string
-	stream := fmt.Sprintf("%s@kline_%s", strings.ToLower(symbol), st)
+	stream := klineStreamName(symbol, st)
 	ch := m.combinedClient.AddSubscriber(stream, 100)
 	streams = append(streams, stream)
-	go m.handleKlineData(symbol, ch, st)
+	go m.handleKlineData(strings.ToUpper(symbol), ch, st)
 
 	return streams
 }
+
+// ensureSubscribed 订阅 (币种, 周期) 的K线流（已订阅时不重复订阅）
+func (m *WSMonitor) ensureSubscribed(symbol, interval string) {
+	if m.combinedClient == nil || !m.klines.markSubscribed(klineKey{symbol, interval}) {
+		return
+	}
+	subStr := m.subscribeSymbol(symbol, interval)
+	log.Printf("动态订阅流: %v", subStr)
+	if err := m.combinedClient.subscribeStreams(subStr); err != nil {
+		log.Printf("警告: 动态订阅%v K线失败: %v (使用API数据)", interval, err)
+	}
+}
+
 func (m *WSMonitor) subscribeAll() error {
 	log.Println("开始订阅所有交易对...")
 
+	// 启动时配置的币种与时间线常驻缓存（持有一个引用，不会被淘汰）
 	for _, symbol := range m.symbols {
 		for _, st := range m.timeframes {
+			key := klineKey{strings.ToUpper(symbol), st}
+			m.klines.acquire(key)
+			m.klines.markSubscribed(key)
 			m.subscribeSymbol(symbol, st)
 		}
 	}
@@ -294,26 +338,6 @@ func (m *WSMonitor) handleKlineData(symbol string, ch <-chan []byte, _time strin
 	}
 }
 
-func (m *WSMonitor) getKlineDataMap(_time string) *sync.Map {
-	var klineDataMap *sync.Map
-	switch _time {
-	case "1m":
-		klineDataMap = &m.klineDataMap1m
-	case "3m":
-		klineDataMap = &m.klineDataMap3m
-	case "15m":
-		klineDataMap = &m.klineDataMap15m
-	case "1h":
-		klineDataMap = &m.klineDataMap1h
-	case "4h":
-		klineDataMap = &m.klineDataMap4h
-	case "1d":
-		klineDataMap = &m.klineDataMap1d
-	default:
-		klineDataMap = &sync.Map{}
-	}
-	return klineDataMap
-}
 func (m *WSMonitor) processKlineUpdate(symbol string, wsData KlineWSData, _time string) {
 	// 转换WebSocket数据为Kline结构
 	kline := Kline{
@@ -326,76 +350,46 @@ func (m *WSMonitor) processKlineUpdate(symbol string, wsData KlineWSData, _time
 	kline.Low, _ = parseFloat(wsData.Kline.LowPrice)
 	kline.Close, _ = parseFloat(wsData.Kline.ClosePrice)
 	kline.Volume, _ = parseFloat(wsData.Kline.Volume)
-	kline.High, _ = parseFloat(wsData.Kline.HighPrice)
 	kline.QuoteVolume, _ = parseFloat(wsData.Kline.QuoteVolume)
 	kline.TakerBuyBaseVolume, _ = parseFloat(wsData.Kline.TakerBuyBaseVolume)
 	kline.TakerBuyQuoteVolume, _ = parseFloat(wsData.Kline.TakerBuyQuoteVolume)
-	// 更新K线数据
-	var klineDataMap = m.getKlineDataMap(_time)
-	value, exists := klineDataMap.Load(symbol)
-	var klines []Kline
-	if exists {
-		// 从缓存条目中提取K线数据
-		if entry, ok := value.(*KlineCacheEntry); ok {
-			klines = entry.Klines
-		} else {
-			// 兼容旧格式（直接存储[]Kline）
-			klines = value.([]Kline)
-		}
 
-		// 检查是否是新的K线
-		if len(klines) > 0 && klines[len(klines)-1].OpenTime == kline.OpenTime {
-			// 更新当前K线
-			klines[len(klines)-1] = kline
-		} else {
-			// 添加新K线
-			klines = append(klines, kline)
-
-			// 保持数据长度
-			if len(klines) > 100 {
-				klines = klines[1:]
-			}
-		}
-	} else {
-		klines = []Kline{kline}
+	// 更新K线数据（尚未加载历史的K线流忽略推送，避免缓存中只有单根K线）
+	now := time.Now()
+	if m.klines.update(symbol, _time, kline, now) {
+		m.lastMessageAt.Store(now.UnixNano())
 	}
 
-	// 存储为KlineCacheEntry，包含接收时间
-	cacheEntry := &KlineCacheEntry{
-		Klines:     klines,
-		ReceivedAt: time.Now(),
+	// 已收盘的K线写入本地历史存储
+	if store := GetHistoryStore(); store != nil && wsData.Kline.IsFinal {
+		if err := store.SaveKlines(symbol, _time, []Kline{kline}); err != nil {
+			log.Printf("⚠️  保存 %s %s K线失败: %v", symbol, _time, err)
+		}
 	}
-	klineDataMap.Store(symbol, cacheEntry)
 }
 
 func (m *WSMonitor) GetCurrentKlines(symbol string, duration string) ([]Kline, error) {
 	// 数据过期阈值：15分钟（超过此时间认为WebSocket数据已过期）
 	const maxDataAge = 15 * time.Minute
 
+	symbol = strings.ToUpper(symbol)
+	if !IsValidInterval(duration) {
+		return nil, fmt.Errorf("不支持的K线周期: %s", duration)
+	}
+
 	// 对每一个进来的symbol检测是否存在内类 是否的话就订阅它
-	value, exists := m.getKlineDataMap(duration).Load(symbol)
+	entry, exists := m.klines.get(symbol, duration)
 	if !exists {
 		// 如果Ws数据未初始化完成时,单独使用api获取 - 兼容性代码 (防止在未初始化完成是,已经有交易员运行)
 		apiClient := NewAPIClient()
-		klines, err := apiClient.GetKlines(symbol, duration, 100)
+		klines, err := loadHistoricalKlines(apiClient, symbol, duration, m.historyDepth())
 		if err != nil {
 			return nil, fmt.Errorf("获取%v分钟K线失败: %v", duration, err)
 		}
 
-		// 动态缓存进缓存（包含时间戳）
-		cacheEntry := &KlineCacheEntry{
-			Klines:     klines,
-			ReceivedAt: time.Now(),
-		}
-		m.getKlineDataMap(duration).Store(strings.ToUpper(symbol), cacheEntry)
-
-		// 订阅 WebSocket 流
-		subStr := m.subscribeSymbol(symbol, duration)
-		subErr := m.combinedClient.subscribeStreams(subStr)
-		log.Printf("动态订阅流: %v", subStr)
-		if subErr != nil {
-			log.Printf("警告: 动态订阅%v分钟K线失败: %v (使用API数据)", duration, subErr)
-		}
+		// 动态缓存进缓存（包含时间戳）并订阅 WebSocket 流
+		m.klines.store(symbol, duration, klines, time.Now())
+		m.ensureSubscribed(symbol, duration)
 
 		// ✅ FIX: 返回深拷贝而非引用
 		result := make([]Kline, len(klines))
@@ -403,43 +397,23 @@ func (m *WSMonitor) GetCurrentKlines(symbol string, duration string) ([]Kline, e
 		return result, nil
 	}
 
-	// 提取K线数据和接收时间
-	var klines []Kline
-	var receivedAt time.Time
-
-	if entry, ok := value.(*KlineCacheEntry); ok {
-		klines = entry.Klines
-		receivedAt = entry.ReceivedAt
-	} else {
-		// 兼容旧格式（直接存储[]Kline，无时间戳）
-		klines = value.([]Kline)
-		// 旧数据视为刚接收（避免误报）
-		receivedAt = time.Now()
-	}
-
 	// ✅ 数据时效性检测：如果数据超过15分钟未更新，回退到API获取
-	dataAge := time.Since(receivedAt)
+	dataAge := time.Since(entry.ReceivedAt)
 	if dataAge > maxDataAge {
-		log.Printf("⚠️  %s %s K线数据已过期 (%.1f分钟未更新)，回退到API获取", 
+		log.Printf("⚠️  %s %s K线数据已过期 (%.1f分钟未更新)，回退到API获取",
 			symbol, duration, dataAge.Minutes())
 
 		// 回退到API获取新数据
 		apiClient := NewAPIClient()
-		freshKlines, err := apiClient.GetKlines(symbol, duration, 100)
+		freshKlines, err := loadHistoricalKlines(apiClient, symbol, duration, m.historyDepth())
 		if err != nil {
 			// API也失败，返回过期数据并警告
 			log.Printf("❌ API获取失败: %v，使用过期缓存数据", err)
-			result := make([]Kline, len(klines))
-			copy(result, klines)
-			return result, fmt.Errorf("WebSocket数据过期且API获取失败: %v", err)
+			return entry.Klines, fmt.Errorf("WebSocket数据过期且API获取失败: %v", err)
 		}
 
 		// 更新缓存
-		cacheEntry := &KlineCacheEntry{
-			Klines:     freshKlines,
-			ReceivedAt: time.Now(),
-		}
-		m.getKlineDataMap(duration).Store(symbol, cacheEntry)
+		m.klines.store(symbol, duration, freshKlines, time.Now())
 
 		log.Printf("✓ %s %s K线数据已从API刷新", symbol, duration)
 
@@ -448,10 +422,91 @@ func (m *WSMonitor) GetCurrentKlines(symbol string, duration string) ([]Kline, e
 		return result, nil
 	}
 
-	// ✅ FIX: 返回深拷贝而非引用，避免并发竞态条件
-	result := make([]Kline, len(klines))
-	copy(result, klines)
-	return result, nil
+	// ✅ FIX: get 返回的已是深拷贝，避免并发竞态条件
+	return entry.Klines, nil
+}
+
+// GetCachedKlines 仅从WebSocket缓存读取K线（不回退API），返回数据及接收时间
+func (m *WSMonitor) GetCachedKlines(symbol string, duration string) ([]Kline, time.Time, bool) {
+	entry, ok := m.klines.get(strings.ToUpper(symbol), duration)
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	return entry.Klines, entry.ReceivedAt, true
+}
+
+// AcquireKlines 为 (币种, 周期) 增加引用并确保已加载历史与订阅（多个交易员共享同一订阅）
+func (m *WSMonitor) AcquireKlines(symbol, interval string) error {
+	symbol = strings.ToUpper(symbol)
+	if !IsValidInterval(interval) {
+		return fmt.Errorf("不支持的K线周期: %s", interval)
+	}
+
+	if !m.klines.acquire(klineKey{symbol, interval}) {
+		klines, err := loadHistoricalKlines(NewAPIClient(), symbol, interval, m.historyDepth())
+		if err != nil {
+			m.klines.release(klineKey{symbol, interval})
+			return fmt.Errorf("加载%s %s历史K线失败: %w", symbol, interval, err)
+		}
+		m.klines.store(symbol, interval, klines, time.Now())
+	}
+	m.ensureSubscribed(symbol, interval)
+	return nil
+}
+
+// ReleaseKlines 释放 (币种, 周期) 的引用（引用为0且长时间未读取后由淘汰流程取消订阅）
+func (m *WSMonitor) ReleaseKlines(symbol, interval string) {
+	m.klines.release(klineKey{strings.ToUpper(symbol), interval})
+}
+
+// KlineCacheStats 获取K线缓存统计
+func (m *WSMonitor) KlineCacheStats() KlineCacheStats {
+	return m.klines.stats()
+}
+
+// evictKlineCache 淘汰无引用的K线缓存并取消对应订阅
+func (m *WSMonitor) evictKlineCache(now time.Time) {
+	keys := m.klines.evict(now)
+	if len(keys) == 0 {
+		return
+	}
+
+	streams := make([]string, len(keys))
+	for i, key := range keys {
+		streams[i] = key.stream()
+	}
+	if m.combinedClient != nil {
+		for _, stream := range streams {
+			m.combinedClient.RemoveSubscriber(stream)
+		}
+		if err := m.combinedClient.unsubscribeStreams(streams); err != nil {
+			log.Printf("⚠️  取消订阅K线流失败: %v", err)
+		}
+	}
+	log.Printf("🧹 已淘汰 %d 个闲置K线缓存并取消订阅", len(keys))
+}
+
+// runKlineCacheEviction 每分钟执行一次K线缓存淘汰
+func (m *WSMonitor) runKlineCacheEviction() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			m.evictKlineCache(now)
+		case <-m.cacheStopChan:
+			return
+		}
+	}
+}
+
+// LastMessageAt 最近一次收到K线推送的时间
+func (m *WSMonitor) LastMessageAt() time.Time {
+	ns := m.lastMessageAt.Load()
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
 }
 
 func (m *WSMonitor) Close() {
@@ -459,6 +514,9 @@ func (m *WSMonitor) Close() {
 	if m.oiStopChan != nil {
 		close(m.oiStopChan)
 	}
+	if m.cacheStopChan != nil {
+		close(m.cacheStopChan)
+	}
 
 	m.wsClient.Close()
 	close(m.alertsChan)
@@ -497,6 +555,13 @@ func (m *WSMonitor) StoreOISnapshot(symbol string, oiValue float64) {
 
 	m.oiHistoryMap.Store(symbol, history)
 
+	// 持久化到本地历史存储（重启后无需重新回填）
+	if store := GetHistoryStore(); store != nil {
+		if err := store.SaveOISnapshots(symbol, []OISnapshot{snapshot}); err != nil {
+			log.Printf("⚠️  保存 %s OI快照失败: %v", symbol, err)
+		}
+	}
+
 	// 診斷日誌（僅前3次採集時輸出）
 	if len(history) <= 3 {
 		log.Printf("📝 [OI存儲] Symbol: %s, OI: %.0f, 历史数据点数: %d", symbol, oiValue, len(history))
@@ -526,7 +591,7 @@ func (m *WSMonitor) CalculateOIChange4h(symbol string, latestOI float64) (float6
 		// ✅ P0修复：歷史數據為空時，嘗試從 API 回填（降級方案）
 		log.Printf("⚠️  %s: OI历史数据为空，尝试从API回填历史数据...", symbol)
 		apiClient := NewAPIClient()
-		historyFromAPI, err := apiClient.GetOpenInterestHistory(symbol, "15m", 20) // 获取20个15分钟数据点（5小时）
+		historyFromAPI, err := loadOIHistory(apiClient, symbol) // 获取20个15分钟数据点（5小时）
 		if err != nil {
 			log.Printf("❌ %s: 从API回填OI历史数据失败: %v，无法计算变化率", symbol, err)
 			return 0.0, "N/A" // API回填也失败，无法计算
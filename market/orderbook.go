@@ -0,0 +1,306 @@
+package market
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// errOrderBookGap 增量深度事件不连续，需要重新拉取快照
+var errOrderBookGap = errors.New("深度增量事件不连续，需要重新同步盘口")
+
+// DepthSnapshot REST 深度快照
+type DepthSnapshot struct {
+	LastUpdateID int64       `json:"lastUpdateId"`
+	Bids         [][2]string `json:"bids"`
+	Asks         [][2]string `json:"asks"`
+}
+
+// depthEvent 币安合约增量深度推送（<symbol>@depth）
+type depthEvent struct {
+	Symbol        string      `json:"s"`
+	FirstUpdateID int64       `json:"U"`
+	FinalUpdateID int64       `json:"u"`
+	PrevUpdateID  int64       `json:"pu"`
+	Bids          [][2]string `json:"b"`
+	Asks          [][2]string `json:"a"`
+}
+
+// OrderBookMetrics 盘口流动性指标
+type OrderBookMetrics struct {
+	BestBid         float64   `json:"best_bid"`
+	BestAsk         float64   `json:"best_ask"`
+	MidPrice        float64   `json:"mid_price"`
+	SpreadBps       float64   `json:"spread_bps"`        // 买卖价差（基点）
+	BidDepth05      float64   `json:"bid_depth_05"`      // 中间价下方0.5%内的买单名义价值（USDT）
+	AskDepth05      float64   `json:"ask_depth_05"`      // 中间价上方0.5%内的卖单名义价值（USDT）
+	BidDepth1       float64   `json:"bid_depth_1"`       // 中间价下方1%内的买单名义价值（USDT）
+	AskDepth1       float64   `json:"ask_depth_1"`       // 中间价上方1%内的卖单名义价值（USDT）
+	Imbalance05     float64   `json:"imbalance_05"`      // ±0.5%内买卖失衡度 (bid-ask)/(bid+ask)，正值买盘更厚
+	Imbalance1      float64   `json:"imbalance_1"`       // ±1%内买卖失衡度
+	SizeUSD         float64   `json:"size_usd"`          // 预估滑点使用的仓位名义价值
+	BuySlippageBps  float64   `json:"buy_slippage_bps"`  // 按 SizeUSD 市价买入相对中间价的预估滑点（基点）
+	SellSlippageBps float64   `json:"sell_slippage_bps"` // 按 SizeUSD 市价卖出相对中间价的预估滑点（基点）
+	Unfillable      bool      `json:"unfillable"`        // 盘口深度不足以成交 SizeUSD（滑点按可见深度计算）
+	Truncated       bool      `json:"truncated"`         // 可见盘口未覆盖 ±1% 范围，深度可能被低估
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// MinDepth1 ±1%内较薄一侧的深度
+func (m *OrderBookMetrics) MinDepth1() float64 {
+	return math.Min(m.BidDepth1, m.AskDepth1)
+}
+
+// SlippageBps 指定方向的预估滑点（long=买入，short=卖出）
+func (m *OrderBookMetrics) SlippageBps(long bool) float64 {
+	if long {
+		return m.BuySlippageBps
+	}
+	return m.SellSlippageBps
+}
+
+// OrderBook 本地维护的订单簿（快照 + 增量事件）
+type OrderBook struct {
+	mu           sync.Mutex
+	symbol       string
+	bids         map[float64]float64
+	asks         map[float64]float64
+	lastUpdateID int64
+	synced       bool
+	bridged      bool         // 快照后已应用首个衔接事件（之后按 pu 校验连续性）
+	buffer       []depthEvent // 快照到达前缓存的增量事件
+	updatedAt    time.Time
+}
+
+// NewOrderBook 创建空订单簿（需应用快照后才可用）
+func NewOrderBook(symbol string) *OrderBook {
+	return &OrderBook{
+		symbol: symbol,
+		bids:   make(map[float64]float64),
+		asks:   make(map[float64]float64),
+	}
+}
+
+// maxBufferedDepthEvents 等待快照期间最多缓存的增量事件数
+const maxBufferedDepthEvents = 1000
+
+// ApplyEvent 应用增量事件；未同步时先缓存，事件不连续时返回 errOrderBookGap
+func (b *OrderBook) ApplyEvent(ev depthEvent) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.synced {
+		if len(b.buffer) >= maxBufferedDepthEvents {
+			b.buffer = b.buffer[1:]
+		}
+		b.buffer = append(b.buffer, ev)
+		return nil
+	}
+
+	if ev.FinalUpdateID < b.lastUpdateID || (b.bridged && ev.FinalUpdateID == b.lastUpdateID) {
+		return nil // 旧事件
+	}
+	// 快照后的首个事件需覆盖 lastUpdateId，之后的事件 pu 必须等于上一事件的 u
+	if (!b.bridged && ev.FirstUpdateID > b.lastUpdateID) || (b.bridged && ev.PrevUpdateID != b.lastUpdateID) {
+		b.synced = false
+		b.bridged = false
+		b.buffer = append(b.buffer[:0], ev)
+		return errOrderBookGap
+	}
+	b.apply(ev)
+	b.bridged = true
+	return nil
+}
+
+// ApplySnapshot 应用 REST 快照并回放缓存的增量事件
+// 缓存事件无法与快照衔接时返回 errOrderBookGap（需等待更多事件后重新拉取快照）
+func (b *OrderBook) ApplySnapshot(snapshot *DepthSnapshot) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.bids = make(map[float64]float64, len(snapshot.Bids))
+	b.asks = make(map[float64]float64, len(snapshot.Asks))
+	setLevels(b.bids, snapshot.Bids)
+	setLevels(b.asks, snapshot.Asks)
+	b.lastUpdateID = snapshot.LastUpdateID
+	b.updatedAt = time.Now()
+
+	buffered := b.buffer
+	b.buffer = nil
+	b.bridged = false
+	for _, ev := range buffered {
+		if ev.FinalUpdateID < b.lastUpdateID {
+			continue
+		}
+		// 第一个事件必须覆盖快照的 lastUpdateId，之后的事件 pu 必须连续
+		if (!b.bridged && ev.FirstUpdateID > b.lastUpdateID) || (b.bridged && ev.PrevUpdateID != b.lastUpdateID) {
+			b.synced = false
+			return errOrderBookGap
+		}
+		b.apply(ev)
+		b.bridged = true
+	}
+
+	b.synced = true
+	return nil
+}
+
+// apply 更新价格档位（调用方持锁）
+func (b *OrderBook) apply(ev depthEvent) {
+	setLevels(b.bids, ev.Bids)
+	setLevels(b.asks, ev.Asks)
+	b.lastUpdateID = ev.FinalUpdateID
+	b.updatedAt = time.Now()
+}
+
+// setLevels 写入价格档位，数量为0表示删除
+func setLevels(side map[float64]float64, levels [][2]string) {
+	for _, level := range levels {
+		price, err := strconv.ParseFloat(level[0], 64)
+		if err != nil {
+			continue
+		}
+		qty, err := strconv.ParseFloat(level[1], 64)
+		if err != nil {
+			continue
+		}
+		if qty == 0 {
+			delete(side, price)
+		} else {
+			side[price] = qty
+		}
+	}
+}
+
+// Synced 是否已与交易所同步
+func (b *OrderBook) Synced() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.synced
+}
+
+// UpdatedAt 最近一次更新时间
+func (b *OrderBook) UpdatedAt() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.updatedAt
+}
+
+// priceLevel 价格档位
+type priceLevel struct {
+	price float64
+	qty   float64
+}
+
+// Metrics 计算盘口指标，sizeUSD 为预估滑点使用的仓位名义价值（≤0 时不计算滑点）
+func (b *OrderBook) Metrics(sizeUSD float64) (*OrderBookMetrics, bool) {
+	b.mu.Lock()
+	bids := sortedLevels(b.bids, true)
+	asks := sortedLevels(b.asks, false)
+	updatedAt := b.updatedAt
+	b.mu.Unlock()
+
+	if len(bids) == 0 || len(asks) == 0 {
+		return nil, false
+	}
+	return computeOrderBookMetrics(bids, asks, sizeUSD, updatedAt), true
+}
+
+// sortedLevels 按价格排序（买单从高到低，卖单从低到高）
+func sortedLevels(side map[float64]float64, descending bool) []priceLevel {
+	levels := make([]priceLevel, 0, len(side))
+	for price, qty := range side {
+		levels = append(levels, priceLevel{price, qty})
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		if descending {
+			return levels[i].price > levels[j].price
+		}
+		return levels[i].price < levels[j].price
+	})
+	return levels
+}
+
+// computeOrderBookMetrics 根据排序后的档位计算指标
+func computeOrderBookMetrics(bids, asks []priceLevel, sizeUSD float64, updatedAt time.Time) *OrderBookMetrics {
+	m := &OrderBookMetrics{
+		BestBid:   bids[0].price,
+		BestAsk:   asks[0].price,
+		SizeUSD:   sizeUSD,
+		UpdatedAt: updatedAt,
+	}
+	m.MidPrice = (m.BestBid + m.BestAsk) / 2
+	m.SpreadBps = (m.BestAsk - m.BestBid) / m.MidPrice * 10000
+
+	m.BidDepth05 = depthWithin(bids, m.MidPrice*(1-0.005), true)
+	m.AskDepth05 = depthWithin(asks, m.MidPrice*(1+0.005), false)
+	m.BidDepth1 = depthWithin(bids, m.MidPrice*(1-0.01), true)
+	m.AskDepth1 = depthWithin(asks, m.MidPrice*(1+0.01), false)
+	m.Imbalance05 = imbalance(m.BidDepth05, m.AskDepth05)
+	m.Imbalance1 = imbalance(m.BidDepth1, m.AskDepth1)
+	m.Truncated = bids[len(bids)-1].price > m.MidPrice*(1-0.01) || asks[len(asks)-1].price < m.MidPrice*(1+0.01)
+
+	if sizeUSD > 0 {
+		var buyFilled, sellFilled bool
+		m.BuySlippageBps, buyFilled = estimateSlippage(asks, m.MidPrice, sizeUSD)
+		m.SellSlippageBps, sellFilled = estimateSlippage(bids, m.MidPrice, sizeUSD)
+		m.Unfillable = !buyFilled || !sellFilled
+	}
+	return m
+}
+
+// depthWithin 累计到 limit 价格为止的名义价值
+func depthWithin(levels []priceLevel, limit float64, descending bool) float64 {
+	total := 0.0
+	for _, level := range levels {
+		if (descending && level.price < limit) || (!descending && level.price > limit) {
+			break
+		}
+		total += level.price * level.qty
+	}
+	return total
+}
+
+// imbalance 买卖失衡度
+func imbalance(bid, ask float64) float64 {
+	if bid+ask == 0 {
+		return 0
+	}
+	return (bid - ask) / (bid + ask)
+}
+
+// estimateSlippage 逐档吃单成交 sizeUSD 的成交均价相对中间价的滑点（基点），返回是否能完全成交
+func estimateSlippage(levels []priceLevel, mid, sizeUSD float64) (float64, bool) {
+	remaining := sizeUSD
+	cost, qty := 0.0, 0.0
+	for _, level := range levels {
+		notional := level.price * level.qty
+		if notional >= remaining {
+			q := remaining / level.price
+			cost += q * level.price
+			qty += q
+			remaining = 0
+			break
+		}
+		cost += notional
+		qty += level.qty
+		remaining -= notional
+	}
+	if qty == 0 {
+		return 0, false
+	}
+	vwap := cost / qty
+	return math.Abs(vwap-mid) / mid * 10000, remaining <= 0
+}
+
+// metricsFromSnapshot 直接由 REST 快照计算指标（未维护本地盘口时使用）
+func metricsFromSnapshot(symbol string, snapshot *DepthSnapshot, sizeUSD float64) (*OrderBookMetrics, bool) {
+	book := NewOrderBook(symbol)
+	if err := book.ApplySnapshot(snapshot); err != nil {
+		return nil, false
+	}
+	return book.Metrics(sizeUSD)
+}
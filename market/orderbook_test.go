@@ -0,0 +1,137 @@
+package market
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testDepthSnapshot(lastUpdateID int64) *DepthSnapshot {
+	return &DepthSnapshot{
+		LastUpdateID: lastUpdateID,
+		Bids:         [][2]string{{"99.9", "10"}, {"99.5", "20"}, {"98.5", "100"}},
+		Asks:         [][2]string{{"100.1", "10"}, {"100.4", "20"}, {"101.5", "100"}},
+	}
+}
+
+func TestOrderBook_SnapshotAndEvents(t *testing.T) {
+	book := NewOrderBook("BTCUSDT")
+
+	// 快照前的事件被缓存，回放时丢弃早于快照的事件
+	book.ApplyEvent(depthEvent{FirstUpdateID: 90, FinalUpdateID: 95, PrevUpdateID: 89, Bids: [][2]string{{"99.9", "999"}}})
+	book.ApplyEvent(depthEvent{FirstUpdateID: 96, FinalUpdateID: 102, PrevUpdateID: 95, Asks: [][2]string{{"100.1", "0"}}})
+	if err := book.ApplySnapshot(testDepthSnapshot(100)); err != nil {
+		t.Fatalf("应用快照失败: %v", err)
+	}
+	if !book.Synced() {
+		t.Fatal("快照后应已同步")
+	}
+
+	metrics, ok := book.Metrics(0)
+	if !ok || metrics.BestBid != 99.9 || metrics.BestAsk != 100.4 {
+		t.Fatalf("应丢弃旧事件并删除数量为0的档位: %+v", metrics)
+	}
+
+	// 连续事件正常应用
+	if err := book.ApplyEvent(depthEvent{FirstUpdateID: 103, FinalUpdateID: 105, PrevUpdateID: 102, Asks: [][2]string{{"100.2", "5"}}}); err != nil {
+		t.Fatal(err)
+	}
+	// 不连续事件触发重新同步
+	if err := book.ApplyEvent(depthEvent{FirstUpdateID: 110, FinalUpdateID: 112, PrevUpdateID: 108}); !errors.Is(err, errOrderBookGap) {
+		t.Fatalf("pu 不连续应返回 errOrderBookGap: %v", err)
+	}
+	if book.Synced() {
+		t.Error("事件不连续后应标记为未同步")
+	}
+}
+
+func TestOrderBook_SnapshotNotBridged(t *testing.T) {
+	book := NewOrderBook("BTCUSDT")
+	book.ApplyEvent(depthEvent{FirstUpdateID: 150, FinalUpdateID: 160, PrevUpdateID: 149})
+	if err := book.ApplySnapshot(testDepthSnapshot(100)); !errors.Is(err, errOrderBookGap) {
+		t.Fatalf("缓存事件无法衔接快照时应返回 errOrderBookGap: %v", err)
+	}
+
+	// 快照后没有缓存事件时，首个事件只需覆盖 lastUpdateId
+	book = NewOrderBook("BTCUSDT")
+	if err := book.ApplySnapshot(testDepthSnapshot(100)); err != nil {
+		t.Fatal(err)
+	}
+	if err := book.ApplyEvent(depthEvent{FirstUpdateID: 98, FinalUpdateID: 104, PrevUpdateID: 97}); err != nil {
+		t.Errorf("覆盖快照的首个事件应被接受: %v", err)
+	}
+}
+
+func TestOrderBook_Metrics(t *testing.T) {
+	book := NewOrderBook("BTCUSDT")
+	book.ApplySnapshot(testDepthSnapshot(1))
+
+	m, ok := book.Metrics(3000)
+	if !ok {
+		t.Fatal("应返回指标")
+	}
+	if m.MidPrice != 100 || m.SpreadBps < 19.9 || m.SpreadBps > 20.1 {
+		t.Errorf("中间价/价差错误: mid=%.2f spread=%.2f", m.MidPrice, m.SpreadBps)
+	}
+	// ±0.5%: 买 99.9*10+99.5*20=2989，卖 100.1*10+100.4*20=3009
+	if m.BidDepth05 < 2988 || m.BidDepth05 > 2990 || m.AskDepth05 < 3008 || m.AskDepth05 > 3010 {
+		t.Errorf("±0.5%%深度错误: bid=%.1f ask=%.1f", m.BidDepth05, m.AskDepth05)
+	}
+	// ±1%: 98.5 低于 99 不计入；可见盘口已覆盖 ±1% 范围
+	if m.BidDepth1 != m.BidDepth05 || m.Truncated {
+		t.Errorf("±1%%深度错误: bid=%.1f truncated=%v", m.BidDepth1, m.Truncated)
+	}
+	if m.Imbalance05 >= 0 {
+		t.Errorf("卖盘更厚时失衡度应为负: %.3f", m.Imbalance05)
+	}
+	// 买入3000 USDT：吃掉 100.1 全部档位和部分 100.4，滑点介于 10~40 bps
+	if m.BuySlippageBps <= 10 || m.BuySlippageBps >= 40 || m.Unfillable {
+		t.Errorf("买入滑点错误: %.2f bps unfillable=%v", m.BuySlippageBps, m.Unfillable)
+	}
+
+	if m, _ := book.Metrics(1e6); !m.Unfillable {
+		t.Error("超过盘口总深度时应标记无法完全成交")
+	}
+}
+
+func TestDepthMonitor_ResyncOnGap(t *testing.T) {
+	var snapshots atomic.Int64
+	monitor := NewDepthMonitor(func(symbol string) (*DepthSnapshot, error) {
+		return testDepthSnapshot(snapshots.Add(1) * 100), nil
+	})
+	tracked := &trackedBook{book: NewOrderBook("BTCUSDT"), refs: 1}
+	monitor.books["BTCUSDT"] = tracked
+
+	monitor.resync("BTCUSDT", tracked)
+	if _, ok := monitor.Metrics("btc", 1000); !ok {
+		t.Fatal("同步后应能读取本地盘口指标")
+	}
+
+	// 不连续事件触发后台重新同步（快照 200 之后的事件可衔接）
+	monitor.mu.Lock()
+	tracked.lastResync = time.Time{}
+	monitor.mu.Unlock()
+	monitor.applyEvent("BTCUSDT", tracked, depthEvent{FirstUpdateID: 150, FinalUpdateID: 201, PrevUpdateID: 149})
+	deadline := time.Now().Add(2 * time.Second)
+	for !tracked.book.Synced() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !tracked.book.Synced() || snapshots.Load() != 2 {
+		t.Errorf("事件不连续后应重新拉取快照: synced=%v snapshots=%d", tracked.book.Synced(), snapshots.Load())
+	}
+
+	if _, ok := monitor.Metrics("ETHUSDT", 1000); ok {
+		t.Error("未维护的币种不应返回本地盘口")
+	}
+}
+
+func TestFormatOrderBook(t *testing.T) {
+	m := &OrderBookMetrics{SpreadBps: 1.5, BidDepth05: 1_500_000, AskDepth05: 900_000, SizeUSD: 4000, BuySlippageBps: 2.1, SellSlippageBps: 1.8}
+	out := Format(&Data{Symbol: "BTCUSDT", OrderBook: m})
+	for _, want := range []string{"spread = 1.50 bps", "bid $1.50M / ask $900.0K", "$4.0K market order: buy 2.10 bps"} {
+		if !contains(out, want) {
+			t.Errorf("Format 应包含 %q:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package market
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// sourceKlineLimit 从数据源获取每个周期的K线数量（与 GetFresh 一致）
+const sourceKlineLimit = 100
+
+// GetFromSource 从指定数据源获取市场数据（用于在非币安交易所交易的交易员，保证AI看到的价格/资金费率与成交场所一致）
+// 数据源实现 OpenInterestSource / FundingRateSource 时同时填充持仓量与资金费率
+func GetFromSource(source DataSource, symbol string) (*Data, error) {
+	symbol = Normalize(symbol)
+
+	klines := make(map[string][]Kline, 6)
+	for _, interval := range []string{"1m", "3m", "15m", "1h", "4h", "1d"} {
+		k, err := source.GetKlines(symbol, interval, sourceKlineLimit)
+		if err != nil {
+			switch interval {
+			case "1m", "1d":
+				// 1分钟/日线失败不影响整体流程（与 Get 一致）
+				log.Printf("⚠️  [%s] %s 获取%sK线失败: %v，将继续处理", source.GetName(), symbol, interval, err)
+				continue
+			default:
+				return nil, fmt.Errorf("[%s] 获取%s %sK线失败: %w", source.GetName(), symbol, interval, err)
+			}
+		}
+		klines[interval] = k
+	}
+	if len(klines["3m"]) == 0 || len(klines["4h"]) == 0 {
+		return nil, fmt.Errorf("[%s] %s 缺少3分钟或4小时K线数据", source.GetName(), symbol)
+	}
+	if isStaleData(klines["3m"], symbol) {
+		return nil, fmt.Errorf("[%s] %s data is stale", source.GetName(), symbol)
+	}
+
+	oiData := &OIData{ActualPeriod: "N/A"}
+	if oiSource, ok := source.(OpenInterestSource); ok {
+		if oi, err := oiSource.GetOpenInterest(symbol); err == nil {
+			oiData = oi
+		} else {
+			log.Printf("⚠️  [%s] %s 获取持仓量失败: %v", source.GetName(), symbol, err)
+		}
+	}
+
+	var fundingRate float64
+	if frSource, ok := source.(FundingRateSource); ok {
+		if rate, err := frSource.GetFundingRate(symbol); err == nil {
+			fundingRate = rate
+		} else {
+			log.Printf("⚠️  [%s] %s 获取资金费率失败: %v", source.GetName(), symbol, err)
+		}
+	}
+
+	return buildData(symbol, klines["1m"], klines["3m"], klines["15m"], klines["1h"], klines["4h"], klines["1d"], oiData, fundingRate), nil
+}
+
+var (
+	exchangeSources   = map[string]DataSource{}
+	exchangeSourcesMu sync.Mutex
+)
+
+// SourceForExchange 获取交易所自身的行情数据源（进程内共享，复用缓存与WebSocket连接）
+// 币安返回 nil，继续使用 WSMonitor + fapi 的既有流程
+func SourceForExchange(exchange string, testnet bool) DataSource {
+	key := exchange
+	if testnet {
+		key += "-testnet"
+	}
+
+	exchangeSourcesMu.Lock()
+	defer exchangeSourcesMu.Unlock()
+
+	if source, ok := exchangeSources[key]; ok {
+		return source
+	}
+	var source DataSource
+	switch exchange {
+	case "hyperliquid":
+		source = NewHyperliquidSource(testnet)
+	case "aster":
+		source = NewAsterSource()
+	default:
+		return nil
+	}
+	exchangeSources[key] = source
+	return source
+}
+
+// oiTracker 记录数据源的持仓量快照，用于计算4小时变化率（非币安交易所没有OI历史接口）
+type oiTracker struct {
+	mu      sync.Mutex
+	history map[string][]OISnapshot
+}
+
+// oiTrackerMinInterval 两次快照的最小间隔，避免同一周期内多次请求堆积快照
+const oiTrackerMinInterval = time.Minute
+
+func newOITracker() *oiTracker {
+	return &oiTracker{history: make(map[string][]OISnapshot)}
+}
+
+// record 记录最新持仓量并返回带4小时变化率的 OIData
+func (t *oiTracker) record(symbol string, oi float64, now time.Time) *OIData {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	history := t.history[symbol]
+	if len(history) == 0 || now.Sub(history[len(history)-1].Timestamp) >= oiTrackerMinInterval {
+		history = append(history, OISnapshot{Value: oi, Timestamp: now})
+	} else {
+		history[len(history)-1] = OISnapshot{Value: oi, Timestamp: now}
+	}
+	// 只保留最近5小时
+	cutoff := now.Add(-5 * time.Hour)
+	for len(history) > 1 && history[0].Timestamp.Before(cutoff) {
+		history = history[1:]
+	}
+	t.history[symbol] = history
+
+	data := &OIData{
+		Latest:       oi,
+		Average:      oi * 0.999, // 近似平均值（与币安口径一致）
+		ActualPeriod: "0m",
+		Historical:   append([]OISnapshot(nil), history...),
+	}
+	if len(history) < 2 {
+		return data
+	}
+
+	// 取最接近4小时前的快照，不足4小时时使用最早快照
+	base := history[0]
+	target := now.Add(-4 * time.Hour)
+	for _, snapshot := range history {
+		if !snapshot.Timestamp.After(target) {
+			base = snapshot
+		}
+	}
+	span := now.Sub(base.Timestamp)
+	if span >= 3*time.Hour+30*time.Minute {
+		data.ActualPeriod = "4h"
+	} else {
+		data.ActualPeriod = fmt.Sprintf("%.1fh", span.Hours())
+	}
+	if base.Value > 0 {
+		data.Change4h = (oi - base.Value) / base.Value * 100
+	}
+	return data
+}
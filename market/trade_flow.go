@@ -0,0 +1,553 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	flowBucketCount        = 60               // 每币种保留60个1分钟桶（滚动1小时）
+	flowBucketSize         = time.Minute      // 桶粒度
+	flowMaxAge             = 2 * time.Minute  // 超过该时长未收到成交推送视为过期
+	flowConnectBackoff     = time.Minute      // 连接失败后的重试间隔
+	flowAlertCooldown      = 10 * time.Minute // 同一币种同类警报的最小间隔
+	flowLiquidationWindow  = 5 * time.Minute  // 清算瀑布警报的统计窗口
+	defaultLargeTradeUSD   = 100_000.0        // 大单阈值（单笔成交额）
+	majorLargeTradeUSD     = 500_000.0        // BTC/ETH 大单阈值
+	defaultLiqAlertUSD     = 1_000_000.0      // 5分钟清算额超过该值时发出警报
+	majorLiqAlertUSD       = 5_000_000.0      // BTC/ETH 清算警报阈值
+	whaleAlertMultiplier   = 10.0             // 单笔成交额超过大单阈值的倍数时发出巨鲸警报
+	flowAlertChannelBuffer = 100
+)
+
+// flowWindows 提示词中输出的统计窗口
+var flowWindows = []time.Duration{5 * time.Minute, 15 * time.Minute, time.Hour}
+
+// FlowWindow 某个时间窗口内的清算与主动成交统计（金额单位 USDT）
+type FlowWindow struct {
+	Window           string  `json:"window"`
+	LongLiqUSD       float64 `json:"long_liq_usd"`  // 多头被强平（强平单方向为卖出）
+	ShortLiqUSD      float64 `json:"short_liq_usd"` // 空头被强平（强平单方向为买入）
+	LiqCount         int     `json:"liq_count"`
+	BuyUSD           float64 `json:"buy_usd"`  // 主动买入成交额
+	SellUSD          float64 `json:"sell_usd"` // 主动卖出成交额
+	CVD              float64 `json:"cvd"`      // 成交量差 = 主动买入 - 主动卖出
+	LargeBuyUSD      float64 `json:"large_buy_usd"`
+	LargeSellUSD     float64 `json:"large_sell_usd"`
+	LargeCount       int     `json:"large_count"`
+	LargeImbalance   float64 `json:"large_imbalance"`   // 大单买卖失衡 (买-卖)/(买+卖)，-1~1
+	LiquidationSkew  float64 `json:"liquidation_skew"`  // 清算方向偏斜 (多头-空头)/(多头+空头)，-1~1
+	CoverageComplete bool    `json:"coverage_complete"` // 统计时长是否覆盖完整窗口
+}
+
+// FlowMetrics 币种的清算与大单流向指标
+type FlowMetrics struct {
+	Symbol         string       `json:"symbol"`
+	Windows        []FlowWindow `json:"windows"`         // 5m / 15m / 1h
+	CumulativeCVD  float64      `json:"cumulative_cvd"`  // 自开始跟踪以来的累计成交量差
+	LargeTradeUSD  float64      `json:"large_trade_usd"` // 大单阈值
+	TrackingSince  time.Time    `json:"tracking_since"`
+	LastTradeAt    time.Time    `json:"last_trade_at"`
+	LastLiquidated time.Time    `json:"last_liquidated,omitempty"`
+}
+
+// flowThresholds 大单与警报阈值
+type flowThresholds struct {
+	LargeTradeUSD float64
+	LiqAlertUSD   float64
+	WhaleAlertUSD float64
+}
+
+// flowThresholdsFor 按币种返回阈值（BTC/ETH 成交额大，阈值更高）
+func flowThresholdsFor(symbol string) flowThresholds {
+	t := flowThresholds{LargeTradeUSD: defaultLargeTradeUSD, LiqAlertUSD: defaultLiqAlertUSD}
+	if symbol == "BTCUSDT" || symbol == "ETHUSDT" {
+		t.LargeTradeUSD = majorLargeTradeUSD
+		t.LiqAlertUSD = majorLiqAlertUSD
+	}
+	t.WhaleAlertUSD = t.LargeTradeUSD * whaleAlertMultiplier
+	return t
+}
+
+// flowBucket 1分钟统计桶
+type flowBucket struct {
+	start        int64 // 桶起始时间（Unix 分钟）
+	longLiqUSD   float64
+	shortLiqUSD  float64
+	liqCount     int
+	buyUSD       float64
+	sellUSD      float64
+	largeBuyUSD  float64
+	largeSellUSD float64
+	largeCount   int
+}
+
+// flowTracker 单个币种的滚动统计
+type flowTracker struct {
+	mu            sync.Mutex
+	symbol        string
+	thresholds    flowThresholds
+	buckets       [flowBucketCount]flowBucket
+	cumulativeCVD float64
+	since         time.Time
+	lastTrade     time.Time
+	lastLiq       time.Time
+	lastAlert     map[string]time.Time
+	refs          int
+	tradeStream   string
+	liqStream     string
+	alertSink     func(Alert)
+}
+
+func newFlowTracker(symbol string, now time.Time, alertSink func(Alert)) *flowTracker {
+	return &flowTracker{
+		symbol:     symbol,
+		thresholds: flowThresholdsFor(symbol),
+		since:      now,
+		lastAlert:  make(map[string]time.Time),
+		alertSink:  alertSink,
+	}
+}
+
+// bucket 返回事件时间所在的桶（过旧的事件返回 nil，调用方持锁）
+func (t *flowTracker) bucket(ts time.Time) *flowBucket {
+	minute := ts.Unix() / int64(flowBucketSize/time.Second)
+	b := &t.buckets[minute%flowBucketCount]
+	if b.start != minute {
+		if b.start > minute {
+			return nil
+		}
+		*b = flowBucket{start: minute}
+	}
+	return b
+}
+
+// addTrade 记录一笔主动成交（buyerMaker 为 true 表示主动卖出）
+func (t *flowTracker) addTrade(ts time.Time, price, qty float64, buyerMaker bool) {
+	notional := price * qty
+	if notional <= 0 {
+		return
+	}
+	large := notional >= t.thresholds.LargeTradeUSD
+
+	t.mu.Lock()
+	if ts.After(t.lastTrade) {
+		t.lastTrade = ts
+	}
+	b := t.bucket(ts)
+	if b == nil {
+		t.mu.Unlock()
+		return
+	}
+	if buyerMaker {
+		b.sellUSD += notional
+		t.cumulativeCVD -= notional
+		if large {
+			b.largeSellUSD += notional
+		}
+	} else {
+		b.buyUSD += notional
+		t.cumulativeCVD += notional
+		if large {
+			b.largeBuyUSD += notional
+		}
+	}
+	if large {
+		b.largeCount++
+	}
+	t.mu.Unlock()
+
+	if whale := t.thresholds.WhaleAlertUSD; whale > 0 && notional >= whale {
+		side := "买入"
+		if buyerMaker {
+			side = "卖出"
+		}
+		t.raise(ts, "whale_trade", notional, whale,
+			fmt.Sprintf("%s 巨鲸主动%s %.0f USDT @ %s", t.symbol, side, notional, formatPriceWithDynamicPrecision(price)))
+	}
+}
+
+// addLiquidation 记录一笔强平（side 为强平单方向：SELL 表示多头被强平）
+func (t *flowTracker) addLiquidation(ts time.Time, side string, notional float64) {
+	if notional <= 0 {
+		return
+	}
+
+	t.mu.Lock()
+	b := t.bucket(ts)
+	if b == nil {
+		t.mu.Unlock()
+		return
+	}
+	if strings.EqualFold(side, "SELL") {
+		b.longLiqUSD += notional
+	} else {
+		b.shortLiqUSD += notional
+	}
+	b.liqCount++
+	if ts.After(t.lastLiq) {
+		t.lastLiq = ts
+	}
+	t.mu.Unlock()
+
+	w := t.window(ts, flowLiquidationWindow)
+	if total := w.LongLiqUSD + w.ShortLiqUSD; t.thresholds.LiqAlertUSD > 0 && total >= t.thresholds.LiqAlertUSD {
+		dominant := "多头"
+		if w.ShortLiqUSD > w.LongLiqUSD {
+			dominant = "空头"
+		}
+		t.raise(ts, "liquidation_cascade", total, t.thresholds.LiqAlertUSD,
+			fmt.Sprintf("%s 5分钟内清算 %.0f USDT（以%s为主，多头 %.0f / 空头 %.0f）",
+				t.symbol, total, dominant, w.LongLiqUSD, w.ShortLiqUSD))
+	}
+}
+
+// raise 发出警报（同类警报受冷却时间限制）
+func (t *flowTracker) raise(ts time.Time, alertType string, value, threshold float64, message string) {
+	if t.alertSink == nil {
+		return
+	}
+	t.mu.Lock()
+	if last, ok := t.lastAlert[alertType]; ok && ts.Sub(last) < flowAlertCooldown {
+		t.mu.Unlock()
+		return
+	}
+	t.lastAlert[alertType] = ts
+	t.mu.Unlock()
+
+	t.alertSink(Alert{
+		Type:      alertType,
+		Symbol:    t.symbol,
+		Value:     value,
+		Threshold: threshold,
+		Message:   message,
+		Timestamp: ts,
+	})
+}
+
+// window 汇总截至 now 的最近 d 时间内的统计
+func (t *flowTracker) window(now time.Time, d time.Duration) FlowWindow {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	step := int64(flowBucketSize / time.Second)
+	current := now.Unix() / step
+	n := int64(d / flowBucketSize)
+	w := FlowWindow{Window: formatFlowWindow(d), CoverageComplete: now.Sub(t.since) >= d}
+	for i := range t.buckets {
+		b := &t.buckets[i]
+		if b.start <= current-n || b.start > current {
+			continue
+		}
+		w.LongLiqUSD += b.longLiqUSD
+		w.ShortLiqUSD += b.shortLiqUSD
+		w.LiqCount += b.liqCount
+		w.BuyUSD += b.buyUSD
+		w.SellUSD += b.sellUSD
+		w.LargeBuyUSD += b.largeBuyUSD
+		w.LargeSellUSD += b.largeSellUSD
+		w.LargeCount += b.largeCount
+	}
+	w.CVD = w.BuyUSD - w.SellUSD
+	if total := w.LargeBuyUSD + w.LargeSellUSD; total > 0 {
+		w.LargeImbalance = (w.LargeBuyUSD - w.LargeSellUSD) / total
+	}
+	if total := w.LongLiqUSD + w.ShortLiqUSD; total > 0 {
+		w.LiquidationSkew = (w.LongLiqUSD - w.ShortLiqUSD) / total
+	}
+	return w
+}
+
+// metrics 生成截至 now 的流向指标
+func (t *flowTracker) metrics(now time.Time) *FlowMetrics {
+	m := &FlowMetrics{Symbol: t.symbol, LargeTradeUSD: t.thresholds.LargeTradeUSD}
+	for _, d := range flowWindows {
+		m.Windows = append(m.Windows, t.window(now, d))
+	}
+	t.mu.Lock()
+	m.CumulativeCVD = t.cumulativeCVD
+	m.TrackingSince = t.since
+	m.LastTradeAt = t.lastTrade
+	m.LastLiquidated = t.lastLiq
+	t.mu.Unlock()
+	return m
+}
+
+// aggTradeEvent 归集成交推送（<symbol>@aggTrade）
+type aggTradeEvent struct {
+	Price      string `json:"p"`
+	Quantity   string `json:"q"`
+	TradeTime  int64  `json:"T"`
+	BuyerMaker bool   `json:"m"`
+}
+
+// forceOrderEvent 强平订单推送（<symbol>@forceOrder）
+type forceOrderEvent struct {
+	Order struct {
+		Symbol      string `json:"s"`
+		Side        string `json:"S"`
+		AvgPrice    string `json:"ap"`
+		Price       string `json:"p"`
+		FilledQty   string `json:"z"`
+		OrigQty     string `json:"q"`
+		TradeTime   int64  `json:"T"`
+		OrderStatus string `json:"X"`
+	} `json:"o"`
+}
+
+// notional 强平成交额（优先使用成交均价与累计成交量）
+func (e *forceOrderEvent) notional() float64 {
+	price, _ := strconv.ParseFloat(e.Order.AvgPrice, 64)
+	if price <= 0 {
+		price, _ = strconv.ParseFloat(e.Order.Price, 64)
+	}
+	qty, _ := strconv.ParseFloat(e.Order.FilledQty, 64)
+	if qty <= 0 {
+		qty, _ = strconv.ParseFloat(e.Order.OrigQty, 64)
+	}
+	return price * qty
+}
+
+var (
+	defaultFlowMonitor     *FlowMonitor
+	defaultFlowMonitorOnce sync.Once
+)
+
+// DefaultFlowMonitor 全局清算与大单流向监控（首次订阅时连接 WebSocket）
+func DefaultFlowMonitor() *FlowMonitor {
+	defaultFlowMonitorOnce.Do(func() {
+		defaultFlowMonitor = NewFlowMonitor()
+	})
+	return defaultFlowMonitor
+}
+
+// FlowMonitor 订阅强平与归集成交流，为交易中的币种统计清算量、大单失衡与CVD
+type FlowMonitor struct {
+	mu        sync.Mutex
+	client    *CombinedStreamsClient
+	connected bool
+	failedAt  time.Time
+	trackers  map[string]*flowTracker
+	alerts    chan Alert
+}
+
+// NewFlowMonitor 创建流向监控
+func NewFlowMonitor() *FlowMonitor {
+	return &FlowMonitor{
+		trackers: make(map[string]*flowTracker),
+		alerts:   make(chan Alert, flowAlertChannelBuffer),
+	}
+}
+
+// Alerts 清算瀑布与巨鲸成交警报（通道满时丢弃）
+func (m *FlowMonitor) Alerts() <-chan Alert {
+	return m.alerts
+}
+
+func (m *FlowMonitor) emit(alert Alert) {
+	select {
+	case m.alerts <- alert:
+	default:
+	}
+}
+
+// ensureConnected 首次使用时建立组合流连接（调用方持锁）
+func (m *FlowMonitor) ensureConnected() error {
+	if m.connected {
+		return nil
+	}
+	if time.Since(m.failedAt) < flowConnectBackoff {
+		return fmt.Errorf("成交流连接失败，%s 后重试", flowConnectBackoff)
+	}
+	if m.client == nil {
+		m.client = NewCombinedStreamsClient(50)
+	}
+	if err := m.client.Connect(); err != nil {
+		m.failedAt = time.Now()
+		return err
+	}
+	m.connected = true
+	return nil
+}
+
+// Acquire 增加币种引用，首次引用时订阅强平与归集成交流
+func (m *FlowMonitor) Acquire(symbol string) error {
+	symbol = Normalize(symbol)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if tracker, ok := m.trackers[symbol]; ok {
+		tracker.refs++
+		return nil
+	}
+	if err := m.ensureConnected(); err != nil {
+		return fmt.Errorf("连接成交流失败: %w", err)
+	}
+
+	tracker := newFlowTracker(symbol, time.Now(), m.emit)
+	tracker.refs = 1
+	tracker.tradeStream = strings.ToLower(symbol) + "@aggTrade"
+	tracker.liqStream = strings.ToLower(symbol) + "@forceOrder"
+	tradeCh := m.client.AddSubscriber(tracker.tradeStream, 2000)
+	liquidationCh := m.client.AddSubscriber(tracker.liqStream, 100)
+	if err := m.client.subscribeStreams([]string{tracker.tradeStream, tracker.liqStream}); err != nil {
+		m.client.RemoveSubscriber(tracker.tradeStream)
+		m.client.RemoveSubscriber(tracker.liqStream)
+		return fmt.Errorf("订阅 %s 成交流失败: %w", symbol, err)
+	}
+	m.trackers[symbol] = tracker
+	go handleAggTrades(tracker, tradeCh)
+	go handleForceOrders(tracker, liquidationCh)
+	return nil
+}
+
+// Release 减少币种引用，引用为0时取消订阅并丢弃统计
+func (m *FlowMonitor) Release(symbol string) {
+	symbol = Normalize(symbol)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tracker, ok := m.trackers[symbol]
+	if !ok {
+		return
+	}
+	tracker.refs--
+	if tracker.refs > 0 {
+		return
+	}
+
+	delete(m.trackers, symbol)
+	if m.client != nil {
+		streams := []string{tracker.tradeStream, tracker.liqStream}
+		for _, stream := range streams {
+			m.client.RemoveSubscriber(stream)
+		}
+		if err := m.client.unsubscribeStreams(streams); err != nil {
+			log.Printf("⚠️  取消订阅 %s 成交流失败: %v", symbol, err)
+		}
+	}
+}
+
+// handleAggTrades 处理归集成交推送
+func handleAggTrades(tracker *flowTracker, ch <-chan []byte) {
+	for data := range ch {
+		var ev aggTradeEvent
+		if err := json.Unmarshal(data, &ev); err != nil {
+			log.Printf("解析 %s 成交数据失败: %v", tracker.symbol, err)
+			continue
+		}
+		price, _ := strconv.ParseFloat(ev.Price, 64)
+		qty, _ := strconv.ParseFloat(ev.Quantity, 64)
+		tracker.addTrade(time.UnixMilli(ev.TradeTime), price, qty, ev.BuyerMaker)
+	}
+}
+
+// handleForceOrders 处理强平订单推送
+func handleForceOrders(tracker *flowTracker, ch <-chan []byte) {
+	for data := range ch {
+		var ev forceOrderEvent
+		if err := json.Unmarshal(data, &ev); err != nil {
+			log.Printf("解析 %s 强平数据失败: %v", tracker.symbol, err)
+			continue
+		}
+		tracker.addLiquidation(time.UnixMilli(ev.Order.TradeTime), ev.Order.Side, ev.notional())
+	}
+}
+
+// Metrics 读取币种的流向指标（未跟踪或成交推送已过期时返回 false）
+func (m *FlowMonitor) Metrics(symbol string) (*FlowMetrics, bool) {
+	m.mu.Lock()
+	tracker, ok := m.trackers[Normalize(symbol)]
+	m.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	metrics := tracker.metrics(time.Now())
+	if metrics.LastTradeAt.IsZero() || time.Since(metrics.LastTradeAt) > flowMaxAge {
+		return nil, false
+	}
+	return metrics, true
+}
+
+// Tracked 当前跟踪的币种数
+func (m *FlowMonitor) Tracked() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.trackers)
+}
+
+// Stop 关闭成交流连接
+func (m *FlowMonitor) Stop() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.client != nil && m.connected {
+		m.client.Close()
+		m.connected = false
+	}
+}
+
+// TradeFlowLease 交易员持有的成交流订阅集合（按币种引用计数，交易员停止后释放）
+type TradeFlowLease struct {
+	symbolLease
+}
+
+// NewTradeFlowLease 创建成交流订阅集合
+func NewTradeFlowLease(monitor *FlowMonitor) *TradeFlowLease {
+	return &TradeFlowLease{symbolLease{tracker: monitor, symbols: make(map[string]bool)}}
+}
+
+// formatFlowWindow 窗口名称，例如 "5m"、"1h"
+func formatFlowWindow(d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dm", int(d.Minutes()))
+}
+
+// formatTradeFlow 格式化清算与大单流向
+func formatTradeFlow(f *FlowMetrics) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Liquidations & order flow (tracking for %s, large trade ≥ %s USDT):\n",
+		formatCountdown(time.Since(f.TrackingSince)), formatVolumeUSD(f.LargeTradeUSD)))
+	for _, w := range f.Windows {
+		partial := ""
+		if !w.CoverageComplete {
+			partial = " [partial]"
+		}
+		sb.WriteString(fmt.Sprintf("[%s]%s Liquidated longs %s / shorts %s (%d orders) | Taker buy %s / sell %s, CVD %s | Large trades %d, buy %s / sell %s, imbalance %+.2f\n",
+			w.Window, partial,
+			formatVolumeUSD(w.LongLiqUSD), formatVolumeUSD(w.ShortLiqUSD), w.LiqCount,
+			formatVolumeUSD(w.BuyUSD), formatVolumeUSD(w.SellUSD), formatSignedVolumeUSD(w.CVD),
+			w.LargeCount, formatVolumeUSD(w.LargeBuyUSD), formatVolumeUSD(w.LargeSellUSD), w.LargeImbalance))
+	}
+	sb.WriteString(fmt.Sprintf("Cumulative CVD since tracking start: %s\n\n", formatSignedVolumeUSD(f.CumulativeCVD)))
+	return sb.String()
+}
+
+// formatVolumeUSD 金额缩写，例如 1.25M、830K
+func formatVolumeUSD(v float64) string {
+	switch {
+	case v >= 1e9:
+		return fmt.Sprintf("%.2fB", v/1e9)
+	case v >= 1e6:
+		return fmt.Sprintf("%.2fM", v/1e6)
+	case v >= 1e3:
+		return fmt.Sprintf("%.0fK", v/1e3)
+	default:
+		return fmt.Sprintf("%.0f", v)
+	}
+}
+
+func formatSignedVolumeUSD(v float64) string {
+	if v < 0 {
+		return "-" + formatVolumeUSD(-v)
+	}
+	return "+" + formatVolumeUSD(v)
+}
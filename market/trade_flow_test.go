@@ -0,0 +1,99 @@
+package market
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFlowTracker_WindowsAndCVD(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tracker := newFlowTracker("SOLUSDT", start, nil)
+
+	// 50分钟前：大额主动卖出
+	tracker.addTrade(start.Add(10*time.Minute), 100, 2000, true)
+	// 最近5分钟：普通主动买入 + 大额主动买入
+	now := start.Add(60 * time.Minute)
+	tracker.addTrade(now.Add(-2*time.Minute), 100, 500, false)
+	tracker.addTrade(now.Add(-time.Minute), 100, 1500, false)
+
+	m := tracker.metrics(now)
+	if len(m.Windows) != 3 {
+		t.Fatalf("应输出3个窗口: %+v", m.Windows)
+	}
+	w5, w1h := m.Windows[0], m.Windows[2]
+	if w5.Window != "5m" || w5.BuyUSD != 200_000 || w5.SellUSD != 0 || w5.CVD != 200_000 {
+		t.Errorf("5分钟窗口统计错误: %+v", w5)
+	}
+	if w5.LargeCount != 1 || w5.LargeBuyUSD != 150_000 || w5.LargeImbalance != 1 {
+		t.Errorf("5分钟大单统计错误: %+v", w5)
+	}
+	if w1h.Window != "1h" || w1h.CVD != 0 || w1h.LargeCount != 2 || w1h.LargeImbalance != -50_000.0/350_000 || !w1h.CoverageComplete {
+		t.Errorf("1小时窗口统计错误: %+v", w1h)
+	}
+	if m.CumulativeCVD != 0 {
+		t.Errorf("累计CVD错误: %v", m.CumulativeCVD)
+	}
+
+	// 超过1小时的大额卖出被移出窗口
+	tracker.addTrade(now.Add(50*time.Minute), 100, 10, false)
+	later := tracker.metrics(now.Add(50 * time.Minute))
+	if later.Windows[2].SellUSD != 0 || later.Windows[2].BuyUSD != 201_000 {
+		t.Errorf("过期数据不应计入1小时窗口: %+v", later.Windows[2])
+	}
+}
+
+func TestFlowTracker_LiquidationAlerts(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	var alerts []Alert
+	tracker := newFlowTracker("SOLUSDT", now.Add(-time.Hour), func(a Alert) { alerts = append(alerts, a) })
+
+	tracker.addLiquidation(now, "SELL", 600_000)
+	tracker.addLiquidation(now.Add(time.Minute), "BUY", 100_000)
+	if len(alerts) != 0 {
+		t.Fatalf("清算额未达阈值不应报警: %+v", alerts)
+	}
+	tracker.addLiquidation(now.Add(2*time.Minute), "SELL", 400_000)
+	if len(alerts) != 1 || alerts[0].Type != "liquidation_cascade" || alerts[0].Value != 1_100_000 || !strings.Contains(alerts[0].Message, "多头") {
+		t.Fatalf("清算瀑布应报警一次: %+v", alerts)
+	}
+	// 冷却期内不重复报警
+	tracker.addLiquidation(now.Add(3*time.Minute), "SELL", 2_000_000)
+	if len(alerts) != 1 {
+		t.Errorf("冷却期内不应重复报警: %d", len(alerts))
+	}
+
+	w := tracker.metrics(now.Add(3 * time.Minute)).Windows[0]
+	if w.LongLiqUSD != 3_000_000 || w.ShortLiqUSD != 100_000 || w.LiqCount != 4 || w.LiquidationSkew <= 0.9 {
+		t.Errorf("清算统计错误: %+v", w)
+	}
+
+	// 巨鲸成交
+	tracker.addTrade(now.Add(3*time.Minute), 100, 10_000, true)
+	if len(alerts) != 2 || alerts[1].Type != "whale_trade" || !strings.Contains(alerts[1].Message, "卖出") {
+		t.Errorf("巨鲸成交应报警: %+v", alerts)
+	}
+}
+
+func TestFlowEvents_ParseAndFormat(t *testing.T) {
+	var ev forceOrderEvent
+	raw := `{"e":"forceOrder","E":1568014460893,"o":{"s":"BTCUSDT","S":"SELL","o":"LIMIT","f":"IOC","q":"0.014","p":"9910","ap":"9900","X":"FILLED","l":"0.014","z":"0.014","T":1568014460893}}`
+	if err := json.Unmarshal([]byte(raw), &ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Order.Side != "SELL" || math.Abs(ev.notional()-138.6) > 1e-9 {
+		t.Errorf("强平事件解析错误: %+v notional=%v", ev, ev.notional())
+	}
+
+	now := time.Now()
+	tracker := newFlowTracker("BTCUSDT", now.Add(-3*time.Minute), nil)
+	tracker.addTrade(now, 50_000, 20, false)
+	text := formatTradeFlow(tracker.metrics(now))
+	for _, want := range []string{"large trade ≥ 500K USDT", "[5m] [partial] Liquidated longs 0 / shorts 0", "[1h] [partial]", "CVD +1.00M", "Cumulative CVD since tracking start: +1.00M"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("提示词缺少 %q:\n%s", want, text)
+		}
+	}
+}
@@ -17,12 +17,15 @@ type Data struct {
 	CurrentRSI7       float64
 	OpenInterest      *OIData
 	FundingRate       float64
-	IntradaySeries    *IntradayData   // 3分钟数据 - 实时价格
-	MidTermSeries15m  *MidTermData15m // 15分钟数据 - 短期趋势
-	MidTermSeries1h   *MidTermData1h  // 1小时数据 - 中期趋势
-	LongerTermContext *LongerTermData // 4小时数据 - 长期趋势
-	DailyContext      *DailyData      // 日线数据 - 长期趋势和极端位置判断
-	RawKlines1h       []Kline         // 原始1小时K线数据（用于K线形态分析，确保数据同步）
+	IntradaySeries    *IntradayData     // 3分钟数据 - 实时价格
+	MidTermSeries15m  *MidTermData15m   // 15分钟数据 - 短期趋势
+	MidTermSeries1h   *MidTermData1h    // 1小时数据 - 中期趋势
+	LongerTermContext *LongerTermData   // 4小时数据 - 长期趋势
+	DailyContext      *DailyData        // 日线数据 - 长期趋势和极端位置判断
+	RawKlines1h       []Kline           // 原始1小时K线数据（用于K线形态分析，确保数据同步）
+	OrderBook         *OrderBookMetrics // 盘口深度与流动性指标（滑点按交易员计划仓位估算）
+	Funding           *FundingInfo      // 资金费率历史、预测费率与期现基差
+	Flow              *FlowMetrics      // 清算量、大单失衡与CVD（仅在订阅成交流的币种上可用）
 }
 
 // OIData Open Interest数据
@@ -40,6 +43,13 @@ type OISnapshot struct {
 	Timestamp time.Time // 时间戳
 }
 
+// FundingRatePoint 资金费率历史数据点
+type FundingRatePoint struct {
+	Rate      float64   `json:"rate"`      // 资金费率
+	Timestamp time.Time `json:"timestamp"` // 结算时间（实时快照为采集时间）
+	Settled   bool      `json:"settled"`   // 是否为已结算费率（否则为 premiumIndex 实时快照）
+}
+
 // IntradayData 日内数据(3分钟间隔) - 主要用于获取实时价格
 type IntradayData struct {
 	MidPrices   []float64
@@ -74,6 +74,17 @@ func (client *Client) SetAPIKey(apiKey, apiURL, customModel string) {
 
 // CallWithMessages 使用 system + user prompt 调用AI API（推荐）
 func (client *Client) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	// 构建 messages 数组（system prompt 为空时不发送 system message）
+	messages := []Message{}
+	if systemPrompt != "" {
+		messages = append(messages, Message{Role: RoleSystem, Content: systemPrompt})
+	}
+	messages = append(messages, Message{Role: RoleUser, Content: userPrompt})
+	return client.CallWithConversation(messages)
+}
+
+// CallWithConversation 使用完整的多轮对话调用AI API（智能体模式的工具调用循环使用）
+func (client *Client) CallWithConversation(messages []Message) (string, error) {
 	if client.APIKey == "" {
 		return "", fmt.Errorf("AI API密钥未设置，请先调用 SetAPIKey")
 	}
@@ -87,7 +98,7 @@ func (client *Client) CallWithMessages(systemPrompt, userPrompt string) (string,
 			fmt.Printf("⚠️  AI API调用失败，正在重试 (%d/%d)...\n", attempt, maxRetries)
 		}
 
-		result, err := client.callOnce(systemPrompt, userPrompt)
+		result, err := client.callOnce(messages)
 		if err == nil {
 			if attempt > 1 {
 				fmt.Printf("✓ AI API重试成功\n")
@@ -117,7 +128,7 @@ func (client *Client) setAuthHeader(reqHeader http.Header) {
 }
 
 // callOnce 单次调用AI API（内部使用）
-func (client *Client) callOnce(systemPrompt, userPrompt string) (string, error) {
+func (client *Client) callOnce(messages []Message) (string, error) {
 	// 打印当前 AI 配置
 	log.Printf("📡 [MCP] AI 请求配置:")
 	log.Printf("   Provider: %s", client.Provider)
@@ -128,23 +139,6 @@ func (client *Client) callOnce(systemPrompt, userPrompt string) (string, error)
 		log.Printf("   API Key: %s...%s", client.APIKey[:4], client.APIKey[len(client.APIKey)-4:])
 	}
 
-	// 构建 messages 数组
-	messages := []map[string]string{}
-
-	// 如果有 system prompt，添加 system message
-	if systemPrompt != "" {
-		messages = append(messages, map[string]string{
-			"role":    "system",
-			"content": systemPrompt,
-		})
-	}
-
-	// 添加 user message
-	messages = append(messages, map[string]string{
-		"role":    "user",
-		"content": userPrompt,
-	})
-
 	// 构建请求体
 	requestBody := map[string]interface{}{
 		"model":       client.Model,
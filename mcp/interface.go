@@ -2,11 +2,26 @@ package mcp
 
 import "net/http"
 
+// 对话消息角色
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Message 对话中的一条消息
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
 // AIClient AI客户端接口
 type AIClient interface {
 	SetAPIKey(apiKey string, customURL string, customModel string)
 	// CallWithMessages 使用 system + user prompt 调用AI API
 	CallWithMessages(systemPrompt, userPrompt string) (string, error)
+	// CallWithConversation 使用多轮对话调用AI API（messages 按时间顺序排列）
+	CallWithConversation(messages []Message) (string, error)
 
 	setAuthHeader(reqHeaders http.Header)
 }
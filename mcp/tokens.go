@@ -0,0 +1,52 @@
+package mcp
+
+import "unicode"
+
+// tokenProfile 各服务商分词器的经验系数（每字符对应的 token 数）
+// 不依赖真实分词器，估算值略偏保守，用于控制提示词长度
+type tokenProfile struct {
+	ascii         float64 // 英文、数字、标点
+	cjk           float64 // 中日韩文字
+	other         float64 // 其他字符（emoji、全角符号等）
+	contextWindow int     // 模型上下文窗口（token）
+}
+
+var tokenProfiles = map[string]tokenProfile{
+	// DeepSeek 官方说明：1个英文字符约0.3个token，1个中文字符约0.6个token
+	ProviderDeepSeek: {ascii: 0.3, cjk: 0.6, other: 1.0, contextWindow: 64000},
+	ProviderQwen:     {ascii: 0.28, cjk: 0.7, other: 1.0, contextWindow: 128000},
+	// 自定义模型分词器未知，按最保守的系数估算
+	ProviderCustom: {ascii: 0.3, cjk: 1.0, other: 1.5, contextWindow: 64000},
+}
+
+// responseTokenReserve 为模型输出（思维链 + JSON）预留的 token 数
+const responseTokenReserve = 8000
+
+func profileFor(provider string) tokenProfile {
+	if p, ok := tokenProfiles[provider]; ok {
+		return p
+	}
+	return tokenProfiles[ProviderCustom]
+}
+
+// EstimateTokens 估算文本在指定服务商下的 token 数
+func EstimateTokens(provider, text string) int {
+	p := profileFor(provider)
+	var tokens float64
+	for _, r := range text {
+		switch {
+		case r <= unicode.MaxASCII:
+			tokens += p.ascii
+		case unicode.Is(unicode.Han, r) || unicode.In(r, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			tokens += p.cjk
+		default:
+			tokens += p.other
+		}
+	}
+	return int(tokens + 0.5)
+}
+
+// DefaultPromptTokenBudget 服务商默认的提示词 token 预算（上下文窗口扣除输出预留）
+func DefaultPromptTokenBudget(provider string) int {
+	return profileFor(provider).contextWindow - responseTokenReserve
+}
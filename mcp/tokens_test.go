@@ -0,0 +1,32 @@
+package mcp
+
+import "testing"
+
+func TestEstimateTokens(t *testing.T) {
+	tests := []struct {
+		name     string
+		provider string
+		text     string
+		want     int
+	}{
+		{"deepseek ascii", ProviderDeepSeek, "BTCUSDT 6500", 4},
+		{"deepseek cjk", ProviderDeepSeek, "多时间周期分析", 4},
+		{"qwen mixed", ProviderQwen, "RSI超买", 2},
+		{"unknown provider uses custom profile", "unknown", "止损🛡️", 5},
+		{"empty", ProviderDeepSeek, "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EstimateTokens(tt.provider, tt.text); got != tt.want {
+				t.Errorf("EstimateTokens(%q) = %d, want %d", tt.text, got, tt.want)
+			}
+		})
+	}
+
+	if DefaultPromptTokenBudget(ProviderQwen) <= DefaultPromptTokenBudget(ProviderDeepSeek) {
+		t.Error("qwen 上下文窗口更大，默认预算应高于 deepseek")
+	}
+	if DefaultPromptTokenBudget("unknown") != DefaultPromptTokenBudget(ProviderCustom) {
+		t.Error("未知服务商应使用 custom 的默认预算")
+	}
+}
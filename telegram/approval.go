@@ -0,0 +1,168 @@
+package telegram
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"nofx/trader"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// 审批回调数据前缀（callback_data 限制 64 字节，只携带提案ID）
+const (
+	callbackApprove = "approve:"
+	callbackReject  = "reject:"
+)
+
+// pendingProposal 已推送、等待按钮操作的提案
+type pendingProposal struct {
+	traderID  string
+	expiresAt time.Time
+}
+
+// NotifyProposal 向交易员所属用户绑定的聊天推送提案消息（带批准/拒绝按钮），实现 trader.ProposalNotifier
+func (b *Bot) NotifyProposal(p *trader.Proposal) {
+	chatIDs := b.ownerChats(p.UserID)
+	if len(chatIDs) == 0 {
+		return
+	}
+
+	b.mu.Lock()
+	// 清理已过期的提案（通过网页处理或过期的提案不会收到按钮回调）
+	for id, pending := range b.proposals {
+		if time.Now().After(pending.expiresAt) {
+			delete(b.proposals, id)
+		}
+	}
+	b.proposals[p.ID] = &pendingProposal{traderID: p.TraderID, expiresAt: p.ExpiresAt}
+	b.mu.Unlock()
+
+	for _, chatID := range chatIDs {
+		msg := tgbotapi.NewMessage(chatID, formatProposalMessage(p))
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("✅ 批准", callbackApprove+p.ID),
+				tgbotapi.NewInlineKeyboardButtonData("❌ 拒绝", callbackReject+p.ID),
+			),
+		)
+		if _, err := b.bot.Send(msg); err != nil {
+			log.Printf("⚠️ [Telegram] 推送审批提案失败: %v", err)
+		}
+	}
+}
+
+// ownerChats 获取用户绑定的所有聊天
+func (b *Bot) ownerChats(userID string) []int64 {
+	if userID == "" || b.links == nil {
+		return nil
+	}
+	links, err := b.links.GetTelegramLinks(userID)
+	if err != nil {
+		log.Printf("⚠️ [Telegram] 查询用户 %s 的聊天绑定失败: %v", userID, err)
+		return nil
+	}
+	chatIDs := make([]int64, 0, len(links))
+	for _, link := range links {
+		chatIDs = append(chatIDs, link.ChatID)
+	}
+	return chatIDs
+}
+
+// handleApprovalCallback 处理批准/拒绝按钮
+func (b *Bot) handleApprovalCallback(cb *tgbotapi.CallbackQuery) {
+	approve, proposalID, ok := parseCallbackData(cb.Data)
+	if !ok || b.resolver == nil {
+		b.answer(cb.ID, "无效操作")
+		return
+	}
+
+	b.mu.Lock()
+	pending, exists := b.proposals[proposalID]
+	if exists && time.Now().After(pending.expiresAt) {
+		delete(b.proposals, proposalID)
+		exists = false
+	}
+	b.mu.Unlock()
+	if !exists {
+		b.answer(cb.ID, "提案不存在或已过期")
+		return
+	}
+	traderID := pending.traderID
+
+	// 只有交易员所属用户绑定的聊天才能操作
+	if !b.canApprove(cb.Message.Chat.ID, traderID) {
+		b.answer(cb.ID, "无权操作")
+		return
+	}
+
+	operator := operatorName(cb.From)
+	var result string
+	var err error
+	if approve {
+		err = b.resolver.ApproveProposal(traderID, proposalID, operator)
+		result = "✅ 已批准并执行"
+	} else {
+		err = b.resolver.RejectProposal(traderID, proposalID, operator, "Telegram 拒绝")
+		result = "🚫 已拒绝"
+	}
+	if err != nil {
+		result = fmt.Sprintf("❌ 处理失败: %v", err)
+	}
+
+	b.mu.Lock()
+	delete(b.proposals, proposalID)
+	b.mu.Unlock()
+
+	b.answer(cb.ID, result)
+	b.editResult(cb.Message, result+" ("+operator+")")
+}
+
+// canApprove 判断聊天是否有权审批该交易员的提案
+func (b *Bot) canApprove(chatID int64, traderID string) bool {
+	userID := b.linkedUser(chatID)
+	if userID == "" || b.traders == nil {
+		return false
+	}
+	traders, err := b.traders.GetUserTraders(userID)
+	if err != nil {
+		return false
+	}
+	for _, t := range traders {
+		if t.GetID() == traderID {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCallbackData 解析按钮数据，返回 (是否批准, 提案ID, 是否合法)
+func parseCallbackData(data string) (bool, string, bool) {
+	switch {
+	case strings.HasPrefix(data, callbackApprove) && len(data) > len(callbackApprove):
+		return true, strings.TrimPrefix(data, callbackApprove), true
+	case strings.HasPrefix(data, callbackReject) && len(data) > len(callbackReject):
+		return false, strings.TrimPrefix(data, callbackReject), true
+	}
+	return false, "", false
+}
+
+// formatProposalMessage 格式化提案消息
+func formatProposalMessage(p *trader.Proposal) string {
+	side := "做多"
+	if p.Action == "open_short" {
+		side = "做空"
+	}
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("📝 开仓审批 [%s]\n", p.TraderName))
+	builder.WriteString(fmt.Sprintf("%s %s | %dx | %.2f USDT\n", p.Symbol, side, p.Leverage, p.PositionSizeUSD))
+	builder.WriteString(fmt.Sprintf("提案价: %.4f | 止损: %.4f | 止盈: %.4f\n", p.ProposedPrice, p.StopLoss, p.TakeProfit))
+	builder.WriteString(fmt.Sprintf("信心度: %d | 有效期至: %s\n", p.Confidence, p.ExpiresAt.Format("2006-01-02 15:04:05")))
+	if p.Reasoning != "" {
+		builder.WriteString("理由: " + p.Reasoning)
+	}
+	return strings.TrimRight(builder.String(), "\n")
+}
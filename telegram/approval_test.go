@@ -0,0 +1,157 @@
+package telegram
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"nofx/trader"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeResolver 记录审批结果
+type fakeResolver struct {
+	approved []string
+	rejected []string
+	err      error
+}
+
+func (r *fakeResolver) ApproveProposal(traderID, proposalID, approvedBy string) error {
+	r.approved = append(r.approved, traderID+"/"+proposalID)
+	return r.err
+}
+
+func (r *fakeResolver) RejectProposal(traderID, proposalID, rejectedBy, note string) error {
+	r.rejected = append(r.rejected, traderID+"/"+proposalID)
+	return r.err
+}
+
+func testProposal(traderID string) *trader.Proposal {
+	return &trader.Proposal{
+		ID:              "p-1",
+		TraderID:        traderID,
+		TraderName:      "Alpha",
+		UserID:          testUserID,
+		Symbol:          "BTCUSDT",
+		Action:          "open_short",
+		Leverage:        10,
+		PositionSizeUSD: 1000,
+		ProposedPrice:   50000,
+		Confidence:      80,
+		Reasoning:       "趋势转弱",
+		ExpiresAt:       time.Now().Add(15 * time.Minute),
+	}
+}
+
+func TestParseCallbackData(t *testing.T) {
+	tests := []struct {
+		data       string
+		approve    bool
+		proposalID string
+		ok         bool
+	}{
+		{"approve:abc", true, "abc", true},
+		{"reject:abc", false, "abc", true},
+		{"approve:", false, "", false},
+		{"close:abc", false, "", false},
+		{"", false, "", false},
+	}
+
+	for _, tt := range tests {
+		approve, proposalID, ok := parseCallbackData(tt.data)
+		assert.Equal(t, tt.ok, ok, tt.data)
+		assert.Equal(t, tt.approve, approve, tt.data)
+		assert.Equal(t, tt.proposalID, proposalID, tt.data)
+	}
+}
+
+func TestFormatProposalMessage(t *testing.T) {
+	msg := formatProposalMessage(testProposal("t"))
+	assert.Contains(t, msg, "[Alpha]")
+	assert.Contains(t, msg, "BTCUSDT 做空 | 10x | 1000.00 USDT")
+	assert.Contains(t, msg, "理由: 趋势转弱")
+}
+
+func TestBot_NotifyAndApprove(t *testing.T) {
+	env := newTestEnv(t)
+
+	env.bot.NotifyProposal(testProposal("user-1_alpha"))
+	sent := env.server.callsOf("sendMessage")
+	require.Len(t, sent, 1, "提案只推送到交易员所属用户绑定的聊天")
+	assert.Equal(t, fmt.Sprint(testLinkedChatID), sent[0].params["chat_id"])
+	assert.Equal(t, "approve:p-1", env.server.lastCallbackData(t, 0))
+
+	// 未绑定的聊天无权审批
+	env.click(testUnlinkedChatID, "approve:p-1")
+	assert.Empty(t, env.resolver.approved)
+
+	env.click(testLinkedChatID, "approve:p-1")
+	assert.Equal(t, []string{"user-1_alpha/p-1"}, env.resolver.approved)
+
+	edits := env.server.callsOf("editMessageText")
+	require.Len(t, edits, 1)
+	assert.Contains(t, edits[0].params["text"], "已批准")
+	assert.Contains(t, edits[0].params["text"], "telegram:@alice")
+
+	// 已处理的提案再次点击不会重复执行
+	env.click(testLinkedChatID, "reject:p-1")
+	assert.Empty(t, env.resolver.rejected)
+	assert.Len(t, env.server.callsOf("answerCallbackQuery"), 3)
+}
+
+func TestBot_LinkedOwnerCanReject(t *testing.T) {
+	env := newTestEnv(t)
+	env.resolver.err = fmt.Errorf("提案已处理")
+
+	env.bot.NotifyProposal(testProposal("user-1_alpha"))
+	env.click(testLinkedChatID, "reject:p-1")
+	assert.Equal(t, []string{"user-1_alpha/p-1"}, env.resolver.rejected)
+
+	edits := env.server.callsOf("editMessageText")
+	require.Len(t, edits, 1)
+	assert.Contains(t, edits[0].params["text"], "处理失败")
+}
+
+func TestBot_LinkedNonOwnerCannotApprove(t *testing.T) {
+	env := newTestEnv(t)
+	env.links.chats[300] = "user-2"
+
+	env.bot.NotifyProposal(testProposal("user-1_alpha"))
+	for _, call := range env.server.callsOf("sendMessage") {
+		assert.NotEqual(t, "300", call.params["chat_id"], "其他用户的聊天不应收到提案")
+	}
+	env.click(300, "approve:p-1")
+	assert.Empty(t, env.resolver.approved)
+}
+
+func TestBot_NotifyWithoutLinkedChat(t *testing.T) {
+	env := newTestEnv(t)
+	p := testProposal("user-2_gamma")
+	p.UserID = "user-2"
+
+	env.bot.NotifyProposal(p)
+	assert.Empty(t, env.server.callsOf("sendMessage"))
+}
+
+func TestBot_ExpiredProposalsAreSwept(t *testing.T) {
+	env := newTestEnv(t)
+
+	expired := testProposal("user-1_alpha")
+	expired.ExpiresAt = time.Now().Add(-time.Second)
+	env.bot.NotifyProposal(expired)
+
+	// 过期提案的按钮不再生效
+	env.click(testLinkedChatID, "approve:p-1")
+	assert.Empty(t, env.resolver.approved)
+	assert.Empty(t, env.bot.proposals)
+
+	// 推送新提案时清理已过期但未被点击的提案
+	env.bot.NotifyProposal(expired)
+	next := testProposal("user-1_alpha")
+	next.ID = "p-2"
+	env.bot.NotifyProposal(next)
+	assert.Len(t, env.bot.proposals, 1)
+	assert.Contains(t, env.bot.proposals, "p-2")
+}
@@ -0,0 +1,218 @@
+package telegram
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"nofx/config"
+	"nofx/logger"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Trader 机器人可操作的交易员（由 *trader.AutoTrader 实现）
+type Trader interface {
+	GetID() string
+	GetName() string
+	GetStatus() map[string]interface{}
+	GetAccountInfo() (map[string]interface{}, error)
+	GetPositions() ([]map[string]interface{}, error)
+	GetTradingMode() string
+	SetTradingMode(mode string) error
+	ManualClosePosition(symbol, side string, percentage float64, note string) (*logger.DecisionAction, error)
+	GetDecisionLogger() logger.IDecisionLogger
+}
+
+// TraderProvider 按用户获取交易员（由 ManagerProvider 实现）
+type TraderProvider interface {
+	GetUserTraders(userID string) ([]Trader, error)
+}
+
+// ChatLinkStore 聊天与 nofx 用户的绑定（由 config.Database 实现）
+type ChatLinkStore interface {
+	LinkTelegramChat(code string, chatID int64, username string) (string, error)
+	GetTelegramChatUser(chatID int64) (string, error)
+	GetTelegramLinks(userID string) ([]*config.TelegramLink, error)
+	UnlinkTelegramChat(userID string, chatID int64) error
+}
+
+// ProposalResolver 处理审批结果（由 manager.TraderManager 实现）
+type ProposalResolver interface {
+	ApproveProposal(traderID, proposalID, approvedBy string) error
+	RejectProposal(traderID, proposalID, rejectedBy, note string) error
+}
+
+// Options 机器人配置
+type Options struct {
+	BotToken    string
+	APIEndpoint string // 为空时使用官方地址（测试中指向假服务器）
+	Resolver    ProposalResolver
+	Traders     TraderProvider
+	Links       ChatLinkStore
+}
+
+// confirmationTTL 危险操作确认有效期
+const confirmationTTL = 2 * time.Minute
+
+// Bot 双向 Telegram 机器人：查询状态、控制交易员、审批开仓提案
+type Bot struct {
+	bot      *tgbotapi.BotAPI
+	resolver ProposalResolver
+	traders  TraderProvider
+	links    ChatLinkStore
+
+	proposals     map[string]*pendingProposal // proposal_id -> 待审批提案
+	confirmations map[string]*pendingConfirmation
+	mu            sync.Mutex
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+	once   sync.Once
+}
+
+// NewBot 创建机器人
+func NewBot(opts Options) (*Bot, error) {
+	if opts.BotToken == "" {
+		return nil, fmt.Errorf("telegram配置不完整: bot_token不能为空")
+	}
+	endpoint := opts.APIEndpoint
+	if endpoint == "" {
+		endpoint = tgbotapi.APIEndpoint
+	}
+
+	bot, err := tgbotapi.NewBotAPIWithAPIEndpoint(opts.BotToken, endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("创建telegram bot失败: %w", err)
+	}
+	bot.Debug = false
+
+	return &Bot{
+		bot:           bot,
+		resolver:      opts.Resolver,
+		traders:       opts.Traders,
+		links:         opts.Links,
+		proposals:     make(map[string]*pendingProposal),
+		confirmations: make(map[string]*pendingConfirmation),
+		stopCh:        make(chan struct{}),
+	}, nil
+}
+
+// Start 开始监听消息与按钮回调
+func (b *Bot) Start() {
+	if _, err := b.bot.Request(tgbotapi.NewSetMyCommands(botCommands...)); err != nil {
+		log.Printf("⚠️ [Telegram] 设置命令菜单失败: %v", err)
+	}
+
+	u := tgbotapi.NewUpdate(0)
+	u.Timeout = 30
+	u.AllowedUpdates = []string{"message", "callback_query"}
+	updates := b.bot.GetUpdatesChan(u)
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		for {
+			select {
+			case update, ok := <-updates:
+				if !ok {
+					return
+				}
+				b.handleUpdate(update)
+			case <-b.stopCh:
+				return
+			}
+		}
+	}()
+	log.Printf("✅ Telegram机器人已启动 (@%s)", b.bot.Self.UserName)
+}
+
+// Stop 停止监听
+func (b *Bot) Stop() {
+	b.once.Do(func() {
+		b.bot.StopReceivingUpdates()
+		close(b.stopCh)
+		b.wg.Wait()
+	})
+}
+
+// handleUpdate 分发消息与回调（单个更新的异常不影响后续处理）
+func (b *Bot) handleUpdate(update tgbotapi.Update) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("❌ [Telegram] 处理更新异常: %v", r)
+		}
+	}()
+
+	switch {
+	case update.CallbackQuery != nil:
+		b.handleCallback(update.CallbackQuery)
+	case update.Message != nil && update.Message.IsCommand():
+		b.handleCommand(update.Message)
+	}
+}
+
+// handleCallback 按前缀分发按钮回调
+func (b *Bot) handleCallback(cb *tgbotapi.CallbackQuery) {
+	if cb.Message == nil || cb.Message.Chat == nil {
+		b.answer(cb.ID, "无效操作")
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(cb.Data, callbackApprove), strings.HasPrefix(cb.Data, callbackReject):
+		b.handleApprovalCallback(cb)
+	case strings.HasPrefix(cb.Data, callbackConfirm), strings.HasPrefix(cb.Data, callbackCancel):
+		b.handleConfirmationCallback(cb)
+	default:
+		b.answer(cb.ID, "无效操作")
+	}
+}
+
+// linkedUser 获取聊天绑定的用户（未绑定时返回空字符串）
+func (b *Bot) linkedUser(chatID int64) string {
+	if b.links == nil {
+		return ""
+	}
+	userID, err := b.links.GetTelegramChatUser(chatID)
+	if err != nil {
+		log.Printf("⚠️ [Telegram] 查询聊天绑定失败: %v", err)
+		return ""
+	}
+	return userID
+}
+
+// reply 发送纯文本消息（不使用 Markdown，避免转义问题）
+func (b *Bot) reply(chatID int64, text string) {
+	if _, err := b.bot.Send(tgbotapi.NewMessage(chatID, text)); err != nil {
+		log.Printf("⚠️ [Telegram] 发送消息失败: %v", err)
+	}
+}
+
+// answer 回应按钮点击（消除客户端加载状态）
+func (b *Bot) answer(callbackID, text string) {
+	if _, err := b.bot.Request(tgbotapi.NewCallback(callbackID, text)); err != nil {
+		log.Printf("⚠️ [Telegram] 回应按钮失败: %v", err)
+	}
+}
+
+// editResult 在原消息后追加处理结果并移除按钮
+func (b *Bot) editResult(msg *tgbotapi.Message, result string) {
+	edit := tgbotapi.NewEditMessageText(msg.Chat.ID, msg.MessageID, msg.Text+"\n\n"+result)
+	if _, err := b.bot.Request(edit); err != nil {
+		log.Printf("⚠️ [Telegram] 更新消息失败: %v", err)
+	}
+}
+
+// operatorName 操作者标识（写入决策日志与审批记录）
+func operatorName(user *tgbotapi.User) string {
+	if user == nil {
+		return "telegram"
+	}
+	if user.UserName != "" {
+		return "telegram:@" + user.UserName
+	}
+	return fmt.Sprintf("telegram:%d", user.ID)
+}
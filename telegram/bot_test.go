@@ -0,0 +1,306 @@
+package telegram
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"nofx/config"
+	"nofx/logger"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testUnlinkedChatID int64 = 100
+	testLinkedChatID   int64 = 200
+	testUserID               = "user-1"
+)
+
+// fakeTelegramServer 模拟 Telegram Bot API：记录调用的方法及参数，getUpdates 返回排队的更新
+type fakeTelegramServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	calls    []fakeCall
+	updates  []tgbotapi.Update
+	nextID   int
+	lastSent chan fakeCall
+}
+
+type fakeCall struct {
+	method string
+	params map[string]string
+}
+
+func newFakeTelegramServer(t *testing.T) *fakeTelegramServer {
+	f := &fakeTelegramServer{lastSent: make(chan fakeCall, 16)}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		parts := strings.Split(r.URL.Path, "/")
+		method := parts[len(parts)-1]
+
+		params := map[string]string{}
+		for k := range r.Form {
+			params[k] = r.Form.Get(k)
+		}
+		call := fakeCall{method: method, params: params}
+		if method != "getUpdates" {
+			f.mu.Lock()
+			f.calls = append(f.calls, call)
+			f.mu.Unlock()
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch method {
+		case "getMe":
+			fmt.Fprint(w, `{"ok":true,"result":{"id":1,"is_bot":true,"first_name":"nofx","username":"nofx_bot"}}`)
+		case "getUpdates":
+			f.mu.Lock()
+			updates := f.updates
+			f.updates = nil
+			f.mu.Unlock()
+			if len(updates) == 0 {
+				time.Sleep(10 * time.Millisecond)
+			}
+			data, _ := json.Marshal(updates)
+			fmt.Fprintf(w, `{"ok":true,"result":%s}`, data)
+		case "sendMessage":
+			fmt.Fprint(w, `{"ok":true,"result":{"message_id":1,"date":0,"chat":{"id":100,"type":"private"}}}`)
+			select {
+			case f.lastSent <- call:
+			default:
+			}
+		default:
+			fmt.Fprint(w, `{"ok":true,"result":true}`)
+		}
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// pushCommand 排队一条命令消息
+func (f *fakeTelegramServer) pushCommand(chatID int64, text string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.nextID++
+	command := strings.SplitN(text, " ", 2)[0]
+	f.updates = append(f.updates, tgbotapi.Update{
+		UpdateID: f.nextID,
+		Message: &tgbotapi.Message{
+			MessageID: f.nextID,
+			From:      &tgbotapi.User{ID: 7, UserName: "alice"},
+			Chat:      &tgbotapi.Chat{ID: chatID, Type: "private"},
+			Text:      text,
+			Entities:  []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}},
+		},
+	})
+}
+
+func (f *fakeTelegramServer) callsOf(method string) []fakeCall {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var result []fakeCall
+	for _, c := range f.calls {
+		if c.method == method {
+			result = append(result, c)
+		}
+	}
+	return result
+}
+
+// lastText 最后一条发送消息的文本
+func (f *fakeTelegramServer) lastText(t *testing.T) string {
+	sent := f.callsOf("sendMessage")
+	require.NotEmpty(t, sent)
+	return sent[len(sent)-1].params["text"]
+}
+
+// lastCallbackData 最后一条消息中第 index 个按钮的回调数据
+func (f *fakeTelegramServer) lastCallbackData(t *testing.T, index int) string {
+	sent := f.callsOf("sendMessage")
+	require.NotEmpty(t, sent)
+	var markup tgbotapi.InlineKeyboardMarkup
+	require.NoError(t, json.Unmarshal([]byte(sent[len(sent)-1].params["reply_markup"]), &markup))
+	require.Greater(t, len(markup.InlineKeyboard[0]), index)
+	return *markup.InlineKeyboard[0][index].CallbackData
+}
+
+// fakeTrader 实现 Trader 接口
+type fakeTrader struct {
+	id        string
+	name      string
+	mode      string
+	running   bool
+	account   map[string]interface{}
+	positions []map[string]interface{}
+	closed    []string
+	records   []*logger.DecisionRecord
+}
+
+func (f *fakeTrader) GetID() string   { return f.id }
+func (f *fakeTrader) GetName() string { return f.name }
+func (f *fakeTrader) GetStatus() map[string]interface{} {
+	return map[string]interface{}{"is_running": f.running, "pending_proposals": 0}
+}
+func (f *fakeTrader) GetAccountInfo() (map[string]interface{}, error) { return f.account, nil }
+func (f *fakeTrader) GetPositions() ([]map[string]interface{}, error) { return f.positions, nil }
+func (f *fakeTrader) GetTradingMode() string                          { return f.mode }
+func (f *fakeTrader) SetTradingMode(mode string) error {
+	f.mode = mode
+	return nil
+}
+func (f *fakeTrader) ManualClosePosition(symbol, side string, percentage float64, note string) (*logger.DecisionAction, error) {
+	f.closed = append(f.closed, symbol+"_"+side+"_"+note)
+	return &logger.DecisionAction{Symbol: symbol, Price: 101.5, Success: true}, nil
+}
+func (f *fakeTrader) GetDecisionLogger() logger.IDecisionLogger {
+	return &fakeDecisionLogger{records: f.records}
+}
+
+// fakeDecisionLogger 只实现 GetLatestRecords
+type fakeDecisionLogger struct {
+	logger.IDecisionLogger
+	records []*logger.DecisionRecord
+}
+
+func (f *fakeDecisionLogger) GetLatestRecords(n int) ([]*logger.DecisionRecord, error) {
+	return f.records, nil
+}
+
+// fakeProvider 按用户返回交易员
+type fakeProvider map[string][]Trader
+
+func (f fakeProvider) GetUserTraders(userID string) ([]Trader, error) {
+	return append([]Trader(nil), f[userID]...), nil
+}
+
+// fakeLinks 内存中的聊天绑定
+type fakeLinks struct {
+	mu    sync.Mutex
+	chats map[int64]string
+	codes map[string]string
+}
+
+func (f *fakeLinks) LinkTelegramChat(code string, chatID int64, username string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	userID, ok := f.codes[code]
+	if !ok {
+		return "", fmt.Errorf("绑定码无效")
+	}
+	delete(f.codes, code)
+	f.chats[chatID] = userID
+	return userID, nil
+}
+
+func (f *fakeLinks) GetTelegramChatUser(chatID int64) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.chats[chatID], nil
+}
+
+func (f *fakeLinks) GetTelegramLinks(userID string) ([]*config.TelegramLink, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	links := []*config.TelegramLink{}
+	for chatID, owner := range f.chats {
+		if owner == userID {
+			links = append(links, &config.TelegramLink{ChatID: chatID, UserID: owner})
+		}
+	}
+	return links, nil
+}
+
+func (f *fakeLinks) UnlinkTelegramChat(userID string, chatID int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.chats, chatID)
+	return nil
+}
+
+// testEnv 测试环境
+type testEnv struct {
+	bot      *Bot
+	server   *fakeTelegramServer
+	traders  []*fakeTrader
+	links    *fakeLinks
+	resolver *fakeResolver
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	server := newFakeTelegramServer(t)
+	alpha := &fakeTrader{
+		id: "user-1_alpha", name: "Alpha", mode: "normal", running: true,
+		account: map[string]interface{}{"total_equity": 1100.0, "total_pnl": 100.0, "total_pnl_pct": 10.0, "position_count": 1, "initial_balance": 1000.0},
+		positions: []map[string]interface{}{
+			{"symbol": "BTCUSDT", "side": "long", "quantity": 0.01, "leverage": 10, "entry_price": 50000.0, "mark_price": 51000.0, "unrealized_pnl": 10.0},
+		},
+	}
+	beta := &fakeTrader{
+		id: "user-1_beta", name: "Beta", mode: "normal",
+		account: map[string]interface{}{"total_equity": 500.0, "total_pnl": -20.0, "total_pnl_pct": -4.0},
+	}
+	links := &fakeLinks{chats: map[int64]string{testLinkedChatID: testUserID}, codes: map[string]string{"ABCD2345": testUserID}}
+	resolver := &fakeResolver{}
+
+	bot, err := NewBot(Options{
+		BotToken:    "test-token",
+		APIEndpoint: server.URL + "/bot%s/%s",
+		Resolver:    resolver,
+		Traders:     fakeProvider{testUserID: {alpha, beta}},
+		Links:       links,
+	})
+	require.NoError(t, err)
+	return &testEnv{bot: bot, server: server, traders: []*fakeTrader{alpha, beta}, links: links, resolver: resolver}
+}
+
+// command 直接调用命令处理
+func (e *testEnv) command(chatID int64, text string) {
+	command := strings.SplitN(text, " ", 2)[0]
+	e.bot.handleCommand(&tgbotapi.Message{
+		From:     &tgbotapi.User{ID: 7, UserName: "alice"},
+		Chat:     &tgbotapi.Chat{ID: chatID},
+		Text:     text,
+		Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}},
+	})
+}
+
+// click 模拟点击按钮
+func (e *testEnv) click(chatID int64, data string) {
+	e.bot.handleCallback(&tgbotapi.CallbackQuery{
+		ID:      "cb",
+		Data:    data,
+		From:    &tgbotapi.User{ID: 7, UserName: "alice"},
+		Message: &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: chatID}, Text: "prompt"},
+	})
+}
+
+func TestNewBot_InvalidConfig(t *testing.T) {
+	_, err := NewBot(Options{})
+	assert.Error(t, err)
+}
+
+// TestBot_PollingLoop 测试通过长轮询接收命令并回复（端到端，经由假服务器）
+func TestBot_PollingLoop(t *testing.T) {
+	env := newTestEnv(t)
+	env.bot.Start()
+	defer env.bot.Stop()
+
+	require.Len(t, env.server.callsOf("setMyCommands"), 1)
+
+	env.server.pushCommand(testLinkedChatID, "/status")
+	select {
+	case call := <-env.server.lastSent:
+		assert.Equal(t, fmt.Sprint(testLinkedChatID), call.params["chat_id"])
+		assert.Contains(t, call.params["text"], "Alpha ▶️ 运行中")
+	case <-time.After(3 * time.Second):
+		t.Fatal("未收到机器人回复")
+	}
+}
@@ -0,0 +1,583 @@
+package telegram
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"nofx/trader"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/google/uuid"
+)
+
+// 危险操作确认按钮前缀
+const (
+	callbackConfirm = "confirm:"
+	callbackCancel  = "cancel:"
+)
+
+// maxMessageLength Telegram 单条消息上限为 4096 字符，预留余量
+const maxMessageLength = 4000
+
+// 危险操作类型
+const (
+	confirmActionPause = "pause"
+	confirmActionClose = "close"
+)
+
+// botCommands 命令菜单
+var botCommands = []tgbotapi.BotCommand{
+	{Command: "status", Description: "交易员运行状态"},
+	{Command: "positions", Description: "当前持仓"},
+	{Command: "pnl", Description: "盈亏概览"},
+	{Command: "decision", Description: "最近一次AI决策摘要"},
+	{Command: "pause", Description: "暂停交易员（需确认）"},
+	{Command: "resume", Description: "恢复交易员"},
+	{Command: "close", Description: "平掉指定币种持仓（需确认）"},
+	{Command: "link", Description: "绑定 nofx 账户"},
+	{Command: "unlink", Description: "解除绑定"},
+	{Command: "help", Description: "帮助"},
+}
+
+const helpText = `🤖 nofx 机器人命令
+/status [交易员] - 运行状态
+/positions [交易员] - 当前持仓
+/pnl [交易员] - 盈亏概览
+/decision [交易员] - 最近一次AI决策摘要
+/pause <交易员> - 暂停AI开仓（需确认）
+/resume <交易员> - 恢复正常模式
+/close <币种> [交易员] - 平掉该币种持仓（需确认）
+/link <绑定码> - 绑定 nofx 账户（绑定码在网页端生成）
+/unlink - 解除绑定
+
+只有一个交易员时可省略交易员参数，交易员可用名称或ID指定。`
+
+// pendingConfirmation 等待确认的危险操作
+type pendingConfirmation struct {
+	chatID    int64
+	userID    string
+	traderID  string
+	action    string
+	symbol    string
+	expiresAt time.Time
+}
+
+// handleCommand 处理命令消息
+func (b *Bot) handleCommand(msg *tgbotapi.Message) {
+	chatID := msg.Chat.ID
+	args := strings.Fields(msg.CommandArguments())
+
+	switch msg.Command() {
+	case "start", "help":
+		b.reply(chatID, helpText)
+		return
+	case "link":
+		b.reply(chatID, b.cmdLink(msg, args))
+		return
+	}
+
+	userID := b.linkedUser(chatID)
+	if userID == "" {
+		b.reply(chatID, "🔒 当前聊天未绑定 nofx 账户，请先在网页端生成绑定码后发送 /link <绑定码>")
+		return
+	}
+
+	var text string
+	switch msg.Command() {
+	case "unlink":
+		text = b.cmdUnlink(chatID)
+	case "status":
+		text = b.cmdStatus(userID, args)
+	case "positions":
+		text = b.cmdPositions(userID, args)
+	case "pnl":
+		text = b.cmdPnL(userID, args)
+	case "decision":
+		text = b.cmdDecision(userID, args)
+	case "resume":
+		text = b.cmdResume(userID, args)
+	case "pause":
+		text = b.cmdPause(chatID, userID, args)
+	case "close":
+		text = b.cmdClose(chatID, userID, args)
+	default:
+		text = "未知命令，发送 /help 查看可用命令"
+	}
+	if text != "" {
+		b.reply(chatID, truncateMessage(text))
+	}
+}
+
+// cmdLink 使用绑定码绑定聊天
+func (b *Bot) cmdLink(msg *tgbotapi.Message, args []string) string {
+	if b.links == nil {
+		return "❌ 未启用账户绑定"
+	}
+	if len(args) != 1 {
+		return "用法: /link <绑定码>（在网页端生成）"
+	}
+
+	username := ""
+	if msg.From != nil {
+		username = msg.From.UserName
+	}
+	if _, err := b.links.LinkTelegramChat(args[0], msg.Chat.ID, username); err != nil {
+		return fmt.Sprintf("❌ 绑定失败: %v", err)
+	}
+	log.Printf("🔗 [Telegram] 聊天 %d 已绑定 nofx 账户", msg.Chat.ID)
+	return "✅ 绑定成功，发送 /status 查看交易员状态"
+}
+
+// cmdUnlink 解除当前聊天绑定
+func (b *Bot) cmdUnlink(chatID int64) string {
+	if err := b.links.UnlinkTelegramChat("", chatID); err != nil {
+		return fmt.Sprintf("❌ 解除绑定失败: %v", err)
+	}
+	return "✅ 已解除绑定"
+}
+
+// cmdStatus 交易员运行状态
+func (b *Bot) cmdStatus(userID string, args []string) string {
+	traders, err := b.filterTraders(userID, args)
+	if err != nil {
+		return "❌ " + err.Error()
+	}
+
+	var builder strings.Builder
+	builder.WriteString("📊 交易员状态\n")
+	for _, t := range traders {
+		status := t.GetStatus()
+		running := "⏹ 已停止"
+		if isRunning, _ := status["is_running"].(bool); isRunning {
+			running = "▶️ 运行中"
+		}
+		builder.WriteString(fmt.Sprintf("\n🤖 %s %s | 模式: %s\n", t.GetName(), running, t.GetTradingMode()))
+
+		account, err := t.GetAccountInfo()
+		if err != nil {
+			builder.WriteString(fmt.Sprintf("  账户获取失败: %v\n", err))
+			continue
+		}
+		builder.WriteString(fmt.Sprintf("  净值: %.2f USDT | 总盈亏: %+.2f (%+.2f%%)\n",
+			floatValue(account, "total_equity"), floatValue(account, "total_pnl"), floatValue(account, "total_pnl_pct")))
+		builder.WriteString(fmt.Sprintf("  持仓: %d | 保证金使用率: %.1f%%",
+			intValue(account, "position_count"), floatValue(account, "margin_used_pct")))
+		if pending := intValue(status, "pending_proposals"); pending > 0 {
+			builder.WriteString(fmt.Sprintf(" | 待审批: %d", pending))
+		}
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+// cmdPositions 当前持仓
+func (b *Bot) cmdPositions(userID string, args []string) string {
+	traders, err := b.filterTraders(userID, args)
+	if err != nil {
+		return "❌ " + err.Error()
+	}
+
+	var builder strings.Builder
+	builder.WriteString("📈 当前持仓\n")
+	for _, t := range traders {
+		builder.WriteString(fmt.Sprintf("\n🤖 %s\n", t.GetName()))
+		positions, err := t.GetPositions()
+		if err != nil {
+			builder.WriteString(fmt.Sprintf("  持仓获取失败: %v\n", err))
+			continue
+		}
+		if len(positions) == 0 {
+			builder.WriteString("  无持仓\n")
+			continue
+		}
+		for _, pos := range positions {
+			builder.WriteString(fmt.Sprintf("  %s %s %dx | 数量: %.4f | 开仓: %.4f | 标记: %.4f | 盈亏: %+.2f (%+.2f%%)\n",
+				pos["symbol"], strings.ToUpper(fmt.Sprint(pos["side"])), intValue(pos, "leverage"),
+				floatValue(pos, "quantity"), floatValue(pos, "entry_price"), floatValue(pos, "mark_price"),
+				floatValue(pos, "unrealized_pnl"), floatValue(pos, "unrealized_pnl_pct")))
+		}
+	}
+	return builder.String()
+}
+
+// cmdPnL 盈亏概览
+func (b *Bot) cmdPnL(userID string, args []string) string {
+	traders, err := b.filterTraders(userID, args)
+	if err != nil {
+		return "❌ " + err.Error()
+	}
+
+	var builder strings.Builder
+	builder.WriteString("💰 盈亏概览\n")
+	var totalEquity, totalPnL float64
+	for _, t := range traders {
+		account, err := t.GetAccountInfo()
+		if err != nil {
+			builder.WriteString(fmt.Sprintf("\n🤖 %s 账户获取失败: %v\n", t.GetName(), err))
+			continue
+		}
+		equity := floatValue(account, "total_equity")
+		pnl := floatValue(account, "total_pnl")
+		totalEquity += equity
+		totalPnL += pnl
+		builder.WriteString(fmt.Sprintf("\n🤖 %s\n  净值: %.2f | 初始: %.2f\n  总盈亏: %+.2f (%+.2f%%) | 未实现: %+.2f | 今日: %+.2f\n",
+			t.GetName(), equity, floatValue(account, "initial_balance"),
+			pnl, floatValue(account, "total_pnl_pct"), floatValue(account, "unrealized_profit"), floatValue(account, "daily_pnl")))
+	}
+	if len(traders) > 1 {
+		builder.WriteString(fmt.Sprintf("\n合计净值: %.2f | 合计盈亏: %+.2f\n", totalEquity, totalPnL))
+	}
+	return builder.String()
+}
+
+// cmdDecision 最近一次AI决策周期的思维链摘要
+func (b *Bot) cmdDecision(userID string, args []string) string {
+	t, err := b.selectTrader(userID, firstArg(args))
+	if err != nil {
+		return "❌ " + err.Error()
+	}
+
+	decisionLogger := t.GetDecisionLogger()
+	if decisionLogger == nil {
+		return "暂无决策记录"
+	}
+	records, err := decisionLogger.GetLatestRecords(20)
+	if err != nil {
+		return fmt.Sprintf("❌ 获取决策记录失败: %v", err)
+	}
+
+	// 只看AI决策周期（跳过手动操作、审批等记录）
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		if record.TriggeredBy != "" {
+			continue
+		}
+
+		var builder strings.Builder
+		builder.WriteString(fmt.Sprintf("🧠 %s 第 %d 周期 (%s)\n", t.GetName(), record.CycleNumber, record.Timestamp.Format("01-02 15:04:05")))
+		if record.ErrorMessage != "" {
+			builder.WriteString("⚠️ " + record.ErrorMessage + "\n")
+		}
+		if len(record.Decisions) > 0 {
+			builder.WriteString("\n决策:\n")
+			for _, action := range record.Decisions {
+				icon := "✅"
+				if !action.Success {
+					icon = "❌"
+				}
+				builder.WriteString(fmt.Sprintf("  %s %s %s\n", icon, action.Symbol, action.Action))
+			}
+		}
+		if record.CoTTrace != "" {
+			builder.WriteString("\n思维链摘要:\n" + truncateRunes(strings.TrimSpace(record.CoTTrace), 1500))
+		}
+		return builder.String()
+	}
+	return "暂无AI决策记录"
+}
+
+// cmdResume 恢复正常模式
+func (b *Bot) cmdResume(userID string, args []string) string {
+	t, err := b.selectTrader(userID, firstArg(args))
+	if err != nil {
+		return "❌ " + err.Error()
+	}
+	if err := t.SetTradingMode(trader.TradingModeNormal); err != nil {
+		return fmt.Sprintf("❌ 恢复失败: %v", err)
+	}
+	log.Printf("▶️ [Telegram] 交易员 %s 已恢复正常模式", t.GetName())
+	return fmt.Sprintf("▶️ %s 已恢复正常模式", t.GetName())
+}
+
+// cmdPause 暂停交易员（发送确认按钮）
+func (b *Bot) cmdPause(chatID int64, userID string, args []string) string {
+	t, err := b.selectTrader(userID, firstArg(args))
+	if err != nil {
+		return "❌ " + err.Error()
+	}
+	if t.GetTradingMode() == trader.TradingModePaused {
+		return fmt.Sprintf("%s 已处于暂停模式", t.GetName())
+	}
+
+	b.requestConfirmation(&pendingConfirmation{
+		chatID:   chatID,
+		userID:   userID,
+		traderID: t.GetID(),
+		action:   confirmActionPause,
+	}, fmt.Sprintf("⚠️ 确认暂停 %s？暂停后AI不再执行任何操作，已有持仓保留。", t.GetName()))
+	return ""
+}
+
+// cmdClose 平掉指定币种持仓（发送确认按钮）
+func (b *Bot) cmdClose(chatID int64, userID string, args []string) string {
+	if len(args) == 0 {
+		return "用法: /close <币种> [交易员]，例如 /close BTC"
+	}
+	symbol := normalizeSymbol(args[0])
+
+	t, err := b.selectTrader(userID, firstArg(args[1:]))
+	if err != nil {
+		return "❌ " + err.Error()
+	}
+	positions, err := symbolPositions(t, symbol)
+	if err != nil {
+		return fmt.Sprintf("❌ 获取持仓失败: %v", err)
+	}
+	if len(positions) == 0 {
+		return fmt.Sprintf("%s 没有 %s 持仓", t.GetName(), symbol)
+	}
+
+	var summary []string
+	for _, pos := range positions {
+		summary = append(summary, fmt.Sprintf("%s 数量 %.4f 盈亏 %+.2f",
+			strings.ToUpper(fmt.Sprint(pos["side"])), floatValue(pos, "quantity"), floatValue(pos, "unrealized_pnl")))
+	}
+	b.requestConfirmation(&pendingConfirmation{
+		chatID:   chatID,
+		userID:   userID,
+		traderID: t.GetID(),
+		action:   confirmActionClose,
+		symbol:   symbol,
+	}, fmt.Sprintf("⚠️ 确认以市价平掉 %s 的 %s 持仓？\n%s", t.GetName(), symbol, strings.Join(summary, "\n")))
+	return ""
+}
+
+// requestConfirmation 发送带确认/取消按钮的消息
+func (b *Bot) requestConfirmation(conf *pendingConfirmation, prompt string) {
+	token := uuid.New().String()
+	conf.expiresAt = time.Now().Add(confirmationTTL)
+
+	b.mu.Lock()
+	for id, pending := range b.confirmations {
+		if time.Now().After(pending.expiresAt) {
+			delete(b.confirmations, id)
+		}
+	}
+	b.confirmations[token] = conf
+	b.mu.Unlock()
+
+	msg := tgbotapi.NewMessage(conf.chatID, prompt)
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ 确认", callbackConfirm+token),
+			tgbotapi.NewInlineKeyboardButtonData("取消", callbackCancel+token),
+		),
+	)
+	if _, err := b.bot.Send(msg); err != nil {
+		log.Printf("⚠️ [Telegram] 发送确认消息失败: %v", err)
+	}
+}
+
+// handleConfirmationCallback 处理确认/取消按钮
+func (b *Bot) handleConfirmationCallback(cb *tgbotapi.CallbackQuery) {
+	confirmed := strings.HasPrefix(cb.Data, callbackConfirm)
+	token := strings.TrimPrefix(strings.TrimPrefix(cb.Data, callbackConfirm), callbackCancel)
+
+	b.mu.Lock()
+	conf, exists := b.confirmations[token]
+	if exists && conf.chatID == cb.Message.Chat.ID {
+		delete(b.confirmations, token)
+	}
+	b.mu.Unlock()
+
+	if !exists || conf.chatID != cb.Message.Chat.ID {
+		b.answer(cb.ID, "操作不存在或已处理")
+		return
+	}
+	if time.Now().After(conf.expiresAt) {
+		b.answer(cb.ID, "确认已过期")
+		b.editResult(cb.Message, "⌛ 确认已过期，请重新发送命令")
+		return
+	}
+	if !confirmed {
+		b.answer(cb.ID, "已取消")
+		b.editResult(cb.Message, "已取消")
+		return
+	}
+	// 确认期间聊天可能已解绑
+	if b.linkedUser(cb.Message.Chat.ID) != conf.userID {
+		b.answer(cb.ID, "无权操作")
+		return
+	}
+
+	result := b.executeConfirmation(conf, operatorName(cb.From))
+	b.answer(cb.ID, "已处理")
+	b.editResult(cb.Message, result)
+}
+
+// executeConfirmation 执行已确认的危险操作
+func (b *Bot) executeConfirmation(conf *pendingConfirmation, operator string) string {
+	t, err := b.selectTrader(conf.userID, conf.traderID)
+	if err != nil {
+		return "❌ " + err.Error()
+	}
+
+	switch conf.action {
+	case confirmActionPause:
+		if err := t.SetTradingMode(trader.TradingModePaused); err != nil {
+			return fmt.Sprintf("❌ 暂停失败: %v", err)
+		}
+		log.Printf("⏸️ [Telegram] 交易员 %s 已暂停 (by %s)", t.GetName(), operator)
+		return fmt.Sprintf("⏸️ %s 已暂停，发送 /resume 恢复", t.GetName())
+
+	case confirmActionClose:
+		positions, err := symbolPositions(t, conf.symbol)
+		if err != nil {
+			return fmt.Sprintf("❌ 获取持仓失败: %v", err)
+		}
+		if len(positions) == 0 {
+			return fmt.Sprintf("%s 已无 %s 持仓", t.GetName(), conf.symbol)
+		}
+
+		var lines []string
+		for _, pos := range positions {
+			side := fmt.Sprint(pos["side"])
+			action, err := t.ManualClosePosition(conf.symbol, side, 0, operator)
+			if err != nil {
+				lines = append(lines, fmt.Sprintf("❌ %s %s 平仓失败: %v", conf.symbol, strings.ToUpper(side), err))
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("✅ %s %s 已平仓 @ %.4f", conf.symbol, strings.ToUpper(side), action.Price))
+		}
+		return strings.Join(lines, "\n")
+	}
+	return "❌ 未知操作"
+}
+
+// filterTraders 获取用户的交易员，指定参数时只返回匹配的交易员
+func (b *Bot) filterTraders(userID string, args []string) ([]Trader, error) {
+	if len(args) > 0 {
+		t, err := b.selectTrader(userID, args[0])
+		if err != nil {
+			return nil, err
+		}
+		return []Trader{t}, nil
+	}
+
+	traders, err := b.userTraders(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(traders) == 0 {
+		return nil, fmt.Errorf("当前账户没有交易员")
+	}
+	return traders, nil
+}
+
+// selectTrader 按ID或名称（不区分大小写）选择交易员，只有一个交易员时可省略
+func (b *Bot) selectTrader(userID, key string) (Trader, error) {
+	traders, err := b.userTraders(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(traders) == 0 {
+		return nil, fmt.Errorf("当前账户没有交易员")
+	}
+
+	if key == "" {
+		if len(traders) == 1 {
+			return traders[0], nil
+		}
+		return nil, fmt.Errorf("请指定交易员: %s", traderNames(traders))
+	}
+	for _, t := range traders {
+		if t.GetID() == key || strings.EqualFold(t.GetName(), key) {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("交易员 %s 不存在，可选: %s", key, traderNames(traders))
+}
+
+// userTraders 获取用户的交易员（按名称排序）
+func (b *Bot) userTraders(userID string) ([]Trader, error) {
+	if b.traders == nil {
+		return nil, fmt.Errorf("交易员服务不可用")
+	}
+	traders, err := b.traders.GetUserTraders(userID)
+	if err != nil {
+		return nil, fmt.Errorf("获取交易员失败: %w", err)
+	}
+	sort.Slice(traders, func(i, j int) bool {
+		return traders[i].GetName() < traders[j].GetName()
+	})
+	return traders, nil
+}
+
+// symbolPositions 获取交易员在指定币种上的持仓（双向持仓时可能有多条）
+func symbolPositions(t Trader, symbol string) ([]map[string]interface{}, error) {
+	positions, err := t.GetPositions()
+	if err != nil {
+		return nil, err
+	}
+	var result []map[string]interface{}
+	for _, pos := range positions {
+		if fmt.Sprint(pos["symbol"]) == symbol {
+			result = append(result, pos)
+		}
+	}
+	return result, nil
+}
+
+func traderNames(traders []Trader) string {
+	names := make([]string, 0, len(traders))
+	for _, t := range traders {
+		names = append(names, t.GetName())
+	}
+	return strings.Join(names, ", ")
+}
+
+// normalizeSymbol BTC / btcusdt -> BTCUSDT
+func normalizeSymbol(symbol string) string {
+	symbol = strings.ToUpper(strings.TrimSpace(symbol))
+	if !strings.HasSuffix(symbol, "USDT") {
+		symbol += "USDT"
+	}
+	return symbol
+}
+
+func firstArg(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	return args[0]
+}
+
+func floatValue(m map[string]interface{}, key string) float64 {
+	switch v := m[key].(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	}
+	return 0
+}
+
+func intValue(m map[string]interface{}, key string) int {
+	switch v := m[key].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	}
+	return 0
+}
+
+// truncateRunes 按字符截断（避免截断多字节字符）
+func truncateRunes(text string, max int) string {
+	runes := []rune(text)
+	if len(runes) <= max {
+		return text
+	}
+	return string(runes[:max]) + "..."
+}
+
+func truncateMessage(text string) string {
+	return truncateRunes(text, maxMessageLength)
+}
@@ -0,0 +1,145 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"nofx/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBot_UnlinkedChatRejected(t *testing.T) {
+	env := newTestEnv(t)
+
+	env.command(999, "/status")
+	assert.Contains(t, env.server.lastText(t), "未绑定")
+
+	env.command(999, "/help")
+	assert.Contains(t, env.server.lastText(t), "/positions")
+}
+
+func TestBot_LinkAndUnlink(t *testing.T) {
+	env := newTestEnv(t)
+
+	env.command(999, "/link WRONG")
+	assert.Contains(t, env.server.lastText(t), "绑定失败")
+
+	env.command(999, "/link ABCD2345")
+	assert.Contains(t, env.server.lastText(t), "绑定成功")
+	assert.Equal(t, testUserID, env.links.chats[999])
+
+	env.command(999, "/unlink")
+	assert.Contains(t, env.server.lastText(t), "已解除绑定")
+	assert.Empty(t, env.links.chats[999])
+}
+
+func TestBot_QueryCommands(t *testing.T) {
+	env := newTestEnv(t)
+
+	env.command(testLinkedChatID, "/status")
+	text := env.server.lastText(t)
+	assert.Contains(t, text, "Alpha ▶️ 运行中")
+	assert.Contains(t, text, "Beta ⏹ 已停止")
+	assert.Contains(t, text, "净值: 1100.00 USDT | 总盈亏: +100.00 (+10.00%)")
+
+	env.command(testLinkedChatID, "/positions alpha")
+	text = env.server.lastText(t)
+	assert.Contains(t, text, "BTCUSDT LONG 10x")
+	assert.NotContains(t, text, "Beta")
+
+	env.command(testLinkedChatID, "/pnl")
+	assert.Contains(t, env.server.lastText(t), "合计净值: 1600.00 | 合计盈亏: +80.00")
+
+	env.command(testLinkedChatID, "/positions gamma")
+	assert.Contains(t, env.server.lastText(t), "交易员 gamma 不存在")
+}
+
+func TestBot_DecisionSummary(t *testing.T) {
+	env := newTestEnv(t)
+	env.traders[0].records = []*logger.DecisionRecord{
+		{CycleNumber: 7, Timestamp: time.Now(), CoTTrace: "BTC 突破关键阻力", Decisions: []logger.DecisionAction{{Symbol: "BTCUSDT", Action: "open_long", Success: true}}},
+		{CycleNumber: 0, Timestamp: time.Now(), TriggeredBy: "manual"},
+	}
+
+	// 多个交易员时必须指定
+	env.command(testLinkedChatID, "/decision")
+	assert.Contains(t, env.server.lastText(t), "请指定交易员")
+
+	env.command(testLinkedChatID, "/decision Alpha")
+	text := env.server.lastText(t)
+	assert.Contains(t, text, "第 7 周期")
+	assert.Contains(t, text, "✅ BTCUSDT open_long")
+	assert.Contains(t, text, "BTC 突破关键阻力")
+}
+
+func TestBot_PauseRequiresConfirmation(t *testing.T) {
+	env := newTestEnv(t)
+	alpha := env.traders[0]
+
+	env.command(testLinkedChatID, "/pause Alpha")
+	assert.Contains(t, env.server.lastText(t), "确认暂停 Alpha")
+	assert.Equal(t, "normal", alpha.mode, "确认前不应执行")
+
+	// 取消
+	env.click(testLinkedChatID, env.server.lastCallbackData(t, 1))
+	assert.Equal(t, "normal", alpha.mode)
+
+	env.command(testLinkedChatID, "/pause Alpha")
+	confirm := env.server.lastCallbackData(t, 0)
+
+	// 其他聊天不能确认
+	env.click(999, confirm)
+	assert.Equal(t, "normal", alpha.mode)
+
+	env.click(testLinkedChatID, confirm)
+	assert.Equal(t, "paused", alpha.mode)
+	edits := env.server.callsOf("editMessageText")
+	assert.Contains(t, edits[len(edits)-1].params["text"], "已暂停")
+
+	// 确认只能使用一次
+	alpha.mode = "normal"
+	env.click(testLinkedChatID, confirm)
+	assert.Equal(t, "normal", alpha.mode)
+
+	env.command(testLinkedChatID, "/resume Alpha")
+	assert.Contains(t, env.server.lastText(t), "已恢复正常模式")
+}
+
+func TestBot_CloseRequiresConfirmation(t *testing.T) {
+	env := newTestEnv(t)
+	alpha := env.traders[0]
+
+	env.command(testLinkedChatID, "/close eth alpha")
+	assert.Contains(t, env.server.lastText(t), "没有 ETHUSDT 持仓")
+
+	env.command(testLinkedChatID, "/close btc alpha")
+	assert.Contains(t, env.server.lastText(t), "确认以市价平掉 Alpha 的 BTCUSDT 持仓")
+	require.Empty(t, alpha.closed)
+
+	env.click(testLinkedChatID, env.server.lastCallbackData(t, 0))
+	assert.Equal(t, []string{"BTCUSDT_long_telegram:@alice"}, alpha.closed)
+	edits := env.server.callsOf("editMessageText")
+	assert.Contains(t, edits[len(edits)-1].params["text"], "BTCUSDT LONG 已平仓 @ 101.5000")
+}
+
+func TestBot_ExpiredConfirmation(t *testing.T) {
+	env := newTestEnv(t)
+
+	env.command(testLinkedChatID, "/pause Alpha")
+	confirm := env.server.lastCallbackData(t, 0)
+	for _, conf := range env.bot.confirmations {
+		conf.expiresAt = time.Now().Add(-time.Second)
+	}
+
+	env.click(testLinkedChatID, confirm)
+	assert.Equal(t, "normal", env.traders[0].mode)
+	edits := env.server.callsOf("editMessageText")
+	assert.Contains(t, edits[len(edits)-1].params["text"], "已过期")
+}
+
+func TestNormalizeSymbol(t *testing.T) {
+	assert.Equal(t, "BTCUSDT", normalizeSymbol("btc"))
+	assert.Equal(t, "ETHUSDT", normalizeSymbol(" ethusdt "))
+}
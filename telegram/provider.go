@@ -0,0 +1,38 @@
+package telegram
+
+import (
+	"fmt"
+
+	"nofx/config"
+	"nofx/manager"
+	"nofx/trader"
+)
+
+var _ Trader = (*trader.AutoTrader)(nil)
+
+// ManagerProvider 基于 TraderManager 与数据库按用户获取交易员
+type ManagerProvider struct {
+	Manager  *manager.TraderManager
+	Database *config.Database
+}
+
+// GetUserTraders 获取用户拥有的交易员（未加载到内存的会先加载）
+func (p *ManagerProvider) GetUserTraders(userID string) ([]Trader, error) {
+	records, err := p.Database.GetTraders(userID)
+	if err != nil {
+		return nil, fmt.Errorf("获取交易员列表失败: %w", err)
+	}
+	if err := p.Manager.LoadUserTraders(p.Database, userID); err != nil {
+		return nil, fmt.Errorf("加载交易员失败: %w", err)
+	}
+
+	traders := make([]Trader, 0, len(records))
+	for _, record := range records {
+		at, err := p.Manager.GetTrader(record.ID)
+		if err != nil {
+			continue
+		}
+		traders = append(traders, at)
+	}
+	return traders, nil
+}
@@ -0,0 +1,467 @@
+package trader
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"nofx/decision"
+	"nofx/logger"
+	"nofx/market"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 提案状态
+const (
+	ProposalStatusPending  = "pending"
+	ProposalStatusApproved = "approved"
+	ProposalStatusRejected = "rejected"
+	ProposalStatusExpired  = "expired"
+	ProposalStatusFailed   = "failed" // 已批准但执行失败
+)
+
+// 审批设置默认值
+const (
+	defaultApprovalExpiryMinutes     = 15
+	defaultApprovalPriceTolerancePct = 0.5
+
+	resolvedProposalRetention = 24 * time.Hour // 已处理提案在内存中的保留时间（决策日志中保留完整记录）
+	maxResolvedProposals      = 200            // 内存中最多保留的已处理提案数
+)
+
+// ApprovalSettings 人工审批设置（阈值为 0 表示不限制，即所有开仓都需审批）
+type ApprovalSettings struct {
+	Required          bool    `json:"required"`            // 是否需要人工审批开仓
+	MinNotional       float64 `json:"min_notional"`        // 仓位价值 ≥ 该值（USDT）时需审批
+	MinLeverage       int     `json:"min_leverage"`        // 杠杆 ≥ 该值时需审批
+	ExpiryMinutes     int     `json:"expiry_minutes"`      // 提案有效期（分钟）
+	PriceTolerancePct float64 `json:"price_tolerance_pct"` // 批准时允许的价格偏离（百分比）
+}
+
+// Proposal AI开仓提案（等待人工审批）
+type Proposal struct {
+	ID              string    `json:"id"`
+	TraderID        string    `json:"trader_id"`
+	TraderName      string    `json:"trader_name"`
+	UserID          string    `json:"user_id"`
+	Symbol          string    `json:"symbol"`
+	Action          string    `json:"action"`
+	Leverage        int       `json:"leverage"`
+	PositionSizeUSD float64   `json:"position_size_usd"`
+	StopLoss        float64   `json:"stop_loss"`
+	TakeProfit      float64   `json:"take_profit"`
+	Confidence      int       `json:"confidence"`
+	Reasoning       string    `json:"reasoning"`
+	ProposedPrice   float64   `json:"proposed_price"`
+	Status          string    `json:"status"`
+	CreatedAt       time.Time `json:"created_at"`
+	ExpiresAt       time.Time `json:"expires_at"`
+	ResolvedAt      time.Time `json:"resolved_at,omitempty"`
+	ResolvedBy      string    `json:"resolved_by,omitempty"`
+	Note            string    `json:"note,omitempty"`
+
+	decision decision.Decision     // 原始决策（批准后执行）
+	entry    *logger.EntrySnapshot // 提案时的行情快照（批准后写入开仓记录）
+}
+
+// ProposalNotifier 提案通知（如 Telegram 审批按钮）
+type ProposalNotifier interface {
+	NotifyProposal(p *Proposal)
+}
+
+var (
+	proposalNotifier      ProposalNotifier
+	proposalNotifierMutex sync.RWMutex
+)
+
+// SetProposalNotifier 设置全局提案通知器（nil 表示关闭通知）
+func SetProposalNotifier(notifier ProposalNotifier) {
+	proposalNotifierMutex.Lock()
+	defer proposalNotifierMutex.Unlock()
+	proposalNotifier = notifier
+}
+
+func notifyProposal(p *Proposal) {
+	proposalNotifierMutex.RLock()
+	notifier := proposalNotifier
+	proposalNotifierMutex.RUnlock()
+	if notifier != nil {
+		notifier.NotifyProposal(p)
+	}
+}
+
+// SetApprovalSettings 设置人工审批配置
+func (at *AutoTrader) SetApprovalSettings(settings ApprovalSettings) {
+	if settings.ExpiryMinutes <= 0 {
+		settings.ExpiryMinutes = defaultApprovalExpiryMinutes
+	}
+	if settings.PriceTolerancePct <= 0 {
+		settings.PriceTolerancePct = defaultApprovalPriceTolerancePct
+	}
+
+	at.proposalsMutex.Lock()
+	at.approvalSettings = settings
+	at.proposalsMutex.Unlock()
+}
+
+// GetApprovalSettings 获取人工审批配置
+func (at *AutoTrader) GetApprovalSettings() ApprovalSettings {
+	at.proposalsMutex.RLock()
+	defer at.proposalsMutex.RUnlock()
+	return at.approvalSettings
+}
+
+// needsApproval 判断开仓决策是否需要人工审批
+func (at *AutoTrader) needsApproval(d *decision.Decision) bool {
+	if d.Action != "open_long" && d.Action != "open_short" {
+		return false
+	}
+
+	settings := at.GetApprovalSettings()
+	if !settings.Required {
+		return false
+	}
+	// 未设置阈值：所有开仓都需审批
+	if settings.MinNotional <= 0 && settings.MinLeverage <= 0 {
+		return true
+	}
+	if settings.MinNotional > 0 && d.PositionSizeUSD >= settings.MinNotional {
+		return true
+	}
+	if settings.MinLeverage > 0 && d.Leverage >= settings.MinLeverage {
+		return true
+	}
+	return false
+}
+
+// createProposal 将开仓决策转为待审批提案
+func (at *AutoTrader) createProposal(d *decision.Decision) (*Proposal, error) {
+	marketData, err := market.Get(d.Symbol)
+	if err != nil {
+		return nil, fmt.Errorf("获取提案价格失败: %w", err)
+	}
+
+	settings := at.GetApprovalSettings()
+	now := time.Now()
+	p := &Proposal{
+		ID:              uuid.New().String(),
+		TraderID:        at.id,
+		TraderName:      at.name,
+		UserID:          at.userID,
+		Symbol:          d.Symbol,
+		Action:          d.Action,
+		Leverage:        d.Leverage,
+		PositionSizeUSD: d.PositionSizeUSD,
+		StopLoss:        d.StopLoss,
+		TakeProfit:      d.TakeProfit,
+		Confidence:      d.Confidence,
+		Reasoning:       d.Reasoning,
+		ProposedPrice:   marketData.CurrentPrice,
+		Status:          ProposalStatusPending,
+		CreatedAt:       now,
+		ExpiresAt:       now.Add(time.Duration(settings.ExpiryMinutes) * time.Minute),
+		decision:        *d,
+	}
+
+	at.proposalsMutex.Lock()
+	if at.proposals == nil {
+		at.proposals = make(map[string]*Proposal)
+	}
+	at.proposals[p.ID] = p
+	at.proposalsMutex.Unlock()
+
+	log.Printf("📝 [%s] 开仓提案等待审批: %s %s %.2f USDT %dx @ %.4f (有效期至 %s)",
+		at.name, p.Symbol, p.Action, p.PositionSizeUSD, p.Leverage, p.ProposedPrice, p.ExpiresAt.Format("15:04:05"))
+	notifyProposal(p)
+	return p, nil
+}
+
+// GetProposals 获取提案列表（status 为空时返回全部），按创建时间倒序
+func (at *AutoTrader) GetProposals(status string) []Proposal {
+	at.expireProposals()
+
+	at.proposalsMutex.RLock()
+	result := make([]Proposal, 0, len(at.proposals))
+	for _, p := range at.proposals {
+		if status == "" || p.Status == status {
+			result = append(result, *p)
+		}
+	}
+	at.proposalsMutex.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	return result
+}
+
+// ApproveProposal 批准提案：价格仍在容差范围内且重新风控通过时执行开仓
+func (at *AutoTrader) ApproveProposal(proposalID, approvedBy string) (*logger.DecisionAction, error) {
+	at.expireProposals()
+
+	at.executionMutex.Lock()
+	defer at.executionMutex.Unlock()
+
+	p, err := at.takePendingProposal(proposalID)
+	if err != nil {
+		return nil, err
+	}
+
+	d := p.decision
+	actionRecord := &logger.DecisionAction{
+		Action:     d.Action,
+		Symbol:     d.Symbol,
+		Leverage:   d.Leverage,
+		Timestamp:  time.Now(),
+		Reason:     d.Reasoning,
+		ProposalID: p.ID,
+		Confidence: d.Confidence,
+		RiskUSD:    d.RiskUSD,
+		Entry:      p.entry,
+	}
+
+	// 运行模式校验（暂停/只减仓时不执行开仓）
+	if mode := at.GetTradingMode(); mode != TradingModeNormal {
+		err = fmt.Errorf("当前运行模式 %s 不允许开仓", mode)
+		at.resolveProposal(p, ProposalStatusRejected, approvedBy, err.Error(), actionRecord)
+		return actionRecord, err
+	}
+
+	// 市场状态策略校验（提案后市场状态可能已切换）
+	if allowed, note := at.checkCurrentRegimePolicy(&d); !allowed {
+		err = fmt.Errorf("市场状态策略不允许开仓: %s", note)
+		at.resolveProposal(p, ProposalStatusRejected, approvedBy, err.Error(), actionRecord)
+		return actionRecord, err
+	} else if note != "" {
+		log.Printf("🧭 [%s] 审批提案 %s 市场状态策略调整: %s", at.name, p.ID, note)
+		actionRecord.Leverage = d.Leverage
+	}
+
+	// 价格偏离校验
+	marketData, err := market.Get(d.Symbol)
+	if err != nil {
+		err = fmt.Errorf("获取当前价格失败: %w", err)
+		at.resolveProposal(p, ProposalStatusFailed, approvedBy, err.Error(), actionRecord)
+		return actionRecord, err
+	}
+	settings := at.GetApprovalSettings()
+	if deviation := priceDeviationPct(p.ProposedPrice, marketData.CurrentPrice); deviation > settings.PriceTolerancePct {
+		err = fmt.Errorf("价格偏离 %.2f%% 超过容差 %.2f%%（提案价: %.4f, 当前价: %.4f）",
+			deviation, settings.PriceTolerancePct, p.ProposedPrice, marketData.CurrentPrice)
+		at.resolveProposal(p, ProposalStatusRejected, approvedBy, err.Error(), actionRecord)
+		return actionRecord, err
+	}
+
+	// 重新执行风控（提案后持仓数、保证金、回撤、盘口与资金费率都可能已变化）
+	at.invalidateDecisionCyclePositions()
+	ctx, err := at.approvalContext(d.Symbol, marketData, d.PositionSizeUSD)
+	if err != nil {
+		err = fmt.Errorf("构建交易上下文失败: %w", err)
+		at.resolveProposal(p, ProposalStatusFailed, approvedBy, err.Error(), actionRecord)
+		return actionRecord, err
+	}
+	if allowed, note := at.applyRiskGuards(ctx, &d); !allowed {
+		at.publishRiskVeto(&d, "risk_guard", note)
+		err = fmt.Errorf("风控不允许开仓: %s", note)
+		at.resolveProposal(p, ProposalStatusRejected, approvedBy, err.Error(), actionRecord)
+		return actionRecord, err
+	} else if note != "" {
+		log.Printf("⚠️ [%s] 审批提案 %s 风控调整: %s", at.name, p.ID, note)
+	}
+
+	// 按审批时的仓位执行，不再重新计算或放大
+	d.SizeLocked = true
+	if err := at.executeDecisionWithRecord(&d, actionRecord); err != nil {
+		at.resolveProposal(p, ProposalStatusFailed, approvedBy, err.Error(), actionRecord)
+		return actionRecord, err
+	}
+
+	at.resolveProposal(p, ProposalStatusApproved, approvedBy, "", actionRecord)
+	at.publishActionEvents(*actionRecord, EventSourceApproval)
+	return actionRecord, nil
+}
+
+// approvalContext 批准时重新构建交易上下文，补充提案币种的盘口、资金费率与全局市场状态（调用方需持有 executionMutex）
+func (at *AutoTrader) approvalContext(symbol string, data *market.Data, positionSizeUSD float64) (*decision.Context, error) {
+	ctx, err := at.buildTradingContext()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := *data
+	if at.dataSource == nil {
+		if ob, err := market.GetOrderBookMetrics(symbol, positionSizeUSD); err == nil {
+			snapshot.OrderBook = ob
+		} else {
+			log.Printf("⚠️  获取 %s 盘口数据失败: %v", symbol, err)
+		}
+		if funding, err := market.GetFundingInfo(symbol); err == nil {
+			snapshot.Funding = funding
+		} else {
+			log.Printf("⚠️  获取 %s 资金费率概况失败: %v", symbol, err)
+		}
+	}
+	ctx.MarketDataMap = map[string]*market.Data{symbol: &snapshot}
+
+	// 与决策周期一致：以 BTCUSDT 行情评估全局市场状态（极端波动风控依赖该结果）
+	if symbol != "BTCUSDT" {
+		var btcData *market.Data
+		if at.dataSource != nil {
+			btcData, err = market.GetFromSource(at.dataSource, "BTCUSDT")
+		} else {
+			btcData, err = market.Get("BTCUSDT")
+		}
+		if err == nil {
+			ctx.MarketDataMap["BTCUSDT"] = btcData
+		} else {
+			log.Printf("⚠️  获取 BTCUSDT 市场数据失败: %v", err)
+		}
+	}
+	ctx.MarketSummary = decision.AnalyzeMarketSummary(ctx)
+	return ctx, nil
+}
+
+// RejectProposal 拒绝提案
+func (at *AutoTrader) RejectProposal(proposalID, rejectedBy, note string) error {
+	at.expireProposals()
+
+	p, err := at.takePendingProposal(proposalID)
+	if err != nil {
+		return err
+	}
+
+	if strings.TrimSpace(note) == "" {
+		note = "人工拒绝"
+	}
+	actionRecord := &logger.DecisionAction{
+		Action:     p.Action,
+		Symbol:     p.Symbol,
+		Leverage:   p.Leverage,
+		Timestamp:  time.Now(),
+		Reason:     p.Reasoning,
+		ProposalID: p.ID,
+	}
+	at.resolveProposal(p, ProposalStatusRejected, rejectedBy, note, actionRecord)
+	return nil
+}
+
+// takePendingProposal 取出待审批提案并标记为处理中（防止重复处理）
+func (at *AutoTrader) takePendingProposal(proposalID string) (*Proposal, error) {
+	at.proposalsMutex.Lock()
+	defer at.proposalsMutex.Unlock()
+
+	p, ok := at.proposals[proposalID]
+	if !ok {
+		return nil, fmt.Errorf("提案不存在: %s", proposalID)
+	}
+	if p.Status != ProposalStatusPending {
+		return nil, fmt.Errorf("提案已处理（状态: %s）", p.Status)
+	}
+	p.Status = "processing"
+	return p, nil
+}
+
+// expireProposals 将过期的待审批提案标记为 expired 并记录，同时清理超出保留期限的已处理提案
+func (at *AutoTrader) expireProposals() {
+	now := time.Now()
+	var expired []*Proposal
+
+	at.proposalsMutex.Lock()
+	for _, p := range at.proposals {
+		if p.Status == ProposalStatusPending && now.After(p.ExpiresAt) {
+			p.Status = "processing"
+			expired = append(expired, p)
+		}
+	}
+	at.pruneResolvedProposals(now)
+	at.proposalsMutex.Unlock()
+
+	for _, p := range expired {
+		actionRecord := &logger.DecisionAction{
+			Action:     p.Action,
+			Symbol:     p.Symbol,
+			Leverage:   p.Leverage,
+			Timestamp:  now,
+			Reason:     p.Reasoning,
+			ProposalID: p.ID,
+		}
+		at.resolveProposal(p, ProposalStatusExpired, "system", "提案已过期", actionRecord)
+	}
+}
+
+// pruneResolvedProposals 删除超过保留期限的已处理提案，并限制保留数量（调用方需持有 proposalsMutex）
+func (at *AutoTrader) pruneResolvedProposals(now time.Time) {
+	var resolved []*Proposal
+	for id, p := range at.proposals {
+		if p.ResolvedAt.IsZero() {
+			continue
+		}
+		if now.Sub(p.ResolvedAt) > resolvedProposalRetention {
+			delete(at.proposals, id)
+			continue
+		}
+		resolved = append(resolved, p)
+	}
+	if len(resolved) <= maxResolvedProposals {
+		return
+	}
+	sort.Slice(resolved, func(i, j int) bool {
+		return resolved[i].ResolvedAt.Before(resolved[j].ResolvedAt)
+	})
+	for _, p := range resolved[:len(resolved)-maxResolvedProposals] {
+		delete(at.proposals, p.ID)
+	}
+}
+
+// resolveProposal 更新提案状态并写入决策日志（TriggeredBy=approval）
+func (at *AutoTrader) resolveProposal(p *Proposal, status, by, note string, actionRecord *logger.DecisionAction) {
+	at.proposalsMutex.Lock()
+	p.Status = status
+	p.ResolvedAt = time.Now()
+	p.ResolvedBy = by
+	p.Note = note
+	at.proposalsMutex.Unlock()
+
+	actionRecord.ProposalStatus = status
+	actionRecord.Success = status == ProposalStatusApproved
+	if !actionRecord.Success {
+		actionRecord.Error = note
+	}
+
+	icon := map[string]string{
+		ProposalStatusApproved: "✅",
+		ProposalStatusRejected: "🚫",
+		ProposalStatusExpired:  "⌛",
+		ProposalStatusFailed:   "❌",
+	}[status]
+	logLine := fmt.Sprintf("%s [审批] %s %s 提案 %s: %s", icon, p.Symbol, p.Action, status, orDefault(note, "已执行"))
+	log.Printf("%s (by %s)", logLine, by)
+
+	record := &logger.DecisionRecord{
+		Exchange:     at.config.Exchange,
+		Decisions:    []logger.DecisionAction{*actionRecord},
+		ExecutionLog: []string{logLine},
+		Success:      actionRecord.Success,
+		TriggeredBy:  "approval",
+	}
+	if !record.Success {
+		record.ErrorMessage = note
+	}
+	if at.decisionLogger != nil {
+		if err := at.decisionLogger.LogDecision(record); err != nil {
+			log.Printf("⚠ [%s] 保存审批记录失败: %v", at.name, err)
+		}
+	}
+}
+
+// priceDeviationPct 计算价格偏离百分比
+func priceDeviationPct(proposed, current float64) float64 {
+	if proposed <= 0 {
+		return math.Inf(1)
+	}
+	return math.Abs(current-proposed) / proposed * 100
+}
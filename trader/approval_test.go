@@ -0,0 +1,204 @@
+package trader
+
+import (
+	"errors"
+	"fmt"
+	"nofx/decision"
+	"nofx/logger"
+	"nofx/market"
+	"time"
+)
+
+// fakeProposalNotifier 记录推送的提案
+type fakeProposalNotifier struct {
+	proposals []*Proposal
+}
+
+func (f *fakeProposalNotifier) NotifyProposal(p *Proposal) {
+	f.proposals = append(f.proposals, p)
+}
+
+// patchPrice 固定 market.Get 返回的价格（盘口与资金费率不可用）
+func (s *AutoTraderTestSuite) patchPrice(price *float64) {
+	s.patches.ApplyFunc(market.Get, func(symbol string) (*market.Data, error) {
+		return &market.Data{Symbol: symbol, CurrentPrice: *price}, nil
+	})
+	s.patches.ApplyFunc(market.GetOrderBookMetrics, func(symbol string, sizeUSD float64) (*market.OrderBookMetrics, error) {
+		return nil, errors.New("no depth")
+	})
+	s.patches.ApplyFunc(market.GetFundingInfo, func(symbol string) (*market.FundingInfo, error) {
+		return nil, errors.New("no funding")
+	})
+}
+
+// TestNeedsApproval 测试审批阈值判断
+func (s *AutoTraderTestSuite) TestNeedsApproval() {
+	tests := []struct {
+		name     string
+		settings ApprovalSettings
+		d        decision.Decision
+		expected bool
+	}{
+		{"未开启审批", ApprovalSettings{}, decision.Decision{Action: "open_long", PositionSizeUSD: 5000, Leverage: 20}, false},
+		{"无阈值_所有开仓需审批", ApprovalSettings{Required: true}, decision.Decision{Action: "open_short", PositionSizeUSD: 10, Leverage: 1}, true},
+		{"平仓无需审批", ApprovalSettings{Required: true}, decision.Decision{Action: "close_long"}, false},
+		{"仓位价值达到阈值", ApprovalSettings{Required: true, MinNotional: 1000}, decision.Decision{Action: "open_long", PositionSizeUSD: 1000, Leverage: 2}, true},
+		{"仓位价值低于阈值", ApprovalSettings{Required: true, MinNotional: 1000}, decision.Decision{Action: "open_long", PositionSizeUSD: 999, Leverage: 2}, false},
+		{"杠杆达到阈值", ApprovalSettings{Required: true, MinNotional: 1000, MinLeverage: 10}, decision.Decision{Action: "open_long", PositionSizeUSD: 100, Leverage: 10}, true},
+		{"杠杆低于阈值", ApprovalSettings{Required: true, MinLeverage: 10}, decision.Decision{Action: "open_long", PositionSizeUSD: 5000, Leverage: 5}, false},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.autoTrader.SetApprovalSettings(tt.settings)
+			s.Equal(tt.expected, s.autoTrader.needsApproval(&tt.d))
+		})
+	}
+
+	// 未设置时补齐默认值
+	settings := s.autoTrader.GetApprovalSettings()
+	s.Equal(defaultApprovalExpiryMinutes, settings.ExpiryMinutes)
+	s.Equal(defaultApprovalPriceTolerancePct, settings.PriceTolerancePct)
+}
+
+// TestApproveProposal 测试批准提案：价格在容差内执行开仓，超出容差则拒绝
+func (s *AutoTraderTestSuite) TestApproveProposal() {
+	price := 50000.0
+	s.patchPrice(&price)
+	s.autoTrader.decisionLogger = logger.NewDecisionLogger(s.T().TempDir())
+	s.autoTrader.SetApprovalSettings(ApprovalSettings{Required: true, PriceTolerancePct: 0.5})
+
+	notifier := &fakeProposalNotifier{}
+	SetProposalNotifier(notifier)
+	defer SetProposalNotifier(nil)
+
+	d := &decision.Decision{Action: "open_long", Symbol: "BTCUSDT", PositionSizeUSD: 1000, Leverage: 10, StopLoss: 48000, TakeProfit: 55000}
+	p, err := s.autoTrader.createProposal(d)
+	s.Require().NoError(err)
+	s.Require().Len(notifier.proposals, 1)
+	s.Equal(ProposalStatusPending, p.Status)
+	s.Equal(50000.0, p.ProposedPrice)
+	s.Len(s.autoTrader.GetProposals(ProposalStatusPending), 1)
+
+	// 价格小幅波动（0.2%）仍可执行
+	price = 50100.0
+	action, err := s.autoTrader.ApproveProposal(p.ID, "tester")
+	s.Require().NoError(err)
+	s.True(action.Success)
+	s.Equal(int64(123456), action.OrderID)
+	s.Equal(ProposalStatusApproved, action.ProposalStatus)
+
+	// 重复批准失败
+	_, err = s.autoTrader.ApproveProposal(p.ID, "tester")
+	s.Error(err)
+
+	// 价格偏离超过容差
+	s.mockTrader.positions = []map[string]interface{}{}
+	price = 50000.0
+	p2, err := s.autoTrader.createProposal(&decision.Decision{Action: "open_short", Symbol: "ETHUSDT", PositionSizeUSD: 500, Leverage: 5})
+	s.Require().NoError(err)
+	price = 50500.0
+	_, err = s.autoTrader.ApproveProposal(p2.ID, "tester")
+	s.Error(err)
+	s.Contains(err.Error(), "价格偏离")
+	s.Len(s.autoTrader.GetProposals(ProposalStatusRejected), 1)
+
+	// 提案后持仓已达上限：批准时重新执行风控并拒绝
+	s.mockTrader.positions = []map[string]interface{}{}
+	p3, err := s.autoTrader.createProposal(&decision.Decision{Action: "open_long", Symbol: "SOLUSDT", PositionSizeUSD: 500, Leverage: 5})
+	s.Require().NoError(err)
+	for _, symbol := range []string{"BTCUSDT", "ETHUSDT", "BNBUSDT"} {
+		s.mockTrader.positions = append(s.mockTrader.positions, map[string]interface{}{
+			"symbol": symbol, "side": "long", "positionAmt": 0.1, "entryPrice": 100.0, "markPrice": 100.0,
+			"unRealizedProfit": 0.0, "liquidationPrice": 50.0, "leverage": 5.0,
+		})
+	}
+	_, err = s.autoTrader.ApproveProposal(p3.ID, "tester")
+	s.Require().Error(err)
+	s.Contains(err.Error(), "风控不允许开仓")
+
+	// 提案后市场进入极端波动：批准时重新评估市场状态并拒绝
+	s.mockTrader.positions = []map[string]interface{}{}
+	p4, err := s.autoTrader.createProposal(&decision.Decision{Action: "open_long", Symbol: "SOLUSDT", PositionSizeUSD: 500, Leverage: 5})
+	s.Require().NoError(err)
+	s.patches.ApplyFunc(market.Get, func(symbol string) (*market.Data, error) {
+		return &market.Data{
+			Symbol:            symbol,
+			CurrentPrice:      price,
+			IntradaySeries:    &market.IntradayData{ATR14: 2},
+			LongerTermContext: &market.LongerTermData{ATR14: 1},
+		}, nil
+	})
+	_, err = s.autoTrader.ApproveProposal(p4.ID, "tester")
+	s.Require().Error(err)
+	s.Contains(err.Error(), "极端波动")
+
+	records, err := s.autoTrader.decisionLogger.GetLatestRecords(10)
+	s.Require().NoError(err)
+	s.Require().Len(records, 4)
+	for _, r := range records {
+		s.Equal("approval", r.TriggeredBy)
+	}
+}
+
+// TestRejectAndExpireProposal 测试拒绝与过期提案写入决策日志
+func (s *AutoTraderTestSuite) TestRejectAndExpireProposal() {
+	price := 3000.0
+	s.patchPrice(&price)
+	s.autoTrader.decisionLogger = logger.NewDecisionLogger(s.T().TempDir())
+	s.autoTrader.SetApprovalSettings(ApprovalSettings{Required: true})
+
+	p1, err := s.autoTrader.createProposal(&decision.Decision{Action: "open_long", Symbol: "ETHUSDT", PositionSizeUSD: 300, Leverage: 3})
+	s.Require().NoError(err)
+	p2, err := s.autoTrader.createProposal(&decision.Decision{Action: "open_short", Symbol: "SOLUSDT", PositionSizeUSD: 200, Leverage: 3})
+	s.Require().NoError(err)
+
+	s.Require().NoError(s.autoTrader.RejectProposal(p1.ID, "tester", ""))
+	s.Error(s.autoTrader.RejectProposal(p1.ID, "tester", ""), "已处理的提案不能再次拒绝")
+
+	// 模拟过期
+	s.autoTrader.proposalsMutex.Lock()
+	s.autoTrader.proposals[p2.ID].ExpiresAt = time.Now().Add(-time.Second)
+	s.autoTrader.proposalsMutex.Unlock()
+
+	expired := s.autoTrader.GetProposals(ProposalStatusExpired)
+	s.Require().Len(expired, 1)
+	s.Equal(p2.ID, expired[0].ID)
+
+	_, err = s.autoTrader.ApproveProposal(p2.ID, "tester")
+	s.Error(err, "过期提案不能批准")
+
+	records, err := s.autoTrader.decisionLogger.GetLatestRecords(10)
+	s.Require().NoError(err)
+	s.Require().Len(records, 2)
+	statuses := map[string]string{}
+	for _, r := range records {
+		s.Equal("approval", r.TriggeredBy)
+		s.False(r.Success)
+		statuses[r.Decisions[0].ProposalID] = r.Decisions[0].ProposalStatus
+	}
+	s.Equal(ProposalStatusRejected, statuses[p1.ID])
+	s.Equal(ProposalStatusExpired, statuses[p2.ID])
+}
+
+// TestPruneResolvedProposals 测试已处理提案超过保留期限或数量上限后从内存中清理
+func (s *AutoTraderTestSuite) TestPruneResolvedProposals() {
+	now := time.Now()
+	s.autoTrader.proposals = map[string]*Proposal{
+		"pending": {ID: "pending", Status: ProposalStatusPending, ExpiresAt: now.Add(time.Hour)},
+		"old":     {ID: "old", Status: ProposalStatusRejected, ResolvedAt: now.Add(-resolvedProposalRetention - time.Minute)},
+		"recent":  {ID: "recent", Status: ProposalStatusApproved, ResolvedAt: now.Add(-time.Minute)},
+	}
+	for i := 0; i < maxResolvedProposals; i++ {
+		id := fmt.Sprintf("bulk-%d", i)
+		s.autoTrader.proposals[id] = &Proposal{ID: id, Status: ProposalStatusExpired, ResolvedAt: now.Add(-time.Duration(i+2) * time.Minute)}
+	}
+
+	s.autoTrader.expireProposals()
+
+	s.Len(s.autoTrader.proposals, maxResolvedProposals+1)
+	s.Contains(s.autoTrader.proposals, "pending", "待审批提案不应被清理")
+	s.Contains(s.autoTrader.proposals, "recent")
+	s.NotContains(s.autoTrader.proposals, "old", "超过保留期限的提案应被清理")
+	s.NotContains(s.autoTrader.proposals, fmt.Sprintf("bulk-%d", maxResolvedProposals-1), "超出数量上限时应先清理最早处理的提案")
+}
@@ -7,13 +7,16 @@ import (
 	"math"
 	"nofx/config"
 	"nofx/decision"
+	"nofx/events"
 	"nofx/logger"
 	"nofx/market"
 	"nofx/mcp"
 	"nofx/pool"
 	"os"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -82,6 +85,23 @@ type AutoTraderConfig struct {
 	// 系统提示词模板
 	SystemPromptTemplate string // 系统提示词模板名称（如 "default", "aggressive"）
 
+	// 用户提示词模板（prompts/user/*.tmpl，为空时使用 default）
+	UserPromptTemplate string
+
+	// 提示词 token 预算（<=0 时按AI服务商默认预算）
+	PromptTokenBudget int
+
+	// 智能体模式：AI 可多轮调用工具按需获取数据（最多 AgentMaxSteps 轮，<=0 时使用默认值）
+	AgentMode     bool
+	AgentMaxSteps int
+
+	// 仓位计算模式：ai（默认，使用AI给出的仓位）或 kelly（按信心度校准的分数Kelly，KellyFraction<=0 时使用默认值）
+	PositionSizingMode string
+	KellyFraction      float64
+
+	// 市场状态策略：状态（trending/ranging/high_volatility/risk_off）→ 系统提示词模板、杠杆上限、禁止新开仓
+	RegimePolicies map[string]decision.RegimePolicy
+
 	// 订单策略配置
 	OrderStrategy       string  // Order strategy: "market_only", "conservative_hybrid", "limit_only"
 	LimitPriceOffset    float64 // Limit order price offset percentage (e.g., -0.03 for -0.03%)
@@ -89,6 +109,9 @@ type AutoTraderConfig struct {
 
 	// K线时间周期配置
 	Timeframes string // 时间周期列表（逗号分隔，例如："1m,3m,1h,4h,1d"）
+
+	// 指标配置（为空时只使用内置的固定指标）
+	Indicators []market.IndicatorSpec
 }
 
 // AutoTrader 自动交易器
@@ -99,6 +122,10 @@ type AutoTrader struct {
 	exchange              string // 交易平台名称
 	config                AutoTraderConfig
 	trader                Trader // 使用Trader接口（支持多平台）
+	dataSource            market.DataSource // 交易所自身的行情数据源（币安为空，使用 WSMonitor 行情）
+	klineLease            *market.KlineLease // 本交易员持有的K线订阅（币种 × 时间周期，多个交易员共享）
+	depthLease            *market.OrderBookLease // 本交易员持有的盘口深度订阅（多个交易员共享本地订单簿）
+	flowLease             *market.TradeFlowLease // 本交易员持有的强平与成交流订阅（多个交易员共享统计）
 	mcpClient             mcp.AIClient
 	decisionLogger        logger.IDecisionLogger // 决策日志记录器
 	initialBalance        float64
@@ -128,10 +155,34 @@ type AutoTrader struct {
 	decisionCyclePositions []map[string]interface{}        // 决策周期内的持仓缓存（减少API调用）
 	decisionCyclePositionsTime time.Time                    // 持仓缓存时间
 	decisionCyclePositionsMutex sync.RWMutex               // 持仓缓存读写锁
+	executionMutex        sync.Mutex                       // 串行化AI决策执行与手动交易操作
+	tradingMode           string                           // 运行模式（normal/paused/reduce_only/close_out）
+	tradingModeMutex      sync.RWMutex                     // 运行模式读写锁
+	approvalSettings      ApprovalSettings                 // 人工审批设置
+	proposals             map[string]*Proposal             // 开仓审批提案 (proposal_id -> proposal)
+	proposalsMutex        sync.RWMutex                     // 审批设置与提案读写锁
+	experimentID          string                           // 所属A/B实验ID（为空表示不在实验中）
+	experimentArm         string                           // 实验分组（对照组为 control）
+	paperTrading          bool                             // 影子交易员：模拟下单，不推送事件
+	shadows               []*AutoTrader                    // 对照组附带的影子交易员（每个周期同时触发）
+	shadowBusy            atomic.Bool                      // 影子交易员上一轮周期仍在执行
+	shadowRetired         atomic.Bool                      // 影子交易员所属实验已结束
+	experimentMutex       sync.RWMutex                     // 实验信息读写锁
+	lessonSource          *AutoTrader                      // 复盘经验来源（影子交易员沿用对照组的经验，为空时使用自身）
+	reflectedTrades       map[string]bool                  // 本次运行中已尝试复盘的交易 (trade_key)
+	reflecting            atomic.Bool                      // 后台复盘仍在执行
+	calibration           *logger.CalibrationReport        // 信心度校准结果缓存（Kelly仓位模式）
+	calibrationMutex      sync.Mutex                       // 校准缓存锁
+	regimeDetector        *decision.RegimeDetector         // 市场状态识别器（带滞后）
 }
 
 // NewAutoTrader 创建自动交易器
 func NewAutoTrader(config AutoTraderConfig, database interface{}, userID string) (*AutoTrader, error) {
+	return newAutoTrader(config, database, userID, nil)
+}
+
+// newAutoTrader 创建自动交易器（executor 不为空时使用指定的执行器，不再连接交易所，用于影子交易员）
+func newAutoTrader(config AutoTraderConfig, database interface{}, userID string, executor Trader) (*AutoTrader, error) {
 	// 设置默认值
 	if config.ID == "" {
 		config.ID = "default_trader"
@@ -185,7 +236,7 @@ func NewAutoTrader(config AutoTraderConfig, database interface{}, userID string)
 	}
 
 	// 根据配置创建对应的交易器
-	var trader Trader
+	trader := executor
 	var err error
 
 	// 记录仓位模式（通用）
@@ -195,31 +246,33 @@ func NewAutoTrader(config AutoTraderConfig, database interface{}, userID string)
 	}
 	log.Printf("📊 [%s] 仓位模式: %s", config.Name, marginModeStr)
 
-	switch config.Exchange {
-	case "binance":
-		log.Printf("🏦 [%s] 使用币安合约交易", config.Name)
-		trader = NewFuturesTrader(
-			config.BinanceAPIKey,
-			config.BinanceSecretKey,
-			userID,
-			config.OrderStrategy,
-			config.LimitPriceOffset,
-			config.LimitTimeoutSeconds,
-		)
-	case "hyperliquid":
-		log.Printf("🏦 [%s] 使用Hyperliquid交易", config.Name)
-		trader, err = NewHyperliquidTrader(config.HyperliquidPrivateKey, config.HyperliquidWalletAddr, config.HyperliquidTestnet)
-		if err != nil {
-			return nil, fmt.Errorf("初始化Hyperliquid交易器失败: %w", err)
-		}
-	case "aster":
-		log.Printf("🏦 [%s] 使用Aster交易", config.Name)
-		trader, err = NewAsterTrader(config.AsterUser, config.AsterSigner, config.AsterPrivateKey)
-		if err != nil {
-			return nil, fmt.Errorf("初始化Aster交易器失败: %w", err)
+	if trader == nil {
+		switch config.Exchange {
+		case "binance":
+			log.Printf("🏦 [%s] 使用币安合约交易", config.Name)
+			trader = NewFuturesTrader(
+				config.BinanceAPIKey,
+				config.BinanceSecretKey,
+				userID,
+				config.OrderStrategy,
+				config.LimitPriceOffset,
+				config.LimitTimeoutSeconds,
+			)
+		case "hyperliquid":
+			log.Printf("🏦 [%s] 使用Hyperliquid交易", config.Name)
+			trader, err = NewHyperliquidTrader(config.HyperliquidPrivateKey, config.HyperliquidWalletAddr, config.HyperliquidTestnet)
+			if err != nil {
+				return nil, fmt.Errorf("初始化Hyperliquid交易器失败: %w", err)
+			}
+		case "aster":
+			log.Printf("🏦 [%s] 使用Aster交易", config.Name)
+			trader, err = NewAsterTrader(config.AsterUser, config.AsterSigner, config.AsterPrivateKey)
+			if err != nil {
+				return nil, fmt.Errorf("初始化Aster交易器失败: %w", err)
+			}
+		default:
+			return nil, fmt.Errorf("不支持的交易平台: %s", config.Exchange)
 		}
-	default:
-		return nil, fmt.Errorf("不支持的交易平台: %s", config.Exchange)
 	}
 
 	// 验证初始金额配置
@@ -245,6 +298,10 @@ func NewAutoTrader(config AutoTraderConfig, database interface{}, userID string)
 		name:                  config.Name,
 		aiModel:               config.AIModel,
 		exchange:              config.Exchange,
+		dataSource:            market.SourceForExchange(config.Exchange, config.HyperliquidTestnet),
+		klineLease:            market.NewKlineLease(),
+		depthLease:            market.NewOrderBookLease(market.DefaultDepthMonitor()),
+		flowLease:             market.NewTradeFlowLease(market.DefaultFlowMonitor()),
 		config:                config,
 		trader:                trader,
 		mcpClient:             mcpClient,
@@ -269,6 +326,7 @@ func NewAutoTrader(config AutoTraderConfig, database interface{}, userID string)
 		database:              database,
 		userID:                userID,
 		disableRiskGuards:     disableRiskGuards,
+		regimeDetector:        decision.NewRegimeDetector(0),
 		decisionCyclePositions: nil, // 初始化为空
 		decisionCyclePositionsTime: time.Time{}, // 初始化为零值
 		decisionCyclePositionsMutex: sync.RWMutex{},
@@ -328,23 +386,49 @@ func (at *AutoTrader) Stop() {
 	at.isRunning = false
 	close(at.stopMonitorCh) // 通知监控goroutine停止
 	at.monitorWg.Wait()     // 等待监控goroutine结束
+	at.releaseLeases()      // 释放K线、盘口与成交流订阅引用
 	log.Println("⏹ 自动交易系统停止")
 }
 
 // runCycle 运行一个交易周期（使用AI全权决策）
-func (at *AutoTrader) runCycle() error {
+func (at *AutoTrader) runCycle() (cycleErr error) {
 	at.callCount++
+	cycleNumber := at.callCount
+	cycleStart := time.Now()
+	at.publishEvent(events.TypeCycleStarted, map[string]interface{}{
+		"cycle":        cycleNumber,
+		"trading_mode": at.GetTradingMode(),
+	})
 
 	log.Print("\n" + strings.Repeat("=", 70) + "\n")
 	log.Printf("⏰ %s - AI决策周期 #%d", time.Now().Format("2006-01-02 15:04:05"), at.callCount)
 	log.Println(strings.Repeat("=", 70))
 
 	// 创建决策记录
+	experimentID, experimentArm := at.GetExperiment()
 	record := &logger.DecisionRecord{
-		Exchange:     at.config.Exchange, // 记录交易所类型，用于计算手续费
-		ExecutionLog: []string{},
-		Success:      true,
-	}
+		Exchange:      at.config.Exchange, // 记录交易所类型，用于计算手续费
+		ExecutionLog:  []string{},
+		Success:       true,
+		ExperimentID:  experimentID,
+		ExperimentArm: experimentArm,
+	}
+	skipped := false // 风控暂停跳过的周期不视为运行错误
+	defer func() {
+		data := map[string]interface{}{
+			"cycle":          cycleNumber,
+			"success":        record.Success && cycleErr == nil,
+			"skipped":        skipped,
+			"duration_ms":    time.Since(cycleStart).Milliseconds(),
+			"decision_count": len(record.Decisions),
+		}
+		if cycleErr != nil {
+			data["error"] = cycleErr.Error()
+		} else if record.ErrorMessage != "" {
+			data["error"] = record.ErrorMessage
+		}
+		at.publishEvent(events.TypeCycleFinished, data)
+	}()
 
 	// 1. 检查是否需要停止交易
 	if time.Now().Before(at.stopUntil) {
@@ -352,10 +436,33 @@ func (at *AutoTrader) runCycle() error {
 		log.Printf("⏸ 风险控制：暂停交易中，剩余 %.0f 分钟", remaining.Minutes())
 		record.Success = false
 		record.ErrorMessage = fmt.Sprintf("风险控制暂停中，剩余 %.0f 分钟", remaining.Minutes())
+		skipped = true
+		at.publishRiskVeto(nil, "risk_pause", record.ErrorMessage)
 		at.decisionLogger.LogDecision(record)
 		return nil
 	}
 
+	// 运行模式：暂停时跳过AI决策（回撤监控、已挂止损止盈单、提案过期与被动平仓检测继续生效），清仓模式下平仓后停止
+	switch at.GetTradingMode() {
+	case TradingModePaused:
+		log.Printf("⏸ [%s] 交易员已暂停，跳过本轮AI决策", at.name)
+		at.expireProposals()
+		at.monitorWhilePaused(record)
+		return nil
+	case TradingModeCloseOut:
+		if _, err := at.CloseOut(); err != nil {
+			return fmt.Errorf("清仓退出失败: %w", err)
+		}
+		go at.Stop() // 在主循环之外停止，避免等待自身
+		return nil
+	}
+
+	// 影子交易员与对照组在同一时刻、同一行情下决策（对照组暂停或清仓时不运行，保证实验对比口径一致）
+	at.triggerShadowCycles()
+
+	// 处理过期的审批提案
+	at.expireProposals()
+
 	// 2. 重置日盈亏（每天重置）
 	if time.Since(at.lastResetTime) > 24*time.Hour {
 		at.dailyPnL = 0
@@ -363,14 +470,17 @@ func (at *AutoTrader) runCycle() error {
 		log.Println("📅 日盈亏已重置")
 	}
 
-	// 4. 收集交易上下文
+	// 4. 收集交易上下文（与手动交易、审批执行互斥：构建过程会读写持仓止损止盈等状态）
+	at.executionMutex.Lock()
 	ctx, err := at.buildTradingContext()
 	if err != nil {
+		at.executionMutex.Unlock()
 		record.Success = false
 		record.ErrorMessage = fmt.Sprintf("构建交易上下文失败: %v", err)
 		at.decisionLogger.LogDecision(record)
 		return fmt.Errorf("构建交易上下文失败: %w", err)
 	}
+	at.publishBalanceUpdate(ctx.Account)
 
 	// 保存账户状态快照
 	record.AccountState = logger.AccountSnapshot{
@@ -398,39 +508,8 @@ func (at *AutoTrader) runCycle() error {
 
 	// 检测被动平仓（止损/止盈/强平/手动）
 	closedPositions := at.detectClosedPositions(ctx.Positions)
-	if len(closedPositions) > 0 {
-		autoCloseActions := at.generateAutoCloseActions(closedPositions)
-		record.Decisions = append(record.Decisions, autoCloseActions...)
-		log.Printf("🔔 检测到 %d 个被动平仓", len(closedPositions))
-		for i, closed := range closedPositions {
-			action := autoCloseActions[i]
-			pnl := closed.Quantity * (closed.MarkPrice - closed.EntryPrice)
-			if closed.Side == "short" {
-				pnl = -pnl
-			}
-			pnlPct := pnl / (closed.EntryPrice * closed.Quantity) * 100 * float64(closed.Leverage)
-
-			// 平仓原因中文映射
-			reasonMap := map[string]string{
-				"stop_loss":   "止损",
-				"take_profit": "止盈",
-				"liquidation": "强平",
-				"unknown":     "未知",
-			}
-			reasonCN := reasonMap[action.Error]
-			if reasonCN == "" {
-				reasonCN = action.Error
-			}
-
-			log.Printf("   └─ %s %s | 开仓: %.4f → 平仓: %.4f | 盈亏: %+.2f%% | 原因: %s",
-				closed.Symbol,
-				closed.Side,
-				closed.EntryPrice,
-				action.Price, // 使用推断的平仓价格
-				pnlPct,
-				reasonCN)
-		}
-	}
+	at.executionMutex.Unlock()
+	at.recordClosedPositions(closedPositions, record)
 
 	log.Print(strings.Repeat("=", 70))
 	for _, coin := range ctx.CandidateCoins {
@@ -444,6 +523,19 @@ func (at *AutoTrader) runCycle() error {
 	log.Printf("🤖 正在请求AI分析并决策... [模板: %s]", at.systemPromptTemplate)
 	decision, err := decision.GetFullDecisionWithCustomPrompt(ctx, at.mcpClient, at.customPrompt, at.overrideBasePrompt, at.systemPromptTemplate)
 
+	// 复盘新平仓的交易（需要本周期的市场状态，在后台执行）
+	at.reflectOnClosedTrades(ctx)
+
+	// 市场状态（构建提示词时识别，AI调用失败也要记录切换）
+	if ctx.Regime != nil {
+		record.Regime = ctx.Regime.Regime
+		if ctx.Regime.Changed {
+			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("🧭 市场状态切换: %s → %s",
+				regimeLabel(ctx.Regime.Previous), regimeLabel(ctx.Regime.Regime)))
+			at.publishRegimeChange(ctx.Regime)
+		}
+	}
+
 	if decision != nil && decision.AIRequestDurationMs > 0 {
 		record.AIRequestDurationMs = decision.AIRequestDurationMs
 		log.Printf("⏱️ AI调用耗时: %.2f 秒", float64(record.AIRequestDurationMs)/1000)
@@ -456,6 +548,17 @@ func (at *AutoTrader) runCycle() error {
 		record.SystemPrompt = decision.SystemPrompt // 保存系统提示词
 		record.InputPrompt = decision.UserPrompt
 		record.CoTTrace = decision.CoTTrace
+		record.PromptTemplate = decision.PromptTemplate
+		record.PromptTemplateVersion = decision.PromptTemplateVersion
+		if usage := decision.PromptUsage; usage != nil {
+			record.PromptTokens = usage.TotalTokens
+			record.PromptTokenBudget = usage.Budget
+			record.DroppedPromptSections = usage.DroppedSections
+			if len(usage.DroppedSections) > 0 || usage.OverBudget {
+				record.ExecutionLog = append(record.ExecutionLog,
+					fmt.Sprintf("提示词压缩: %d/%d tokens，降级段落: %s", usage.TotalTokens, usage.Budget, strings.Join(usage.DroppedSections, ", ")))
+			}
+		}
 		if len(decision.Decisions) > 0 {
 			decisionJSON, _ := json.MarshalIndent(decision.Decisions, "", "  ")
 			record.DecisionJSON = string(decisionJSON)
@@ -487,6 +590,12 @@ func (at *AutoTrader) runCycle() error {
 		return fmt.Errorf("获取AI决策失败: %w", err)
 	}
 
+	at.publishEvent(events.TypeDecision, map[string]interface{}{
+		"cycle":          cycleNumber,
+		"decisions":      decision.Decisions,
+		"ai_duration_ms": decision.AIRequestDurationMs,
+	})
+
 	// // 5. 打印系统提示词
 	// log.Printf("\n" + strings.Repeat("=", 70))
 	// log.Printf("📋 系统提示词 [模板: %s]", at.systemPromptTemplate)
@@ -524,6 +633,9 @@ func (at *AutoTrader) runCycle() error {
 	}
 	log.Println()
 
+	// 执行期间与手动交易操作互斥，避免同时操作同一持仓
+	at.executionMutex.Lock()
+
 	// 🔧 在执行决策前，先获取一次持仓信息并缓存（减少API调用）
 	// 这样在同一个决策周期内，所有需要持仓信息的操作都可以复用这个缓存
 	at.decisionCyclePositionsMutex.Lock()
@@ -568,6 +680,23 @@ func (at *AutoTrader) runCycle() error {
 		}
 
 		if (d.Action == "hold" || d.Action == "wait") && (d.NewStopLoss > 0 || d.NewTakeProfit > 0) {
+			// 运行模式校验（只减仓模式下不允许放宽止损或调整止盈）
+			for _, action := range []string{"update_stop_loss", "update_take_profit"} {
+				probe := d
+				probe.Action = action
+				if (action == "update_stop_loss" && d.NewStopLoss <= 0) || (action == "update_take_profit" && d.NewTakeProfit <= 0) {
+					continue
+				}
+				if allowed, note := at.checkTradingMode(&probe); !allowed {
+					at.publishRiskVeto(&probe, "trading_mode", note)
+					record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("🚫 运行模式阻止 AUTO %s %s: %s", action, d.Symbol, note))
+					if action == "update_stop_loss" {
+						d.NewStopLoss = 0
+					} else {
+						d.NewTakeProfit = 0
+					}
+				}
+			}
 			if d.NewStopLoss > 0 {
 				updateDecision := d
 				updateDecision.Action = "update_stop_loss"
@@ -585,6 +714,7 @@ func (at *AutoTrader) runCycle() error {
 				} else {
 					updateRecord.Success = true
 					record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("✓ AUTO update_stop_loss %s 成功", d.Symbol))
+					at.publishActionEvents(updateRecord, EventSourceAI)
 				}
 				record.Decisions = append(record.Decisions, updateRecord)
 			}
@@ -605,15 +735,51 @@ func (at *AutoTrader) runCycle() error {
 				} else {
 					updateRecord.Success = true
 					record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("✓ AUTO update_take_profit %s 成功", d.Symbol))
+					at.publishActionEvents(updateRecord, EventSourceAI)
 				}
 				record.Decisions = append(record.Decisions, updateRecord)
 			}
 		}
 
+		if allowed, note := at.checkTradingMode(&d); !allowed {
+			msg := fmt.Sprintf("🚫 运行模式阻止 %s %s: %s", d.Symbol, d.Action, note)
+			log.Println(msg)
+			at.publishRiskVeto(&d, "trading_mode", note)
+			record.ExecutionLog = append(record.ExecutionLog, msg)
+			continue
+		}
+
+		// Kelly 仓位模式：在市场状态策略、风控与审批判断之前确定仓位
+		if note, err := at.applyKellySizing(&d, ctx.Account.AvailableBalance); err != nil {
+			msg := fmt.Sprintf("🎯 仓位计算阻止 %s %s: %v", d.Symbol, d.Action, err)
+			log.Println(msg)
+			at.publishRiskVeto(&d, "kelly", err.Error())
+			record.ExecutionLog = append(record.ExecutionLog, msg)
+			continue
+		} else if note != "" {
+			msg := fmt.Sprintf("🎯 仓位计算 %s %s: %s", d.Symbol, d.Action, note)
+			log.Println(msg)
+			record.ExecutionLog = append(record.ExecutionLog, msg)
+		}
+
+		// 市场状态策略独立于风控开关，始终生效
+		if allowed, note := at.checkRegimePolicy(ctx, &d); !allowed {
+			msg := fmt.Sprintf("🧭 市场状态策略阻止 %s %s: %s", d.Symbol, d.Action, note)
+			log.Println(msg)
+			at.publishRiskVeto(&d, "regime", note)
+			record.ExecutionLog = append(record.ExecutionLog, msg)
+			continue
+		} else if note != "" {
+			msg := fmt.Sprintf("🧭 市场状态策略调整 %s %s: %s", d.Symbol, d.Action, note)
+			log.Println(msg)
+			record.ExecutionLog = append(record.ExecutionLog, msg)
+		}
+
 		allowed, note := at.applyRiskGuards(ctx, &d)
 		if !allowed {
 			msg := fmt.Sprintf("⛔ 风控阻止 %s %s: %s", d.Symbol, d.Action, note)
 			log.Println(msg)
+			at.publishRiskVeto(&d, "risk_guard", note)
 			record.ExecutionLog = append(record.ExecutionLog, msg)
 			continue
 		}
@@ -623,6 +789,30 @@ func (at *AutoTrader) runCycle() error {
 			record.ExecutionLog = append(record.ExecutionLog, msg)
 		}
 
+		// 开仓决策记录信心度、风险与行情快照（用于交易归因）
+		if d.Action == "open_long" || d.Action == "open_short" {
+			actionRecord.Confidence = d.Confidence
+			actionRecord.RiskUSD = d.RiskUSD
+			actionRecord.Entry = entrySnapshot(ctx, d.Symbol)
+		}
+
+		// 需要人工审批的开仓转为待审批提案，不直接执行
+		if at.needsApproval(&d) {
+			if proposal, err := at.createProposal(&d); err != nil {
+				actionRecord.Error = err.Error()
+				record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("❌ %s %s 创建审批提案失败: %v", d.Symbol, d.Action, err))
+			} else {
+				at.proposalsMutex.Lock()
+				proposal.entry = actionRecord.Entry
+				at.proposalsMutex.Unlock()
+				actionRecord.ProposalID = proposal.ID
+				actionRecord.ProposalStatus = ProposalStatusPending
+				record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("📝 %s %s 等待人工审批（提案 %s）", d.Symbol, d.Action, proposal.ID))
+			}
+			record.Decisions = append(record.Decisions, actionRecord)
+			continue
+		}
+
 		if err := at.executeDecisionWithRecord(&d, &actionRecord); err != nil {
 			log.Printf("❌ 执行决策失败 (%s %s): %v", d.Symbol, d.Action, err)
 			actionRecord.Error = err.Error()
@@ -630,6 +820,7 @@ func (at *AutoTrader) runCycle() error {
 		} else {
 			actionRecord.Success = true
 			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("✓ %s %s 成功", d.Symbol, d.Action))
+			at.publishActionEvents(actionRecord, EventSourceAI)
 			// 成功执行后短暂延迟
 			time.Sleep(1 * time.Second)
 		}
@@ -638,7 +829,8 @@ func (at *AutoTrader) runCycle() error {
 	}
 
 	// 9. 更新持仓快照（用于下一周期检测被动平仓）
-	at.updatePositionSnapshot(ctx.Positions)
+	at.refreshPositionSnapshot()
+	at.executionMutex.Unlock()
 
 	// 10. 保存决策记录
 	if err := at.decisionLogger.LogDecision(record); err != nil {
@@ -648,7 +840,7 @@ func (at *AutoTrader) runCycle() error {
 	return nil
 }
 
-// buildTradingContext 构建交易上下文
+// buildTradingContext 构建交易上下文（会更新持仓状态与行情订阅，调用方需持有 executionMutex）
 func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 	// 1. 获取账户信息
 	balance, err := at.trader.GetBalance()
@@ -679,7 +871,125 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 	if err != nil {
 		return nil, fmt.Errorf("获取持仓失败: %w", err)
 	}
+	positionInfos, totalMarginUsed := at.positionInfosFrom(positions)
+
+	// 3. 获取交易员的候选币种池
+	candidateCoins, err := at.getCandidateCoins()
+	if err != nil {
+		return nil, fmt.Errorf("获取候选币种失败: %w", err)
+	}
+
+	// 4. 计算总盈亏
+	totalPnL := totalEquity - at.initialBalance
+	totalPnLPct := 0.0
+	if at.initialBalance > 0 {
+		totalPnLPct = (totalPnL / at.initialBalance) * 100
+	}
+
+	marginUsedPct := 0.0
+	if totalEquity > 0 {
+		marginUsedPct = (totalMarginUsed / totalEquity) * 100
+	}
+
+	// 5. 分析历史表现（最近100个周期，避免长期持仓的交易记录丢失）
+	// 假设每3分钟一个周期，100个周期 = 5小时，足够覆盖大部分交易
+	performance, err := at.decisionLogger.AnalyzePerformance(100)
+	if err != nil {
+		log.Printf("⚠️  分析历史表现失败: %v", err)
+		// 不影响主流程，继续执行（但设置performance为nil以避免传递错误数据）
+		performance = nil
+	}
+
+	// 6. Fetch open orders for AI decision context to prevent duplicate orders
+	openOrders, err := at.trader.GetOpenOrders("")
+	if err != nil {
+		log.Printf("⚠️  Failed to fetch open orders: %v (continuing execution, but AI won't see order status)", err)
+		// Don't block main flow, use empty list
+		openOrders = []decision.OpenOrderInfo{}
+	} else {
+		log.Printf("  ✓ Fetched %d open orders", len(openOrders))
+	}
 
+	// 7. Build context
+	// 解析时间周期配置
+	timeframes := []string{}
+	if at.config.Timeframes != "" {
+		timeframeStrs := strings.Split(at.config.Timeframes, ",")
+		for _, tf := range timeframeStrs {
+			tf = strings.TrimSpace(tf)
+			if tf != "" {
+				timeframes = append(timeframes, tf)
+			}
+		}
+	}
+
+	// 为持仓与候选币种 × 配置的时间周期持有K线订阅（币安行情，多个交易员共享）
+	if at.dataSource == nil && at.klineLease != nil {
+		leaseSymbols := make([]string, 0, len(positionInfos)+len(candidateCoins))
+		for _, pos := range positionInfos {
+			leaseSymbols = append(leaseSymbols, pos.Symbol)
+		}
+		for _, coin := range candidateCoins {
+			leaseSymbols = append(leaseSymbols, coin.Symbol)
+		}
+		// 指标使用的时间周期同样需要订阅
+		leaseTimeframes := append([]string{}, timeframes...)
+		for _, tf := range market.IndicatorTimeframes(at.config.Indicators) {
+			if !slices.Contains(leaseTimeframes, tf) {
+				leaseTimeframes = append(leaseTimeframes, tf)
+			}
+		}
+		at.klineLease.Update(leaseSymbols, leaseTimeframes)
+		if at.depthLease != nil {
+			at.depthLease.Update(leaseSymbols)
+		}
+		if at.flowLease != nil {
+			at.flowLease.Update(leaseSymbols)
+		}
+	}
+
+	ctx := &decision.Context{
+		CurrentTime:        time.Now().Format("2006-01-02 15:04:05"),
+		RuntimeMinutes:     int(time.Since(at.startTime).Minutes()),
+		CallCount:          at.callCount,
+		BTCETHLeverage:     at.config.BTCETHLeverage,     // 使用配置的杠杆倍数
+		AltcoinLeverage:    at.config.AltcoinLeverage,    // 使用配置的杠杆倍数
+		TakerFeeRate:       at.config.TakerFeeRate,       // Use configured taker fee rate
+		MakerFeeRate:       at.config.MakerFeeRate,       // Use configured maker fee rate
+		Timeframes:         timeframes,                   // 配置的时间周期列表
+		Indicators:         at.config.Indicators,         // 交易员选择的指标
+		UserPromptTemplate: at.config.UserPromptTemplate, // 用户提示词模板
+		AIProvider:         at.config.AIModel,            // 用于估算 token 数
+		PromptTokenBudget:  at.config.PromptTokenBudget,  // 提示词 token 预算
+		TemplateOwner:      at.userID,                    // 系统提示词模板在交易员所属用户的命名空间中解析
+		AgentMode:          at.config.AgentMode,          // 智能体模式
+		AgentMaxSteps:      at.config.AgentMaxSteps,      // 智能体模式最多工具调用轮数
+		Lessons:            at.loadLessons(),             // 历史复盘经验
+		RegimeDetector:     at.regimeDetector,            // 市场状态识别
+		RegimePolicies:     at.config.RegimePolicies,     // 市场状态策略
+		Account: decision.AccountInfo{
+			TotalEquity:      totalEquity,
+			AvailableBalance: availableBalance,
+			UnrealizedPnL:    totalUnrealizedProfit,
+			TotalPnL:         totalPnL,
+			TotalPnLPct:      totalPnLPct,
+			MarginUsed:       totalMarginUsed,
+			MarginUsedPct:    marginUsedPct,
+			PositionCount:    len(positionInfos),
+		},
+		Positions:      positionInfos,
+		OpenOrders:     openOrders, // 添加未成交订单（用于 AI 了解挂单状态，避免重复下单）
+		CandidateCoins: candidateCoins,
+		Performance:    performance, // 添加历史表现分析（包含 RecentTrades 用于 AI 学习）
+		DataSource:     at.dataSource,
+	}
+
+	return ctx, nil
+}
+
+// positionInfosFrom 将交易所持仓转换为决策上下文中的持仓信息，同时维护持仓首次出现时间与止损止盈记录
+// 会读写 positionFirstSeenTime/positionStopLoss/positionTakeProfit，调用方需持有 executionMutex
+func (at *AutoTrader) positionInfosFrom(positions []map[string]interface{}) ([]decision.PositionInfo, float64) {
 	var positionInfos []decision.PositionInfo
 	totalMarginUsed := 0.0
 
@@ -760,82 +1070,7 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 		}
 	}
 
-	// 3. 获取交易员的候选币种池
-	candidateCoins, err := at.getCandidateCoins()
-	if err != nil {
-		return nil, fmt.Errorf("获取候选币种失败: %w", err)
-	}
-
-	// 4. 计算总盈亏
-	totalPnL := totalEquity - at.initialBalance
-	totalPnLPct := 0.0
-	if at.initialBalance > 0 {
-		totalPnLPct = (totalPnL / at.initialBalance) * 100
-	}
-
-	marginUsedPct := 0.0
-	if totalEquity > 0 {
-		marginUsedPct = (totalMarginUsed / totalEquity) * 100
-	}
-
-	// 5. 分析历史表现（最近100个周期，避免长期持仓的交易记录丢失）
-	// 假设每3分钟一个周期，100个周期 = 5小时，足够覆盖大部分交易
-	performance, err := at.decisionLogger.AnalyzePerformance(100)
-	if err != nil {
-		log.Printf("⚠️  分析历史表现失败: %v", err)
-		// 不影响主流程，继续执行（但设置performance为nil以避免传递错误数据）
-		performance = nil
-	}
-
-	// 6. Fetch open orders for AI decision context to prevent duplicate orders
-	openOrders, err := at.trader.GetOpenOrders("")
-	if err != nil {
-		log.Printf("⚠️  Failed to fetch open orders: %v (continuing execution, but AI won't see order status)", err)
-		// Don't block main flow, use empty list
-		openOrders = []decision.OpenOrderInfo{}
-	} else {
-		log.Printf("  ✓ Fetched %d open orders", len(openOrders))
-	}
-
-	// 7. Build context
-	// 解析时间周期配置
-	timeframes := []string{}
-	if at.config.Timeframes != "" {
-		timeframeStrs := strings.Split(at.config.Timeframes, ",")
-		for _, tf := range timeframeStrs {
-			tf = strings.TrimSpace(tf)
-			if tf != "" {
-				timeframes = append(timeframes, tf)
-			}
-		}
-	}
-
-	ctx := &decision.Context{
-		CurrentTime:     time.Now().Format("2006-01-02 15:04:05"),
-		RuntimeMinutes:  int(time.Since(at.startTime).Minutes()),
-		CallCount:       at.callCount,
-		BTCETHLeverage:  at.config.BTCETHLeverage,  // 使用配置的杠杆倍数
-		AltcoinLeverage: at.config.AltcoinLeverage, // 使用配置的杠杆倍数
-		TakerFeeRate:    at.config.TakerFeeRate,    // Use configured taker fee rate
-		MakerFeeRate:    at.config.MakerFeeRate,    // Use configured maker fee rate
-		Timeframes:      timeframes,                 // 配置的时间周期列表
-		Account: decision.AccountInfo{
-			TotalEquity:      totalEquity,
-			AvailableBalance: availableBalance,
-			UnrealizedPnL:    totalUnrealizedProfit,
-			TotalPnL:         totalPnL,
-			TotalPnLPct:      totalPnLPct,
-			MarginUsed:       totalMarginUsed,
-			MarginUsedPct:    marginUsedPct,
-			PositionCount:    len(positionInfos),
-		},
-		Positions:      positionInfos,
-		OpenOrders:     openOrders, // 添加未成交订单（用于 AI 了解挂单状态，避免重复下单）
-		CandidateCoins: candidateCoins,
-		Performance:    performance, // 添加历史表现分析（包含 RecentTrades 用于 AI 学习）
-	}
-
-	return ctx, nil
+	return positionInfos, totalMarginUsed
 }
 
 // executeDecisionWithRecord 执行AI决策并记录详细信息
@@ -1284,6 +1519,8 @@ func (at *AutoTrader) executeUpdateStopLossWithRecord(decision *decision.Decisio
 		return fmt.Errorf("修改止损失败: %w", err)
 	}
 
+	at.positionStopLoss[decision.Symbol+"_"+strings.ToLower(side)] = decision.NewStopLoss // 记录最新止损（只减仓模式判断是否收紧）
+
 	log.Printf("  ✓ 止损已调整: %.2f (当前价格: %.2f)", decision.NewStopLoss, marketData.CurrentPrice)
 	return nil
 }
@@ -1657,6 +1894,11 @@ func (at *AutoTrader) GetExchange() string {
 	return at.exchange
 }
 
+// GetScanInterval 获取扫描间隔
+func (at *AutoTrader) GetScanInterval() time.Duration {
+	return at.config.ScanInterval
+}
+
 // SetCustomPrompt 设置自定义交易策略prompt
 func (at *AutoTrader) SetCustomPrompt(prompt string) {
 	at.customPrompt = prompt
@@ -1677,6 +1919,32 @@ func (at *AutoTrader) GetSystemPromptTemplate() string {
 	return at.systemPromptTemplate
 }
 
+// GetUserPromptTemplate 获取当前用户提示词模板名称（为空表示使用 default）
+func (at *AutoTrader) GetUserPromptTemplate() string {
+	return at.config.UserPromptTemplate
+}
+
+// PreviewPrompts 基于当前实时上下文构建系统与用户提示词（不调用AI、不执行交易）
+// userPromptTemplate 不为空时覆盖交易员配置的用户提示词模板
+// 构建上下文会读写持仓状态，需与交易周期、手动交易互斥执行
+func (at *AutoTrader) PreviewPrompts(userPromptTemplate string) (*decision.Context, string, string, error) {
+	at.executionMutex.Lock()
+	ctx, err := at.buildTradingContext()
+	at.executionMutex.Unlock()
+	if err != nil {
+		return nil, "", "", fmt.Errorf("构建交易上下文失败: %w", err)
+	}
+	if userPromptTemplate != "" {
+		ctx.UserPromptTemplate = userPromptTemplate
+	}
+	ctx.RegimePreview = true // 预览不推进市场状态识别
+	systemPrompt, userPrompt, err := decision.PreparePrompts(ctx, at.customPrompt, at.overrideBasePrompt, at.systemPromptTemplate)
+	if err != nil {
+		return nil, "", "", err
+	}
+	return ctx, systemPrompt, userPrompt, nil
+}
+
 // GetDecisionLogger 获取决策日志记录器
 func (at *AutoTrader) GetDecisionLogger() logger.IDecisionLogger {
 	return at.decisionLogger
@@ -1690,19 +1958,22 @@ func (at *AutoTrader) GetStatus() map[string]interface{} {
 	}
 
 	return map[string]interface{}{
-		"trader_id":       at.id,
-		"trader_name":     at.name,
-		"ai_model":        at.aiModel,
-		"exchange":        at.exchange,
-		"is_running":      at.isRunning,
-		"start_time":      at.startTime.Format(time.RFC3339),
-		"runtime_minutes": int(time.Since(at.startTime).Minutes()),
-		"call_count":      at.callCount,
-		"initial_balance": at.initialBalance,
-		"scan_interval":   at.config.ScanInterval.String(),
-		"stop_until":      at.stopUntil.Format(time.RFC3339),
-		"last_reset_time": at.lastResetTime.Format(time.RFC3339),
-		"ai_provider":     aiProvider,
+		"trader_id":         at.id,
+		"trader_name":       at.name,
+		"ai_model":          at.aiModel,
+		"exchange":          at.exchange,
+		"is_running":        at.isRunning,
+		"start_time":        at.startTime.Format(time.RFC3339),
+		"runtime_minutes":   int(time.Since(at.startTime).Minutes()),
+		"call_count":        at.callCount,
+		"initial_balance":   at.initialBalance,
+		"scan_interval":     at.config.ScanInterval.String(),
+		"stop_until":        at.stopUntil.Format(time.RFC3339),
+		"last_reset_time":   at.lastResetTime.Format(time.RFC3339),
+		"ai_provider":       aiProvider,
+		"trading_mode":      at.GetTradingMode(),
+		"approval":          at.GetApprovalSettings(),
+		"pending_proposals": len(at.GetProposals(ProposalStatusPending)),
 	}
 }
 
@@ -2015,8 +2286,8 @@ func (at *AutoTrader) normalizePositionSize(decision *decision.Decision, availab
 
 	var adjustments []string
 
-	// 🔧 如果AI决策的仓位小于最大可用仓位，且置信度较高，可以适当增加仓位
-	if decision.PositionSizeUSD < maxPositionUSD {
+	// 🔧 如果AI决策的仓位小于最大可用仓位，且置信度较高，可以适当增加仓位（Kelly仓位与已审批提案的仓位不再放大）
+	if decision.PositionSizeUSD < maxPositionUSD && !decision.SizeLocked {
 		// 高置信度时，可以使用更多可用资金（但不超过AI决策的150%）
 		if decision.Confidence >= 85 && maxPositionUSD > decision.PositionSizeUSD*1.5 {
 			// 如果最大可用仓位远大于AI决策，且置信度高，可以适当增加
@@ -2056,6 +2327,11 @@ func (at *AutoTrader) normalizePositionSize(decision *decision.Decision, availab
 	return "", nil
 }
 
+// entrySnapshot 开仓时的行情快照（决策执行循环中 decision 包名被局部变量遮蔽）
+func entrySnapshot(ctx *decision.Context, symbol string) *logger.EntrySnapshot {
+	return decision.BuildEntrySnapshot(ctx, symbol)
+}
+
 func (at *AutoTrader) applyRiskGuards(ctx *decision.Context, d *decision.Decision) (bool, string) {
 	if at.disableRiskGuards {
 		return true, ""
@@ -2085,6 +2361,21 @@ func (at *AutoTrader) applyRiskGuards(ctx *decision.Context, d *decision.Decisio
 		return false, "市场处于极端波动，系统只允许观望或减仓"
 	}
 
+	allowed, note := at.checkOrderBookLiquidity(ctx, d)
+	if !allowed {
+		return false, note
+	}
+
+	// 临近资金费率结算的检查基于调整后的仓位
+	if ok, fundingNote := at.checkFundingSettlement(ctx, d); !ok {
+		return false, fundingNote
+	} else if fundingNote != "" {
+		if note != "" {
+			note += "；"
+		}
+		note += fundingNote
+	}
+
 	// 动态上限风控已禁用（用户不需要此限制）
 	// maxPosition := at.calculateDynamicPositionCap(ctx, d)
 	// if maxPosition <= 0 {
@@ -2097,7 +2388,7 @@ func (at *AutoTrader) applyRiskGuards(ctx *decision.Context, d *decision.Decisio
 	// 	return true, fmt.Sprintf("仓位从 %.2f 调整至 %.2f USDT（动态上限）", original, d.PositionSizeUSD)
 	// }
 
-	return true, ""
+	return true, note
 }
 
 func (at *AutoTrader) calculateDynamicPositionCap(ctx *decision.Context, d *decision.Decision) float64 {
@@ -2331,6 +2622,11 @@ func (at *AutoTrader) checkPositionDrawdown() {
 	}
 	log.Printf("   ├─ 持仓数量: %d", len(positions))
 
+	snapshots := make([]map[string]interface{}, 0, len(positions))
+	defer func() {
+		at.publishEvent(events.TypePositionUpdate, map[string]interface{}{"positions": snapshots})
+	}()
+
 	for _, pos := range positions {
 		symbol := pos["symbol"].(string)
 		side := pos["side"].(string)
@@ -2376,12 +2672,23 @@ func (at *AutoTrader) checkPositionDrawdown() {
 		if peakPnLPct > 0 && currentPnLPct < peakPnLPct {
 			drawdownPct = ((peakPnLPct - currentPnLPct) / peakPnLPct) * 100
 		}
+		snapshots = append(snapshots, map[string]interface{}{
+			"symbol":       symbol,
+			"side":         side,
+			"entry_price":  entryPrice,
+			"mark_price":   markPrice,
+			"quantity":     quantity,
+			"leverage":     leverage,
+			"pnl_pct":      currentPnLPct,
+			"peak_pnl_pct": peakPnLPct,
+			"drawdown_pct": drawdownPct,
+		})
 
 		// 检查平仓条件：收益大于5%且回撤超过40%
 		if currentPnLPct > 5.0 && drawdownPct >= 40.0 {
-			log.Printf("\n" + strings.Repeat("=", 70))
+			log.Print("\n" + strings.Repeat("=", 70))
 			log.Printf("🚨 [紧急平仓] 回撤超过阈值！")
-			log.Printf(strings.Repeat("=", 70))
+			log.Print(strings.Repeat("=", 70))
 			log.Printf("   持仓信息: %s %s", symbol, side)
 			log.Printf("   ├─ 当前收益: %.2f%%", currentPnLPct)
 			log.Printf("   ├─ 历史最高: %.2f%%", peakPnLPct)
@@ -2389,7 +2696,7 @@ func (at *AutoTrader) checkPositionDrawdown() {
 			log.Printf("   ├─ 入场价格: %.4f", entryPrice)
 			log.Printf("   ├─ 当前价格: %.4f", markPrice)
 			log.Printf("   └─ 持仓数量: %.4f", quantity)
-			log.Printf(strings.Repeat("-", 70))
+			log.Print(strings.Repeat("-", 70))
 
 			// 执行平仓
 			log.Printf("   ⏳ 正在执行紧急平仓...")
@@ -2400,8 +2707,18 @@ func (at *AutoTrader) checkPositionDrawdown() {
 				log.Printf("   └─ 锁定收益: %.2f%%", currentPnLPct)
 				// 平仓后清理该持仓的缓存
 				at.ClearPeakPnLCache(symbol, side)
+				at.publishActionEvents(logger.DecisionAction{
+					Action:    "close_" + side,
+					Symbol:    symbol,
+					Quantity:  quantity,
+					Leverage:  leverage,
+					Price:     markPrice,
+					Timestamp: time.Now(),
+					Success:   true,
+					Reason:    fmt.Sprintf("回撤保护: 峰值 %.2f%% 回撤 %.2f%%", peakPnLPct, drawdownPct),
+				}, EventSourceMonitor)
 			}
-			log.Printf(strings.Repeat("=", 70) + "\n")
+			log.Print(strings.Repeat("=", 70) + "\n")
 		} else if currentPnLPct > 5.0 {
 			// 记录接近平仓条件的情况（用于调试）
 			log.Printf("   ├─ [%s %s] 收益: %.2f%% | 峰值: %.2f%% | 回撤: %.2f%% (安全)",
@@ -2486,6 +2803,69 @@ func (at *AutoTrader) ClearPeakPnLCache(symbol, side string) {
 	delete(at.peakPnLCache, posKey)
 }
 
+// recordClosedPositions 将被动平仓（止损/止盈/强平/手动）写入决策记录并发布事件
+func (at *AutoTrader) recordClosedPositions(closedPositions []decision.PositionInfo, record *logger.DecisionRecord) {
+	if len(closedPositions) == 0 {
+		return
+	}
+	autoCloseActions := at.generateAutoCloseActions(closedPositions)
+	record.Decisions = append(record.Decisions, autoCloseActions...)
+	log.Printf("🔔 检测到 %d 个被动平仓", len(closedPositions))
+	for i, closed := range closedPositions {
+		action := autoCloseActions[i]
+		at.publishActionEvents(action, EventSourceExchange)
+		pnl := closed.Quantity * (closed.MarkPrice - closed.EntryPrice)
+		if closed.Side == "short" {
+			pnl = -pnl
+		}
+		pnlPct := pnl / (closed.EntryPrice * closed.Quantity) * 100 * float64(closed.Leverage)
+
+		// 平仓原因中文映射
+		reasonMap := map[string]string{
+			"stop_loss":   "止损",
+			"take_profit": "止盈",
+			"liquidation": "强平",
+			"unknown":     "未知",
+		}
+		reasonCN := reasonMap[action.Error]
+		if reasonCN == "" {
+			reasonCN = action.Error
+		}
+
+		log.Printf("   └─ %s %s | 开仓: %.4f → 平仓: %.4f | 盈亏: %+.2f%% | 原因: %s",
+			closed.Symbol,
+			closed.Side,
+			closed.EntryPrice,
+			action.Price, // 使用推断的平仓价格
+			pnlPct,
+			reasonCN)
+	}
+}
+
+// monitorWhilePaused 暂停期间仍检测被动平仓（交易所挂单继续生效），有平仓时写入决策记录
+func (at *AutoTrader) monitorWhilePaused(record *logger.DecisionRecord) {
+	at.executionMutex.Lock()
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		at.executionMutex.Unlock()
+		log.Printf("⚠️ [%s] 暂停期间获取持仓失败: %v", at.name, err)
+		return
+	}
+	positionInfos, _ := at.positionInfosFrom(positions)
+	closedPositions := at.detectClosedPositions(positionInfos)
+	at.updatePositionSnapshot(positionInfos)
+	at.executionMutex.Unlock()
+
+	if len(closedPositions) == 0 {
+		return
+	}
+	record.ExecutionLog = append(record.ExecutionLog, "交易员已暂停，仅记录被动平仓")
+	at.recordClosedPositions(closedPositions, record)
+	if err := at.decisionLogger.LogDecision(record); err != nil {
+		log.Printf("⚠ 保存决策记录失败: %v", err)
+	}
+}
+
 // detectClosedPositions 检测被交易所自动平仓的持仓（止损/止盈触发）
 // 对比上一次和当前的持仓快照，找出消失的持仓
 func (at *AutoTrader) detectClosedPositions(currentPositions []decision.PositionInfo) []decision.PositionInfo {
@@ -2602,6 +2982,18 @@ func (at *AutoTrader) inferCloseDetails(pos decision.PositionInfo) (price float6
 	return markPrice, "unknown"
 }
 
+// refreshPositionSnapshot 执行决策后从交易所重新获取持仓并更新快照（调用方需持有 executionMutex）
+// AI 调用期间持仓可能已被手动平仓，沿用周期开始时的持仓会在下一周期把它误记为被动平仓
+func (at *AutoTrader) refreshPositionSnapshot() {
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		log.Printf("⚠️ 刷新持仓快照失败: %v（保留上一次快照）", err)
+		return
+	}
+	positionInfos, _ := at.positionInfosFrom(positions)
+	at.updatePositionSnapshot(positionInfos)
+}
+
 // updatePositionSnapshot 更新持仓快照（在每次 buildTradingContext 后调用）
 func (at *AutoTrader) updatePositionSnapshot(currentPositions []decision.PositionInfo) {
 	// 清空旧快照
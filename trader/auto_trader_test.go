@@ -403,6 +403,37 @@ func (s *AutoTraderTestSuite) TestBuildTradingContext() {
 	s.Equal(5, ctx.AltcoinLeverage)
 }
 
+// TestPreviewPromptsWaitsForExecution 预览提示词需等待正在执行的交易周期释放锁
+func (s *AutoTraderTestSuite) TestPreviewPromptsWaitsForExecution() {
+	s.patches.ApplyFunc(market.Get, func(symbol string) (*market.Data, error) {
+		return &market.Data{Symbol: symbol, CurrentPrice: 50000.0}, nil
+	})
+	s.patches.ApplyFunc(decision.PreparePrompts, func(ctx *decision.Context, customPrompt string, overrideBase bool, templateName string) (string, string, error) {
+		return "system", "user", nil
+	})
+
+	s.autoTrader.executionMutex.Lock()
+	done := make(chan error, 1)
+	go func() {
+		_, _, _, err := s.autoTrader.PreviewPrompts("")
+		done <- err
+	}()
+
+	select {
+	case <-done:
+		s.Fail("持有执行锁时预览不应构建上下文")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	s.autoTrader.executionMutex.Unlock()
+	select {
+	case err := <-done:
+		s.NoError(err)
+	case <-time.After(time.Second):
+		s.Fail("释放执行锁后预览应完成")
+	}
+}
+
 // ============================================================
 // 层次 9: 交易执行测试
 // ============================================================
@@ -981,6 +1012,7 @@ func (m *MockDatabase) UpdateTraderInitialBalance(userID, traderID string, newBa
 type MockTrader struct {
 	balance              map[string]interface{}
 	positions            []map[string]interface{}
+	openOrders           []decision.OpenOrderInfo
 	shouldFailBalance    bool
 	shouldFailPositions  bool
 	shouldFailOpenLong   bool
@@ -1090,7 +1122,10 @@ func (m *MockTrader) FormatQuantity(symbol string, quantity float64) (string, er
 }
 
 func (m *MockTrader) GetOpenOrders(symbol string) ([]decision.OpenOrderInfo, error) {
-	return []decision.OpenOrderInfo{}, nil
+	if m.openOrders == nil {
+		return []decision.OpenOrderInfo{}, nil
+	}
+	return m.openOrders, nil
 }
 
 // ============================================================
@@ -0,0 +1,108 @@
+package trader
+
+import (
+	"nofx/decision"
+	"nofx/events"
+	"nofx/logger"
+	"sync"
+	"time"
+)
+
+// 事件来源
+const (
+	EventSourceAI       = "ai"
+	EventSourceManual   = "manual"
+	EventSourceApproval = "approval"
+	EventSourceExchange = "exchange"         // 交易所侧触发（止损/止盈/强平）
+	EventSourceMonitor  = "drawdown_monitor" // 回撤监控紧急平仓
+)
+
+// EventPublisher 交易员事件发布（由 events.Bus 实现）
+type EventPublisher interface {
+	Publish(e events.Event)
+}
+
+var (
+	eventPublisher      EventPublisher
+	eventPublisherMutex sync.RWMutex
+)
+
+// SetEventPublisher 设置全局事件发布器（nil 表示关闭推送）
+func SetEventPublisher(publisher EventPublisher) {
+	eventPublisherMutex.Lock()
+	defer eventPublisherMutex.Unlock()
+	eventPublisher = publisher
+}
+
+// publishEvent 发布交易员事件
+func (at *AutoTrader) publishEvent(eventType string, data interface{}) {
+	if at.paperTrading {
+		return // 影子交易员的模拟交易不推送（避免触发通知与Webhook）
+	}
+	eventPublisherMutex.RLock()
+	publisher := eventPublisher
+	eventPublisherMutex.RUnlock()
+	if publisher == nil {
+		return
+	}
+
+	publisher.Publish(events.Event{
+		Type:       eventType,
+		TraderID:   at.id,
+		TraderName: at.name,
+		UserID:     at.userID,
+		Timestamp:  time.Now(),
+		Data:       data,
+	})
+}
+
+// publishActionEvents 根据执行成功的操作发布订单/持仓事件
+func (at *AutoTrader) publishActionEvents(action logger.DecisionAction, source string) {
+	if !action.Success {
+		return
+	}
+	data := map[string]interface{}{
+		"source": source,
+		"action": action,
+	}
+
+	switch action.Action {
+	case "open_long", "open_short":
+		at.publishEvent(events.TypeOrderFilled, data)
+		at.publishEvent(events.TypePositionOpened, data)
+	case "close_long", "close_short":
+		at.publishEvent(events.TypeOrderFilled, data)
+		at.publishEvent(events.TypePositionClosed, data)
+	case "partial_close":
+		at.publishEvent(events.TypeOrderFilled, data)
+	case "auto_close_long", "auto_close_short":
+		// 交易所侧触发的平仓，Error 字段记录平仓原因
+		data["reason"] = action.Error
+		at.publishEvent(events.TypePositionClosed, data)
+	case "update_stop_loss", "update_take_profit":
+		at.publishEvent(events.TypeOrderPlaced, data)
+	}
+}
+
+// publishRiskVeto 发布决策被否决事件
+func (at *AutoTrader) publishRiskVeto(d *decision.Decision, source, reason string) {
+	data := map[string]interface{}{
+		"source": source,
+		"reason": reason,
+	}
+	if d != nil {
+		data["symbol"] = d.Symbol
+		data["action"] = d.Action
+	}
+	at.publishEvent(events.TypeRiskVeto, data)
+}
+
+// publishBalanceUpdate 发布账户余额快照
+func (at *AutoTrader) publishBalanceUpdate(account decision.AccountInfo) {
+	at.publishEvent(events.TypeBalanceUpdate, account)
+}
+
+// publishRegimeChange 发布市场状态切换事件
+func (at *AutoTrader) publishRegimeChange(state *decision.RegimeState) {
+	at.publishEvent(events.TypeRegimeChange, state)
+}
@@ -0,0 +1,96 @@
+package trader
+
+import (
+	"nofx/events"
+	"nofx/logger"
+	"nofx/market"
+	"sync"
+	"time"
+)
+
+// fakeEventPublisher 记录发布的事件
+type fakeEventPublisher struct {
+	mu     sync.Mutex
+	events []events.Event
+}
+
+func (f *fakeEventPublisher) Publish(e events.Event) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events = append(f.events, e)
+}
+
+func (f *fakeEventPublisher) types() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var result []string
+	for _, e := range f.events {
+		result = append(result, e.Type)
+	}
+	return result
+}
+
+func (s *AutoTraderTestSuite) useEventPublisher() *fakeEventPublisher {
+	publisher := &fakeEventPublisher{}
+	SetEventPublisher(publisher)
+	s.T().Cleanup(func() { SetEventPublisher(nil) })
+	return publisher
+}
+
+// TestEvents_CycleLifecycle 测试决策周期开始/结束事件（暂停模式下直接结束）
+func (s *AutoTraderTestSuite) TestEvents_CycleLifecycle() {
+	publisher := s.useEventPublisher()
+	s.autoTrader.database = &fakeTradingModeStore{}
+	s.Require().NoError(s.autoTrader.SetTradingMode(TradingModePaused))
+
+	s.NoError(s.autoTrader.runCycle())
+	s.Equal([]string{events.TypeCycleStarted, events.TypeCycleFinished}, publisher.types())
+
+	finished := publisher.events[1]
+	s.Equal("test_trader", finished.TraderID)
+	s.Equal("test_user", finished.UserID)
+	s.Equal(true, finished.Data.(map[string]interface{})["success"])
+}
+
+// TestEvents_RiskPauseVeto 测试风控暂停期间发布否决事件
+func (s *AutoTraderTestSuite) TestEvents_RiskPauseVeto() {
+	publisher := s.useEventPublisher()
+	s.autoTrader.decisionLogger = logger.NewDecisionLogger(s.T().TempDir())
+	s.autoTrader.stopUntil = time.Now().Add(10 * time.Minute)
+
+	s.NoError(s.autoTrader.runCycle())
+	s.Equal([]string{events.TypeCycleStarted, events.TypeRiskVeto, events.TypeCycleFinished}, publisher.types())
+	s.Equal(false, publisher.events[2].Data.(map[string]interface{})["success"])
+	s.Equal(true, publisher.events[2].Data.(map[string]interface{})["skipped"])
+}
+
+// TestEvents_ManualClose 测试手动平仓发布成交与平仓事件
+func (s *AutoTraderTestSuite) TestEvents_ManualClose() {
+	publisher := s.useEventPublisher()
+	s.patches.ApplyFunc(market.Get, func(symbol string) (*market.Data, error) {
+		return &market.Data{Symbol: symbol, CurrentPrice: 100.0}, nil
+	})
+	s.autoTrader.decisionLogger = logger.NewDecisionLogger(s.T().TempDir())
+	s.mockTrader.positions = []map[string]interface{}{
+		{"symbol": "BTCUSDT", "side": "long", "positionAmt": 0.5},
+	}
+
+	_, err := s.autoTrader.ManualClosePosition("BTCUSDT", "", 0, "")
+	s.Require().NoError(err)
+	s.Equal([]string{events.TypeOrderFilled, events.TypePositionClosed}, publisher.types())
+	s.Equal(EventSourceManual, publisher.events[1].Data.(map[string]interface{})["source"])
+}
+
+// TestEvents_DrawdownMonitor 测试回撤监控发布持仓快照
+func (s *AutoTraderTestSuite) TestEvents_DrawdownMonitor() {
+	publisher := s.useEventPublisher()
+	s.mockTrader.positions = []map[string]interface{}{
+		{"symbol": "BTCUSDT", "side": "long", "entryPrice": 100.0, "markPrice": 101.0, "positionAmt": 1.0, "leverage": 5.0},
+	}
+
+	s.autoTrader.checkPositionDrawdown()
+	s.Require().Equal([]string{events.TypePositionUpdate}, publisher.types())
+	snapshots := publisher.events[0].Data.(map[string]interface{})["positions"].([]map[string]interface{})
+	s.Require().Len(snapshots, 1)
+	s.InDelta(5.0, snapshots[0]["pnl_pct"], 0.0001)
+}
@@ -0,0 +1,162 @@
+package trader
+
+import (
+	"fmt"
+	"log"
+	"nofx/config"
+)
+
+// ShadowOverrides 影子交易员相对对照组的配置差异（空值沿用对照组）
+type ShadowOverrides struct {
+	SystemPromptTemplate string
+	UserPromptTemplate   string
+	AIModel              *config.AIModelConfig
+}
+
+// ShadowTraderID 影子交易员ID（同时作为决策日志目录名）
+func ShadowTraderID(controlID, experimentID, arm string) string {
+	return fmt.Sprintf("%s_exp_%s_%s", controlID, experimentID, arm)
+}
+
+// NewShadowTrader 基于对照组创建影子交易员
+// 影子交易员复用对照组交易所账户的行情（价格与数量精度），以模拟账户下单，初始资金与对照组相同
+func (at *AutoTrader) NewShadowTrader(experimentID, arm string, overrides ShadowOverrides) (*AutoTrader, error) {
+	cfg := at.config
+	cfg.ID = ShadowTraderID(at.id, experimentID, arm)
+	cfg.Name = fmt.Sprintf("%s [%s]", at.name, arm)
+	cfg.InitialBalance = at.initialBalance
+	if overrides.SystemPromptTemplate != "" {
+		cfg.SystemPromptTemplate = overrides.SystemPromptTemplate
+	} else {
+		cfg.SystemPromptTemplate = at.systemPromptTemplate
+	}
+	if overrides.UserPromptTemplate != "" {
+		cfg.UserPromptTemplate = overrides.UserPromptTemplate
+	}
+	if model := overrides.AIModel; model != nil {
+		cfg.AIModel = model.Provider
+		cfg.UseQwen = model.Provider == "qwen"
+		cfg.CustomAPIURL = model.CustomAPIURL
+		cfg.CustomModelName = model.CustomModelName
+		switch model.Provider {
+		case "qwen":
+			cfg.QwenKey = model.APIKey
+		case "deepseek":
+			cfg.DeepSeekKey = model.APIKey
+		default:
+			cfg.CustomAPIKey = model.APIKey
+		}
+	}
+
+	executor := NewPaperTrader(at.trader, at.initialBalance, at.effectiveTakerFeeRate())
+	shadow, err := newAutoTrader(cfg, nil, at.userID, executor)
+	if err != nil {
+		return nil, fmt.Errorf("创建影子交易员失败: %w", err)
+	}
+	shadow.customPrompt = at.customPrompt
+	shadow.overrideBasePrompt = at.overrideBasePrompt
+	shadow.paperTrading = true
+	shadow.lessonSource = at
+	shadow.experimentID = experimentID
+	shadow.experimentArm = arm
+	return shadow, nil
+}
+
+// AttachExperiment 把交易员作为对照组加入实验，之后每个决策周期同时触发影子交易员
+func (at *AutoTrader) AttachExperiment(experimentID string, shadows []*AutoTrader) {
+	for _, shadow := range at.DetachExperiment() {
+		shadow.retire()
+	}
+
+	at.experimentMutex.Lock()
+	at.experimentID = experimentID
+	at.experimentArm = config.ExperimentControlArm
+	at.shadows = shadows
+	at.experimentMutex.Unlock()
+	log.Printf("🧪 [%s] 已加入实验 %s（%d 个影子交易员）", at.name, experimentID, len(shadows))
+}
+
+// DetachExperiment 结束实验，返回被移除的影子交易员
+func (at *AutoTrader) DetachExperiment() []*AutoTrader {
+	at.experimentMutex.Lock()
+	defer at.experimentMutex.Unlock()
+	shadows := at.shadows
+	at.experimentID, at.experimentArm, at.shadows = "", "", nil
+	return shadows
+}
+
+// StopExperiment 结束实验并释放影子交易员持有的行情订阅
+func (at *AutoTrader) StopExperiment() {
+	for _, shadow := range at.DetachExperiment() {
+		shadow.retire()
+	}
+}
+
+// retire 停用影子交易员；周期执行中时由该周期结束后释放订阅
+func (at *AutoTrader) retire() {
+	at.shadowRetired.Store(true)
+	if at.shadowBusy.CompareAndSwap(false, true) {
+		at.releaseLeases()
+	}
+}
+
+// GetExperiment 当前所属实验ID与分组（不在实验中时为空）
+func (at *AutoTrader) GetExperiment() (string, string) {
+	at.experimentMutex.RLock()
+	defer at.experimentMutex.RUnlock()
+	return at.experimentID, at.experimentArm
+}
+
+// GetShadowTraders 当前实验的影子交易员
+func (at *AutoTrader) GetShadowTraders() []*AutoTrader {
+	at.experimentMutex.RLock()
+	defer at.experimentMutex.RUnlock()
+	return append([]*AutoTrader(nil), at.shadows...)
+}
+
+// IsPaperTrading 是否为模拟下单的影子交易员
+func (at *AutoTrader) IsPaperTrading() bool {
+	return at.paperTrading
+}
+
+// triggerShadowCycles 触发所有影子交易员运行一个决策周期
+func (at *AutoTrader) triggerShadowCycles() {
+	for _, shadow := range at.GetShadowTraders() {
+		go shadow.runShadowCycle()
+	}
+}
+
+// runShadowCycle 运行影子交易员的一个周期（上一轮未结束时跳过，避免周期堆积）
+func (at *AutoTrader) runShadowCycle() {
+	if !at.shadowBusy.CompareAndSwap(false, true) {
+		log.Printf("⏭  [%s] 上一轮周期尚未结束，跳过本轮", at.name)
+		return
+	}
+	defer func() {
+		if at.shadowRetired.Load() {
+			at.releaseLeases() // 周期内实验已结束，保持 busy 状态不再运行
+			return
+		}
+		at.shadowBusy.Store(false)
+	}()
+
+	if at.shadowRetired.Load() {
+		return
+	}
+	if err := at.runCycle(); err != nil {
+		log.Printf("❌ [%s] 影子交易员周期失败: %v", at.name, err)
+	}
+}
+
+// releaseLeases 释放本交易员持有的K线、盘口与成交流订阅引用
+func (at *AutoTrader) releaseLeases() {
+	if at.klineLease != nil {
+		at.klineLease.Release()
+	}
+	if at.depthLease != nil {
+		at.depthLease.Release()
+	}
+	if at.flowLease != nil {
+		at.flowLease.Release()
+	}
+}
@@ -0,0 +1,99 @@
+package trader
+
+import (
+	"nofx/logger"
+	"os"
+	"testing"
+	"time"
+)
+
+// TestShadowTrader_AttachAndStop 测试影子交易员的创建、实验分组标记与结束
+func TestShadowTrader_AttachAndStop(t *testing.T) {
+	control := &AutoTrader{
+		id:                   "t1",
+		name:                 "Trader",
+		config:               AutoTraderConfig{ID: "t1", Exchange: "binance", AIModel: "deepseek", InitialBalance: 1000, UserPromptTemplate: "compact"},
+		trader:               &MockTrader{},
+		initialBalance:       1000,
+		systemPromptTemplate: "default",
+		customPrompt:         "只做BTC",
+		userID:               "user-a",
+	}
+
+	shadowID := ShadowTraderID("t1", "exp1", "aggressive")
+	defer os.RemoveAll("decision_logs/" + shadowID)
+
+	shadow, err := control.NewShadowTrader("exp1", "aggressive", ShadowOverrides{SystemPromptTemplate: "aggressive"})
+	if err != nil {
+		t.Fatalf("创建影子交易员失败: %v", err)
+	}
+	if shadow.GetID() != shadowID || !shadow.IsPaperTrading() {
+		t.Errorf("影子交易员ID或模拟标记不正确: %s %v", shadow.GetID(), shadow.IsPaperTrading())
+	}
+	if _, ok := shadow.trader.(*PaperTrader); !ok {
+		t.Errorf("影子交易员应使用模拟执行器: %T", shadow.trader)
+	}
+	if shadow.GetSystemPromptTemplate() != "aggressive" || shadow.GetUserPromptTemplate() != "compact" || shadow.customPrompt != "只做BTC" {
+		t.Errorf("应覆盖系统模板并沿用对照组其他配置: %s %s %q",
+			shadow.GetSystemPromptTemplate(), shadow.GetUserPromptTemplate(), shadow.customPrompt)
+	}
+	if id, arm := shadow.GetExperiment(); id != "exp1" || arm != "aggressive" {
+		t.Errorf("影子交易员分组不正确: %s %s", id, arm)
+	}
+
+	control.AttachExperiment("exp1", []*AutoTrader{shadow})
+	if id, arm := control.GetExperiment(); id != "exp1" || arm != "control" {
+		t.Errorf("对照组分组不正确: %s %s", id, arm)
+	}
+	if len(control.GetShadowTraders()) != 1 {
+		t.Fatalf("应附带一个影子交易员")
+	}
+
+	control.StopExperiment()
+	if id, _ := control.GetExperiment(); id != "" || len(control.GetShadowTraders()) != 0 {
+		t.Errorf("结束实验后应清除分组与影子交易员")
+	}
+	if !shadow.shadowRetired.Load() {
+		t.Errorf("结束实验后影子交易员应停用")
+	}
+	shadow.runShadowCycle() // 已停用的影子交易员不再运行周期
+	if shadow.callCount != 0 {
+		t.Errorf("已停用的影子交易员不应运行周期: %d", shadow.callCount)
+	}
+}
+
+// TestShadowCycles_SkippedWhileControlGated 测试对照组风控暂停或运行模式暂停时影子交易员不运行周期
+func TestShadowCycles_SkippedWhileControlGated(t *testing.T) {
+	tests := []struct {
+		name string
+		gate func(at *AutoTrader)
+	}{
+		{"风控暂停", func(at *AutoTrader) { at.stopUntil = time.Now().Add(time.Hour) }},
+		{"运行模式暂停", func(at *AutoTrader) { at.tradingMode = TradingModePaused }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shadow := &AutoTrader{
+				name:           "Shadow",
+				stopUntil:      time.Now().Add(time.Hour),
+				decisionLogger: logger.NewDecisionLogger(t.TempDir()),
+			}
+			control := &AutoTrader{
+				name:           "Trader",
+				trader:         &MockTrader{},
+				decisionLogger: logger.NewDecisionLogger(t.TempDir()),
+			}
+			tt.gate(control)
+			control.AttachExperiment("exp1", []*AutoTrader{shadow})
+
+			if err := control.runCycle(); err != nil {
+				t.Fatalf("runCycle 失败: %v", err)
+			}
+			time.Sleep(50 * time.Millisecond)
+			if shadow.shadowBusy.Load() || shadow.callCount != 0 {
+				t.Errorf("对照组被暂停时影子交易员不应运行周期: %d", shadow.callCount)
+			}
+		})
+	}
+}
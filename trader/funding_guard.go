@@ -0,0 +1,50 @@
+package trader
+
+import (
+	"fmt"
+	"nofx/decision"
+	"time"
+)
+
+const (
+	fundingGuardWindow      = 30 * time.Minute // 距下次结算不足该时间视为临近结算
+	fundingGuardMinNotional = 1000.0           // 仓位名义价值达到该值（USDT）才检查结算成本
+	fundingWarnRate         = 0.0005           // 不利费率 ≥ 0.05% 时提示
+	fundingBlockRate        = 0.001            // 不利费率 ≥ 0.1% 时禁止开仓
+)
+
+// checkFundingSettlement 临近资金费率结算时的开仓风控：大仓位在不利费率结算前开仓时提示，费率过高时拒绝
+func (at *AutoTrader) checkFundingSettlement(ctx *decision.Context, d *decision.Decision) (bool, string) {
+	if ctx == nil || d.PositionSizeUSD < fundingGuardMinNotional {
+		return true, ""
+	}
+	data, ok := ctx.MarketDataMap[d.Symbol]
+	if !ok || data.Funding == nil {
+		return true, ""
+	}
+
+	funding := data.Funding
+	ttf := funding.TimeToFunding()
+	if ttf <= 0 || ttf > fundingGuardWindow {
+		return true, ""
+	}
+
+	long := d.Action == "open_long"
+	adverse := funding.AdverseRate(long)
+	if adverse < fundingWarnRate {
+		return true, ""
+	}
+
+	side := "空单"
+	if long {
+		side = "多单"
+	}
+	cost := d.PositionSizeUSD * adverse
+	minutes := int(ttf.Minutes())
+	if adverse >= fundingBlockRate {
+		return false, fmt.Sprintf("%d分钟后结算资金费率 %.4f%%，%s %.0f USDT 将支付约 %.2f USDT，等待结算后再开仓",
+			minutes, funding.PredictedRate*100, side, d.PositionSizeUSD, cost)
+	}
+	return true, fmt.Sprintf("注意：%d分钟后结算资金费率 %.4f%%，%s将支付约 %.2f USDT",
+		minutes, funding.PredictedRate*100, side, cost)
+}
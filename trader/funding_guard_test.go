@@ -0,0 +1,42 @@
+package trader
+
+import (
+	"nofx/decision"
+	"nofx/market"
+	"time"
+)
+
+// TestCheckFundingSettlement 测试临近资金费率结算的开仓风控
+func (s *AutoTraderTestSuite) TestCheckFundingSettlement() {
+	ctxWith := func(rate float64, untilSettlement time.Duration) *decision.Context {
+		funding := &market.FundingInfo{PredictedRate: rate, NextFundingTime: time.Now().Add(untilSettlement)}
+		return &decision.Context{MarketDataMap: map[string]*market.Data{"ETHUSDT": {Symbol: "ETHUSDT", Funding: funding}}}
+	}
+	long := decision.Decision{Action: "open_long", Symbol: "ETHUSDT", PositionSizeUSD: 20000}
+	short := decision.Decision{Action: "open_short", Symbol: "ETHUSDT", PositionSizeUSD: 20000}
+
+	tests := []struct {
+		name     string
+		ctx      *decision.Context
+		d        decision.Decision
+		allowed  bool
+		wantNote bool
+	}{
+		{"远离结算_放行", ctxWith(0.002, 3*time.Hour), long, true, false},
+		{"费率对空单有利_放行", ctxWith(0.002, 10*time.Minute), short, true, false},
+		{"小仓位_放行", ctxWith(0.002, 10*time.Minute), decision.Decision{Action: "open_long", Symbol: "ETHUSDT", PositionSizeUSD: 200}, true, false},
+		{"不利费率偏高_提示", ctxWith(0.0006, 10*time.Minute), long, true, true},
+		{"不利费率过高_拒绝多单", ctxWith(0.0015, 10*time.Minute), long, false, true},
+		{"负费率_拒绝空单", ctxWith(-0.0015, 10*time.Minute), short, false, true},
+		{"无资金费率数据_放行", &decision.Context{MarketDataMap: map[string]*market.Data{}}, long, true, false},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			d := tt.d
+			allowed, note := s.autoTrader.checkFundingSettlement(tt.ctx, &d)
+			s.Equal(tt.allowed, allowed, note)
+			s.Equal(tt.wantNote, note != "", note)
+		})
+	}
+}
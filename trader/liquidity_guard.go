@@ -0,0 +1,61 @@
+package trader
+
+import (
+	"fmt"
+	"nofx/decision"
+	"nofx/market"
+)
+
+const (
+	maxEntrySpreadBps   = 15.0 // 买卖价差超过该值（基点）禁止开仓
+	maxEntrySlippageBps = 30.0 // 计划仓位的预估滑点超过该值（基点）禁止开仓
+	maxDepthShare       = 0.25 // 单笔仓位最多占 ±1% 同侧盘口深度的比例
+	minLiquidityEntry   = 12.0 // 按深度缩减后的最小开仓名义价值（USDT）
+)
+
+// checkOrderBookLiquidity 基于盘口深度的开仓风控：价差过大或滑点过高时拒绝，仓位超过盘口承载能力时缩减
+func (at *AutoTrader) checkOrderBookLiquidity(ctx *decision.Context, d *decision.Decision) (bool, string) {
+	// 非币安交易员使用交易所自身的行情，币安盘口不代表实际成交深度
+	if at.dataSource != nil || d.PositionSizeUSD <= 0 {
+		return true, ""
+	}
+
+	// 优先使用本地实时盘口按实际仓位计算，其次使用决策上下文中的盘口数据
+	ob, sizeMatched := market.DefaultDepthMonitor().Metrics(d.Symbol, d.PositionSizeUSD)
+	if !sizeMatched && ctx != nil {
+		if data, ok := ctx.MarketDataMap[d.Symbol]; ok {
+			ob = data.OrderBook
+		}
+	}
+	if ob == nil {
+		return true, ""
+	}
+
+	if ob.SpreadBps > maxEntrySpreadBps {
+		return false, fmt.Sprintf("盘口价差 %.1f bps > %.0f bps，流动性不足", ob.SpreadBps, maxEntrySpreadBps)
+	}
+
+	long := d.Action == "open_long"
+	depth := ob.BidDepth1
+	if long {
+		depth = ob.AskDepth1
+	}
+	// 可见盘口未覆盖 ±1% 时深度被低估，不据此缩减仓位
+	if !ob.Truncated && depth > 0 && d.PositionSizeUSD > depth*maxDepthShare {
+		original := d.PositionSizeUSD
+		capped := depth * maxDepthShare
+		if capped < minLiquidityEntry {
+			return false, fmt.Sprintf("±1%%盘口深度仅 %.0f USDT，无法承载开仓", depth)
+		}
+		d.PositionSizeUSD = capped
+		return true, fmt.Sprintf("仓位从 %.2f 调整至 %.2f USDT（不超过±1%%盘口深度的%.0f%%）", original, capped, maxDepthShare*100)
+	}
+
+	if sizeMatched {
+		slippage := ob.SlippageBps(long)
+		if ob.Unfillable || slippage > maxEntrySlippageBps {
+			return false, fmt.Sprintf("预估滑点 %.1f bps > %.0f bps（仓位 %.0f USDT）", slippage, maxEntrySlippageBps, d.PositionSizeUSD)
+		}
+	}
+	return true, ""
+}
@@ -0,0 +1,39 @@
+package trader
+
+import (
+	"nofx/decision"
+	"nofx/market"
+)
+
+// TestCheckOrderBookLiquidity 测试盘口深度风控
+func (s *AutoTraderTestSuite) TestCheckOrderBookLiquidity() {
+	ctxWith := func(ob *market.OrderBookMetrics) *decision.Context {
+		return &decision.Context{MarketDataMap: map[string]*market.Data{"SOLUSDT": {Symbol: "SOLUSDT", OrderBook: ob}}}
+	}
+	deep := &market.OrderBookMetrics{SpreadBps: 1, BidDepth1: 400000, AskDepth1: 200000}
+
+	tests := []struct {
+		name     string
+		ob       *market.OrderBookMetrics
+		d        decision.Decision
+		allowed  bool
+		wantSize float64
+	}{
+		{"无盘口数据_放行", nil, decision.Decision{Action: "open_long", Symbol: "SOLUSDT", PositionSizeUSD: 1000}, true, 1000},
+		{"深度充足_放行", deep, decision.Decision{Action: "open_long", Symbol: "SOLUSDT", PositionSizeUSD: 1000}, true, 1000},
+		{"价差过大_拒绝", &market.OrderBookMetrics{SpreadBps: 25, BidDepth1: 1e6, AskDepth1: 1e6}, decision.Decision{Action: "open_short", Symbol: "SOLUSDT", PositionSizeUSD: 1000}, false, 1000},
+		{"超过卖盘深度_缩减多单", deep, decision.Decision{Action: "open_long", Symbol: "SOLUSDT", PositionSizeUSD: 80000}, true, 50000},
+		{"空单按买盘深度_不缩减", deep, decision.Decision{Action: "open_short", Symbol: "SOLUSDT", PositionSizeUSD: 80000}, true, 80000},
+		{"盘口截断_不缩减", &market.OrderBookMetrics{SpreadBps: 1, BidDepth1: 1000, AskDepth1: 1000, Truncated: true}, decision.Decision{Action: "open_long", Symbol: "SOLUSDT", PositionSizeUSD: 5000}, true, 5000},
+		{"深度过薄_拒绝", &market.OrderBookMetrics{SpreadBps: 1, BidDepth1: 40, AskDepth1: 40}, decision.Decision{Action: "open_long", Symbol: "SOLUSDT", PositionSizeUSD: 100}, false, 100},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			d := tt.d
+			allowed, note := s.autoTrader.checkOrderBookLiquidity(ctxWith(tt.ob), &d)
+			s.Equal(tt.allowed, allowed, note)
+			s.InDelta(tt.wantSize, d.PositionSizeUSD, 0.01)
+		})
+	}
+}
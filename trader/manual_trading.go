@@ -0,0 +1,298 @@
+package trader
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"nofx/decision"
+	"nofx/logger"
+	"strings"
+	"time"
+)
+
+// ManualCloseReason 手动操作在决策日志中的平仓原因标记
+const ManualCloseReason = "manual"
+
+// 手动撤单类型
+const (
+	CancelOrderTypeAll        = "all"
+	CancelOrderTypeStopLoss   = "stop_loss"
+	CancelOrderTypeTakeProfit = "take_profit"
+	CancelOrderTypeStop       = "stop" // 止损 + 止盈
+)
+
+// ManualClosePosition 手动平仓（percentage 为 0 或 100 时全部平仓，否则按百分比部分平仓）
+// side 为空时自动从当前持仓推断方向
+func (at *AutoTrader) ManualClosePosition(symbol, side string, percentage float64, note string) (*logger.DecisionAction, error) {
+	symbol = normalizeSymbol(symbol)
+	if percentage < 0 || percentage > 100 {
+		return nil, fmt.Errorf("平仓百分比必须在 0-100 之间，当前: %.1f", percentage)
+	}
+
+	at.executionMutex.Lock()
+	defer at.executionMutex.Unlock()
+	at.invalidateDecisionCyclePositions()
+
+	side, err := at.resolvePositionSide(symbol, side)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &decision.Decision{
+		Symbol:          symbol,
+		Reasoning:       manualReason(note),
+		ClosePercentage: percentage,
+	}
+	switch {
+	case percentage > 0 && percentage < 100:
+		d.Action = "partial_close"
+	case side == "long":
+		d.Action = "close_long"
+	default:
+		d.Action = "close_short"
+	}
+
+	actionRecord := at.newManualAction(d)
+	switch d.Action {
+	case "partial_close":
+		err = at.executePartialCloseWithRecord(d, actionRecord)
+	case "close_long":
+		err = at.executeCloseLongWithRecord(d, actionRecord)
+	default:
+		err = at.executeCloseShortWithRecord(d, actionRecord)
+	}
+	// partial_close 可能被自动修正为全平，以执行后的 action 为准
+	actionRecord.Action = d.Action
+	actionRecord.CloseReason = ManualCloseReason
+
+	if err == nil && d.Action != "partial_close" {
+		// 全部平仓后移除快照，避免下一周期被识别为被动平仓
+		delete(at.lastPositions, symbol+"_"+side)
+		at.ClearPeakPnLCache(symbol, side)
+	}
+
+	at.logManualActions([]logger.DecisionAction{*finishManualAction(actionRecord, err)})
+	return actionRecord, err
+}
+
+// ManualCloseAllPositions 一键平掉所有持仓（交易员继续运行）
+func (at *AutoTrader) ManualCloseAllPositions(note string) ([]logger.DecisionAction, error) {
+	at.executionMutex.Lock()
+	defer at.executionMutex.Unlock()
+	at.invalidateDecisionCyclePositions()
+
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		return nil, fmt.Errorf("获取持仓失败: %w", err)
+	}
+
+	var actions []logger.DecisionAction
+	var failed []string
+	for _, pos := range positions {
+		symbol, _ := pos["symbol"].(string)
+		side, _ := pos["side"].(string)
+		amt, _ := pos["positionAmt"].(float64)
+		if symbol == "" || amt == 0 {
+			continue
+		}
+
+		d := &decision.Decision{Symbol: symbol, Reasoning: manualReason(note)}
+		actionRecord := at.newManualAction(d)
+		actionRecord.Quantity = math.Abs(amt)
+		if side == "long" {
+			d.Action = "close_long"
+			actionRecord.Action = d.Action
+			err = at.executeCloseLongWithRecord(d, actionRecord)
+		} else {
+			d.Action = "close_short"
+			actionRecord.Action = d.Action
+			err = at.executeCloseShortWithRecord(d, actionRecord)
+		}
+		actionRecord.CloseReason = ManualCloseReason
+
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s %s: %v", symbol, side, err))
+		} else {
+			delete(at.lastPositions, symbol+"_"+side)
+			at.ClearPeakPnLCache(symbol, side)
+		}
+		actions = append(actions, *finishManualAction(actionRecord, err))
+	}
+
+	if len(actions) > 0 {
+		at.logManualActions(actions)
+	}
+	if len(failed) > 0 {
+		return actions, fmt.Errorf("部分持仓平仓失败: %s", strings.Join(failed, "; "))
+	}
+	return actions, nil
+}
+
+// ManualUpdateStopLoss 手动调整止损价格
+func (at *AutoTrader) ManualUpdateStopLoss(symbol string, price float64, note string) (*logger.DecisionAction, error) {
+	return at.manualUpdateProtection(symbol, "update_stop_loss", price, note)
+}
+
+// ManualUpdateTakeProfit 手动调整止盈价格
+func (at *AutoTrader) ManualUpdateTakeProfit(symbol string, price float64, note string) (*logger.DecisionAction, error) {
+	return at.manualUpdateProtection(symbol, "update_take_profit", price, note)
+}
+
+// manualUpdateProtection 复用 AI 决策的止损/止盈调整逻辑
+func (at *AutoTrader) manualUpdateProtection(symbol, action string, price float64, note string) (*logger.DecisionAction, error) {
+	symbol = normalizeSymbol(symbol)
+	if price <= 0 {
+		return nil, fmt.Errorf("价格必须大于0")
+	}
+
+	at.executionMutex.Lock()
+	defer at.executionMutex.Unlock()
+	at.invalidateDecisionCyclePositions()
+
+	d := &decision.Decision{Symbol: symbol, Action: action, Reasoning: manualReason(note)}
+	actionRecord := at.newManualAction(d)
+
+	var err error
+	if action == "update_stop_loss" {
+		d.NewStopLoss = price
+		err = at.executeUpdateStopLossWithRecord(d, actionRecord)
+	} else {
+		d.NewTakeProfit = price
+		err = at.executeUpdateTakeProfitWithRecord(d, actionRecord)
+	}
+
+	at.logManualActions([]logger.DecisionAction{*finishManualAction(actionRecord, err)})
+	return actionRecord, err
+}
+
+// ManualCancelOrders 手动撤销挂单（orderType: all / stop_loss / take_profit / stop）
+func (at *AutoTrader) ManualCancelOrders(symbol, orderType, note string) (*logger.DecisionAction, error) {
+	symbol = normalizeSymbol(symbol)
+	if orderType == "" {
+		orderType = CancelOrderTypeAll
+	}
+
+	at.executionMutex.Lock()
+	defer at.executionMutex.Unlock()
+
+	d := &decision.Decision{Symbol: symbol, Action: "cancel_orders", Reasoning: manualReason(note)}
+	actionRecord := at.newManualAction(d)
+
+	var err error
+	switch orderType {
+	case CancelOrderTypeAll:
+		err = at.trader.CancelAllOrders(symbol)
+	case CancelOrderTypeStopLoss:
+		err = at.trader.CancelStopLossOrders(symbol)
+	case CancelOrderTypeTakeProfit:
+		err = at.trader.CancelTakeProfitOrders(symbol)
+	case CancelOrderTypeStop:
+		err = at.trader.CancelStopOrders(symbol)
+	default:
+		return nil, fmt.Errorf("不支持的撤单类型: %s", orderType)
+	}
+	actionRecord.Reason = fmt.Sprintf("%s [%s]", actionRecord.Reason, orderType)
+
+	if err == nil {
+		log.Printf("  ✓ [%s] 已手动撤销 %s 的挂单 (%s)", at.name, symbol, orderType)
+	}
+
+	at.logManualActions([]logger.DecisionAction{*finishManualAction(actionRecord, err)})
+	return actionRecord, err
+}
+
+// resolvePositionSide 校验持仓存在并返回方向（long/short）
+func (at *AutoTrader) resolvePositionSide(symbol, side string) (string, error) {
+	side = strings.ToLower(side)
+	if side != "" && side != "long" && side != "short" {
+		return "", fmt.Errorf("无效的持仓方向: %s", side)
+	}
+
+	positions, err := at.trader.GetPositions()
+	if err != nil {
+		return "", fmt.Errorf("获取持仓失败: %w", err)
+	}
+	for _, pos := range positions {
+		posSymbol, _ := pos["symbol"].(string)
+		posSide, _ := pos["side"].(string)
+		amt, _ := pos["positionAmt"].(float64)
+		if posSymbol != symbol || amt == 0 {
+			continue
+		}
+		if side == "" || side == posSide {
+			return posSide, nil
+		}
+	}
+
+	if side != "" {
+		return "", fmt.Errorf("持仓不存在: %s %s", symbol, side)
+	}
+	return "", fmt.Errorf("持仓不存在: %s", symbol)
+}
+
+// invalidateDecisionCyclePositions 清除决策周期持仓缓存，确保手动操作读取最新持仓
+func (at *AutoTrader) invalidateDecisionCyclePositions() {
+	at.decisionCyclePositionsMutex.Lock()
+	at.decisionCyclePositions = nil
+	at.decisionCyclePositionsTime = time.Time{}
+	at.decisionCyclePositionsMutex.Unlock()
+}
+
+// newManualAction 创建手动操作的决策动作记录
+func (at *AutoTrader) newManualAction(d *decision.Decision) *logger.DecisionAction {
+	return &logger.DecisionAction{
+		Action:    d.Action,
+		Symbol:    d.Symbol,
+		Timestamp: time.Now(),
+		Reason:    d.Reasoning,
+	}
+}
+
+// finishManualAction 根据执行结果填充成功/失败信息
+func finishManualAction(actionRecord *logger.DecisionAction, err error) *logger.DecisionAction {
+	if err != nil {
+		actionRecord.Success = false
+		actionRecord.Error = err.Error()
+	} else {
+		actionRecord.Success = true
+	}
+	return actionRecord
+}
+
+// logManualActions 将手动操作写入决策日志（TriggeredBy=manual）
+func (at *AutoTrader) logManualActions(actions []logger.DecisionAction) {
+	record := &logger.DecisionRecord{
+		Exchange:     at.config.Exchange,
+		Decisions:    actions,
+		ExecutionLog: []string{},
+		Success:      true,
+		TriggeredBy:  ManualCloseReason,
+	}
+	for _, action := range actions {
+		at.publishActionEvents(action, EventSourceManual)
+		if action.Success {
+			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("✓ [手动] %s %s 成功", action.Symbol, action.Action))
+		} else {
+			record.Success = false
+			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("❌ [手动] %s %s 失败: %s", action.Symbol, action.Action, action.Error))
+		}
+	}
+	if !record.Success {
+		record.ErrorMessage = "部分手动操作执行失败"
+	}
+
+	if at.decisionLogger == nil {
+		return
+	}
+	if err := at.decisionLogger.LogDecision(record); err != nil {
+		log.Printf("⚠ [%s] 保存手动操作记录失败: %v", at.name, err)
+	}
+}
+
+// manualReason 生成手动操作的理由说明
+func manualReason(note string) string {
+	if strings.TrimSpace(note) == "" {
+		return "manual: 用户手动操作"
+	}
+	return "manual: " + strings.TrimSpace(note)
+}
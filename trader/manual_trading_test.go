@@ -0,0 +1,119 @@
+package trader
+
+import (
+	"nofx/decision"
+	"nofx/logger"
+	"nofx/market"
+)
+
+// TestManualClosePosition 测试手动平仓：平仓原因为 manual、写入手动决策记录、交易员保持运行
+func (s *AutoTraderTestSuite) TestManualClosePosition() {
+	s.patches.ApplyFunc(market.Get, func(symbol string) (*market.Data, error) {
+		return &market.Data{Symbol: symbol, CurrentPrice: 51000.0}, nil
+	})
+
+	decisionLogger := logger.NewDecisionLogger(s.T().TempDir())
+	s.autoTrader.decisionLogger = decisionLogger
+	s.autoTrader.isRunning = true
+	s.mockTrader.positions = []map[string]interface{}{
+		{"symbol": "BTCUSDT", "side": "long", "positionAmt": 0.5, "entryPrice": 50000.0, "markPrice": 51000.0, "leverage": 10.0},
+	}
+	s.autoTrader.lastPositions["BTCUSDT_long"] = decision.PositionInfo{Symbol: "BTCUSDT", Side: "long", Quantity: 0.5}
+
+	action, err := s.autoTrader.ManualClosePosition("btc", "", 0, "止盈离场")
+	s.Require().NoError(err)
+	s.Equal("close_long", action.Action)
+	s.Equal(ManualCloseReason, action.CloseReason)
+	s.Equal(int64(123458), action.OrderID)
+	s.True(action.Success)
+	s.True(s.autoTrader.isRunning, "手动平仓不应停止交易员")
+	s.NotContains(s.autoTrader.lastPositions, "BTCUSDT_long", "手动平仓后不应再被识别为被动平仓")
+
+	records, err := decisionLogger.GetLatestRecords(10)
+	s.Require().NoError(err)
+	s.Require().Len(records, 1)
+	s.Equal("manual", records[0].TriggeredBy)
+	s.Require().Len(records[0].Decisions, 1)
+	s.Equal(ManualCloseReason, records[0].Decisions[0].CloseReason)
+	s.Contains(records[0].Decisions[0].Reason, "止盈离场")
+
+	// 不存在的持仓/方向
+	_, err = s.autoTrader.ManualClosePosition("ETHUSDT", "", 0, "")
+	s.Error(err)
+	_, err = s.autoTrader.ManualClosePosition("BTCUSDT", "short", 0, "")
+	s.Error(err)
+	_, err = s.autoTrader.ManualClosePosition("BTCUSDT", "long", 150, "")
+	s.Error(err)
+}
+
+// TestRefreshPositionSnapshot_ManualCloseDuringCycle 测试AI调用期间被手动平仓的持仓不会在下一周期被误记为被动平仓
+func (s *AutoTraderTestSuite) TestRefreshPositionSnapshot_ManualCloseDuringCycle() {
+	full := func(symbol, side string, amt float64) map[string]interface{} {
+		return map[string]interface{}{"symbol": symbol, "side": side, "positionAmt": amt, "entryPrice": 100.0,
+			"markPrice": 101.0, "unRealizedProfit": 1.0, "liquidationPrice": 50.0, "leverage": 10.0}
+	}
+	// 周期开始时有两个持仓，AI调用期间 ETHUSDT 空仓被手动平掉
+	s.autoTrader.lastPositions = map[string]decision.PositionInfo{}
+	s.autoTrader.positionFirstSeenTime["ETHUSDT_short"] = 1
+	s.autoTrader.positionStopLoss["ETHUSDT_short"] = 105
+	s.mockTrader.positions = []map[string]interface{}{full("BTCUSDT", "long", 0.5)}
+
+	s.autoTrader.refreshPositionSnapshot()
+
+	s.Contains(s.autoTrader.lastPositions, "BTCUSDT_long")
+	s.NotContains(s.autoTrader.lastPositions, "ETHUSDT_short", "快照应基于执行后的实时持仓")
+	s.NotContains(s.autoTrader.positionStopLoss, "ETHUSDT_short", "已平仓持仓的止损记录应被清理")
+	s.Empty(s.autoTrader.detectClosedPositions([]decision.PositionInfo{{Symbol: "BTCUSDT", Side: "long"}}))
+}
+
+// TestManualCloseAllPositions 测试一键平仓
+func (s *AutoTraderTestSuite) TestManualCloseAllPositions() {
+	s.patches.ApplyFunc(market.Get, func(symbol string) (*market.Data, error) {
+		return &market.Data{Symbol: symbol, CurrentPrice: 100.0}, nil
+	})
+
+	decisionLogger := logger.NewDecisionLogger(s.T().TempDir())
+	s.autoTrader.decisionLogger = decisionLogger
+	s.mockTrader.positions = []map[string]interface{}{
+		{"symbol": "BTCUSDT", "side": "long", "positionAmt": 0.5},
+		{"symbol": "ETHUSDT", "side": "short", "positionAmt": -2.0},
+	}
+
+	actions, err := s.autoTrader.ManualCloseAllPositions("")
+	s.Require().NoError(err)
+	s.Require().Len(actions, 2)
+	s.Equal("close_long", actions[0].Action)
+	s.Equal("close_short", actions[1].Action)
+	for _, action := range actions {
+		s.Equal(ManualCloseReason, action.CloseReason)
+		s.True(action.Success)
+	}
+
+	records, err := decisionLogger.GetLatestRecords(10)
+	s.Require().NoError(err)
+	s.Require().Len(records, 1)
+	s.Len(records[0].Decisions, 2)
+
+	// 部分失败时返回错误但保留已执行的动作
+	s.mockTrader.shouldFailCloseLong = true
+	actions, err = s.autoTrader.ManualCloseAllPositions("")
+	s.Error(err)
+	s.Len(actions, 2)
+	s.False(actions[0].Success)
+	s.True(actions[1].Success)
+}
+
+// TestManualCancelOrders 测试手动撤单类型校验
+func (s *AutoTraderTestSuite) TestManualCancelOrders() {
+	s.autoTrader.decisionLogger = logger.NewDecisionLogger(s.T().TempDir())
+
+	for _, orderType := range []string{"", CancelOrderTypeAll, CancelOrderTypeStopLoss, CancelOrderTypeTakeProfit, CancelOrderTypeStop} {
+		action, err := s.autoTrader.ManualCancelOrders("BTCUSDT", orderType, "")
+		s.NoError(err)
+		s.Equal("cancel_orders", action.Action)
+		s.True(action.Success)
+	}
+
+	_, err := s.autoTrader.ManualCancelOrders("BTCUSDT", "market", "")
+	s.Error(err)
+}
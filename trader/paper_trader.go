@@ -0,0 +1,439 @@
+package trader
+
+import (
+	"fmt"
+	"nofx/decision"
+	"sort"
+	"sync"
+	"time"
+)
+
+// PaperTrader 模拟下单的交易器（A/B 实验的影子交易员使用）
+// 行情价格与数量精度来自真实交易所账户，余额、持仓和止盈止损单只在内存中模拟，不会向交易所下单
+type PaperTrader struct {
+	market       Trader // 真实交易所交易器，仅用于读取价格与数量精度
+	takerFeeRate float64
+
+	mu          sync.Mutex
+	wallet      float64                   // 钱包余额（已实现盈亏与手续费计入）
+	positions   map[string]*paperPosition // symbol_side -> 持仓
+	orders      []*paperOrder             // 止盈止损单
+	leverages   map[string]int            // symbol -> 杠杆
+	nextOrderID int64
+}
+
+type paperPosition struct {
+	symbol     string
+	side       string // long / short
+	quantity   float64
+	entryPrice float64
+	leverage   int
+	markPrice  float64
+}
+
+type paperOrder struct {
+	id           int64
+	symbol       string
+	positionSide string // LONG / SHORT
+	kind         string // stop_loss / take_profit
+	quantity     float64
+	stopPrice    float64
+}
+
+// NewPaperTrader 创建模拟交易器
+func NewPaperTrader(market Trader, initialBalance, takerFeeRate float64) *PaperTrader {
+	return &PaperTrader{
+		market:       market,
+		takerFeeRate: takerFeeRate,
+		wallet:       initialBalance,
+		positions:    make(map[string]*paperPosition),
+		leverages:    make(map[string]int),
+		nextOrderID:  time.Now().UnixMilli(),
+	}
+}
+
+func paperPositionKey(symbol, side string) string {
+	return symbol + "_" + side
+}
+
+func (p *PaperTrader) newOrderID() int64 {
+	p.nextOrderID++
+	return p.nextOrderID
+}
+
+// refresh 更新持仓标记价格，并按标记价格撮合触发的止盈止损单与强平（调用方持有锁）
+func (p *PaperTrader) refresh() error {
+	for key, pos := range p.positions {
+		price, err := p.market.GetMarketPrice(pos.symbol)
+		if err != nil {
+			return fmt.Errorf("获取 %s 价格失败: %w", pos.symbol, err)
+		}
+		pos.markPrice = price
+
+		if liq := pos.liquidationPrice(); liq > 0 &&
+			((pos.side == "long" && price <= liq) || (pos.side == "short" && price >= liq)) {
+			p.closeLocked(pos, pos.quantity, liq)
+			p.removeOrdersLocked(pos.symbol, "")
+			delete(p.positions, key)
+			continue
+		}
+
+		for _, order := range p.triggeredOrdersLocked(pos, price) {
+			qty := order.quantity
+			if qty <= 0 || qty > pos.quantity {
+				qty = pos.quantity
+			}
+			p.closeLocked(pos, qty, order.stopPrice)
+			p.removeOrdersLocked(pos.symbol, order.kind)
+			if pos.quantity <= 0 {
+				break
+			}
+		}
+		if pos.quantity <= 1e-12 {
+			p.removeOrdersLocked(pos.symbol, "")
+			delete(p.positions, key)
+		}
+	}
+	return nil
+}
+
+// triggeredOrdersLocked 返回当前价格下触发的止盈止损单（止损优先）
+func (p *PaperTrader) triggeredOrdersLocked(pos *paperPosition, price float64) []*paperOrder {
+	var triggered []*paperOrder
+	for _, order := range p.orders {
+		if order.symbol != pos.symbol || order.positionSide != sideToPositionSide(pos.side) {
+			continue
+		}
+		hit := false
+		switch {
+		case order.kind == "stop_loss" && pos.side == "long":
+			hit = price <= order.stopPrice
+		case order.kind == "stop_loss" && pos.side == "short":
+			hit = price >= order.stopPrice
+		case order.kind == "take_profit" && pos.side == "long":
+			hit = price >= order.stopPrice
+		case order.kind == "take_profit" && pos.side == "short":
+			hit = price <= order.stopPrice
+		}
+		if hit {
+			triggered = append(triggered, order)
+		}
+	}
+	sort.SliceStable(triggered, func(i, j int) bool {
+		return triggered[i].kind == "stop_loss" && triggered[j].kind != "stop_loss"
+	})
+	return triggered
+}
+
+func sideToPositionSide(side string) string {
+	if side == "short" {
+		return "SHORT"
+	}
+	return "LONG"
+}
+
+// liquidationPrice 逐仓估算的强平价（保证金亏完即强平，忽略维持保证金）
+func (pos *paperPosition) liquidationPrice() float64 {
+	if pos.leverage <= 0 {
+		return 0
+	}
+	if pos.side == "long" {
+		return pos.entryPrice * (1 - 1/float64(pos.leverage))
+	}
+	return pos.entryPrice * (1 + 1/float64(pos.leverage))
+}
+
+func (pos *paperPosition) unrealizedPnL() float64 {
+	if pos.side == "long" {
+		return pos.quantity * (pos.markPrice - pos.entryPrice)
+	}
+	return pos.quantity * (pos.entryPrice - pos.markPrice)
+}
+
+// closeLocked 按指定价格平掉部分或全部仓位，盈亏与手续费计入钱包
+func (p *PaperTrader) closeLocked(pos *paperPosition, quantity, price float64) {
+	pnl := quantity * (price - pos.entryPrice)
+	if pos.side == "short" {
+		pnl = -pnl
+	}
+	fee := quantity * price * p.takerFeeRate
+	p.wallet += pnl - fee
+	pos.quantity -= quantity
+}
+
+// removeOrdersLocked 删除该币种的止盈止损单（kind 为空表示全部）
+func (p *PaperTrader) removeOrdersLocked(symbol, kind string) {
+	kept := p.orders[:0]
+	for _, order := range p.orders {
+		if order.symbol == symbol && (kind == "" || order.kind == kind) {
+			continue
+		}
+		kept = append(kept, order)
+	}
+	p.orders = kept
+}
+
+// GetBalance 获取模拟账户余额
+func (p *PaperTrader) GetBalance() (map[string]interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.refresh(); err != nil {
+		return nil, err
+	}
+
+	unrealized, marginUsed := 0.0, 0.0
+	for _, pos := range p.positions {
+		unrealized += pos.unrealizedPnL()
+		marginUsed += pos.quantity * pos.entryPrice / float64(pos.leverage)
+	}
+	available := p.wallet + unrealized - marginUsed
+	if available < 0 {
+		available = 0
+	}
+	return map[string]interface{}{
+		"totalWalletBalance":    p.wallet,
+		"availableBalance":      available,
+		"totalUnrealizedProfit": unrealized,
+	}, nil
+}
+
+// GetPositions 获取模拟持仓（字段与币安格式一致）
+func (p *PaperTrader) GetPositions() ([]map[string]interface{}, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.refresh(); err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, 0, len(p.positions))
+	for key := range p.positions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]map[string]interface{}, 0, len(keys))
+	for _, key := range keys {
+		pos := p.positions[key]
+		amt := pos.quantity
+		if pos.side == "short" {
+			amt = -amt
+		}
+		result = append(result, map[string]interface{}{
+			"symbol":           pos.symbol,
+			"side":             pos.side,
+			"positionAmt":      amt,
+			"entryPrice":       pos.entryPrice,
+			"markPrice":        pos.markPrice,
+			"unRealizedProfit": pos.unrealizedPnL(),
+			"leverage":         float64(pos.leverage),
+			"liquidationPrice": pos.liquidationPrice(),
+		})
+	}
+	return result, nil
+}
+
+func (p *PaperTrader) open(symbol, side string, quantity float64, leverage int) (map[string]interface{}, error) {
+	if quantity <= 0 {
+		return nil, fmt.Errorf("开仓数量必须大于0")
+	}
+	price, err := p.market.GetMarketPrice(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if leverage <= 0 {
+		leverage = p.leverages[symbol]
+	}
+	if leverage <= 0 {
+		leverage = 1
+	}
+	p.leverages[symbol] = leverage
+
+	// 与真实交易所一致：开仓前清理该币种的旧委托单
+	p.removeOrdersLocked(symbol, "")
+
+	key := paperPositionKey(symbol, side)
+	pos, exists := p.positions[key]
+	if !exists {
+		pos = &paperPosition{symbol: symbol, side: side, leverage: leverage}
+		p.positions[key] = pos
+	}
+	total := pos.quantity + quantity
+	pos.entryPrice = (pos.entryPrice*pos.quantity + price*quantity) / total
+	pos.quantity = total
+	pos.leverage = leverage
+	pos.markPrice = price
+	p.wallet -= quantity * price * p.takerFeeRate
+
+	return map[string]interface{}{
+		"orderId":  p.newOrderID(),
+		"symbol":   symbol,
+		"status":   "FILLED",
+		"avgPrice": price,
+	}, nil
+}
+
+func (p *PaperTrader) close(symbol, side string, quantity float64) (map[string]interface{}, error) {
+	price, err := p.market.GetMarketPrice(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := paperPositionKey(symbol, side)
+	pos, exists := p.positions[key]
+	if !exists || pos.quantity <= 0 {
+		return nil, fmt.Errorf("没有找到 %s 的%s持仓", symbol, map[string]string{"long": "多", "short": "空"}[side])
+	}
+	if quantity <= 0 || quantity > pos.quantity {
+		quantity = pos.quantity
+	}
+	pos.markPrice = price
+	p.closeLocked(pos, quantity, price)
+	if pos.quantity <= 1e-12 {
+		delete(p.positions, key)
+		p.removeOrdersLocked(symbol, "")
+	}
+
+	return map[string]interface{}{
+		"orderId":  p.newOrderID(),
+		"symbol":   symbol,
+		"status":   "FILLED",
+		"avgPrice": price,
+	}, nil
+}
+
+// OpenLong 模拟开多仓（按当前价格成交）
+func (p *PaperTrader) OpenLong(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+	return p.open(symbol, "long", quantity, leverage)
+}
+
+// OpenShort 模拟开空仓（按当前价格成交）
+func (p *PaperTrader) OpenShort(symbol string, quantity float64, leverage int) (map[string]interface{}, error) {
+	return p.open(symbol, "short", quantity, leverage)
+}
+
+// CloseLong 模拟平多仓（quantity=0表示全部平仓）
+func (p *PaperTrader) CloseLong(symbol string, quantity float64) (map[string]interface{}, error) {
+	return p.close(symbol, "long", quantity)
+}
+
+// CloseShort 模拟平空仓（quantity=0表示全部平仓）
+func (p *PaperTrader) CloseShort(symbol string, quantity float64) (map[string]interface{}, error) {
+	return p.close(symbol, "short", quantity)
+}
+
+// SetLeverage 记录杠杆（下次开仓使用）
+func (p *PaperTrader) SetLeverage(symbol string, leverage int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.leverages[symbol] = leverage
+	return nil
+}
+
+// SetMarginMode 模拟账户统一按逐仓估算强平价，忽略仓位模式
+func (p *PaperTrader) SetMarginMode(symbol string, isCrossMargin bool) error {
+	return nil
+}
+
+// GetMarketPrice 获取真实市场价格
+func (p *PaperTrader) GetMarketPrice(symbol string) (float64, error) {
+	return p.market.GetMarketPrice(symbol)
+}
+
+func (p *PaperTrader) addOrder(symbol, positionSide, kind string, quantity, stopPrice float64) error {
+	if stopPrice <= 0 {
+		return fmt.Errorf("触发价格必须大于0")
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.orders = append(p.orders, &paperOrder{
+		id:           p.newOrderID(),
+		symbol:       symbol,
+		positionSide: positionSide,
+		kind:         kind,
+		quantity:     quantity,
+		stopPrice:    stopPrice,
+	})
+	return nil
+}
+
+// SetStopLoss 挂模拟止损单（按标记价格触发，以止损价成交）
+func (p *PaperTrader) SetStopLoss(symbol string, positionSide string, quantity, stopPrice float64) error {
+	return p.addOrder(symbol, positionSide, "stop_loss", quantity, stopPrice)
+}
+
+// SetTakeProfit 挂模拟止盈单（按标记价格触发，以止盈价成交）
+func (p *PaperTrader) SetTakeProfit(symbol string, positionSide string, quantity, takeProfitPrice float64) error {
+	return p.addOrder(symbol, positionSide, "take_profit", quantity, takeProfitPrice)
+}
+
+// CancelStopLossOrders 取消模拟止损单
+func (p *PaperTrader) CancelStopLossOrders(symbol string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.removeOrdersLocked(symbol, "stop_loss")
+	return nil
+}
+
+// CancelTakeProfitOrders 取消模拟止盈单
+func (p *PaperTrader) CancelTakeProfitOrders(symbol string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.removeOrdersLocked(symbol, "take_profit")
+	return nil
+}
+
+// CancelAllOrders 取消该币种的所有模拟挂单
+func (p *PaperTrader) CancelAllOrders(symbol string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.removeOrdersLocked(symbol, "")
+	return nil
+}
+
+// CancelStopOrders 取消该币种的模拟止盈止损单
+func (p *PaperTrader) CancelStopOrders(symbol string) error {
+	return p.CancelAllOrders(symbol)
+}
+
+// FormatQuantity 使用真实交易所的数量精度
+func (p *PaperTrader) FormatQuantity(symbol string, quantity float64) (string, error) {
+	return p.market.FormatQuantity(symbol, quantity)
+}
+
+// GetOpenOrders 获取模拟挂单（格式与真实交易所一致）
+func (p *PaperTrader) GetOpenOrders(symbol string) ([]decision.OpenOrderInfo, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var result []decision.OpenOrderInfo
+	for _, order := range p.orders {
+		if symbol != "" && order.symbol != symbol {
+			continue
+		}
+		orderType := "STOP_MARKET"
+		if order.kind == "take_profit" {
+			orderType = "TAKE_PROFIT_MARKET"
+		}
+		side := "SELL"
+		if order.positionSide == "SHORT" {
+			side = "BUY"
+		}
+		result = append(result, decision.OpenOrderInfo{
+			Symbol:       order.symbol,
+			OrderID:      order.id,
+			Type:         orderType,
+			Side:         side,
+			PositionSide: order.positionSide,
+			Quantity:     order.quantity,
+			StopPrice:    order.stopPrice,
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,105 @@
+package trader
+
+import (
+	"math"
+	"testing"
+)
+
+// priceFeedTrader 可控价格的行情源（其余方法沿用 MockTrader）
+type priceFeedTrader struct {
+	*MockTrader
+	prices map[string]float64
+}
+
+func (p *priceFeedTrader) GetMarketPrice(symbol string) (float64, error) {
+	return p.prices[symbol], nil
+}
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+// TestPaperTrader_OpenCloseAndStops 测试模拟账户的开平仓、手续费与止盈止损撮合
+func TestPaperTrader_OpenCloseAndStops(t *testing.T) {
+	feed := &priceFeedTrader{MockTrader: &MockTrader{}, prices: map[string]float64{"BTCUSDT": 100}}
+	paper := NewPaperTrader(feed, 1000, 0.001)
+
+	order, err := paper.OpenLong("BTCUSDT", 2, 5)
+	if err != nil {
+		t.Fatalf("开多失败: %v", err)
+	}
+	if _, ok := order["orderId"].(int64); !ok {
+		t.Errorf("订单ID应为 int64: %#v", order["orderId"])
+	}
+	if err := paper.SetStopLoss("BTCUSDT", "LONG", 2, 90); err != nil {
+		t.Fatalf("设置止损失败: %v", err)
+	}
+	if err := paper.SetTakeProfit("BTCUSDT", "LONG", 2, 120); err != nil {
+		t.Fatalf("设置止盈失败: %v", err)
+	}
+
+	// 价格上涨：未实现盈亏 2*(110-100)=20，开仓手续费 0.2
+	feed.prices["BTCUSDT"] = 110
+	balance, _ := paper.GetBalance()
+	if !approxEqual(balance["totalWalletBalance"].(float64), 999.8) || !approxEqual(balance["totalUnrealizedProfit"].(float64), 20) {
+		t.Errorf("余额不正确: %#v", balance)
+	}
+	if !approxEqual(balance["availableBalance"].(float64), 999.8+20-40) {
+		t.Errorf("可用余额应扣除保证金: %#v", balance)
+	}
+	orders, _ := paper.GetOpenOrders("BTCUSDT")
+	if len(orders) != 2 {
+		t.Fatalf("应有止盈止损两个挂单: %d", len(orders))
+	}
+
+	// 跌破止损：以止损价成交，止盈单一并撤销
+	feed.prices["BTCUSDT"] = 85
+	positions, _ := paper.GetPositions()
+	if len(positions) != 0 {
+		t.Fatalf("触发止损后应无持仓: %#v", positions)
+	}
+	balance, _ = paper.GetBalance()
+	want := 1000 - 0.2 + 2*(90-100) - 2*90*0.001
+	if !approxEqual(balance["totalWalletBalance"].(float64), want) {
+		t.Errorf("止损后余额 = %v, 期望 %v", balance["totalWalletBalance"], want)
+	}
+	if orders, _ := paper.GetOpenOrders(""); len(orders) != 0 {
+		t.Errorf("平仓后挂单应清空: %d", len(orders))
+	}
+
+	// 空单部分平仓
+	feed.prices["BTCUSDT"] = 100
+	if _, err := paper.OpenShort("BTCUSDT", 4, 10); err != nil {
+		t.Fatalf("开空失败: %v", err)
+	}
+	feed.prices["BTCUSDT"] = 95
+	if _, err := paper.CloseShort("BTCUSDT", 1); err != nil {
+		t.Fatalf("部分平空失败: %v", err)
+	}
+	positions, _ = paper.GetPositions()
+	if len(positions) != 1 || positions[0]["side"] != "short" || !approxEqual(positions[0]["positionAmt"].(float64), -3) {
+		t.Errorf("部分平仓后应剩余3个空单: %#v", positions)
+	}
+	if _, err := paper.CloseLong("BTCUSDT", 0); err == nil {
+		t.Errorf("没有多仓时平多应返回错误")
+	}
+}
+
+// TestPaperTrader_Liquidation 测试模拟账户的强平
+func TestPaperTrader_Liquidation(t *testing.T) {
+	feed := &priceFeedTrader{MockTrader: &MockTrader{}, prices: map[string]float64{"ETHUSDT": 100}}
+	paper := NewPaperTrader(feed, 1000, 0)
+
+	if _, err := paper.OpenLong("ETHUSDT", 10, 10); err != nil {
+		t.Fatalf("开多失败: %v", err)
+	}
+	feed.prices["ETHUSDT"] = 89
+	positions, _ := paper.GetPositions()
+	if len(positions) != 0 {
+		t.Fatalf("跌破强平价后应被强平: %#v", positions)
+	}
+	balance, _ := paper.GetBalance()
+	if !approxEqual(balance["totalWalletBalance"].(float64), 900) {
+		t.Errorf("强平应亏掉全部保证金: %v", balance["totalWalletBalance"])
+	}
+}
@@ -0,0 +1,173 @@
+package trader
+
+import (
+	"fmt"
+	"log"
+	"nofx/config"
+	"nofx/decision"
+	"nofx/logger"
+	"time"
+)
+
+const (
+	maxReflectionsPerCycle = 3   // 每个周期最多复盘的已平仓交易数
+	maxPromptLessonPool    = 200 // 构建提示词时参与相关度筛选的最近复盘经验数
+
+	reflectionLookback = 7 * 24 * time.Hour // 只复盘该时间内平仓的交易（避免首次启用时回溯过久的历史）
+)
+
+// lessonStore 复盘经验持久化所需的数据库方法（由 config.Database 实现）
+type lessonStore interface {
+	TradeLessonExists(traderID, tradeKey string) (bool, error)
+	CreateTradeLesson(l *config.TradeLesson) error
+	GetTradeLessons(userID string, filter config.TradeLessonFilter) ([]*config.TradeLesson, error)
+}
+
+// tradeKey 已平仓交易的唯一标识
+func tradeKey(trade logger.TradeOutcome) string {
+	return fmt.Sprintf("%s_%s_%d_%d", trade.Symbol, trade.Side, trade.OpenTime.UnixMilli(), trade.CloseTime.UnixMilli())
+}
+
+// loadLessons 读取交易员最近的复盘经验（影子交易员沿用对照组的经验）
+func (at *AutoTrader) loadLessons() []decision.TradeLesson {
+	source := at
+	if at.lessonSource != nil {
+		source = at.lessonSource
+	}
+	store, ok := source.database.(lessonStore)
+	if !ok {
+		return nil
+	}
+	records, err := store.GetTradeLessons(source.userID, config.TradeLessonFilter{TraderID: source.id, Limit: maxPromptLessonPool})
+	if err != nil {
+		log.Printf("⚠️  [%s] 读取复盘经验失败: %v", at.name, err)
+		return nil
+	}
+	lessons := make([]decision.TradeLesson, 0, len(records))
+	for _, r := range records {
+		lessons = append(lessons, decision.TradeLesson{
+			ID:        r.ID,
+			Symbol:    r.Symbol,
+			Side:      r.Side,
+			Regime:    r.Regime,
+			Setup:     r.Setup,
+			Lesson:    r.Lesson,
+			Keywords:  r.Keywords,
+			PnLPct:    r.PnLPct,
+			CreatedAt: r.CreatedAt,
+		})
+	}
+	return lessons
+}
+
+// pendingReflections 近期平仓且尚未复盘的交易（包括停机期间平仓的交易，是否已复盘以数据库记录为准；
+// 每笔交易在本次运行中只尝试一次）
+func (at *AutoTrader) pendingReflections(ctx *decision.Context) []logger.TradeOutcome {
+	if at.paperTrading {
+		return nil
+	}
+	store, ok := at.database.(lessonStore)
+	if !ok {
+		return nil
+	}
+	perf, ok := ctx.Performance.(*logger.PerformanceAnalysis)
+	if !ok || perf == nil {
+		return nil
+	}
+	if at.reflectedTrades == nil {
+		at.reflectedTrades = make(map[string]bool)
+	}
+
+	cutoff := time.Now().Add(-reflectionLookback)
+	var pending []logger.TradeOutcome
+	for _, trade := range perf.RecentTrades {
+		key := tradeKey(trade)
+		if at.reflectedTrades[key] || trade.CloseTime.Before(cutoff) {
+			continue
+		}
+		if exists, err := store.TradeLessonExists(at.id, key); err != nil || exists {
+			if exists {
+				at.reflectedTrades[key] = true
+			}
+			continue
+		}
+		at.reflectedTrades[key] = true
+		pending = append(pending, trade)
+		if len(pending) >= maxReflectionsPerCycle {
+			break
+		}
+	}
+	return pending
+}
+
+// reflectOnClosedTrades 在后台复盘新平仓的交易（不阻塞决策周期，上一轮复盘未结束时跳过）
+func (at *AutoTrader) reflectOnClosedTrades(ctx *decision.Context) {
+	if _, ok := at.database.(lessonStore); !ok {
+		return
+	}
+	if !at.reflecting.CompareAndSwap(false, true) {
+		return
+	}
+	trades := at.pendingReflections(ctx)
+	if len(trades) == 0 {
+		at.reflecting.Store(false)
+		return
+	}
+	currentRegime := decision.MarketRegime(ctx.MarketSummary)
+	go func() {
+		defer at.reflecting.Store(false)
+		at.reflectOnTrades(trades, currentRegime)
+	}()
+}
+
+// tradeRegime 交易开仓时的市场状态（旧记录缺少开仓快照时使用当前市场状态）
+func tradeRegime(trade logger.TradeOutcome, currentRegime string) string {
+	if trade.Entry != nil && trade.Entry.Regime != "" {
+		return trade.Entry.Regime
+	}
+	return currentRegime
+}
+
+// reflectOnTrades 请AI逐笔复盘并保存经验，返回保存成功的条数
+// 经验按开仓时的市场状态标记，currentRegime 仅在交易缺少开仓快照时使用
+func (at *AutoTrader) reflectOnTrades(trades []logger.TradeOutcome, currentRegime string) int {
+	store, ok := at.database.(lessonStore)
+	if !ok {
+		return 0
+	}
+
+	saved := 0
+	for _, trade := range trades {
+		key := tradeKey(trade)
+		if exists, err := store.TradeLessonExists(at.id, key); err != nil || exists {
+			continue
+		}
+		regime := tradeRegime(trade, currentRegime)
+		reflection, err := decision.ReflectOnTrade(at.mcpClient, trade, regime)
+		if err != nil {
+			log.Printf("⚠️  [%s] 复盘 %s %s 失败: %v", at.name, trade.Symbol, trade.Side, err)
+			continue
+		}
+		lesson := &config.TradeLesson{
+			UserID:   at.userID,
+			TraderID: at.id,
+			TradeKey: key,
+			Symbol:   trade.Symbol,
+			Side:     trade.Side,
+			Regime:   regime,
+			Setup:    reflection.Setup,
+			Lesson:   reflection.Lesson,
+			Keywords: reflection.Keywords,
+			PnL:      trade.PnL,
+			PnLPct:   trade.PnLPct,
+			ClosedAt: trade.CloseTime,
+		}
+		if err := store.CreateTradeLesson(lesson); err != nil {
+			log.Printf("⚠️  [%s] 保存复盘经验失败: %v", at.name, err)
+			continue
+		}
+		saved++
+		log.Printf("🧠 [%s] 复盘 %s %s (%+.2f%%): %s", at.name, trade.Symbol, trade.Side, trade.PnLPct, reflection.Lesson)
+	}
+	return saved
+}
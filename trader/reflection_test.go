@@ -0,0 +1,146 @@
+package trader
+
+import (
+	"fmt"
+	"nofx/config"
+	"nofx/decision"
+	"nofx/logger"
+	"nofx/mcp"
+	"testing"
+	"time"
+)
+
+// reflectionAIClient 返回固定的复盘回复
+type reflectionAIClient struct {
+	mcp.AIClient
+	calls int
+}
+
+func (c *reflectionAIClient) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	c.calls++
+	return `{"setup": "突破追高", "lesson": "高波动时等回踩确认", "keywords": ["突破", "追高"]}`, nil
+}
+
+// memoryLessonStore 内存中的复盘经验存储
+type memoryLessonStore struct {
+	lessons []*config.TradeLesson
+}
+
+func (s *memoryLessonStore) TradeLessonExists(traderID, tradeKey string) (bool, error) {
+	for _, l := range s.lessons {
+		if l.TraderID == traderID && l.TradeKey == tradeKey {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (s *memoryLessonStore) CreateTradeLesson(l *config.TradeLesson) error {
+	if exists, _ := s.TradeLessonExists(l.TraderID, l.TradeKey); exists {
+		return fmt.Errorf("该交易已复盘: %s", l.TradeKey)
+	}
+	l.ID = int64(len(s.lessons) + 1)
+	l.CreatedAt = time.Now()
+	s.lessons = append(s.lessons, l)
+	return nil
+}
+
+func (s *memoryLessonStore) GetTradeLessons(userID string, filter config.TradeLessonFilter) ([]*config.TradeLesson, error) {
+	var result []*config.TradeLesson
+	for _, l := range s.lessons {
+		if l.UserID == userID && (filter.TraderID == "" || l.TraderID == filter.TraderID) {
+			result = append(result, l)
+		}
+	}
+	return result, nil
+}
+
+// TestReflection_ClosedTradesBecomeLessons 测试近期平仓的交易被复盘一次并写入下一周期的上下文
+func TestReflection_ClosedTradesBecomeLessons(t *testing.T) {
+	store := &memoryLessonStore{}
+	client := &reflectionAIClient{}
+	start := time.Now().Add(-time.Hour)
+	at := &AutoTrader{id: "t1", name: "Trader", userID: "user-a", database: store, mcpClient: client, startTime: start}
+
+	stale := logger.TradeOutcome{Symbol: "ETHUSDT", Side: "short", CloseTime: time.Now().Add(-reflectionLookback - time.Hour)}
+	closed := logger.TradeOutcome{Symbol: "BTCUSDT", Side: "long", PnL: -5, PnLPct: -3.2, OpenTime: start.Add(time.Minute), CloseTime: start.Add(30 * time.Minute)}
+	ctx := &decision.Context{
+		Performance:   &logger.PerformanceAnalysis{RecentTrades: []logger.TradeOutcome{closed, stale}},
+		MarketSummary: &decision.MarketSummary{TrendLabel: "bullish", VolatilityLabel: "high"},
+	}
+
+	pending := at.pendingReflections(ctx)
+	if len(pending) != 1 || pending[0].Symbol != "BTCUSDT" {
+		t.Fatalf("只应复盘回溯窗口内平仓的交易: %+v", pending)
+	}
+	if again := at.pendingReflections(ctx); len(again) != 0 {
+		t.Errorf("同一笔交易在本次运行中只应尝试一次: %+v", again)
+	}
+
+	if saved := at.reflectOnTrades(pending, decision.MarketRegime(ctx.MarketSummary)); saved != 1 {
+		t.Fatalf("应保存1条复盘经验，实际 %d", saved)
+	}
+	if saved := at.reflectOnTrades(pending, "bullish/high"); saved != 0 || client.calls != 1 {
+		t.Errorf("已复盘的交易不应再次调用AI: saved=%d calls=%d", saved, client.calls)
+	}
+
+	lesson := store.lessons[0]
+	if lesson.Regime != "bullish/high" || lesson.Setup != "突破追高" || lesson.TradeKey != tradeKey(closed) || lesson.PnLPct != -3.2 {
+		t.Errorf("复盘经验内容不正确: %+v", lesson)
+	}
+
+	// 影子交易员不复盘，但沿用对照组的经验
+	shadow := &AutoTrader{id: "t1-shadow", name: "Shadow", userID: "user-a", paperTrading: true, lessonSource: at, startTime: start}
+	if pending := shadow.pendingReflections(ctx); len(pending) != 0 {
+		t.Errorf("影子交易员不应复盘: %+v", pending)
+	}
+	lessons := shadow.loadLessons()
+	if len(lessons) != 1 || lessons[0].Lesson != "高波动时等回踩确认" || len(lessons[0].Keywords) != 2 {
+		t.Errorf("影子交易员应读取对照组的复盘经验: %+v", lessons)
+	}
+}
+
+// TestReflection_TradesClosedWhileStopped 测试停机期间平仓的交易在重启后仍会复盘，已复盘的交易不占用名额
+func TestReflection_TradesClosedWhileStopped(t *testing.T) {
+	store := &memoryLessonStore{}
+	start := time.Now()
+	at := &AutoTrader{id: "t1", name: "Trader", userID: "user-a", database: store, mcpClient: &reflectionAIClient{}, startTime: start}
+
+	var trades []logger.TradeOutcome
+	for i := 0; i < maxReflectionsPerCycle+1; i++ {
+		closeTime := start.Add(-time.Duration(i+1) * time.Hour)
+		trades = append(trades, logger.TradeOutcome{Symbol: "BTCUSDT", Side: "long", OpenTime: closeTime.Add(-time.Hour), CloseTime: closeTime})
+	}
+	for _, trade := range trades[:maxReflectionsPerCycle] {
+		if err := store.CreateTradeLesson(&config.TradeLesson{UserID: "user-a", TraderID: "t1", TradeKey: tradeKey(trade)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx := &decision.Context{Performance: &logger.PerformanceAnalysis{RecentTrades: trades}}
+
+	pending := at.pendingReflections(ctx)
+	if len(pending) != 1 || tradeKey(pending[0]) != tradeKey(trades[maxReflectionsPerCycle]) {
+		t.Errorf("应跳过已复盘的交易并复盘停机期间平仓的交易: %+v", pending)
+	}
+}
+
+// TestReflection_UsesEntryRegime 测试经验按开仓时的市场状态标记，缺少开仓快照时使用当前市场状态
+func TestReflection_UsesEntryRegime(t *testing.T) {
+	store := &memoryLessonStore{}
+	at := &AutoTrader{id: "t1", name: "Trader", userID: "user-a", database: store, mcpClient: &reflectionAIClient{}}
+
+	now := time.Now()
+	withEntry := logger.TradeOutcome{Symbol: "BTCUSDT", Side: "long", OpenTime: now.Add(-48 * time.Hour), CloseTime: now.Add(-47 * time.Hour),
+		Entry: &logger.EntrySnapshot{Regime: "bearish/low"}}
+	legacy := logger.TradeOutcome{Symbol: "ETHUSDT", Side: "short", OpenTime: now.Add(-2 * time.Hour), CloseTime: now.Add(-time.Hour)}
+
+	if saved := at.reflectOnTrades([]logger.TradeOutcome{withEntry, legacy}, "bullish/high"); saved != 2 {
+		t.Fatalf("应保存2条复盘经验，实际 %d", saved)
+	}
+	if store.lessons[0].Regime != "bearish/low" {
+		t.Errorf("应使用开仓时的市场状态: %s", store.lessons[0].Regime)
+	}
+	if store.lessons[1].Regime != "bullish/high" {
+		t.Errorf("缺少开仓快照时应使用当前市场状态: %s", store.lessons[1].Regime)
+	}
+}
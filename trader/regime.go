@@ -0,0 +1,71 @@
+package trader
+
+import (
+	"fmt"
+	"nofx/decision"
+)
+
+// regimeLabel 市场状态中文名称（runCycle 中 decision 变量会遮蔽包名）
+func regimeLabel(regime string) string {
+	return decision.RegimeLabelCN(regime)
+}
+
+// GetRegimeState 当前市场状态（识别器未启用时返回 nil）
+func (at *AutoTrader) GetRegimeState() *decision.RegimeState {
+	if at.regimeDetector == nil {
+		return nil
+	}
+	state := at.regimeDetector.Current()
+	return &state
+}
+
+// GetRegimeTransitions 最近的市场状态切换记录
+func (at *AutoTrader) GetRegimeTransitions() []decision.RegimeTransition {
+	if at.regimeDetector == nil {
+		return []decision.RegimeTransition{}
+	}
+	return at.regimeDetector.Transitions()
+}
+
+// GetRegimePolicies 交易员配置的市场状态策略
+func (at *AutoTrader) GetRegimePolicies() map[string]decision.RegimePolicy {
+	return at.config.RegimePolicies
+}
+
+// checkRegimePolicy 按本周期市场状态策略校验决策：禁止新开仓时否决开仓，超过杠杆上限时下调杠杆
+// 返回是否允许执行以及说明（允许但有调整时说明非空）
+func (at *AutoTrader) checkRegimePolicy(ctx *decision.Context, d *decision.Decision) (bool, string) {
+	if ctx == nil || ctx.Regime == nil || ctx.RegimePolicy == nil {
+		return true, ""
+	}
+	return applyRegimePolicy(ctx.Regime.Regime, *ctx.RegimePolicy, d)
+}
+
+// checkCurrentRegimePolicy 按识别器当前状态校验决策（审批通过时执行，状态可能已在提案后切换）
+func (at *AutoTrader) checkCurrentRegimePolicy(d *decision.Decision) (bool, string) {
+	state := at.GetRegimeState()
+	if state == nil {
+		return true, ""
+	}
+	policy, ok := at.config.RegimePolicies[state.Regime]
+	if !ok {
+		return true, ""
+	}
+	return applyRegimePolicy(state.Regime, policy, d)
+}
+
+// applyRegimePolicy 对开仓决策应用市场状态策略（平仓、止损止盈调整不受影响）
+func applyRegimePolicy(regime string, policy decision.RegimePolicy, d *decision.Decision) (bool, string) {
+	if d.Action != "open_long" && d.Action != "open_short" {
+		return true, ""
+	}
+	if policy.NoNewEntries {
+		return false, fmt.Sprintf("%s状态禁止新开仓", decision.RegimeLabelCN(regime))
+	}
+	if policy.MaxLeverage > 0 && d.Leverage > policy.MaxLeverage {
+		note := fmt.Sprintf("%s状态杠杆上限 %dx，杠杆 %dx→%dx", decision.RegimeLabelCN(regime), policy.MaxLeverage, d.Leverage, policy.MaxLeverage)
+		d.Leverage = policy.MaxLeverage
+		return true, note
+	}
+	return true, ""
+}
@@ -0,0 +1,59 @@
+package trader
+
+import (
+	"nofx/decision"
+	"nofx/market"
+	"testing"
+	"time"
+)
+
+// TestCheckRegimePolicy 测试市场状态策略否决新开仓与下调杠杆，平仓不受影响
+func TestCheckRegimePolicy(t *testing.T) {
+	at := &AutoTrader{id: "t1", name: "Trader"}
+	ctx := &decision.Context{
+		Regime:       &decision.RegimeState{Regime: decision.RegimeRiskOff},
+		RegimePolicy: &decision.RegimePolicy{NoNewEntries: true},
+	}
+
+	if allowed, note := at.checkRegimePolicy(ctx, &decision.Decision{Action: "open_long", Symbol: "BTCUSDT", Leverage: 5}); allowed || note == "" {
+		t.Errorf("禁止新开仓时应否决开仓: %v %s", allowed, note)
+	}
+	if allowed, _ := at.checkRegimePolicy(ctx, &decision.Decision{Action: "close_long", Symbol: "BTCUSDT"}); !allowed {
+		t.Error("平仓不应受市场状态策略限制")
+	}
+
+	ctx.Regime.Regime = decision.RegimeHighVolatility
+	ctx.RegimePolicy = &decision.RegimePolicy{MaxLeverage: 3}
+	d := &decision.Decision{Action: "open_short", Symbol: "ETHUSDT", Leverage: 10}
+	if allowed, note := at.checkRegimePolicy(ctx, d); !allowed || note == "" || d.Leverage != 3 {
+		t.Errorf("超过杠杆上限应下调到 3x: %v %s %d", allowed, note, d.Leverage)
+	}
+
+	if allowed, note := at.checkRegimePolicy(&decision.Context{}, &decision.Decision{Action: "open_long", Leverage: 20}); !allowed || note != "" {
+		t.Errorf("未配置策略时不应干预: %v %s", allowed, note)
+	}
+}
+
+// TestCheckCurrentRegimePolicy 测试审批执行时按识别器当前状态校验（尚未识别出状态时不干预）
+func TestCheckCurrentRegimePolicy(t *testing.T) {
+	at := &AutoTrader{
+		id:             "t1",
+		name:           "Trader",
+		config:         AutoTraderConfig{RegimePolicies: map[string]decision.RegimePolicy{decision.RegimeRiskOff: {NoNewEntries: true}}},
+		regimeDetector: decision.NewRegimeDetector(0),
+	}
+	open := &decision.Decision{Action: "open_long", Symbol: "BTCUSDT", Leverage: 5}
+	if allowed, _ := at.checkCurrentRegimePolicy(open); !allowed {
+		t.Error("尚未识别出市场状态时不应干预")
+	}
+
+	at.regimeDetector.Observe(&decision.Context{MarketDataMap: map[string]*market.Data{
+		"BTCUSDT": {Symbol: "BTCUSDT", CurrentPrice: 100, PriceChange1h: -2.5, PriceChange4h: -3},
+	}}, time.Now())
+	if allowed, note := at.checkCurrentRegimePolicy(open); allowed || note == "" {
+		t.Errorf("避险状态应否决审批中的开仓: %v %s", allowed, note)
+	}
+	if state := at.GetRegimeState(); state == nil || state.Regime != decision.RegimeRiskOff || len(at.GetRegimeTransitions()) != 1 {
+		t.Errorf("市场状态查询不正确: %+v", state)
+	}
+}
@@ -0,0 +1,108 @@
+package trader
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"nofx/decision"
+	"nofx/logger"
+	"time"
+)
+
+// 仓位计算模式
+const (
+	PositionSizingAI    = "ai"    // 使用AI给出的仓位
+	PositionSizingKelly = "kelly" // 按信心度校准后的分数Kelly计算仓位
+
+	DefaultKellyFraction = 0.25 // 默认使用 1/4 Kelly
+	MaxKellyFraction     = 1.0
+)
+
+const (
+	minCalibrationTrades = 20               // 信心度区间至少需要的已平仓交易数，样本不足时沿用AI仓位
+	calibrationCacheTTL  = 10 * time.Minute // 校准结果缓存时间
+)
+
+// IsValidPositionSizingMode 检查仓位计算模式是否有效（空值视为 ai）
+func IsValidPositionSizingMode(mode string) bool {
+	return mode == "" || mode == PositionSizingAI || mode == PositionSizingKelly
+}
+
+// kellyFraction 分数Kelly系数（未配置时使用默认值）
+func (at *AutoTrader) kellyFraction() float64 {
+	if at.config.KellyFraction > 0 {
+		return math.Min(at.config.KellyFraction, MaxKellyFraction)
+	}
+	return DefaultKellyFraction
+}
+
+// getCalibration 读取信心度校准结果（带缓存，避免每次开仓都重新分析全部决策记录）
+func (at *AutoTrader) getCalibration() *logger.CalibrationReport {
+	at.calibrationMutex.Lock()
+	defer at.calibrationMutex.Unlock()
+
+	if at.calibration != nil && time.Since(at.calibration.GeneratedAt) < calibrationCacheTTL {
+		return at.calibration
+	}
+	if at.decisionLogger == nil {
+		return nil
+	}
+	report, err := logger.AnalyzeCalibration(at.id, at.decisionLogger)
+	if err != nil {
+		log.Printf("⚠️  [%s] 信心度校准失败: %v", at.name, err)
+		return at.calibration
+	}
+	at.calibration = report
+	return report
+}
+
+// applyKellySizing Kelly 模式下按信心度区间的历史胜率与盈亏比重新计算开仓仓位
+// 保证金 = 可用余额 × Kelly比例 × 分数系数，仓位价值不超过单币种上限（BTC/ETH 10倍、山寨币 5倍）。
+// 需在市场状态策略、风控与审批判断之前调用，使盘口深度、资金费率与审批阈值都基于最终仓位；
+// 按 Kelly 计算后标记 SizeLocked，执行时不再按信心度放大。
+// 返回调整说明；区间无正期望时拒绝开仓。
+func (at *AutoTrader) applyKellySizing(d *decision.Decision, bankroll float64) (string, error) {
+	if at.config.PositionSizingMode != PositionSizingKelly || (d.Action != "open_long" && d.Action != "open_short") {
+		return "", nil
+	}
+	if d.Confidence <= 0 {
+		return "Kelly模式：决策未给出信心度，沿用AI仓位", nil
+	}
+
+	report := at.getCalibration()
+	if report == nil {
+		return "Kelly模式：暂无校准数据，沿用AI仓位", nil
+	}
+	bucket := report.Bucket(d.Confidence)
+	if bucket.Trades < minCalibrationTrades {
+		return fmt.Sprintf("Kelly模式：信心度 %s 区间样本不足（%d/%d 笔），沿用AI仓位", bucket.Range, bucket.Trades, minCalibrationTrades), nil
+	}
+	if bucket.Losses == 0 {
+		return fmt.Sprintf("Kelly模式：信心度 %s 区间没有亏损交易（%d 笔），盈亏比无法估计，沿用AI仓位", bucket.Range, bucket.Trades), nil
+	}
+	if bucket.Kelly <= 0 {
+		return "", fmt.Errorf("信心度 %s 区间历史无正期望（胜率 %.1f%%，盈亏比 %.2f，%d 笔），Kelly仓位为0，放弃开仓",
+			bucket.Range, bucket.WinRate, bucket.PayoffRatio, bucket.Trades)
+	}
+
+	if bankroll <= 0 {
+		return "", fmt.Errorf("可用余额 %.2f USDT 无法按Kelly计算仓位", bankroll)
+	}
+	if d.Leverage <= 0 {
+		d.Leverage = at.defaultLeverageForSymbol(d.Symbol)
+	}
+
+	fraction := at.kellyFraction()
+	size := bankroll * bucket.Kelly * fraction * float64(d.Leverage)
+	capMultiple := 5.0
+	if d.Symbol == "BTCUSDT" || d.Symbol == "ETHUSDT" {
+		capMultiple = 10.0
+	}
+	size = math.Floor(math.Min(size, bankroll*capMultiple)*100+1e-6) / 100 // 容差避免浮点误差少算一分
+
+	original := d.PositionSizeUSD
+	d.PositionSizeUSD = size
+	d.SizeLocked = true
+	return fmt.Sprintf("Kelly仓位 %.2f→%.2f USDT（信心度 %s 区间胜率 %.1f%%、盈亏比 %.2f，%.2f×Kelly=%.1f%% 保证金）",
+		original, size, bucket.Range, bucket.WinRate, bucket.PayoffRatio, fraction, bucket.Kelly*fraction*100), nil
+}
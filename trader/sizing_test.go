@@ -0,0 +1,100 @@
+package trader
+
+import (
+	"nofx/decision"
+	"nofx/logger"
+	"strings"
+	"testing"
+)
+
+// calibrationTrades 生成指定信心度的历史交易（wins 笔盈利 winPct%，losses 笔亏损 lossPct%）
+func calibrationTrades(confidence, wins, losses int, winPct, lossPct float64) []logger.TradeOutcome {
+	var trades []logger.TradeOutcome
+	for i := 0; i < wins; i++ {
+		trades = append(trades, logger.TradeOutcome{Confidence: confidence, PnL: winPct, PnLPct: winPct})
+	}
+	for i := 0; i < losses; i++ {
+		trades = append(trades, logger.TradeOutcome{Confidence: confidence, PnL: -lossPct, PnLPct: -lossPct})
+	}
+	return trades
+}
+
+// TestApplyKellySizing 测试 Kelly 模式按信心度区间的校准结果计算仓位
+func TestApplyKellySizing(t *testing.T) {
+	var trades []logger.TradeOutcome
+	trades = append(trades, calibrationTrades(85, 12, 8, 10, 5)...) // 胜率60%、盈亏比2 → Kelly 0.4
+	trades = append(trades, calibrationTrades(65, 5, 15, 5, 5)...)  // 胜率25%、盈亏比1 → 无正期望
+	trades = append(trades, calibrationTrades(95, 3, 1, 5, 5)...)   // 样本不足
+	trades = append(trades, calibrationTrades(75, 25, 0, 5, 0)...)  // 没有亏损交易
+
+	at := &AutoTrader{
+		id:          "t1",
+		name:        "Trader",
+		config:      AutoTraderConfig{PositionSizingMode: PositionSizingKelly, AltcoinLeverage: 5},
+		calibration: logger.BuildCalibrationReport("t1", trades),
+	}
+
+	d := &decision.Decision{Action: "open_long", Symbol: "SOLUSDT", Leverage: 5, PositionSizeUSD: 3000, Confidence: 88}
+	msg, err := at.applyKellySizing(d, 1000)
+	if err != nil {
+		t.Fatalf("不应报错: %v", err)
+	}
+	// 保证金 = 1000 × 0.4 × 0.25 = 100，仓位 = 100 × 5倍
+	if d.PositionSizeUSD != 500 || !d.SizeLocked || !strings.Contains(msg, "Kelly仓位") {
+		t.Errorf("Kelly仓位应为500 USDT且锁定，实际 %.2f locked=%v (%s)", d.PositionSizeUSD, d.SizeLocked, msg)
+	}
+	// 已按 Kelly 计算的仓位执行时不再按高信心度放大
+	if _, err := at.normalizePositionSize(d, 1000); err != nil || d.PositionSizeUSD != 500 {
+		t.Errorf("Kelly仓位执行时不应被放大: size=%.2f err=%v", d.PositionSizeUSD, err)
+	}
+
+	d = &decision.Decision{Action: "open_long", Symbol: "SOLUSDT", Leverage: 5, PositionSizeUSD: 3000, Confidence: 62}
+	if _, err := at.applyKellySizing(d, 1000); err == nil || !strings.Contains(err.Error(), "无正期望") {
+		t.Errorf("无正期望的区间应拒绝开仓: %v", err)
+	}
+
+	d = &decision.Decision{Action: "open_long", Symbol: "SOLUSDT", Leverage: 5, PositionSizeUSD: 300, Confidence: 95}
+	msg, err = at.applyKellySizing(d, 1000)
+	if err != nil || d.PositionSizeUSD != 300 || d.SizeLocked || !strings.Contains(msg, "样本不足") {
+		t.Errorf("样本不足时应沿用AI仓位: size=%.2f msg=%s err=%v", d.PositionSizeUSD, msg, err)
+	}
+	// 沿用AI仓位时保留原有的高信心度放大（300 × 1.2）
+	if _, err := at.normalizePositionSize(d, 1000); err != nil || d.PositionSizeUSD != 360 {
+		t.Errorf("沿用AI仓位时应保留高信心度放大: size=%.2f err=%v", d.PositionSizeUSD, err)
+	}
+
+	// 全部盈利的区间无法估计盈亏比，沿用AI仓位
+	d = &decision.Decision{Action: "open_long", Symbol: "SOLUSDT", Leverage: 5, PositionSizeUSD: 300, Confidence: 75}
+	msg, err = at.applyKellySizing(d, 1000)
+	if err != nil || d.PositionSizeUSD != 300 || d.SizeLocked || !strings.Contains(msg, "没有亏损交易") {
+		t.Errorf("没有亏损交易时应沿用AI仓位: size=%.2f msg=%s err=%v", d.PositionSizeUSD, msg, err)
+	}
+
+	// 平仓不计算 Kelly 仓位
+	d = &decision.Decision{Action: "close_long", Symbol: "SOLUSDT", Confidence: 62}
+	if msg, err := at.applyKellySizing(d, 1000); err != nil || msg != "" {
+		t.Errorf("平仓不应计算Kelly仓位: msg=%s err=%v", msg, err)
+	}
+
+	// ai 模式不受校准结果影响
+	at.config.PositionSizingMode = PositionSizingAI
+	d = &decision.Decision{Action: "open_long", Symbol: "SOLUSDT", Leverage: 5, PositionSizeUSD: 3000, Confidence: 62}
+	if msg, err := at.applyKellySizing(d, 1000); err != nil || msg != "" || d.PositionSizeUSD != 3000 {
+		t.Errorf("ai 模式应使用AI仓位: size=%.2f err=%v", d.PositionSizeUSD, err)
+	}
+}
+
+// TestNormalizePositionSize_SizeLocked 测试已锁定的仓位（已审批提案）执行时不再放大，但仍受保证金约束
+func TestNormalizePositionSize_SizeLocked(t *testing.T) {
+	at := &AutoTrader{config: AutoTraderConfig{AltcoinLeverage: 5}}
+
+	d := &decision.Decision{Action: "open_long", Symbol: "SOLUSDT", Leverage: 5, PositionSizeUSD: 300, Confidence: 95, SizeLocked: true}
+	if _, err := at.normalizePositionSize(d, 1000); err != nil || d.PositionSizeUSD != 300 {
+		t.Errorf("锁定的仓位不应被放大: size=%.2f err=%v", d.PositionSizeUSD, err)
+	}
+
+	d = &decision.Decision{Action: "open_long", Symbol: "SOLUSDT", Leverage: 5, PositionSizeUSD: 30000, Confidence: 95, SizeLocked: true}
+	if _, err := at.normalizePositionSize(d, 1000); err != nil || d.PositionSizeUSD >= 30000 {
+		t.Errorf("锁定的仓位仍应受保证金限制: size=%.2f err=%v", d.PositionSizeUSD, err)
+	}
+}
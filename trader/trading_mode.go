@@ -0,0 +1,167 @@
+package trader
+
+import (
+	"fmt"
+	"log"
+	"nofx/decision"
+	"nofx/logger"
+	"strings"
+)
+
+// 交易员运行模式
+const (
+	TradingModeNormal     = "normal"      // 正常：AI 可开仓/平仓
+	TradingModePaused     = "paused"      // 暂停：跳过AI决策周期，保留回撤监控与已有止损止盈单
+	TradingModeReduceOnly = "reduce_only" // 只减仓：AI 只能平仓、部分平仓或收紧止损
+	TradingModeCloseOut   = "close_out"   // 清仓退出：平掉所有持仓后停止交易员
+)
+
+// tradingModeStore 运行模式持久化所需的数据库方法（由 config.Database 实现）
+type tradingModeStore interface {
+	UpdateTraderStatus(userID, id string, isRunning bool) error
+	UpdateTraderTradingMode(userID, id, mode string) error
+}
+
+// IsValidTradingMode 判断运行模式是否合法
+func IsValidTradingMode(mode string) bool {
+	switch mode {
+	case TradingModeNormal, TradingModePaused, TradingModeReduceOnly, TradingModeCloseOut:
+		return true
+	}
+	return false
+}
+
+// GetTradingMode 获取当前运行模式
+func (at *AutoTrader) GetTradingMode() string {
+	at.tradingModeMutex.RLock()
+	defer at.tradingModeMutex.RUnlock()
+	if at.tradingMode == "" {
+		return TradingModeNormal
+	}
+	return at.tradingMode
+}
+
+// SetTradingMode 设置运行模式并持久化到数据库
+func (at *AutoTrader) SetTradingMode(mode string) error {
+	if mode == "" {
+		mode = TradingModeNormal
+	}
+	if !IsValidTradingMode(mode) {
+		return fmt.Errorf("不支持的运行模式: %s", mode)
+	}
+
+	at.tradingModeMutex.Lock()
+	previous := at.tradingMode
+	at.tradingMode = mode
+	at.tradingModeMutex.Unlock()
+
+	if previous != mode {
+		log.Printf("🔀 [%s] 运行模式切换: %s → %s", at.name, orDefault(previous, TradingModeNormal), mode)
+	}
+
+	if store, ok := at.database.(tradingModeStore); ok {
+		if err := store.UpdateTraderTradingMode(at.userID, at.id, mode); err != nil {
+			return fmt.Errorf("保存运行模式失败: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkTradingMode 按运行模式校验AI决策（只减仓模式下拒绝开仓、放宽止损和调整止盈）
+func (at *AutoTrader) checkTradingMode(d *decision.Decision) (bool, string) {
+	mode := at.GetTradingMode()
+	if mode != TradingModeReduceOnly && mode != TradingModeCloseOut {
+		return true, ""
+	}
+
+	switch d.Action {
+	case "close_long", "close_short", "partial_close", "hold", "wait":
+		return true, ""
+	case "update_stop_loss":
+		if loosened, note := at.isStopLossLoosened(d.Symbol, d.NewStopLoss); loosened {
+			return false, note
+		}
+		return true, ""
+	default:
+		return false, fmt.Sprintf("只减仓模式下禁止执行 %s", d.Action)
+	}
+}
+
+// isStopLossLoosened 判断新止损是否比当前止损更宽松（调用方需持有 executionMutex）
+// 当前止损优先取本地记录，其次取交易所挂着的止损单；重启后或外部开仓的持仓没有本地记录，
+// 当前止损无法确认时一律视为放宽，避免只减仓模式下把止损挪远
+func (at *AutoTrader) isStopLossLoosened(symbol string, newStopLoss float64) (bool, string) {
+	side, err := at.resolvePositionSide(symbol, "")
+	if err != nil {
+		return true, fmt.Sprintf("无法确认当前止损: %v", err)
+	}
+
+	current := at.positionStopLoss[symbol+"_"+side]
+	if current <= 0 {
+		current = at.exchangeStopLoss(symbol, side)
+	}
+	if current <= 0 {
+		return true, fmt.Sprintf("%s %s 当前止损未知，只减仓模式下不允许调整止损", symbol, side)
+	}
+
+	if (side == "long" && newStopLoss < current) || (side == "short" && newStopLoss > current) {
+		return true, fmt.Sprintf("只减仓模式下只允许收紧止损（当前: %.4f, 新: %.4f）", current, newStopLoss)
+	}
+	return false, ""
+}
+
+// exchangeStopLoss 从交易所挂单中查找该持仓的止损触发价（未找到返回 0）
+func (at *AutoTrader) exchangeStopLoss(symbol, side string) float64 {
+	orders, err := at.trader.GetOpenOrders(symbol)
+	if err != nil {
+		log.Printf("⚠ [%s] 获取 %s 挂单失败: %v", at.name, symbol, err)
+		return 0
+	}
+
+	closingSide := "SELL"
+	if side == "short" {
+		closingSide = "BUY"
+	}
+	for _, order := range orders {
+		if order.Symbol != symbol || (order.Type != "STOP_MARKET" && order.Type != "STOP") || order.StopPrice <= 0 {
+			continue
+		}
+		if strings.EqualFold(order.PositionSide, side) ||
+			((order.PositionSide == "" || order.PositionSide == "BOTH") && order.Side == closingSide) {
+			return order.StopPrice
+		}
+	}
+	return 0
+}
+
+// CloseOut 平掉所有持仓，成功后恢复为正常模式并标记为已停止（调用方负责停止主循环）
+func (at *AutoTrader) CloseOut() ([]logger.DecisionAction, error) {
+	if err := at.SetTradingMode(TradingModeCloseOut); err != nil {
+		return nil, err
+	}
+
+	actions, err := at.ManualCloseAllPositions("close-out: 清仓退出")
+	if err != nil {
+		// 保持 close_out 模式，下一周期继续尝试清仓
+		return actions, err
+	}
+
+	if err := at.SetTradingMode(TradingModeNormal); err != nil {
+		log.Printf("⚠ [%s] %v", at.name, err)
+	}
+	if store, ok := at.database.(tradingModeStore); ok {
+		if err := store.UpdateTraderStatus(at.userID, at.id, false); err != nil {
+			log.Printf("⚠ [%s] 更新交易员状态失败: %v", at.name, err)
+		}
+	}
+	log.Printf("🏁 [%s] 清仓完成（%d 个持仓），交易员将停止", at.name, len(actions))
+	return actions, nil
+}
+
+// orDefault 空字符串时返回默认值
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,152 @@
+package trader
+
+import (
+	"nofx/decision"
+	"nofx/events"
+	"nofx/logger"
+	"nofx/market"
+	"time"
+)
+
+// fakeTradingModeStore 记录运行模式持久化调用
+type fakeTradingModeStore struct {
+	MockDatabase
+	modes   []string
+	running *bool
+}
+
+func (f *fakeTradingModeStore) UpdateTraderTradingMode(userID, id, mode string) error {
+	f.modes = append(f.modes, mode)
+	return nil
+}
+
+func (f *fakeTradingModeStore) UpdateTraderStatus(userID, id string, isRunning bool) error {
+	f.running = &isRunning
+	return nil
+}
+
+// TestCheckTradingMode 测试各运行模式下的决策校验
+func (s *AutoTraderTestSuite) TestCheckTradingMode() {
+	s.mockTrader.positions = []map[string]interface{}{
+		{"symbol": "BTCUSDT", "side": "long", "positionAmt": 0.5},
+		{"symbol": "ETHUSDT", "side": "short", "positionAmt": -2.0},
+		{"symbol": "SOLUSDT", "side": "long", "positionAmt": 10.0},
+		{"symbol": "BNBUSDT", "side": "short", "positionAmt": -3.0},
+	}
+	s.autoTrader.positionStopLoss["BTCUSDT_long"] = 48000
+	s.autoTrader.positionStopLoss["ETHUSDT_short"] = 3200
+	// SOLUSDT 没有本地止损记录（如重启后），以交易所止损单为准；BNBUSDT 当前止损未知
+	s.mockTrader.openOrders = []decision.OpenOrderInfo{
+		{Symbol: "SOLUSDT", Type: "STOP_MARKET", Side: "SELL", PositionSide: "LONG", StopPrice: 140},
+		{Symbol: "BNBUSDT", Type: "TAKE_PROFIT_MARKET", Side: "BUY", PositionSide: "SHORT", StopPrice: 500},
+	}
+
+	tests := []struct {
+		name    string
+		mode    string
+		d       decision.Decision
+		allowed bool
+	}{
+		{"正常模式_允许开仓", TradingModeNormal, decision.Decision{Action: "open_long", Symbol: "BTCUSDT"}, true},
+		{"只减仓_拒绝开多", TradingModeReduceOnly, decision.Decision{Action: "open_long", Symbol: "BTCUSDT"}, false},
+		{"只减仓_拒绝开空", TradingModeReduceOnly, decision.Decision{Action: "open_short", Symbol: "BTCUSDT"}, false},
+		{"只减仓_允许平仓", TradingModeReduceOnly, decision.Decision{Action: "close_long", Symbol: "BTCUSDT"}, true},
+		{"只减仓_允许部分平仓", TradingModeReduceOnly, decision.Decision{Action: "partial_close", Symbol: "BTCUSDT"}, true},
+		{"只减仓_允许收紧多头止损", TradingModeReduceOnly, decision.Decision{Action: "update_stop_loss", Symbol: "BTCUSDT", NewStopLoss: 49000}, true},
+		{"只减仓_拒绝放宽多头止损", TradingModeReduceOnly, decision.Decision{Action: "update_stop_loss", Symbol: "BTCUSDT", NewStopLoss: 47000}, false},
+		{"只减仓_允许收紧空头止损", TradingModeReduceOnly, decision.Decision{Action: "update_stop_loss", Symbol: "ETHUSDT", NewStopLoss: 3100}, true},
+		{"只减仓_拒绝放宽空头止损", TradingModeReduceOnly, decision.Decision{Action: "update_stop_loss", Symbol: "ETHUSDT", NewStopLoss: 3300}, false},
+		{"只减仓_按交易所止损单允许收紧", TradingModeReduceOnly, decision.Decision{Action: "update_stop_loss", Symbol: "SOLUSDT", NewStopLoss: 145}, true},
+		{"只减仓_按交易所止损单拒绝放宽", TradingModeReduceOnly, decision.Decision{Action: "update_stop_loss", Symbol: "SOLUSDT", NewStopLoss: 130}, false},
+		{"只减仓_当前止损未知时拒绝", TradingModeReduceOnly, decision.Decision{Action: "update_stop_loss", Symbol: "BNBUSDT", NewStopLoss: 520}, false},
+		{"只减仓_持仓不存在时拒绝", TradingModeReduceOnly, decision.Decision{Action: "update_stop_loss", Symbol: "XRPUSDT", NewStopLoss: 1}, false},
+		{"只减仓_拒绝调整止盈", TradingModeReduceOnly, decision.Decision{Action: "update_take_profit", Symbol: "BTCUSDT", NewTakeProfit: 60000}, false},
+	}
+
+	for _, tt := range tests {
+		s.Run(tt.name, func() {
+			s.Require().NoError(s.autoTrader.SetTradingMode(tt.mode))
+			allowed, note := s.autoTrader.checkTradingMode(&tt.d)
+			s.Equal(tt.allowed, allowed, note)
+		})
+	}
+
+	s.Error(s.autoTrader.SetTradingMode("turbo"))
+	s.Equal(TradingModeReduceOnly, s.autoTrader.GetStatus()["trading_mode"])
+}
+
+// TestTradingMode_PausedSkipsCycle 测试暂停模式下跳过AI决策周期（mcpClient 为 nil，若调用AI会 panic）
+func (s *AutoTraderTestSuite) TestTradingMode_PausedSkipsCycle() {
+	store := &fakeTradingModeStore{}
+	s.autoTrader.database = store
+
+	s.Require().NoError(s.autoTrader.SetTradingMode(TradingModePaused))
+	s.Equal([]string{TradingModePaused}, store.modes, "运行模式应持久化")
+
+	s.NoError(s.autoTrader.runCycle())
+}
+
+// TestTradingMode_PausedStillMonitors 测试暂停期间仍检测被动平仓并处理过期提案
+func (s *AutoTraderTestSuite) TestTradingMode_PausedStillMonitors() {
+	publisher := s.useEventPublisher()
+	s.autoTrader.database = &fakeTradingModeStore{}
+	s.autoTrader.decisionLogger = logger.NewDecisionLogger(s.T().TempDir())
+	s.Require().NoError(s.autoTrader.SetTradingMode(TradingModePaused))
+
+	// 暂停期间交易所止损单成交，持仓消失
+	s.autoTrader.lastPositions = map[string]decision.PositionInfo{
+		"BTCUSDT_long": {Symbol: "BTCUSDT", Side: "long", EntryPrice: 100, MarkPrice: 95, Quantity: 1, Leverage: 5, StopLoss: 95},
+	}
+	s.mockTrader.positions = []map[string]interface{}{}
+	s.autoTrader.proposals = map[string]*Proposal{
+		"p1": {ID: "p1", Symbol: "ETHUSDT", Action: "open_long", Status: ProposalStatusPending, ExpiresAt: time.Now().Add(-time.Second)},
+	}
+
+	s.NoError(s.autoTrader.runCycle())
+
+	s.Equal(ProposalStatusExpired, s.autoTrader.proposals["p1"].Status, "暂停期间提案仍应过期")
+	s.Empty(s.autoTrader.lastPositions, "持仓快照应更新，避免恢复后重复记录")
+	s.Contains(publisher.types(), events.TypePositionClosed)
+
+	records, err := s.autoTrader.decisionLogger.GetLatestRecords(10)
+	s.Require().NoError(err)
+	var autoClose *logger.DecisionAction
+	for _, r := range records {
+		for i := range r.Decisions {
+			if r.Decisions[i].Action == "auto_close_long" {
+				autoClose = &r.Decisions[i]
+			}
+		}
+	}
+	s.Require().NotNil(autoClose, "被动平仓应写入决策记录")
+	s.Equal("stop_loss", autoClose.Error)
+}
+
+// TestTradingMode_CloseOut 测试清仓模式：平掉所有持仓、恢复正常模式并标记停止
+func (s *AutoTraderTestSuite) TestTradingMode_CloseOut() {
+	s.patches.ApplyFunc(market.Get, func(symbol string) (*market.Data, error) {
+		return &market.Data{Symbol: symbol, CurrentPrice: 100.0}, nil
+	})
+
+	store := &fakeTradingModeStore{}
+	s.autoTrader.database = store
+	s.autoTrader.decisionLogger = logger.NewDecisionLogger(s.T().TempDir())
+	s.mockTrader.positions = []map[string]interface{}{
+		{"symbol": "BTCUSDT", "side": "long", "positionAmt": 0.5},
+	}
+
+	// 平仓失败时保持清仓模式
+	s.mockTrader.shouldFailCloseLong = true
+	_, err := s.autoTrader.CloseOut()
+	s.Error(err)
+	s.Equal(TradingModeCloseOut, s.autoTrader.GetTradingMode())
+	s.Nil(store.running)
+
+	s.mockTrader.shouldFailCloseLong = false
+	actions, err := s.autoTrader.CloseOut()
+	s.Require().NoError(err)
+	s.Len(actions, 1)
+	s.Equal(TradingModeNormal, s.autoTrader.GetTradingMode())
+	s.Require().NotNil(store.running)
+	s.False(*store.running)
+}
@@ -0,0 +1,372 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"nofx/config"
+	"nofx/events"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Webhook 事件类型
+const (
+	EventTradeOpened  = "trade_opened"  // 开仓成交
+	EventTradeClosed  = "trade_closed"  // 平仓（止盈、手动、强平、回撤保护等）
+	EventStopHit      = "stop_hit"      // 止损触发
+	EventRiskBlocked  = "risk_blocked"  // 风控/运行模式否决决策
+	EventTraderError  = "trader_error"  // 决策周期运行失败
+	EventDailySummary = "daily_summary" // 每日汇总（UTC 日切时发送）
+	EventTest         = "test"          // 测试事件（仅通过测试接口发送）
+)
+
+// EventTypes 可订阅的事件类型
+var EventTypes = []string{EventTradeOpened, EventTradeClosed, EventStopHit, EventRiskBlocked, EventTraderError, EventDailySummary}
+
+// IsValidEventType 判断是否为可订阅的事件类型
+func IsValidEventType(eventType string) bool {
+	for _, t := range EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Payload Webhook 请求体
+type Payload struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	CreatedAt  time.Time   `json:"created_at"`
+	TraderID   string      `json:"trader_id,omitempty"`
+	TraderName string      `json:"trader_name,omitempty"`
+	Data       interface{} `json:"data"`
+}
+
+// Store Webhook 订阅与死信存储（由 config.Database 实现）
+type Store interface {
+	GetWebhooks(userID string) ([]*config.WebhookSubscription, error)
+	AddWebhookDeadLetter(dl *config.WebhookDeadLetter) error
+}
+
+// Options 投递参数（零值使用默认值）
+type Options struct {
+	MaxAttempts    int           // 最大尝试次数，默认 5
+	InitialBackoff time.Duration // 首次重试间隔，之后指数翻倍，默认 2s
+	MaxBackoff     time.Duration // 最大重试间隔，默认 5m
+	Timeout        time.Duration // 单次请求超时，默认 10s
+	Workers        int           // 并发投递数，默认 4
+	QueueSize      int           // 投递队列长度，默认 1000
+	Client         *http.Client  // 为空时使用只能连接公网地址的客户端
+}
+
+// Result 单次投递结果
+type Result struct {
+	Success    bool   `json:"success"`
+	StatusCode int    `json:"status_code"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Dispatcher 订阅事件总线，将交易事件签名后推送到用户配置的 Webhook
+type Dispatcher struct {
+	store  Store
+	opts   Options
+	client *http.Client
+	queue  chan *delivery
+
+	mu      sync.Mutex
+	stopped bool
+	pending map[*delivery]*time.Timer // 等待重试的投递
+
+	summaries *summaryTracker
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+}
+
+// delivery 一条待投递消息
+type delivery struct {
+	sub       *config.WebhookSubscription
+	eventType string
+	id        string
+	body      []byte
+	attempts  int
+	lastCode  int
+	lastError string
+}
+
+// NewDispatcher 创建 Webhook 投递器
+func NewDispatcher(store Store, opts Options) *Dispatcher {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.InitialBackoff <= 0 {
+		opts.InitialBackoff = 2 * time.Second
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 5 * time.Minute
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1000
+	}
+	client := opts.Client
+	if client == nil {
+		client = newSafeClient(opts.Timeout)
+	}
+
+	return &Dispatcher{
+		store:     store,
+		opts:      opts,
+		client:    client,
+		queue:     make(chan *delivery, opts.QueueSize),
+		pending:   make(map[*delivery]*time.Timer),
+		summaries: newSummaryTracker(),
+		stopCh:    make(chan struct{}),
+	}
+}
+
+// Start 启动投递协程；bus 不为空时订阅交易员事件并转换为 Webhook 事件
+func (d *Dispatcher) Start(bus *events.Bus) {
+	for i := 0; i < d.opts.Workers; i++ {
+		d.wg.Add(1)
+		go d.worker()
+	}
+	if bus != nil {
+		d.wg.Add(1)
+		go d.consume(bus)
+	}
+	log.Printf("🔔 [Webhook] 投递器已启动（%d 个并发，最多尝试 %d 次）", d.opts.Workers, d.opts.MaxAttempts)
+}
+
+// Stop 停止投递：队列中和等待重试的消息写入死信
+func (d *Dispatcher) Stop() {
+	d.mu.Lock()
+	if d.stopped {
+		d.mu.Unlock()
+		return
+	}
+	d.stopped = true
+	var waiting []*delivery
+	for dl, timer := range d.pending {
+		if timer.Stop() {
+			waiting = append(waiting, dl)
+			d.wg.Done()
+		}
+	}
+	d.pending = map[*delivery]*time.Timer{}
+	d.mu.Unlock()
+
+	close(d.stopCh)
+	d.wg.Wait()
+
+	for len(d.queue) > 0 {
+		waiting = append(waiting, <-d.queue)
+	}
+	for _, dl := range waiting {
+		if dl.lastError == "" {
+			dl.lastError = "服务停止，未完成投递"
+		}
+		d.deadLetter(dl)
+	}
+}
+
+// Emit 向用户所有订阅了该事件类型的 Webhook 投递消息
+func (d *Dispatcher) Emit(userID string, payload Payload) {
+	if payload.ID == "" {
+		payload.ID = uuid.New().String()
+	}
+	if payload.CreatedAt.IsZero() {
+		payload.CreatedAt = time.Now().UTC()
+	}
+
+	subs, err := d.store.GetWebhooks(userID)
+	if err != nil {
+		log.Printf("⚠️ [Webhook] 获取用户 %s 的订阅失败: %v", userID, err)
+		return
+	}
+	var body []byte
+	for _, sub := range subs {
+		if !sub.Accepts(payload.Type) {
+			continue
+		}
+		if body == nil {
+			if body, err = json.Marshal(payload); err != nil {
+				log.Printf("⚠️ [Webhook] 序列化事件失败: %v", err)
+				return
+			}
+		}
+		d.enqueue(&delivery{sub: sub, eventType: payload.Type, id: payload.ID, body: body})
+	}
+}
+
+// SendTest 同步发送一条测试事件（不重试、不写死信）
+func (d *Dispatcher) SendTest(sub *config.WebhookSubscription) Result {
+	payload := Payload{
+		ID:        uuid.New().String(),
+		Type:      EventTest,
+		CreatedAt: time.Now().UTC(),
+		Data: map[string]interface{}{
+			"message":         "这是一条来自 nofx 的测试事件",
+			"subscription_id": sub.ID,
+		},
+	}
+	body, _ := json.Marshal(payload)
+	return d.send(sub, EventTest, payload.ID, body)
+}
+
+// Redeliver 同步重新投递一条死信（不重试）
+func (d *Dispatcher) Redeliver(sub *config.WebhookSubscription, dl *config.WebhookDeadLetter) Result {
+	var payload Payload
+	if err := json.Unmarshal([]byte(dl.Payload), &payload); err != nil {
+		return Result{Error: fmt.Sprintf("死信内容无效: %v", err)}
+	}
+	return d.send(sub, dl.EventType, payload.ID, []byte(dl.Payload))
+}
+
+// enqueue 加入投递队列（已停止或队列已满时直接写入死信）
+func (d *Dispatcher) enqueue(dl *delivery) {
+	d.mu.Lock()
+	stopped := d.stopped
+	d.mu.Unlock()
+	if stopped {
+		dl.lastError = "服务停止，未完成投递"
+		d.deadLetter(dl)
+		return
+	}
+
+	select {
+	case d.queue <- dl:
+	default:
+		dl.lastError = "投递队列已满"
+		d.deadLetter(dl)
+	}
+}
+
+func (d *Dispatcher) worker() {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-d.stopCh:
+			return
+		case dl := <-d.queue:
+			d.attempt(dl)
+		}
+	}
+}
+
+// attempt 执行一次投递，失败时按指数退避安排重试，不可重试或次数耗尽时写入死信
+func (d *Dispatcher) attempt(dl *delivery) {
+	dl.attempts++
+	result := d.send(dl.sub, dl.eventType, dl.id, dl.body)
+	if result.Success {
+		return
+	}
+	dl.lastCode = result.StatusCode
+	dl.lastError = result.Error
+
+	if !retryable(result.StatusCode) || dl.attempts >= d.opts.MaxAttempts {
+		log.Printf("⚠️ [Webhook] %s 投递失败（第 %d 次），写入死信: %s", dl.sub.URL, dl.attempts, dl.lastError)
+		d.deadLetter(dl)
+		return
+	}
+
+	backoff := d.backoff(dl.attempts)
+	d.mu.Lock()
+	if d.stopped {
+		d.mu.Unlock()
+		d.deadLetter(dl)
+		return
+	}
+	// 等待中的重试计入 wg，保证 Stop 返回后不会再写死信
+	d.wg.Add(1)
+	d.pending[dl] = time.AfterFunc(backoff, func() {
+		defer d.wg.Done()
+		d.mu.Lock()
+		delete(d.pending, dl)
+		d.mu.Unlock()
+		d.enqueue(dl)
+	})
+	d.mu.Unlock()
+}
+
+// send 签名并发送一次请求
+func (d *Dispatcher) send(sub *config.WebhookSubscription, eventType, id string, body []byte) Result {
+	start := time.Now()
+	req, err := http.NewRequest(http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return Result{Error: fmt.Sprintf("创建请求失败: %v", err)}
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "nofx-webhook/1.0")
+	req.Header.Set(HeaderEvent, eventType)
+	req.Header.Set(HeaderDelivery, id)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(sub.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	result := Result{DurationMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Error = fmt.Sprintf("请求失败: %v", err)
+		return result
+	}
+	defer resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+		result.Success = true
+		return result
+	}
+	// 只返回状态码，不回显响应内容（测试投递的结果会返回给调用方）
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	result.Error = fmt.Sprintf("HTTP %d", resp.StatusCode)
+	return result
+}
+
+// retryable 网络错误、超时、限流和 5xx 可重试，其余 4xx 视为配置错误直接进入死信
+func retryable(statusCode int) bool {
+	return statusCode == 0 || statusCode == http.StatusRequestTimeout ||
+		statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// backoff 第 n 次失败后的重试间隔（指数退避，附带最多 20% 抖动）
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.opts.InitialBackoff
+	for i := 1; i < attempts && wait < d.opts.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > d.opts.MaxBackoff {
+		wait = d.opts.MaxBackoff
+	}
+	return wait + time.Duration(rand.Int63n(int64(wait)/5+1))
+}
+
+func (d *Dispatcher) deadLetter(dl *delivery) {
+	err := d.store.AddWebhookDeadLetter(&config.WebhookDeadLetter{
+		SubscriptionID: dl.sub.ID,
+		UserID:         dl.sub.UserID,
+		EventType:      dl.eventType,
+		Payload:        string(dl.body),
+		Attempts:       dl.attempts,
+		LastStatus:     dl.lastCode,
+		LastError:      dl.lastError,
+	})
+	if err != nil {
+		log.Printf("⚠️ [Webhook] 写入死信失败: %v", err)
+	}
+}
@@ -0,0 +1,256 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"nofx/config"
+	"nofx/decision"
+	"nofx/events"
+	"nofx/logger"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeStore 内存订阅与死信存储
+type fakeStore struct {
+	mu          sync.Mutex
+	subs        []*config.WebhookSubscription
+	deadLetters []*config.WebhookDeadLetter
+}
+
+func (f *fakeStore) GetWebhooks(userID string) ([]*config.WebhookSubscription, error) {
+	var result []*config.WebhookSubscription
+	for _, s := range f.subs {
+		if s.UserID == userID {
+			result = append(result, s)
+		}
+	}
+	return result, nil
+}
+
+func (f *fakeStore) AddWebhookDeadLetter(dl *config.WebhookDeadLetter) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.deadLetters = append(f.deadLetters, dl)
+	return nil
+}
+
+func (f *fakeStore) deadLetterCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.deadLetters)
+}
+
+// received 接收端记录的请求
+type received struct {
+	event   string
+	payload Payload
+}
+
+// newReceiver 创建校验签名的接收端（本地回环地址，测试期间允许投递），statusFor 返回第 n 次请求的状态码
+func newReceiver(t *testing.T, secret string, statusFor func(n int) int) (*httptest.Server, chan received, *int32) {
+	SetAllowPrivateTargets(true)
+	t.Cleanup(func() { SetAllowPrivateTargets(false) })
+	ch := make(chan received, 32)
+	var count int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(atomic.AddInt32(&count, 1))
+		body, _ := io.ReadAll(r.Body)
+		ts, _ := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		assert.True(t, Verify(secret, r.Header.Get(HeaderSignature), ts, body), "签名校验失败")
+
+		var p Payload
+		require.NoError(t, json.Unmarshal(body, &p))
+		assert.Equal(t, p.ID, r.Header.Get(HeaderDelivery))
+
+		status := statusFor(n)
+		w.WriteHeader(status)
+		if status < 300 {
+			ch <- received{event: r.Header.Get(HeaderEvent), payload: p}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, ch, &count
+}
+
+func fastOptions() Options {
+	return Options{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond, Workers: 2}
+}
+
+func waitReceived(t *testing.T, ch chan received) received {
+	select {
+	case r := <-ch:
+		return r
+	case <-time.After(3 * time.Second):
+		t.Fatal("未收到 Webhook")
+		return received{}
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	body := []byte(`{"a":1}`)
+	sig := Sign("secret", 1700000000, body)
+	assert.True(t, Verify("secret", sig, 1700000000, body))
+	assert.False(t, Verify("other", sig, 1700000000, body))
+	assert.False(t, Verify("secret", sig, 1700000001, body), "时间戳参与签名")
+	assert.False(t, Verify("secret", sig, 1700000000, []byte(`{"a":2}`)))
+}
+
+func TestDispatcher_RetryThenSuccess(t *testing.T) {
+	server, ch, count := newReceiver(t, "s3cret", func(n int) int {
+		if n < 3 {
+			return http.StatusServiceUnavailable
+		}
+		return http.StatusOK
+	})
+	store := &fakeStore{subs: []*config.WebhookSubscription{
+		{ID: "w1", UserID: "u1", URL: server.URL, Secret: "s3cret", Enabled: true},
+		{ID: "w2", UserID: "u1", URL: server.URL, Secret: "s3cret", Enabled: true, EventTypes: []string{EventDailySummary}},
+	}}
+	d := NewDispatcher(store, fastOptions())
+	d.Start(nil)
+	defer d.Stop()
+
+	d.Emit("u1", Payload{Type: EventTradeOpened, Data: map[string]interface{}{"symbol": "BTCUSDT"}})
+
+	r := waitReceived(t, ch)
+	assert.Equal(t, EventTradeOpened, r.event)
+	assert.Equal(t, int32(3), atomic.LoadInt32(count), "未订阅该事件的 Webhook 不应收到")
+	assert.Equal(t, 0, store.deadLetterCount())
+}
+
+func TestDispatcher_DeadLetter(t *testing.T) {
+	retryServer, _, retryCount := newReceiver(t, "s", func(int) int { return http.StatusInternalServerError })
+	rejectServer, _, rejectCount := newReceiver(t, "s", func(int) int { return http.StatusBadRequest })
+	store := &fakeStore{subs: []*config.WebhookSubscription{
+		{ID: "retry", UserID: "u1", URL: retryServer.URL, Secret: "s", Enabled: true},
+		{ID: "reject", UserID: "u1", URL: rejectServer.URL, Secret: "s", Enabled: true},
+		{ID: "disabled", UserID: "u1", URL: rejectServer.URL, Secret: "s", Enabled: false},
+	}}
+	d := NewDispatcher(store, fastOptions())
+	d.Start(nil)
+	defer d.Stop()
+
+	d.Emit("u1", Payload{Type: EventRiskBlocked})
+
+	require.Eventually(t, func() bool { return store.deadLetterCount() == 2 }, 3*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(3), atomic.LoadInt32(retryCount), "5xx 应重试到最大次数")
+	assert.Equal(t, int32(1), atomic.LoadInt32(rejectCount), "4xx 不应重试")
+
+	letters := map[string]*config.WebhookDeadLetter{}
+	for _, dl := range store.deadLetters {
+		letters[dl.SubscriptionID] = dl
+	}
+	assert.Equal(t, 3, letters["retry"].Attempts)
+	assert.Equal(t, http.StatusInternalServerError, letters["retry"].LastStatus)
+	assert.Equal(t, 1, letters["reject"].Attempts)
+	assert.Equal(t, EventRiskBlocked, letters["reject"].EventType)
+
+	// 死信可重新投递（请求体与消息ID不变）
+	okServer, ch, _ := newReceiver(t, "s", func(int) int { return http.StatusOK })
+	result := d.Redeliver(&config.WebhookSubscription{ID: "reject", URL: okServer.URL, Secret: "s"}, letters["reject"])
+	assert.True(t, result.Success)
+	assert.Equal(t, EventRiskBlocked, waitReceived(t, ch).event)
+}
+
+func TestDispatcher_StopDeadLettersPendingRetries(t *testing.T) {
+	server, _, _ := newReceiver(t, "s", func(int) int { return http.StatusBadGateway })
+	store := &fakeStore{subs: []*config.WebhookSubscription{{ID: "w1", UserID: "u1", URL: server.URL, Secret: "s", Enabled: true}}}
+	opts := fastOptions()
+	opts.InitialBackoff = time.Hour
+	d := NewDispatcher(store, opts)
+	d.Start(nil)
+
+	d.Emit("u1", Payload{Type: EventTraderError})
+	require.Eventually(t, func() bool {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		return len(d.pending) == 1
+	}, 3*time.Second, 10*time.Millisecond)
+
+	d.Stop()
+	require.Equal(t, 1, store.deadLetterCount())
+	assert.Equal(t, 1, store.deadLetters[0].Attempts)
+}
+
+func TestDispatcher_SendTest(t *testing.T) {
+	server, ch, _ := newReceiver(t, "s", func(int) int { return http.StatusNoContent })
+	d := NewDispatcher(&fakeStore{}, fastOptions())
+
+	result := d.SendTest(&config.WebhookSubscription{ID: "w1", URL: server.URL, Secret: "s"})
+	assert.True(t, result.Success)
+	assert.Equal(t, http.StatusNoContent, result.StatusCode)
+	assert.Equal(t, EventTest, waitReceived(t, ch).event)
+
+	result = d.SendTest(&config.WebhookSubscription{ID: "w1", URL: "http://127.0.0.1:1", Secret: "s"})
+	assert.False(t, result.Success)
+	assert.NotEmpty(t, result.Error)
+}
+
+func TestDispatcher_BusEvents(t *testing.T) {
+	server, ch, _ := newReceiver(t, "s", func(int) int { return http.StatusOK })
+	store := &fakeStore{subs: []*config.WebhookSubscription{{ID: "w1", UserID: "u1", URL: server.URL, Secret: "s", Enabled: true}}}
+	d := NewDispatcher(store, fastOptions())
+	d.opts.Workers = 1 // 单协程投递，保证顺序
+	bus := events.NewBus(100)
+	d.Start(bus)
+	defer d.Stop()
+	require.Eventually(t, func() bool { return bus.SubscriberCount() == 1 }, time.Second, 5*time.Millisecond)
+
+	publish := func(eventType string, data interface{}) {
+		bus.Publish(events.Event{Type: eventType, UserID: "u1", TraderID: "t1", TraderName: "alpha", Data: data})
+	}
+	publish(events.TypeCycleFinished, map[string]interface{}{"cycle": 1, "error": "风险控制暂停中", "skipped": true})
+	publish(events.TypePositionOpened, map[string]interface{}{
+		"source": "ai",
+		"action": logger.DecisionAction{Action: "open_short", Symbol: "ETHUSDT", Quantity: 2, Price: 3000, Leverage: 5, Success: true},
+	})
+	publish(events.TypePositionClosed, map[string]interface{}{
+		"source": "exchange",
+		"reason": "stop_loss",
+		"action": logger.DecisionAction{Action: "auto_close_short", Symbol: "ETHUSDT", Price: 3100, Success: true},
+	})
+	publish(events.TypeRiskVeto, map[string]interface{}{"source": "risk_guard", "reason": "超过单日亏损上限", "symbol": "BTCUSDT"})
+	publish(events.TypeCycleFinished, map[string]interface{}{"cycle": 2, "error": "获取AI决策失败: timeout"})
+	publish(events.TypeBalanceUpdate, decision.AccountInfo{TotalEquity: 1000})
+
+	opened := waitReceived(t, ch)
+	assert.Equal(t, EventTradeOpened, opened.event)
+	assert.Equal(t, "t1", opened.payload.TraderID)
+	assert.Equal(t, "alpha", opened.payload.TraderName)
+	trade := opened.payload.Data.(map[string]interface{})
+	assert.Equal(t, "short", trade["side"])
+	assert.Equal(t, "ETHUSDT", trade["symbol"])
+	assert.Equal(t, float64(5), trade["leverage"])
+
+	stop := waitReceived(t, ch)
+	assert.Equal(t, EventStopHit, stop.event)
+	assert.Equal(t, "stop_loss", stop.payload.Data.(map[string]interface{})["close_reason"])
+
+	assert.Equal(t, EventRiskBlocked, waitReceived(t, ch).event)
+
+	traderErr := waitReceived(t, ch)
+	assert.Equal(t, EventTraderError, traderErr.event, "风控暂停跳过的周期不应视为错误")
+	assert.Equal(t, "获取AI决策失败: timeout", traderErr.payload.Data.(map[string]interface{})["error"])
+
+	// 日切后发送每日汇总
+	d.flushSummaries(time.Now().Add(24 * time.Hour))
+	summary := waitReceived(t, ch)
+	assert.Equal(t, EventDailySummary, summary.event)
+	data := summary.payload.Data.(map[string]interface{})
+	assert.Equal(t, float64(2), data["cycles"])
+	assert.Equal(t, float64(1), data["errors"])
+	assert.Equal(t, float64(1), data["trades_opened"])
+	assert.Equal(t, float64(1), data["trades_closed"])
+	assert.Equal(t, float64(1), data["stop_hits"])
+	assert.Equal(t, float64(1), data["risk_blocked"])
+	assert.Equal(t, float64(1000), data["end_equity"])
+}
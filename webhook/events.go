@@ -0,0 +1,276 @@
+package webhook
+
+import (
+	"log"
+	"nofx/decision"
+	"nofx/events"
+	"nofx/logger"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 订阅的交易员事件（与决策记录 DecisionAction 产生的位置一致）
+var busEventTypes = map[string]bool{
+	events.TypePositionOpened: true,
+	events.TypePositionClosed: true,
+	events.TypeRiskVeto:       true,
+	events.TypeCycleFinished:  true,
+	events.TypeBalanceUpdate:  true,
+}
+
+// summaryCheckInterval 检查日切的间隔（交易员空闲时也能按时发送每日汇总）
+const summaryCheckInterval = time.Minute
+
+// consume 消费事件总线；订阅因消费过慢被关闭时按最后事件ID重新订阅补发
+func (d *Dispatcher) consume(bus *events.Bus) {
+	defer d.wg.Done()
+
+	filter := func(e events.Event) bool { return busEventTypes[e.Type] }
+	var lastID uint64
+	ticker := time.NewTicker(summaryCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		sub, missed := bus.Subscribe(filter, lastID, 1024)
+		for _, e := range missed {
+			d.handleEvent(e)
+			lastID = e.ID
+		}
+
+	loop:
+		for {
+			select {
+			case <-d.stopCh:
+				sub.Close()
+				return
+			case now := <-ticker.C:
+				d.flushSummaries(now)
+			case e, ok := <-sub.C:
+				if !ok {
+					log.Printf("⚠️ [Webhook] 事件订阅被关闭，从事件 #%d 重新订阅", lastID)
+					break loop
+				}
+				d.handleEvent(e)
+				lastID = e.ID
+			}
+		}
+	}
+}
+
+// handleEvent 更新每日统计并投递对应的 Webhook 事件
+func (d *Dispatcher) handleEvent(e events.Event) {
+	if e.UserID == "" {
+		return
+	}
+	for _, s := range d.summaries.record(e) {
+		d.Emit(s.userID, s.payload())
+	}
+
+	eventType, data, ok := convertEvent(e)
+	if !ok {
+		return
+	}
+	d.Emit(e.UserID, Payload{
+		Type:       eventType,
+		CreatedAt:  e.Timestamp.UTC(),
+		TraderID:   e.TraderID,
+		TraderName: e.TraderName,
+		Data:       data,
+	})
+}
+
+// flushSummaries 发送已跨日的每日汇总
+func (d *Dispatcher) flushSummaries(now time.Time) {
+	for _, s := range d.summaries.flush(now) {
+		d.Emit(s.userID, s.payload())
+	}
+}
+
+// convertEvent 将交易员事件转换为 Webhook 事件
+func convertEvent(e events.Event) (string, map[string]interface{}, bool) {
+	data, _ := e.Data.(map[string]interface{})
+
+	switch e.Type {
+	case events.TypePositionOpened:
+		return EventTradeOpened, tradeData(data), true
+
+	case events.TypePositionClosed:
+		trade := tradeData(data)
+		if trade["close_reason"] == "stop_loss" {
+			return EventStopHit, trade, true
+		}
+		return EventTradeClosed, trade, true
+
+	case events.TypeRiskVeto:
+		return EventRiskBlocked, data, data != nil
+
+	case events.TypeCycleFinished:
+		errMsg, _ := data["error"].(string)
+		skipped, _ := data["skipped"].(bool)
+		if errMsg == "" || skipped {
+			return "", nil, false
+		}
+		return EventTraderError, map[string]interface{}{
+			"cycle": data["cycle"],
+			"error": errMsg,
+		}, true
+	}
+	return "", nil, false
+}
+
+// tradeData 从决策操作提取成交信息
+func tradeData(data map[string]interface{}) map[string]interface{} {
+	action, _ := data["action"].(logger.DecisionAction)
+	side := "long"
+	if strings.HasSuffix(action.Action, "_short") {
+		side = "short"
+	}
+
+	closeReason := action.CloseReason
+	if reason, _ := data["reason"].(string); reason != "" {
+		closeReason = reason // 交易所侧触发的平仓原因
+	}
+
+	trade := map[string]interface{}{
+		"source":   data["source"],
+		"action":   action.Action,
+		"symbol":   action.Symbol,
+		"side":     side,
+		"quantity": action.Quantity,
+		"price":    action.Price,
+		"order_id": action.OrderID,
+	}
+	if action.Leverage > 0 {
+		trade["leverage"] = action.Leverage
+	}
+	if closeReason != "" {
+		trade["close_reason"] = closeReason
+	}
+	if action.PnL != 0 {
+		trade["pnl"] = action.PnL
+	}
+	if action.Reason != "" {
+		trade["reason"] = action.Reason
+	}
+	return trade
+}
+
+// dailySummary 单个交易员一天（UTC）的统计
+type dailySummary struct {
+	userID      string
+	traderID    string
+	traderName  string
+	date        string
+	cycles      int
+	errors      int
+	opened      int
+	closed      int
+	stopHits    int
+	blocked     int
+	closedPnL   float64
+	startEquity float64
+	endEquity   float64
+}
+
+func (s *dailySummary) payload() Payload {
+	data := map[string]interface{}{
+		"date":          s.date,
+		"cycles":        s.cycles,
+		"errors":        s.errors,
+		"trades_opened": s.opened,
+		"trades_closed": s.closed,
+		"stop_hits":     s.stopHits,
+		"risk_blocked":  s.blocked,
+		"closed_pnl":    s.closedPnL,
+	}
+	if s.startEquity > 0 {
+		data["start_equity"] = s.startEquity
+		data["end_equity"] = s.endEquity
+		data["equity_change"] = s.endEquity - s.startEquity
+		data["equity_change_pct"] = (s.endEquity - s.startEquity) / s.startEquity * 100
+	}
+	return Payload{
+		Type:       EventDailySummary,
+		TraderID:   s.traderID,
+		TraderName: s.traderName,
+		Data:       data,
+	}
+}
+
+// summaryTracker 按交易员累计当日统计，日切时产出汇总
+type summaryTracker struct {
+	mu      sync.Mutex
+	traders map[string]*dailySummary
+}
+
+func newSummaryTracker() *summaryTracker {
+	return &summaryTracker{traders: make(map[string]*dailySummary)}
+}
+
+// record 累计事件，返回因跨日而结束的汇总
+func (t *summaryTracker) record(e events.Event) []*dailySummary {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	date := e.Timestamp.UTC().Format("2006-01-02")
+	var finished []*dailySummary
+	s := t.traders[e.TraderID]
+	if s != nil && s.date != date {
+		finished = append(finished, s)
+		s = nil
+	}
+	if s == nil {
+		s = &dailySummary{userID: e.UserID, traderID: e.TraderID, date: date}
+		t.traders[e.TraderID] = s
+	}
+	s.traderName = e.TraderName
+
+	data, _ := e.Data.(map[string]interface{})
+	switch e.Type {
+	case events.TypeCycleFinished:
+		s.cycles++
+		if errMsg, _ := data["error"].(string); errMsg != "" {
+			if skipped, _ := data["skipped"].(bool); !skipped {
+				s.errors++
+			}
+		}
+	case events.TypePositionOpened:
+		s.opened++
+	case events.TypePositionClosed:
+		s.closed++
+		trade := tradeData(data)
+		if trade["close_reason"] == "stop_loss" {
+			s.stopHits++
+		}
+		if pnl, ok := trade["pnl"].(float64); ok {
+			s.closedPnL += pnl
+		}
+	case events.TypeRiskVeto:
+		s.blocked++
+	case events.TypeBalanceUpdate:
+		if account, ok := e.Data.(decision.AccountInfo); ok && account.TotalEquity > 0 {
+			if s.startEquity == 0 {
+				s.startEquity = account.TotalEquity
+			}
+			s.endEquity = account.TotalEquity
+		}
+	}
+	return finished
+}
+
+// flush 取出日期早于 now（UTC）的汇总
+func (t *summaryTracker) flush(now time.Time) []*dailySummary {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	today := now.UTC().Format("2006-01-02")
+	var finished []*dailySummary
+	for traderID, s := range t.traders {
+		if s.date < today {
+			finished = append(finished, s)
+			delete(t.traders, traderID)
+		}
+	}
+	return finished
+}
@@ -0,0 +1,34 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+)
+
+// 请求头
+const (
+	HeaderEvent     = "X-Nofx-Event"     // 事件类型
+	HeaderDelivery  = "X-Nofx-Delivery"  // 消息ID（重试时不变，可用于去重）
+	HeaderTimestamp = "X-Nofx-Timestamp" // 签名时间戳（Unix 秒）
+	HeaderSignature = "X-Nofx-Signature" // sha256=<hex>
+)
+
+// Sign 计算签名：HMAC-SHA256(secret, "<timestamp>.<body>")，包含时间戳以防重放
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 校验签名（供接收方参考实现与测试使用）
+func Verify(secret, signature string, timestamp int64, body []byte) bool {
+	if !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
@@ -0,0 +1,77 @@
+package webhook
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// allowPrivateTargets 允许投递到回环/内网/链路本地地址（默认禁止，防止 Webhook 被用来探测内网）
+var allowPrivateTargets atomic.Bool
+
+// SetAllowPrivateTargets 设置是否允许投递到内网地址（仅用于测试或受信任的内网部署）
+func SetAllowPrivateTargets(allow bool) {
+	allowPrivateTargets.Store(allow)
+}
+
+// isBlockedIP 判断地址是否属于禁止投递的回环、内网、链路本地或未指定地址
+func isBlockedIP(ip net.IP) bool {
+	if allowPrivateTargets.Load() {
+		return false
+	}
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast()
+}
+
+// ValidateURL 校验 Webhook 地址：仅支持 http/https，且主机解析后的所有地址都不能是内网地址
+func ValidateURL(rawURL string) (string, error) {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return "", fmt.Errorf("无效的Webhook地址，仅支持 http/https")
+	}
+
+	host := parsed.Hostname()
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		ips, err = net.LookupIP(host)
+		if err != nil || len(ips) == 0 {
+			return "", fmt.Errorf("无法解析Webhook主机: %s", host)
+		}
+	}
+	for _, ip := range ips {
+		if isBlockedIP(ip) {
+			return "", fmt.Errorf("Webhook地址不能指向内网、回环或链路本地地址: %s", host)
+		}
+	}
+	return parsed.String(), nil
+}
+
+// guardDial 建立连接前校验实际连接的地址（防止 DNS 重绑定与重定向到内网）
+func guardDial(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || isBlockedIP(ip) {
+		return fmt.Errorf("禁止连接内网地址: %s", host)
+	}
+	return nil
+}
+
+// newSafeClient 创建只能连接公网地址的 HTTP 客户端（不使用环境变量代理，避免绕过地址校验）
+func newSafeClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: guardDial}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConnsPerHost: 2,
+		},
+	}
+}
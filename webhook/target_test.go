@@ -0,0 +1,57 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"nofx/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateURL(t *testing.T) {
+	tests := []struct {
+		url   string
+		valid bool
+	}{
+		{"https://203.0.113.10/hook", true},
+		{"ftp://203.0.113.10/hook", false},
+		{"http:///hook", false},
+		{"http://127.0.0.1:8080/hook", false},
+		{"http://localhost/hook", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"http://10.0.0.5/hook", false},
+		{"http://192.168.1.1/hook", false},
+		{"http://[::1]/hook", false},
+		{"http://0.0.0.0/hook", false},
+	}
+	for _, tt := range tests {
+		_, err := ValidateURL(tt.url)
+		assert.Equal(t, tt.valid, err == nil, "%s: %v", tt.url, err)
+	}
+}
+
+func TestDispatcher_BlocksPrivateTargetsAndHidesBody(t *testing.T) {
+	var hits int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("internal-secret-page"))
+	}))
+	defer server.Close()
+
+	d := NewDispatcher(&fakeStore{}, fastOptions())
+	result := d.SendTest(&config.WebhookSubscription{ID: "w1", URL: server.URL, Secret: "s"})
+	assert.False(t, result.Success)
+	assert.Zero(t, hits, "连接时应拒绝回环地址")
+
+	// 允许内网时可以投递，但失败结果只包含状态码
+	SetAllowPrivateTargets(true)
+	defer SetAllowPrivateTargets(false)
+	result = d.SendTest(&config.WebhookSubscription{ID: "w1", URL: server.URL, Secret: "s"})
+	require.Equal(t, http.StatusForbidden, result.StatusCode)
+	assert.Equal(t, "HTTP 403", result.Error)
+	assert.NotContains(t, result.Error, "internal-secret-page")
+}